@@ -0,0 +1,52 @@
+package analysis
+
+// SignalAccuracyWindow 本地信号滚动验证的回看交易日数
+var SignalAccuracyWindow = 60
+
+// minAccuracySamples 有效预测（信号非中性且次日非平盘）少于该数时不给出命中率
+const minAccuracySamples = 10
+
+// localSignalScore 只用截至第 t 根K线的数据计算本地信号评分（50 为中性）
+func localSignalScore(stockData []StockData, indicators []TechnicalIndicator, method string, t int) (float64, bool) {
+	switch method {
+	case "本地趋势":
+		if t >= len(indicators) {
+			return 0, false
+		}
+		return localTechnicalScore(stockData[:t+1], indicators[:t+1])
+	case "资金流向":
+		return localMoneyFlowScore(CalculateMoneyFlow(stockData[:t+1]))
+	default:
+		return 0, false
+	}
+}
+
+// EvaluateSignalAccuracy 滚动验证本地信号（method 为“本地趋势”或“资金流向”）的次日方向命中率：
+// 在最近 window 个交易日的每一天只用当日及以前的数据给出信号，与次日收盘涨跌比对，中性信号与平盘日不计；
+// 有效样本少于 minAccuracySamples 或方法未知时返回 0
+func EvaluateSignalAccuracy(stockData []StockData, indicators []TechnicalIndicator, method string, window int) float64 {
+	n := len(stockData)
+	start := n - 1 - window
+	if start < 0 {
+		start = 0
+	}
+	hits, total := 0, 0
+	for t := start; t < n-1; t++ {
+		score, ok := localSignalScore(stockData, indicators, method, t)
+		if !ok || score == 50 {
+			continue
+		}
+		change := stockData[t+1].Close - stockData[t].Close
+		if change == 0 {
+			continue
+		}
+		total++
+		if (score > 50) == (change > 0) {
+			hits++
+		}
+	}
+	if total < minAccuracySamples {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
@@ -3,6 +3,7 @@ package analysis
 import (
 	"fmt"
 	"io/ioutil"
+	"log"
 	"math"
 	"os"
 	"path/filepath"
@@ -20,8 +21,6 @@ import (
 
 	"regexp"
 
-	"github.com/chromedp/cdproto/page"
-	"github.com/chromedp/chromedp"
 	"github.com/russross/blackfriday/v2"
 )
 
@@ -47,7 +46,12 @@ type AnalysisParams struct {
 	Risk         string
 	Scope        []string
 	Lang         string
-	Prompt       string // 可选，手动传递prompt
+	Prompt       string  // 可选，手动传递prompt
+	Temperature  float64 // 采样温度，0 表示使用 DefaultTemperature
+	MaxTokens    int     // 最大响应 token 数，0 表示使用 MaxResponseTokens
+	APIURL       string  // chat/completions 地址，为空时按 DeepSeekBaseURL 拼接
+	DataSource   string  // 行情来源 online（默认）/csv，csv 时从 CSVDir 读取 {code}.csv，不访问行情接口
+	CSVDir       string  // csv 模式的 K 线目录，为空时使用 DefaultCSVDir
 
 	// 新增：扩展预测参数
 	PredictionTypes      []string // 预测类型：价格、波动率、成交量、涨跌概率等
@@ -75,6 +79,9 @@ type AnalysisResult struct {
 	Report    string
 	SavedFile string
 	Err       error
+	MoneyFlow *MoneyFlow         // 本地资金流向估算，无本地行情时为nil
+	Scores    map[string]float64 // 多维评分（0-100）
+	Industry  string             // 所属行业，用于板块聚合
 }
 
 type StockData struct {
@@ -83,7 +90,7 @@ type StockData struct {
 	Close  float64
 	Low    float64
 	High   float64
-	Volume float64
+	Volume float64 // 成交量，统一为股
 }
 
 type TechnicalIndicator struct {
@@ -96,6 +103,12 @@ type TechnicalIndicator struct {
 	MA120 float64 // 120日均线
 	MA250 float64 // 250日均线
 
+	// 指数均线族，窗口不足时为 0
+	EMA12  float64 // 12日指数均线
+	EMA26  float64 // 26日指数均线
+	DEMA20 float64 // 20日双重指数均线 2*EMA-EMA(EMA)
+	TEMA20 float64 // 20日三重指数均线 3*EMA-3*EMA(EMA)+EMA(EMA(EMA))
+
 	// MACD指标
 	MACD          float64 // MACD线
 	MACDSignal    float64 // MACD信号线
@@ -150,71 +163,293 @@ type TechnicalIndicator struct {
 	}
 }
 
-// 函数声明补充
-func FetchStockHistory(stockCode, start, end, apiKey string) ([]StockData, []TechnicalIndicator, error) {
-	// 尝试多个数据源，确保数据准确性
-	var stockData []StockData
-	var err error
+// stockDataSource 行情数据源
+type stockDataSource struct {
+	id   string // 白名单标识，见 AllowedDataSources
+	name string
+	fn   func(context.Context, string) ([]StockData, error)
+}
 
-	// 数据源优先级：1. 雪球API 2. 网易API 3. 腾讯API
-	dataSources := []struct {
-		name string
-		fn   func(string) ([]StockData, error)
-	}{
-		{"雪球API", fetchFromXueqiu},
-		{"网易API", fetchFromNetEase},
-		{"腾讯API", fetchFromTencent},
+// 行情数据源默认顺序：1. 雪球API 2. 网易API 3. 腾讯API 4. Yahoo API
+var stockDataSources = []stockDataSource{
+	{sourceXueqiu, "雪球API", fetchFromXueqiu},
+	{sourceNetEase, "网易API", fetchFromNetEase},
+	{sourceTencent, "腾讯API", fetchFromTencent},
+	{sourceYahoo, "Yahoo API", fetchFromYahoo},
+}
+
+// FetchStockHistory 从实时数据源获取行情并按 start/end 裁剪；区间过短时开头含 start 之前的预热K线，见 clipToDateRange
+func FetchStockHistory(ctx context.Context, stockCode, start, end, apiKey string) ([]StockData, []TechnicalIndicator, error) {
+	stockData, _, err := fetchFromSources(ctx, stockCode)
+	if err != nil {
+		return nil, nil, err
 	}
+	stockData, indicators := prepareStockData(stockData, stockCode)
+	stockData, indicators, _, err = clipToDateRange(stockData, indicators, start, end)
+	return stockData, indicators, err
+}
 
-	for _, source := range dataSources {
-		fmt.Printf("[数据源] 尝试从 %s 获取 %s 的历史数据...\n", source.name, stockCode)
-		stockData, err = source.fn(stockCode)
-		if err == nil && len(stockData) > 0 {
-			fmt.Printf("[数据源] ✓ 成功从 %s 获取 %d 条数据\n", source.name, len(stockData))
-			break
+// marketPreferredSource 各市场优先尝试的数据源：A股、港股先走腾讯，美股先走 Yahoo，失败后按默认顺序降级
+var marketPreferredSource = map[string]string{MarketA: sourceTencent, MarketHK: sourceTencent, MarketUS: sourceYahoo, MarketCrypto: sourceYahoo}
+
+// marketOnlySources 只能由特定数据源提供行情的市场，其余数据源不再尝试
+var marketOnlySources = map[string][]string{MarketCrypto: {sourceYahoo}}
+
+// sourcesForMarket 将市场首选数据源排到最前，其余保持默认顺序；marketOnlySources 中的市场只保留指定数据源
+func sourcesForMarket(market string) []stockDataSource {
+	preferred := marketPreferredSource[market]
+	ordered := make([]stockDataSource, 0, len(stockDataSources))
+	for _, src := range stockDataSources {
+		if src.id == preferred {
+			ordered = append(ordered, src)
+		}
+	}
+	for _, src := range stockDataSources {
+		if src.id != preferred {
+			ordered = append(ordered, src)
 		}
-		fmt.Printf("[数据源] ✗ %s 获取失败: %v\n", source.name, err)
 	}
+	if only, ok := marketOnlySources[market]; ok {
+		filtered := ordered[:0]
+		for _, src := range ordered {
+			for _, id := range only {
+				if src.id == id {
+					filtered = append(filtered, src)
+				}
+			}
+		}
+		ordered = filtered
+	}
+	return ordered
+}
 
-	if len(stockData) == 0 {
-		return nil, nil, fmt.Errorf("所有数据源都获取失败")
+// NormalizeStockCode 去掉 A 股代码的交易所后缀（600519.SH、000001.SZ、600519.SS），港股补零为 5 位（0700.HK、700 → 00700），
+// 其余代码原样返回
+func NormalizeStockCode(stockCode string) string {
+	code := strings.TrimSpace(stockCode)
+	if hk := hkCode(code); hk != "" {
+		return hk
+	}
+	upper := strings.ToUpper(code)
+	for _, suffix := range []string{".SH", ".SZ", ".SS"} {
+		if base := strings.TrimSuffix(upper, suffix); base != upper && StockMarket(base) == "A股" {
+			return base
+		}
 	}
+	return code
+}
 
-	// 数据验证：检查价格合理性
-	stockData = validateAndFilterData(stockData, stockCode)
+// fetchFromSources 依次尝试各数据源，返回首个成功的数据及数据源名称；ctx 取消时不再尝试后续数据源
+func fetchFromSources(ctx context.Context, stockCode string) ([]StockData, string, error) {
+	// 尝试多个数据源，确保数据准确性
+	stockCode = NormalizeStockCode(stockCode)
+	var stockData []StockData
+	var err error
+	allowed := 0
+	for _, source := range sourcesForMarket(StockMarket(stockCode)) {
+		if !DataSourceAllowed(source.id) {
+			log.Printf("[合规] %s 不在允许的数据源列表中，已跳过\n", source.name)
+			continue
+		}
+		allowed++
+		log.Printf("[数据源] 尝试从 %s 获取 %s 的历史数据...\n", source.name, stockCode)
+		stockData, err = source.fn(ctx, stockCode)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, "", ctxErr
+		}
+		if err == nil && len(stockData) > 0 {
+			log.Printf("[数据源] ✓ 成功从 %s 获取 %d 条数据\n", source.name, len(stockData))
+			return stockData, source.name, nil
+		}
+		log.Printf("[数据源] ✗ %s 获取失败: %v\n", source.name, err)
+	}
+	if allowed == 0 {
+		return nil, "", fmt.Errorf("合规限制：没有允许使用的行情数据源（允许列表：%s）", strings.Join(AllowedDataSources, ","))
+	}
+	return nil, "", fmt.Errorf("所有数据源都获取失败")
+}
 
-	// 按日期排序
+// prepareStockData 校验、排序并计算技术指标
+func prepareStockData(stockData []StockData, stockCode string) ([]StockData, []TechnicalIndicator) {
+	// 按日期排序（修复模式依赖相邻K线）
 	sort.Slice(stockData, func(i, j int) bool {
 		return stockData[i].Date.Before(stockData[j].Date)
 	})
 
+	// 数据验证：检查价格合理性
+	stockData = validateAndFilterData(stockData, stockCode)
+
+	// 对比交易日历检测缺失交易日
+	stockData = checkMissingTradingDays(stockData, stockCode)
+
 	// 计算技术指标
+	if len(stockData) > 0 && len(stockData) < minIndicatorBars {
+		log.Printf("[数据校验] %s 仅有 %d 根K线（少于 %d 天），不计算技术指标\n", stockCode, len(stockData), minIndicatorBars)
+	}
 	indicators := calculateTechnicalIndicators(stockData)
 
-	return stockData, indicators, nil
+	return stockData, indicators
 }
 
-// 腾讯API数据源
-func fetchFromTencent(stockCode string) ([]StockData, error) {
-	// 腾讯API symbol格式：sh600036、sz000001
-	symbol := stockCode
-	if len(stockCode) == 6 && stockCode[0] == '6' {
-		symbol = "sh" + stockCode
-	} else if len(stockCode) == 6 && (stockCode[0] == '0' || stockCode[0] == '3') {
-		symbol = "sz" + stockCode
+// clipWarmupBars 区间内行情不足该根数时向前补足预热K线，保证风险指标至少有 minRiskSamples 个收益率样本
+const clipWarmupBars = minRiskSamples + 1
+
+// clipToDateRange 按 start/end（YYYY-MM-DD，含首尾，空表示不限）裁剪行情；指标已在完整序列上算好，裁剪不影响均线等预热。
+// 区间内不足 clipWarmupBars 根时保留 start 之前的行情作为预热，warmup 为返回序列开头的预热根数，展示时应跳过
+func clipToDateRange(stockData []StockData, indicators []TechnicalIndicator, start, end string) ([]StockData, []TechnicalIndicator, int, error) {
+	if start == "" && end == "" {
+		return stockData, indicators, 0, nil
+	}
+	var from, to time.Time
+	var err error
+	if start != "" {
+		if from, err = time.ParseInLocation("2006-01-02", start, time.Local); err != nil {
+			return nil, nil, 0, fmt.Errorf("开始日期格式错误（应为 YYYY-MM-DD）: %s", start)
+		}
+	}
+	if end != "" {
+		if to, err = time.ParseInLocation("2006-01-02", end, time.Local); err != nil {
+			return nil, nil, 0, fmt.Errorf("结束日期格式错误（应为 YYYY-MM-DD）: %s", end)
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+
+	// 行情按日期升序，区间对应 [lo, hi)
+	lo, hi := len(stockData), len(stockData)
+	for i, d := range stockData {
+		if !from.IsZero() && d.Date.Before(from) {
+			continue
+		}
+		if lo == len(stockData) {
+			lo = i
+		}
+		if !to.IsZero() && !d.Date.Before(to) {
+			hi = i
+			break
+		}
+	}
+	if lo >= hi {
+		if start == "" {
+			start = "不限"
+		}
+		if end == "" {
+			end = "不限"
+		}
+		if len(stockData) == 0 {
+			return nil, nil, 0, fmt.Errorf("区间无数据：%s 至 %s", start, end)
+		}
+		return nil, nil, 0, fmt.Errorf("区间无数据：%s 至 %s 超出可用行情范围（%s 至 %s）", start, end,
+			stockData[0].Date.Format("2006-01-02"), stockData[len(stockData)-1].Date.Format("2006-01-02"))
+	}
+	warmup := 0
+	if n := hi - lo; n < clipWarmupBars {
+		warmup = clipWarmupBars - n
+		if warmup > lo {
+			warmup = lo
+		}
+	}
+	begin := lo - warmup
+	clippedData := append([]StockData(nil), stockData[begin:hi]...)
+	var clippedInd []TechnicalIndicator
+	if begin < len(indicators) {
+		indEnd := hi
+		if indEnd > len(indicators) {
+			indEnd = len(indicators)
+		}
+		clippedInd = append(clippedInd, indicators[begin:indEnd]...)
+	}
+	return clippedData, clippedInd, warmup, nil
+}
+
+// displayRange 去掉开头的 warmup 根预热行情，得到用户请求区间内用于图表、表格与回测的部分
+func displayRange(stockData []StockData, indicators []TechnicalIndicator, warmup int) ([]StockData, []TechnicalIndicator) {
+	if warmup <= 0 {
+		return stockData, indicators
+	}
+	if warmup > len(stockData) {
+		warmup = len(stockData)
+	}
+	if warmup > len(indicators) {
+		return stockData[warmup:], nil
+	}
+	return stockData[warmup:], indicators[warmup:]
+}
+
+// 数据源标识，用于代码规范化
+const (
+	sourceTencent = "tencent"
+	sourceNetEase = "netease"
+	sourceXueqiu  = "xueqiu"
+	sourceYahoo   = "yahoo"
+)
+
+// IsHKStock 是否港股代码，与 StockMarket 同一规则：1-5 位数字，可带 .HK 后缀
+func IsHKStock(stockCode string) bool {
+	return StockMarket(stockCode) == MarketHK
+}
+
+// normalizeSymbol 将用户输入的代码转换为各数据源的 symbol 格式：
+// 腾讯 sh600036/sz000001/hk00700，网易 1.600036/0.000001，雪球 SH600036/SZ000001/00700，Yahoo 600036.SS/000001.SZ/0700.HK/BTC-USD；
+// 无法识别的代码（如美股 AAPL）原样返回
+func normalizeSymbol(stockCode, source string) string {
+	if StockMarket(stockCode) == MarketCrypto {
+		if source != sourceYahoo {
+			return stockCode
+		}
+		return yahooCryptoSymbol(stockCode)
+	}
+	if hk := hkCode(stockCode); hk != "" {
+		switch source {
+		case sourceTencent:
+			return "hk" + hk
+		case sourceYahoo:
+			return hk[1:] + ".HK"
+		default:
+			return hk
+		}
+	}
+	if len(stockCode) != 6 {
+		return stockCode
+	}
+	sh := stockCode[0] == '6'
+	sz := stockCode[0] == '0' || stockCode[0] == '3'
+	if !sh && !sz {
+		return stockCode
 	}
+	switch source {
+	case sourceTencent:
+		if sh {
+			return "sh" + stockCode
+		}
+		return "sz" + stockCode
+	case sourceNetEase:
+		if sh {
+			return "1." + stockCode
+		}
+		return "0." + stockCode
+	case sourceXueqiu:
+		if sh {
+			return "SH" + stockCode
+		}
+		return "SZ" + stockCode
+	case sourceYahoo:
+		if sh {
+			return stockCode + ".SS"
+		}
+		return stockCode + ".SZ"
+	}
+	return stockCode
+}
+
+// 腾讯API数据源
+func fetchFromTencent(ctx context.Context, stockCode string) ([]StockData, error) {
+	symbol := normalizeSymbol(stockCode, sourceTencent)
 
 	url := "https://web.ifzq.gtimg.cn/appstock/app/kline/kline?param=" + symbol + ",day,,,320"
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	resp, err := client.Do(req)
+	body, err := fetchWithRetry(ctx, url, defaultFetchHeaders, FetchMaxRetries)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, _ := ioutil.ReadAll(resp.Body)
 	var data struct {
 		Data map[string]struct {
 			Day [][]interface{} `json:"day"`
@@ -238,6 +473,9 @@ func fetchFromTencent(stockCode string) ([]StockData, error) {
 			high, _ := strconv.ParseFloat(item[3].(string), 64)
 			low, _ := strconv.ParseFloat(item[4].(string), 64)
 			vol, _ := strconv.ParseFloat(item[5].(string), 64)
+			if !IsHKStock(stockCode) {
+				vol *= 100 // 腾讯A股成交量单位为手，统一换算为股；港股已是股
+			}
 
 			stockData = append(stockData, StockData{
 				Date:   dt,
@@ -253,26 +491,17 @@ func fetchFromTencent(stockCode string) ([]StockData, error) {
 }
 
 // 网易API数据源
-func fetchFromNetEase(stockCode string) ([]StockData, error) {
-	// 网易API格式：0.000001（深市）、1.600036（沪市）
-	symbol := stockCode
-	if len(stockCode) == 6 && stockCode[0] == '6' {
-		symbol = "1." + stockCode
-	} else if len(stockCode) == 6 && (stockCode[0] == '0' || stockCode[0] == '3') {
-		symbol = "0." + stockCode
+func fetchFromNetEase(ctx context.Context, stockCode string) ([]StockData, error) {
+	if IsHKStock(stockCode) {
+		return nil, fmt.Errorf("网易API 不支持港股")
 	}
+	symbol := normalizeSymbol(stockCode, sourceNetEase)
 
 	url := fmt.Sprintf("http://api.money.126.net/data/feed/%s/history", symbol)
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	resp, err := client.Do(req)
+	body, err := fetchWithRetry(ctx, url, defaultFetchHeaders, FetchMaxRetries)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, _ := ioutil.ReadAll(resp.Body)
 	var data map[string]struct {
 		Data [][]float64 `json:"data"`
 	}
@@ -305,14 +534,8 @@ func fetchFromNetEase(stockCode string) ([]StockData, error) {
 }
 
 // 雪球API数据源
-func fetchFromXueqiu(stockCode string) ([]StockData, error) {
-	// 雪球API格式：SZ000001、SH600036
-	symbol := stockCode
-	if len(stockCode) == 6 && stockCode[0] == '6' {
-		symbol = "SH" + stockCode
-	} else if len(stockCode) == 6 && (stockCode[0] == '0' || stockCode[0] == '3') {
-		symbol = "SZ" + stockCode
-	}
+func fetchFromXueqiu(ctx context.Context, stockCode string) ([]StockData, error) {
+	symbol := normalizeSymbol(stockCode, sourceXueqiu)
 
 	// 获取当前时间戳（雪球API不需要时间参数，但保留注释说明）
 	// now := time.Now()
@@ -320,18 +543,14 @@ func fetchFromXueqiu(stockCode string) ([]StockData, error) {
 	// startTime := now.AddDate(0, -1, 0).UnixNano() / 1e6 // 最近1个月
 
 	url := fmt.Sprintf("https://stock.xueqiu.com/v5/stock/chart/kline.json?symbol=%s&period=day&type=before&count=320&indicator=kline", symbol)
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	req.Header.Set("Referer", "https://xueqiu.com")
-
-	resp, err := client.Do(req)
+	headers := map[string]string{"Referer": "https://xueqiu.com"}
+	for k, v := range defaultFetchHeaders {
+		headers[k] = v
+	}
+	body, err := fetchWithRetry(ctx, url, headers, FetchMaxRetries)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	body, _ := ioutil.ReadAll(resp.Body)
 	var data struct {
 		Data struct {
 			Item [][]interface{} `json:"item"`
@@ -368,50 +587,293 @@ func fetchFromXueqiu(stockCode string) ([]StockData, error) {
 	return stockData, nil
 }
 
+// Yahoo API数据源（v8 chart 接口，旧的 v7 CSV 下载接口已返回 401）
+func fetchFromYahoo(ctx context.Context, stockCode string) ([]StockData, error) {
+	symbol := normalizeSymbol(stockCode, sourceYahoo)
+
+	now := time.Now()
+	url := fmt.Sprintf("https://query1.finance.yahoo.com/v8/finance/chart/%s?period1=%d&period2=%d&interval=1d",
+		symbol, now.AddDate(0, -15, 0).Unix(), now.Unix())
+	body, err := fetchWithRetry(ctx, url, defaultFetchHeaders, FetchMaxRetries)
+	if err != nil {
+		return nil, err
+	}
+	return parseYahooChart(body)
+}
+
+// parseYahooChart 解析 chart.result[0] 的 timestamp 与 indicators.quote[0]，跳过含 null 的K线（如节假日）
+func parseYahooChart(body []byte) ([]StockData, error) {
+	var data struct {
+		Chart struct {
+			Result []struct {
+				Timestamp  []int64 `json:"timestamp"`
+				Indicators struct {
+					Quote []struct {
+						Open   []*float64 `json:"open"`
+						High   []*float64 `json:"high"`
+						Low    []*float64 `json:"low"`
+						Close  []*float64 `json:"close"`
+						Volume []*float64 `json:"volume"`
+					} `json:"quote"`
+				} `json:"indicators"`
+			} `json:"result"`
+			Error *struct {
+				Code        string `json:"code"`
+				Description string `json:"description"`
+			} `json:"error"`
+		} `json:"chart"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	if data.Chart.Error != nil {
+		return nil, fmt.Errorf("Yahoo API 错误: %s %s", data.Chart.Error.Code, data.Chart.Error.Description)
+	}
+	if len(data.Chart.Result) == 0 || len(data.Chart.Result[0].Indicators.Quote) == 0 {
+		return nil, fmt.Errorf("Yahoo API 未返回行情数据")
+	}
+
+	result := data.Chart.Result[0]
+	quote := result.Indicators.Quote[0]
+	at := func(arr []*float64, i int) (float64, bool) {
+		if i >= len(arr) || arr[i] == nil {
+			return 0, false
+		}
+		return *arr[i], true
+	}
+	var stockData []StockData
+	for i, ts := range result.Timestamp {
+		open, ok1 := at(quote.Open, i)
+		high, ok2 := at(quote.High, i)
+		low, ok3 := at(quote.Low, i)
+		close, ok4 := at(quote.Close, i)
+		volume, ok5 := at(quote.Volume, i)
+		if !(ok1 && ok2 && ok3 && ok4 && ok5) {
+			continue
+		}
+		stockData = append(stockData, StockData{
+			Date:   time.Unix(ts, 0),
+			Open:   open,
+			High:   high,
+			Low:    low,
+			Close:  close,
+			Volume: volume,
+		})
+	}
+	return stockData, nil
+}
+
+// 异常K线处理模式
+const (
+	OutlierDrop   = "drop"   // 直接丢弃异常K线
+	OutlierRepair = "repair" // 孤立异常点用相邻值插值，连续异常用前值填充，保持序列连续
+)
+
+// OutlierMode 当前异常K线处理模式，默认丢弃
+var OutlierMode = OutlierDrop
+
+// isValidBar K线价格/成交量合理性检查，收盘价范围按市场取 MarketProfile
+func isValidBar(data StockData, profile MarketProfile) bool {
+	// 基本价格检查
+	if data.Open <= 0 || data.Close <= 0 || data.High <= 0 || data.Low <= 0 {
+		return false
+	}
+
+	// 价格逻辑检查
+	if data.High < data.Open || data.High < data.Close || data.High < data.Low {
+		return false
+	}
+	if data.Low > data.Open || data.Low > data.Close || data.Low > data.High {
+		return false
+	}
+
+	// 价格范围检查（防止异常值）
+	if !profile.priceInRange(data.Close) {
+		return false
+	}
+
+	// 成交量检查
+	if data.Volume < 0 {
+		return false
+	}
+	return true
+}
+
 // 数据验证和过滤
 func validateAndFilterData(stockData []StockData, stockCode string) []StockData {
+	if OutlierMode == OutlierRepair {
+		return repairOutliers(stockData, stockCode)
+	}
+
 	var validData []StockData
+	profile := MarketProfileFor(stockCode)
 
 	// 价格合理性检查
 	for _, data := range stockData {
-		// 基本价格检查
-		if data.Open <= 0 || data.Close <= 0 || data.High <= 0 || data.Low <= 0 {
+		if !isValidBar(data, profile) {
 			continue
 		}
+		validData = append(validData, data)
+	}
 
-		// 价格逻辑检查
-		if data.High < data.Open || data.High < data.Close || data.High < data.Low {
+	// 如果过滤后数据太少，记录警告
+	if len(validData) < int(float64(len(stockData))*0.8) {
+		log.Printf("[数据验证] ⚠️  %s 数据过滤较多：原始%d条，有效%d条\n",
+			stockCode, len(stockData), len(validData))
+	}
+
+	return validData
+}
+
+// repairOutliers 修复异常K线而非丢弃（要求数据已按日期升序）：
+// 前后均为有效K线的孤立异常点取前后均值插值，连续异常或末尾异常用前值填充，开头无前值的异常点丢弃
+func repairOutliers(stockData []StockData, stockCode string) []StockData {
+	repaired := make([]StockData, 0, len(stockData))
+	interpolated, filled, dropped := 0, 0, 0
+	profile := MarketProfileFor(stockCode)
+	for i, data := range stockData {
+		if isValidBar(data, profile) {
+			repaired = append(repaired, data)
 			continue
 		}
-		if data.Low > data.Open || data.Low > data.Close || data.Low > data.High {
+		if len(repaired) == 0 {
+			dropped++
 			continue
 		}
-
-		// 价格范围检查（防止异常值）
-		if data.Close > 10000 || data.Close < 0.01 {
+		prev := repaired[len(repaired)-1]
+		prevValid := i > 0 && isValidBar(stockData[i-1], profile)
+		if prevValid && i+1 < len(stockData) && isValidBar(stockData[i+1], profile) {
+			next := stockData[i+1]
+			repaired = append(repaired, StockData{
+				Date:   data.Date,
+				Open:   (prev.Open + next.Open) / 2,
+				Close:  (prev.Close + next.Close) / 2,
+				High:   (prev.High + next.High) / 2,
+				Low:    (prev.Low + next.Low) / 2,
+				Volume: (prev.Volume + next.Volume) / 2,
+			})
+			interpolated++
 			continue
 		}
+		fill := prev
+		fill.Date = data.Date
+		repaired = append(repaired, fill)
+		filled++
+	}
+	if interpolated+filled+dropped > 0 {
+		log.Printf("[数据验证] %s 异常K线修复：插值%d条，前值填充%d条，丢弃%d条\n",
+			stockCode, interpolated, filled, dropped)
+	}
+	return repaired
+}
 
-		// 成交量检查
-		if data.Volume < 0 {
+// calcKDJSeries 按时间顺序迭代 9 日 KDJ：K = 2/3*前K + 1/3*RSV，D = 2/3*前D + 1/3*K，J = 3K-2D，初始 K/D 为 50
+func calcKDJSeries(stockData []StockData, period int) (ks, ds, js []float64) {
+	ks = make([]float64, len(stockData))
+	ds = make([]float64, len(stockData))
+	js = make([]float64, len(stockData))
+	k, d := 50.0, 50.0
+	for i := range stockData {
+		start := i - period + 1
+		if start < 0 {
+			start = 0
+		}
+		low, high := stockData[start].Low, stockData[start].High
+		for _, bar := range stockData[start : i+1] {
+			low = math.Min(low, bar.Low)
+			high = math.Max(high, bar.High)
+		}
+		rsv := 50.0
+		if high > low {
+			rsv = (stockData[i].Close - low) / (high - low) * 100
+		}
+		k = 2.0/3.0*k + 1.0/3.0*rsv
+		d = 2.0/3.0*d + 1.0/3.0*k
+		ks[i], ds[i], js[i] = k, d, 3*k-2*d
+	}
+	return ks, ds, js
+}
+
+// calcMACDSeries 以首日收盘价为初值递推 EMA12/EMA26，返回 DIF 与 DEA(DIF 的 9 日 EMA) 序列
+func calcMACDSeries(closes []float64) (difs, deas []float64) {
+	ema12, ema26 := emaSeries(closes, 12), emaSeries(closes, 26)
+	difs = make([]float64, len(closes))
+	for i := range closes {
+		difs[i] = ema12[i] - ema26[i]
+	}
+	return difs, emaSeries(difs, 9)
+}
+
+// emaSeries 以首个值为初值递推 period 日指数均线 EMA = (2*当日值 + (period-1)*前值)/(period+1)，
+// 与 MACD 及通达信/同花顺口径一致
+func emaSeries(values []float64, period int) []float64 {
+	out := make([]float64, len(values))
+	for i, v := range values {
+		if i == 0 {
+			out[i] = v
 			continue
 		}
+		out[i] = (2*v + float64(period-1)*out[i-1]) / float64(period+1)
+	}
+	return out
+}
 
-		validData = append(validData, data)
+// calcEMAFamily 计算 period 日 EMA、DEMA、TEMA 序列，均按 emaSeries 递推；递推初期偏差较大，
+// EMA 前 period-1 根、DEMA 前 2*(period-1) 根、TEMA 前 3*(period-1) 根置为 0
+func calcEMAFamily(closes []float64, period int) (ema, dema, tema []float64) {
+	raw := emaSeries(closes, period)
+	ema2 := emaSeries(raw, period)
+	ema3 := emaSeries(ema2, period)
+	ema = make([]float64, len(closes))
+	dema = make([]float64, len(closes))
+	tema = make([]float64, len(closes))
+	for i := range closes {
+		if i >= period-1 {
+			ema[i] = raw[i]
+		}
+		if i >= 2*(period-1) {
+			dema[i] = 2*raw[i] - ema2[i]
+		}
+		if i >= 3*(period-1) {
+			tema[i] = 3*raw[i] - 3*ema2[i] + ema3[i]
+		}
 	}
+	return ema, dema, tema
+}
 
-	// 如果过滤后数据太少，记录警告
-	if len(validData) < int(float64(len(stockData))*0.8) {
-		fmt.Printf("[数据验证] ⚠️  %s 数据过滤较多：原始%d条，有效%d条\n",
-			stockCode, len(stockData), len(validData))
+// prefixSums 前缀和，sums[i] 为前 i 个值之和，长度 len(values)+1
+func prefixSums(values []float64) []float64 {
+	sums := make([]float64, len(values)+1)
+	for i, v := range values {
+		sums[i+1] = sums[i] + v
 	}
+	return sums
+}
 
-	return validData
+// gainLossPrefixSums 逐日上涨幅度与下跌幅度（取正）的前缀和，首日无涨跌
+func gainLossPrefixSums(closes []float64) (gains, losses []float64) {
+	gains = make([]float64, len(closes)+1)
+	losses = make([]float64, len(closes)+1)
+	for i := range closes {
+		gains[i+1], losses[i+1] = gains[i], losses[i]
+		if i == 0 {
+			continue
+		}
+		if change := closes[i] - closes[i-1]; change > 0 {
+			gains[i+1] += change
+		} else {
+			losses[i+1] -= change
+		}
+	}
+	return gains, losses
 }
 
+// minIndicatorBars 计算技术指标所需的最少K线数；超过该值后各指标按自身窗口独立计算，窗口不足的指标为 0
+const minIndicatorBars = 5
+
 // 计算技术指标
 func calculateTechnicalIndicators(stockData []StockData) []TechnicalIndicator {
-	if len(stockData) == 0 {
+	if len(stockData) < minIndicatorBars {
 		return nil
 	}
 
@@ -422,64 +884,33 @@ func calculateTechnicalIndicators(stockData []StockData) []TechnicalIndicator {
 		volumes = append(volumes, d.Volume)
 	}
 
-	// 本地计算技术指标
-	ma := func(arr []float64, n int, idx int) float64 {
+	// 前缀和：窗口均值 O(1) 求得，避免每根K线按周期回看
+	closeSums := prefixSums(closes)
+	volumeSums := prefixSums(volumes)
+	gainSums, lossSums := gainLossPrefixSums(closes)
+	ma := func(sums []float64, n int, idx int) float64 {
 		if idx+1 < n {
 			return 0
 		}
-		sum := 0.0
-		for i := idx + 1 - n; i <= idx; i++ {
-			sum += arr[i]
-		}
-		return sum / float64(n)
+		return (sums[idx+1] - sums[idx+1-n]) / float64(n)
 	}
 
-	// 计算MACD
-	calcMACD := func(prices []float64, idx int) (float64, float64, float64) {
+	// 计算MACD：先顺序算出完整 DIF 序列，再对 DIF 做 9 日 EMA 得到 DEA，柱状图 = 2*(DIF-DEA)，与通达信/同花顺口径一致
+	difs, deas := calcMACDSeries(closes)
+	calcMACD := func(idx int) (float64, float64, float64) {
 		if idx < 25 {
 			return 0, 0, 0
 		}
-		ema12 := 0.0
-		ema26 := 0.0
-		alpha12 := 2.0 / 13.0
-		alpha26 := 2.0 / 27.0
-
-		for i := 0; i <= idx; i++ {
-			if i == 0 {
-				ema12 = prices[i]
-				ema26 = prices[i]
-			} else {
-				ema12 = alpha12*prices[i] + (1-alpha12)*ema12
-				ema26 = alpha26*prices[i] + (1-alpha26)*ema26
-			}
-		}
-
-		macd := ema12 - ema26
-		signal := 0.0
-		histogram := macd - signal
-
-		return macd, signal, histogram
+		return difs[idx], deas[idx], 2 * (difs[idx] - deas[idx])
 	}
 
-	// 计算RSI
-	calcRSI := func(prices []float64, period int, idx int) float64 {
+	// 计算RSI：窗口内涨跌幅合计由前缀和相减得到
+	calcRSI := func(period int, idx int) float64 {
 		if idx < period {
 			return 0
 		}
-		gains := 0.0
-		losses := 0.0
-		for i := idx - period + 1; i <= idx; i++ {
-			if i > 0 {
-				change := prices[i] - prices[i-1]
-				if change > 0 {
-					gains += change
-				} else {
-					losses -= change
-				}
-			}
-		}
-		avgGain := gains / float64(period)
-		avgLoss := losses / float64(period)
+		avgGain := (gainSums[idx+1] - gainSums[idx+1-period]) / float64(period)
+		avgLoss := (lossSums[idx+1] - lossSums[idx+1-period]) / float64(period)
 		if avgLoss == 0 {
 			return 100
 		}
@@ -492,12 +923,9 @@ func calculateTechnicalIndicators(stockData []StockData) []TechnicalIndicator {
 		if idx < period-1 {
 			return 0, 0, 0
 		}
-		sum := 0.0
-		for i := idx - period + 1; i <= idx; i++ {
-			sum += prices[i]
-		}
-		middle := sum / float64(period)
+		middle := ma(closeSums, period, idx)
 
+		// 方差仍逐项累加（周期仅 20），用平方和前缀相减会因抵消误差损失精度
 		variance := 0.0
 		for i := idx - period + 1; i <= idx; i++ {
 			variance += (prices[i] - middle) * (prices[i] - middle)
@@ -510,43 +938,42 @@ func calculateTechnicalIndicators(stockData []StockData) []TechnicalIndicator {
 		return upper, middle, lower
 	}
 
-	// 计算成交量均线
-	calcVolumeMA := func(volumes []float64, n int, idx int) float64 {
-		if idx+1 < n {
-			return 0
-		}
-		sum := 0.0
-		for i := idx + 1 - n; i <= idx; i++ {
-			sum += volumes[i]
-		}
-		return sum / float64(n)
-	}
+	obv := calculateOBV(stockData)
+	ks, ds, js := calcKDJSeries(stockData, 9)
+	ema12, _, _ := calcEMAFamily(closes, 12)
+	ema26, _, _ := calcEMAFamily(closes, 26)
+	_, dema20, tema20 := calcEMAFamily(closes, 20)
 
-	var indicators []TechnicalIndicator
+	indicators := make([]TechnicalIndicator, 0, len(stockData))
 	for i := range stockData {
 		// 计算MACD
-		macd, signal, histogram := calcMACD(closes, i)
+		macd, signal, histogram := calcMACD(i)
 
 		// 计算RSI
-		rsi6 := calcRSI(closes, 6, i)
-		rsi12 := calcRSI(closes, 12, i)
-		rsi24 := calcRSI(closes, 24, i)
+		rsi6 := calcRSI(6, i)
+		rsi12 := calcRSI(12, i)
+		rsi24 := calcRSI(24, i)
 
 		// 计算BOLL
 		bollUpper, bollMiddle, bollLower := calcBOLL(closes, 20, i)
 
 		// 计算成交量均线
-		volMA5 := calcVolumeMA(volumes, 5, i)
-		volMA10 := calcVolumeMA(volumes, 10, i)
-		volMA20 := calcVolumeMA(volumes, 20, i)
+		volMA5 := ma(volumeSums, 5, i)
+		volMA10 := ma(volumeSums, 10, i)
+		volMA20 := ma(volumeSums, 20, i)
 
 		indicators = append(indicators, TechnicalIndicator{
-			MA5:   ma(closes, 5, i),
-			MA10:  ma(closes, 10, i),
-			MA20:  ma(closes, 20, i),
-			MA60:  ma(closes, 60, i),
-			MA120: ma(closes, 120, i),
-			MA250: ma(closes, 250, i),
+			MA5:   ma(closeSums, 5, i),
+			MA10:  ma(closeSums, 10, i),
+			MA20:  ma(closeSums, 20, i),
+			MA60:  ma(closeSums, 60, i),
+			MA120: ma(closeSums, 120, i),
+			MA250: ma(closeSums, 250, i),
+
+			EMA12:  ema12[i],
+			EMA26:  ema26[i],
+			DEMA20: dema20[i],
+			TEMA20: tema20[i],
 
 			MACD:          macd,
 			MACDSignal:    signal,
@@ -563,102 +990,97 @@ func calculateTechnicalIndicators(stockData []StockData) []TechnicalIndicator {
 			VolumeMA5:  volMA5,
 			VolumeMA10: volMA10,
 			VolumeMA20: volMA20,
+
+			K: ks[i],
+			D: ds[i],
+			J: js[i],
+
+			OBV: obv[i],
 		})
 	}
 	return indicators
 }
 
+// predictionItems 预测项目开关及中英文名称，按此顺序写入 prompt
+var predictionItems = []struct {
+	enabled func(AnalysisParams) bool
+	zh, en  string
+}{
+	{func(p AnalysisParams) bool { return p.TargetPrice }, "目标价位预测", "target price"},
+	{func(p AnalysisParams) bool { return p.StopLoss }, "止损位预测", "stop-loss level"},
+	{func(p AnalysisParams) bool { return p.TakeProfit }, "止盈位预测", "take-profit level"},
+	{func(p AnalysisParams) bool { return p.Volatility }, "波动率预测", "volatility"},
+	{func(p AnalysisParams) bool { return p.Volume }, "成交量预测", "trading volume"},
+	{func(p AnalysisParams) bool { return p.Probability }, "涨跌概率预测", "up/down probability"},
+	{func(p AnalysisParams) bool { return p.RiskLevel }, "风险等级评估", "risk level assessment"},
+	{func(p AnalysisParams) bool { return p.TrendStrength }, "趋势强度预测", "trend strength"},
+	{func(p AnalysisParams) bool { return p.SupportResistance }, "支撑阻力位预测", "support/resistance levels"},
+	{func(p AnalysisParams) bool { return p.TechnicalSignals }, "技术信号预测", "technical signals"},
+	{func(p AnalysisParams) bool { return p.FundamentalMetrics }, "基本面指标预测", "fundamental metrics"},
+	{func(p AnalysisParams) bool { return p.SentimentScore }, "情绪评分预测", "sentiment score"},
+	{func(p AnalysisParams) bool { return p.MarketPosition }, "市场定位分析", "market positioning"},
+	{func(p AnalysisParams) bool { return p.CompetitiveAdvantage }, "竞争优势分析", "competitive advantages"},
+}
+
+// BuildPrompt 按分析参数生成 prompt，Lang 为 en 时固定文字全部使用英文模板
 func BuildPrompt(params AnalysisParams) string {
+	en := isEnglish(params.Lang)
+	t := templateFor(params.Lang)
 	// 判断是否联网/混合模式
 	isOnline := params.SearchMode || params.HybridSearch
 	prompt := ""
 	if isOnline {
-		prompt += fmt.Sprintf(`请联网获取股票%s的最新股价、最新公告和新闻，分析时以最新联网数据为准。
-
-【重要】数据验证要求：
-1. 请联网查询该股票的最新收盘价，并与本地K线数据对比
-2. 如果最新联网价格与本地数据差异超过5%，请以联网数据为准
-3. 在报告开头明确标注：
-   - 最新联网价格：XX.XX元（查询时间：YYYY-MM-DD HH:MM）
-   - 本地数据最新价格：XX.XX元（日期：YYYY-MM-DD）
-   - 数据差异：+/-X.XX元（X.XX%）
-4. 如果发现价格异常（如超过1000元或低于0.01元），请重新查询并标注"数据异常，已重新验证"
-
-请确保获取的是真实准确的股价数据，不要使用过时或错误的价格信息。`, strings.Join(params.StockCodes, ","))
+		prompt += fmt.Sprintf(t.online, strings.Join(params.StockCodes, ","))
 	} else {
-		prompt += fmt.Sprintf("请对股票代码 %s 进行智能分析。\n", strings.Join(params.StockCodes, ","))
+		prompt += fmt.Sprintf(t.offline, strings.Join(params.StockCodes, ","))
 	}
-	prompt += fmt.Sprintf("分析时间范围：%s 至 %s\n", params.Start, params.End)
+	prompt += fmt.Sprintf(t.timeRange, params.Start, params.End)
 	if len(params.Periods) > 0 {
-		prompt += fmt.Sprintf("预测周期：%s\n", strings.Join(params.Periods, ","))
+		prompt += fmt.Sprintf(t.periods, strings.Join(localizeList(params.Periods, params.Lang), ","))
 	}
 	if len(params.Dims) > 0 {
-		prompt += fmt.Sprintf("分析维度：%s\n", strings.Join(params.Dims, "、"))
+		prompt += fmt.Sprintf(t.dims, strings.Join(localizeList(params.Dims, params.Lang), t.listSep))
 	}
 	if params.Risk != "" {
-		prompt += fmt.Sprintf("风险偏好：%s\n", params.Risk)
+		prompt += fmt.Sprintf(t.risk, localize(params.Risk, params.Lang))
 	}
 	if params.Lang != "" {
-		prompt += fmt.Sprintf("输出语言：%s\n", params.Lang)
+		prompt += fmt.Sprintf(t.lang, params.Lang)
 	}
-	prompt += "\n【预测要求】\n"
+	prompt += t.predHeader
 	if len(params.PredictionTypes) > 0 {
-		prompt += fmt.Sprintf("预测类型：%s\n", strings.Join(params.PredictionTypes, "、"))
+		prompt += fmt.Sprintf(t.predTypes, strings.Join(localizeList(params.PredictionTypes, params.Lang), t.listSep))
 	}
 	var predictions []string
-	if params.TargetPrice {
-		predictions = append(predictions, "目标价位预测")
-	}
-	if params.StopLoss {
-		predictions = append(predictions, "止损位预测")
-	}
-	if params.TakeProfit {
-		predictions = append(predictions, "止盈位预测")
-	}
-	if params.Volatility {
-		predictions = append(predictions, "波动率预测")
-	}
-	if params.Volume {
-		predictions = append(predictions, "成交量预测")
-	}
-	if params.Probability {
-		predictions = append(predictions, "涨跌概率预测")
-	}
-	if params.RiskLevel {
-		predictions = append(predictions, "风险等级评估")
-	}
-	if params.TrendStrength {
-		predictions = append(predictions, "趋势强度预测")
-	}
-	if params.SupportResistance {
-		predictions = append(predictions, "支撑阻力位预测")
-	}
-	if params.TechnicalSignals {
-		predictions = append(predictions, "技术信号预测")
-	}
-	if params.FundamentalMetrics {
-		predictions = append(predictions, "基本面指标预测")
-	}
-	if params.SentimentScore {
-		predictions = append(predictions, "情绪评分预测")
-	}
-	if params.MarketPosition {
-		predictions = append(predictions, "市场定位分析")
-	}
-	if params.CompetitiveAdvantage {
-		predictions = append(predictions, "竞争优势分析")
+	for _, item := range predictionItems {
+		if !item.enabled(params) {
+			continue
+		}
+		if en {
+			predictions = append(predictions, item.en)
+		} else {
+			predictions = append(predictions, item.zh)
+		}
 	}
 	if len(predictions) > 0 {
-		prompt += fmt.Sprintf("具体预测项目：%s\n", strings.Join(predictions, "、"))
+		prompt += fmt.Sprintf(t.predItems, strings.Join(predictions, t.listSep))
 	}
 	if params.Confidence {
-		prompt += "每个预测结论都需要提供置信度/概率区间\n"
+		prompt += t.confidence
 	}
-	prompt += "\n请提供详细的技术分析和投资建议，包含上述所有预测项目。"
-	prompt += "\n\n【格式要求】\n1. 多周期预测请用markdown表格输出，表头包含：周期、趋势判断、关键价位、置信度、主要驱动因素/理由。\n2. 综合预测结论请用markdown表格输出，表头包含：预测项目、预测值/区间、置信度、主要驱动因素/理由。\n3. 若某项预测不适用或数据不足，请在表格中注明'数据不足'或'-'。\n4. 结论部分请分为'主要结论'、'风险提示'、'操作建议'三块，分别用表格或要点输出。"
+	prompt += t.detail
+	prompt += t.format
 	// 智能异常检测与提示
-	prompt += "\n5. 请对比最新股价与历史K线（如最近30日均价、最高价、最低价），如最新价与历史均值/区间差异超过10%，请在报告开头高亮提示'行情异动'，并简要分析可能原因。"
-	prompt += "\n6. 如果多周期预测或综合结论中某项置信度低于60%，请在该行或结论部分自动加'风险提示'（如'预测不确定性较高，请谨慎参考'）。"
+	prompt += t.anomaly
+	prompt += t.lowConfidence
+	dims := RadarDims
+	if en {
+		dims = make([]string, len(RadarDims))
+		for i, d := range RadarDims {
+			dims[i] = radarDimEnglish[d]
+		}
+	}
+	prompt += fmt.Sprintf(t.dimScores, strings.Join(dims, t.listSep), dims[0])
 	return prompt
 }
 
@@ -683,23 +1105,27 @@ func replaceImagesWithAbsHTML(md string) string {
 	})
 }
 
-// 新增：将行情数据结构化为表格文本
-func FormatStockDataTable(stockData []StockData, indicators []TechnicalIndicator) string {
+// 新增：将行情数据结构化为表格文本，表头按 lang 输出
+func FormatStockDataTable(stockData []StockData, indicators []TechnicalIndicator, lang string) string {
 	if len(stockData) == 0 {
 		return ""
 	}
-	head := "\n【历史行情数据表】\n| 日期 | 开盘 | 收盘 | 最高 | 最低 | 成交量 | MA5 | MA10 | MA20 | MA60 | MA120 | MA250 | MACD | RSI6 | RSI12 | BOLL上轨 | BOLL中轨 | BOLL下轨 |\n|------|------|------|------|------|--------|-----|------|------|------|-------|-------|------|------|-------|----------|----------|----------|\n"
+	w := wrapperFor(lang)
+	head := w.stockTableTitle + markdownHeader(w.stockTableHead)
 	rows := ""
 	for i, d := range stockData {
 		if i >= len(indicators) {
 			break
 		}
-		row := fmt.Sprintf("| %s | %.2f | %.2f | %.2f | %.2f | %.0f | %.2f | %.2f | %.2f | %.2f | %.2f | %.2f | %.3f | %.1f | %.1f | %.2f | %.2f | %.2f |\n",
-			d.Date.Format("2006-01-02"), d.Open, d.Close, d.High, d.Low, d.Volume,
-			indicators[i].MA5, indicators[i].MA10, indicators[i].MA20, indicators[i].MA60,
-			indicators[i].MA120, indicators[i].MA250, indicators[i].MACD,
-			indicators[i].RSI6, indicators[i].RSI12,
-			indicators[i].BOLLUpper, indicators[i].BOLLMiddle, indicators[i].BOLLLower)
+		ind := indicators[i]
+		row := fmt.Sprintf("| %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %s | %.3f | %.1f/%.1f/%.1f | %.1f | %.1f | %s | %s | %s |\n",
+			d.Date.Format("2006-01-02"), FormatPrice(d.Open), FormatPrice(d.Close), FormatPrice(d.High), FormatPrice(d.Low), formatVolumeLang(d.Volume, lang),
+			FormatPrice(ind.MA5), FormatPrice(ind.MA10), FormatPrice(ind.MA20), FormatPrice(ind.MA60),
+			FormatPrice(ind.MA120), FormatPrice(ind.MA250),
+			FormatPrice(ind.EMA12), FormatPrice(ind.EMA26), FormatPrice(ind.DEMA20), FormatPrice(ind.TEMA20), ind.MACD,
+			ind.K, ind.D, ind.J,
+			ind.RSI6, ind.RSI12,
+			FormatPrice(ind.BOLLUpper), FormatPrice(ind.BOLLMiddle), FormatPrice(ind.BOLLLower))
 		rows += row
 		if i > 30 {
 			break
@@ -729,77 +1155,118 @@ func filterRecentDataToDate(stockData []StockData, indicators []TechnicalIndicat
 			break
 		}
 		filteredData = append(filteredData, stockData[i])
-		filteredInd = append(filteredInd, indicators[i])
+		if i < len(indicators) {
+			filteredInd = append(filteredInd, indicators[i])
+		}
 	}
 	return filteredData, filteredInd
 }
 
-// 新增：回测结果 markdown 表格
-func FormatBacktestTable(btParams BacktestParams, btResult BacktestResult) string {
-	head := "\n【策略回测结果】\n| 策略类型 | 参数 | 总收益率 | 胜率 | 最大回撤 | 盈亏比 | 交易次数 |\n|---|---|---|---|---|---|---|\n"
+// 新增：回测结果 markdown 表格，lang 为 en 时使用英文表头
+func FormatBacktestTable(btParams BacktestParams, btResult BacktestResult, lang string) string {
+	l := labelsFor(lang)
+	head := "\n" + l.backtestTitle + "\n" + markdownHeader(l.backtestHeader)
 	paramStr := fmt.Sprintf("%+v", btParams)
-	row := fmt.Sprintf("| %s | %s | %.2f%% | %.2f%% | %.2f%% | %.2f | %d |\n",
-		btParams.StrategyType, paramStr, btResult.TotalReturn*100, btResult.WinRate*100, btResult.MaxDrawdown*100, btResult.ProfitFactor, btResult.Trades)
+	row := fmt.Sprintf("| %s | %s | %.0f | %s | %s | %s | %s | %s | %s | %d |\n",
+		btParams.StrategyType, paramStr, btResult.InitialCapital, FormatPercent(btResult.TotalReturn), FormatPercent(btResult.AnnualizedReturn), FormatRatio(btResult.SharpeRatio), FormatPercent(btResult.WinRate), FormatPercent(btResult.MaxDrawdown), FormatRatio(btResult.ProfitFactor), btResult.Trades)
 	return head + row
 }
 
-// 新增：风险指标 markdown 表格
-func FormatRiskTable(risk RiskMetrics) string {
-	head := "\n【风险指标】\n| 波动率 | 最大回撤 | 夏普比率 | VaR(95%) | 风险等级 | 风险评分 |\n|---|---|---|---|---|---|\n"
-	row := fmt.Sprintf("| %.4f | %.2f%% | %.2f | %.4f | %s | %.1f |\n",
-		risk.Volatility, risk.MaxDrawdown*100, risk.SharpeRatio, risk.VaR95, risk.RiskLevel, risk.RiskScore)
-	return head + row
+// 新增：风险指标 markdown 表格，lang 为 en 时使用英文表头与风险等级
+func FormatRiskTable(risk RiskMetrics, lang string) string {
+	l := labelsFor(lang)
+	head := "\n" + l.riskTitle + "\n" + markdownHeader(l.riskHead)
+	row := fmt.Sprintf("| %s | %s | %s | %s | %s | %.1f |\n",
+		FormatPercent(risk.Volatility), FormatPercent(risk.MaxDrawdown), FormatRatio(risk.SharpeRatio), FormatPercent(risk.VaR95), localize(risk.RiskLevel, lang), risk.RiskScore)
+	extHead := "\n" + markdownHeader(l.riskExtHead)
+	extRow := fmt.Sprintf("| %s | %s | %s | %s | %s | %s |\n",
+		FormatRatio(risk.SortinoRatio), FormatRatio(risk.CalmarRatio), FormatPercent(risk.DownsideDeviation), FormatRatio(risk.Skewness), FormatRatio(risk.Kurtosis), FormatPercent(risk.VaR99))
+	return head + row + extHead + extRow
 }
 
-// 新增：回测结果 HTML 表格
-func FormatBacktestTableHTML(btParams BacktestParams, btResult BacktestResult) string {
-	return fmt.Sprintf(`
-<h3>【策略回测结果】</h3>
-<table>
-<tr><th>策略类型</th><th>参数</th><th>总收益率</th><th>胜率</th><th>最大回撤</th><th>盈亏比</th><th>交易次数</th></tr>
+// 新增：回测结果 HTML 表格，lang 为 en 时使用英文表头
+func FormatBacktestTableHTML(btParams BacktestParams, btResult BacktestResult, lang string) string {
+	l := labelsFor(lang)
+	return "\n<h3>" + l.backtestTitle + "</h3>\n<table>\n" + htmlHeader(l.backtestHeader) + fmt.Sprintf(`
 <tr>
 <td>%s</td>
 <td>%+v</td>
-<td>%.2f%%</td>
-<td>%.2f%%</td>
-<td>%.2f%%</td>
-<td>%.2f</td>
+<td>%.0f</td>
+<td>%s</td>
+<td>%s</td>
+<td>%s</td>
+<td>%s</td>
+<td>%s</td>
+<td>%s</td>
 <td>%d</td>
 </tr>
 </table>
-`, btParams.StrategyType, btParams, btResult.TotalReturn*100, btResult.WinRate*100, btResult.MaxDrawdown*100, btResult.ProfitFactor, btResult.Trades)
+`, btParams.StrategyType, btParams, btResult.InitialCapital, FormatPercent(btResult.TotalReturn), FormatPercent(btResult.AnnualizedReturn), FormatRatio(btResult.SharpeRatio), FormatPercent(btResult.WinRate), FormatPercent(btResult.MaxDrawdown), FormatRatio(btResult.ProfitFactor), btResult.Trades)
 }
 
-// 新增：风险指标 HTML 表格
-func FormatRiskTableHTML(risk RiskMetrics) string {
-	return fmt.Sprintf(`
-<h3>【风险指标】</h3>
-<table>
-<tr><th>波动率</th><th>最大回撤</th><th>夏普比率</th><th>VaR(95%%)</th><th>风险等级</th><th>风险评分</th></tr>
+// 新增：风险指标 HTML 表格，lang 为 en 时使用英文表头与风险等级
+func FormatRiskTableHTML(risk RiskMetrics, lang string) string {
+	l := labelsFor(lang)
+	return "\n<h3>" + l.riskTitle + "</h3>\n<table>\n" + htmlHeader(l.riskHead) + fmt.Sprintf(`
 <tr>
-<td>%.4f</td>
-<td>%.2f%%</td>
-<td>%.2f</td>
-<td>%.4f</td>
+<td>%s</td>
+<td>%s</td>
+<td>%s</td>
+<td>%s</td>
 <td>%s</td>
 <td>%.1f</td>
 </tr>
+`, FormatPercent(risk.Volatility), FormatPercent(risk.MaxDrawdown), FormatRatio(risk.SharpeRatio), FormatPercent(risk.VaR95), localize(risk.RiskLevel, lang), risk.RiskScore) +
+		htmlHeader(l.riskExtHead) + fmt.Sprintf(`
+<tr>
+<td>%s</td>
+<td>%s</td>
+<td>%s</td>
+<td>%s</td>
+<td>%s</td>
+<td>%s</td>
+</tr>
 </table>
-`, risk.Volatility, risk.MaxDrawdown*100, risk.SharpeRatio, risk.VaR95, risk.RiskLevel, risk.RiskScore)
+`, FormatRatio(risk.SortinoRatio), FormatRatio(risk.CalmarRatio), FormatPercent(risk.DownsideDeviation), FormatRatio(risk.Skewness), FormatRatio(risk.Kurtosis), FormatPercent(risk.VaR99))
 }
 
-func AnalyzeOne(params AnalysisParams, genFunc func(string, string, string, string, string, bool, bool) (string, error)) AnalysisResult {
+// generateWithContext 在独立 goroutine 中执行生成调用，ctx 取消或超时时立即返回 ctx.Err()；
+// 不感知 context 的生成函数（如 Gemini）在后台自行结束，结果丢弃
+func generateWithContext(ctx context.Context, generate func() (string, error)) (string, error) {
+	type result struct {
+		report string
+		err    error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		report, err := generate()
+		ch <- result{report, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case r := <-ch:
+		return r.report, r.err
+	}
+}
+
+// AnalyzeOne 分析单只股票并导出报告。ctx 贯穿行情抓取、图表与 PDF 渲染及大模型调用，
+// 取消或超时时返回 Err 为 ctx.Err() 的结果，不再写入任何文件；genFunc 需自行使用同一 ctx 才能中断进行中的 HTTP 请求
+func AnalyzeOne(ctx context.Context, params AnalysisParams, genFunc func(string, string, string, string, string, bool, bool) (string, error)) AnalysisResult {
+	if err := ctx.Err(); err != nil {
+		return AnalysisResult{StockCode: params.StockCodes[0], Err: err}
+	}
 	prompt := params.Prompt
 	if prompt == "" {
 		prompt = BuildPrompt(params)
 	}
+	industryCtx := LoadIndustryContext(ctx, params.StockCodes[0])
+	prompt += FormatIndustryPrompt(industryCtx, params.Lang)
 
-	// 自动插入当前系统日期声明，防止AI用自身认知时间
+	// 自动插入当前系统日期声明，防止AI用自身认知时间；外层固定文字按 Lang 输出，见 wrapperTexts
+	wrap := wrapperFor(params.Lang)
 	now := time.Now().Format("2006-01-02")
-	dateNotice := fmt.Sprintf(
-		"\n【重要提示】本系统优先使用 DeepSeek 联网模式获取最新行情和分析，只有在联网失败时才尝试本地数据源。请严格以当前分析时间 %s 为准，禁止引用AI自身认知的时间或任何与本地参数不符的时间信息。若分析区间超出数据范围，请直接说明“数据不足”，不要虚构或假设当前时间。\n",
-		now)
-	prompt = dateNotice + prompt + "\n请再次确认，所有分析均以当前分析时间为准，不要引用AI自身时间认知。\n"
+	prompt = fmt.Sprintf(wrap.dateNotice, now) + prompt + wrap.confirm
 
 	useHTML := false
 	for _, o := range params.Output {
@@ -816,99 +1283,204 @@ func AnalyzeOne(params AnalysisParams, genFunc func(string, string, string, stri
 
 	var stockData []StockData
 	var indicators []TechnicalIndicator
+	// shownData/shownInd 为去掉预热K线后的请求区间，用于图表、行情表与回测；风险、资金流等指标用含预热的完整序列
+	var shownData []StockData
+	var shownInd []TechnicalIndicator
 	var chartPaths []string
+	var chartErr error
+	var dataInfo DataSourceInfo
+	var dataNotice string
+	// generate 记录本次实际使用的生成调用，质量不达标时可原样重试
+	var generate func() (string, error)
 
 	if params.LLMType == "Gemini" {
-		report, err = GenerateGeminiReportWithConfigAndSearch(params.Model, params.APIKey, prompt, params.SearchMode)
+		generate = func() (string, error) {
+			return GenerateGeminiReportWithConfigAndSearch(ctx, params.Model, params.APIKey, prompt, params.SearchMode)
+		}
 	} else if params.LLMType == "gmini" {
 		// 伪实现：调用 gmini API
-		report, err = GenerateGminiReportWithConfigAndSearch(params)
+		generate = func() (string, error) {
+			return GenerateGminiReportWithConfigAndSearch(params)
+		}
 	} else if params.SearchMode || params.HybridSearch {
 		// DeepSeek 联网/混合模式
-		stockData, indicators, _ = FetchStockHistory(params.StockCodes[0], params.Start, params.End, params.APIKey)
-		if len(stockData) > 0 {
-			latest := stockData[len(stockData)-1].Date
-			stockData, indicators = filterRecentDataToDate(stockData, indicators, latest, 12)
-			chartPaths, _ = GenerateCharts(params.StockCodes[0], stockData, indicators, "charts")
+		stockData, indicators, dataInfo, _ = params.fetchStockHistory(ctx)
+		if err := ctx.Err(); err != nil {
+			return AnalysisResult{StockCode: params.StockCodes[0], Err: err}
+		}
+		dataNotice = dataInfo.Notice(params.Lang)
+		if rt := params.realtimeNotice(ctx, stockData); rt != "" {
+			dataNotice += rt
+			prompt = rt + prompt
 		}
-		report, err = genFunc(params.StockCodes[0], prompt, params.APIKey, "https://api.deepseek.com/v1/chat/completions", params.Model, params.SearchMode, params.HybridSearch)
-	} else {
-		// DeepSeek 本地数据模式
-		stockData, indicators, fetchErr := FetchStockHistory(params.StockCodes[0], params.Start, params.End, params.APIKey)
 		if len(stockData) > 0 {
 			latest := stockData[len(stockData)-1].Date
+			before := len(stockData)
 			stockData, indicators = filterRecentDataToDate(stockData, indicators, latest, 12)
-			chartPaths, _ = GenerateCharts(params.StockCodes[0], stockData, indicators, "charts")
-		}
-		if len(stockData) == 0 && fetchErr != nil {
-			params.SearchMode = true
-			params.HybridSearch = false
-			prompt = "[提示] DeepSeek 联网模式优先，本地数据源全部获取失败，已自动继续使用 DeepSeek 联网分析。\n" + BuildPrompt(params)
-			stockData, indicators, _ = FetchStockHistory(params.StockCodes[0], params.Start, params.End, params.APIKey)
-			if len(stockData) > 0 {
-				latest := stockData[len(stockData)-1].Date
-				stockData, indicators = filterRecentDataToDate(stockData, indicators, latest, 12)
-				chartPaths, _ = GenerateCharts(params.StockCodes[0], stockData, indicators, "charts")
+			shownData, shownInd = displayRange(stockData, indicators, dataInfo.WarmupBars-(before-len(stockData)))
+			if div := FormatDivergenceNotice(DetectDivergence(stockData, indicators), params.Lang); div != "" {
+				dataNotice += div
+				prompt = div + prompt
 			}
-			report, err = genFunc(params.StockCodes[0], prompt, params.APIKey, "https://api.deepseek.com/v1/chat/completions", params.Model, true, false)
+			chartPaths, chartErr = GenerateCharts(ctx, params.StockCodes[0], shownData, shownInd, "charts")
+		}
+		generate = func() (string, error) {
+			return genFunc(params.StockCodes[0], prompt, params.APIKey, params.ChatURL(), params.Model, params.SearchMode, params.HybridSearch)
+		}
+	} else {
+		// DeepSeek 本地数据模式，无行情时不再继续生成
+		var fetchErr error
+		stockData, indicators, dataInfo, fetchErr = params.fetchStockHistory(ctx)
+		if err := ctx.Err(); err != nil {
+			return AnalysisResult{StockCode: params.StockCodes[0], Err: err}
+		}
+		if len(stockData) == 0 {
+			return noDataResult(params.StockCodes[0], fetchErr)
+		}
+		dataNotice = dataInfo.Notice(params.Lang) + params.realtimeNotice(ctx, stockData)
+		latest := stockData[len(stockData)-1].Date
+		before := len(stockData)
+		stockData, indicators = filterRecentDataToDate(stockData, indicators, latest, 12)
+		shownData, shownInd = displayRange(stockData, indicators, dataInfo.WarmupBars-(before-len(stockData)))
+		dataNotice += FormatDivergenceNotice(DetectDivergence(stockData, indicators), params.Lang)
+		chartPaths, chartErr = GenerateCharts(ctx, params.StockCodes[0], shownData, shownInd, "charts")
+		risk := CalculateRiskMetrics(stockData, RiskFreeRate)
+		if useHTML {
+			riskTable = FormatRiskTableHTML(risk, params.Lang)
 		} else {
-			riskTable = ""
-			if len(stockData) > 0 {
-				risk := CalculateRiskMetrics(stockData)
-				if useHTML {
-					riskTable = FormatRiskTableHTML(risk)
-				} else {
-					riskTable = FormatRiskTable(risk)
-				}
-			}
-			stockTable := FormatStockDataTable(stockData, indicators)
-			prompt = stockTable + "\n" + prompt
-			report, err = genFunc(params.StockCodes[0], prompt, params.APIKey, "https://api.deepseek.com/v1/chat/completions", params.Model, false, false)
+			riskTable = FormatRiskTable(risk, params.Lang)
+		}
+		stockTable := FormatStockDataTable(shownData, shownInd, params.Lang)
+		prompt = dataNotice + stockTable + FormatMoneyFlowPrompt(CalculateMoneyFlow(stockData), params.Lang) + "\n" + prompt
+		generate = func() (string, error) {
+			return genFunc(params.StockCodes[0], prompt, params.APIKey, params.ChatURL(), params.Model, false, false)
 		}
 	}
+	log.Printf("[Token] %s 预估 prompt 约 %d tokens，最大响应 %d tokens\n",
+		params.StockCodes[0], EstimateTokens(prompt, params.Model), params.Generation().maxTokens())
+	report, err = generateWithContext(ctx, generate)
 	if err != nil {
 		return AnalysisResult{StockCode: params.StockCodes[0], Err: err}
 	}
 
-	// ====== 图表引用、风险、回测表格统一拼接 ======
-	if len(chartPaths) > 0 {
-		for _, p := range chartPaths {
+	// ====== 报告质量打分，低分自动重试一次，仍不达标则标注 ======
+	quality := ScoreReportQuality(report, params)
+	if quality.Total < QualityThreshold {
+		log.Printf("[质量] %s 报告质量评分 %.0f 低于 %.0f，尝试重新生成...\n", params.StockCodes[0], quality.Total, QualityThreshold)
+		if retry, retryErr := generateWithContext(ctx, generate); retryErr == nil {
+			if retryQuality := ScoreReportQuality(retry, params); retryQuality.Total > quality.Total {
+				report, quality = retry, retryQuality
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return AnalysisResult{StockCode: params.StockCodes[0], Err: err}
+		}
+	}
+
+	// ====== 规整 LLM 输出的 markdown 表格 ======
+	if fixed, n := RepairMarkdownTables(report); n > 0 {
+		log.Printf("[表格] %s 报告中 %d 个表格格式异常，已修复或降级为代码块\n", params.StockCodes[0], n)
+		report = fixed
+	}
+
+	// ====== 图表引用、风险、机器学习预测、回测表格统一拼接 ======
+	if chartErr != nil {
+		log.Printf("[图表] %s 部分图表生成失败，已跳过对应图片引用: %v\n", params.StockCodes[0], chartErr)
+	}
+	for _, p := range chartPaths {
+		if p != "" {
 			chartRefs += fmt.Sprintf("![图表](%s)\n", p)
 		}
 	}
 	if riskTable == "" && len(stockData) > 0 {
-		risk := CalculateRiskMetrics(stockData)
+		risk := CalculateRiskMetrics(stockData, RiskFreeRate)
+		if useHTML {
+			riskTable = FormatRiskTableHTML(risk, params.Lang)
+		} else {
+			riskTable = FormatRiskTable(risk, params.Lang)
+		}
+	}
+	// ====== 机器学习预测，联网模式无本地行情或K线不足 mlMinBars 时跳过 ======
+	var mlTable string
+	var mlPreds []MLPrediction
+	if len(stockData) >= mlMinBars {
+		if preds, err := PredictMLCached(ctx, params.StockCodes[0], stockData, indicators); err != nil {
+			log.Printf("[机器学习] %s 跳过预测表格: %v\n", params.StockCodes[0], err)
+		} else {
+			mlPreds = preds
+			if useHTML {
+				mlTable = FormatMLPredictionTableHTML(preds, params.Lang)
+			} else {
+				mlTable = FormatMLPredictionTable(preds, params.Lang)
+			}
+		}
+	}
+	var moneyFlow *MoneyFlow
+	var moneyFlowTable string
+	if len(stockData) >= 2 {
+		mf := CalculateMoneyFlow(stockData)
+		moneyFlow = &mf
 		if useHTML {
-			riskTable = FormatRiskTableHTML(risk)
+			moneyFlowTable = FormatMoneyFlowTableHTML(mf, params.Lang)
 		} else {
-			riskTable = FormatRiskTable(risk)
+			moneyFlowTable = FormatMoneyFlowTable(mf, params.Lang)
+		}
+	}
+
+	// ====== 多维评分雷达图，有同行业历史评分时叠加行业均值 ======
+	scores := ExtractDimensionScores(report)
+	if filled := FillLocalScores(scores, stockData, indicators, moneyFlow); len(filled) > 0 {
+		log.Printf("[评分] %s 报告未给出%s评分，已按本地数据估算\n", params.StockCodes[0], strings.Join(filled, "、"))
+	}
+	var benchmark map[string]float64
+	var benchmarkLabel string
+	records, recordsErr := LoadConclusionRecords()
+	if recordsErr == nil {
+		industry := ReportIndustry(report)
+		var peers int
+		benchmark, peers = IndustryAverageScores(records, industry, params.StockCodes[0])
+		benchmarkLabel = fmt.Sprintf("%s行业均值（%d只）", industry, peers)
+	}
+	if radarPNG, err := GenerateRadarChart(ctx, params.StockCodes[0], RadarDims, scores, benchmark, benchmarkLabel, "charts"); err != nil {
+		log.Printf("[评分] 生成雷达图失败: %s\n", err)
+	} else if radarPNG != "" {
+		chartRefs += fmt.Sprintf("![多维评分雷达图](%s)\n", radarPNG)
+	}
+	// ====== 各维度评分趋势，本次评分尚未写入历史，临时并入计算 ======
+	var scoreTrendTable string
+	current := ConclusionRecord{StockCode: params.StockCodes[0], Scores: scores, Time: time.Now()}
+	if trends := StockScoreTrends(append(records, current), params.StockCodes[0], ScoreTrendWindow); len(trends) > 0 {
+		scoreTrendTable = FormatScoreTrends(trends)
+		if trendPNG, err := GenerateScoreTrendChart(ctx, params.StockCodes[0], trends, "charts"); err != nil {
+			log.Printf("[评分] 生成评分趋势图失败: %s\n", err)
+		} else if trendPNG != "" {
+			chartRefs += fmt.Sprintf("![评分趋势](%s)\n", trendPNG)
 		}
 	}
 	var btParams BacktestParams
 	if params.BacktestParams != nil {
 		btParams = *params.BacktestParams
 	} else {
-		btParams = BacktestParams{
-			StrategyType:   "ma_cross",
-			FastMAPeriod:   5,
-			SlowMAPeriod:   20,
-			BreakoutPeriod: 10,
-			RSIPeriod:      14,
-			RSIOverbought:  70,
-			RSIOversold:    30,
-			StopLoss:       0.05,
-			TakeProfit:     0.10,
-			InitialCash:    100000,
-		}
-	}
-	btResult := BacktestStrategy(stockData, btParams)
+		btParams = DefaultBacktestParams()
+	}
+	if btParams.BuyFee == 0 && btParams.SellFee == 0 {
+		profile := MarketProfileFor(params.StockCodes[0])
+		btParams.BuyFee, btParams.SellFee = profile.BuyFee, profile.SellFee
+	}
+	btResult := BacktestStrategy(shownData, btParams)
+	if btPNG, err := GenerateBacktestChart(ctx, params.StockCodes[0], shownData, btResult, "charts"); err != nil {
+		log.Printf("[回测] 生成资金曲线图失败: %s\n", err)
+	} else if btPNG != "" {
+		chartRefs += fmt.Sprintf("![回测资金曲线](%s)\n", btPNG)
+	}
+	attribution := AnalyzeBacktestAttribution(shownData, btResult, 3)
 	if useHTML {
-		backtestTable = FormatBacktestTableHTML(btParams, btResult)
+		backtestTable = FormatBacktestTableHTML(btParams, btResult, params.Lang) + FormatAttributionTableHTML(attribution, params.Lang)
 	} else {
-		backtestTable = FormatBacktestTable(btParams, btResult)
+		backtestTable = FormatBacktestTable(btParams, btResult, params.Lang) + FormatAttributionTable(attribution, params.Lang)
 	}
 
-	finalReport := chartRefs + riskTable + backtestTable + report
+	finalReport := dataNotice + quality.Notice(params.Lang) + chartRefs + riskTable + mlTable + moneyFlowTable + scoreTrendTable + backtestTable + report
 
 	// ====== 预测异常检测与高亮提示 ======
 	anomalyMsg := ""
@@ -937,6 +1509,34 @@ func AnalyzeOne(params AnalysisParams, genFunc func(string, string, string, stri
 		finalReport = "\n> [!WARNING] " + anomalyMsg + "\n" + finalReport
 	}
 
+	// ====== 结论一致性校验 ======
+	currentPrice := 0.0
+	if len(stockData) > 0 {
+		currentPrice = stockData[len(stockData)-1].Close
+	}
+	conclusion := ExtractConclusion(report, currentPrice)
+	if conflicts := CheckReportConsistency(conclusion); len(conflicts) > 0 {
+		finalReport = "\n> [!WARNING] 结论存在冲突，请复核：" + strings.Join(conflicts, "；") + "\n" + finalReport
+	}
+
+	// ====== 外币标的附带本币折算 ======
+	finalReport = CurrencyNotice(params.StockCodes[0], currentPrice, conclusion.TargetPrice) + finalReport
+
+	// ====== 置信度加权综合建议，置于报告开头 ======
+	riskLevel := "数据不足"
+	if len(stockData) > 0 {
+		riskLevel = CalculateRiskMetrics(stockData, RiskFreeRate).RiskLevel
+	}
+	signals := CollectRecommendSignals(report, conclusion, stockData, indicators, moneyFlow, mlPreds)
+	finalReport = FormatAggregatedRecommendation(AggregateRecommendation(signals, riskLevel), params.Lang) + finalReport
+
+	if err := ctx.Err(); err != nil {
+		return AnalysisResult{StockCode: params.StockCodes[0], Err: err}
+	}
+	if err := redactForExport(params.StockCodes[0], &report, &finalReport); err != nil {
+		return AnalysisResult{StockCode: params.StockCodes[0], Err: fmt.Errorf("脱敏失败，已取消导出: %v", err)}
+	}
+
 	// ====== 恢复多格式导出逻辑 ======
 	os.MkdirAll("history", 0755)
 	exports := []string{"md"}
@@ -944,9 +1544,9 @@ func AnalyzeOne(params AnalysisParams, genFunc func(string, string, string, stri
 		exports = params.Output
 	}
 	var writeErr error
+	fbase := reserveHistoryBase(fmt.Sprintf("%s-%s-%s", fileNameCode(params.StockCodes[0]), fileNameCode(params.End), time.Now().Format("150405")))
 	for _, ext := range exports {
 		var fname string
-		fbase := fmt.Sprintf("%s-%s-%s", params.StockCodes[0], params.End, time.Now().Format("150405"))
 		fpath := ""
 		reportHTML := replaceImagesWithAbsHTML(finalReport)
 		if ext == "md" {
@@ -954,7 +1554,7 @@ func AnalyzeOne(params AnalysisParams, genFunc func(string, string, string, stri
 			fpath = filepath.Join("history", fname)
 			err := ioutil.WriteFile(fpath, []byte(finalReport), 0644)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "[错误] 写入Markdown文件失败: %s\n", err)
+				log.Printf("[错误] 写入Markdown文件失败: %s\n", err)
 				writeErr = err
 			} else {
 				savedFile = fname
@@ -962,10 +1562,10 @@ func AnalyzeOne(params AnalysisParams, genFunc func(string, string, string, stri
 		} else if ext == "html" {
 			fname = fbase + ".html"
 			fpath = filepath.Join("history", fname)
-			html := "<meta charset=\"utf-8\">\n" + exportCSS + markdownToHTML(convertMarkdownTablesToHTML(reportHTML))
+			html := "<meta charset=\"utf-8\">\n" + exportCSS + TimelineLinkHTML(params.StockCodes[0]) + markdownToHTML(convertMarkdownTablesToHTML(reportHTML))
 			err := ioutil.WriteFile(fpath, []byte(html), 0644)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "[错误] 写入HTML文件失败: %s\n", err)
+				log.Printf("[错误] 写入HTML文件失败: %s\n", err)
 				writeErr = err
 			} else {
 				savedFile = fname
@@ -974,43 +1574,68 @@ func AnalyzeOne(params AnalysisParams, genFunc func(string, string, string, stri
 			fname = fbase + ".pdf"
 			fpath = filepath.Join("history", fname)
 			htmlPath := fpath + ".tmp.html"
-			htmlContent := "<meta charset=\"utf-8\">\n" + exportCSS + markdownToHTML(convertMarkdownTablesToHTML(reportHTML))
+			htmlContent := "<meta charset=\"utf-8\">\n" + exportCSS + TimelineLinkHTML(params.StockCodes[0]) + markdownToHTML(convertMarkdownTablesToHTML(reportHTML))
 			ioutil.WriteFile(htmlPath, []byte(htmlContent), 0644)
-			err := htmlToPDF(htmlPath, fpath)
-			os.Remove(htmlPath)
+			err := htmlToPDF(ctx, htmlPath, fpath)
 			if err != nil {
-				fmt.Fprintf(os.Stderr, "[错误] 生成PDF失败: %s\n", err)
+				// 无可用 PDF 引擎时保留 HTML，不中断导出
+				htmlName := fbase + ".html"
+				if renameErr := os.Rename(htmlPath, filepath.Join("history", htmlName)); renameErr != nil {
+					os.Remove(htmlPath)
+					log.Printf("[错误] 生成PDF失败: %s\n", err)
+					writeErr = err
+				} else {
+					log.Printf("[PDF] PDF 不可用，已保存 HTML：%s（%s）\n", htmlName, err)
+					savedFile = htmlName
+				}
+			} else {
+				os.Remove(htmlPath)
+				log.Println("[调试] 已写入PDF文件：", fpath)
+				savedFile = fname
+			}
+		} else if ext == "json" {
+			fname = fbase + ".json"
+			fpath = filepath.Join("history", fname)
+			data, err := json.MarshalIndent(BuildReportJSON(params, report, stockData, dataInfo, btResult, conclusion, scores, time.Now()), "", "  ")
+			if err == nil {
+				err = ioutil.WriteFile(fpath, data, 0644)
+			}
+			if err != nil {
+				log.Printf("[错误] 写入JSON文件失败: %s\n", err)
 				writeErr = err
 			} else {
-				fmt.Println("[调试] 已写入PDF文件：", fpath)
 				savedFile = fname
 			}
 		}
 	}
-	if writeErr != nil {
-		return AnalysisResult{StockCode: params.StockCodes[0], Report: finalReport, SavedFile: savedFile, Err: writeErr}
+	if savedFile != "" {
+		if err := AppendFeedEntry(NewFeedEntry(params.StockCodes[0], savedFile, report, time.Now())); err != nil {
+			log.Printf("[订阅] 更新 %s 失败: %s\n", FeedFile, err)
+		}
+		rec := NewConclusionRecord(params.StockCodes[0], savedFile, report, currentPrice, time.Now())
+		rec.Scores = scores
+		rec.Model = params.Model
+		if err := AppendConclusionRecord(rec); err != nil {
+			log.Printf("[结论统计] 写入 %s 失败: %s\n", ConclusionFile, err)
+		}
+		pred := ExtractPrediction(params.StockCodes[0], params.End, report, conclusion)
+		pred.Model = params.Model
+		pred.SavedFile = savedFile
+		if err := AppendPrediction(pred); err != nil {
+			log.Printf("[预测追踪] 写入 %s 失败: %s\n", PredictionsFile, err)
+		}
+		if err := SaveAnalysisRecord(newAnalysisRecord(params.StockCodes[0], params.Model, savedFile, report, riskLevel, conclusion, time.Now())); err != nil {
+			log.Printf("[数据库] 写入 %s 失败: %s\n", DBPath, err)
+		}
+		if err := WriteStockTimeline(params.StockCodes[0]); err != nil {
+			log.Printf("[历史] 更新 %s 时间线失败: %s\n", params.StockCodes[0], err)
+		}
 	}
-	return AnalysisResult{StockCode: params.StockCodes[0], Report: finalReport, SavedFile: savedFile}
-}
-
-func htmlToPDF(htmlPath, pdfPath string) error {
-	ctx, cancel := chromedp.NewContext(context.Background())
-	defer cancel()
-	var pdfBuf []byte
-	absPath, _ := filepath.Abs(htmlPath)
-	fileURL := "file://" + absPath
-	err := chromedp.Run(ctx,
-		chromedp.Navigate(fileURL),
-		chromedp.ActionFunc(func(ctx context.Context) error {
-			var err error
-			pdfBuf, _, err = page.PrintToPDF().WithPrintBackground(true).Do(ctx)
-			return err
-		}),
-	)
-	if err != nil {
-		return err
+	industry := industryCtx.Industry
+	if industry == "" {
+		industry = ReportIndustry(report)
 	}
-	return os.WriteFile(pdfPath, pdfBuf, 0644)
+	return AnalysisResult{StockCode: params.StockCodes[0], Report: finalReport, SavedFile: savedFile, Err: writeErr, MoneyFlow: moneyFlow, Scores: scores, Industry: industry}
 }
 
 const exportCSS = `
@@ -1028,9 +1653,29 @@ code { background: #f5f5f5; color: #c7254e; padding: 2px 4px; border-radius: 4px
 </style>
 `
 
+// renderInlineMarkdown 渲染单元格内的行内 markdown（粗体、斜体、代码、链接），
+// 单元格按拆分后的内容单独解析，\| 还原为竖线；解析结果不是单个段落（如被识别为列表、标题）时保留原文
+func renderInlineMarkdown(cell string) string {
+	cell = strings.ReplaceAll(cell, `\|`, "|")
+	if cell == "" {
+		return ""
+	}
+	out := strings.TrimSpace(markdownToHTML(cell))
+	inner, ok := strings.CutPrefix(out, "<p>")
+	if !ok {
+		return cell
+	}
+	inner, ok = strings.CutSuffix(inner, "</p>")
+	if !ok || strings.Contains(inner, "<p>") {
+		return cell
+	}
+	return inner
+}
+
 // 新增：将 markdown 表格转换为 HTML 表格
 func convertMarkdownTablesToHTML(md string) string {
-	re := regexp.MustCompile(`(?ms)(\|.+\|\n\|[-| ]+\|\n(?:\|.*\|\n?)+)`)
+	md, _ = RepairMarkdownTables(md)
+	re := regexp.MustCompile(`(?ms)(\|.+\|\n\|[-:| ]+\|\n(?:\|.*\|\n?)+)`)
 	return re.ReplaceAllStringFunc(md, func(table string) string {
 		lines := strings.Split(strings.TrimSpace(table), "\n")
 		if len(lines) < 2 {
@@ -1039,24 +1684,23 @@ func convertMarkdownTablesToHTML(md string) string {
 		var html strings.Builder
 		html.WriteString("<table>\n")
 		// 表头
-		cols := strings.Split(lines[0], "|")
-		if len(cols) < 3 {
+		cols := splitTableRow(lines[0])
+		if len(cols) == 0 {
 			return table // 防止越界
 		}
 		html.WriteString("<tr>")
-		for _, c := range cols[1 : len(cols)-1] {
-			html.WriteString("<th>" + strings.TrimSpace(c) + "</th>")
+		for _, c := range cols {
+			html.WriteString("<th>" + renderInlineMarkdown(c) + "</th>")
 		}
 		html.WriteString("</tr>\n")
 		// 表体
 		for _, l := range lines[2:] {
-			cells := strings.Split(l, "|")
-			if len(cells) < 3 {
+			if !strings.HasPrefix(strings.TrimSpace(l), "|") {
 				continue // 跳过异常行
 			}
 			html.WriteString("<tr>")
-			for _, c := range cells[1 : len(cells)-1] {
-				html.WriteString("<td>" + strings.TrimSpace(c) + "</td>")
+			for _, c := range splitTableRow(l) {
+				html.WriteString("<td>" + renderInlineMarkdown(c) + "</td>")
 			}
 			html.WriteString("</tr>\n")
 		}
@@ -1090,27 +1734,79 @@ func DetectPredictionAnomaly(predValue float64, history []StockData) (bool, stri
 
 // 修改 GenerateAIReportWithConfigAndSearch 实现，支持 hybridSearch
 func GenerateAIReportWithConfigAndSearch(stock, prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool) (string, error) {
-	// 构造请求体
+	return GenerateAIReportWithContext(context.Background(), stock, prompt, apiKey, apiURL, model, searchMode, hybridSearch)
+}
+
+// DefaultTemperature 未指定 Temperature 时的采样温度
+var DefaultTemperature = 0.7
+
+// GenerationConfig 单次生成的采样参数，零值字段使用 DefaultTemperature / MaxResponseTokens
+type GenerationConfig struct {
+	Temperature float64
+	MaxTokens   int
+}
+
+// ChatURL 本次分析使用的 chat/completions 地址
+func (p AnalysisParams) ChatURL() string {
+	if p.APIURL != "" {
+		return p.APIURL
+	}
+	return ChatCompletionsURL(DeepSeekBaseURL)
+}
+
+// Generation 分析参数中的采样配置
+func (p AnalysisParams) Generation() GenerationConfig {
+	return GenerationConfig{Temperature: p.Temperature, MaxTokens: p.MaxTokens}
+}
+
+func (c GenerationConfig) temperature() float64 {
+	if c.Temperature > 0 {
+		return c.Temperature
+	}
+	return DefaultTemperature
+}
+
+func (c GenerationConfig) maxTokens() int {
+	if c.MaxTokens > 0 {
+		return c.MaxTokens
+	}
+	return MaxResponseTokens
+}
+
+// deepSeekChatBody 构造 DeepSeek chat/completions 请求体
+func deepSeekChatBody(prompt, model string, searchMode bool, hybridSearch bool, cfg GenerationConfig) map[string]interface{} {
 	body := map[string]interface{}{
 		"model": model,
 		"messages": []map[string]string{
 			{"role": "system", "content": "你是一个智能股票分析助手。"},
 			{"role": "user", "content": prompt},
 		},
-		"temperature": 0.7,
-		"max_tokens":  2000,
+		"temperature": cfg.temperature(),
+		"max_tokens":  cfg.maxTokens(),
 	}
 	if hybridSearch {
 		body["search"] = true // 混合模式，自动融合
 	} else if searchMode {
 		body["search"] = true // 兼容原有联网搜索
 	}
-	data, _ := json.Marshal(body)
-	client := &http.Client{}
-	req, _ := http.NewRequest("POST", apiURL, strings.NewReader(string(data)))
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Content-Type", "application/json")
-	resp, err := client.Do(req)
+	return body
+}
+
+// GenerateAIReportWithContext 同 GenerateAIReportWithConfigAndSearch，ctx 取消时中断请求
+func GenerateAIReportWithContext(ctx context.Context, stock, prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool) (string, error) {
+	return GenerateAIReportWithGeneration(ctx, stock, prompt, apiKey, apiURL, model, searchMode, hybridSearch, GenerationConfig{})
+}
+
+// GenerateAIReportWithGeneration 同 GenerateAIReportWithContext，按 cfg 设置 temperature 与 max_tokens
+func GenerateAIReportWithGeneration(ctx context.Context, stock, prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool, cfg GenerationConfig) (string, error) {
+	if err := CheckLLMAllowed(apiURL); err != nil {
+		return "", err
+	}
+	data, _ := json.Marshal(deepSeekChatBody(prompt, model, searchMode, hybridSearch, cfg))
+	resp, err := postLLMWithRetry(ctx, &http.Client{}, apiURL, data, map[string]string{
+		"Authorization": "Bearer " + apiKey,
+		"Content-Type":  "application/json",
+	})
 	if err != nil {
 		return "", err
 	}
@@ -1142,9 +1838,11 @@ func GenerateGminiReportWithConfigAndSearch(params AnalysisParams) (string, erro
 	return "[gmini大模型分析报告]（此处为gmini模型返回的内容）", nil
 }
 
-// Gemini大模型API调用，支持 deepSearch
-func GenerateGeminiReportWithConfigAndSearch(model, apiKey, prompt string, deepSearch bool) (string, error) {
-	ctx := context.Background()
+// Gemini大模型API调用，支持 deepSearch；ctx 取消时中止请求
+func GenerateGeminiReportWithConfigAndSearch(ctx context.Context, model, apiKey, prompt string, deepSearch bool) (string, error) {
+	if err := CheckLLMAllowed("https://" + GeminiAPIHost); err != nil {
+		return "", err
+	}
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
 		APIKey:  apiKey,
 		Backend: genai.BackendGeminiAPI,
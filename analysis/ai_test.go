@@ -0,0 +1,270 @@
+package analysis
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// macdTestCloses 30 日收盘价，参考值按 EMA12/EMA26 以首日收盘为初值、DEA 为 DIF 的 9 日 EMA 独立计算
+var macdTestCloses = []float64{10.0, 10.5, 10.2, 10.8, 11.3, 11.1, 11.6, 12.0, 11.7, 12.4, 12.9, 12.6, 13.1, 13.5, 13.2,
+	13.8, 14.1, 13.9, 14.6, 15.0, 14.7, 15.3, 15.8, 15.5, 16.1, 16.4, 16.0, 16.7, 17.1, 16.8}
+
+func TestCalcMACDSeriesReference(t *testing.T) {
+	difs, deas := calcMACDSeries(macdTestCloses)
+	cases := []struct {
+		idx      int
+		dif, dea float64
+	}{
+		{0, 0, 0},
+		{1, 0.039886039886040336, 0.007977207977208068},
+		{9, 0.501504794439871, 0.28543678654685894},
+		{29, 1.3744453356215356, 1.2717157931525913},
+	}
+	for _, c := range cases {
+		if math.Abs(difs[c.idx]-c.dif) > 1e-9 || math.Abs(deas[c.idx]-c.dea) > 1e-9 {
+			t.Errorf("第 %d 日 DIF/DEA = %v/%v，期望 %v/%v", c.idx, difs[c.idx], deas[c.idx], c.dif, c.dea)
+		}
+	}
+}
+
+func TestCalculateTechnicalIndicatorsMACDHistogram(t *testing.T) {
+	var data []StockData
+	for _, c := range macdTestCloses {
+		data = append(data, StockData{Open: c, Close: c, High: c, Low: c, Volume: 1000})
+	}
+	ind := calculateTechnicalIndicators(data)
+	last := ind[len(ind)-1]
+	if math.Abs(last.MACDHistogram-0.20545908493788856) > 1e-9 {
+		t.Errorf("MACD 柱 = %v，期望 2*(DIF-DEA) = 0.2054…", last.MACDHistogram)
+	}
+	if ind[24].MACD != 0 || ind[25].MACD == 0 {
+		t.Errorf("MACD 应从第 26 根K线起输出，得到 ind[24]=%v ind[25]=%v", ind[24].MACD, ind[25].MACD)
+	}
+}
+
+func TestCalcKDJSeriesJExceeds100InRisingMarket(t *testing.T) {
+	var data []StockData
+	price := 10.0
+	for i := 0; i < 20; i++ {
+		price *= 1.03
+		data = append(data, StockData{Open: price * 0.99, Close: price, High: price, Low: price * 0.98})
+	}
+	ks, ds, js := calcKDJSeries(data, 9)
+	last := len(data) - 1
+	if js[last] <= 100 {
+		t.Fatalf("持续上涨时 J 应超过 100，得到 %v", js[last])
+	}
+	if ks[last] > 100 || ds[last] > 100 {
+		t.Errorf("K/D 不应超过 100，得到 K=%v D=%v", ks[last], ds[last])
+	}
+	if math.Abs(js[last]-(3*ks[last]-2*ds[last])) > 1e-9 {
+		t.Errorf("J 应为 3K-2D")
+	}
+}
+
+func TestCalcKDJSeriesIterative(t *testing.T) {
+	data := []StockData{
+		{High: 10, Low: 8, Close: 9},
+		{High: 11, Low: 9, Close: 11},
+	}
+	ks, ds, _ := calcKDJSeries(data, 9)
+	// 首日 RSV=50，K=D=50；次日 RSV=(11-8)/(11-8)*100=100
+	wantK := 2.0/3.0*50 + 1.0/3.0*100
+	wantD := 2.0/3.0*50 + 1.0/3.0*wantK
+	if math.Abs(ks[0]-50) > 1e-9 || math.Abs(ds[0]-50) > 1e-9 || math.Abs(ks[1]-wantK) > 1e-9 || math.Abs(ds[1]-wantD) > 1e-9 {
+		t.Errorf("K/D = %v/%v，期望 50/50 与 %v/%v", ks, ds, wantK, wantD)
+	}
+}
+
+// syntheticBars 生成 n 根确定性的振荡K线
+func syntheticBars(n int) []StockData {
+	data := make([]StockData, n)
+	for i := range data {
+		c := 50 + 10*math.Sin(float64(i)/7) + 3*math.Cos(float64(i)/3) + float64(i)*0.01
+		data[i] = StockData{Open: c - 0.3, Close: c, High: c + 1, Low: c - 1, Volume: 1e6 + 1e5*math.Sin(float64(i)/5)}
+	}
+	return data
+}
+
+// naiveMA 逐项回看的简单均线，用于校验前缀和实现
+func naiveMA(values []float64, n, idx int) float64 {
+	if idx+1 < n {
+		return 0
+	}
+	sum := 0.0
+	for i := idx - n + 1; i <= idx; i++ {
+		sum += values[i]
+	}
+	return sum / float64(n)
+}
+
+// naiveRSI 逐项回看的 RSI
+func naiveRSI(closes []float64, period, idx int) float64 {
+	if idx < period {
+		return 0
+	}
+	var gain, loss float64
+	for i := idx - period + 1; i <= idx; i++ {
+		if ch := closes[i] - closes[i-1]; ch > 0 {
+			gain += ch
+		} else {
+			loss -= ch
+		}
+	}
+	if loss == 0 {
+		return 100
+	}
+	return 100 - 100/(1+gain/loss)
+}
+
+func TestCalculateTechnicalIndicatorsMatchesNaive(t *testing.T) {
+	data := syntheticBars(600)
+	var closes, volumes []float64
+	for _, d := range data {
+		closes = append(closes, d.Close)
+		volumes = append(volumes, d.Volume)
+	}
+	ind := calculateTechnicalIndicators(data)
+	const eps = 1e-9
+	check := func(name string, i int, got, want float64) {
+		t.Helper()
+		if math.Abs(got-want) > eps*math.Max(1, math.Abs(want)) {
+			t.Fatalf("第 %d 根 %s = %v，逐项计算为 %v", i, name, got, want)
+		}
+	}
+	for i := range data {
+		check("MA5", i, ind[i].MA5, naiveMA(closes, 5, i))
+		check("MA20", i, ind[i].MA20, naiveMA(closes, 20, i))
+		check("MA60", i, ind[i].MA60, naiveMA(closes, 60, i))
+		check("MA250", i, ind[i].MA250, naiveMA(closes, 250, i))
+		check("VolumeMA10", i, ind[i].VolumeMA10, naiveMA(volumes, 10, i))
+		check("RSI6", i, ind[i].RSI6, naiveRSI(closes, 6, i))
+		check("RSI24", i, ind[i].RSI24, naiveRSI(closes, 24, i))
+		check("BOLLMiddle", i, ind[i].BOLLMiddle, naiveMA(closes, 20, i))
+	}
+}
+
+func BenchmarkCalculateTechnicalIndicators(b *testing.B) {
+	data := syntheticBars(5000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		calculateTechnicalIndicators(data)
+	}
+}
+
+func TestConvertMarkdownTablesRendersInlineMarkdown(t *testing.T) {
+	md := "| 指标 | 说明 |\n|---|---|\n| **趋势** | 见 [公告](https://example.com/a?b=1&c=2) |\n| *RSI* | `70` 以上超买 \\| 谨慎 |\n| - 列表 | # 标题 |\n"
+	out := convertMarkdownTablesToHTML(md)
+	for _, want := range []string{
+		"<td><strong>趋势</strong></td>",
+		`<a href="https://example.com/a?b=1&amp;c=2">公告</a>`,
+		"<td><em>RSI</em></td>",
+		"<code>70</code> 以上超买 | 谨慎",
+		"<td>- 列表</td>",
+		"<td># 标题</td>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("缺少 %q:\n%s", want, out)
+		}
+	}
+	if n := strings.Count(out, "<tr>"); n != 4 {
+		t.Errorf("转义竖线不应拆分单元格，期望 4 行，得到 %d:\n%s", n, out)
+	}
+}
+
+func TestEMAFamilyHandRecursion(t *testing.T) {
+	// period=3 时 EMA = (2*当日 + 2*前值)/4，逐步手算：10, 10.5, 11.25, 12.125
+	if got, want := emaSeries([]float64{10, 11, 12, 13}, 3), []float64{10, 10.5, 11.25, 12.125}; !floatsEqual(got, want) {
+		t.Errorf("EMA = %v，期望 %v", got, want)
+	}
+
+	// 按 alpha=2/(n+1) 独立递推三层 EMA，与预热期之后的 EMA/DEMA/TEMA 比对
+	const period = 5
+	alpha := 2.0 / (period + 1)
+	var e1, e2, e3 float64
+	ema, dema, tema := calcEMAFamily(macdTestCloses, period)
+	for i, c := range macdTestCloses {
+		if i == 0 {
+			e1, e2, e3 = c, c, c
+		} else {
+			e1 = alpha*c + (1-alpha)*e1
+			e2 = alpha*e1 + (1-alpha)*e2
+			e3 = alpha*e2 + (1-alpha)*e3
+		}
+		wantEMA, wantDEMA, wantTEMA := e1, 2*e1-e2, 3*e1-3*e2+e3
+		if i < period-1 {
+			wantEMA = 0
+		}
+		if i < 2*(period-1) {
+			wantDEMA = 0
+		}
+		if i < 3*(period-1) {
+			wantTEMA = 0
+		}
+		if math.Abs(ema[i]-wantEMA) > 1e-9 || math.Abs(dema[i]-wantDEMA) > 1e-9 || math.Abs(tema[i]-wantTEMA) > 1e-9 {
+			t.Errorf("第 %d 日 EMA/DEMA/TEMA = %v/%v/%v，期望 %v/%v/%v", i, ema[i], dema[i], tema[i], wantEMA, wantDEMA, wantTEMA)
+		}
+	}
+}
+
+func TestIndicatorEMAMatchesMACD(t *testing.T) {
+	var data []StockData
+	for _, c := range macdTestCloses {
+		data = append(data, StockData{Open: c, Close: c, High: c, Low: c, Volume: 1000})
+	}
+	ind := calculateTechnicalIndicators(data)
+	for i := 25; i < len(ind); i++ {
+		if diff := ind[i].EMA12 - ind[i].EMA26; math.Abs(diff-ind[i].MACD) > 1e-12 {
+			t.Errorf("第 %d 日 EMA12-EMA26 = %v，DIF = %v，两者口径应一致", i, diff, ind[i].MACD)
+		}
+	}
+}
+
+func floatsEqual(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > 1e-12 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestValidateAndFilterDataRepairMode(t *testing.T) {
+	data := datedBars(8)
+	data[0].Close = -1 // 开头异常无前值，丢弃
+	data[3].High = 0   // 孤立异常点，前后均值插值
+	data[5].Open = -1  // 连续异常，前值填充
+	data[6].Low = 0
+
+	if got := validateAndFilterData(append([]StockData(nil), data...), "600036"); len(got) != 4 {
+		t.Errorf("丢弃模式应剩 4 根，得到 %d", len(got))
+	}
+
+	old := OutlierMode
+	OutlierMode = OutlierRepair
+	defer func() { OutlierMode = old }()
+	got := validateAndFilterData(append([]StockData(nil), data...), "600036")
+	if len(got) != 7 {
+		t.Fatalf("修复模式应只丢弃开头异常点，剩 7 根，得到 %d", len(got))
+	}
+	for i, bar := range got {
+		if !bar.Date.Equal(data[i+1].Date) {
+			t.Errorf("第 %d 根日期 = %s，期望 %s，序列应保持连续", i, bar.Date, data[i+1].Date)
+		}
+	}
+	if bar, want := got[2], (data[2].Close+data[4].Close)/2; bar.Close != want || bar.High != (data[2].High+data[4].High)/2 {
+		t.Errorf("孤立异常点应插值为前后均值: %+v，期望收盘 %v", bar, want)
+	}
+	for _, bar := range got[4:6] {
+		if bar.Close != data[4].Close || bar.Open != data[4].Open {
+			t.Errorf("连续异常应用前值填充: %+v", bar)
+		}
+	}
+	if got[6] != data[7] {
+		t.Errorf("有效K线不应被修改: %+v", got[6])
+	}
+}
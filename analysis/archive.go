@@ -0,0 +1,175 @@
+package analysis
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// HistoryDir 历史报告目录
+var HistoryDir = "history"
+
+// ArchiveDir 历史报告归档目录，按月存放 history-YYYY-MM.zip
+var ArchiveDir = filepath.Join("history", "archive")
+
+// archivableExts 参与归档的报告格式，feed.xml、conclusions.jsonl、predictions.csv 等索引文件保留在原目录
+var archivableExts = map[string]bool{".md": true, ".html": true, ".pdf": true}
+
+func archiveZipPath(month string) string {
+	return filepath.Join(ArchiveDir, "history-"+month+".zip")
+}
+
+// ArchiveHistory 将修改时间早于 now 往前 days 天的报告按修改月份打包进归档 zip，写入成功后删除原文件，返回归档的文件数
+func ArchiveHistory(days int, now time.Time) (int, error) {
+	files, err := ioutil.ReadDir(HistoryDir)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := now.AddDate(0, 0, -days)
+	byMonth := make(map[string][]string)
+	for _, f := range files {
+		if f.IsDir() || !archivableExts[strings.ToLower(filepath.Ext(f.Name()))] || !f.ModTime().Before(cutoff) {
+			continue
+		}
+		month := f.ModTime().Format("2006-01")
+		byMonth[month] = append(byMonth[month], f.Name())
+	}
+	if len(byMonth) == 0 {
+		return 0, nil
+	}
+	if err := os.MkdirAll(ArchiveDir, 0755); err != nil {
+		return 0, err
+	}
+
+	archived := 0
+	for month, names := range byMonth {
+		if err := appendToArchive(archiveZipPath(month), names); err != nil {
+			return archived, fmt.Errorf("归档 %s 失败: %v", month, err)
+		}
+		for _, name := range names {
+			os.Remove(filepath.Join(HistoryDir, name))
+		}
+		archived += len(names)
+	}
+	return archived, nil
+}
+
+// appendToArchive 将 history 下的 names 写入 zipPath：已有归档时先复制原条目（同名条目以新文件为准），写入临时文件后再替换
+func appendToArchive(zipPath string, names []string) error {
+	tmpPath := zipPath + ".tmp"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(out)
+	fail := func(err error) error {
+		zw.Close()
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	replaced := make(map[string]bool)
+	for _, name := range names {
+		replaced[name] = true
+	}
+	if zr, err := zip.OpenReader(zipPath); err == nil {
+		for _, f := range zr.File {
+			if replaced[f.Name] {
+				continue
+			}
+			if err := zw.Copy(f); err != nil {
+				zr.Close()
+				return fail(err)
+			}
+		}
+		zr.Close()
+	}
+
+	for _, name := range names {
+		src := filepath.Join(HistoryDir, name)
+		info, err := os.Stat(src)
+		if err != nil {
+			return fail(err)
+		}
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return fail(err)
+		}
+		header.Method = zip.Deflate
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			return fail(err)
+		}
+		data, err := ioutil.ReadFile(src)
+		if err != nil {
+			return fail(err)
+		}
+		if _, err := w.Write(data); err != nil {
+			return fail(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		out.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := out.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, zipPath)
+}
+
+// ListArchivedFiles 归档中的报告文件名 -> 所在归档 zip 文件名
+func ListArchivedFiles() map[string]string {
+	result := make(map[string]string)
+	zips, _ := filepath.Glob(filepath.Join(ArchiveDir, "history-*.zip"))
+	sort.Strings(zips)
+	for _, z := range zips {
+		zr, err := zip.OpenReader(z)
+		if err != nil {
+			continue
+		}
+		for _, f := range zr.File {
+			result[f.Name] = filepath.Base(z)
+		}
+		zr.Close()
+	}
+	return result
+}
+
+// ReadHistoryFile 读取历史报告，history 目录不存在时透明地从归档 zip 中解压读取
+func ReadHistoryFile(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(HistoryDir, filename))
+	if err == nil || !os.IsNotExist(err) {
+		return data, err
+	}
+	zipName, ok := ListArchivedFiles()[filename]
+	if !ok {
+		return nil, err
+	}
+	zr, zerr := zip.OpenReader(filepath.Join(ArchiveDir, zipName))
+	if zerr != nil {
+		return nil, zerr
+	}
+	defer zr.Close()
+	for _, f := range zr.File {
+		if f.Name != filename {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		return io.ReadAll(rc)
+	}
+	return nil, err
+}
@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useHistoryDir 将历史与归档目录指向临时目录，files 为文件名 -> 修改时间
+func useHistoryDir(t *testing.T, files map[string]time.Time) {
+	t.Helper()
+	oldHistory, oldArchive := HistoryDir, ArchiveDir
+	HistoryDir = t.TempDir()
+	ArchiveDir = filepath.Join(HistoryDir, "archive")
+	t.Cleanup(func() { HistoryDir, ArchiveDir = oldHistory, oldArchive })
+	for name, mtime := range files {
+		path := filepath.Join(HistoryDir, name)
+		if err := os.WriteFile(path, []byte("report "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestArchiveHistoryAndTransparentRead(t *testing.T) {
+	now := time.Date(2024, 6, 15, 12, 0, 0, 0, time.Local)
+	useHistoryDir(t, map[string]time.Time{
+		"600036-2024-03-29-150000.md":   time.Date(2024, 3, 29, 15, 0, 0, 0, time.Local),
+		"600036-2024-03-29-150000.html": time.Date(2024, 3, 29, 15, 0, 0, 0, time.Local),
+		"000001--093000.md":             time.Date(2024, 4, 2, 9, 30, 0, 0, time.Local),
+		"600519-2024-06-10-100000.md":   time.Date(2024, 6, 10, 10, 0, 0, 0, time.Local),
+		"conclusions.jsonl":             time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local),
+	})
+
+	n, err := ArchiveHistory(30, now)
+	if err != nil || n != 3 {
+		t.Fatalf("应归档 3 份超过 30 天的报告，得到 %d, err=%v", n, err)
+	}
+	for name, zipName := range map[string]string{
+		"600036-2024-03-29-150000.md":   "history-2024-03.zip",
+		"600036-2024-03-29-150000.html": "history-2024-03.zip",
+		"000001--093000.md":             "history-2024-04.zip",
+	} {
+		if _, err := os.Stat(filepath.Join(HistoryDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s 归档后应从目录移除", name)
+		}
+		if got := ListArchivedFiles()[name]; got != zipName {
+			t.Errorf("%s 所在归档 = %q，期望 %s", name, got, zipName)
+		}
+		data, err := ReadHistoryFile(name)
+		if err != nil || string(data) != "report "+name {
+			t.Errorf("应透明读取归档内的 %s: %q %v", name, data, err)
+		}
+	}
+	for _, name := range []string{"600519-2024-06-10-100000.md", "conclusions.jsonl"} {
+		if _, err := os.Stat(filepath.Join(HistoryDir, name)); err != nil {
+			t.Errorf("%s 不应被归档: %v", name, err)
+		}
+	}
+	if _, err := ReadHistoryFile("missing.md"); !os.IsNotExist(err) {
+		t.Errorf("不存在的报告应返回 NotExist: %v", err)
+	}
+
+	// 历史索引仍能定位归档内的报告
+	entries := QueryHistory(HistoryFilter{StockCode: "600036.SH", Formats: []string{"md"}})
+	if len(entries) != 1 || entries[0].Archive != "history-2024-03.zip" || entries[0].End != "2024-03-29" {
+		t.Errorf("索引应包含归档内的报告: %+v", entries)
+	}
+	if all := QueryHistory(HistoryFilter{}); len(all) != 4 || all[0].Name != "600519-2024-06-10-100000.md" {
+		t.Errorf("索引应按时间倒序包含目录与归档中的报告: %+v", all)
+	}
+}
+
+func TestArchiveHistoryMergesIntoExistingZip(t *testing.T) {
+	march := time.Date(2024, 3, 5, 10, 0, 0, 0, time.Local)
+	useHistoryDir(t, map[string]time.Time{"600036-2024-03-05-100000.md": march})
+	if n, err := ArchiveHistory(30, march.AddDate(0, 2, 0)); err != nil || n != 1 {
+		t.Fatalf("首次归档: %d %v", n, err)
+	}
+	later := march.AddDate(0, 0, 10)
+	for _, name := range []string{"600036-2024-03-15-100000.md", "600036-2024-03-05-100000.md"} {
+		path := filepath.Join(HistoryDir, name)
+		os.WriteFile(path, []byte("v2 "+name), 0644)
+		os.Chtimes(path, later, later)
+	}
+	if n, err := ArchiveHistory(30, march.AddDate(0, 2, 0)); err != nil || n != 2 {
+		t.Fatalf("再次归档: %d %v", n, err)
+	}
+	archived := ListArchivedFiles()
+	if len(archived) != 2 {
+		t.Fatalf("同月归档应合并到同一 zip: %v", archived)
+	}
+	if data, _ := ReadHistoryFile("600036-2024-03-05-100000.md"); string(data) != "v2 600036-2024-03-05-100000.md" {
+		t.Errorf("同名条目应以新文件为准: %q", data)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(ArchiveDir, "*.tmp")); len(matches) != 0 {
+		t.Errorf("不应残留临时文件: %v", matches)
+	}
+}
@@ -0,0 +1,177 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RoundTrip 一次完整的开平仓交易
+type RoundTrip struct {
+	EntryDate  time.Time
+	ExitDate   time.Time
+	EntryPrice float64
+	ExitPrice  float64
+	Shares     float64
+	PnL        float64 // 盈亏金额
+	Return     float64 // 收益率
+	ExitReason string
+}
+
+// BacktestAttribution 回测风险归因结果
+type BacktestAttribution struct {
+	DrawdownPeak   time.Time   // 最大回撤起点（权益峰值日）
+	DrawdownTrough time.Time   // 最大回撤终点（权益谷底日）
+	MaxDrawdown    float64     // 最大回撤幅度
+	DrawdownTrades []RoundTrip // 回撤区间内的亏损交易，按亏损从大到小
+	TopLosses      []RoundTrip // 全部交易中亏损最大的若干笔
+	WorstTrade     *RoundTrip  // 单笔最大亏损
+}
+
+// BuildRoundTrips 将逐笔买卖记录配对为开平仓交易
+func BuildRoundTrips(trades []Trade) []RoundTrip {
+	var trips []RoundTrip
+	var open *Trade
+	for i := range trades {
+		t := trades[i]
+		if t.Type == "buy" {
+			open = &trades[i]
+			continue
+		}
+		if t.Type == "sell" && open != nil {
+			pnl := (t.Price - open.Price) * open.Shares
+			ret := 0.0
+			if open.Price > 0 {
+				ret = (t.Price - open.Price) / open.Price
+			}
+			trips = append(trips, RoundTrip{
+				EntryDate:  open.Date,
+				ExitDate:   t.Date,
+				EntryPrice: open.Price,
+				ExitPrice:  t.Price,
+				Shares:     open.Shares,
+				PnL:        pnl,
+				Return:     ret,
+				ExitReason: t.Reason,
+			})
+			open = nil
+		}
+	}
+	return trips
+}
+
+// AnalyzeBacktestAttribution 对资金曲线和交易记录做归因，找出贡献最大回撤的交易区间和单笔最大亏损
+func AnalyzeBacktestAttribution(stockData []StockData, result BacktestResult, topN int) BacktestAttribution {
+	var attr BacktestAttribution
+	trips := BuildRoundTrips(result.TradeHistory)
+
+	var losses []RoundTrip
+	for _, t := range trips {
+		if t.PnL < 0 {
+			losses = append(losses, t)
+		}
+	}
+	sort.Slice(losses, func(i, j int) bool { return losses[i].PnL < losses[j].PnL })
+	if len(losses) > 0 {
+		worst := losses[0]
+		attr.WorstTrade = &worst
+	}
+	if topN > 0 && len(losses) > topN {
+		attr.TopLosses = losses[:topN]
+	} else {
+		attr.TopLosses = losses
+	}
+
+	// 资金曲线第 k 个点对应 stockData[offset+k-1]，第 0 个点为初始资金
+	curve := result.EquityCurve
+	if len(curve) < 2 || len(stockData) == 0 {
+		return attr
+	}
+	offset := len(stockData) - (len(curve) - 1)
+	if offset < 1 {
+		offset = 1
+	}
+	dateAt := func(k int) time.Time {
+		idx := offset + k - 1
+		if idx < 0 {
+			idx = 0
+		}
+		if idx >= len(stockData) {
+			idx = len(stockData) - 1
+		}
+		return stockData[idx].Date
+	}
+	peakIdx, bestPeak, bestTrough := 0, 0, 0
+	for k, eq := range curve {
+		if eq > curve[peakIdx] {
+			peakIdx = k
+		}
+		if curve[peakIdx] <= 0 {
+			continue
+		}
+		dd := (curve[peakIdx] - eq) / curve[peakIdx]
+		if dd > attr.MaxDrawdown {
+			attr.MaxDrawdown = dd
+			bestPeak, bestTrough = peakIdx, k
+		}
+	}
+	if attr.MaxDrawdown == 0 {
+		return attr
+	}
+	attr.DrawdownPeak = dateAt(bestPeak)
+	attr.DrawdownTrough = dateAt(bestTrough)
+	for _, t := range losses {
+		if t.ExitDate.After(attr.DrawdownPeak) && !t.EntryDate.After(attr.DrawdownTrough) {
+			attr.DrawdownTrades = append(attr.DrawdownTrades, t)
+		}
+	}
+	return attr
+}
+
+// FormatAttributionTable 回测风险归因 markdown 表格，lang 为 en 时使用英文
+func FormatAttributionTable(attr BacktestAttribution, lang string) string {
+	if attr.WorstTrade == nil && attr.MaxDrawdown == 0 {
+		return ""
+	}
+	l := labelsFor(lang)
+	var b strings.Builder
+	b.WriteString("\n" + l.attrTitle + "\n")
+	if attr.MaxDrawdown > 0 {
+		b.WriteString(fmt.Sprintf(l.attrDrawdown+"\n",
+			FormatPercent(attr.MaxDrawdown), attr.DrawdownPeak.Format("2006-01-02"), attr.DrawdownTrough.Format("2006-01-02"), len(attr.DrawdownTrades)))
+	}
+	if len(attr.TopLosses) == 0 {
+		return b.String()
+	}
+	b.WriteString("\n" + markdownHeader(l.attrHead))
+	for i, t := range attr.TopLosses {
+		b.WriteString(fmt.Sprintf("| %d | %s | %s | %s | %s | %s | %s | %s |\n",
+			i+1, t.EntryDate.Format("2006-01-02"), t.ExitDate.Format("2006-01-02"), FormatPrice(t.EntryPrice), FormatPrice(t.ExitPrice), formatAmountLang(t.PnL, lang), FormatPercent(t.Return), localize(t.ExitReason, lang)))
+	}
+	return b.String()
+}
+
+// FormatAttributionTableHTML 回测风险归因 HTML 表格，lang 为 en 时使用英文
+func FormatAttributionTableHTML(attr BacktestAttribution, lang string) string {
+	if attr.WorstTrade == nil && attr.MaxDrawdown == 0 {
+		return ""
+	}
+	l := labelsFor(lang)
+	var b strings.Builder
+	b.WriteString("\n<h3>" + l.attrTitle + "</h3>\n")
+	if attr.MaxDrawdown > 0 {
+		b.WriteString(fmt.Sprintf("<p>"+l.attrDrawdown+"</p>\n",
+			FormatPercent(attr.MaxDrawdown), attr.DrawdownPeak.Format("2006-01-02"), attr.DrawdownTrough.Format("2006-01-02"), len(attr.DrawdownTrades)))
+	}
+	if len(attr.TopLosses) == 0 {
+		return b.String()
+	}
+	b.WriteString("<table>\n" + htmlHeader(l.attrHead) + "\n")
+	for i, t := range attr.TopLosses {
+		b.WriteString(fmt.Sprintf("<tr><td>%d</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			i+1, t.EntryDate.Format("2006-01-02"), t.ExitDate.Format("2006-01-02"), FormatPrice(t.EntryPrice), FormatPrice(t.ExitPrice), formatAmountLang(t.PnL, lang), FormatPercent(t.Return), localize(t.ExitReason, lang)))
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
@@ -0,0 +1,67 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+// attributionFixture 三笔交易：小赚、大亏（止损）、小亏（期末平仓），资金曲线与之对应
+func attributionFixture() ([]StockData, BacktestResult) {
+	data := datedBars(6)
+	trade := func(i int, typ string, price float64, reason string) Trade {
+		return Trade{Date: data[i].Date, Type: typ, Price: price, Shares: 100, Reason: reason}
+	}
+	return data, BacktestResult{
+		TradeHistory: []Trade{
+			trade(0, "buy", 10, "金叉买入"), trade(1, "sell", 11, "死叉卖出"),
+			trade(2, "buy", 12, "金叉买入"), trade(3, "sell", 6, "止损"),
+			trade(4, "buy", 7, "金叉买入"), trade(5, "sell", 6.5, "期末平仓"),
+		},
+		EquityCurve: []float64{1000, 1000, 1100, 1100, 500, 500, 450},
+	}
+}
+
+func TestAnalyzeBacktestAttributionFindsBigLoss(t *testing.T) {
+	data, result := attributionFixture()
+	attr := AnalyzeBacktestAttribution(data, result, 5)
+	if attr.WorstTrade == nil || attr.WorstTrade.PnL != -600 || attr.WorstTrade.ExitReason != "止损" {
+		t.Fatalf("单笔最大亏损 = %+v，期望止损亏损 600", attr.WorstTrade)
+	}
+	if len(attr.TopLosses) != 2 || attr.TopLosses[0].PnL != -600 || attr.TopLosses[1].PnL != -50 {
+		t.Errorf("Top 亏损应按亏损从大到小且不含盈利交易: %+v", attr.TopLosses)
+	}
+	if want := (1100.0 - 450) / 1100; attr.MaxDrawdown != want {
+		t.Errorf("最大回撤 = %v，期望 %v", attr.MaxDrawdown, want)
+	}
+	if !attr.DrawdownPeak.Equal(data[2].Date) || !attr.DrawdownTrough.Equal(data[5].Date) {
+		t.Errorf("回撤区间 = %s ~ %s，期望 %s ~ %s", attr.DrawdownPeak, attr.DrawdownTrough, data[2].Date, data[5].Date)
+	}
+	if len(attr.DrawdownTrades) != 2 || attr.DrawdownTrades[0].PnL != -600 {
+		t.Errorf("回撤区间内亏损交易 = %+v", attr.DrawdownTrades)
+	}
+	if top := AnalyzeBacktestAttribution(data, result, 1).TopLosses; len(top) != 1 {
+		t.Errorf("topN=1 时应只保留 1 笔: %+v", top)
+	}
+}
+
+func TestFormatAttributionTableLang(t *testing.T) {
+	data, result := attributionFixture()
+	attr := AnalyzeBacktestAttribution(data, result, 5)
+	zh := FormatAttributionTable(attr, "")
+	if !strings.Contains(zh, "【回测风险归因】") || !strings.Contains(zh, "| 1 |") || !strings.Contains(zh, "止损") {
+		t.Errorf("中文归因表:\n%s", zh)
+	}
+	for name, table := range map[string]string{"markdown": FormatAttributionTable(attr, LangEN), "html": FormatAttributionTableHTML(attr, LangEN)} {
+		if containsHan(table) {
+			t.Errorf("%s 英文归因表含中文:\n%s", name, table)
+		}
+		for _, want := range []string{"Backtest Risk Attribution", "Max drawdown", "Stop loss", "Closed at period end"} {
+			if !strings.Contains(table, want) {
+				t.Errorf("%s 英文归因表缺少 %q:\n%s", name, want, table)
+			}
+		}
+	}
+	if FormatAttributionTable(BacktestAttribution{}, LangEN) != "" {
+		t.Error("无亏损且无回撤时应返回空")
+	}
+}
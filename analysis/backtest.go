@@ -1,8 +1,13 @@
 package analysis
 
+import (
+	"math"
+	"time"
+)
+
 // 回测参数
 type BacktestParams struct {
-	StrategyType   string  // 策略类型：ma_cross, breakout, rsi
+	StrategyType   string  // 策略类型：ma_cross, breakout, rsi, atr_trailing
 	FastMAPeriod   int     // 快速均线周期
 	SlowMAPeriod   int     // 慢速均线周期
 	BreakoutPeriod int     // 突破周期
@@ -12,16 +17,34 @@ type BacktestParams struct {
 	StopLoss       float64 // 止损百分比
 	TakeProfit     float64 // 止盈百分比
 	InitialCash    float64 // 初始资金
+	ATRPeriod      int     // ATR周期（atr_trailing，默认14）
+	ATRMultiplier  float64 // 追踪止损的ATR倍数k（atr_trailing，默认3）
+	BuyFee         float64 // 买入费率，AnalyzeOne 中买卖费率均为 0 时按市场取 MarketProfile
+	SellFee        float64 // 卖出费率（含印花税）
 }
 
 // 回测结果
 type BacktestResult struct {
-	TotalReturn  float64   // 总收益率
-	WinRate      float64   // 胜率
-	MaxDrawdown  float64   // 最大回撤
-	Trades       int       // 交易次数
-	ProfitFactor float64   // 盈亏比
-	EquityCurve  []float64 // 资金曲线
+	InitialCapital   float64   `json:"initial_capital"`   // 初始资金
+	TotalReturn      float64   `json:"total_return"`      // 总收益率
+	AnnualizedReturn float64   `json:"annualized_return"` // 年化收益率，按回测交易日跨度折算
+	SharpeRatio      float64   `json:"sharpe_ratio"`      // 资金曲线日收益的年化夏普比率
+	WinRate          float64   `json:"win_rate"`          // 胜率
+	MaxDrawdown      float64   `json:"max_drawdown"`      // 最大回撤
+	Trades           int       `json:"trades"`            // 交易次数
+	ProfitFactor     float64   `json:"profit_factor"`     // 盈亏比
+	EquityCurve      []float64 `json:"equity_curve"`      // 资金曲线
+	TradeHistory     []Trade   `json:"trade_history"`     // 逐笔交易记录
+}
+
+// 单笔交易记录
+type Trade struct {
+	Date    time.Time `json:"date"`    // 成交日期
+	Type    string    `json:"type"`    // buy / sell
+	Price   float64   `json:"price"`   // 成交价
+	Shares  float64   `json:"shares"`  // 成交股数
+	Capital float64   `json:"capital"` // 成交后账户权益
+	Reason  string    `json:"reason"`  // 触发原因
 }
 
 // 均线计算
@@ -64,14 +87,57 @@ func rsi(prices []float64, period int, idx int) float64 {
 
 // 主回测入口
 func BacktestStrategy(stockData []StockData, params BacktestParams) BacktestResult {
+	var result BacktestResult
 	switch params.StrategyType {
 	case "breakout":
-		return backtestBreakout(stockData, params)
+		result = backtestBreakout(stockData, params)
 	case "rsi":
-		return backtestRSI(stockData, params)
+		result = backtestRSI(stockData, params)
+	case "atr_trailing":
+		result = backtestATRTrailing(stockData, params)
 	default:
-		return backtestMACross(stockData, params)
+		result = backtestMACross(stockData, params)
+	}
+	fillBacktestMetrics(&result, params)
+	return result
+}
+
+// fillBacktestMetrics 补充初始资金、年化收益率（(1+总收益)^(252/交易日数)-1）与资金曲线夏普比率，
+// 交易日数取资金曲线覆盖的回测区间
+func fillBacktestMetrics(result *BacktestResult, params BacktestParams) {
+	result.InitialCapital = params.InitialCash
+	days := len(result.EquityCurve) - 1
+	if days > 0 && result.TotalReturn > -1 {
+		result.AnnualizedReturn = math.Pow(1+result.TotalReturn, 252/float64(days)) - 1
+	}
+	if returns := equityReturns(result.EquityCurve); len(returns) > 1 {
+		result.SharpeRatio = calculateSharpeRatio(returns, RiskFreeRate)
+	}
+}
+
+// equityReturns 资金曲线的逐日收益率
+func equityReturns(curve []float64) []float64 {
+	var returns []float64
+	for i := 1; i < len(curve); i++ {
+		if prev := curve[i-1]; prev > 0 {
+			returns = append(returns, (curve[i]-prev)/prev)
+		}
+	}
+	return returns
+}
+
+// equityMaxDrawdown 资金曲线的最大回撤
+func equityMaxDrawdown(curve []float64) float64 {
+	maxDD, peak := 0.0, 0.0
+	for _, v := range curve {
+		if v > peak {
+			peak = v
+		}
+		if peak > 0 && (peak-v)/peak > maxDD {
+			maxDD = (peak - v) / peak
+		}
 	}
+	return maxDD
 }
 
 // 均线交叉策略
@@ -89,6 +155,7 @@ func backtestMACross(stockData []StockData, params BacktestParams) BacktestResul
 	lossSum := 0.0
 	maxEquity := cash
 	equityCurve := []float64{cash}
+	var tradeHistory []Trade
 
 	var closes []float64
 	for _, d := range stockData {
@@ -101,14 +168,16 @@ func backtestMACross(stockData []StockData, params BacktestParams) BacktestResul
 		price := closes[i]
 
 		if fastMA > slowMA && ma(closes, params.FastMAPeriod, i-1) <= ma(closes, params.SlowMAPeriod, i-1) && position == 0 {
-			position = cash / price
+			position = cash * (1 - params.BuyFee) / price
 			entryPrice = price
 			cash = 0
 			trades++
+			tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "buy", Price: price, Shares: position, Capital: position * price, Reason: "金叉买入"})
 		}
 		if fastMA < slowMA && ma(closes, params.FastMAPeriod, i-1) >= ma(closes, params.SlowMAPeriod, i-1) && position > 0 {
 			profit := (price - entryPrice) * position
-			cash = position * price
+			cash = position * price * (1 - params.SellFee)
+			tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "sell", Price: price, Shares: position, Capital: cash, Reason: "死叉卖出"})
 			if profit > 0 {
 				wins++
 				profitSum += profit
@@ -122,15 +191,16 @@ func backtestMACross(stockData []StockData, params BacktestParams) BacktestResul
 		if position > 0 {
 			if price <= entryPrice*(1-params.StopLoss) {
 				profit := (price - entryPrice) * position
-				cash = position * price
+				cash = position * price * (1 - params.SellFee)
+				tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "sell", Price: price, Shares: position, Capital: cash, Reason: "止损"})
 				losses++
 				lossSum += -profit
 				position = 0
 				entryPrice = 0
-			}
-			if price >= entryPrice*(1+params.TakeProfit) {
+			} else if price >= entryPrice*(1+params.TakeProfit) {
 				profit := (price - entryPrice) * position
-				cash = position * price
+				cash = position * price * (1 - params.SellFee)
+				tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "sell", Price: price, Shares: position, Capital: cash, Reason: "止盈"})
 				wins++
 				profitSum += profit
 				position = 0
@@ -151,7 +221,8 @@ func backtestMACross(stockData []StockData, params BacktestParams) BacktestResul
 		equityCurve = append(equityCurve, equity)
 	}
 	if position > 0 {
-		cash += position * closes[len(closes)-1]
+		cash += position * closes[len(closes)-1] * (1 - params.SellFee)
+		tradeHistory = append(tradeHistory, Trade{Date: stockData[len(stockData)-1].Date, Type: "sell", Price: closes[len(closes)-1], Shares: position, Capital: cash, Reason: "期末平仓"})
 		profit := (closes[len(closes)-1] - entryPrice) * position
 		if profit > 0 {
 			wins++
@@ -192,6 +263,7 @@ func backtestMACross(stockData []StockData, params BacktestParams) BacktestResul
 		Trades:       trades,
 		ProfitFactor: profitFactor,
 		EquityCurve:  equityCurve,
+		TradeHistory: tradeHistory,
 	}
 }
 
@@ -210,6 +282,7 @@ func backtestBreakout(stockData []StockData, params BacktestParams) BacktestResu
 	lossSum := 0.0
 	maxEquity := cash
 	equityCurve := []float64{cash}
+	var tradeHistory []Trade
 
 	var closes []float64
 	for _, d := range stockData {
@@ -226,10 +299,11 @@ func backtestBreakout(stockData []StockData, params BacktestParams) BacktestResu
 		}
 		// 突破买入
 		if price > maxHigh && position == 0 {
-			position = cash / price
+			position = cash * (1 - params.BuyFee) / price
 			entryPrice = price
 			cash = 0
 			trades++
+			tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "buy", Price: price, Shares: position, Capital: position * price, Reason: "突破买入"})
 		}
 		// 跌破最低卖出
 		minLow := closes[i-params.BreakoutPeriod]
@@ -240,7 +314,8 @@ func backtestBreakout(stockData []StockData, params BacktestParams) BacktestResu
 		}
 		if price < minLow && position > 0 {
 			profit := (price - entryPrice) * position
-			cash = position * price
+			cash = position * price * (1 - params.SellFee)
+			tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "sell", Price: price, Shares: position, Capital: cash, Reason: "跌破区间低点卖出"})
 			if profit > 0 {
 				wins++
 				profitSum += profit
@@ -255,15 +330,16 @@ func backtestBreakout(stockData []StockData, params BacktestParams) BacktestResu
 		if position > 0 {
 			if price <= entryPrice*(1-params.StopLoss) {
 				profit := (price - entryPrice) * position
-				cash = position * price
+				cash = position * price * (1 - params.SellFee)
+				tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "sell", Price: price, Shares: position, Capital: cash, Reason: "止损"})
 				losses++
 				lossSum += -profit
 				position = 0
 				entryPrice = 0
-			}
-			if price >= entryPrice*(1+params.TakeProfit) {
+			} else if price >= entryPrice*(1+params.TakeProfit) {
 				profit := (price - entryPrice) * position
-				cash = position * price
+				cash = position * price * (1 - params.SellFee)
+				tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "sell", Price: price, Shares: position, Capital: cash, Reason: "止盈"})
 				wins++
 				profitSum += profit
 				position = 0
@@ -284,7 +360,8 @@ func backtestBreakout(stockData []StockData, params BacktestParams) BacktestResu
 		equityCurve = append(equityCurve, equity)
 	}
 	if position > 0 {
-		cash += position * closes[len(closes)-1]
+		cash += position * closes[len(closes)-1] * (1 - params.SellFee)
+		tradeHistory = append(tradeHistory, Trade{Date: stockData[len(stockData)-1].Date, Type: "sell", Price: closes[len(closes)-1], Shares: position, Capital: cash, Reason: "期末平仓"})
 		profit := (closes[len(closes)-1] - entryPrice) * position
 		if profit > 0 {
 			wins++
@@ -325,6 +402,7 @@ func backtestBreakout(stockData []StockData, params BacktestParams) BacktestResu
 		Trades:       trades,
 		ProfitFactor: profitFactor,
 		EquityCurve:  equityCurve,
+		TradeHistory: tradeHistory,
 	}
 }
 
@@ -343,6 +421,7 @@ func backtestRSI(stockData []StockData, params BacktestParams) BacktestResult {
 	lossSum := 0.0
 	maxEquity := cash
 	equityCurve := []float64{cash}
+	var tradeHistory []Trade
 
 	var closes []float64
 	for _, d := range stockData {
@@ -354,15 +433,17 @@ func backtestRSI(stockData []StockData, params BacktestParams) BacktestResult {
 		rsiVal := rsi(closes, params.RSIPeriod, i)
 		// 超卖买入
 		if rsiVal < params.RSIOversold && position == 0 {
-			position = cash / price
+			position = cash * (1 - params.BuyFee) / price
 			entryPrice = price
 			cash = 0
 			trades++
+			tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "buy", Price: price, Shares: position, Capital: position * price, Reason: "RSI超卖买入"})
 		}
 		// 超买卖出
 		if rsiVal > params.RSIOverbought && position > 0 {
 			profit := (price - entryPrice) * position
-			cash = position * price
+			cash = position * price * (1 - params.SellFee)
+			tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "sell", Price: price, Shares: position, Capital: cash, Reason: "RSI超买卖出"})
 			if profit > 0 {
 				wins++
 				profitSum += profit
@@ -377,15 +458,16 @@ func backtestRSI(stockData []StockData, params BacktestParams) BacktestResult {
 		if position > 0 {
 			if price <= entryPrice*(1-params.StopLoss) {
 				profit := (price - entryPrice) * position
-				cash = position * price
+				cash = position * price * (1 - params.SellFee)
+				tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "sell", Price: price, Shares: position, Capital: cash, Reason: "止损"})
 				losses++
 				lossSum += -profit
 				position = 0
 				entryPrice = 0
-			}
-			if price >= entryPrice*(1+params.TakeProfit) {
+			} else if price >= entryPrice*(1+params.TakeProfit) {
 				profit := (price - entryPrice) * position
-				cash = position * price
+				cash = position * price * (1 - params.SellFee)
+				tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "sell", Price: price, Shares: position, Capital: cash, Reason: "止盈"})
 				wins++
 				profitSum += profit
 				position = 0
@@ -406,7 +488,186 @@ func backtestRSI(stockData []StockData, params BacktestParams) BacktestResult {
 		equityCurve = append(equityCurve, equity)
 	}
 	if position > 0 {
-		cash += position * closes[len(closes)-1]
+		cash += position * closes[len(closes)-1] * (1 - params.SellFee)
+		tradeHistory = append(tradeHistory, Trade{Date: stockData[len(stockData)-1].Date, Type: "sell", Price: closes[len(closes)-1], Shares: position, Capital: cash, Reason: "期末平仓"})
+		profit := (closes[len(closes)-1] - entryPrice) * position
+		if profit > 0 {
+			wins++
+			profitSum += profit
+		} else {
+			losses++
+			lossSum += -profit
+		}
+		position = 0
+	}
+	finalEquity := cash
+	if finalEquity < 0.01 {
+		finalEquity = 0.01
+	}
+	maxDrawdown := 0.0
+	peak := equityCurve[0]
+	for _, eq := range equityCurve {
+		if eq > peak {
+			peak = eq
+		}
+		drawdown := (peak - eq) / peak
+		if drawdown > maxDrawdown {
+			maxDrawdown = drawdown
+		}
+	}
+	winRate := 0.0
+	if trades > 0 {
+		winRate = float64(wins) / float64(trades)
+	}
+	profitFactor := 0.0
+	if lossSum > 0 {
+		profitFactor = profitSum / lossSum
+	}
+	return BacktestResult{
+		TotalReturn:  (finalEquity - params.InitialCash) / params.InitialCash,
+		WinRate:      winRate,
+		MaxDrawdown:  maxDrawdown,
+		Trades:       trades,
+		ProfitFactor: profitFactor,
+		EquityCurve:  equityCurve,
+		TradeHistory: tradeHistory,
+	}
+}
+
+// atrSeries 平均真实波幅（Wilder 平滑），前 period 根K线数据不足时为 0
+func atrSeries(stockData []StockData, period int) []float64 {
+	atr := make([]float64, len(stockData))
+	if period < 1 || len(stockData) <= period {
+		return atr
+	}
+	tr := make([]float64, len(stockData))
+	for i := 1; i < len(stockData); i++ {
+		high, low, prevClose := stockData[i].High, stockData[i].Low, stockData[i-1].Close
+		tr[i] = math.Max(high-low, math.Max(math.Abs(high-prevClose), math.Abs(low-prevClose)))
+	}
+	sum := 0.0
+	for i := 1; i <= period; i++ {
+		sum += tr[i]
+	}
+	atr[period] = sum / float64(period)
+	for i := period + 1; i < len(stockData); i++ {
+		atr[i] = (atr[i-1]*float64(period-1) + tr[i]) / float64(period)
+	}
+	return atr
+}
+
+// atrTrailingSetup 补齐 ATR 策略的默认参数，返回是否突破入场及首个可交易K线下标
+func atrTrailingSetup(params BacktestParams) (BacktestParams, bool, int) {
+	if params.ATRPeriod <= 0 {
+		params.ATRPeriod = 14
+	}
+	if params.ATRMultiplier <= 0 {
+		params.ATRMultiplier = 3
+	}
+	useBreakout := params.BreakoutPeriod >= 2
+	if !useBreakout && (params.FastMAPeriod <= 0 || params.SlowMAPeriod <= params.FastMAPeriod) {
+		params.FastMAPeriod, params.SlowMAPeriod = 5, 20
+	}
+	start := params.ATRPeriod
+	if useBreakout && params.BreakoutPeriod > start {
+		start = params.BreakoutPeriod
+	} else if !useBreakout && params.SlowMAPeriod > start {
+		start = params.SlowMAPeriod
+	}
+	return params, useBreakout, start
+}
+
+// backtestLookback 策略首个可交易K线下标，即指标预热所需的K线数
+func backtestLookback(params BacktestParams) int {
+	switch params.StrategyType {
+	case "breakout":
+		return params.BreakoutPeriod
+	case "rsi":
+		return params.RSIPeriod
+	case "atr_trailing":
+		_, _, start := atrTrailingSetup(params)
+		return start
+	default:
+		return params.SlowMAPeriod
+	}
+}
+
+// ATR追踪止损策略：BreakoutPeriod>=2 时用突破入场，否则用均线金叉入场；
+// 止损线初始为 entry - k*ATR，之后按 close - k*ATR 只上移不下移，收盘跌破止损线离场
+func backtestATRTrailing(stockData []StockData, params BacktestParams) BacktestResult {
+	params, useBreakout, start := atrTrailingSetup(params)
+	if len(stockData) <= start {
+		return BacktestResult{}
+	}
+	cash := params.InitialCash
+	position := 0.0
+	entryPrice := 0.0
+	stopLine := 0.0
+	trades := 0
+	wins := 0
+	losses := 0
+	profitSum := 0.0
+	lossSum := 0.0
+	equityCurve := []float64{cash}
+	var tradeHistory []Trade
+
+	var closes []float64
+	for _, d := range stockData {
+		closes = append(closes, d.Close)
+	}
+	atr := atrSeries(stockData, params.ATRPeriod)
+
+	for i := start; i < len(stockData); i++ {
+		price := closes[i]
+		if position == 0 {
+			entry, reason := false, ""
+			if useBreakout {
+				maxHigh := closes[i-params.BreakoutPeriod]
+				for j := i - params.BreakoutPeriod + 1; j < i; j++ {
+					if closes[j] > maxHigh {
+						maxHigh = closes[j]
+					}
+				}
+				entry, reason = price > maxHigh, "突破买入"
+			} else {
+				entry = ma(closes, params.FastMAPeriod, i) > ma(closes, params.SlowMAPeriod, i) &&
+					ma(closes, params.FastMAPeriod, i-1) <= ma(closes, params.SlowMAPeriod, i-1)
+				reason = "金叉买入"
+			}
+			if entry {
+				position = cash * (1 - params.BuyFee) / price
+				entryPrice = price
+				stopLine = price - params.ATRMultiplier*atr[i]
+				cash = 0
+				trades++
+				tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "buy", Price: price, Shares: position, Capital: position * price, Reason: reason})
+			}
+		} else if price <= stopLine {
+			profit := (price - entryPrice) * position
+			cash = position * price * (1 - params.SellFee)
+			tradeHistory = append(tradeHistory, Trade{Date: stockData[i].Date, Type: "sell", Price: price, Shares: position, Capital: cash, Reason: "ATR追踪止损"})
+			if profit > 0 {
+				wins++
+				profitSum += profit
+			} else {
+				losses++
+				lossSum += -profit
+			}
+			position = 0
+			entryPrice = 0
+			stopLine = 0
+		} else if trail := price - params.ATRMultiplier*atr[i]; trail > stopLine {
+			stopLine = trail // 止损线只上移
+		}
+		equity := cash
+		if position > 0 {
+			equity += position * price
+		}
+		equityCurve = append(equityCurve, equity)
+	}
+	if position > 0 {
+		cash += position * closes[len(closes)-1] * (1 - params.SellFee)
+		tradeHistory = append(tradeHistory, Trade{Date: stockData[len(stockData)-1].Date, Type: "sell", Price: closes[len(closes)-1], Shares: position, Capital: cash, Reason: "期末平仓"})
 		profit := (closes[len(closes)-1] - entryPrice) * position
 		if profit > 0 {
 			wins++
@@ -447,5 +708,6 @@ func backtestRSI(stockData []StockData, params BacktestParams) BacktestResult {
 		Trades:       trades,
 		ProfitFactor: profitFactor,
 		EquityCurve:  equityCurve,
+		TradeHistory: tradeHistory,
 	}
 }
@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+)
+
+// trendPullbackBars 20 根横盘、30 根每日上涨 2%、15 根每日下跌 4% 的K线
+func trendPullbackBars() []StockData {
+	var data []StockData
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	price := 10.0
+	for i := 0; i < 65; i++ {
+		switch {
+		case i >= 50:
+			price *= 0.96
+		case i >= 20:
+			price *= 1.02
+		}
+		data = append(data, StockData{Date: day.AddDate(0, 0, i), Open: price, Close: price, High: price * 1.01, Low: price * 0.99, Volume: 1000})
+	}
+	return data
+}
+
+func TestBacktestATRTrailingStopTriggered(t *testing.T) {
+	data := trendPullbackBars()
+	params := BacktestParams{StrategyType: "atr_trailing", BreakoutPeriod: 10, ATRPeriod: 5, ATRMultiplier: 2, InitialCash: 100000}
+	r := BacktestStrategy(data, params)
+	if r.Trades != 1 || len(r.TradeHistory) != 2 {
+		t.Fatalf("应恰好一买一卖，交易记录: %+v", r.TradeHistory)
+	}
+	buy, sell := r.TradeHistory[0], r.TradeHistory[1]
+	if buy.Reason != "突破买入" || !buy.Date.Equal(data[20].Date) {
+		t.Errorf("应在趋势首日突破买入: %+v", buy)
+	}
+	if sell.Reason != "ATR追踪止损" || sell.Date.Before(data[50].Date) {
+		t.Errorf("应在回撤阶段触发 ATR 追踪止损: %+v", sell)
+	}
+	// 初始止损线低于入场价，止损卖出价高于入场价说明止损线随趋势上移了
+	if sell.Price <= buy.Price || r.TotalReturn <= 0 || r.WinRate != 1 {
+		t.Errorf("追踪止损应锁定趋势利润: 买入 %.2f 卖出 %.2f 收益 %.4f", buy.Price, sell.Price, r.TotalReturn)
+	}
+	if peak := data[49].Close; sell.Price < peak*0.9 {
+		t.Errorf("止损卖出价 %.2f 距高点 %.2f 回撤过大", sell.Price, peak)
+	}
+	if len(r.EquityCurve) != len(data)-10+1 {
+		t.Errorf("资金曲线长度 = %d", len(r.EquityCurve))
+	}
+
+	params.ATRMultiplier = 100
+	if r := BacktestStrategy(data, params); len(r.TradeHistory) != 2 || r.TradeHistory[1].Reason != "期末平仓" {
+		t.Errorf("止损倍数过大时不应触发止损: %+v", r.TradeHistory)
+	}
+}
+
+func TestBacktestATRTrailingDefaults(t *testing.T) {
+	params := BacktestParams{StrategyType: "atr_trailing"}
+	if got := backtestLookback(params); got != 20 {
+		t.Errorf("默认均线入场预热 = %d，期望慢线周期 20", got)
+	}
+	params.BreakoutPeriod = 30
+	if got := backtestLookback(params); got != 30 {
+		t.Errorf("突破入场预热 = %d，期望 30", got)
+	}
+	if r := BacktestStrategy(trendPullbackBars()[:14], BacktestParams{StrategyType: "atr_trailing", InitialCash: 1000}); r.Trades != 0 || len(r.EquityCurve) != 0 {
+		t.Errorf("K线不足 ATR 周期时不应交易: %+v", r)
+	}
+}
@@ -0,0 +1,81 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// BatchConcurrency 批量分析默认并发数
+var BatchConcurrency = 4
+
+var (
+	historyNameMu  sync.Mutex
+	historyReserve = make(map[string]bool)
+)
+
+// fileNameCode 股票代码用于文件名时只保留字母、数字、点、横线与下划线，其余（含路径分隔符）替换为 _，
+// 并去掉开头的点，避免拼出 ../ 等越出目录的路径
+func fileNameCode(code string) string {
+	safe := strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '.', r == '-', r == '_':
+			return r
+		}
+		return '_'
+	}, code)
+	if safe = strings.TrimLeft(safe, "."); safe == "" {
+		return "_"
+	}
+	return safe
+}
+
+// reserveHistoryBase 为报告文件名（不含扩展名）加锁去重：同一秒内同名（如同一股票多种模式并发）时追加 -2、-3…，
+// 已被本进程占用或 history 下已存在同名文件的都会跳过
+func reserveHistoryBase(base string) string {
+	historyNameMu.Lock()
+	defer historyNameMu.Unlock()
+	name := base
+	for i := 2; ; i++ {
+		if !historyReserve[name] {
+			matches, _ := filepath.Glob(filepath.Join(HistoryDir, name+".*"))
+			if len(matches) == 0 {
+				historyReserve[name] = true
+				return name
+			}
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// AnalyzeBatch 用固定数量的 worker 并发执行 AnalyzeOne，concurrency<=0 时使用 BatchConcurrency，返回结果与输入顺序一致；
+// ctx 取消后尚未开始的股票直接以 ctx.Err() 结束
+func AnalyzeBatch(ctx context.Context, paramsList []AnalysisParams, genFunc func(string, string, string, string, string, bool, bool) (string, error), concurrency int) []AnalysisResult {
+	if concurrency <= 0 {
+		concurrency = BatchConcurrency
+	}
+	if concurrency > len(paramsList) {
+		concurrency = len(paramsList)
+	}
+	results := make([]AnalysisResult, len(paramsList))
+	jobs := make(chan int, len(paramsList))
+	for i := range paramsList {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = AnalyzeOne(ctx, paramsList[i], genFunc)
+			}
+		}()
+	}
+	wg.Wait()
+	return results
+}
@@ -0,0 +1,183 @@
+package analysis
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TradingCalendarFile 自定义休市日，格式 {"A股": ["2027-01-01"], "港股": [...], "美股": [...]}，与内置休市日合并
+var TradingCalendarFile = "holidays.json"
+
+// FillMissingTradingDays 检测到缺失交易日时是否用相邻K线线性插补
+var FillMissingTradingDays = false
+
+// 内置 A股 法定节假日休市的工作日，仅覆盖表中出现的年份；港股/美股无内置数据，需在 TradingCalendarFile 中提供后才做缺失检测
+var builtinHolidays = map[string][]string{
+	"A股": {
+		"2024-01-01", "2024-02-09", "2024-02-12", "2024-02-13", "2024-02-14", "2024-02-15", "2024-02-16",
+		"2024-04-04", "2024-04-05", "2024-05-01", "2024-05-02", "2024-05-03", "2024-06-10", "2024-09-16", "2024-09-17",
+		"2024-10-01", "2024-10-02", "2024-10-03", "2024-10-04", "2024-10-07",
+		"2025-01-01", "2025-01-28", "2025-01-29", "2025-01-30", "2025-01-31", "2025-02-03", "2025-02-04",
+		"2025-04-04", "2025-05-01", "2025-05-02", "2025-05-05", "2025-06-02",
+		"2025-10-01", "2025-10-02", "2025-10-03", "2025-10-06", "2025-10-07", "2025-10-08",
+		"2026-01-01", "2026-01-02", "2026-02-16", "2026-02-17", "2026-02-18", "2026-02-19", "2026-02-20", "2026-02-23",
+		"2026-04-06", "2026-05-01", "2026-05-04", "2026-05-05", "2026-06-19", "2026-09-25",
+		"2026-10-01", "2026-10-02", "2026-10-05", "2026-10-06", "2026-10-07",
+	},
+}
+
+// TradingCalendar 某一市场的交易日历：周末与休市日之外均为交易日。
+// 只有休市日数据中出现过的年份视为已覆盖，其他年份无法区分节假日与缺失，不做缺失检测
+type TradingCalendar struct {
+	Market   string
+	holidays map[string]bool
+	years    map[int]bool
+}
+
+var (
+	calendarOnce sync.Once
+	calendarData map[string][]string
+)
+
+// loadHolidays 合并内置与自定义休市日，只读取一次
+func loadHolidays() map[string][]string {
+	calendarOnce.Do(func() {
+		calendarData = make(map[string][]string)
+		for m, days := range builtinHolidays {
+			calendarData[m] = append(calendarData[m], days...)
+		}
+		data, err := ioutil.ReadFile(TradingCalendarFile)
+		if err != nil {
+			return
+		}
+		var custom map[string][]string
+		if err := json.Unmarshal(data, &custom); err != nil {
+			log.Printf("[交易日历] 解析 %s 失败，仅使用内置休市日: %v\n", TradingCalendarFile, err)
+			return
+		}
+		for m, days := range custom {
+			calendarData[m] = append(calendarData[m], days...)
+		}
+	})
+	return calendarData
+}
+
+// CalendarFor 市场对应的交易日历，ok=false 表示该市场没有休市日数据，无法可靠判断缺失；
+// 有数据时也只覆盖其中出现的年份，见 Covers
+func CalendarFor(market string) (TradingCalendar, bool) {
+	days := loadHolidays()[market]
+	cal := TradingCalendar{Market: market, holidays: make(map[string]bool, len(days)), years: make(map[int]bool)}
+	for _, d := range days {
+		d = strings.TrimSpace(d)
+		t, err := time.Parse("2006-01-02", d)
+		if err != nil {
+			continue
+		}
+		cal.holidays[d] = true
+		cal.years[t.Year()] = true
+	}
+	return cal, len(cal.years) > 0
+}
+
+// Covers 该年份是否有休市日数据
+func (c TradingCalendar) Covers(year int) bool {
+	return c.years[year]
+}
+
+// IsTradingDay 是否为交易日
+func (c TradingCalendar) IsTradingDay(t time.Time) bool {
+	if wd := t.Weekday(); wd == time.Saturday || wd == time.Sunday {
+		return false
+	}
+	return !c.holidays[t.Format("2006-01-02")]
+}
+
+// MissingTradingDays 行情序列（按日期升序）首尾之间按日历应有、实际缺失的交易日，未覆盖年份的日期不计
+func (c TradingCalendar) MissingTradingDays(data []StockData) []time.Time {
+	if len(data) < 2 {
+		return nil
+	}
+	present := make(map[string]bool, len(data))
+	for _, d := range data {
+		present[d.Date.Format("2006-01-02")] = true
+	}
+	first, last := data[0].Date, data[len(data)-1].Date
+	var missing []time.Time
+	for t := time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, first.Location()); !t.After(last); t = t.AddDate(0, 0, 1) {
+		if c.Covers(t.Year()) && c.IsTradingDay(t) && !present[t.Format("2006-01-02")] {
+			missing = append(missing, t)
+		}
+	}
+	return missing
+}
+
+// fillMissingDays 在缺失交易日处按前后K线线性插补价格，成交量取前后均值
+func fillMissingDays(data []StockData, missing []time.Time) []StockData {
+	if len(missing) == 0 {
+		return data
+	}
+	filled := make([]StockData, 0, len(data)+len(missing))
+	mi := 0
+	for i, d := range data {
+		if i > 0 {
+			prev := data[i-1]
+			// 收集 prev 与 d 之间的缺失日
+			var gap []time.Time
+			for mi < len(missing) && missing[mi].Before(d.Date) {
+				if missing[mi].After(prev.Date) {
+					gap = append(gap, missing[mi])
+				}
+				mi++
+			}
+			for k, t := range gap {
+				w := float64(k+1) / float64(len(gap)+1)
+				lerp := func(a, b float64) float64 { return a + (b-a)*w }
+				filled = append(filled, StockData{
+					Date:   time.Date(t.Year(), t.Month(), t.Day(), prev.Date.Hour(), prev.Date.Minute(), 0, 0, prev.Date.Location()),
+					Open:   lerp(prev.Open, d.Open),
+					Close:  lerp(prev.Close, d.Close),
+					Low:    lerp(prev.Low, d.Low),
+					High:   lerp(prev.High, d.High),
+					Volume: (prev.Volume + d.Volume) / 2,
+				})
+			}
+		}
+		filled = append(filled, d)
+	}
+	return filled
+}
+
+// checkMissingTradingDays 对比交易日历警告缺失交易日，开启 FillMissingTradingDays 时插补
+func checkMissingTradingDays(data []StockData, stockCode string) []StockData {
+	cal, ok := CalendarFor(StockMarket(stockCode))
+	if !ok || len(data) == 0 {
+		return data
+	}
+	for y := data[0].Date.Year(); y <= data[len(data)-1].Date.Year(); y++ {
+		if !cal.Covers(y) {
+			log.Printf("[交易日历] %s 无 %d 年休市日数据，该年不做缺失交易日检测（可在 %s 中补充）\n", cal.Market, y, TradingCalendarFile)
+		}
+	}
+	missing := cal.MissingTradingDays(data)
+	if len(missing) == 0 {
+		return data
+	}
+	var days []string
+	for i, t := range missing {
+		if i == 5 {
+			days = append(days, "…")
+			break
+		}
+		days = append(days, t.Format("2006-01-02"))
+	}
+	log.Printf("[数据校验] 警告：%s 行情缺失 %d 个交易日（%s），指标周期实际偏短\n", stockCode, len(missing), strings.Join(days, "、"))
+	if !FillMissingTradingDays {
+		return data
+	}
+	log.Printf("[数据校验] %s 已用相邻K线插补 %d 个缺失交易日\n", stockCode, len(missing))
+	return fillMissingDays(data, missing)
+}
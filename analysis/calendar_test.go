@@ -0,0 +1,54 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+)
+
+// weekdayBars from 起 n 个自然日内的全部工作日K线，跳过 skip 中的日期
+func weekdayBars(from time.Time, n int, skip map[string]bool) []StockData {
+	var data []StockData
+	for i := 0; i < n; i++ {
+		t := from.AddDate(0, 0, i)
+		if wd := t.Weekday(); wd == time.Saturday || wd == time.Sunday || skip[t.Format("2006-01-02")] {
+			continue
+		}
+		c := 10 + float64(i)
+		data = append(data, StockData{Date: t, Open: c, Close: c, High: c + 1, Low: c - 1, Volume: 100})
+	}
+	return data
+}
+
+func TestMissingTradingDaysDetectsGaps(t *testing.T) {
+	cal, ok := CalendarFor("A股")
+	if !ok {
+		t.Fatal("A股应有内置休市日")
+	}
+	// 2025-10 国庆休市 1-3、6-8 日，另删去 10-14、10-15 两个交易日
+	skip := map[string]bool{"2025-10-01": true, "2025-10-02": true, "2025-10-03": true, "2025-10-06": true,
+		"2025-10-07": true, "2025-10-08": true, "2025-10-14": true, "2025-10-15": true}
+	data := weekdayBars(time.Date(2025, 9, 29, 0, 0, 0, 0, time.Local), 21, skip)
+	missing := cal.MissingTradingDays(data)
+	if len(missing) != 2 || missing[0].Format("2006-01-02") != "2025-10-14" || missing[1].Format("2006-01-02") != "2025-10-15" {
+		t.Fatalf("应只检测到 10-14、10-15 缺失，得到 %v", missing)
+	}
+	filled := fillMissingDays(data, missing)
+	if len(filled) != len(data)+2 {
+		t.Fatalf("插补后应多 2 根K线，得到 %d", len(filled)-len(data))
+	}
+}
+
+func TestMissingTradingDaysSkipsUncoveredYears(t *testing.T) {
+	cal, _ := CalendarFor("A股")
+	if cal.Covers(2027) || !cal.Covers(2026) {
+		t.Fatalf("内置数据应覆盖 2026、不覆盖 2027")
+	}
+	// 2027 年元旦等节假日没有数据，不应被当作缺失交易日
+	data := weekdayBars(time.Date(2026, 12, 28, 0, 0, 0, 0, time.Local), 14, map[string]bool{"2027-01-01": true, "2027-01-04": true})
+	if missing := cal.MissingTradingDays(data); len(missing) != 0 {
+		t.Fatalf("未覆盖年份不应检测缺失，得到 %v", missing)
+	}
+	if _, ok := CalendarFor("美股"); ok {
+		t.Error("美股无内置休市日，应返回 ok=false")
+	}
+}
@@ -0,0 +1,107 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// sourceEastMoney 东方财富资金流向接口的数据源标识，受 AllowedDataSources 白名单约束
+const sourceEastMoney = "eastmoney"
+
+// EastMoneyFlowURL 东方财富 push2 实时资金流向接口，%s 为 secid（1.600519 / 0.000001）；
+// f62 主力净流入，f66 超大单净额，f72 大单净额，f184 主力净占比（%）
+var EastMoneyFlowURL = "https://push2.eastmoney.com/api/qt/ulist.np/get?fltt=2&secids=%s&fields=f12,f14,f62,f66,f72,f184"
+
+// CapitalFlow 当日主力资金流向（元），来自东方财富，仅支持 A 股
+type CapitalFlow struct {
+	MainNetInflow  float64 `json:"main_net_inflow"`  // 主力净流入（超大单+大单）
+	SuperLargeNet  float64 `json:"super_large_net"`  // 超大单净额
+	LargeNet       float64 `json:"large_net"`        // 大单净额
+	MainNetPercent float64 `json:"main_net_percent"` // 主力净流入占成交额比例（%）
+}
+
+// eastMoneyNumber 解析接口数值字段，停牌或无数据时接口返回 "-"，按 0 处理
+type eastMoneyNumber float64
+
+func (n *eastMoneyNumber) UnmarshalJSON(b []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch x := v.(type) {
+	case float64:
+		*n = eastMoneyNumber(x)
+	case string:
+		f, err := strconv.ParseFloat(x, 64)
+		if err != nil {
+			*n = 0
+			return nil
+		}
+		*n = eastMoneyNumber(f)
+	default:
+		*n = 0
+	}
+	return nil
+}
+
+// parseEastMoneyFlow 解析 ulist.np/get 响应中第一只股票的资金流向
+func parseEastMoneyFlow(body []byte) (CapitalFlow, error) {
+	var resp struct {
+		RC   int `json:"rc"`
+		Data *struct {
+			Diff []struct {
+				MainNetInflow eastMoneyNumber `json:"f62"`
+				SuperLargeNet eastMoneyNumber `json:"f66"`
+				LargeNet      eastMoneyNumber `json:"f72"`
+				MainNetPct    eastMoneyNumber `json:"f184"`
+			} `json:"diff"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return CapitalFlow{}, fmt.Errorf("解析资金流向失败: %v", err)
+	}
+	if resp.RC != 0 {
+		return CapitalFlow{}, fmt.Errorf("东方财富接口返回错误码 %d", resp.RC)
+	}
+	if resp.Data == nil || len(resp.Data.Diff) == 0 {
+		return CapitalFlow{}, fmt.Errorf("东方财富接口未返回资金流向数据")
+	}
+	d := resp.Data.Diff[0]
+	return CapitalFlow{
+		MainNetInflow:  float64(d.MainNetInflow),
+		SuperLargeNet:  float64(d.SuperLargeNet),
+		LargeNet:       float64(d.LargeNet),
+		MainNetPercent: float64(d.MainNetPct),
+	}, nil
+}
+
+// eastMoneySecID 东方财富 secid：沪市 1.xxxxxx，深市 0.xxxxxx，与网易格式一致；非 A 股返回空
+func eastMoneySecID(stockCode string) string {
+	if StockMarket(stockCode) != "A股" {
+		return ""
+	}
+	secid := normalizeSymbol(stockCode, sourceNetEase)
+	if secid == stockCode {
+		return ""
+	}
+	return secid
+}
+
+// FetchCapitalFlow 获取 A 股当日主力净流入与大单净额
+func FetchCapitalFlow(ctx context.Context, stockCode string) (CapitalFlow, error) {
+	stockCode = NormalizeStockCode(stockCode)
+	if !DataSourceAllowed(sourceEastMoney) {
+		return CapitalFlow{}, fmt.Errorf("合规限制：%s 不在允许的数据源列表中", sourceEastMoney)
+	}
+	secid := eastMoneySecID(stockCode)
+	if secid == "" {
+		return CapitalFlow{}, fmt.Errorf("资金流向仅支持沪深 A 股: %s", stockCode)
+	}
+	body, err := fetchWithRetry(ctx, fmt.Sprintf(EastMoneyFlowURL, secid), defaultFetchHeaders, FetchMaxRetries)
+	if err != nil {
+		return CapitalFlow{}, err
+	}
+	return parseEastMoneyFlow(body)
+}
@@ -0,0 +1,75 @@
+package analysis
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 东方财富 ulist.np/get 响应样例（fltt=2 时数值为浮点数，停牌时为 "-"）
+const eastMoneyFlowJSON = `{"rc":0,"rt":11,"svr":181669437,"lt":1,"full":1,"dlmkts":"","data":{"total":1,"diff":[{"f12":"600036","f14":"招商银行","f62":123456789.0,"f66":"98765432.5","f72":24691356.5,"f184":"-"}]}}`
+
+func TestParseEastMoneyFlow(t *testing.T) {
+	flow, err := parseEastMoneyFlow([]byte(eastMoneyFlowJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CapitalFlow{MainNetInflow: 123456789, SuperLargeNet: 98765432.5, LargeNet: 24691356.5}
+	if flow != want {
+		t.Errorf("资金流向 = %+v，期望 %+v（\"-\" 按 0 处理）", flow, want)
+	}
+	for _, c := range []struct{ body, want string }{
+		{`{"rc":102,"data":null}`, "错误码 102"},
+		{`{"rc":0,"data":{"total":0,"diff":[]}}`, "未返回资金流向"},
+		{`{"rc":0,"data":null}`, "未返回资金流向"},
+		{`<html>`, "解析资金流向失败"},
+	} {
+		if _, err := parseEastMoneyFlow([]byte(c.body)); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: 应返回含 %q 的错误，得到 %v", c.body, c.want, err)
+		}
+	}
+}
+
+func TestFetchCapitalFlow(t *testing.T) {
+	var secids []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secids = append(secids, r.URL.Query().Get("secids"))
+		w.Write([]byte(eastMoneyFlowJSON))
+	}))
+	defer srv.Close()
+	oldURL, oldRetries := EastMoneyFlowURL, FetchMaxRetries
+	EastMoneyFlowURL, FetchMaxRetries = srv.URL+"/api/qt/ulist.np/get?secids=%s", 0
+	defer func() { EastMoneyFlowURL, FetchMaxRetries = oldURL, oldRetries }()
+
+	for _, code := range []string{"600036", "000001.SZ"} {
+		if flow, err := FetchCapitalFlow(context.Background(), code); err != nil || flow.MainNetInflow != 123456789 {
+			t.Errorf("%s: %+v %v", code, flow, err)
+		}
+	}
+	if len(secids) != 2 || secids[0] != "1.600036" || secids[1] != "0.000001" {
+		t.Errorf("secid 应按沪深市场拼接: %v", secids)
+	}
+	if _, err := FetchCapitalFlow(context.Background(), "AAPL"); err == nil || !strings.Contains(err.Error(), "仅支持沪深 A 股") || len(secids) != 2 {
+		t.Errorf("非 A 股不应请求接口: %v", err)
+	}
+}
+
+func TestScoreStocksByFlowFactor(t *testing.T) {
+	list := []StockComparison{
+		{StockCode: "AAPL", Risk: &RiskMetrics{}},
+		{StockCode: "000001", Risk: &RiskMetrics{}, CapitalFlow: &CapitalFlow{MainNetPercent: -3}},
+		{StockCode: "600036", Risk: &RiskMetrics{}, CapitalFlow: &CapitalFlow{MainNetPercent: 5}},
+	}
+	ScoreStocksByFactors(list, []FactorWeight{{"main_net_percent", 1}})
+	if list[0].StockCode != "600036" || list[0].FactorScores["main_net_percent"] != 100 || list[1].FactorScores["main_net_percent"] != 0 {
+		t.Errorf("主力净占比越高得分越高: %+v", list)
+	}
+	if _, ok := list[2].FactorScores["main_net_percent"]; ok || list[2].StockCode != "AAPL" {
+		t.Errorf("无资金流向的股票不应参与该因子打分: %+v", list[2])
+	}
+	if !needsCapitalFlow([]FactorWeight{{"sharpe", 1}, {"large_net", 1}}) || needsCapitalFlow(DefaultFactors) {
+		t.Error("只有含资金面因子时才需要请求资金流向")
+	}
+}
@@ -2,9 +2,14 @@ package analysis
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/chromedp/chromedp"
@@ -12,11 +17,111 @@ import (
 	"github.com/go-echarts/go-echarts/v2/opts"
 )
 
-// GenerateCharts 自动生成K线、均线、成交量图，返回PNG图片路径列表
-func GenerateCharts(stockCode string, stockData []StockData, indicators []TechnicalIndicator, outDir string) ([]string, error) {
+// chartMu 串行化图表生成：GenerateCharts 会先清空输出目录的 .html，批量并发时需避免删掉其他任务的中间文件
+var chartMu sync.Mutex
+
+// K线与成交量配色：红涨绿跌
+const (
+	chartUpColor   = "#d14a61"
+	chartDownColor = "#2f9e44"
+)
+
+// klineOverlays 叠加在K线图上的指标线
+var klineOverlays = []struct {
+	name  string
+	value func(TechnicalIndicator) float64
+}{
+	{"MA5", func(t TechnicalIndicator) float64 { return t.MA5 }},
+	{"MA20", func(t TechnicalIndicator) float64 { return t.MA20 }},
+	{"BOLL上轨", func(t TechnicalIndicator) float64 { return t.BOLLUpper }},
+	{"BOLL下轨", func(t TechnicalIndicator) float64 { return t.BOLLLower }},
+}
+
+// overlayLineData 按K线下标取指标值，指标缺失或未完成预热（为 0）时输出 "-" 断开折线，避免拉低纵轴
+func overlayLineData(indicators []TechnicalIndicator, n int, value func(TechnicalIndicator) float64) []opts.LineData {
+	data := make([]opts.LineData, n)
+	for i := range data {
+		data[i] = opts.LineData{Value: "-"}
+		if i < len(indicators) {
+			if v := value(indicators[i]); v != 0 {
+				data[i] = opts.LineData{Value: v}
+			}
+		}
+	}
+	return data
+}
+
+// ChartMaxXLabels X 轴最多显示的日期标签数，超过时按间隔抽稀
+var ChartMaxXLabels = 12
+
+// xAxisLabelInterval 按数据长度计算 X 轴标签间隔：每隔 interval 个标签显示一个，0 表示全部显示
+func xAxisLabelInterval(n int) int {
+	if ChartMaxXLabels <= 0 || n <= ChartMaxXLabels {
+		return 0
+	}
+	return (n+ChartMaxXLabels-1)/ChartMaxXLabels - 1
+}
+
+// xAxisOpts 抽稀后的 X 轴配置，首尾日期始终显示
+func xAxisOpts(n int) charts.GlobalOpts {
+	return charts.WithXAxisOpts(opts.XAxis{
+		AxisLabel: &opts.AxisLabel{
+			Interval:     strconv.Itoa(xAxisLabelInterval(n)),
+			Rotate:       30,
+			ShowMinLabel: opts.Bool(true),
+			ShowMaxLabel: opts.Bool(true),
+		},
+	})
+}
+
+// klineChart K线图，叠加 klineOverlays 指标线
+func klineChart(dates []string, stockData []StockData, indicators []TechnicalIndicator) *charts.Kline {
+	kline := charts.NewKLine()
+	var items []opts.KlineData
+	for _, d := range stockData {
+		items = append(items, opts.KlineData{Value: [4]float64{d.Open, d.Close, d.Low, d.High}})
+	}
+	kline.SetGlobalOptions(
+		xAxisOpts(len(dates)),
+		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true)}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Bottom: "0"}),
+	)
+	kline.SetXAxis(dates).AddSeries("K线", items, charts.WithItemStyleOpts(opts.ItemStyle{
+		Color: chartUpColor, Color0: chartDownColor, BorderColor: chartUpColor, BorderColor0: chartDownColor,
+	}))
+	overlay := charts.NewLine()
+	overlay.SetXAxis(dates)
+	for _, o := range klineOverlays {
+		overlay.AddSeries(o.name, overlayLineData(indicators, len(stockData), o.value), charts.WithLineChartOpts(opts.LineChart{ShowSymbol: opts.Bool(false)}))
+	}
+	kline.Overlap(overlay)
+	return kline
+}
+
+// volumeChart 成交量柱状图，收盘不低于开盘为红，否则为绿
+func volumeChart(dates []string, stockData []StockData) *charts.Bar {
+	vol := charts.NewBar()
+	var vols []opts.BarData
+	for _, d := range stockData {
+		color := chartUpColor
+		if d.Close < d.Open {
+			color = chartDownColor
+		}
+		vols = append(vols, opts.BarData{Value: d.Volume, ItemStyle: &opts.ItemStyle{Color: color}})
+	}
+	vol.SetGlobalOptions(xAxisOpts(len(dates)))
+	vol.SetXAxis(dates).AddSeries("成交量", vols)
+	return vol
+}
+
+// GenerateCharts 自动生成K线（叠加 MA5/MA20 与布林带上下轨）、均线、成交量（红涨绿跌）图，返回成功生成的PNG路径列表；
+// 任一图表失败时仍继续生成其余图表，并返回首个错误
+func GenerateCharts(ctx context.Context, stockCode string, stockData []StockData, indicators []TechnicalIndicator, outDir string) ([]string, error) {
 	if len(stockData) == 0 {
 		return nil, nil
 	}
+	chartMu.Lock()
+	defer chartMu.Unlock()
 	os.MkdirAll(outDir, 0755)
 
 	// 新增：生成前清理 charts 目录下所有 .html 文件
@@ -29,25 +134,25 @@ func GenerateCharts(stockCode string, stockData []StockData, indicators []Techni
 
 	var paths []string
 
-	// 1. K线图
-	kline := charts.NewKLine()
 	var kDates []string
-	var kItems []opts.KlineData
 	for _, d := range stockData {
 		kDates = append(kDates, d.Date.Format("2006-01-02"))
-		kItems = append(kItems, opts.KlineData{
-			Value: [4]float64{d.Open, d.Close, d.Low, d.High},
-		})
 	}
-	kline.SetGlobalOptions()
-	kline.SetXAxis(kDates).AddSeries("K线", kItems)
-	klinePath := filepath.Join(outDir, stockCode+"-kline.html")
-	f1, _ := os.Create(klinePath)
-	_ = kline.Render(f1)
-	klinePNG := filepath.Join(outDir, stockCode+"-kline.png")
-	_ = html2png(klinePath, klinePNG)
-	os.Remove(klinePath)
-	paths = append(paths, klinePNG)
+
+	var firstErr error
+	render := func(chart chartRenderer, name string) {
+		pngPath := filepath.Join(outDir, fileNameCode(stockCode)+"-"+name+".png")
+		if err := renderChartPNG(ctx, chart, filepath.Join(outDir, fileNameCode(stockCode)+"-"+name+".html"), pngPath); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("生成 %s 图表失败: %v", name, err)
+			}
+			return
+		}
+		paths = append(paths, pngPath)
+	}
+
+	// 1. K线图，叠加 MA5/MA20 与布林带上下轨
+	render(klineChart(kDates, stockData, indicators), "kline")
 
 	// 2. 均线图
 	ma := charts.NewLine()
@@ -58,42 +163,228 @@ func GenerateCharts(stockCode string, stockData []StockData, indicators []Techni
 		ma20 = append(ma20, opts.LineData{Value: ind.MA20})
 		ma60 = append(ma60, opts.LineData{Value: ind.MA60})
 	}
-	ma.SetGlobalOptions()
+	ma.SetGlobalOptions(xAxisOpts(len(kDates)))
 	ma.SetXAxis(kDates).
 		AddSeries("MA5", ma5).
 		AddSeries("MA10", ma10).
 		AddSeries("MA20", ma20).
 		AddSeries("MA60", ma60)
-	maPath := filepath.Join(outDir, stockCode+"-ma.html")
-	f2, _ := os.Create(maPath)
-	_ = ma.Render(f2)
-	maPNG := filepath.Join(outDir, stockCode+"-ma.png")
-	_ = html2png(maPath, maPNG)
-	os.Remove(maPath)
-	paths = append(paths, maPNG)
+	render(ma, "ma")
 
 	// 3. 成交量图
-	vol := charts.NewBar()
-	var vols []opts.BarData
-	for _, d := range stockData {
-		vols = append(vols, opts.BarData{Value: d.Volume})
-	}
-	vol.SetGlobalOptions()
-	vol.SetXAxis(kDates).AddSeries("成交量", vols)
-	volPath := filepath.Join(outDir, stockCode+"-vol.html")
-	f3, _ := os.Create(volPath)
-	_ = vol.Render(f3)
-	volPNG := filepath.Join(outDir, stockCode+"-vol.png")
-	_ = html2png(volPath, volPNG)
-	os.Remove(volPath)
-	paths = append(paths, volPNG)
-
-	return paths, nil
+	render(volumeChart(kDates, stockData), "vol")
+
+	return paths, firstErr
+}
+
+// chartRenderer go-echarts 图表的 HTML 渲染接口
+type chartRenderer interface {
+	Render(w io.Writer) error
+}
+
+// renderChartPNG 渲染图表 HTML 并转为 PNG，中间 HTML 用后删除；任一步失败时删除可能残留的 PNG，避免报告引用旧图或空图
+func renderChartPNG(ctx context.Context, chart chartRenderer, htmlPath, pngPath string) error {
+	f, err := os.Create(htmlPath)
+	if err != nil {
+		return err
+	}
+	err = chart.Render(f)
+	f.Close()
+	defer os.Remove(htmlPath)
+	if err != nil {
+		return err
+	}
+	if err := html2png(ctx, htmlPath, pngPath); err != nil {
+		os.Remove(pngPath)
+		return err
+	}
+	return nil
+}
+
+// GenerateRadarChart 生成多维评分雷达图（0-100），benchmark 非空时叠加行业均值；缺失的维度按 0 绘制并在维度名上标注，全部缺失时不生成
+func GenerateRadarChart(ctx context.Context, stockCode string, dims []string, scores, benchmark map[string]float64, benchmarkLabel, outDir string) (string, error) {
+	if len(scores) == 0 {
+		return "", nil
+	}
+	chartMu.Lock()
+	defer chartMu.Unlock()
+	os.MkdirAll(outDir, 0755)
+
+	var indicators []*opts.Indicator
+	var values, benchValues []float64
+	for _, dim := range dims {
+		name := dim
+		v, ok := scores[dim]
+		if !ok {
+			name += "（缺失）"
+		}
+		indicators = append(indicators, &opts.Indicator{Name: name, Min: 0, Max: 100})
+		values = append(values, v)
+		benchValues = append(benchValues, benchmark[dim])
+	}
+
+	radar := charts.NewRadar()
+	radar.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: stockCode + " 多维评分"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Bottom: "0"}),
+		charts.WithRadarComponentOpts(opts.RadarComponent{Indicator: indicators, Shape: "polygon", SplitNumber: 5}),
+	)
+	radar.AddSeries(stockCode, []opts.RadarData{{Name: stockCode, Value: values}},
+		charts.WithAreaStyleOpts(opts.AreaStyle{Opacity: opts.Float(0.3)}))
+	if len(benchmark) > 0 {
+		radar.AddSeries(benchmarkLabel, []opts.RadarData{{Name: benchmarkLabel, Value: benchValues}},
+			charts.WithAreaStyleOpts(opts.AreaStyle{Opacity: opts.Float(0.1)}))
+	}
+
+	radarPath := filepath.Join(outDir, fileNameCode(stockCode)+"-radar.html")
+	f, err := os.Create(radarPath)
+	if err != nil {
+		return "", err
+	}
+	err = radar.Render(f)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(radarPath)
+	radarPNG := filepath.Join(outDir, fileNameCode(stockCode)+"-radar.png")
+	if err := html2png(ctx, radarPath, radarPNG); err != nil {
+		return "", err
+	}
+	return radarPNG, nil
+}
+
+// GenerateScoreTrendChart 生成各维度评分随历次分析变化的折线图，某次分析缺少的维度留空
+func GenerateScoreTrendChart(ctx context.Context, stockCode string, trends []ScoreTrend, outDir string) (string, error) {
+	if len(trends) == 0 {
+		return "", nil
+	}
+	chartMu.Lock()
+	defer chartMu.Unlock()
+	os.MkdirAll(outDir, 0755)
+
+	var times []time.Time
+	seen := make(map[time.Time]bool)
+	for _, t := range trends {
+		for _, p := range t.Points {
+			if !seen[p.Time] {
+				seen[p.Time] = true
+				times = append(times, p.Time)
+			}
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+	var labels []string
+	for _, t := range times {
+		labels = append(labels, t.Format("01-02 15:04"))
+	}
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Title: stockCode + " 评分趋势"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), Bottom: "0"}),
+		charts.WithYAxisOpts(opts.YAxis{Min: 0, Max: 100}),
+		xAxisOpts(len(labels)),
+	)
+	line.SetXAxis(labels)
+	for _, t := range trends {
+		byTime := make(map[time.Time]float64, len(t.Points))
+		for _, p := range t.Points {
+			byTime[p.Time] = p.Score
+		}
+		var data []opts.LineData
+		for _, tm := range times {
+			if v, ok := byTime[tm]; ok {
+				data = append(data, opts.LineData{Value: v})
+			} else {
+				data = append(data, opts.LineData{Value: "-"})
+			}
+		}
+		line.AddSeries(t.Dim+"（"+t.Direction+"）", data, charts.WithLineChartOpts(opts.LineChart{ConnectNulls: opts.Bool(true)}))
+	}
+
+	trendPath := filepath.Join(outDir, fileNameCode(stockCode)+"-scoretrend.html")
+	f, err := os.Create(trendPath)
+	if err != nil {
+		return "", err
+	}
+	err = line.Render(f)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(trendPath)
+	trendPNG := filepath.Join(outDir, fileNameCode(stockCode)+"-scoretrend.png")
+	if err := html2png(ctx, trendPath, trendPNG); err != nil {
+		return "", err
+	}
+	return trendPNG, nil
+}
+
+// GenerateBacktestChart 生成回测资金曲线图，买卖点按逐笔交易记录标注；资金曲线首点对应回测起始日前一根K线
+func GenerateBacktestChart(ctx context.Context, stockCode string, stockData []StockData, result BacktestResult, outDir string) (string, error) {
+	if len(result.EquityCurve) < 2 || len(result.EquityCurve) > len(stockData) {
+		return "", nil
+	}
+	chartMu.Lock()
+	defer chartMu.Unlock()
+	os.MkdirAll(outDir, 0755)
+
+	offset := len(stockData) - len(result.EquityCurve)
+	var dates []string
+	var equity []opts.LineData
+	for i, v := range result.EquityCurve {
+		dates = append(dates, stockData[offset+i].Date.Format("2006-01-02"))
+		equity = append(equity, opts.LineData{Value: v})
+	}
+	var marks []opts.MarkPointNameCoordItem
+	for _, t := range result.TradeHistory {
+		name, color := "买", "#d14a61"
+		if t.Type == "sell" {
+			name, color = "卖", "#2f9e44"
+		}
+		marks = append(marks, opts.MarkPointNameCoordItem{
+			Name:       t.Reason,
+			Coordinate: []interface{}{t.Date.Format("2006-01-02"), t.Capital},
+			Value:      name,
+			Symbol:     "pin",
+			SymbolSize: 30,
+			ItemStyle:  &opts.ItemStyle{Color: color},
+		})
+	}
+
+	line := charts.NewLine()
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{
+			Title:    stockCode + " 回测资金曲线",
+			Subtitle: "初始资金 " + strconv.FormatFloat(result.InitialCapital, 'f', 0, 64) + "，年化收益率 " + FormatPercent(result.AnnualizedReturn) + "，夏普比率 " + FormatRatio(result.SharpeRatio),
+		}),
+		xAxisOpts(len(dates)),
+		charts.WithYAxisOpts(opts.YAxis{Scale: opts.Bool(true)}),
+	)
+	line.SetXAxis(dates).AddSeries("账户权益", equity, charts.WithMarkPointNameCoordItemOpts(marks...))
+
+	btPath := filepath.Join(outDir, fileNameCode(stockCode)+"-backtest.html")
+	f, err := os.Create(btPath)
+	if err != nil {
+		return "", err
+	}
+	err = line.Render(f)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(btPath)
+	btPNG := filepath.Join(outDir, fileNameCode(stockCode)+"-backtest.png")
+	if err := html2png(ctx, btPath, btPNG); err != nil {
+		return "", err
+	}
+	return btPNG, nil
 }
 
-// html2png 用 chromedp 将 HTML 渲染为 PNG
-func html2png(htmlPath, pngPath string) error {
-	ctx, cancel := chromedp.NewContext(context.Background())
+// html2png 用 chromedp 将 HTML 渲染为 PNG，ctx 取消时中止渲染；声明为变量便于替换渲染实现
+var html2png = func(ctx context.Context, htmlPath, pngPath string) error {
+	ctx, cancel := chromedp.NewContext(ctx)
 	defer cancel()
 	ctx, cancel = context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
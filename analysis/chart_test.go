@@ -0,0 +1,204 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestXAxisLabelInterval(t *testing.T) {
+	for _, c := range []struct{ n, want int }{
+		{0, 0}, {12, 0}, {13, 1}, {24, 1}, {25, 2}, {250, 20}, {1000, 83},
+	} {
+		interval := xAxisLabelInterval(c.n)
+		if interval != c.want {
+			t.Errorf("n=%d: 间隔 = %d，期望 %d", c.n, interval, c.want)
+		}
+		if shown := (c.n + interval) / (interval + 1); shown > ChartMaxXLabels {
+			t.Errorf("n=%d: 抽稀后显示 %d 个标签，超过上限 %d", c.n, shown, ChartMaxXLabels)
+		}
+	}
+	old := ChartMaxXLabels
+	ChartMaxXLabels = 0
+	defer func() { ChartMaxXLabels = old }()
+	if xAxisLabelInterval(1000) != 0 {
+		t.Error("ChartMaxXLabels<=0 时应全部显示")
+	}
+}
+
+func TestLongSeriesChartThinsLabels(t *testing.T) {
+	data := datedBars(250)
+	dates := make([]string, len(data))
+	for i, d := range data {
+		dates[i] = d.Date.Format("2006-01-02")
+	}
+	var buf bytes.Buffer
+	if err := klineChart(dates, data, calculateTechnicalIndicators(data)).Render(&buf); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, want := range []string{`"interval":"20"`, `"showMinLabel":true`, `"showMaxLabel":true`, dates[0], dates[249]} {
+		if !strings.Contains(html, want) {
+			t.Errorf("K线图缺少 %s", want)
+		}
+	}
+	buf.Reset()
+	if err := volumeChart(dates[:10], data[:10]).Render(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"interval":"0"`) {
+		t.Error("短序列应显示全部日期标签")
+	}
+}
+
+// captureHTML2PNG 将待截图的 HTML 原样拷贝到 PNG 路径，便于检查图表内容
+func captureHTML2PNG(t *testing.T) {
+	t.Helper()
+	old := html2png
+	html2png = func(ctx context.Context, htmlPath, pngPath string) error {
+		data, err := os.ReadFile(htmlPath)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(pngPath, data, 0644)
+	}
+	t.Cleanup(func() { html2png = old })
+}
+
+func TestGenerateRadarChartWithBenchmark(t *testing.T) {
+	captureHTML2PNG(t)
+	dir := t.TempDir()
+	scores := map[string]float64{"技术面": 75, "资金面": 60, "情绪面": 40}
+	benchmark := map[string]float64{"技术面": 55, "基本面": 65, "资金面": 50, "情绪面": 45}
+	path, err := GenerateRadarChart(context.Background(), "600036.SH", RadarDims, scores, benchmark, "银行均值", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != filepath.Join(dir, "600036.SH-radar.png") {
+		t.Errorf("雷达图路径 = %s", path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := string(data)
+	for _, want := range []string{"600036.SH 多维评分", "基本面（缺失）", `"max":100`, "银行均值", "[75,0,60,40]", "[55,65,50,45]"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("雷达图缺少 %s", want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "600036.SH-radar.html")); !os.IsNotExist(err) {
+		t.Error("生成 PNG 后应删除中间 HTML")
+	}
+
+	if path, err := GenerateRadarChart(context.Background(), "600036", RadarDims, nil, benchmark, "银行均值", dir); path != "" || err != nil {
+		t.Errorf("评分全部缺失时不应生成: %q %v", path, err)
+	}
+	path, _ = GenerateRadarChart(context.Background(), "000001", RadarDims, scores, nil, "", dir)
+	if data, _ := os.ReadFile(path); strings.Contains(string(data), "均值") {
+		t.Error("无行业均值时不应叠加对比序列")
+	}
+}
+
+func TestGenerateChartsOverlaysAndColors(t *testing.T) {
+	captureHTML2PNG(t)
+	dir := t.TempDir()
+	data := datedBars(60)
+	data[58].Close, data[59].Close = data[58].Open+0.5, data[59].Open-0.5 // 一涨一跌
+	paths, err := GenerateCharts(context.Background(), "600036.SH", data, calculateTechnicalIndicators(data), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"600036.SH-kline.png", "600036.SH-ma.png", "600036.SH-vol.png"}
+	if len(paths) != len(want) {
+		t.Fatalf("应返回 K线、均线、成交量三张图: %v", paths)
+	}
+	contents := make(map[string]string)
+	for i, path := range paths {
+		if path != filepath.Join(dir, want[i]) {
+			t.Errorf("第 %d 张图路径 = %s", i+1, path)
+		}
+		info, err := os.Stat(path)
+		if err != nil || info.Size() == 0 {
+			t.Errorf("%s 应存在且非空: %v", path, err)
+		}
+		b, _ := os.ReadFile(path)
+		contents[want[i]] = string(b)
+	}
+	kline := contents["600036.SH-kline.png"]
+	for _, s := range []string{`"name":"K线"`, `"name":"MA5"`, `"name":"MA20"`, `"name":"BOLL上轨"`, `"name":"BOLL下轨"`, `"value":"-"`, chartUpColor, chartDownColor} {
+		if !strings.Contains(kline, s) {
+			t.Errorf("K线图缺少叠加序列或配色 %s", s)
+		}
+	}
+	vol := contents["600036.SH-vol.png"]
+	if !strings.Contains(vol, `"color":"`+chartUpColor+`"`) || !strings.Contains(vol, `"color":"`+chartDownColor+`"`) {
+		t.Error("成交量柱应按涨跌分别着色")
+	}
+	if htmls, _ := filepath.Glob(filepath.Join(dir, "*.html")); len(htmls) != 0 {
+		t.Errorf("生成 PNG 后应删除中间 HTML: %v", htmls)
+	}
+}
+
+func TestGenerateChartsReturnsHTML2PNGError(t *testing.T) {
+	old := html2png
+	defer func() { html2png = old }()
+	html2png = func(ctx context.Context, htmlPath, pngPath string) error {
+		os.WriteFile(pngPath, nil, 0644) // 模拟截图中途失败留下的空文件
+		if strings.HasSuffix(pngPath, "-vol.png") {
+			return errors.New("chrome 不可用")
+		}
+		return os.WriteFile(pngPath, []byte("png"), 0644)
+	}
+	dir := t.TempDir()
+	data := datedBars(30)
+	paths, err := GenerateCharts(context.Background(), "600036", data, calculateTechnicalIndicators(data), dir)
+	if err == nil || !strings.Contains(err.Error(), "vol") || !strings.Contains(err.Error(), "chrome 不可用") {
+		t.Errorf("应返回成交量图的渲染错误: %v", err)
+	}
+	if len(paths) != 2 || strings.Contains(strings.Join(paths, ","), "-vol.png") {
+		t.Errorf("失败的图表不应出现在返回路径中: %v", paths)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "600036-vol.png")); !os.IsNotExist(err) {
+		t.Error("渲染失败时应删除残留的空 PNG")
+	}
+}
+
+func TestAnalyzeOneSkipsBrokenChartRefs(t *testing.T) {
+	wd, _ := os.Getwd()
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	oldTransport, oldRetries, oldPNG := FetchTransport, FetchMaxRetries, html2png
+	FetchTransport, FetchMaxRetries = failingTransport{}, 0
+	defer func() {
+		FetchTransport, FetchMaxRetries, html2png = oldTransport, oldRetries, oldPNG
+		os.Chdir(wd)
+	}()
+	params := AnalysisParams{StockCodes: []string{"600036"}, Model: "deepseek-chat", DataSource: DataSourceCSV, CSVDir: filepath.Join(dir, "csv")}
+	os.MkdirAll(params.CSVDir, 0755)
+	writeTestCSV(t, filepath.Join(params.CSVDir, "600036.csv"), 150)
+	analyze := func() string {
+		result := AnalyzeOne(context.Background(), params, func(stock, p, apiKey, apiURL, model string, searchMode, hybridSearch bool) (string, error) {
+			return "技术面评分：70", nil
+		})
+		if result.Err != nil {
+			t.Fatalf("图表失败不应中断分析: %v", result.Err)
+		}
+		return result.Report
+	}
+
+	captureHTML2PNG(t)
+	if report := analyze(); !strings.Contains(report, "![图表](") {
+		t.Fatalf("图表生成成功时报告应引用图片:\n%s", report)
+	}
+	html2png = func(ctx context.Context, htmlPath, pngPath string) error { return errors.New("chrome 不可用") }
+	if report := analyze(); strings.Contains(report, "![") {
+		t.Errorf("html2png 失败时报告不应包含图片引用:\n%s", report)
+	}
+}
@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowTransport 每个请求等待片刻后失败，记录同时进行的最大请求数
+type slowTransport struct {
+	inflight, peak int32
+}
+
+func (s *slowTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	n := atomic.AddInt32(&s.inflight, 1)
+	defer atomic.AddInt32(&s.inflight, -1)
+	for {
+		p := atomic.LoadInt32(&s.peak)
+		if n <= p || atomic.CompareAndSwapInt32(&s.peak, p, n) {
+			break
+		}
+	}
+	time.Sleep(5 * time.Millisecond)
+	return failingTransport{}.RoundTrip(req)
+}
+
+func TestCompareStocksConcurrentResultsComplete(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	st := &slowTransport{}
+	oldTransport, oldRetries, oldCache, oldConc := FetchTransport, FetchMaxRetries, DataCacheDir, CompareConcurrency
+	FetchTransport, FetchMaxRetries, DataCacheDir, CompareConcurrency = st, 0, t.TempDir(), 4
+	defer func() {
+		FetchTransport, FetchMaxRetries, DataCacheDir, CompareConcurrency = oldTransport, oldRetries, oldCache, oldConc
+		os.Chdir(wd)
+	}()
+
+	// 偶数下标的股票有本地缓存可用，奇数下标的实时接口与缓存均失败
+	var codes []string
+	for i := 0; i < 12; i++ {
+		code := fmt.Sprintf("6000%02d", i)
+		codes = append(codes, code)
+		if i%2 == 0 {
+			if err := saveStockCache(code, "测试", datedBars(80)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	list, err := CompareStocks(context.Background(), codes, "", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(codes) {
+		t.Fatalf("结果 %d 条，期望 %d 条", len(list), len(codes))
+	}
+	byCode := make(map[string]StockComparison, len(list))
+	for _, c := range list {
+		byCode[c.StockCode] = c
+	}
+	for i, code := range codes {
+		c, found := byCode[code]
+		if !found {
+			t.Errorf("结果缺少 %s", code)
+			continue
+		}
+		if ok := i%2 == 0; ok != (c.Risk != nil) || ok == (c.Error != "") {
+			t.Errorf("%s: Risk=%v Error=%q，失败的股票应记录错误而非丢弃", code, c.Risk != nil, c.Error)
+		}
+	}
+	if peak := atomic.LoadInt32(&st.peak); peak > int32(CompareConcurrency) || peak < 2 {
+		t.Errorf("同时进行的请求峰值 %d，期望并发且不超过 %d", peak, CompareConcurrency)
+	}
+}
+
+func TestCompareStocksMixedMarketsRouteSources(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	var mu sync.Mutex
+	calls := make(map[string][]string) // 规范化后的代码 → 依次尝试的数据源
+	stub := func(id string) func(context.Context, string) ([]StockData, error) {
+		return func(ctx context.Context, code string) ([]StockData, error) {
+			mu.Lock()
+			calls[code] = append(calls[code], id)
+			mu.Unlock()
+			if (id == sourceTencent && StockMarket(code) == MarketA) || (id == sourceYahoo && code == "AAPL") {
+				return datedBars(80), nil
+			}
+			return nil, fmt.Errorf("%s 无 %s 的行情", id, code)
+		}
+	}
+	oldSources, oldCache, oldDB := stockDataSources, DataCacheDir, DBPath
+	stockDataSources = []stockDataSource{
+		{sourceXueqiu, "雪球API", stub(sourceXueqiu)},
+		{sourceNetEase, "网易API", stub(sourceNetEase)},
+		{sourceTencent, "腾讯API", stub(sourceTencent)},
+		{sourceYahoo, "Yahoo API", stub(sourceYahoo)},
+	}
+	DataCacheDir, DBPath = t.TempDir(), ""
+	defer func() {
+		stockDataSources, DataCacheDir, DBPath = oldSources, oldCache, oldDB
+		os.Chdir(wd)
+	}()
+
+	list, err := CompareStocks(context.Background(), []string{"600519.SH", "AAPL", "000001.sz", "ZZZZ"}, "", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byCode := make(map[string]StockComparison)
+	for _, c := range list {
+		byCode[c.StockCode] = c
+	}
+	for code, source := range map[string]string{"600519.SH": "腾讯API", "000001.sz": "腾讯API", "AAPL": "Yahoo API"} {
+		if c := byCode[code]; c.Risk == nil || c.Error != "" || !strings.Contains(c.DataSource, source) {
+			t.Errorf("%s 应经 %s 取到行情: %+v", code, source, c)
+		}
+	}
+	if c := byCode["ZZZZ"]; c.Risk != nil || c.Error == "" {
+		t.Errorf("取不到行情的代码应标注错误而非丢弃: %+v", c)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := calls["600519"]; len(got) != 1 || got[0] != sourceTencent {
+		t.Errorf("带后缀的 A 股应规范化后先走腾讯: %v（全部调用 %v）", got, calls)
+	}
+	if got := calls["AAPL"]; len(got) != 1 || got[0] != sourceYahoo {
+		t.Errorf("美股应先走 Yahoo: %v", got)
+	}
+	if got := calls["ZZZZ"]; len(got) != 4 || got[0] != sourceYahoo || got[3] != sourceTencent {
+		t.Errorf("首选数据源失败后应按默认顺序降级: %v", got)
+	}
+}
@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AllowedDataSources 允许使用的行情数据源标识（xueqiu/netease/tencent/yahoo/eastmoney），为空表示不限制
+var AllowedDataSources []string
+
+// AllowedLLMs 允许调用的大模型服务，可填别名 deepseek/gemini 或接口域名（如 openrouter.ai），为空表示不限制
+var AllowedLLMs []string
+
+// GeminiAPIHost Gemini 接口域名，用于白名单校验
+const GeminiAPIHost = "generativelanguage.googleapis.com"
+
+// llmAliases 大模型别名对应的接口域名
+var llmAliases = map[string]string{
+	"deepseek": "api.deepseek.com",
+	"gemini":   GeminiAPIHost,
+}
+
+// DataSourceAllowed 行情数据源是否在白名单内
+func DataSourceAllowed(id string) bool {
+	if len(AllowedDataSources) == 0 {
+		return true
+	}
+	for _, s := range AllowedDataSources {
+		if strings.EqualFold(strings.TrimSpace(s), id) {
+			return true
+		}
+	}
+	return false
+}
+
+// llmHost 接口地址的域名，无法解析时原样返回
+func llmHost(apiURL string) string {
+	if u, err := url.Parse(apiURL); err == nil && u.Host != "" {
+		return strings.ToLower(u.Hostname())
+	}
+	return strings.ToLower(apiURL)
+}
+
+// CheckLLMAllowed 按接口域名校验大模型服务是否在白名单内，不在时返回合规提示错误
+func CheckLLMAllowed(apiURL string) error {
+	if len(AllowedLLMs) == 0 {
+		return nil
+	}
+	host := llmHost(apiURL)
+	for _, s := range AllowedLLMs {
+		s = strings.ToLower(strings.TrimSpace(s))
+		if alias, ok := llmAliases[s]; ok {
+			s = alias
+		}
+		if s == host {
+			return nil
+		}
+	}
+	return fmt.Errorf("合规限制：大模型服务 %s 不在允许列表（%s）中，已阻止调用", host, strings.Join(AllowedLLMs, ","))
+}
+
+// LLMProviderURL 交互菜单中大模型名称对应的接口地址，DeepSeek 取当前 DeepSeekBaseURL
+func LLMProviderURL(llmType string) string {
+	if llmType == "Gemini" {
+		return "https://" + GeminiAPIHost
+	}
+	return ChatCompletionsURL(DeepSeekBaseURL)
+}
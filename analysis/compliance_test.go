@@ -0,0 +1,89 @@
+package analysis
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func useAllowlists(t *testing.T, sources, llms []string) {
+	t.Helper()
+	oldSources, oldLLMs := AllowedDataSources, AllowedLLMs
+	AllowedDataSources, AllowedLLMs = sources, llms
+	t.Cleanup(func() { AllowedDataSources, AllowedLLMs = oldSources, oldLLMs })
+}
+
+func TestFetchFromSourcesSkipsBlockedSources(t *testing.T) {
+	called := map[string]int{}
+	stub := func(id string) func(context.Context, string) ([]StockData, error) {
+		return func(ctx context.Context, code string) ([]StockData, error) {
+			called[id]++
+			return datedBars(30), nil
+		}
+	}
+	old := stockDataSources
+	stockDataSources = []stockDataSource{
+		{sourceTencent, "腾讯API", stub(sourceTencent)},
+		{sourceYahoo, "Yahoo API", stub(sourceYahoo)},
+	}
+	defer func() { stockDataSources = old }()
+
+	useAllowlists(t, []string{" Yahoo "}, nil)
+	if _, source, err := fetchFromSources(context.Background(), "600036"); err != nil || source != "Yahoo API" {
+		t.Fatalf("应跳过腾讯改用 Yahoo: source=%q err=%v", source, err)
+	}
+	if called[sourceTencent] != 0 {
+		t.Errorf("不在白名单的数据源不应被调用: %v", called)
+	}
+
+	AllowedDataSources = []string{sourceNetEase}
+	if _, _, err := fetchFromSources(context.Background(), "600036"); err == nil || !strings.Contains(err.Error(), "合规限制") {
+		t.Errorf("全部数据源被禁用时应返回合规错误: %v", err)
+	}
+	if _, err := FetchCapitalFlow(context.Background(), "600036"); err == nil || !strings.Contains(err.Error(), "合规限制") {
+		t.Errorf("东方财富被禁用时资金流向应返回合规错误: %v", err)
+	}
+	if _, _, err := FetchRealtimeQuote(context.Background(), "600036"); err == nil || !strings.Contains(err.Error(), "合规限制") {
+		t.Errorf("腾讯被禁用时实时行情应返回合规错误: %v", err)
+	}
+}
+
+func TestCheckLLMAllowed(t *testing.T) {
+	useAllowlists(t, nil, nil)
+	if err := CheckLLMAllowed("https://openrouter.ai/api/v1/chat/completions"); err != nil {
+		t.Errorf("白名单为空时不应限制: %v", err)
+	}
+	AllowedLLMs = []string{"deepseek", " OpenRouter.ai "}
+	for _, u := range []string{"https://api.deepseek.com/chat/completions", "https://openrouter.ai/api/v1/chat/completions"} {
+		if err := CheckLLMAllowed(u); err != nil {
+			t.Errorf("%s 应被允许: %v", u, err)
+		}
+	}
+	err := CheckLLMAllowed(LLMProviderURL("Gemini"))
+	if err == nil || !strings.Contains(err.Error(), "合规限制") || !strings.Contains(err.Error(), GeminiAPIHost) {
+		t.Errorf("Gemini 不在白名单时应阻止: %v", err)
+	}
+}
+
+func TestBlockedLLMIsNotCalled(t *testing.T) {
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { calls++ }))
+	defer srv.Close()
+	useAllowlists(t, nil, []string{"deepseek"})
+
+	ctx := context.Background()
+	if _, err := GenerateAIReportWithGeneration(ctx, "600036", "prompt", "key", srv.URL, "deepseek-chat", false, false, GenerationConfig{}); err == nil || !strings.Contains(err.Error(), "合规限制") {
+		t.Errorf("非白名单接口应返回合规错误: %v", err)
+	}
+	if _, err := GenerateAIReportStream(ctx, "600036", "prompt", "key", srv.URL, "deepseek-chat", false, false, GenerationConfig{}, func(string) {}); err == nil || !strings.Contains(err.Error(), "合规限制") {
+		t.Errorf("流式调用同样应受白名单限制: %v", err)
+	}
+	if _, err := GenerateGeminiReportWithConfigAndSearch(ctx, "gemini-2.0-flash", "key", "prompt", false); err == nil || !strings.Contains(err.Error(), "合规限制") {
+		t.Errorf("Gemini 调用应受白名单限制: %v", err)
+	}
+	if calls != 0 {
+		t.Errorf("被禁用的大模型服务收到了 %d 次请求", calls)
+	}
+}
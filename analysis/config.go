@@ -0,0 +1,149 @@
+package analysis
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile 全局配置文件，命令行未显式指定的大模型与推送参数从这里读取；文件不存在时不生效
+var ConfigFile = "config.yaml"
+
+// DeepSeekConfig 大模型接口配置
+type DeepSeekConfig struct {
+	APIURL      string  `yaml:"api_url"` // OpenAI 兼容接口前缀，同 -api-base
+	Model       string  `yaml:"model"`
+	APIKey      string  `yaml:"api_key"`
+	Temperature float64 `yaml:"temperature"`
+	MaxRetries  int     `yaml:"max_retries"` // 429/503 时的最大重试次数，同 -llm-retries
+}
+
+// NotifyConfig 邮件与 IM 推送的默认配置
+type NotifyConfig struct {
+	SMTPServer string `yaml:"smtp_server"`
+	SMTPPort   int    `yaml:"smtp_port"`
+	SMTPUser   string `yaml:"smtp_user"`
+	SMTPPass   string `yaml:"smtp_pass"`
+	SMTPTLS    string `yaml:"smtp_tls"` // auto/tls/starttls/none
+	// SMTPInsecure 不校验 SMTP 服务器证书，同 -smtp-insecure，仅用于自签名证书
+	SMTPInsecure bool     `yaml:"smtp_insecure_skip_verify"`
+	Webhook      string   `yaml:"webhook"`
+	Emails       []string `yaml:"emails"` // 默认收件人
+}
+
+// StorageConfig 本地持久化配置
+type StorageConfig struct {
+	DBPath          string `yaml:"db_path"`          // 分析结果 SQLite 库路径，同 -db
+	RedisAddr       string `yaml:"redis_addr"`       // 机器学习预测缓存的 Redis 地址，同 -redis
+	CacheExpiration int    `yaml:"cache_expiration"` // 预测缓存时长（秒），同 -cache-ttl
+}
+
+// MLConfig 机器学习模型配置
+type MLConfig struct {
+	ModelPath string `yaml:"model_path"` // 随机森林模型保存目录，同 -model-path
+}
+
+// Config 配置文件结构（YAML），顶层为 deepseek、notify、storage、ml 四节
+type Config struct {
+	DeepSeek DeepSeekConfig `yaml:"deepseek"`
+	Notify   NotifyConfig   `yaml:"notify"`
+	Storage  StorageConfig  `yaml:"storage"`
+	ML       MLConfig       `yaml:"ml"`
+}
+
+// DefaultConfig 配置文件缺省字段的取值
+func DefaultConfig() Config {
+	return Config{
+		DeepSeek: DeepSeekConfig{
+			APIURL:      DeepSeekBaseURL,
+			Model:       DefaultDeepSeekModels[0],
+			Temperature: DefaultTemperature,
+			MaxRetries:  LLMMaxRetries,
+		},
+		Notify: NotifyConfig{
+			SMTPPort: 465,
+			SMTPTLS:  SMTPTLSAuto,
+		},
+		Storage: StorageConfig{
+			DBPath:          DBPath,
+			CacheExpiration: int(MLCacheTTL / time.Second),
+		},
+		ML: MLConfig{
+			ModelPath: MLModelPath,
+		},
+	}
+}
+
+// LoadConfig 读取 YAML 配置文件（JSON 是 YAML 的子集，旧的 JSON 配置仍可读取）并覆盖默认值；
+// 文件不存在时返回默认配置与 os.ErrNotExist
+func LoadConfig(path string) (Config, error) {
+	cfg := DefaultConfig()
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return DefaultConfig(), fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+	if err := validateConfig(cfg); err != nil {
+		return DefaultConfig(), fmt.Errorf("配置文件 %s 无效: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// validateConfig 校验配置取值范围
+func validateConfig(cfg Config) error {
+	if err := validateHTTPURL("deepseek.api_url", cfg.DeepSeek.APIURL); err != nil {
+		return err
+	}
+	if strings.TrimSpace(cfg.DeepSeek.Model) == "" {
+		return fmt.Errorf("deepseek.model 不能为空")
+	}
+	if t := cfg.DeepSeek.Temperature; t < 0 || t > 2 {
+		return fmt.Errorf("deepseek.temperature 应在 0-2 之间: %v", t)
+	}
+	if cfg.DeepSeek.MaxRetries < 0 {
+		return fmt.Errorf("deepseek.max_retries 不能为负: %d", cfg.DeepSeek.MaxRetries)
+	}
+	n := cfg.Notify
+	if n.SMTPPort <= 0 || n.SMTPPort > 65535 {
+		return fmt.Errorf("notify.smtp_port 无效: %d", n.SMTPPort)
+	}
+	switch n.SMTPTLS {
+	case SMTPTLSAuto, SMTPTLSImplicit, SMTPTLSStartTLS, SMTPTLSNone:
+	default:
+		return fmt.Errorf("notify.smtp_tls 仅支持 auto/tls/starttls/none: %s", n.SMTPTLS)
+	}
+	if n.Webhook != "" {
+		if err := validateHTTPURL("notify.webhook", n.Webhook); err != nil {
+			return err
+		}
+	}
+	for _, e := range n.Emails {
+		if !strings.Contains(e, "@") {
+			return fmt.Errorf("notify.emails 含无效邮箱: %s", e)
+		}
+	}
+	if len(n.Emails) > 0 && n.SMTPServer == "" {
+		return fmt.Errorf("配置了 notify.emails 但未配置 notify.smtp_server")
+	}
+	if cfg.Storage.CacheExpiration <= 0 {
+		return fmt.Errorf("storage.cache_expiration 应为正整数（秒）: %d", cfg.Storage.CacheExpiration)
+	}
+	if strings.TrimSpace(cfg.ML.ModelPath) == "" {
+		return fmt.Errorf("ml.model_path 不能为空")
+	}
+	return nil
+}
+
+func validateHTTPURL(field, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%s 应为 http(s) 地址: %s", field, raw)
+	}
+	return nil
+}
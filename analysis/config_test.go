@@ -0,0 +1,112 @@
+package analysis
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigOverridesDefaults(t *testing.T) {
+	path := writeConfig(t, `
+deepseek:
+  api_key: sk-x
+  model: deepseek-reasoner
+  temperature: 0.3
+notify:
+  smtp_server: smtp.example.com
+  smtp_port: 587
+  smtp_tls: starttls
+  smtp_insecure_skip_verify: true
+  webhook: https://hooks.example.com/x
+  emails:
+    - a@example.com
+storage:
+  db_path: data/q.db
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	def := DefaultConfig()
+	if cfg.DeepSeek.APIKey != "sk-x" || cfg.DeepSeek.Model != "deepseek-reasoner" || cfg.DeepSeek.Temperature != 0.3 {
+		t.Errorf("deepseek 未覆盖: %+v", cfg.DeepSeek)
+	}
+	if cfg.DeepSeek.APIURL != def.DeepSeek.APIURL || cfg.DeepSeek.MaxRetries != def.DeepSeek.MaxRetries {
+		t.Errorf("未配置的字段应保留默认值: %+v", cfg.DeepSeek)
+	}
+	n := cfg.Notify
+	if n.SMTPServer != "smtp.example.com" || n.SMTPPort != 587 || n.SMTPTLS != SMTPTLSStartTLS || !n.SMTPInsecure ||
+		n.Webhook != "https://hooks.example.com/x" || !reflect.DeepEqual(n.Emails, []string{"a@example.com"}) {
+		t.Errorf("notify 未覆盖: %+v", n)
+	}
+	if cfg.Storage.DBPath != "data/q.db" {
+		t.Errorf("storage.db_path = %q", cfg.Storage.DBPath)
+	}
+}
+
+func TestLoadConfigJSONCompatible(t *testing.T) {
+	// JSON 是 YAML 的子集，旧的 config.json 内容仍可直接使用
+	cfg, err := LoadConfig(writeConfig(t, `{"deepseek": {"model": "deepseek-reasoner"}, "notify": {"emails": []}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.DeepSeek.Model != "deepseek-reasoner" || cfg.Notify.SMTPPort != DefaultConfig().Notify.SMTPPort {
+		t.Errorf("JSON 格式配置未正确加载: %+v", cfg)
+	}
+}
+
+func TestLoadConfigRoundTrip(t *testing.T) {
+	want := DefaultConfig()
+	want.DeepSeek.APIKey = "sk-round"
+	want.Notify.SMTPServer = "smtp.example.com"
+	want.Notify.Emails = []string{"a@example.com", "b@example.com"}
+	want.Storage.RedisAddr = "localhost:6379"
+	data, err := yaml.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadConfig(writeConfig(t, string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("YAML 往返后配置不一致:\n%s\n得到 %+v", data, got)
+	}
+}
+
+func TestLoadConfigInvalid(t *testing.T) {
+	if cfg, err := LoadConfig(filepath.Join(t.TempDir(), "none.yaml")); !errors.Is(err, os.ErrNotExist) || !reflect.DeepEqual(cfg, DefaultConfig()) {
+		t.Errorf("文件不存在时应返回默认配置与 ErrNotExist，得到 %v", err)
+	}
+	for _, c := range []struct{ content, want string }{
+		{`{"deepseek": {"api_url": "ftp://x"}}`, "api_url"},
+		{`{"notify": {"smtp_port": 0}}`, "smtp_port"},
+		{`{"notify": {"smtp_tls": "ssl"}}`, "smtp_tls"},
+		{`{"notify": {"emails": ["a@example.com"]}}`, "smtp_server"},
+		{`{"notify": {"smtp_server": "s", "emails": ["bad"]}}`, "emails"},
+		{"deepseek:\n  temperature: 3\n", "temperature"},
+		{"deepseek: [", "解析"},
+	} {
+		cfg, err := LoadConfig(writeConfig(t, c.content))
+		if err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: 错误 %v，期望包含 %q", c.content, err, c.want)
+		}
+		if !reflect.DeepEqual(cfg, DefaultConfig()) {
+			t.Errorf("%s: 无效配置应回退默认值", c.content)
+		}
+	}
+}
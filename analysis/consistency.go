@@ -0,0 +1,108 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// StructuredConclusion 从报告中抽取的关键结论，用于一致性校验
+type StructuredConclusion struct {
+	Trend          string  // 趋势判断原文，如“看涨”
+	Recommendation string  // 操作建议原文，如“买入”
+	TargetPrice    float64 // 目标价，0 表示未给出
+	CurrentPrice   float64 // 当前价（本地最新收盘价），0 表示未知
+}
+
+// 方向词与结论行关键词取各语言并集（见 reportKeywordSets），中英文报告都能识别
+var (
+	bullishWords = allKeywords(func(k reportKeywords) []string { return k.bullish })
+	bearishWords = allKeywords(func(k reportKeywords) []string { return k.bearish })
+	buyWords     = allKeywords(func(k reportKeywords) []string { return k.buy })
+	sellWords    = allKeywords(func(k reportKeywords) []string { return k.sell })
+	trendKeys    = allKeywords(func(k reportKeywords) []string { return k.trend })
+	adviceKeys   = allKeywords(func(k reportKeywords) []string { return k.advice })
+	targetKeys   = allKeywords(func(k reportKeywords) []string { return k.target })
+	numberRe     = regexp.MustCompile(`([0-9]+\.[0-9]+|[0-9]+)`)
+)
+
+// textDirection 判断文本方向：1 多，-1 空，0 无法判断或多空词同时出现
+func textDirection(text string, up, down []string) int {
+	lower := strings.ToLower(text)
+	hasUp, hasDown := false, false
+	for _, w := range up {
+		if strings.Contains(lower, w) {
+			hasUp = true
+			break
+		}
+	}
+	for _, w := range down {
+		if strings.Contains(lower, w) {
+			hasDown = true
+			break
+		}
+	}
+	switch {
+	case hasUp && !hasDown:
+		return 1
+	case hasDown && !hasUp:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// ExtractConclusion 从报告文本中抽取趋势、建议、目标价
+func ExtractConclusion(report string, currentPrice float64) StructuredConclusion {
+	c := StructuredConclusion{CurrentPrice: currentPrice}
+	for _, line := range strings.Split(report, "\n") {
+		if c.Trend == "" && containsAny(line, trendKeys) && textDirection(line, bullishWords, bearishWords) != 0 {
+			c.Trend = strings.TrimSpace(line)
+		}
+		if c.Recommendation == "" && containsAny(line, adviceKeys) && textDirection(line, buyWords, sellWords) != 0 {
+			c.Recommendation = strings.TrimSpace(line)
+		}
+		if c.TargetPrice == 0 && containsAny(line, targetKeys) {
+			if m := numberRe.FindString(line); m != "" {
+				c.TargetPrice, _ = strconv.ParseFloat(m, 64)
+			}
+		}
+	}
+	return c
+}
+
+// CheckReportConsistency 检测趋势、建议、目标价方向是否矛盾，返回冲突说明列表
+func CheckReportConsistency(c StructuredConclusion) []string {
+	var conflicts []string
+	trend := textDirection(c.Trend, bullishWords, bearishWords)
+	advice := textDirection(c.Recommendation, buyWords, sellWords)
+	target := 0
+	if c.TargetPrice > 0 && c.CurrentPrice > 0 {
+		if c.TargetPrice > c.CurrentPrice {
+			target = 1
+		} else if c.TargetPrice < c.CurrentPrice {
+			target = -1
+		}
+	}
+	if trend != 0 && advice != 0 && trend != advice {
+		conflicts = append(conflicts, fmt.Sprintf("趋势判断（%s）与操作建议（%s）方向相反", directionText(trend), directionText(advice)))
+	}
+	if target != 0 && advice != 0 && target != advice {
+		conflicts = append(conflicts, fmt.Sprintf("目标价 %.2f 相对当前价 %.2f 的方向与操作建议（%s）相反", c.TargetPrice, c.CurrentPrice, directionText(advice)))
+	}
+	if target != 0 && trend != 0 && target != trend {
+		conflicts = append(conflicts, fmt.Sprintf("目标价 %.2f 相对当前价 %.2f 的方向与趋势判断（%s）相反", c.TargetPrice, c.CurrentPrice, directionText(trend)))
+	}
+	return conflicts
+}
+
+func directionText(d int) string {
+	if d > 0 {
+		return "偏多"
+	}
+	if d < 0 {
+		return "偏空"
+	}
+	return "中性"
+}
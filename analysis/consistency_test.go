@@ -0,0 +1,44 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckReportConsistencyDetectsConflicts(t *testing.T) {
+	report := "## 主要结论\n趋势判断：看涨，多头排列\n操作建议：逢高卖出\n目标价：9.50 元\n"
+	c := ExtractConclusion(report, 10)
+	if c.TargetPrice != 9.5 || c.Recommendation == "" || c.Trend == "" {
+		t.Fatalf("结论抽取错误: %+v", c)
+	}
+	conflicts := CheckReportConsistency(c)
+	if len(conflicts) != 2 {
+		t.Fatalf("应检出趋势与建议、目标价与趋势两处冲突，得到 %v", conflicts)
+	}
+	if !strings.Contains(conflicts[0], "趋势判断（偏多）与操作建议（偏空）") {
+		t.Errorf("趋势与建议冲突说明 = %q", conflicts[0])
+	}
+	if !strings.Contains(conflicts[1], "目标价 9.50") || !strings.Contains(conflicts[1], "趋势判断（偏多）") {
+		t.Errorf("目标价冲突说明 = %q", conflicts[1])
+	}
+
+	// 目标价高于现价却建议卖出
+	if got := CheckReportConsistency(StructuredConclusion{Recommendation: "建议减持", TargetPrice: 12, CurrentPrice: 10}); len(got) != 1 || !strings.Contains(got[0], "操作建议（偏空）") {
+		t.Errorf("目标价与建议冲突 = %v", got)
+	}
+}
+
+func TestCheckReportConsistencyNoConflict(t *testing.T) {
+	for _, c := range []StructuredConclusion{
+		{Trend: "看涨", Recommendation: "买入", TargetPrice: 12, CurrentPrice: 10},
+		{Trend: "看跌", Recommendation: "减仓", TargetPrice: 8, CurrentPrice: 10},
+		{Trend: "震荡", Recommendation: "卖出"},                  // 趋势无方向不判冲突
+		{Trend: "看涨", Recommendation: "先买入后择机卖出"},            // 多空词同时出现视为无法判断
+		{Trend: "看涨", Recommendation: "买入", TargetPrice: 12}, // 缺少当前价不比较目标价
+		{},
+	} {
+		if got := CheckReportConsistency(c); len(got) != 0 {
+			t.Errorf("%+v 不应有冲突: %v", c, got)
+		}
+	}
+}
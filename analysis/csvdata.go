@@ -0,0 +1,235 @@
+package analysis
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 行情数据源模式
+const (
+	DataSourceOnline = "online" // 网络接口，失败时降级本地缓存
+	DataSourceCSV    = "csv"    // 从本地目录按 {code}.csv 读取，不访问网络
+)
+
+// DefaultCSVDir csv 模式未指定目录时使用的 K 线目录
+const DefaultCSVDir = "data"
+
+// csvDateLayouts CSV 日期列支持的格式
+var csvDateLayouts = []string{"2006-01-02", "2006/01/02", "20060102", "2006-01-02 15:04:05"}
+
+func parseCSVDate(s string) (time.Time, error) {
+	for _, layout := range csvDateLayouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("无法识别的日期: %s", s)
+}
+
+// csvColumns K线字段及其可识别的表头名（小写比较），Adj Close 等其余列忽略
+var csvColumns = []struct {
+	name    string
+	aliases []string
+}{
+	{"date", []string{"date", "datetime", "trade_date", "日期"}},
+	{"open", []string{"open", "开盘", "开盘价"}},
+	{"high", []string{"high", "最高", "最高价"}},
+	{"low", []string{"low", "最低", "最低价"}},
+	{"close", []string{"close", "收盘", "收盘价"}},
+	{"volume", []string{"volume", "vol", "成交量"}},
+}
+
+// csvDefaultIndex 无表头时的列序 date,open,high,low,close,volume
+var csvDefaultIndex = []int{0, 1, 2, 3, 4, 5}
+
+// csvHeaderIndex 按表头名定位各字段所在列，缺列时报出全部缺失的列名
+func csvHeaderIndex(header []string) ([]int, error) {
+	index := make([]int, len(csvColumns))
+	var missing []string
+	for i, col := range csvColumns {
+		index[i] = -1
+		for j, h := range header {
+			h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+			for _, alias := range col.aliases {
+				if h == alias {
+					index[i] = j
+				}
+			}
+			if index[i] >= 0 {
+				break
+			}
+		}
+		if index[i] < 0 {
+			missing = append(missing, col.name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("缺少列: %s（表头: %s）", strings.Join(missing, ","), strings.Join(header, ","))
+	}
+	return index, nil
+}
+
+// FetchCSVKlines 读取单个 K 线 CSV 文件。有表头时按列名（date/open/high/low/close/volume，大小写不敏感）取值，
+// 列序不限，兼容 Yahoo 导出的 Adj Close 列；无表头时按 date,open,high,low,close,volume 顺序读取。
+// 含 null 的行（Yahoo 休市日）跳过
+func FetchCSVKlines(path string) ([]StockData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	var list []StockData
+	var index []int
+	maxIndex := 0
+	for line := 1; ; line++ {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行: %v", path, line, err)
+		}
+		if len(rec) == 0 || (len(rec) == 1 && strings.TrimSpace(rec[0]) == "") {
+			continue
+		}
+		if index == nil {
+			isHeader := false
+			if _, err := parseCSVDate(strings.TrimSpace(strings.TrimPrefix(rec[0], "\ufeff"))); err == nil {
+				index = csvDefaultIndex
+			} else if index, err = csvHeaderIndex(rec); err != nil {
+				return nil, fmt.Errorf("%s: %v", path, err)
+			} else {
+				isHeader = true
+			}
+			for _, i := range index {
+				if i > maxIndex {
+					maxIndex = i
+				}
+			}
+			if isHeader {
+				continue
+			}
+		}
+		if len(rec) <= maxIndex {
+			return nil, fmt.Errorf("%s 第 %d 行: 列数不足（%d 列，需要至少 %d 列）", path, line, len(rec), maxIndex+1)
+		}
+		var v [6]string
+		for i := range v {
+			v[i] = strings.TrimSpace(rec[index[i]])
+		}
+		if isCSVNull(v[1:]) {
+			continue
+		}
+		date, err := parseCSVDate(strings.TrimPrefix(v[0], "\ufeff"))
+		if err != nil {
+			return nil, fmt.Errorf("%s 第 %d 行: %v", path, line, err)
+		}
+		var nums [5]float64
+		for i := range nums {
+			if nums[i], err = strconv.ParseFloat(strings.ReplaceAll(v[i+1], ",", ""), 64); err != nil {
+				return nil, fmt.Errorf("%s 第 %d 行 %s 列: %v", path, line, csvColumns[i+1].name, err)
+			}
+		}
+		list = append(list, StockData{Date: date, Open: nums[0], High: nums[1], Low: nums[2], Close: nums[3], Volume: nums[4]})
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("%s 中没有K线数据", path)
+	}
+	return list, nil
+}
+
+// isCSVNull 数值列为 null 或空（Yahoo CSV 的休市日）
+func isCSVNull(values []string) bool {
+	for _, v := range values {
+		if v == "" || strings.EqualFold(v, "null") {
+			return true
+		}
+	}
+	return false
+}
+
+// CSVStockCodes 列出目录下所有 {code}.csv 对应的股票代码（按代码排序）
+func CSVStockCodes(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.csv"))
+	if err != nil {
+		return nil, err
+	}
+	codes := make([]string, 0, len(files))
+	for _, f := range files {
+		codes = append(codes, strings.TrimSuffix(filepath.Base(f), filepath.Ext(f)))
+	}
+	sort.Strings(codes)
+	return codes, nil
+}
+
+// LoadCSVDir 批量读取目录下全部 {code}.csv，返回代码到K线的映射；任一文件解析失败即返回错误
+func LoadCSVDir(dir string) (map[string][]StockData, error) {
+	codes, err := CSVStockCodes(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(codes) == 0 {
+		return nil, fmt.Errorf("目录 %s 下没有 CSV 文件", dir)
+	}
+	all := make(map[string][]StockData, len(codes))
+	for _, code := range codes {
+		data, err := FetchCSVKlines(filepath.Join(dir, code+".csv"))
+		if err != nil {
+			return nil, err
+		}
+		all[code] = data
+	}
+	return all, nil
+}
+
+// FetchStockHistoryFromCSV 从 dir/{code}.csv 读取行情，经过与网络数据相同的校验、指标计算与区间裁剪
+func FetchStockHistoryFromCSV(dir, stockCode, start, end string) ([]StockData, []TechnicalIndicator, DataSourceInfo, error) {
+	if dir == "" {
+		dir = DefaultCSVDir
+	}
+	path := filepath.Join(dir, fileNameCode(stockCode)+".csv")
+	raw, err := FetchCSVKlines(path)
+	if err != nil {
+		return nil, nil, DataSourceInfo{Level: DataLevelLLM}, err
+	}
+	fi, _ := os.Stat(path)
+	log.Printf("[数据源] ✓ 从 %s 读取 %d 条数据\n", path, len(raw))
+	stockData, indicators := prepareStockData(raw, stockCode)
+	if len(stockData) == 0 {
+		return nil, nil, DataSourceInfo{Level: DataLevelLLM}, fmt.Errorf("%s 数据校验后无有效行情", path)
+	}
+	stockData, indicators, warmup, err := clipToDateRange(stockData, indicators, start, end)
+	if err != nil {
+		return nil, nil, DataSourceInfo{Level: DataLevelLLM}, err
+	}
+	info := DataSourceInfo{Level: DataLevelCSV, Source: path, LatestDate: stockData[len(stockData)-1].Date, WarmupBars: warmup}
+	if fi != nil {
+		info.FetchedAt = fi.ModTime()
+	}
+	return stockData, indicators, info, nil
+}
+
+// fetchStockHistory 按 DataSource 选择行情来源，csv 模式不访问任何行情接口
+func (p AnalysisParams) fetchStockHistory(ctx context.Context) ([]StockData, []TechnicalIndicator, DataSourceInfo, error) {
+	switch p.DataSource {
+	case DataSourceOnline, "":
+		return FetchStockHistoryWithFallbackContext(ctx, p.StockCodes[0], p.Start, p.End, p.APIKey)
+	case DataSourceCSV:
+		return FetchStockHistoryFromCSV(p.CSVDir, p.StockCodes[0], p.Start, p.End)
+	default:
+		return nil, nil, DataSourceInfo{Level: DataLevelLLM}, fmt.Errorf("未知数据源模式: %s（可选 online/csv）", p.DataSource)
+	}
+}
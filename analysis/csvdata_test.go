@@ -0,0 +1,117 @@
+package analysis
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingTransport 记录行情请求次数并直接失败
+type countingTransport struct{ calls int32 }
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&c.calls, 1)
+	return failingTransport{}.RoundTrip(req)
+}
+
+func TestLoadCSVDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCSV(t, filepath.Join(dir, "600036.csv"), 40)
+	writeTestCSV(t, filepath.Join(dir, "AAPL.csv"), 25)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("忽略"), 0644)
+
+	codes, err := CSVStockCodes(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"600036", "AAPL"}; !reflect.DeepEqual(codes, want) {
+		t.Errorf("代码 = %v，期望 %v", codes, want)
+	}
+	all, err := LoadCSVDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 || len(all["600036"]) != 40 || len(all["AAPL"]) != 25 {
+		t.Errorf("批量读取条数不符: 600036=%d AAPL=%d", len(all["600036"]), len(all["AAPL"]))
+	}
+
+	os.WriteFile(filepath.Join(dir, "BAD.csv"), []byte("date,open\n2024-01-02,1\n"), 0644)
+	if _, err := LoadCSVDir(dir); err == nil {
+		t.Error("目录中有无法解析的文件时应返回错误")
+	}
+	if _, err := LoadCSVDir(t.TempDir()); err == nil {
+		t.Error("空目录应返回错误")
+	}
+}
+
+func TestCSVDataSourceSkipsNetwork(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCSV(t, filepath.Join(dir, "600036.csv"), 60)
+	ct := &countingTransport{}
+	oldTransport := FetchTransport
+	FetchTransport = ct
+	defer func() { FetchTransport = oldTransport }()
+
+	params := AnalysisParams{StockCodes: []string{"600036"}, DataSource: DataSourceCSV, CSVDir: dir}
+	data, _, info, err := params.fetchStockHistory(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 60 || info.Level != DataLevelCSV {
+		t.Errorf("读取 %d 条、级别 %v，期望 60 条 CSV 数据", len(data), info.Level)
+	}
+	if n := atomic.LoadInt32(&ct.calls); n != 0 {
+		t.Errorf("csv 模式不应访问网络，发起了 %d 次请求", n)
+	}
+	params.StockCodes = []string{"000001"}
+	if _, _, _, err := params.fetchStockHistory(context.Background()); err == nil || atomic.LoadInt32(&ct.calls) != 0 {
+		t.Errorf("缺少 CSV 文件时应报错且不回退网络，err=%v", err)
+	}
+}
+
+func TestFetchCSVKlinesHeaderVariants(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	want := StockData{Open: 10, High: 11, Low: 9.5, Close: 10.5, Volume: 12345}
+
+	for _, c := range []struct{ name, content string }{
+		{"乱序表头", "Volume,Close,DATE,low,High,open\n12345,10.5,2024-01-02,9.5,11,10\n"},
+		{"Yahoo Adj Close", "Date,Open,High,Low,Close,Adj Close,Volume\n2024-01-02,10,11,9.5,10.5,10.2,12345\n2024-01-03,null,null,null,null,null,null\n"},
+		{"中文表头", "\ufeff日期,开盘,最高,最低,收盘,成交量\n2024/01/02,10,11,9.5,10.5,\"12,345\"\n"},
+		{"无表头", "2024-01-02,10,11,9.5,10.5,12345\n"},
+	} {
+		data, err := FetchCSVKlines(write(c.name+".csv", c.content))
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if len(data) != 1 {
+			t.Errorf("%s: 读取 %d 条，期望 1 条（null 行跳过）", c.name, len(data))
+			continue
+		}
+		got := data[0]
+		if got.Date.Format("2006-01-02") != "2024-01-02" || got.Open != want.Open || got.High != want.High ||
+			got.Low != want.Low || got.Close != want.Close || got.Volume != want.Volume {
+			t.Errorf("%s: 得到 %+v", c.name, got)
+		}
+	}
+
+	_, err := FetchCSVKlines(write("missing.csv", "date,open,close\n2024-01-02,10,10.5\n"))
+	if err == nil || !strings.Contains(err.Error(), "high,low,volume") {
+		t.Errorf("缺列时应列出全部缺失列，得到 %v", err)
+	}
+	if _, err := FetchCSVKlines(write("short.csv", "2024-01-02,10,11,9.5,10.5\n")); err == nil {
+		t.Error("无表头且列数不足时应报错而非越界")
+	}
+}
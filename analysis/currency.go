@@ -0,0 +1,141 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BaseCurrency 本币，外币标的价格会附带折算
+var BaseCurrency = "CNY"
+
+// FXRateURL 汇率接口，%s 为原币代码，需返回 {"rates": {"CNY": 7.1, ...}} 结构
+var FXRateURL = "https://open.er-api.com/v6/latest/%s"
+
+// FXCacheTTL 汇率缓存有效期
+var FXCacheTTL = 6 * time.Hour
+
+type fxCacheEntry struct {
+	Rate      float64   `json:"rate"`
+	FetchedAt time.Time `json:"fetched_at"`
+}
+
+var (
+	fxMu    sync.Mutex
+	fxCache = make(map[string]fxCacheEntry)
+)
+
+// StockCurrency 标的计价币种：A股 CNY，港股 HKD，美股 USD
+func StockCurrency(stockCode string) string {
+	switch StockMarket(stockCode) {
+	case "A股":
+		return "CNY"
+	case "港股":
+		return "HKD"
+	default:
+		return "USD"
+	}
+}
+
+func fxCachePath(from, to string) string {
+	return filepath.Join(DataCacheDir, fmt.Sprintf("fx-%s-%s.json", from, to))
+}
+
+// GetFXRate 获取 from→to 汇率，优先使用未过期的内存/本地缓存，接口失败时返回错误
+func GetFXRate(from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return 1, nil
+	}
+	key := from + "-" + to
+
+	fxMu.Lock()
+	defer fxMu.Unlock()
+	if e, ok := fxCache[key]; ok && time.Since(e.FetchedAt) < FXCacheTTL {
+		return e.Rate, nil
+	}
+	if data, err := ioutil.ReadFile(fxCachePath(from, to)); err == nil {
+		var e fxCacheEntry
+		if json.Unmarshal(data, &e) == nil && e.Rate > 0 && time.Since(e.FetchedAt) < FXCacheTTL {
+			fxCache[key] = e
+			return e.Rate, nil
+		}
+	}
+
+	rate, err := fetchFXRate(from, to)
+	if err != nil {
+		return 0, err
+	}
+	e := fxCacheEntry{Rate: rate, FetchedAt: time.Now()}
+	fxCache[key] = e
+	if data, err := json.Marshal(e); err == nil && os.MkdirAll(DataCacheDir, 0755) == nil {
+		ioutil.WriteFile(fxCachePath(from, to), data, 0644)
+	}
+	return rate, nil
+}
+
+// fetchFXRate 请求汇率接口
+func fetchFXRate(from, to string) (float64, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(fmt.Sprintf(FXRateURL, from))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("汇率接口返回状态码 %d", resp.StatusCode)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	return parseFXRate(body, to)
+}
+
+// parseFXRate 从汇率接口返回中取目标币种汇率
+func parseFXRate(body []byte, to string) (float64, error) {
+	var data struct {
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return 0, fmt.Errorf("解析汇率失败: %v", err)
+	}
+	rate, ok := data.Rates[to]
+	if !ok || rate <= 0 {
+		return 0, fmt.Errorf("汇率接口未返回 %s 汇率", to)
+	}
+	return rate, nil
+}
+
+// FormatPriceWithLocal 原币价格附带本币折算，如 “190.50 USD（≈1356.36 CNY）”；rate<=0 或同币种时仅显示原币
+func FormatPriceWithLocal(price float64, currency string, rate float64) string {
+	if rate <= 0 || strings.EqualFold(currency, BaseCurrency) {
+		return fmt.Sprintf("%s %s", FormatPrice(price), currency)
+	}
+	return fmt.Sprintf("%s %s（≈%s %s）", FormatPrice(price), currency, FormatPrice(price*rate), BaseCurrency)
+}
+
+// CurrencyNotice 外币标的的报告折算说明，本币标的或汇率获取失败时仅显示原币
+func CurrencyNotice(stockCode string, currentPrice, targetPrice float64) string {
+	currency := StockCurrency(stockCode)
+	if strings.EqualFold(currency, BaseCurrency) || currentPrice <= 0 {
+		return ""
+	}
+	rate, err := GetFXRate(currency, BaseCurrency)
+	if err != nil {
+		log.Printf("[汇率] 获取 %s/%s 汇率失败，仅显示原币: %v\n", currency, BaseCurrency, err)
+		rate = 0
+	}
+	msg := "\n> 【价格折算】最新收盘价 " + FormatPriceWithLocal(currentPrice, currency, rate)
+	if targetPrice > 0 {
+		msg += "，目标价 " + FormatPriceWithLocal(targetPrice, currency, rate)
+	}
+	if rate > 0 {
+		msg += fmt.Sprintf("，汇率 1 %s = %.4f %s", currency, rate, BaseCurrency)
+	}
+	return msg + "\n"
+}
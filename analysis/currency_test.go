@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// useFXServer 以本地服务替换汇率接口并清空汇率缓存，返回请求计数
+func useFXServer(t *testing.T, handler http.HandlerFunc) *int {
+	t.Helper()
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	oldURL, oldDir := FXRateURL, DataCacheDir
+	FXRateURL, DataCacheDir = srv.URL+"/latest/%s", t.TempDir()
+	resetFXCache := func() {
+		fxMu.Lock()
+		fxCache = make(map[string]fxCacheEntry)
+		fxMu.Unlock()
+	}
+	resetFXCache()
+	t.Cleanup(func() {
+		FXRateURL, DataCacheDir = oldURL, oldDir
+		resetFXCache()
+	})
+	return &calls
+}
+
+func TestFormatPriceWithLocal(t *testing.T) {
+	for _, c := range []struct {
+		price, rate float64
+		currency    string
+		want        string
+	}{
+		{190.5, 7.12, "USD", "190.50 USD（≈1356.36 CNY）"},
+		{320, 0.91, "HKD", "320.00 HKD（≈291.20 CNY）"},
+		{190.5, 0, "USD", "190.50 USD"},
+		{35.2, 1, "cny", "35.20 cny"},
+	} {
+		if got := FormatPriceWithLocal(c.price, c.currency, c.rate); got != c.want {
+			t.Errorf("FormatPriceWithLocal(%v, %s, %v) = %q，期望 %q", c.price, c.currency, c.rate, got, c.want)
+		}
+	}
+}
+
+func TestCurrencyNoticeConvertsAndCaches(t *testing.T) {
+	calls := useFXServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/latest/USD" {
+			t.Errorf("请求路径 = %s", r.URL.Path)
+		}
+		w.Write([]byte(`{"result":"success","rates":{"USD":1,"CNY":7.1}}`))
+	})
+	notice := CurrencyNotice("AAPL", 200, 220)
+	for _, want := range []string{"200.00 USD（≈1420.00 CNY）", "目标价 220.00 USD（≈1562.00 CNY）", "1 USD = 7.1000 CNY"} {
+		if !strings.Contains(notice, want) {
+			t.Errorf("折算说明缺少 %q: %q", want, notice)
+		}
+	}
+	CurrencyNotice("MSFT", 400, 0)
+	if *calls != 1 {
+		t.Errorf("缓存有效期内应只请求一次汇率接口，实际 %d 次", *calls)
+	}
+	// 内存缓存清空后从本地缓存文件读取
+	fxMu.Lock()
+	fxCache = make(map[string]fxCacheEntry)
+	fxMu.Unlock()
+	if rate, err := GetFXRate("usd", "cny"); err != nil || rate != 7.1 || *calls != 1 {
+		t.Errorf("应读取本地汇率缓存: rate=%v err=%v 请求 %d 次", rate, err, *calls)
+	}
+	if CurrencyNotice("600036", 35, 40) != "" {
+		t.Error("本币标的不应附带折算")
+	}
+}
+
+func TestCurrencyNoticeFallsBackToOriginal(t *testing.T) {
+	useFXServer(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+	})
+	notice := CurrencyNotice("00700", 320, 0)
+	if !strings.Contains(notice, "最新收盘价 320.00 HKD") || strings.Contains(notice, "CNY") {
+		t.Errorf("汇率接口失败时应仅显示原币: %q", notice)
+	}
+	if _, err := parseFXRate([]byte(`{"rates":{"USD":1}}`), "CNY"); err == nil {
+		t.Error("缺少目标币种时应返回错误")
+	}
+}
@@ -1,11 +1,31 @@
 package analysis
 
-// DeepSeekConfig 用于存储API Key和API地址
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DeepSeekConfig 用于存储API地址与默认模型
 var (
-	DeepSeekAPIURL = "https://openrouter.ai/api/v1/chat/completions" // 可配置
-	DeepSeekModel  = "deepseek/deepseek-r1:free"                     // 可配置
+	DeepSeekBaseURL = "https://api.deepseek.com/v1" // OpenAI 兼容接口前缀，可指向自建代理或 https://openrouter.ai/api/v1
+	DeepSeekModel   = "deepseek/deepseek-r1:free"   // 可配置
 )
 
+// ChatCompletionsURL 基于 base 拼接 chat/completions 地址
+func ChatCompletionsURL(base string) string {
+	return strings.TrimRight(base, "/") + "/chat/completions"
+}
+
+// ModelsURL 基于 base 拼接模型列表地址
+func ModelsURL(base string) string {
+	return strings.TrimRight(base, "/") + "/models"
+}
+
 type deepSeekRequest struct {
 	Model    string        `json:"model"`
 	Messages []deepMessage `json:"messages"`
@@ -23,3 +43,82 @@ type deepSeekResponse struct {
 		} `json:"message"`
 	} `json:"choices"`
 }
+
+// DeepSeekBalanceURL 账户余额查询接口
+var DeepSeekBalanceURL = "https://api.deepseek.com/user/balance"
+
+// MinBalanceWarning 余额低于该值（账户币种）时提前警告
+var MinBalanceWarning = 1.0
+
+// BalanceInfo DeepSeek 账户余额
+type BalanceInfo struct {
+	Supported bool    // 接口是否支持余额查询，不支持时其余字段无意义
+	Available bool    // 余额是否足够调用 API
+	Currency  string  // 币种，如 CNY/USD
+	Total     float64 // 可用总余额
+}
+
+// CheckBalance 查询 DeepSeek 账户余额；接口不存在（如使用 OpenRouter 等兼容服务）时返回 Supported=false 且不报错
+func CheckBalance(apiKey string) (BalanceInfo, error) {
+	req, err := http.NewRequest("GET", DeepSeekBalanceURL, nil)
+	if err != nil {
+		return BalanceInfo{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Accept", "application/json")
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return BalanceInfo{}, err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return parseBalance(body)
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return BalanceInfo{}, nil
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return BalanceInfo{}, fmt.Errorf("API Key 无效或无权限查询余额（状态码 %d）", resp.StatusCode)
+	default:
+		return BalanceInfo{}, fmt.Errorf("余额查询失败，状态码 %d", resp.StatusCode)
+	}
+}
+
+// parseBalance 解析余额接口返回，多币种时取第一个
+func parseBalance(body []byte) (BalanceInfo, error) {
+	var data struct {
+		IsAvailable  bool `json:"is_available"`
+		BalanceInfos []struct {
+			Currency     string `json:"currency"`
+			TotalBalance string `json:"total_balance"`
+		} `json:"balance_infos"`
+	}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return BalanceInfo{}, fmt.Errorf("解析余额失败: %v", err)
+	}
+	info := BalanceInfo{Supported: true, Available: data.IsAvailable}
+	if len(data.BalanceInfos) > 0 {
+		info.Currency = data.BalanceInfos[0].Currency
+		total, err := strconv.ParseFloat(data.BalanceInfos[0].TotalBalance, 64)
+		if err != nil {
+			return BalanceInfo{}, fmt.Errorf("解析余额失败: %v", err)
+		}
+		info.Total = total
+	}
+	return info, nil
+}
+
+// Warning 余额不可用或低于 MinBalanceWarning 时返回提示，否则为空
+func (b BalanceInfo) Warning() string {
+	if !b.Supported {
+		return ""
+	}
+	if !b.Available {
+		return fmt.Sprintf("账户余额不足（%.2f %s），API 调用将失败，请先充值", b.Total, b.Currency)
+	}
+	if b.Total < MinBalanceWarning {
+		return fmt.Sprintf("账户余额仅剩 %.2f %s，低于 %.2f，分析可能中途失败", b.Total, b.Currency, MinBalanceWarning)
+	}
+	return ""
+}
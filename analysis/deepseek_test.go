@@ -0,0 +1,102 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestChatURLFromConfiguredBase(t *testing.T) {
+	old := DeepSeekBaseURL
+	defer func() { DeepSeekBaseURL = old }()
+
+	DeepSeekBaseURL = "https://openrouter.ai/api/v1/"
+	if got := (AnalysisParams{}).ChatURL(); got != "https://openrouter.ai/api/v1/chat/completions" {
+		t.Errorf("默认 chat 地址 = %q", got)
+	}
+	if got := ModelsURL(DeepSeekBaseURL); got != "https://openrouter.ai/api/v1/models" {
+		t.Errorf("模型列表地址 = %q", got)
+	}
+	if got := (AnalysisParams{APIURL: "http://proxy.local/chat"}).ChatURL(); got != "http://proxy.local/chat" {
+		t.Errorf("APIURL 应优先于 DeepSeekBaseURL，得到 %q", got)
+	}
+}
+
+func TestGenerateAIReportHitsConfiguredBase(t *testing.T) {
+	var gotPath, gotAuth, gotModel string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotAuth = r.URL.Path, r.Header.Get("Authorization")
+		var body struct {
+			Model string `json:"model"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		gotModel = body.Model
+		fmt.Fprint(w, `{"choices":[{"message":{"content":"报告正文"}}]}`)
+	}))
+	defer srv.Close()
+
+	old := DeepSeekBaseURL
+	defer func() { DeepSeekBaseURL = old }()
+	DeepSeekBaseURL = srv.URL + "/proxy/v1"
+
+	params := AnalysisParams{Model: "my-model"}
+	report, err := GenerateAIReportWithContext(context.Background(), "600036", "prompt", "sk-test", params.ChatURL(), params.Model, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != "报告正文" {
+		t.Errorf("报告 = %q", report)
+	}
+	if gotPath != "/proxy/v1/chat/completions" || gotAuth != "Bearer sk-test" || gotModel != "my-model" {
+		t.Errorf("请求 path=%q auth=%q model=%q，期望打到配置的代理地址", gotPath, gotAuth, gotModel)
+	}
+}
+
+func TestCheckBalanceWarnsWhenLow(t *testing.T) {
+	var status int
+	var body, auth string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+	old := DeepSeekBalanceURL
+	DeepSeekBalanceURL = srv.URL + "/user/balance"
+	defer func() { DeepSeekBalanceURL = old }()
+
+	for _, c := range []struct {
+		name, body string
+		warn       string
+	}{
+		{"充足", `{"is_available":true,"balance_infos":[{"currency":"CNY","total_balance":"110.00"}]}`, ""},
+		{"偏低", `{"is_available":true,"balance_infos":[{"currency":"CNY","total_balance":"0.35"}]}`, "账户余额仅剩 0.35 CNY，低于 1.00"},
+		{"耗尽", `{"is_available":false,"balance_infos":[{"currency":"USD","total_balance":"0.00"}]}`, "账户余额不足（0.00 USD）"},
+	} {
+		status, body = http.StatusOK, c.body
+		info, err := CheckBalance("sk-test")
+		if err != nil || !info.Supported {
+			t.Fatalf("%s: info=%+v err=%v", c.name, info, err)
+		}
+		if w := info.Warning(); (c.warn == "") != (w == "") || !strings.Contains(w, c.warn) {
+			t.Errorf("%s: 提示 = %q，期望包含 %q", c.name, w, c.warn)
+		}
+	}
+	if auth != "Bearer sk-test" {
+		t.Errorf("Authorization = %q", auth)
+	}
+
+	// 兼容服务没有余额接口时优雅跳过
+	status, body = http.StatusNotFound, "not found"
+	if info, err := CheckBalance("sk-test"); err != nil || info.Supported || info.Warning() != "" {
+		t.Errorf("接口不支持时应跳过: info=%+v err=%v", info, err)
+	}
+	status = http.StatusUnauthorized
+	if _, err := CheckBalance("sk-bad"); err == nil || !strings.Contains(err.Error(), "API Key 无效") {
+		t.Errorf("无效 Key 应返回错误: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 背离类型
+const (
+	DivergenceTop    = "顶背离"
+	DivergenceBottom = "底背离"
+)
+
+// divergencePivotWindow 拐点判定窗口：收盘价为前后各 N 根K线内的最高（低）时视为波峰（谷）
+const divergencePivotWindow = 3
+
+// DivergenceLookback 只检测最近 N 根K线内的背离，更早的背离对当前走势参考意义不大
+var DivergenceLookback = 60
+
+// Divergence 一次价格与指标的背离
+type Divergence struct {
+	Date      time.Time `json:"date"`      // 第二个拐点日期，即背离确认日
+	PrevDate  time.Time `json:"prev_date"` // 用于比较的前一个拐点日期
+	Type      string    `json:"type"`      // 顶背离/底背离
+	Indicator string    `json:"indicator"` // MACD/RSI
+	Price     float64   `json:"price"`
+	PrevPrice float64   `json:"prev_price"`
+	Value     float64   `json:"value"` // 背离确认日的指标值
+	PrevValue float64   `json:"prev_value"`
+}
+
+// divergenceIndicators 参与背离检测的指标：MACD 取 DIF 线，RSI 取 12 日
+var divergenceIndicators = []struct {
+	name  string
+	value func(TechnicalIndicator) float64
+}{
+	{"MACD", func(t TechnicalIndicator) float64 { return t.MACD }},
+	{"RSI", func(t TechnicalIndicator) float64 { return t.RSI12 }},
+}
+
+// pricePivots 返回收盘价波峰（high=true）或波谷的下标
+func pricePivots(stockData []StockData, from int, high bool) []int {
+	var pivots []int
+	for i := from; i < len(stockData); i++ {
+		if i < divergencePivotWindow || i+divergencePivotWindow >= len(stockData) {
+			continue
+		}
+		c := stockData[i].Close
+		pivot := true
+		for j := i - divergencePivotWindow; j <= i+divergencePivotWindow && pivot; j++ {
+			if j == i {
+				continue
+			}
+			if (high && stockData[j].Close >= c) || (!high && stockData[j].Close <= c) {
+				pivot = false
+			}
+		}
+		if pivot {
+			pivots = append(pivots, i)
+		}
+	}
+	return pivots
+}
+
+// DetectDivergence 识别最近 DivergenceLookback 根K线内相邻两个价格拐点与 MACD/RSI 的背离：
+// 价格创新高而指标未创新高为顶背离，价格创新低而指标未创新低为底背离。
+// indicators 须与 stockData 按下标对齐，指标未完成预热（为 0）的拐点不参与比较；结果按日期升序
+func DetectDivergence(stockData []StockData, indicators []TechnicalIndicator) []Divergence {
+	if len(indicators) != len(stockData) || len(stockData) < 2*divergencePivotWindow+2 {
+		return nil
+	}
+	from := 0
+	if DivergenceLookback > 0 && len(stockData) > DivergenceLookback {
+		from = len(stockData) - DivergenceLookback
+	}
+	var list []Divergence
+	for _, high := range []bool{true, false} {
+		pivots := pricePivots(stockData, from, high)
+		for k := 1; k < len(pivots); k++ {
+			a, b := pivots[k-1], pivots[k]
+			pa, pb := stockData[a].Close, stockData[b].Close
+			if (high && pb <= pa) || (!high && pb >= pa) {
+				continue
+			}
+			for _, ind := range divergenceIndicators {
+				va, vb := ind.value(indicators[a]), ind.value(indicators[b])
+				if va == 0 || vb == 0 {
+					continue
+				}
+				d := Divergence{Date: stockData[b].Date, PrevDate: stockData[a].Date, Indicator: ind.name,
+					Price: pb, PrevPrice: pa, Value: vb, PrevValue: va}
+				switch {
+				case high && vb < va:
+					d.Type = DivergenceTop
+				case !high && vb > va:
+					d.Type = DivergenceBottom
+				default:
+					continue
+				}
+				list = append(list, d)
+			}
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Date.Before(list[j].Date) })
+	return list
+}
+
+// FormatDivergenceNotice 背离提示（按 lang 输出），注入 prompt 并置于报告数据区；无背离时返回空
+func FormatDivergenceNotice(list []Divergence, lang string) string {
+	if len(list) == 0 {
+		return ""
+	}
+	w := wrapperFor(lang)
+	var items []string
+	for _, d := range list {
+		items = append(items, fmt.Sprintf(w.divergenceItem,
+			d.Date.Format("2006-01-02"), d.Indicator, localize(d.Type, lang), d.PrevPrice, d.Price, d.Indicator, d.PrevValue, d.Value))
+	}
+	return fmt.Sprintf(w.divergence, strings.Join(items, w.itemSep))
+}
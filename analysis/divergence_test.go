@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// barsFromCloses 以收盘价序列构造逐日K线，开高低与收盘相同
+func barsFromCloses(closes []float64) []StockData {
+	data := make([]StockData, len(closes))
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)
+	for i, c := range closes {
+		data[i] = StockData{Date: day.AddDate(0, 0, i), Open: c, High: c, Low: c, Close: c, Volume: 1000}
+	}
+	return data
+}
+
+// topDivergenceCloses 横盘预热后急涨见顶，回落后缓涨创新高再回落：价格新高、动能减弱
+func topDivergenceCloses() []float64 {
+	var closes []float64
+	add := func(from, to float64, n int) {
+		for i := 1; i <= n; i++ {
+			closes = append(closes, from+(to-from)*float64(i)/float64(n))
+		}
+	}
+	add(10, 10, 40)
+	add(10, 14, 8)
+	add(14, 12.5, 5)
+	for i := 0; i < 12; i++ { // 缓涨且涨跌交替，RSI 走弱
+		step := 0.45
+		if i%2 == 1 {
+			step = -0.15
+		}
+		closes = append(closes, closes[len(closes)-1]+step)
+	}
+	add(14.2, 13, 6)
+	return closes
+}
+
+func TestDetectDivergenceTop(t *testing.T) {
+	data := barsFromCloses(topDivergenceCloses())
+	list := DetectDivergence(data, calculateTechnicalIndicators(data))
+	if len(list) != 2 {
+		t.Fatalf("应识别出 MACD 与 RSI 两个顶背离，得到 %+v", list)
+	}
+	for i, want := range []string{"MACD", "RSI"} {
+		d := list[i]
+		if d.Type != DivergenceTop || d.Indicator != want || !d.Date.Equal(data[63].Date) || !d.PrevDate.Equal(data[47].Date) {
+			t.Errorf("第 %d 个背离 = %+v", i+1, d)
+		}
+		if d.Price <= d.PrevPrice || d.Value >= d.PrevValue {
+			t.Errorf("顶背离应为价格新高、指标走低: %+v", d)
+		}
+	}
+}
+
+func TestDetectDivergenceBottom(t *testing.T) {
+	closes := topDivergenceCloses()
+	for i := range closes {
+		closes[i] = 30 - closes[i] // 镜像为急跌见底、缓跌创新低
+	}
+	data := barsFromCloses(closes)
+	list := DetectDivergence(data, calculateTechnicalIndicators(data))
+	if len(list) == 0 {
+		t.Fatal("应识别出底背离")
+	}
+	for _, d := range list {
+		if d.Type != DivergenceBottom || !d.Date.Equal(data[63].Date) || d.Price >= d.PrevPrice || d.Value <= d.PrevValue {
+			t.Errorf("底背离应为价格新低、指标抬高: %+v", d)
+		}
+	}
+}
+
+func TestDetectDivergenceSkipsWarmupAndOldPivots(t *testing.T) {
+	// 波峰在下标 5、15、25，价格逐个抬高，指标逐个走低
+	closes := make([]float64, 32)
+	indicators := make([]TechnicalIndicator, len(closes))
+	for i := range closes {
+		closes[i] = 10
+	}
+	for k, i := range []int{5, 15, 25} {
+		closes[i] = 11 + float64(k)
+		indicators[i] = TechnicalIndicator{MACD: 0.9 - 0.1*float64(k), RSI12: 80 - 5*float64(k)}
+	}
+	indicators[5] = TechnicalIndicator{} // 指标未预热
+	data := barsFromCloses(closes)
+	list := DetectDivergence(data, indicators)
+	if len(list) != 2 || !list[0].Date.Equal(data[25].Date) || !list[0].PrevDate.Equal(data[15].Date) {
+		t.Errorf("指标为 0 的拐点不应参与比较: %+v", list)
+	}
+
+	old := DivergenceLookback
+	defer func() { DivergenceLookback = old }()
+	DivergenceLookback = 10
+	if list := DetectDivergence(data, indicators); len(list) != 0 {
+		t.Errorf("回看窗口外的拐点不应参与比较: %+v", list)
+	}
+	if DetectDivergence(data, indicators[1:]) != nil {
+		t.Error("指标与K线未对齐时应返回空")
+	}
+}
+
+func TestFormatDivergenceNotice(t *testing.T) {
+	data := barsFromCloses(topDivergenceCloses())
+	notice := FormatDivergenceNotice(DetectDivergence(data, calculateTechnicalIndicators(data)), "")
+	for _, want := range []string{data[63].Date.Format("2006-01-02"), "MACD", "RSI", "顶背离"} {
+		if !strings.Contains(notice, want) {
+			t.Errorf("背离提示缺少 %q:\n%s", want, notice)
+		}
+	}
+	if FormatDivergenceNotice(nil, "") != "" {
+		t.Error("无背离时不应输出提示")
+	}
+}
@@ -3,28 +3,141 @@ package analysis
 import (
 	"bytes"
 	"crypto/tls"
+	"encoding/base64"
 	"fmt"
+	"html"
 	"io"
 	"mime"
 	"mime/multipart"
 	"mime/quotedprintable"
+	"net"
 	"net/smtp"
 	"net/textproto"
 	"os"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
-// 发送邮件（支持附件）
+// SMTP 连接加密方式
+const (
+	SMTPTLSAuto     = "auto"     // 按端口选择：465 隐式 TLS，25 明文，其他端口 STARTTLS
+	SMTPTLSImplicit = "tls"      // 建连即 TLS（SMTPS）
+	SMTPTLSStartTLS = "starttls" // 明文建连后 STARTTLS 升级，服务器不支持时报错
+	SMTPTLSNone     = "none"     // 明文，不加密
+)
+
+// SMTPTLSMode 当前 SMTP 加密方式，默认按端口自动选择
+var SMTPTLSMode = SMTPTLSAuto
+
+// SMTPInsecureSkipVerify 为 true 时不校验 SMTP 服务器证书（仅用于自签名证书的内网服务器），默认校验
+var SMTPInsecureSkipVerify = false
+
+// smtpDialTimeout SMTP 建连超时
+const smtpDialTimeout = 30 * time.Second
+
+// smtpTLSMode 解析端口实际使用的加密方式
+func smtpTLSMode(port int) string {
+	if SMTPTLSMode != SMTPTLSAuto && SMTPTLSMode != "" {
+		return SMTPTLSMode
+	}
+	switch port {
+	case 465:
+		return SMTPTLSImplicit
+	case 25:
+		return SMTPTLSNone
+	default:
+		return SMTPTLSStartTLS
+	}
+}
+
+// dialSMTP 按加密方式连接 SMTP 服务器
+func dialSMTP(host string, port int) (*smtp.Client, error) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	tlsconfig := &tls.Config{ServerName: host, InsecureSkipVerify: SMTPInsecureSkipVerify}
+	mode := smtpTLSMode(port)
+	var conn net.Conn
+	var err error
+	switch mode {
+	case SMTPTLSImplicit:
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: smtpDialTimeout}, "tcp", addr, tlsconfig)
+	case SMTPTLSStartTLS, SMTPTLSNone:
+		conn, err = net.DialTimeout("tcp", addr, smtpDialTimeout)
+	default:
+		return nil, fmt.Errorf("未知 SMTP 加密方式: %s（可选 auto/tls/starttls/none）", mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if mode == SMTPTLSStartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			c.Close()
+			return nil, fmt.Errorf("SMTP 服务器 %s 不支持 STARTTLS，可改用 -smtp-tls tls/none", addr)
+		}
+		if err := c.StartTLS(tlsconfig); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// 发送邮件（支持附件），正文为纯文本
 func SendEmail(smtpServer string, smtpPort int, user, pass string, to []string, subject, body string, attachPaths []string) error {
+	return SendEmailWithContentType(smtpServer, smtpPort, user, pass, to, subject, body, "text/plain", attachPaths)
+}
+
+// SendEmailWithContentType 发送邮件，contentType 为 text/html 时正文以 multipart/alternative 发送（附纯文本版本），
+// HTML 中引用的本地 PNG 图表作为 cid 内联图片嵌入
+func SendEmailWithContentType(smtpServer string, smtpPort int, user, pass string, to []string, subject, body, contentType string, attachPaths []string) error {
 	host := smtpServer
-	addr := fmt.Sprintf("%s:%d", smtpServer, smtpPort)
+	msg := buildEmailMessage(user, to, subject, body, contentType, attachPaths)
+	c, err := dialSMTP(host, smtpPort)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if pass != "" {
+		if err = c.Auth(smtp.PlainAuth("", user, pass, host)); err != nil {
+			return err
+		}
+	}
+	if err = c.Mail(user); err != nil {
+		return err
+	}
+	for _, addr := range to {
+		if err = c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(msg)
+	if err != nil {
+		return err
+	}
+	w.Close()
+	c.Quit()
+	return nil
+}
+
+// buildEmailMessage 组装 MIME 邮件：multipart/mixed 下依次为正文与附件
+func buildEmailMessage(from string, to []string, subject, body, contentType string, attachPaths []string) []byte {
 	msg := bytes.NewBuffer(nil)
 	writer := multipart.NewWriter(msg)
 	boundary := writer.Boundary()
 	// 邮件头
 	headers := make(map[string]string)
-	headers["From"] = user
+	headers["From"] = from
 	headers["To"] = strings.Join(to, ", ")
 	headers["Subject"] = mime.QEncoding.Encode("utf-8", subject)
 	headers["MIME-Version"] = "1.0"
@@ -34,12 +147,11 @@ func SendEmail(smtpServer string, smtpPort int, user, pass string, to []string,
 	}
 	fmt.Fprintf(msg, "\r\n")
 	// 正文
-	bodyHeader := make(textproto.MIMEHeader)
-	bodyHeader.Set("Content-Type", "text/plain; charset=utf-8")
-	bodyWriter, _ := writer.CreatePart(bodyHeader)
-	qp := quotedprintable.NewWriter(bodyWriter)
-	qp.Write([]byte(body))
-	qp.Close()
+	if contentType == "text/html" {
+		writeHTMLBody(writer, body)
+	} else {
+		writeTextPart(writer, "text/plain", body)
+	}
 	// 附件
 	for _, path := range attachPaths {
 		f, err := os.Open(path)
@@ -54,37 +166,97 @@ func SendEmail(smtpServer string, smtpPort int, user, pass string, to []string,
 		io.Copy(part, f)
 	}
 	writer.Close()
-	// 发送
-	tlsconfig := &tls.Config{ServerName: host, InsecureSkipVerify: true}
-	smtpAuth := smtp.PlainAuth("", user, pass, host)
-	conn, err := tls.Dial("tcp", addr, tlsconfig)
-	if err != nil {
-		return err
-	}
-	c, err := smtp.NewClient(conn, host)
-	if err != nil {
-		return err
-	}
-	if err = c.Auth(smtpAuth); err != nil {
-		return err
-	}
-	if err = c.Mail(user); err != nil {
-		return err
+	return msg.Bytes()
+}
+
+// writeTextPart 写入 quoted-printable 编码的文本 part
+func writeTextPart(w *multipart.Writer, contentType, text string) {
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Type", contentType+"; charset=utf-8")
+	header.Set("Content-Transfer-Encoding", "quoted-printable")
+	part, _ := w.CreatePart(header)
+	qp := quotedprintable.NewWriter(part)
+	qp.Write([]byte(text))
+	qp.Close()
+}
+
+var (
+	emailImgRe = regexp.MustCompile(`(<img[^>]*\ssrc=")([^"]+\.png)(")`)
+	emailTagRe = regexp.MustCompile(`<[^>]+>`)
+	// 纯文本版本去掉样式表等不可见内容
+	emailHiddenRe = regexp.MustCompile(`(?is)<(?:head|style)\b[^>]*>.*?</(?:head|style)>`)
+	emailCellRe   = regexp.MustCompile(`(?i)</t[dh]>`)
+	emailBlankRe  = regexp.MustCompile(`\n\s*\n(\s*\n)+`)
+)
+
+// writeHTMLBody 写入 multipart/alternative 正文：纯文本版本 + multipart/related（HTML 与内联图片）。
+// 读取不到的图片保持原引用
+func writeHTMLBody(mixed *multipart.Writer, body string) {
+	type inlineImage struct {
+		cid, name string
+		data      []byte
 	}
-	for _, addr := range to {
-		if err = c.Rcpt(addr); err != nil {
-			return err
+	var images []inlineImage
+	cids := make(map[string]string)
+	htmlBody := emailImgRe.ReplaceAllStringFunc(body, func(tag string) string {
+		m := emailImgRe.FindStringSubmatch(tag)
+		src := m[2]
+		cid, ok := cids[src]
+		if !ok {
+			data, err := os.ReadFile(strings.TrimPrefix(src, "file://"))
+			if err != nil {
+				return tag
+			}
+			cid = fmt.Sprintf("chart%d@quantix", len(images)+1)
+			cids[src] = cid
+			images = append(images, inlineImage{cid: cid, name: filepath.Base(src), data: data})
 		}
+		return m[1] + "cid:" + cid + m[3]
+	})
+	plain := emailCellRe.ReplaceAllString(emailHiddenRe.ReplaceAllString(body, ""), "\t")
+	plain = emailBlankRe.ReplaceAllString(html.UnescapeString(emailTagRe.ReplaceAllString(plain, "")), "\n\n")
+	plain = strings.TrimSpace(plain)
+
+	var related bytes.Buffer
+	rw := multipart.NewWriter(&related)
+	writeTextPart(rw, "text/html", htmlBody)
+	for _, img := range images {
+		header := make(textproto.MIMEHeader)
+		header.Set("Content-Type", "image/png")
+		header.Set("Content-Transfer-Encoding", "base64")
+		header.Set("Content-ID", "<"+img.cid+">")
+		header.Set("Content-Disposition", fmt.Sprintf("inline; filename=\"%s\"", img.name))
+		part, _ := rw.CreatePart(header)
+		writeBase64Lines(part, img.data)
 	}
-	w, err := c.Data()
-	if err != nil {
-		return err
-	}
-	_, err = w.Write(msg.Bytes())
-	if err != nil {
-		return err
+	rw.Close()
+
+	var alt bytes.Buffer
+	aw := multipart.NewWriter(&alt)
+	writeTextPart(aw, "text/plain", plain)
+	relHeader := make(textproto.MIMEHeader)
+	relHeader.Set("Content-Type", "multipart/related; boundary="+rw.Boundary())
+	relPart, _ := aw.CreatePart(relHeader)
+	relPart.Write(related.Bytes())
+	aw.Close()
+
+	altHeader := make(textproto.MIMEHeader)
+	altHeader.Set("Content-Type", "multipart/alternative; boundary="+aw.Boundary())
+	altPart, _ := mixed.CreatePart(altHeader)
+	altPart.Write(alt.Bytes())
+}
+
+// writeBase64Lines base64 编码并按 76 列换行（RFC 2045）
+func writeBase64Lines(w io.Writer, data []byte) {
+	enc := base64.StdEncoding.EncodeToString(data)
+	for len(enc) > 76 {
+		io.WriteString(w, enc[:76]+"\r\n")
+		enc = enc[76:]
 	}
-	w.Close()
-	c.Quit()
-	return nil
+	io.WriteString(w, enc+"\r\n")
+}
+
+// EmailReportHTML 报告 markdown 渲染为邮件 HTML 正文，图表保留本地路径，由 SendEmailWithContentType 内联
+func EmailReportHTML(report string) string {
+	return "<html><head><meta charset=\"utf-8\">" + exportCSS + "</head><body>\n" + markdownToHTML(convertMarkdownTablesToHTML(report)) + "</body></html>"
 }
@@ -0,0 +1,270 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// selfSignedCert 生成 127.0.0.1 的自签名证书，客户端默认不信任
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "quantix-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serveStartTLS 最简 SMTP 服务器：支持 EHLO/STARTTLS/QUIT，每个连接处理一次
+func serveStartTLS(ln net.Listener, cert tls.Certificate) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			conn.Write([]byte("220 localhost ESMTP\r\n"))
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+				case strings.HasPrefix(cmd, "EHLO"):
+					conn.Write([]byte("250-localhost\r\n250 STARTTLS\r\n"))
+				case cmd == "STARTTLS":
+					conn.Write([]byte("220 ready\r\n"))
+					tc := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{cert}})
+					if tc.Handshake() != nil {
+						return
+					}
+					conn, r = tc, bufio.NewReader(tc)
+				case cmd == "QUIT":
+					conn.Write([]byte("221 bye\r\n"))
+					return
+				default:
+					conn.Write([]byte("250 ok\r\n"))
+				}
+			}
+		}(conn)
+	}
+}
+
+func TestDialSMTPVerifiesCertificateByDefault(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go serveStartTLS(ln, selfSignedCert(t))
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	oldMode, oldInsecure := SMTPTLSMode, SMTPInsecureSkipVerify
+	defer func() { SMTPTLSMode, SMTPInsecureSkipVerify = oldMode, oldInsecure }()
+	SMTPTLSMode = SMTPTLSStartTLS
+
+	if c, err := dialSMTP("127.0.0.1", port); err == nil {
+		c.Close()
+		t.Fatal("默认应校验证书，自签名证书的 STARTTLS 不应成功")
+	}
+
+	SMTPInsecureSkipVerify = true
+	c, err := dialSMTP("127.0.0.1", port)
+	if err != nil {
+		t.Fatalf("显式关闭校验后应能连接: %v", err)
+	}
+	if _, ok := c.TLSConnectionState(); !ok {
+		t.Error("STARTTLS 后连接应已加密")
+	}
+	c.Quit()
+}
+
+// serveSMTPCapture 最简明文 SMTP 服务器：接受任意认证与收件人，把每封邮件的 DATA 内容发到 mails
+func serveSMTPCapture(ln net.Listener, mails chan<- string) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			conn.Write([]byte("220 localhost ESMTP\r\n"))
+			r := bufio.NewReader(conn)
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+				case strings.HasPrefix(cmd, "EHLO"):
+					conn.Write([]byte("250-localhost\r\n250 8BITMIME\r\n"))
+				case cmd == "DATA":
+					conn.Write([]byte("354 go ahead\r\n"))
+					var data strings.Builder
+					for {
+						l, err := r.ReadString('\n')
+						if err != nil {
+							return
+						}
+						if l == ".\r\n" {
+							break
+						}
+						data.WriteString(strings.TrimPrefix(l, "."))
+					}
+					mails <- data.String()
+					conn.Write([]byte("250 queued\r\n"))
+				case cmd == "QUIT":
+					conn.Write([]byte("221 bye\r\n"))
+					return
+				default:
+					conn.Write([]byte("250 ok\r\n"))
+				}
+			}
+		}(conn)
+	}
+}
+
+// mimePart 解析后的 MIME 节点
+type mimePart struct {
+	mediaType string
+	header    textproto.MIMEHeader
+	body      []byte
+	children  []mimePart
+}
+
+// parseMIMEPart 递归解析 multipart 结构
+func parseMIMEPart(t *testing.T, header textproto.MIMEHeader, body io.Reader) mimePart {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("Content-Type 非法: %q %v", header.Get("Content-Type"), err)
+	}
+	p := mimePart{mediaType: mediaType, header: header}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		p.body, _ = io.ReadAll(body)
+		return p
+	}
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextRawPart()
+		if err == io.EOF {
+			return p
+		}
+		if err != nil {
+			t.Fatalf("解析 %s 失败: %v", mediaType, err)
+		}
+		p.children = append(p.children, parseMIMEPart(t, part.Header, part))
+	}
+}
+
+// sendCapturedEmail 通过本地 SMTP mock 发送邮件，返回解析后的 MIME 根节点
+func sendCapturedEmail(t *testing.T, body, contentType string, attachPaths []string) mimePart {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	mails := make(chan string, 1)
+	go serveSMTPCapture(ln, mails)
+	oldMode := SMTPTLSMode
+	SMTPTLSMode = SMTPTLSNone
+	defer func() { SMTPTLSMode = oldMode }()
+
+	err = SendEmailWithContentType("127.0.0.1", ln.Addr().(*net.TCPAddr).Port, "bot@example.com", "", []string{"a@example.com"}, "600036 分析报告", body, contentType, attachPaths)
+	if err != nil {
+		t.Fatalf("发送邮件失败: %v", err)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(<-mails))
+	if err != nil {
+		t.Fatalf("邮件头解析失败: %v", err)
+	}
+	if subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); subject != "600036 分析报告" {
+		t.Errorf("Subject = %q", subject)
+	}
+	return parseMIMEPart(t, textproto.MIMEHeader(msg.Header), msg.Body)
+}
+
+func TestSendEmailHTMLInlinesCharts(t *testing.T) {
+	dir := t.TempDir()
+	chart := filepath.Join(dir, "600036-radar.png")
+	png := []byte("\x89PNG\r\n\x1a\nfake-chart")
+	os.WriteFile(chart, png, 0644)
+	attach := filepath.Join(dir, "600036.md")
+	os.WriteFile(attach, []byte("# 报告"), 0644)
+	body := EmailReportHTML("## 结论\n| 指标 | 数值 |\n|---|---|\n| 市盈率 | 8.2 |\n\n<img src=\"" + chart + "\">\n<img src=\"" + chart + "\">\n<img src=\"" + filepath.Join(dir, "missing.png") + "\">")
+
+	root := sendCapturedEmail(t, body, "text/html", []string{attach})
+	if root.mediaType != "multipart/mixed" || len(root.children) != 2 {
+		t.Fatalf("根节点应为含正文与附件的 multipart/mixed: %s %d", root.mediaType, len(root.children))
+	}
+	alt, file := root.children[0], root.children[1]
+	if alt.mediaType != "multipart/alternative" || len(alt.children) != 2 {
+		t.Fatalf("正文应为 multipart/alternative: %s", alt.mediaType)
+	}
+	plain, related := alt.children[0], alt.children[1]
+	if plain.mediaType != "text/plain" || strings.Contains(string(plain.body), "<table") {
+		t.Errorf("纯文本版本不应含 HTML 标签: %s", plain.body)
+	}
+	if related.mediaType != "multipart/related" || len(related.children) != 2 {
+		t.Fatalf("HTML 与图片应放在 multipart/related 中，重复引用只内联一次: %s %d", related.mediaType, len(related.children))
+	}
+	htmlPart, img := related.children[0], related.children[1]
+	htmlText, _ := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(htmlPart.body)))
+	if htmlPart.mediaType != "text/html" || strings.Count(string(htmlText), `src="cid:chart1@quantix"`) != 2 || !strings.Contains(string(htmlText), "missing.png") || !strings.Contains(string(htmlText), "<table") {
+		t.Errorf("HTML 正文应引用 cid 并保留读取失败的图片:\n%s", htmlText)
+	}
+	data, _ := base64.StdEncoding.DecodeString(strings.ReplaceAll(string(img.body), "\r\n", ""))
+	if img.mediaType != "image/png" || img.header.Get("Content-ID") != "<chart1@quantix>" || !bytes.Equal(data, png) {
+		t.Errorf("内联图片 part: %s %v", img.mediaType, img.header)
+	}
+	if file.mediaType != "application/octet-stream" || !strings.Contains(file.header.Get("Content-Disposition"), "600036.md") {
+		t.Errorf("附件 part: %s %v", file.mediaType, file.header)
+	}
+}
+
+func TestSendEmailPlainText(t *testing.T) {
+	root := sendCapturedEmail(t, "趋势判断：看涨", "text/plain", nil)
+	if root.mediaType != "multipart/mixed" || len(root.children) != 1 || root.children[0].mediaType != "text/plain" {
+		t.Fatalf("纯文本邮件结构: %+v", root)
+	}
+	text, _ := io.ReadAll(quotedprintable.NewReader(bytes.NewReader(root.children[0].body)))
+	if string(text) != "趋势判断：看涨" {
+		t.Errorf("正文 = %q", text)
+	}
+}
@@ -0,0 +1,204 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FactorWeight 多股对比打分使用的因子及权重
+type FactorWeight struct {
+	Factor string  `json:"factor"`
+	Weight float64 `json:"weight"`
+}
+
+// DefaultFactors 未指定因子时的默认权重：风险调整收益、最大回撤与波动率
+var DefaultFactors = []FactorWeight{
+	{Factor: "sharpe", Weight: 0.4},
+	{Factor: "max_drawdown", Weight: 0.3},
+	{Factor: "volatility", Weight: 0.3},
+}
+
+// riskFactor 风险类因子的取值与方向
+type riskFactor struct {
+	value        func(RiskMetrics) float64
+	higherBetter bool
+}
+
+// riskFactors 可用的风险类因子；RadarDims 中的维度名也可作为因子，取最近一次分析的维度评分，越高越好
+var riskFactors = map[string]riskFactor{
+	"sharpe":       {func(r RiskMetrics) float64 { return r.SharpeRatio }, true},
+	"sortino":      {func(r RiskMetrics) float64 { return r.SortinoRatio }, true},
+	"calmar":       {func(r RiskMetrics) float64 { return r.CalmarRatio }, true},
+	"volatility":   {func(r RiskMetrics) float64 { return r.Volatility }, false},
+	"max_drawdown": {func(r RiskMetrics) float64 { return r.MaxDrawdown }, false},
+	"var95":        {func(r RiskMetrics) float64 { return r.VaR95 }, false},
+	"risk_score":   {func(r RiskMetrics) float64 { return r.RiskScore }, false},
+}
+
+// flowFactors 资金面因子，取东方财富当日资金流向（仅 A 股），越高越好；主力净流入受市值影响，跨股对比建议用 main_net_percent
+var flowFactors = map[string]func(CapitalFlow) float64{
+	"main_net_inflow":  func(f CapitalFlow) float64 { return f.MainNetInflow },
+	"main_net_percent": func(f CapitalFlow) float64 { return f.MainNetPercent },
+	"large_net":        func(f CapitalFlow) float64 { return f.SuperLargeNet + f.LargeNet },
+}
+
+// needsCapitalFlow 因子列表中是否含资金面因子，含有时才请求资金流向接口
+func needsCapitalFlow(factors []FactorWeight) bool {
+	for _, fw := range factors {
+		if _, ok := flowFactors[fw.Factor]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// knownFactor 因子名是否可用
+func knownFactor(name string) bool {
+	if _, ok := riskFactors[name]; ok {
+		return true
+	}
+	if _, ok := flowFactors[name]; ok {
+		return true
+	}
+	for _, dim := range RadarDims {
+		if dim == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFactorWeights 校验因子与权重：数量一致、因子已知且不重复、权重非负且和为正
+func ParseFactorWeights(factors []string, weights []float64) ([]FactorWeight, error) {
+	if len(factors) != len(weights) {
+		return nil, &RequestError{fmt.Sprintf("factors 与 weights 数量不一致（%d/%d）", len(factors), len(weights))}
+	}
+	list := make([]FactorWeight, 0, len(factors))
+	seen := make(map[string]bool)
+	total := 0.0
+	for i, f := range factors {
+		f = strings.TrimSpace(f)
+		if !knownFactor(f) {
+			return nil, &RequestError{"未知因子: " + f}
+		}
+		if seen[f] {
+			return nil, &RequestError{"因子重复: " + f}
+		}
+		if weights[i] < 0 {
+			return nil, &RequestError{fmt.Sprintf("因子 %s 的权重不能为负", f)}
+		}
+		seen[f] = true
+		total += weights[i]
+		list = append(list, FactorWeight{Factor: f, Weight: weights[i]})
+	}
+	if total <= 0 {
+		return nil, &RequestError{"权重之和必须为正"}
+	}
+	return list, nil
+}
+
+// factorValue 股票在某因子上的原始取值，缺少行情、资金流向或评分时返回 false
+func factorValue(c StockComparison, factor string) (float64, bool) {
+	if rf, ok := riskFactors[factor]; ok {
+		if c.Risk == nil {
+			return 0, false
+		}
+		return rf.value(*c.Risk), true
+	}
+	if ff, ok := flowFactors[factor]; ok {
+		if c.CapitalFlow == nil {
+			return 0, false
+		}
+		return ff(*c.CapitalFlow), true
+	}
+	if c.Prediction == nil {
+		return 0, false
+	}
+	v, ok := c.Prediction.Scores[factor]
+	return v, ok
+}
+
+// factorRange 一组股票在某因子上的取值范围
+type factorRange struct {
+	lo, hi float64
+	n      int
+}
+
+func (r *factorRange) add(v float64) {
+	if r.n == 0 || v < r.lo {
+		r.lo = v
+	}
+	if r.n == 0 || v > r.hi {
+		r.hi = v
+	}
+	r.n++
+}
+
+// ScoreStocksByFactors 各因子在参与对比的股票间按 min-max 归一到 0-100（越小越好的因子取反，取值全相同时记 50），
+// 综合得分为可用因子的加权平均；缺失的因子不计入该股票的权重。结果按综合得分降序，无任何可用因子的排在最后。
+// 股票带 Industry 时按行业中性化：同行业至少两只有该因子取值时在行业内归一，否则（含无行业信息）退回全部股票间归一
+func ScoreStocksByFactors(list []StockComparison, factors []FactorWeight) {
+	for _, fw := range factors {
+		var global factorRange
+		byIndustry := make(map[string]*factorRange)
+		for _, c := range list {
+			v, ok := factorValue(c, fw.Factor)
+			if !ok {
+				continue
+			}
+			global.add(v)
+			if c.Industry != "" {
+				if byIndustry[c.Industry] == nil {
+					byIndustry[c.Industry] = &factorRange{}
+				}
+				byIndustry[c.Industry].add(v)
+			}
+		}
+		higherBetter := true
+		if rf, ok := riskFactors[fw.Factor]; ok {
+			higherBetter = rf.higherBetter
+		}
+		for i := range list {
+			v, ok := factorValue(list[i], fw.Factor)
+			if !ok {
+				continue
+			}
+			r := global
+			if g := byIndustry[list[i].Industry]; g != nil && g.n >= 2 {
+				r = *g
+			}
+			norm := 50.0
+			if r.hi > r.lo {
+				norm = (v - r.lo) / (r.hi - r.lo) * 100
+				if !higherBetter {
+					norm = 100 - norm
+				}
+			}
+			if list[i].FactorScores == nil {
+				list[i].FactorScores = make(map[string]float64)
+			}
+			list[i].FactorScores[fw.Factor] = norm
+		}
+	}
+
+	for i := range list {
+		sum, weight := 0.0, 0.0
+		for _, fw := range factors {
+			if v, ok := list[i].FactorScores[fw.Factor]; ok {
+				sum += v * fw.Weight
+				weight += fw.Weight
+			}
+		}
+		if weight > 0 {
+			list[i].Score = sum / weight
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		si, sj := len(list[i].FactorScores) > 0, len(list[j].FactorScores) > 0
+		if si != sj {
+			return si
+		}
+		return list[i].Score > list[j].Score
+	})
+}
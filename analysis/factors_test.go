@@ -0,0 +1,186 @@
+package analysis
+
+import (
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseFactorWeights(t *testing.T) {
+	list, err := ParseFactorWeights([]string{" sharpe", "技术面"}, []float64{2, 0})
+	if err != nil || len(list) != 2 || list[0] != (FactorWeight{"sharpe", 2}) || list[1] != (FactorWeight{"技术面", 0}) {
+		t.Fatalf("合法因子解析 = %+v %v", list, err)
+	}
+	for _, c := range []struct {
+		name    string
+		factors []string
+		weights []float64
+		want    string
+	}{
+		{"数量不一致", []string{"sharpe", "volatility"}, []float64{1}, "数量不一致"},
+		{"未知因子", []string{"pe"}, []float64{1}, "未知因子"},
+		{"因子重复", []string{"sharpe", "sharpe"}, []float64{1, 1}, "因子重复"},
+		{"负权重", []string{"sharpe", "volatility"}, []float64{2, -1}, "不能为负"},
+		{"权重和为零", []string{"sharpe"}, []float64{0}, "和必须为正"},
+	} {
+		_, err := ParseFactorWeights(c.factors, c.weights)
+		if !IsRequestError(err) || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%s: 应返回含 %q 的请求错误，得到 %v", c.name, c.want, err)
+		}
+	}
+}
+
+func TestScoreStocksByFactorsWeights(t *testing.T) {
+	group := func() []StockComparison {
+		return []StockComparison{
+			{StockCode: "D"},
+			{StockCode: "C", Risk: &RiskMetrics{SharpeRatio: 0, MaxDrawdown: 0.2}},
+			{StockCode: "B", Risk: &RiskMetrics{SharpeRatio: 1, MaxDrawdown: 0.3}},
+			{StockCode: "A", Risk: &RiskMetrics{SharpeRatio: 2, MaxDrawdown: 0.1}},
+		}
+	}
+	list := group()
+	ScoreStocksByFactors(list, []FactorWeight{{"sharpe", 1}, {"max_drawdown", 1}})
+	if list[0].StockCode != "A" || list[0].Score != 100 || list[3].StockCode != "D" || list[3].FactorScores != nil {
+		t.Fatalf("应按综合得分降序、无行情的排最后: %+v", list)
+	}
+	// 回撤越小越好：C 的回撤居中得 50，B 回撤最大得 0
+	if b := list[1]; b.FactorScores["sharpe"] != 50 || b.FactorScores["max_drawdown"] != 0 || math.Abs(b.Score-25) > 1e-9 {
+		t.Errorf("B 分项得分 = %+v", b)
+	}
+
+	list = group()
+	ScoreStocksByFactors(list, []FactorWeight{{"sharpe", 3}, {"max_drawdown", 1}})
+	if list[1].StockCode != "B" || math.Abs(list[1].Score-37.5) > 1e-9 || list[2].StockCode != "C" || math.Abs(list[2].Score-12.5) > 1e-9 {
+		t.Errorf("提高夏普权重后 B 应领先 C: %+v", list)
+	}
+}
+
+func TestCompareEndpointWithAndWithoutWeights(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	oldTransport, oldRetries, oldCache := FetchTransport, FetchMaxRetries, DataCacheDir
+	FetchTransport, FetchMaxRetries, DataCacheDir = failingTransport{}, 0, t.TempDir()
+	defer func() {
+		FetchTransport, FetchMaxRetries, DataCacheDir = oldTransport, oldRetries, oldCache
+		os.Chdir(wd)
+	}()
+	if err := saveStockCache("600036", "测试", datedBars(80)); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveStockCache("000001", "测试", trendBars(80, 0.01)); err != nil {
+		t.Fatal(err)
+	}
+	h := NewTaskServer("", "deepseek-chat").Handler()
+
+	code, body := doJSON(t, h, "GET", "/compare?codes=600036,000001", "", nil)
+	if code != 200 {
+		t.Fatalf("默认因子对比: %d %v", code, body)
+	}
+	if factors, _ := body["factors"].([]interface{}); len(factors) != len(DefaultFactors) {
+		t.Errorf("未带权重时应使用默认因子: %v", body["factors"])
+	}
+	for _, s := range body["stocks"].([]interface{}) {
+		fs, _ := s.(map[string]interface{})["factor_scores"].(map[string]interface{})
+		if len(fs) != 3 || fs["sharpe"] == nil || fs["max_drawdown"] == nil || fs["volatility"] == nil {
+			t.Errorf("应返回各默认因子的归一化得分: %v", s)
+		}
+	}
+
+	code, body = doJSON(t, h, "GET", "/compare?codes=600036,000001&factors=sharpe,volatility&weights=1,0", "", nil)
+	if code != 200 {
+		t.Fatalf("自定义权重对比: %d %v", code, body)
+	}
+	stocks := body["stocks"].([]interface{})
+	first, second := stocks[0].(map[string]interface{}), stocks[1].(map[string]interface{})
+	if first["score"] != 100.0 || second["score"] != 0.0 || len(first["factor_scores"].(map[string]interface{})) != 2 {
+		t.Errorf("只按夏普计分时应一只 100 一只 0: %v", stocks)
+	}
+
+	for _, q := range []string{"factors=sharpe,volatility&weights=1", "factors=pe&weights=1", "factors=sharpe&weights=abc", "factors=sharpe&weights=0"} {
+		if code, _ := doJSON(t, h, "GET", "/compare?codes=600036,000001&"+q, "", nil); code != 400 {
+			t.Errorf("%s: 状态码 = %d，期望 400", q, code)
+		}
+	}
+}
+
+func TestScoreStocksByFactorsIndustryNeutral(t *testing.T) {
+	group := func(withIndustry bool) []StockComparison {
+		list := []StockComparison{
+			{StockCode: "601398", Industry: "银行", Risk: &RiskMetrics{SharpeRatio: 0.1}},
+			{StockCode: "600036", Industry: "银行", Risk: &RiskMetrics{SharpeRatio: 0.3}},
+			{StockCode: "000858", Industry: "白酒", Risk: &RiskMetrics{SharpeRatio: 2}},
+			{StockCode: "600519", Industry: "白酒", Risk: &RiskMetrics{SharpeRatio: 3}},
+		}
+		if !withIndustry {
+			for i := range list {
+				list[i].Industry = ""
+			}
+		}
+		return list
+	}
+	scores := func(list []StockComparison) map[string]float64 {
+		m := make(map[string]float64)
+		for _, c := range list {
+			m[c.StockCode] = c.Score
+		}
+		return m
+	}
+
+	neutral := scores(scoreList(group(true)))
+	if neutral["600036"] != 100 || neutral["601398"] != 0 || neutral["600519"] != 100 || neutral["000858"] != 0 {
+		t.Errorf("行业内归一：各行业最优 100、最差 0，得到 %v", neutral)
+	}
+	global := scores(scoreList(group(false)))
+	if global["600519"] != 100 || global["601398"] != 0 || global["600036"] >= global["000858"] {
+		t.Errorf("无行业信息时退回全局归一，银行股应落后白酒股: %v", global)
+	}
+
+	// 同行业只有一只时该股退回全局归一
+	list := append(group(true), StockComparison{StockCode: "300750", Industry: "电池", Risk: &RiskMetrics{SharpeRatio: 1.55}})
+	if got := scores(scoreList(list)); math.Abs(got["300750"]-50) > 1e-9 || got["600519"] != 100 {
+		t.Errorf("单只行业应按全部股票归一: %v", got)
+	}
+}
+
+// scoreList 只按夏普打分并返回原切片
+func scoreList(list []StockComparison) []StockComparison {
+	ScoreStocksByFactors(list, []FactorWeight{{"sharpe", 1}})
+	return list
+}
+
+func TestResolveIndustries(t *testing.T) {
+	oldConclusion, oldMap := ConclusionFile, IndustryMapFile
+	ConclusionFile, IndustryMapFile = filepath.Join(t.TempDir(), "conclusions.jsonl"), filepath.Join(t.TempDir(), "industry.json")
+	defer func() { ConclusionFile, IndustryMapFile = oldConclusion, oldMap }()
+	os.WriteFile(IndustryMapFile, []byte(`{"stocks": {"300750": "电池"}}`), 0644)
+
+	got := ResolveIndustries([]string{"600036", " 300750", "AAPL", "ZZZZ"}, map[string]string{"AAPL": "科技"})
+	if got["600036"] != "银行" || got["300750"] != "电池" || got["AAPL"] != "科技" {
+		t.Errorf("行业归属 = %v", got)
+	}
+	if _, ok := got["ZZZZ"]; ok {
+		t.Errorf("无法识别的股票不应列出: %v", got)
+	}
+}
+
+func TestCompareEndpointIndustryQuery(t *testing.T) {
+	h := NewTaskServer("", "deepseek-chat").Handler()
+	for _, q := range []string{"industry_neutral=maybe", "industries=600036", "industries=600036:银行,:白酒"} {
+		if code, _ := doJSON(t, h, "GET", "/compare?codes=600036,600519&"+q, "", nil); code != 400 {
+			t.Errorf("%s: 状态码 = %d，期望 400", q, code)
+		}
+	}
+	if m, err := parseIndustryQuery(url.Values{"industry_neutral": {"false"}}, []string{"600036"}); m != nil || err != nil {
+		t.Errorf("未开启行业中性化时应返回 nil: %v %v", m, err)
+	}
+	m, err := parseIndustryQuery(url.Values{"industries": {"600519:白酒"}}, []string{"600036", "600519"})
+	if err != nil || m["600519"] != "白酒" || m["600036"] != "银行" {
+		t.Errorf("指定行业应覆盖，其余自动识别: %v %v", m, err)
+	}
+}
@@ -0,0 +1,169 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DataCacheDir 行情本地缓存目录，每只股票一个 <code>.json
+var DataCacheDir = "cache"
+
+// DataLevel 行情数据降级层级
+type DataLevel int
+
+const (
+	DataLevelRealtime DataLevel = iota // 实时接口
+	DataLevelCache                     // 本地缓存（可能已过期）
+	DataLevelLLM                       // 无本地数据，依赖 LLM 联网
+	DataLevelCSV                       // 本地 CSV 文件（离线模式）
+)
+
+func (l DataLevel) String() string {
+	switch l {
+	case DataLevelRealtime:
+		return "实时接口"
+	case DataLevelCache:
+		return "本地缓存"
+	case DataLevelCSV:
+		return "本地CSV"
+	default:
+		return "LLM联网"
+	}
+}
+
+// DataSourceInfo 记录本次分析实际使用的数据层级与时效
+type DataSourceInfo struct {
+	Level      DataLevel
+	Source     string    // 具体数据源名称，如“雪球API”
+	FetchedAt  time.Time // 数据抓取时间（缓存为写入缓存的时间）
+	LatestDate time.Time // 行情最新交易日
+	WarmupBars int       // 行情开头为补足风险指标样本而保留的 start 之前的K线根数，展示时用 displayRange 跳过
+}
+
+// Notice 生成写入报告与 prompt 的数据来源说明，按 lang 输出
+func (i DataSourceInfo) Notice(lang string) string {
+	w := wrapperFor(lang)
+	level := localize(i.Level.String(), lang)
+	switch i.Level {
+	case DataLevelRealtime:
+		return fmt.Sprintf(w.sourceRealtime, level, localize(i.Source, lang), i.LatestDate.Format("2006-01-02"))
+	case DataLevelCache:
+		age := time.Since(i.FetchedAt).Round(time.Minute)
+		return fmt.Sprintf(w.sourceCache, level, i.FetchedAt.Format("2006-01-02 15:04"), age, i.LatestDate.Format("2006-01-02"))
+	case DataLevelCSV:
+		return fmt.Sprintf(w.sourceCSV, level, i.Source, i.LatestDate.Format("2006-01-02"))
+	default:
+		return fmt.Sprintf(w.sourceLLM, level)
+	}
+}
+
+type stockCacheFile struct {
+	StockCode string      `json:"stock_code"`
+	Source    string      `json:"source"`
+	FetchedAt time.Time   `json:"fetched_at"`
+	Data      []StockData `json:"data"`
+}
+
+func stockCachePath(stockCode string) string {
+	return filepath.Join(DataCacheDir, fileNameCode(stockCode)+".json")
+}
+
+// saveStockCache 将成功获取的原始行情写入本地缓存
+func saveStockCache(stockCode, source string, stockData []StockData) error {
+	if err := os.MkdirAll(DataCacheDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(stockCacheFile{StockCode: stockCode, Source: source, FetchedAt: time.Now(), Data: stockData})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(stockCachePath(stockCode), data, 0644)
+}
+
+// loadStockCache 读取本地缓存，不论是否过期
+func loadStockCache(stockCode string) (stockCacheFile, error) {
+	var c stockCacheFile
+	data, err := ioutil.ReadFile(stockCachePath(stockCode))
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, err
+	}
+	if len(c.Data) == 0 {
+		return c, fmt.Errorf("缓存为空")
+	}
+	return c, nil
+}
+
+// ErrNoData 行情数据源与本地缓存均无可用数据；本地数据模式下 AnalyzeOne 直接返回该错误，不再基于缺失数据生成报告
+var ErrNoData = errors.New("无法获取行情")
+
+// noDataResult 无行情时的分析结果，Err 可用 errors.Is(err, ErrNoData) 判断
+func noDataResult(stockCode string, fetchErr error) AnalysisResult {
+	if fetchErr == nil {
+		return AnalysisResult{StockCode: stockCode, Err: ErrNoData}
+	}
+	log.Printf("[数据源] %s 本地行情不可用: %v\n", stockCode, fetchErr)
+	return AnalysisResult{StockCode: stockCode, Err: fmt.Errorf("%w: %v", ErrNoData, fetchErr)}
+}
+
+// FetchStockHistoryWithFallback 多级降级获取行情：实时接口 → 本地缓存（即使过期）→ LLM 联网。
+// 前两级都失败时返回错误，DataSourceInfo.Level 为 DataLevelLLM。
+// 行情按 start/end 裁剪，区间内无数据时直接返回“区间无数据”错误；区间过短时保留的预热根数见 DataSourceInfo.WarmupBars。
+func FetchStockHistoryWithFallback(stockCode, start, end, apiKey string) ([]StockData, []TechnicalIndicator, DataSourceInfo, error) {
+	return FetchStockHistoryWithFallbackContext(context.Background(), stockCode, start, end, apiKey)
+}
+
+// FetchStockHistoryWithFallbackContext 同 FetchStockHistoryWithFallback，ctx 取消或超时时直接返回 ctx.Err()，不再降级到本地缓存
+func FetchStockHistoryWithFallbackContext(ctx context.Context, stockCode, start, end, apiKey string) ([]StockData, []TechnicalIndicator, DataSourceInfo, error) {
+	raw, source, err := fetchFromSources(ctx, stockCode)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, nil, DataSourceInfo{Level: DataLevelLLM}, ctxErr
+	}
+	if err == nil {
+		if cacheErr := saveStockCache(stockCode, source, raw); cacheErr != nil {
+			log.Printf("[数据缓存] 写入 %s 缓存失败: %v\n", stockCode, cacheErr)
+		}
+		stockData, indicators := prepareStockData(raw, stockCode)
+		if len(stockData) > 0 {
+			var warmup int
+			stockData, indicators, warmup, err = clipToDateRange(stockData, indicators, start, end)
+			if err != nil {
+				return nil, nil, DataSourceInfo{Level: DataLevelLLM}, err
+			}
+			info := DataSourceInfo{Level: DataLevelRealtime, Source: source, FetchedAt: time.Now(), LatestDate: stockData[len(stockData)-1].Date, WarmupBars: warmup}
+			return stockData, indicators, info, nil
+		}
+	}
+
+	cached, cacheErr := loadStockCache(stockCode)
+	if cacheErr == nil {
+		stockData, indicators := prepareStockData(cached.Data, stockCode)
+		if len(stockData) > 0 {
+			var warmup int
+			stockData, indicators, warmup, cacheErr = clipToDateRange(stockData, indicators, start, end)
+			if cacheErr != nil {
+				return nil, nil, DataSourceInfo{Level: DataLevelLLM}, cacheErr
+			}
+			log.Printf("[数据源] ⚠️  实时接口失败，使用 %s 的本地缓存数据\n", cached.FetchedAt.Format("2006-01-02 15:04"))
+			info := DataSourceInfo{Level: DataLevelCache, Source: cached.Source, FetchedAt: cached.FetchedAt, LatestDate: stockData[len(stockData)-1].Date, WarmupBars: warmup}
+			return stockData, indicators, info, nil
+		}
+	}
+
+	if err == nil {
+		err = fmt.Errorf("数据校验后无有效行情")
+	}
+	if cacheErr == nil {
+		cacheErr = fmt.Errorf("缓存数据校验后无有效行情")
+	}
+	return nil, nil, DataSourceInfo{Level: DataLevelLLM}, fmt.Errorf("%v；本地缓存不可用: %v", err, cacheErr)
+}
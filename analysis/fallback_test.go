@@ -0,0 +1,115 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzeOneNoDataSkipsLLM(t *testing.T) {
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	oldTransport, oldRetries, oldCache, oldDB := FetchTransport, FetchMaxRetries, DataCacheDir, DBPath
+	FetchTransport, FetchMaxRetries, DataCacheDir, DBPath = failingTransport{}, 0, t.TempDir(), ""
+	defer func() {
+		FetchTransport, FetchMaxRetries, DataCacheDir, DBPath = oldTransport, oldRetries, oldCache, oldDB
+		os.Chdir(wd)
+	}()
+
+	calls := 0
+	gen := func(stock, prompt, apiKey, apiURL, model string, searchMode, hybridSearch bool) (string, error) {
+		calls++
+		return "报告", nil
+	}
+	params := AnalysisParams{StockCodes: []string{"600036"}, Model: "deepseek-chat"}
+
+	result := AnalyzeOne(context.Background(), params, gen)
+	if !errors.Is(result.Err, ErrNoData) {
+		t.Fatalf("实时接口与缓存均失败时应返回 ErrNoData，得到 %v", result.Err)
+	}
+	if calls != 0 || result.Report != "" {
+		t.Errorf("无行情时不应调用大模型，调用 %d 次，报告 %q", calls, result.Report)
+	}
+
+	// 纯联网模式由大模型自行检索，无本地行情时仍继续
+	params.SearchMode = true
+	result = AnalyzeOne(context.Background(), params, gen)
+	if result.Err != nil || calls == 0 {
+		t.Errorf("联网模式应继续生成，得到 err=%v 调用 %d 次", result.Err, calls)
+	}
+	if strings.Contains(result.Report, "【机器学习预测】") {
+		t.Error("无本地行情时应跳过机器学习预测表")
+	}
+}
+
+// stubStockSource 以单一数据源替换实时接口，fail 为 true 时该数据源返回错误
+func stubStockSource(t *testing.T, data []StockData, fail *bool) {
+	t.Helper()
+	oldSources, oldCache := stockDataSources, DataCacheDir
+	stockDataSources = []stockDataSource{{sourceTencent, "腾讯API", func(ctx context.Context, code string) ([]StockData, error) {
+		if *fail {
+			return nil, errors.New("接口超时")
+		}
+		return append([]StockData(nil), data...), nil
+	}}}
+	DataCacheDir = t.TempDir()
+	t.Cleanup(func() { stockDataSources, DataCacheDir = oldSources, oldCache })
+}
+
+func TestFetchWithFallbackLevels(t *testing.T) {
+	fail := false
+	stubStockSource(t, datedBars(60), &fail)
+	ctx := context.Background()
+
+	// 第一级：实时接口成功，同时写入本地缓存
+	data, _, info, err := FetchStockHistoryWithFallbackContext(ctx, "600036", "", "", "")
+	if err != nil || info.Level != DataLevelRealtime || info.Source != "腾讯API" || len(data) != 60 {
+		t.Fatalf("实时接口: level=%v source=%q n=%d err=%v", info.Level, info.Source, len(data), err)
+	}
+	if !strings.Contains(info.Notice(""), "实时接口（腾讯API）") {
+		t.Errorf("实时接口说明 = %q", info.Notice(""))
+	}
+	cached, err := loadStockCache("600036")
+	if err != nil || len(cached.Data) != 60 {
+		t.Fatalf("成功获取后应写入缓存: %v", err)
+	}
+
+	// 第二级：实时接口失败，使用（即使过期的）本地缓存
+	fail = true
+	data, _, info, err = FetchStockHistoryWithFallbackContext(ctx, "600036", "", "", "")
+	if err != nil || info.Level != DataLevelCache || len(data) != 60 {
+		t.Fatalf("本地缓存: level=%v n=%d err=%v", info.Level, len(data), err)
+	}
+	if !info.FetchedAt.Equal(cached.FetchedAt) || !info.LatestDate.Equal(data[59].Date) {
+		t.Errorf("缓存时效信息错误: %+v", info)
+	}
+	if notice := info.Notice(""); !strings.Contains(notice, "本地缓存") || !strings.Contains(notice, "请注意时效") {
+		t.Errorf("缓存说明 = %q", notice)
+	}
+
+	// 第三级：实时接口与缓存均不可用，交给 LLM 联网
+	_, _, info, err = FetchStockHistoryWithFallbackContext(ctx, "000001", "", "", "")
+	if err == nil || info.Level != DataLevelLLM || !strings.Contains(err.Error(), "本地缓存不可用") {
+		t.Fatalf("LLM 联网: level=%v err=%v", info.Level, err)
+	}
+	if !strings.Contains(info.Notice(""), "LLM联网") {
+		t.Errorf("LLM 联网说明 = %q", info.Notice(""))
+	}
+}
+
+func TestFetchWithFallbackCancelledSkipsCache(t *testing.T) {
+	fail := true
+	stubStockSource(t, nil, &fail)
+	if err := saveStockCache("600036", "测试", datedBars(60)); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, info, err := FetchStockHistoryWithFallbackContext(ctx, "600036", "", "", ""); !errors.Is(err, context.Canceled) || info.Level != DataLevelLLM {
+		t.Errorf("取消后不应降级到缓存: level=%v err=%v", info.Level, err)
+	}
+}
@@ -0,0 +1,130 @@
+package analysis
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FeedFile Atom 订阅文件路径，与报告放在同一目录便于用相对链接访问
+var FeedFile = filepath.Join("history", "feed.xml")
+
+// FeedMaxEntries 订阅中保留的最大条目数，<=0 表示不生成订阅
+var FeedMaxEntries = 50
+
+// FeedBaseURL 报告链接前缀，如将 history 目录发布到 https://example.com/reports/，为空时使用相对路径
+var FeedBaseURL = ""
+
+// feedMu 保护订阅文件的读-改-写，批量并发分析时避免条目互相覆盖
+var feedMu sync.Mutex
+
+// FeedEntry 一条分析报告订阅条目
+type FeedEntry struct {
+	ID      string
+	Title   string
+	Summary string
+	Link    string
+	Updated time.Time
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Summary string   `xml:"summary"`
+	Link    atomLink `xml:"link"`
+	Updated string   `xml:"updated"`
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// NewFeedEntry 根据一次分析结果生成订阅条目，摘要取报告正文前若干字
+func NewFeedEntry(stockCode, savedFile, report string, at time.Time) FeedEntry {
+	link := savedFile
+	if FeedBaseURL != "" {
+		link = strings.TrimRight(FeedBaseURL, "/") + "/" + savedFile
+	}
+	return FeedEntry{
+		ID:      "urn:quantix:" + savedFile,
+		Title:   fmt.Sprintf("[%s] AI 智能分析报告 %s", stockCode, at.Format("2006-01-02 15:04")),
+		Summary: summarizeReport(report, 200),
+		Link:    link,
+		Updated: at,
+	}
+}
+
+// summarizeReport 跳过表格、图片与提示块，截取正文前 maxRunes 个字符
+func summarizeReport(report string, maxRunes int) string {
+	var parts []string
+	for _, line := range strings.Split(report, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "|") || strings.HasPrefix(line, "!") ||
+			strings.HasPrefix(line, ">") || strings.HasPrefix(line, "<") || strings.HasPrefix(line, "【") {
+			continue
+		}
+		parts = append(parts, strings.TrimLeft(line, "#*- "))
+	}
+	summary := []rune(strings.Join(parts, " "))
+	if len(summary) > maxRunes {
+		return string(summary[:maxRunes]) + "…"
+	}
+	return string(summary)
+}
+
+// AppendFeedEntry 将条目插入订阅开头（同 ID 覆盖），超出 FeedMaxEntries 的旧条目被移除
+func AppendFeedEntry(entry FeedEntry) error {
+	if FeedMaxEntries <= 0 {
+		return nil
+	}
+	feedMu.Lock()
+	defer feedMu.Unlock()
+	feed := atomFeed{ID: "urn:quantix:feed", Title: "Quantix 分析报告"}
+	if data, err := ioutil.ReadFile(FeedFile); err == nil {
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return fmt.Errorf("解析订阅文件 %s 失败: %v", FeedFile, err)
+		}
+	}
+
+	updated := entry.Updated.Format(time.RFC3339)
+	entries := []atomEntry{{
+		ID:      entry.ID,
+		Title:   entry.Title,
+		Summary: entry.Summary,
+		Link:    atomLink{Href: entry.Link, Rel: "alternate"},
+		Updated: updated,
+	}}
+	for _, e := range feed.Entries {
+		if e.ID != entry.ID {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) > FeedMaxEntries {
+		entries = entries[:FeedMaxEntries]
+	}
+	feed.Entries = entries
+	feed.Updated = updated
+
+	data, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(FeedFile), 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(FeedFile, append([]byte(xml.Header), data...), 0644)
+}
@@ -0,0 +1,85 @@
+package analysis
+
+import (
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useFeedFile(t *testing.T, maxEntries int) {
+	t.Helper()
+	oldFile, oldMax, oldBase := FeedFile, FeedMaxEntries, FeedBaseURL
+	FeedFile, FeedMaxEntries, FeedBaseURL = filepath.Join(t.TempDir(), "history", "feed.xml"), maxEntries, ""
+	t.Cleanup(func() { FeedFile, FeedMaxEntries, FeedBaseURL = oldFile, oldMax, oldBase })
+}
+
+func readFeed(t *testing.T) atomFeed {
+	t.Helper()
+	data, err := os.ReadFile(FeedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var feed atomFeed
+	if err := xml.Unmarshal(data, &feed); err != nil {
+		t.Fatalf("订阅文件不是合法的 Atom: %v\n%s", err, data)
+	}
+	return feed
+}
+
+func TestNewFeedEntry(t *testing.T) {
+	useFeedFile(t, 50)
+	FeedBaseURL = "https://example.com/reports/"
+	at := time.Date(2024, 6, 28, 15, 30, 0, 0, time.Local)
+	report := "> [!NOTE] 数据来源\n## 主要结论\n| 指标 | 数值 |\n![图](k.png)\n**趋势看涨**，" + strings.Repeat("量", 300)
+	e := NewFeedEntry("600036", "600036_20240628.md", report, at)
+	if e.ID != "urn:quantix:600036_20240628.md" || e.Link != "https://example.com/reports/600036_20240628.md" {
+		t.Errorf("ID/链接错误: %+v", e)
+	}
+	if e.Title != "[600036] AI 智能分析报告 2024-06-28 15:30" || !e.Updated.Equal(at) {
+		t.Errorf("标题/时间错误: %+v", e)
+	}
+	if !strings.HasPrefix(e.Summary, "主要结论 趋势看涨**，量") || !strings.HasSuffix(e.Summary, "…") || len([]rune(e.Summary)) != 201 {
+		t.Errorf("摘要应跳过提示块、表格与图片并截断到 200 字: %q", e.Summary)
+	}
+}
+
+func TestAppendFeedEntryLimitsAndDedups(t *testing.T) {
+	useFeedFile(t, 3)
+	base := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		e := NewFeedEntry("600036", fmt.Sprintf("r%d.md", i), "报告正文", base.Add(time.Duration(i)*time.Hour))
+		if err := AppendFeedEntry(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	feed := readFeed(t)
+	if len(feed.Entries) != 3 || feed.Entries[0].ID != "urn:quantix:r4.md" || feed.Entries[2].ID != "urn:quantix:r2.md" {
+		t.Fatalf("应只保留最新 3 条且新条目在前: %+v", feed.Entries)
+	}
+	if feed.Updated != "2024-06-01T13:00:00Z" || feed.Entries[0].Link.Href != "r4.md" {
+		t.Errorf("订阅更新时间/链接错误: %s %+v", feed.Updated, feed.Entries[0])
+	}
+
+	// 同 ID 重新分析时覆盖旧条目并移到开头
+	if err := AppendFeedEntry(NewFeedEntry("600036", "r2.md", "更新后的报告", base.Add(6*time.Hour))); err != nil {
+		t.Fatal(err)
+	}
+	feed = readFeed(t)
+	if len(feed.Entries) != 3 || feed.Entries[0].ID != "urn:quantix:r2.md" || feed.Entries[0].Summary != "更新后的报告" || feed.Entries[2].ID != "urn:quantix:r3.md" {
+		t.Errorf("同 ID 条目应覆盖: %+v", feed.Entries)
+	}
+}
+
+func TestAppendFeedEntryDisabled(t *testing.T) {
+	useFeedFile(t, 0)
+	if err := AppendFeedEntry(NewFeedEntry("600036", "r.md", "报告", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(FeedFile); !os.IsNotExist(err) {
+		t.Errorf("FeedMaxEntries<=0 时不应生成订阅文件: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// FetchMaxRetries 行情接口失败后的最大重试次数（不含首次请求）
+var FetchMaxRetries = 3
+
+// FetchTimeout 行情接口单次请求超时
+var FetchTimeout = 10 * time.Second
+
+// FetchBackoff 首次重试前的等待时间，之后每次翻倍
+var FetchBackoff = 500 * time.Millisecond
+
+// defaultFetchHeaders 各数据源通用请求头
+var defaultFetchHeaders = map[string]string{
+	"User-Agent": "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36",
+}
+
+// retryableStatus 429 限流与 5xx 服务端错误可重试，其他非 2xx 直接失败
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// fetchWithRetry GET 请求，网络错误、429、5xx 时按指数退避重试，每次尝试都输出状态与耗时；
+// ctx 取消或超时时立即返回 ctx.Err()，不再重试
+func fetchWithRetry(ctx context.Context, rawURL string, headers map[string]string, maxRetries int) ([]byte, error) {
+	source := rawURL
+	if u, err := url.Parse(rawURL); err == nil {
+		source = u.Host
+	}
+	client := &http.Client{Timeout: FetchTimeout, Transport: FetchTransport}
+	backoff := FetchBackoff
+	var lastErr error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		req, err := http.NewRequestWithContext(ctx, "GET", rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+
+		start := time.Now()
+		resp, err := client.Do(req)
+		if ctx.Err() != nil {
+			if err == nil {
+				resp.Body.Close()
+			}
+			return nil, ctx.Err()
+		}
+		if err != nil {
+			log.Printf("[数据源] %s 第 %d 次请求失败（%v）: %v\n", source, attempt+1, time.Since(start).Round(time.Millisecond), err)
+			lastErr = err
+			continue
+		}
+		body, readErr := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		log.Printf("[数据源] %s 第 %d 次请求状态码 %d（%v）\n", source, attempt+1, resp.StatusCode, time.Since(start).Round(time.Millisecond))
+
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			if readErr != nil {
+				lastErr = readErr
+				continue
+			}
+			return body, nil
+		case retryableStatus(resp.StatusCode):
+			lastErr = fmt.Errorf("%s 返回状态码 %d", source, resp.StatusCode)
+		default:
+			return nil, fmt.Errorf("%s 返回状态码 %d", source, resp.StatusCode)
+		}
+	}
+	return nil, fmt.Errorf("%s 重试 %d 次后仍失败: %v", source, maxRetries, lastErr)
+}
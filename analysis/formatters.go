@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+)
+
+// 报告数值统一格式：价格 2 位小数，比例以 % 展示，成交量/金额按量级带万/亿单位
+
+// FormatPrice 价格，保留 2 位小数
+func FormatPrice(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
+
+// FormatPercent 比例（0.0523 表示 5.23%）转为百分比字符串
+func FormatPercent(ratio float64) string {
+	return fmt.Sprintf("%.2f%%", ratio*100)
+}
+
+// FormatRatio 夏普比率、盈亏比等无量纲指标，保留 2 位小数
+func FormatRatio(v float64) string {
+	return fmt.Sprintf("%.2f", v)
+}
+
+// withUnit 按万/亿量级格式化，unit 为基本单位（如“股”“元”）
+func withUnit(v float64, unit string) string {
+	abs := math.Abs(v)
+	switch {
+	case abs >= 1e8:
+		return fmt.Sprintf("%.2f亿%s", v/1e8, unit)
+	case abs >= 1e4:
+		return fmt.Sprintf("%.2f万%s", v/1e4, unit)
+	default:
+		return fmt.Sprintf("%.0f%s", v, unit)
+	}
+}
+
+// FormatVolume 成交量（单位：股）
+func FormatVolume(shares float64) string {
+	return withUnit(shares, "股")
+}
+
+// FormatAmount 金额（单位：元）
+func FormatAmount(yuan float64) string {
+	return withUnit(yuan, "元")
+}
+
+// withUnitEN 英文报告按 K/M/B 量级格式化，unit 为空时只输出数值
+func withUnitEN(v float64, unit string) string {
+	abs := math.Abs(v)
+	var s string
+	switch {
+	case abs >= 1e9:
+		s = fmt.Sprintf("%.2fB", v/1e9)
+	case abs >= 1e6:
+		s = fmt.Sprintf("%.2fM", v/1e6)
+	case abs >= 1e3:
+		s = fmt.Sprintf("%.2fK", v/1e3)
+	default:
+		s = fmt.Sprintf("%.0f", v)
+	}
+	if unit != "" {
+		s += " " + unit
+	}
+	return s
+}
+
+// formatVolumeLang 成交量，英文报告用 K/M/B 与 shares
+func formatVolumeLang(shares float64, lang string) string {
+	if isEnglish(lang) {
+		return withUnitEN(shares, "shares")
+	}
+	return FormatVolume(shares)
+}
+
+// formatAmountLang 金额，英文报告用 K/M/B 且不带币种
+func formatAmountLang(yuan float64, lang string) string {
+	if isEnglish(lang) {
+		return withUnitEN(yuan, "")
+	}
+	return FormatAmount(yuan)
+}
@@ -0,0 +1,43 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatters(t *testing.T) {
+	for _, c := range []struct {
+		name, got, want string
+	}{
+		{"价格", FormatPrice(12.345), "12.35"},
+		{"价格取整", FormatPrice(8), "8.00"},
+		{"百分比", FormatPercent(0.0523), "5.23%"},
+		{"负百分比", FormatPercent(-0.1), "-10.00%"},
+		{"比率", FormatRatio(1.256), "1.26"},
+		{"成交量 个位", FormatVolume(800), "800股"},
+		{"成交量 万", FormatVolume(12345), "1.23万股"},
+		{"成交量 亿", FormatVolume(3.456e8), "3.46亿股"},
+		{"金额 负万", FormatAmount(-56789), "-5.68万元"},
+		{"金额 亿", FormatAmount(1.2e10), "120.00亿元"},
+		{"英文 K", formatVolumeLang(12345, "en"), "12.35K shares"},
+		{"英文 M", formatAmountLang(3.2e6, "en"), "3.20M"},
+		{"英文 B", formatAmountLang(-4.5e9, "en"), "-4.50B"},
+		{"英文 个位", formatVolumeLang(999, "en"), "999 shares"},
+		{"中文 lang", formatVolumeLang(12345, "zh"), "1.23万股"},
+	} {
+		if c.got != c.want {
+			t.Errorf("%s: 得到 %q，期望 %q", c.name, c.got, c.want)
+		}
+	}
+}
+
+func TestStockDataTableUsesFormatters(t *testing.T) {
+	data := syntheticBars(3)
+	data[2].Close, data[2].Volume = 12.345, 123456
+	table := FormatStockDataTable(data, make([]TechnicalIndicator, len(data)), "zh")
+	for _, want := range []string{"12.35", "12.35万股"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("行情表应包含统一格式的 %q:\n%s", want, table)
+		}
+	}
+}
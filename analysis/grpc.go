@@ -0,0 +1,208 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"Quantix/proto/quantixpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// GRPCService gRPC 接口，与 HTTP 接口共用同一个 TaskServer，两边提交的任务可互相查询
+type GRPCService struct {
+	quantixpb.UnimplementedQuantixServiceServer
+	tasks *TaskServer
+}
+
+// NewGRPCServer 创建已注册 QuantixService 的 gRPC 服务，请求指标记入 tasks.Metrics
+func NewGRPCServer(tasks *TaskServer) *grpc.Server {
+	s := grpc.NewServer(grpc.UnaryInterceptor(metricsInterceptor(tasks.Metrics)))
+	quantixpb.RegisterQuantixServiceServer(s, &GRPCService{tasks: tasks})
+	return s
+}
+
+// metricsInterceptor 按完整方法名与状态码记录 gRPC 请求，method 标签固定为 grpc
+func metricsInterceptor(m *Metrics) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		m.track(1)
+		defer m.track(-1)
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.RecordRequest("grpc", info.FullMethod, status.Code(err).String(), time.Since(start).Seconds())
+		return resp, err
+	}
+}
+
+// grpcError 按业务层错误类型映射 gRPC 状态码
+func grpcError(err error) error {
+	switch {
+	case IsRequestError(err):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ErrNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ErrUnauthorized):
+		return status.Error(codes.Unauthenticated, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func taskToPB(t Task) *quantixpb.Task {
+	return &quantixpb.Task{
+		Id:        t.ID,
+		StockCode: t.StockCode,
+		Status:    string(t.Status),
+		Report:    t.Report,
+		SavedFile: t.SavedFile,
+		Error:     t.Error,
+		CreatedAt: timestamppb.New(t.CreatedAt),
+		UpdatedAt: timestamppb.New(t.UpdatedAt),
+	}
+}
+
+func predictionToPB(r ConclusionRecord) *quantixpb.Prediction {
+	return &quantixpb.Prediction{
+		StockCode:      r.StockCode,
+		Market:         r.Market,
+		Industry:       r.Industry,
+		Stance:         r.Stance,
+		Trend:          r.Trend,
+		Recommendation: r.Recommendation,
+		TargetPrice:    r.TargetPrice,
+		CurrentPrice:   r.CurrentPrice,
+		Model:          r.Model,
+		Scores:         r.Scores,
+		Time:           timestamppb.New(r.Time),
+	}
+}
+
+func riskToPB(r RiskMetrics) *quantixpb.RiskMetrics {
+	return &quantixpb.RiskMetrics{
+		Volatility:   r.Volatility,
+		Var95:        r.VaR95,
+		Var99:        r.VaR99,
+		MaxDrawdown:  r.MaxDrawdown,
+		SharpeRatio:  r.SharpeRatio,
+		SortinoRatio: r.SortinoRatio,
+		CalmarRatio:  r.CalmarRatio,
+		Beta:         r.Beta,
+		RiskLevel:    r.RiskLevel,
+		RiskScore:    r.RiskScore,
+	}
+}
+
+// grpcToken 取 metadata authorization: Bearer <token> 中的令牌，与 HTTP 接口一致
+func grpcToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("authorization"); len(v) > 0 {
+		return bearerToken(v[0])
+	}
+	return ""
+}
+
+func (g *GRPCService) SubmitAnalysis(ctx context.Context, in *quantixpb.AnalyzeRequest) (*quantixpb.Task, error) {
+	task, err := g.tasks.SubmitAnalysis(AnalyzeRequest{
+		StockCode:   in.StockCode,
+		APIKey:      in.ApiKey,
+		Model:       in.Model,
+		Start:       in.Start,
+		End:         in.End,
+		Mode:        in.Mode,
+		Periods:     in.Periods,
+		Dims:        in.Dims,
+		Output:      in.Output,
+		Lang:        in.Lang,
+		Temperature: in.Temperature,
+		MaxTokens:   int(in.MaxTokens),
+	}, grpcToken(ctx))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return taskToPB(task), nil
+}
+
+func (g *GRPCService) GetTask(ctx context.Context, in *quantixpb.TaskRequest) (*quantixpb.Task, error) {
+	task, err := g.tasks.GetTask(in.TaskId)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return taskToPB(task), nil
+}
+
+func (g *GRPCService) CancelTask(ctx context.Context, in *quantixpb.TaskRequest) (*quantixpb.Task, error) {
+	task, err := g.tasks.CancelTask(in.TaskId)
+	if errors.Is(err, ErrNotFound) {
+		return nil, grpcError(err)
+	}
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return taskToPB(task), nil
+}
+
+func (g *GRPCService) GetPrediction(ctx context.Context, in *quantixpb.StockRequest) (*quantixpb.Prediction, error) {
+	rec, err := LatestPrediction(in.StockCode)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	pb := predictionToPB(rec)
+	if trend, err := StockMultiPeriodTrend(ctx, rec.StockCode); err != nil {
+		log.Printf("[预测] %s 多周期趋势计算失败: %v\n", rec.StockCode, err)
+	} else {
+		pb.Consistency = trend.Consistency
+		for _, p := range trend.Periods {
+			pb.Periods = append(pb.Periods, &quantixpb.PeriodTrend{Period: p.Period, Days: int32(p.Days), Trend: p.Trend, Slope: p.Slope, Confidence: p.Confidence})
+		}
+	}
+	return pb, nil
+}
+
+func (g *GRPCService) GetRisk(ctx context.Context, in *quantixpb.StockRequest) (*quantixpb.RiskReply, error) {
+	risk, info, err := StockRisk(ctx, in.StockCode, in.Start, in.End)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &quantixpb.RiskReply{StockCode: in.StockCode, Risk: riskToPB(risk), DataSource: info.Source}, nil
+}
+
+func (g *GRPCService) CompareStocks(ctx context.Context, in *quantixpb.CompareRequest) (*quantixpb.CompareReply, error) {
+	var factors []FactorWeight
+	if len(in.Factors) > 0 {
+		names := make([]string, len(in.Factors))
+		weights := make([]float64, len(in.Factors))
+		for i, f := range in.Factors {
+			names[i], weights[i] = f.Factor, f.Weight
+		}
+		var err error
+		if factors, err = ParseFactorWeights(names, weights); err != nil {
+			return nil, grpcError(err)
+		}
+	}
+	var industries map[string]string
+	if in.IndustryNeutral || len(in.Industries) > 0 {
+		industries = ResolveIndustries(in.StockCodes, in.Industries)
+	}
+	list, err := CompareStocks(ctx, in.StockCodes, in.Start, in.End, factors, industries)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	reply := &quantixpb.CompareReply{}
+	for _, c := range list {
+		item := &quantixpb.StockComparison{StockCode: c.StockCode, DataSource: c.DataSource, Error: c.Error, Score: c.Score, FactorScores: c.FactorScores, Industry: c.Industry}
+		if c.Risk != nil {
+			item.Risk = riskToPB(*c.Risk)
+		}
+		if c.Prediction != nil {
+			item.Prediction = predictionToPB(*c.Prediction)
+		}
+		reply.Stocks = append(reply.Stocks, item)
+	}
+	return reply, nil
+}
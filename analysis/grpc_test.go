@@ -0,0 +1,93 @@
+package analysis
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"Quantix/proto/quantixpb"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// dialTestGRPC 在内存连接上启动 gRPC 服务并返回客户端
+func dialTestGRPC(t *testing.T, tasks *TaskServer) quantixpb.QuantixServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := NewGRPCServer(tasks)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return quantixpb.NewQuantixServiceClient(conn)
+}
+
+func TestGRPCSubmitGetCancel(t *testing.T) {
+	bt := &blockingTransport{started: make(chan struct{}, 1), release: make(chan struct{}, 1)}
+	oldTransport, oldCache, oldDB := FetchTransport, DataCacheDir, DBPath
+	FetchTransport, DataCacheDir, DBPath = bt, t.TempDir(), ""
+	defer func() { FetchTransport, DataCacheDir, DBPath = oldTransport, oldCache, oldDB }()
+
+	s := NewTaskServer("server-key", "deepseek-chat")
+	s.AuthToken = "token"
+	client := dialTestGRPC(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	authed := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer token")
+
+	for _, c := range []struct {
+		name string
+		ctx  context.Context
+		req  *quantixpb.AnalyzeRequest
+		want codes.Code
+	}{
+		{"未带令牌", ctx, &quantixpb.AnalyzeRequest{StockCode: "600036"}, codes.Unauthenticated},
+		{"令牌错误", metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer wrong"), &quantixpb.AnalyzeRequest{StockCode: "600036"}, codes.Unauthenticated},
+		{"代码非法", authed, &quantixpb.AnalyzeRequest{StockCode: "../x"}, codes.InvalidArgument},
+		{"日期非法", authed, &quantixpb.AnalyzeRequest{StockCode: "600036", Start: "20240101"}, codes.InvalidArgument},
+	} {
+		if _, err := client.SubmitAnalysis(c.ctx, c.req); status.Code(err) != c.want {
+			t.Errorf("%s: 状态码 = %v，期望 %v（%v）", c.name, status.Code(err), c.want, err)
+		}
+	}
+
+	task, err := client.SubmitAnalysis(authed, &quantixpb.AnalyzeRequest{StockCode: "600036", Start: "2024-01-01", End: "2024-06-30"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-bt.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("任务未开始获取行情")
+	}
+	got, err := client.GetTask(ctx, &quantixpb.TaskRequest{TaskId: task.Id})
+	if err != nil || got.Status != string(TaskRunning) || got.StockCode != "600036" {
+		t.Errorf("GetTask = %v %v，期望 running", got, err)
+	}
+	got, err = client.CancelTask(ctx, &quantixpb.TaskRequest{TaskId: task.Id})
+	if err != nil || got.Status != string(TaskCanceled) {
+		t.Errorf("CancelTask = %v %v，期望 canceled", got, err)
+	}
+	select {
+	case <-bt.release:
+	case <-time.After(5 * time.Second):
+		t.Fatal("取消后行情请求未中断")
+	}
+	if _, err := client.CancelTask(ctx, &quantixpb.TaskRequest{TaskId: task.Id}); status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("重复取消状态码 = %v，期望 FailedPrecondition", status.Code(err))
+	}
+	if _, err := client.GetTask(ctx, &quantixpb.TaskRequest{TaskId: "nope"}); status.Code(err) != codes.NotFound {
+		t.Errorf("查询不存在的任务状态码 = %v，期望 NotFound", status.Code(err))
+	}
+}
@@ -1,34 +1,163 @@
 package analysis
 
 import (
+	"archive/zip"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 )
 
+// historyNameRe 报告文件名 {股票代码}-{截止日期}-{HHMMSS}[-序号].{格式}，截止日期可为空
+var historyNameRe = regexp.MustCompile(`^(.+?)-(\d{4}-\d{2}-\d{2})?-(\d{6})(?:-\d+)?\.(\w+)$`)
+
+// HistoryFilter 历史报告查询条件，零值字段不过滤
+type HistoryFilter struct {
+	StockCode string
+	Since     time.Time // 生成时间下限（含）
+	Until     time.Time // 生成时间上限（不含）
+	Formats   []string  // md/html/pdf/json
+}
+
+// HistoryEntry 一份历史报告的索引信息
+type HistoryEntry struct {
+	Name      string
+	StockCode string
+	End       string    // 分析截止日期，未指定时为空
+	Format    string    // 扩展名，不含点
+	Time      time.Time // 生成时间（文件修改时间）
+	Archive   string    // 所在归档 zip，未归档为空
+}
+
+// parseHistoryName 按报告命名规则解析文件名，feed.xml、predictions.csv 等非报告文件返回 false
+func parseHistoryName(name string) (HistoryEntry, bool) {
+	m := historyNameRe.FindStringSubmatch(name)
+	if m == nil {
+		return HistoryEntry{}, false
+	}
+	return HistoryEntry{Name: name, StockCode: m[1], End: m[2], Format: strings.ToLower(m[4])}, true
+}
+
+// match 是否满足过滤条件，股票代码忽略大小写与交易所后缀
+func (f HistoryFilter) match(e HistoryEntry) bool {
+	if f.StockCode != "" && !strings.EqualFold(NormalizeStockCode(f.StockCode), NormalizeStockCode(e.StockCode)) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	if len(f.Formats) > 0 {
+		for _, format := range f.Formats {
+			if strings.EqualFold(strings.TrimPrefix(format, "."), e.Format) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// QueryHistory 扫描 history 目录与归档 zip 建立报告索引，按条件过滤后按生成时间倒序返回；同名文件以目录中的为准
+func QueryHistory(filter HistoryFilter) []HistoryEntry {
+	var entries []HistoryEntry
+	seen := make(map[string]bool)
+	files, _ := ioutil.ReadDir(HistoryDir)
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		if e, ok := parseHistoryName(f.Name()); ok {
+			e.Time = f.ModTime()
+			seen[e.Name] = true
+			if filter.match(e) {
+				entries = append(entries, e)
+			}
+		}
+	}
+	zips, _ := filepath.Glob(filepath.Join(ArchiveDir, "history-*.zip"))
+	for _, z := range zips {
+		zr, err := zip.OpenReader(z)
+		if err != nil {
+			continue
+		}
+		for _, f := range zr.File {
+			if seen[f.Name] {
+				continue
+			}
+			if e, ok := parseHistoryName(f.Name); ok {
+				e.Time, e.Archive = f.Modified, filepath.Base(z)
+				seen[e.Name] = true
+				if filter.match(e) {
+					entries = append(entries, e)
+				}
+			}
+		}
+		zr.Close()
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if !entries[i].Time.Equal(entries[j].Time) {
+			return entries[i].Time.After(entries[j].Time)
+		}
+		return entries[i].Name > entries[j].Name
+	})
+	return entries
+}
+
+// ListHistoryEntries 按条件列出历史报告
+func ListHistoryEntries(filter HistoryFilter) {
+	entries := QueryHistory(filter)
+	if len(entries) == 0 {
+		log.Println("[历史记录] 没有符合条件的分析记录。")
+		return
+	}
+	log.Printf("[历史记录] 共 %d 份符合条件的分析记录：\n", len(entries))
+	for _, e := range entries {
+		line := fmt.Sprintf("%s\t%s", e.Time.Format("2006-01-02 15:04"), e.Name)
+		if e.Archive != "" {
+			line += fmt.Sprintf("（已归档：%s）", e.Archive)
+		}
+		fmt.Println(line)
+	}
+}
+
 func ListHistoryFiles() {
-	files, err := ioutil.ReadDir("history")
+	files, err := ioutil.ReadDir(HistoryDir)
 	if err != nil {
-		fmt.Println("[历史记录] 无法读取 history 目录：", err)
+		log.Println("[历史记录] 无法读取 history 目录：", err)
 		return
 	}
-	if len(files) == 0 {
-		fmt.Println("[历史记录] 暂无历史分析记录。")
+	archived := ListArchivedFiles()
+	if len(files) == 0 && len(archived) == 0 {
+		log.Println("[历史记录] 暂无历史分析记录。")
 		return
 	}
-	fmt.Println("[历史记录] 可用分析记录：")
+	log.Println("[历史记录] 可用分析记录：")
 	for _, f := range files {
 		if !f.IsDir() {
 			fmt.Println(f.Name())
 		}
 	}
+	names := make([]string, 0, len(archived))
+	for name := range archived {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s（已归档：%s）\n", name, archived[name])
+	}
 }
 
 func ShowHistoryFile(filename string) {
-	path := filepath.Join("history", filename)
-	data, err := ioutil.ReadFile(path)
+	data, err := ReadHistoryFile(filename)
 	if err != nil {
-		fmt.Println("[历史记录] 读取失败：", err)
+		log.Println("[历史记录] 读取失败：", err)
 		return
 	}
 	fmt.Println(string(data))
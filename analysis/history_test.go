@@ -0,0 +1,70 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseHistoryName(t *testing.T) {
+	for _, c := range []struct {
+		name              string
+		ok                bool
+		code, end, format string
+	}{
+		{"600036-2024-03-29-150000.md", true, "600036", "2024-03-29", "md"},
+		{"600036.SH-2024-03-29-150000-2.html", true, "600036.SH", "2024-03-29", "html"},
+		{"000001--093000.PDF", true, "000001", "", "pdf"},
+		{"BTC-USD-2024-06-01-220000.json", true, "BTC-USD", "2024-06-01", "json"},
+		{"feed.xml", false, "", "", ""},
+		{"predictions.csv", false, "", "", ""},
+		{"600036-scoretrend.png", false, "", "", ""},
+	} {
+		e, ok := parseHistoryName(c.name)
+		if ok != c.ok || e.StockCode != c.code || e.End != c.end || e.Format != c.format {
+			t.Errorf("%s: %+v %v", c.name, e, ok)
+		}
+	}
+}
+
+func TestQueryHistoryFilters(t *testing.T) {
+	day := func(d, h int) time.Time { return time.Date(2024, 6, d, h, 0, 0, 0, time.Local) }
+	useHistoryDir(t, map[string]time.Time{
+		"600036-2024-05-31-150000.md":       day(1, 15),
+		"600036-2024-05-31-150000.html":     day(1, 15),
+		"600036.SH-2024-06-07-090000-2.pdf": day(8, 9),
+		"000001--093000.md":                 day(3, 9),
+		"AAPL-2024-06-10-220000.JSON":       day(10, 22),
+		"feed.xml":                          day(11, 0),
+		"600036-scoretrend.png":             day(11, 0),
+	})
+	names := func(entries []HistoryEntry) []string {
+		var list []string
+		for _, e := range entries {
+			list = append(list, e.Name)
+		}
+		return list
+	}
+	for _, c := range []struct {
+		desc   string
+		filter HistoryFilter
+		want   []string
+	}{
+		{"全部按时间倒序，非报告文件不入索引", HistoryFilter{},
+			[]string{"AAPL-2024-06-10-220000.JSON", "600036.SH-2024-06-07-090000-2.pdf", "000001--093000.md", "600036-2024-05-31-150000.md", "600036-2024-05-31-150000.html"}},
+		{"代码忽略交易所后缀", HistoryFilter{StockCode: "600036"},
+			[]string{"600036.SH-2024-06-07-090000-2.pdf", "600036-2024-05-31-150000.md", "600036-2024-05-31-150000.html"}},
+		{"代码忽略大小写", HistoryFilter{StockCode: "aapl"}, []string{"AAPL-2024-06-10-220000.JSON"}},
+		{"格式忽略大小写与点", HistoryFilter{Formats: []string{".json", "PDF"}},
+			[]string{"AAPL-2024-06-10-220000.JSON", "600036.SH-2024-06-07-090000-2.pdf"}},
+		{"日期范围含下限不含上限", HistoryFilter{Since: day(3, 9), Until: day(10, 22)},
+			[]string{"600036.SH-2024-06-07-090000-2.pdf", "000001--093000.md"}},
+		{"条件组合", HistoryFilter{StockCode: "600036.SH", Formats: []string{"md", "html"}, Until: day(2, 0)},
+			[]string{"600036-2024-05-31-150000.md", "600036-2024-05-31-150000.html"}},
+		{"无匹配", HistoryFilter{StockCode: "600519"}, nil},
+	} {
+		if got := names(QueryHistory(c.filter)); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: %v，期望 %v", c.desc, got, c.want)
+		}
+	}
+}
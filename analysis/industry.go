@@ -0,0 +1,178 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+)
+
+// IndustryMapFile 自定义行业映射，格式 {"stocks": {"600519": "白酒"}, "indexes": {"白酒": {"code": "399997", "name": "中证白酒"}}}，与内置映射合并且优先
+var IndustryMapFile = "industry.json"
+
+// IndustryIndex 行业指数
+type IndustryIndex struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+// 内置股票 -> 行业映射，覆盖常见权重股，其余依赖自定义映射或历史报告
+var builtinStockIndustry = map[string]string{
+	"600519": "白酒", "000858": "白酒", "000568": "白酒", "600809": "白酒",
+	"600036": "银行", "601398": "银行", "601288": "银行", "000001": "银行", "601166": "银行",
+	"600030": "证券", "300059": "证券", "601688": "证券",
+	"300750": "新能源车", "002594": "新能源车",
+	"300760": "医疗", "300015": "医疗",
+	"000002": "地产", "600048": "地产",
+	"601088": "煤炭", "600188": "煤炭",
+	"601899": "有色金属", "603993": "有色金属",
+	"600760": "军工", "600893": "军工",
+	"000333": "家电", "000651": "家电", "600690": "家电",
+	"601318": "保险", "601628": "保险",
+	"00700": "互联网", "09988": "互联网", "03690": "互联网",
+	"AAPL": "消费电子", "NVDA": "半导体", "MSFT": "软件", "TSLA": "新能源车",
+}
+
+// 内置行业 -> 行业指数（深市指数，各数据源均可按 6 位代码获取）
+var builtinIndustryIndex = map[string]IndustryIndex{
+	"白酒":   {Code: "399997", Name: "中证白酒"},
+	"银行":   {Code: "399986", Name: "中证银行"},
+	"证券":   {Code: "399975", Name: "证券公司"},
+	"新能源车": {Code: "399976", Name: "CS新能车"},
+	"医疗":   {Code: "399989", Name: "中证医疗"},
+	"地产":   {Code: "399393", Name: "国证地产"},
+	"煤炭":   {Code: "399998", Name: "中证煤炭"},
+	"有色金属": {Code: "399395", Name: "国证有色"},
+	"军工":   {Code: "399967", Name: "中证军工"},
+	"传媒":   {Code: "399971", Name: "中证传媒"},
+	"食品饮料": {Code: "399396", Name: "国证食品"},
+}
+
+// IndustryContext 注入 prompt 的行业上下文
+type IndustryContext struct {
+	Industry string // 为空表示未知行业
+	Source   string // 自定义映射/内置映射/历史报告
+	Index    IndustryIndex
+	Change5  float64 // 行业指数近 5 日涨跌幅
+	Change20 float64 // 行业指数近 20 日涨跌幅
+	HasIndex bool    // 是否成功获取行业指数表现
+}
+
+type industryMapConfig struct {
+	Stocks  map[string]string        `json:"stocks"`
+	Indexes map[string]IndustryIndex `json:"indexes"`
+}
+
+// loadIndustryMap 读取自定义映射，文件不存在或格式错误时返回空映射
+func loadIndustryMap() industryMapConfig {
+	var cfg industryMapConfig
+	if data, err := ioutil.ReadFile(IndustryMapFile); err == nil {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			log.Printf("[行业] 解析 %s 失败，忽略自定义映射: %v\n", IndustryMapFile, err)
+		}
+	}
+	return cfg
+}
+
+// classifyIndustry 依次查自定义映射、内置映射、历史报告中最近一次提及的行业，均无返回空
+func classifyIndustry(stockCode string, cfg industryMapConfig, records []ConclusionRecord) (industry, source string) {
+	if v := cfg.Stocks[stockCode]; v != "" {
+		return v, "自定义映射"
+	}
+	if v := builtinStockIndustry[stockCode]; v != "" {
+		return v, "内置映射"
+	}
+	var latest *ConclusionRecord
+	for i, r := range records {
+		if r.StockCode == stockCode && r.Industry != "" && (latest == nil || r.Time.After(latest.Time)) {
+			latest = &records[i]
+		}
+	}
+	if latest != nil {
+		return latest.Industry, "历史报告"
+	}
+	return "", ""
+}
+
+// industryIndexFor 行业对应的指数，自定义映射优先
+func industryIndexFor(industry string, cfg industryMapConfig) (IndustryIndex, bool) {
+	if idx, ok := cfg.Indexes[industry]; ok && idx.Code != "" {
+		return idx, true
+	}
+	idx, ok := builtinIndustryIndex[industry]
+	return idx, ok
+}
+
+// periodChange 最近 days 个交易日涨跌幅（数据需按日期升序）
+func periodChange(data []StockData, days int) float64 {
+	n := len(data)
+	if n < 2 {
+		return 0
+	}
+	if days > n-1 {
+		days = n - 1
+	}
+	base := data[n-1-days].Close
+	if base <= 0 {
+		return 0
+	}
+	return (data[n-1].Close - base) / base
+}
+
+// LoadIndustryContext 归类行业并获取行业指数近期表现，指数获取失败或 ctx 取消时仅保留行业名称
+func LoadIndustryContext(ctx context.Context, stockCode string) IndustryContext {
+	cfg := loadIndustryMap()
+	records, _ := LoadConclusionRecords()
+	var ic IndustryContext
+	ic.Industry, ic.Source = classifyIndustry(stockCode, cfg, records)
+	if ic.Industry == "" {
+		return ic
+	}
+	idx, ok := industryIndexFor(ic.Industry, cfg)
+	if !ok {
+		return ic
+	}
+	ic.Index = idx
+	data, _, err := fetchFromSources(ctx, idx.Code)
+	if err != nil || len(data) < 2 {
+		log.Printf("[行业] 获取行业指数 %s(%s) 失败，仅注入行业名称: %v\n", idx.Name, idx.Code, err)
+		return ic
+	}
+	sort.Slice(data, func(i, j int) bool { return data[i].Date.Before(data[j].Date) })
+	ic.Change5, ic.Change20, ic.HasIndex = periodChange(data, 5), periodChange(data, 20), true
+	return ic
+}
+
+// ResolveIndustries 多股对比的行业归属：overrides（代码→行业）优先，其余依次查自定义映射、内置映射、历史报告，无法识别的不列出
+func ResolveIndustries(stockCodes []string, overrides map[string]string) map[string]string {
+	cfg := loadIndustryMap()
+	records, _ := LoadConclusionRecords()
+	industries := make(map[string]string, len(stockCodes))
+	for _, code := range stockCodes {
+		code = strings.TrimSpace(code)
+		if v := overrides[code]; v != "" {
+			industries[code] = v
+			continue
+		}
+		if v, _ := classifyIndustry(NormalizeStockCode(code), cfg, records); v != "" {
+			industries[code] = v
+		}
+	}
+	return industries
+}
+
+// FormatIndustryPrompt 行业上下文 prompt 片段（按 lang 输出）；未知行业时要求模型自行判断并写明所属行业
+func FormatIndustryPrompt(ctx IndustryContext, lang string) string {
+	w := wrapperFor(lang)
+	if ctx.Industry == "" {
+		return w.industryUnknown
+	}
+	msg := fmt.Sprintf(w.industry, localize(ctx.Industry, lang), localize(ctx.Source, lang))
+	if ctx.HasIndex {
+		msg += fmt.Sprintf(w.industryIndex, localize(ctx.Index.Name, lang), ctx.Index.Code, FormatPercent(ctx.Change5), FormatPercent(ctx.Change20))
+	}
+	return msg + w.industryTail
+}
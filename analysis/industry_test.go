@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifyIndustryPrecedence(t *testing.T) {
+	cfg := industryMapConfig{Stocks: map[string]string{"600519": "食品饮料"}}
+	day := time.Date(2024, 6, 3, 0, 0, 0, 0, time.Local)
+	records := []ConclusionRecord{
+		{StockCode: "688981", Industry: "芯片", Time: day},
+		{StockCode: "688981", Industry: "半导体", Time: day.AddDate(0, 0, 1)},
+		{StockCode: "688981", Time: day.AddDate(0, 0, 2)},
+	}
+	for _, c := range []struct{ code, industry, source string }{
+		{"600519", "食品饮料", "自定义映射"},
+		{"600036", "银行", "内置映射"},
+		{"688981", "半导体", "历史报告"},
+		{"830799", "", ""},
+	} {
+		if industry, source := classifyIndustry(c.code, cfg, records); industry != c.industry || source != c.source {
+			t.Errorf("%s: 行业 %q（%s），期望 %q（%s）", c.code, industry, source, c.industry, c.source)
+		}
+	}
+}
+
+func TestLoadIndustryContextInjectsIndexPerformance(t *testing.T) {
+	dir := t.TempDir()
+	oldMap, oldConclusions := IndustryMapFile, ConclusionFile
+	IndustryMapFile, ConclusionFile = filepath.Join(dir, "industry.json"), filepath.Join(dir, "conclusions.jsonl")
+	defer func() { IndustryMapFile, ConclusionFile = oldMap, oldConclusions }()
+	os.WriteFile(IndustryMapFile, []byte(`{"stocks": {"688981": "半导体"}, "indexes": {"半导体": {"code": "H30184", "name": "半导体指数"}, "银行": {"code": "399001", "name": "自定义银行"}}}`), 0644)
+
+	fail := false
+	bars := datedBars(30)
+	for i := range bars {
+		bars[i].Close = 100 + float64(i)
+	}
+	stubStockSource(t, bars, &fail)
+
+	ic := LoadIndustryContext(context.Background(), "600036")
+	if ic.Industry != "银行" || ic.Source != "内置映射" || ic.Index.Code != "399001" || !ic.HasIndex {
+		t.Fatalf("行业上下文 = %+v，自定义指数应优先", ic)
+	}
+	if want5, want20 := 5.0/124, 20.0/109; !floatsEqual([]float64{ic.Change5, ic.Change20}, []float64{want5, want20}) {
+		t.Errorf("近5日 %v、近20日 %v，期望 %v、%v", ic.Change5, ic.Change20, want5, want20)
+	}
+	prompt := FormatIndustryPrompt(ic, "")
+	for _, want := range []string{"【行业上下文】所属行业：银行", "自定义银行(399001) 近5日 4.03%，近20日 18.35%", "同行业共性"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("行业 prompt 缺少 %q:\n%s", want, prompt)
+		}
+	}
+
+	// 指数获取失败时只注入行业名称
+	fail = true
+	ic = LoadIndustryContext(context.Background(), "688981")
+	if ic.Industry != "半导体" || ic.HasIndex {
+		t.Errorf("指数失败时应仅保留行业: %+v", ic)
+	}
+	if prompt := FormatIndustryPrompt(ic, ""); !strings.Contains(prompt, "所属行业：半导体") || strings.Contains(prompt, "行业指数") {
+		t.Errorf("行业 prompt:\n%s", prompt)
+	}
+}
+
+func TestFormatIndustryPromptUnknown(t *testing.T) {
+	prompt := FormatIndustryPrompt(IndustryContext{}, "")
+	if !strings.Contains(prompt, "未能识别所属行业") || !strings.Contains(prompt, "所属行业：XX") {
+		t.Errorf("未知行业应要求模型自行判断:\n%s", prompt)
+	}
+	if en := FormatIndustryPrompt(IndustryContext{}, LangEN); containsHan(en) || !strings.Contains(en, "Industry: XX") {
+		t.Errorf("英文未知行业提示:\n%s", en)
+	}
+}
@@ -0,0 +1,429 @@
+package analysis
+
+import (
+	"regexp"
+	"strings"
+)
+
+// LangEN 英文报告，AnalysisParams.Lang 为其他值时沿用中文模板
+const LangEN = "en"
+
+// isEnglish Lang 是否为英文（en、en-US 等）
+func isEnglish(lang string) bool {
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	return lang == LangEN || strings.HasPrefix(lang, LangEN+"-") || strings.HasPrefix(lang, LangEN+"_")
+}
+
+// radarDimEnglish 评分维度的英文名，英文 prompt 要求模型按英文名输出，解析时两种写法都识别
+var radarDimEnglish = map[string]string{
+	"技术面": "Technical",
+	"基本面": "Fundamental",
+	"资金面": "Capital Flow",
+	"情绪面": "Sentiment",
+}
+
+// promptTemplate BuildPrompt 的固定文字，%s/%d 占位符两种语言保持一致
+type promptTemplate struct {
+	online        string // %s 股票代码
+	offline       string // %s 股票代码
+	timeRange     string // %s 开始、%s 结束
+	periods       string
+	dims          string
+	risk          string
+	lang          string
+	predHeader    string
+	predTypes     string
+	predItems     string
+	confidence    string
+	detail        string
+	format        string
+	anomaly       string
+	lowConfidence string
+	dimScores     string // %s 维度列表、%s 示例维度
+	listSep       string
+}
+
+var promptTemplates = map[string]promptTemplate{
+	"zh": {
+		online: `请联网获取股票%s的最新股价、最新公告和新闻，分析时以最新联网数据为准。
+
+【重要】数据验证要求：
+1. 请联网查询该股票的最新收盘价，并与本地K线数据对比
+2. 如果最新联网价格与本地数据差异超过5%%，请以联网数据为准
+3. 在报告开头明确标注：
+   - 最新联网价格：XX.XX元（查询时间：YYYY-MM-DD HH:MM）
+   - 本地数据最新价格：XX.XX元（日期：YYYY-MM-DD）
+   - 数据差异：+/-X.XX元（X.XX%%）
+4. 如果发现价格异常（如超过1000元或低于0.01元），请重新查询并标注"数据异常，已重新验证"
+
+请确保获取的是真实准确的股价数据，不要使用过时或错误的价格信息。`,
+		offline:       "请对股票代码 %s 进行智能分析。\n",
+		timeRange:     "分析时间范围：%s 至 %s\n",
+		periods:       "预测周期：%s\n",
+		dims:          "分析维度：%s\n",
+		risk:          "风险偏好：%s\n",
+		lang:          "输出语言：%s\n",
+		predHeader:    "\n【预测要求】\n",
+		predTypes:     "预测类型：%s\n",
+		predItems:     "具体预测项目：%s\n",
+		confidence:    "每个预测结论都需要提供置信度/概率区间\n",
+		detail:        "\n请提供详细的技术分析和投资建议，包含上述所有预测项目。",
+		format:        "\n\n【格式要求】\n1. 多周期预测请用markdown表格输出，表头包含：周期、趋势判断、关键价位、置信度、主要驱动因素/理由。\n2. 综合预测结论请用markdown表格输出，表头包含：预测项目、预测值/区间、置信度、主要驱动因素/理由。\n3. 若某项预测不适用或数据不足，请在表格中注明'数据不足'或'-'。\n4. 结论部分请分为'主要结论'、'风险提示'、'操作建议'三块，分别用表格或要点输出。",
+		anomaly:       "\n5. 请对比最新股价与历史K线（如最近30日均价、最高价、最低价），如最新价与历史均值/区间差异超过10%，请在报告开头高亮提示'行情异动'，并简要分析可能原因。",
+		lowConfidence: "\n6. 如果多周期预测或综合结论中某项置信度低于60%，请在该行或结论部分自动加'风险提示'（如'预测不确定性较高，请谨慎参考'）。",
+		dimScores:     "\n7. 请在报告末尾输出'维度评分'，按%s逐行给出 0-100 的评分，格式如'%s：75'，无法评估的维度注明'数据不足'。",
+		listSep:       "、",
+	},
+	LangEN: {
+		online: `Please search online for the latest price, announcements and news of stock %s, and base the analysis on the latest online data.
+
+[IMPORTANT] Data verification requirements:
+1. Look up the latest closing price online and compare it with the local K-line data
+2. If the online price differs from the local data by more than 5%%, use the online data
+3. State clearly at the beginning of the report:
+   - Latest online price: XX.XX (queried at: YYYY-MM-DD HH:MM)
+   - Latest local price: XX.XX (date: YYYY-MM-DD)
+   - Difference: +/-X.XX (X.XX%%)
+4. If the price looks abnormal (e.g. above 1000 or below 0.01), query again and mark it "Data anomaly, re-verified"
+
+Make sure the price data is real and accurate; do not use outdated or incorrect prices.`,
+		offline:       "Please analyze stock %s.\n",
+		timeRange:     "Analysis period: %s to %s\n",
+		periods:       "Forecast horizons: %s\n",
+		dims:          "Analysis dimensions: %s\n",
+		risk:          "Risk preference: %s\n",
+		lang:          "Output language: %s. Write the entire report in English.\n",
+		predHeader:    "\n[Forecast Requirements]\n",
+		predTypes:     "Forecast types: %s\n",
+		predItems:     "Forecast items: %s\n",
+		confidence:    "Every forecast must include a confidence level or probability range\n",
+		detail:        "\nPlease provide a detailed technical analysis and investment advice covering all forecast items above.",
+		format:        "\n\n[Format Requirements]\n1. Present multi-horizon forecasts as a markdown table with columns: Horizon, Trend, Key Levels, Confidence, Main Drivers/Rationale.\n2. Present the overall forecast conclusions as a markdown table with columns: Forecast Item, Value/Range, Confidence, Main Drivers/Rationale.\n3. If a forecast is not applicable or data is insufficient, write 'N/A' or '-' in the table.\n4. Split the conclusion into 'Key Conclusions', 'Risk Warnings' and 'Recommendations', each as a table or bullet points.",
+		anomaly:       "\n5. Compare the latest price with the historical K-line data (e.g. 30-day average, high and low). If the latest price deviates from the historical average/range by more than 10%, highlight 'Abnormal Price Movement' at the beginning of the report and briefly analyze possible causes.",
+		lowConfidence: "\n6. If any multi-horizon forecast or overall conclusion has confidence below 60%, add a 'Risk Warning' to that row or to the conclusion (e.g. 'High forecast uncertainty, use with caution').",
+		dimScores:     "\n7. At the end of the report, output 'Dimension Scores' with one 0-100 score per line for %s, formatted like '%s: 75'; write 'N/A' for dimensions that cannot be assessed.",
+		listSep:       ", ",
+	},
+}
+
+// templateFor Lang 对应的 prompt 模板，非英文时使用中文
+func templateFor(lang string) promptTemplate {
+	if isEnglish(lang) {
+		return promptTemplates[LangEN]
+	}
+	return promptTemplates["zh"]
+}
+
+// tableLabels 风险表、回测表的标题与表头
+type tableLabels struct {
+	riskTitle      string
+	riskHead       []string
+	riskExtHead    []string
+	backtestTitle  string
+	backtestHeader []string
+	mlTitle        string
+	mlHeader       []string
+	moneyFlowTitle string
+	moneyFlowHead  []string
+	attrTitle      string
+	attrDrawdown   string // 最大回撤、起止日期、区间内亏损交易数
+	attrHead       []string
+}
+
+var reportTableLabels = map[string]tableLabels{
+	"zh": {
+		riskTitle:      "【风险指标】",
+		riskHead:       []string{"波动率", "最大回撤", "夏普比率", "VaR(95%)", "风险等级", "风险评分"},
+		riskExtHead:    []string{"索提诺比率", "卡玛比率", "下行偏差", "偏度", "峰度", "VaR(99%)"},
+		backtestTitle:  "【策略回测结果】",
+		backtestHeader: []string{"策略类型", "参数", "初始资金", "总收益率", "年化收益率", "夏普比率", "胜率", "最大回撤", "盈亏比", "交易次数"},
+		mlTitle:        "【机器学习预测】",
+		mlHeader:       []string{"方法", "下一日", "下一周", "下一月", "置信度", "趋势"},
+		moneyFlowTitle: "【资金流向】",
+		moneyFlowHead:  []string{"OBV趋势", "近5日主力净流入", "近20日主力净流入", "量比(5/20)", "近5日涨跌", "量价信号"},
+		attrTitle:      "【回测风险归因】",
+		attrDrawdown:   "最大回撤 %s，区间 %s ~ %s，区间内亏损交易 %d 笔",
+		attrHead:       []string{"排名", "买入日期", "卖出日期", "买入价", "卖出价", "亏损金额", "收益率", "平仓原因"},
+	},
+	LangEN: {
+		riskTitle:      "[Risk Metrics]",
+		riskHead:       []string{"Volatility", "Max Drawdown", "Sharpe Ratio", "VaR(95%)", "Risk Level", "Risk Score"},
+		riskExtHead:    []string{"Sortino Ratio", "Calmar Ratio", "Downside Deviation", "Skewness", "Kurtosis", "VaR(99%)"},
+		backtestTitle:  "[Backtest Results]",
+		backtestHeader: []string{"Strategy", "Parameters", "Initial Capital", "Total Return", "Annualized Return", "Sharpe Ratio", "Win Rate", "Max Drawdown", "Profit Factor", "Trades"},
+		mlTitle:        "[Machine Learning Forecast]",
+		mlHeader:       []string{"Method", "Next Day", "Next Week", "Next Month", "Confidence", "Trend"},
+		moneyFlowTitle: "[Money Flow]",
+		moneyFlowHead:  []string{"OBV Trend", "5-day Main Net Inflow", "20-day Main Net Inflow", "Volume Ratio (5/20)", "5-day Change", "Volume-Price Signal"},
+		attrTitle:      "[Backtest Risk Attribution]",
+		attrDrawdown:   "Max drawdown %s from %s to %s, %d losing trades in the period",
+		attrHead:       []string{"Rank", "Entry Date", "Exit Date", "Entry Price", "Exit Price", "Loss", "Return", "Exit Reason"},
+	},
+}
+
+// labelsFor Lang 对应的表头，非英文时使用中文
+func labelsFor(lang string) tableLabels {
+	if isEnglish(lang) {
+		return reportTableLabels[LangEN]
+	}
+	return reportTableLabels["zh"]
+}
+
+// markdownHeader 拼接 markdown 表头与分隔行
+func markdownHeader(cols []string) string {
+	return "| " + strings.Join(cols, " | ") + " |\n|" + strings.Repeat("---|", len(cols)) + "\n"
+}
+
+// htmlHeader 拼接 HTML 表头行；调用方的 fmt 模板中 % 需转义
+func htmlHeader(cols []string) string {
+	return "<tr><th>" + strings.Join(cols, "</th><th>") + "</th></tr>"
+}
+
+// reportKeywords 从报告中识别小节与结论所用的关键词；报告语言未知时按各语言并集识别
+type reportKeywords struct {
+	sections   []string // 必备结论小节，完整性评分用
+	trend      []string // 趋势判断所在行
+	advice     []string // 操作建议所在行
+	target     []string // 目标价所在行
+	bullish    []string
+	bearish    []string
+	buy        []string
+	sell       []string
+	stopLoss   []string
+	upProb     []string
+	downProb   []string
+	confidence []string
+	industry   []string
+}
+
+var reportKeywordSets = map[string]reportKeywords{
+	"zh": {
+		sections:   []string{"主要结论", "风险提示", "操作建议"},
+		trend:      []string{"趋势"},
+		advice:     []string{"建议"},
+		target:     []string{"目标价"},
+		bullish:    []string{"看涨", "看多", "上涨", "多头", "上升", "走强"},
+		bearish:    []string{"看跌", "看空", "下跌", "空头", "下降", "走弱"},
+		buy:        []string{"买入", "增持", "加仓", "建仓"},
+		sell:       []string{"卖出", "减持", "减仓", "清仓"},
+		stopLoss:   []string{"止损"},
+		upProb:     []string{"上涨概率", "上行概率"},
+		downProb:   []string{"下跌概率", "下行概率"},
+		confidence: []string{"置信度"},
+		industry:   []string{"所属行业", "所处行业", "行业"},
+	},
+	LangEN: {
+		sections:   []string{"Key Conclusions", "Risk Warnings", "Recommendations"},
+		trend:      []string{"trend", "outlook"},
+		advice:     []string{"recommend", "advice", "rating"},
+		target:     []string{"target price", "price target"},
+		bullish:    []string{"bullish", "uptrend", "upward"},
+		bearish:    []string{"bearish", "downtrend", "downward"},
+		buy:        []string{"buy", "accumulate", "overweight"},
+		sell:       []string{"sell", "underweight"},
+		stopLoss:   []string{"stop loss", "stop-loss"},
+		upProb:     []string{"upside probability", "probability of rise", "probability of rising", "up probability"},
+		downProb:   []string{"downside probability", "probability of decline", "probability of falling", "down probability"},
+		confidence: []string{"confidence"},
+		industry:   []string{"industry", "sector"},
+	},
+}
+
+// keywordsFor Lang 对应的关键词，非英文时使用中文
+func keywordsFor(lang string) reportKeywords {
+	if isEnglish(lang) {
+		return reportKeywordSets[LangEN]
+	}
+	return reportKeywordSets["zh"]
+}
+
+// allKeywords 各语言关键词的并集（小写），中文在前
+func allKeywords(pick func(reportKeywords) []string) []string {
+	var words []string
+	for _, lang := range []string{"zh", LangEN} {
+		for _, w := range pick(reportKeywordSets[lang]) {
+			words = append(words, strings.ToLower(w))
+		}
+	}
+	return words
+}
+
+// keywordPattern 各语言关键词拼成不区分大小写的正则分组，供数值抽取正则使用
+func keywordPattern(pick func(reportKeywords) []string) string {
+	words := allKeywords(pick)
+	for i, w := range words {
+		words[i] = regexp.QuoteMeta(w)
+	}
+	return "(?i:" + strings.Join(words, "|") + ")"
+}
+
+// containsAny 文本（不区分大小写）是否包含任一关键词，words 须为小写
+func containsAny(text string, words []string) bool {
+	lower := strings.ToLower(text)
+	for _, w := range words {
+		if strings.Contains(lower, w) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapperText AnalyzeOne 包在 prompt 外层的固定说明，以及同时写入 prompt 与报告的数据区文字
+type wrapperText struct {
+	dateNotice      string // %s 当前日期
+	confirm         string
+	industryUnknown string
+	industry        string // %s 行业、%s 来源
+	industryIndex   string // %s 指数名、%s 指数代码、%s 近5日、%s 近20日
+	industryTail    string
+	divergence      string // %s 背离列表
+	divergenceItem  string // 日期、指标、类型、前后价格、指标、前后指标值
+	itemSep         string
+	realtime        string // 实时价、时间、本地收盘、日期、差异
+	realtimeAlert   string // %s 阈值
+	sourceRealtime  string // 层级、数据源、截至日期
+	sourceCache     string // 层级、缓存时间、已缓存时长、截至日期
+	sourceCSV       string // 层级、文件、截至日期
+	sourceLLM       string // 层级
+	stockTableTitle string
+	stockTableHead  []string
+	moneyFlow       string // OBV 趋势、OBV 变化、5日净流入、20日净流入、量比、5日涨跌、量价信号
+	qualityNote     string // 总分、完整性、一致性、数据支撑
+	qualityMissing  string
+	recommend       string // 操作、仓位上限、加权得分、风险等级、信号列表
+	recommendSignal string // 来源、方向、置信度
+	recommendHit    string // 来源、方向、回看天数、命中率
+	recommendSplit  string
+}
+
+var wrapperTexts = map[string]wrapperText{
+	"zh": {
+		dateNotice:      "\n【重要提示】本系统优先使用 DeepSeek 联网模式获取最新行情和分析，只有在联网失败时才尝试本地数据源。请严格以当前分析时间 %s 为准，禁止引用AI自身认知的时间或任何与本地参数不符的时间信息。若分析区间超出数据范围，请直接说明“数据不足”，不要虚构或假设当前时间。\n",
+		confirm:         "\n请再次确认，所有分析均以当前分析时间为准，不要引用AI自身时间认知。\n",
+		industryUnknown: "\n【行业上下文】未能识别所属行业，请根据公司主营业务判断，并在报告中写明“所属行业：XX”，结合该行业近期表现与共性进行分析。\n",
+		industry:        "\n【行业上下文】所属行业：%s（来源：%s）",
+		industryIndex:   "，行业指数 %s(%s) 近5日 %s，近20日 %s",
+		industryTail:    "。请结合行业景气度、同行业共性与个股相对行业的强弱进行分析。\n",
+		divergence:      "\n> 【指标背离】%s。背离提示潜在反转，请结合量能与趋势确认。\n",
+		divergenceItem:  "%s %s %s（价格 %.2f→%.2f，%s %.2f→%.2f）",
+		itemSep:         "；",
+		realtime:        "> 【实时行情】最新价 %.2f（%s，腾讯行情），本地最新收盘 %.2f（%s），差异 %+.2f%%",
+		realtimeAlert:   "，⚠️ 行情异动（超过 %s），请以实时价为准并分析原因",
+		sourceRealtime:  "\n> 【数据来源】%s（%s），行情截至 %s\n",
+		sourceCache:     "\n> 【数据来源】%s（实时接口全部失败，使用 %s 缓存的数据，已缓存 %s），行情截至 %s，请注意时效\n",
+		sourceCSV:       "\n> 【数据来源】%s（%s，离线模式），行情截至 %s\n",
+		sourceLLM:       "\n> 【数据来源】%s（实时接口与本地缓存均不可用，行情以模型联网结果为准）\n",
+		stockTableTitle: "\n【历史行情数据表】\n",
+		stockTableHead:  []string{"日期", "开盘", "收盘", "最高", "最低", "成交量", "MA5", "MA10", "MA20", "MA60", "MA120", "MA250", "EMA12", "EMA26", "DEMA20", "TEMA20", "MACD", "KDJ(K/D/J)", "RSI6", "RSI12", "BOLL上轨", "BOLL中轨", "BOLL下轨"},
+		moneyFlow:       "\n【资金面本地数据】OBV 近10日%s（变化 %s），近5日主力净流入估算 %s，近20日 %s，量比(5/20) %s，近5日涨跌 %s，量价信号：%s。资金面分析请以以上数据为依据，不要凭空推测。\n",
+		qualityNote:     "\n> [!NOTE] 报告质量评分 %.0f（完整性 %.0f / 一致性 %.0f / 数据支撑 %.0f），仅供谨慎参考",
+		qualityMissing:  "；未覆盖：",
+		recommend:       "\n> 【综合建议】%s，建议仓位不超过 %d%%（加权得分 %+.2f，风险等级 %s）\n> 信号：%s\n",
+		recommendSignal: "%s %+.2f（置信度 %s）",
+		recommendHit:    "%s %+.2f（近%d日命中率 %s）",
+		recommendSplit:  "> 各信号多空分歧，仓位已减半，请谨慎参考\n",
+	},
+	LangEN: {
+		dateNotice:      "\n[IMPORTANT] This system prefers the DeepSeek online mode for the latest quotes and analysis and only falls back to local data sources when online access fails. Treat %s as the current analysis date; do not rely on your own notion of the current time or on any time information that contradicts the local parameters. If the analysis period exceeds the available data, state \"Insufficient data\" instead of inventing or assuming the current time.\n",
+		confirm:         "\nPlease confirm again that all analysis is based on the current analysis date above, not on your own notion of time.\n",
+		industryUnknown: "\n[Industry Context] The industry could not be identified. Determine it from the company's main business, state \"Industry: XX\" in the report, and take the industry's recent performance and common traits into account.\n",
+		industry:        "\n[Industry Context] Industry: %s (source: %s)",
+		industryIndex:   "; industry index %s(%s) 5-day change %s, 20-day change %s",
+		industryTail:    ". Consider the industry cycle, traits shared with peers and the stock's strength relative to the industry.\n",
+		divergence:      "\n> [Indicator Divergence] %s. Divergence signals a potential reversal; confirm it with volume and trend.\n",
+		divergenceItem:  "%s %s %s (price %.2f→%.2f, %s %.2f→%.2f)",
+		itemSep:         "; ",
+		realtime:        "> [Realtime Quote] Latest price %.2f (%s, Tencent), latest local close %.2f (%s), difference %+.2f%%",
+		realtimeAlert:   ", ⚠️ abnormal price movement (over %s); use the realtime price and analyze the cause",
+		sourceRealtime:  "\n> [Data Source] %s (%s), quotes up to %s\n",
+		sourceCache:     "\n> [Data Source] %s (all realtime APIs failed; using data cached at %s, %s old), quotes up to %s; mind the staleness\n",
+		sourceCSV:       "\n> [Data Source] %s (%s, offline mode), quotes up to %s\n",
+		sourceLLM:       "\n> [Data Source] %s (realtime APIs and local cache unavailable; quotes rely on the model's online search)\n",
+		stockTableTitle: "\n[Historical Quotes]\n",
+		stockTableHead:  []string{"Date", "Open", "Close", "High", "Low", "Volume", "MA5", "MA10", "MA20", "MA60", "MA120", "MA250", "EMA12", "EMA26", "DEMA20", "TEMA20", "MACD", "KDJ(K/D/J)", "RSI6", "RSI12", "BOLL Upper", "BOLL Middle", "BOLL Lower"},
+		moneyFlow:       "\n[Local Capital Flow Data] OBV 10-day trend %s (change %s), estimated 5-day main net inflow %s, 20-day %s, volume ratio (5/20) %s, 5-day price change %s, volume-price signal: %s. Base the capital flow analysis on this data; do not speculate.\n",
+		qualityNote:     "\n> [!NOTE] Report quality score %.0f (completeness %.0f / consistency %.0f / data support %.0f), use with caution",
+		qualityMissing:  "; missing: ",
+		recommend:       "\n> [Overall Recommendation] %s, position no more than %d%% (weighted score %+.2f, risk level %s)\n> Signals: %s\n",
+		recommendSignal: "%s %+.2f (confidence %s)",
+		recommendHit:    "%s %+.2f (%d-day hit rate %s)",
+		recommendSplit:  "> Signals disagree on direction; position halved, use with caution\n",
+	},
+}
+
+// wrapperFor Lang 对应的外层固定文字，非英文时使用中文
+func wrapperFor(lang string) wrapperText {
+	if isEnglish(lang) {
+		return wrapperTexts[LangEN]
+	}
+	return wrapperTexts["zh"]
+}
+
+// termEnglish 交互选项、指标状态、数据源、内置行业等中文取值的英文写法，英文报告的 prompt 与数据区按此翻译
+var termEnglish = map[string]string{
+	// 预测周期
+	"1天": "1 day", "3天": "3 days", "1周": "1 week", "2周": "2 weeks", "1月": "1 month", "2月": "2 months", "3月": "3 months",
+	"半年": "6 months", "1年": "1 year", "2年": "2 years", "3年": "3 years",
+	"短期(1-7天)": "Short term (1-7 days)", "中期(1-3月)": "Medium term (1-3 months)", "长期(3-12月)": "Long term (3-12 months)", "超长期(1年以上)": "Very long term (over 1 year)",
+	// 分析维度
+	"技术面": "Technical", "基本面": "Fundamental", "资金面": "Capital Flow", "情绪面": "Sentiment",
+	"行业对比": "Industry Comparison", "情绪分析": "Sentiment Analysis", "K线形态": "Candlestick Patterns", "均线系统": "Moving Averages",
+	"成交量分析": "Volume Analysis", "技术指标": "Technical Indicators", "支撑阻力": "Support and Resistance", "财务数据": "Financial Data",
+	"盈利能力": "Profitability", "估值分析": "Valuation", "行业地位": "Industry Position", "管理层": "Management",
+	"主力资金": "Main Capital", "北向资金": "Northbound Capital", "大宗交易": "Block Trades", "机构持仓": "Institutional Holdings",
+	"散户情绪": "Retail Sentiment", "新闻舆情": "News Sentiment", "研报分析": "Research Reports", "公告解读": "Announcements",
+	"论坛讨论": "Forum Discussions", "社交媒体": "Social Media", "宏观经济": "Macroeconomy", "政策影响": "Policy Impact",
+	"国际环境": "International Environment", "产业链": "Industry Chain", "竞争格局": "Competitive Landscape",
+	// 风险偏好
+	"保守": "Conservative", "稳健": "Moderate", "激进": "Aggressive", "风险为主": "Risk-focused", "机会为主": "Opportunity-focused", "平衡型": "Balanced",
+	// 预测类型
+	"价格预测": "Price forecast", "波动率预测": "Volatility forecast", "成交量预测": "Volume forecast", "涨跌概率预测": "Up/down probability forecast",
+	"技术指标预测": "Technical indicator forecast", "基本面指标预测": "Fundamental indicator forecast", "情绪评分预测": "Sentiment score forecast",
+	"市场定位预测": "Market positioning forecast", "竞争优势预测": "Competitive advantage forecast", "风险等级预测": "Risk level forecast",
+	// 背离、OBV 与量价信号
+	DivergenceTop: "bearish divergence", DivergenceBottom: "bullish divergence",
+	"上升": "rising", "下降": "falling", "走平": "flat",
+	"放量上涨": "rising on heavy volume", "放量下跌": "falling on heavy volume", "缩量上涨": "rising on light volume", "缩量下跌": "falling on light volume", "量价平稳": "stable volume and price",
+	// 机器学习预测方法与趋势
+	"决策树": "Decision Tree", "随机森林": "Random Forest", "集成": "Ensemble", "上涨": "Up", "下跌": "Down", "震荡": "Sideways",
+	// 回测平仓原因
+	"死叉卖出": "Death cross sell", "止损": "Stop loss", "止盈": "Take profit", "期末平仓": "Closed at period end",
+	"跌破区间低点卖出": "Breakdown sell", "RSI超买卖出": "RSI overbought sell", "ATR追踪止损": "ATR trailing stop",
+	// 综合建议的信号来源、操作与风险等级
+	"LLM结论": "LLM conclusion", "本地趋势": "Local trend", "资金流向": "Money flow", "机器学习": "Machine learning",
+	"买入": "Buy", "增持": "Add", "持有": "Hold", "减持": "Reduce", "卖出": "Sell",
+	"低风险": "Low risk", "中低风险": "Medium-low risk", "中风险": "Medium risk", "高风险": "High risk", "极高风险": "Very high risk", "数据不足": "Insufficient data",
+	// 数据层级与数据源
+	"实时接口": "Realtime API", "本地缓存": "Local cache", "本地CSV": "Local CSV", "LLM联网": "LLM online search",
+	"雪球API": "Xueqiu API", "网易API": "NetEase API", "腾讯API": "Tencent API",
+	// 行业归类来源、内置行业与行业指数
+	"自定义映射": "custom mapping", "内置映射": "built-in mapping", "历史报告": "past reports",
+	"白酒": "Baijiu", "银行": "Banking", "证券": "Securities", "新能源车": "New Energy Vehicles", "医疗": "Healthcare", "地产": "Real Estate",
+	"煤炭": "Coal", "有色金属": "Non-ferrous Metals", "军工": "Defense", "传媒": "Media", "食品饮料": "Food and Beverage",
+	"保险": "Insurance", "家电": "Home Appliances", "半导体": "Semiconductors", "软件": "Software", "互联网": "Internet", "消费电子": "Consumer Electronics",
+	"中证白酒": "CSI Baijiu", "中证银行": "CSI Banks", "证券公司": "CSI Securities Companies", "CS新能车": "CSI New Energy Vehicles",
+	"中证医疗": "CSI Healthcare", "国证地产": "CNI Real Estate", "中证煤炭": "CSI Coal", "国证有色": "CNI Non-ferrous Metals",
+	"中证军工": "CSI Defense", "中证传媒": "CSI Media", "国证食品": "CNI Food and Beverage",
+}
+
+// localize 英文报告时按 termEnglish 翻译，未收录的取值原样返回
+func localize(term, lang string) string {
+	if isEnglish(lang) {
+		if en, ok := termEnglish[term]; ok {
+			return en
+		}
+	}
+	return term
+}
+
+// localizeList 逐项 localize
+func localizeList(terms []string, lang string) []string {
+	if !isEnglish(lang) {
+		return terms
+	}
+	out := make([]string, len(terms))
+	for i, t := range terms {
+		out[i] = localize(t, lang)
+	}
+	return out
+}
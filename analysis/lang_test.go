@@ -0,0 +1,232 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"unicode"
+)
+
+const englishReport = `## Key Conclusions
+| Forecast Item | Value/Range | Confidence | Main Drivers/Rationale |
+|---|---|---|---|
+| Target price | 62.50 | 70% | Technical breakout above MA60 at 55.20 |
+| Up probability | 65% | 65% | Rising OBV, 5-day inflow 12.3M |
+
+Trend: bullish, the uptrend is intact with MACD at 1.25 and RSI at 58.
+Recommendation: Buy on pullbacks toward 56.00.
+Stop loss: 52.80
+Overall confidence: 70%
+
+Technical analysis shows support at 54.10 and resistance at 60.30; Fundamental valuation PE 12.5x is below the 5-year average of 15.2x.
+
+## Risk Warnings
+- Volatility 28% annualized, max drawdown 18%.
+
+## Recommendations
+- Position size 30%, add above 60.30.
+
+Dimension Scores
+Technical: 78
+Fundamental: 70
+Capital Flow: 65
+Sentiment: 60
+`
+
+func TestScoreReportQualityEnglish(t *testing.T) {
+	params := AnalysisParams{Lang: "en", Dims: []string{"技术面", "基本面"}}
+	q := ScoreReportQuality(englishReport, params)
+	if q.Completeness != 100 {
+		t.Errorf("完整性 = %.1f，期望 100，未覆盖: %v", q.Completeness, q.Missing)
+	}
+	if q.Total < QualityThreshold {
+		t.Errorf("英文报告总分 %.2f 低于阈值 %.0f", q.Total, QualityThreshold)
+	}
+	// 中文小节名对英文报告不适用，中文参数下仍按中文小节评分
+	if zh := ScoreReportQuality(englishReport, AnalysisParams{}); zh.Completeness != 0 {
+		t.Errorf("中文参数下完整性 = %.1f，期望 0", zh.Completeness)
+	}
+}
+
+func TestExtractConclusionEnglish(t *testing.T) {
+	c := ExtractConclusion(englishReport, 57.3)
+	if textDirection(c.Trend, bullishWords, bearishWords) != 1 {
+		t.Errorf("趋势 %q 应识别为偏多", c.Trend)
+	}
+	if textDirection(c.Recommendation, buyWords, sellWords) != 1 {
+		t.Errorf("建议 %q 应识别为买入", c.Recommendation)
+	}
+	if c.TargetPrice != 62.5 {
+		t.Errorf("目标价 = %v，期望 62.5", c.TargetPrice)
+	}
+	if issues := CheckReportConsistency(c); len(issues) != 0 {
+		t.Errorf("不应有一致性冲突: %v", issues)
+	}
+	p := ExtractPrediction("AAPL", "2024-06-28", englishReport, c)
+	if p.StopLoss != 52.8 || p.UpProb != 65 || p.Trend != "偏多" || p.Advice != "偏多" {
+		t.Errorf("预测记录解析错误: %+v", p)
+	}
+	if got := matchFloat(confidenceRe, englishReport); got != 70 {
+		t.Errorf("置信度 = %v，期望 70", got)
+	}
+}
+
+func TestExtractConclusionChinese(t *testing.T) {
+	report := "趋势判断：看涨\n操作建议：逢低买入\n目标价：12.5 元\n止损：9.8\n上涨概率 60%"
+	c := ExtractConclusion(report, 10)
+	if c.TargetPrice != 12.5 || c.Trend == "" || c.Recommendation == "" {
+		t.Errorf("中文结论解析错误: %+v", c)
+	}
+	p := ExtractPrediction("600036", "", report, c)
+	if p.StopLoss != 9.8 || p.UpProb != 60 {
+		t.Errorf("中文预测解析错误: %+v", p)
+	}
+}
+
+// failingTransport 行情请求直接失败，测试中不访问网络
+type failingTransport struct{}
+
+func (failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("测试中禁止联网")
+}
+
+// datedBars n 根工作日K线，起始于 2024-01-02
+func datedBars(n int) []StockData {
+	data := syntheticBars(n)
+	day := time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)
+	for i := range data {
+		for day.Weekday() == time.Saturday || day.Weekday() == time.Sunday {
+			day = day.AddDate(0, 0, 1)
+		}
+		data[i].Date = day
+		day = day.AddDate(0, 0, 1)
+	}
+	return data
+}
+
+// writeTestCSV 写入 n 根工作日K线，起始于 2024-01-02
+func writeTestCSV(t *testing.T, path string, n int) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("date,open,high,low,close,volume\n")
+	for _, d := range datedBars(n) {
+		fmt.Fprintf(&b, "%s,%.2f,%.2f,%.2f,%.2f,%.0f\n", d.Date.Format("2006-01-02"), d.Open, d.High, d.Low, d.Close, d.Volume)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// containsHan 是否含中文字符，用于检查英文报告片段
+func containsHan(s string) bool {
+	for _, r := range s {
+		if unicode.Is(unicode.Han, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// analyzeCapturePrompt 在临时目录中以 CSV 离线模式运行 AnalyzeOne，返回交给大模型的 prompt
+func analyzeCapturePrompt(t *testing.T, params AnalysisParams, report string) string {
+	t.Helper()
+	prompt, _ := analyzeCSV(t, params, report, 150)
+	return prompt
+}
+
+// analyzeCSV 同 analyzeCapturePrompt，CSV 写入 bars 根K线，并返回分析结果
+func analyzeCSV(t *testing.T, params AnalysisParams, report string, bars int) (string, AnalysisResult) {
+	t.Helper()
+	wd, _ := os.Getwd()
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	oldTransport, oldRetries, oldPNG := FetchTransport, FetchMaxRetries, html2png
+	FetchTransport, FetchMaxRetries = failingTransport{}, 0
+	html2png = func(ctx context.Context, htmlPath, pngPath string) error { return nil }
+	defer func() {
+		FetchTransport, FetchMaxRetries, html2png = oldTransport, oldRetries, oldPNG
+		os.Chdir(wd)
+	}()
+
+	params.DataSource, params.CSVDir = DataSourceCSV, filepath.Join(dir, "csv")
+	os.MkdirAll(params.CSVDir, 0755)
+	writeTestCSV(t, filepath.Join(params.CSVDir, params.StockCodes[0]+".csv"), bars)
+	var prompt string
+	result := AnalyzeOne(context.Background(), params, func(stock, p, apiKey, apiURL, model string, searchMode, hybridSearch bool) (string, error) {
+		prompt = p
+		return report, nil
+	})
+	if result.Err != nil {
+		t.Fatalf("分析失败: %v", result.Err)
+	}
+	return prompt, result
+}
+
+func TestAnalyzeOneEnglishPromptHasNoChinese(t *testing.T) {
+	params := AnalysisParams{
+		StockCodes:      []string{"600036"}, // 内置映射为银行，覆盖行业上下文的翻译
+		Start:           "2024-01-01",
+		End:             "2024-12-31",
+		Lang:            "en",
+		Model:           "deepseek-chat",
+		Periods:         []string{"1周", "1月", "3月"},
+		Dims:            []string{"技术面", "基本面", "资金面", "行业对比", "情绪分析"},
+		Risk:            "稳健",
+		PredictionTypes: []string{"价格预测", "涨跌概率预测", "风险等级预测"},
+		Confidence:      true,
+	}
+	prompt := analyzeCapturePrompt(t, params, englishReport)
+	if prompt == "" {
+		t.Fatal("未调用大模型")
+	}
+	for i, r := range prompt {
+		if unicode.Is(unicode.Han, r) {
+			start := i - 60
+			if start < 0 {
+				start = 0
+			}
+			end := i + 60
+			if end > len(prompt) {
+				end = len(prompt)
+			}
+			t.Fatalf("英文 prompt 含中文字符，位置 %d 附近: %q", i, prompt[start:end])
+		}
+	}
+	for _, want := range []string{"[IMPORTANT]", "[Industry Context] Industry: Banking", "[Historical Quotes]", "[Data Source] Local CSV", "[Local Capital Flow Data]", "Risk preference: Moderate"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("英文 prompt 缺少 %q", want)
+		}
+	}
+}
+
+func TestAnalyzeOneChinesePromptKeepsChineseWrapper(t *testing.T) {
+	params := AnalysisParams{StockCodes: []string{"600036"}, Start: "2024-01-01", End: "2024-12-31", Model: "deepseek-chat"}
+	prompt := analyzeCapturePrompt(t, params, "趋势：看涨\n操作建议：买入")
+	for _, want := range []string{"【重要提示】", "【行业上下文】所属行业：银行", "【历史行情数据表】", "请再次确认"} {
+		if !strings.Contains(prompt, want) {
+			t.Errorf("中文 prompt 缺少 %q", want)
+		}
+	}
+}
+
+func TestRiskTableEnglishRiskLevel(t *testing.T) {
+	for level, want := range map[string]string{"低风险": "Low risk", "中风险": "Medium risk", "高风险": "High risk", "数据不足": "Insufficient data"} {
+		risk := RiskMetrics{RiskLevel: level, Volatility: 0.3, RiskScore: 55}
+		for name, table := range map[string]string{"markdown": FormatRiskTable(risk, LangEN), "html": FormatRiskTableHTML(risk, LangEN)} {
+			if containsHan(table) || !strings.Contains(table, want) {
+				t.Errorf("%s 英文风险表的风险等级应为 %q:\n%s", name, want, table)
+			}
+		}
+		if zh := FormatRiskTable(risk, ""); !strings.Contains(zh, level) {
+			t.Errorf("中文风险表应保留 %s:\n%s", level, zh)
+		}
+	}
+}
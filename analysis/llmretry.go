@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LLMMaxRetries 大模型接口返回 429 限流或 503 过载时的最大重试次数（不含首次请求）
+var LLMMaxRetries = 3
+
+// LLMBackoff 响应未带 Retry-After 时首次重试前的等待时间，之后每次翻倍
+var LLMBackoff = 2 * time.Second
+
+// LLMMaxRetryWait 单次等待上限，避免 Retry-After 过大时长时间阻塞批量任务
+var LLMMaxRetryWait = time.Minute
+
+// llmRetryableStatus 仅 429 与 503 重试，其他非 200 多为请求本身有误（如 Key 无效、参数错误），直接返回
+func llmRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// parseRetryAfter 解析 Retry-After：秒数或 HTTP 日期，无法解析时返回 false
+func parseRetryAfter(v string, now time.Time) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// postLLMWithRetry POST 大模型接口，429/503 时按 Retry-After（缺省为指数退避）等待后重试至多 LLMMaxRetries 次；
+// 返回首个非 429/503 的响应，由调用方检查状态码并关闭 Body。ctx 取消时立即返回 ctx.Err()
+func postLLMWithRetry(ctx context.Context, client *http.Client, apiURL string, data []byte, headers map[string]string) (*http.Response, error) {
+	backoff := LLMBackoff
+	var lastErr error
+	for attempt := 0; attempt <= LLMMaxRetries; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", apiURL, strings.NewReader(string(data)))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if !llmRetryableStatus(resp.StatusCode) {
+			return resp, nil
+		}
+		respData, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		lastErr = fmt.Errorf("DeepSeek API 返回状态码 %d", resp.StatusCode)
+		if msg := strings.TrimSpace(string(respData)); msg != "" {
+			lastErr = fmt.Errorf("%v: %s", lastErr, msg)
+		}
+		if attempt == LLMMaxRetries {
+			break
+		}
+		wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		if !ok {
+			wait = backoff
+		}
+		if wait > LLMMaxRetryWait {
+			wait = LLMMaxRetryWait
+		}
+		backoff *= 2
+		log.Printf("[大模型] 第 %d 次请求返回 %d，%v 后重试\n", attempt+1, resp.StatusCode, wait.Round(time.Millisecond))
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return nil, fmt.Errorf("重试 %d 次后仍失败: %v", LLMMaxRetries, lastErr)
+}
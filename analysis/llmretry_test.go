@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGenerateAIReportRetriesOn429(t *testing.T) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			fmt.Fprint(w, `{"choices":[{"message":{"content":"重试后成功"}}]}`)
+		}
+	}))
+	defer srv.Close()
+	oldRetries, oldBackoff := LLMMaxRetries, LLMBackoff
+	LLMMaxRetries, LLMBackoff = 3, time.Millisecond
+	defer func() { LLMMaxRetries, LLMBackoff = oldRetries, oldBackoff }()
+
+	report, err := GenerateAIReportWithContext(context.Background(), "600036", "prompt", "sk", srv.URL, "m", false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report != "重试后成功" || atomic.LoadInt32(&calls) != 3 {
+		t.Errorf("报告 %q，请求 %d 次，期望第 3 次成功", report, calls)
+	}
+}
+
+func TestPostLLMWithRetryLimits(t *testing.T) {
+	var calls int32
+	status := http.StatusTooManyRequests
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	oldRetries, oldBackoff := LLMMaxRetries, LLMBackoff
+	LLMMaxRetries, LLMBackoff = 2, time.Millisecond
+	defer func() { LLMMaxRetries, LLMBackoff = oldRetries, oldBackoff }()
+
+	if _, err := postLLMWithRetry(context.Background(), &http.Client{}, srv.URL, nil, nil); err == nil || calls != 3 {
+		t.Errorf("持续 429 应在 1+%d 次后失败，请求 %d 次，err=%v", LLMMaxRetries, calls, err)
+	}
+
+	// 其他 4xx 不重试，原样返回给调用方
+	calls, status = 0, http.StatusUnauthorized
+	resp, err := postLLMWithRetry(context.Background(), &http.Client{}, srv.URL, nil, nil)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || calls != 1 {
+		t.Errorf("401 应直接返回，请求 %d 次，err=%v", calls, err)
+	}
+	if resp != nil {
+		resp.Body.Close()
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	for _, c := range []struct {
+		in   string
+		want time.Duration
+		ok   bool
+	}{
+		{"5", 5 * time.Second, true},
+		{" 0 ", 0, true},
+		{"-1", 0, false},
+		{"", 0, false},
+		{"soon", 0, false},
+		{now.Add(30 * time.Second).Format(http.TimeFormat), 30 * time.Second, true},
+		{now.Add(-time.Minute).Format(http.TimeFormat), 0, true},
+	} {
+		if got, ok := parseRetryAfter(c.in, now); got != c.want || ok != c.ok {
+			t.Errorf("parseRetryAfter(%q) = %v %v，期望 %v %v", c.in, got, ok, c.want, c.ok)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package analysis
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 市场，取值与交易日历、结论统计中的“市场”字段一致
+const (
+	MarketA      = "A股"
+	MarketHK     = "港股"
+	MarketUS     = "美股"
+	MarketCrypto = "加密"
+)
+
+// cryptoPairRe 加密货币交易对：BTC-USD、ETH/USDT、BTCUSDT
+var cryptoPairRe = regexp.MustCompile(`^[A-Z0-9]{2,10}(?:[-/](?:USD|USDT|USDC|EUR|CNY|BTC|ETH)|USDT|USDC)$`)
+
+// StockMarket 根据代码粗分市场：BTC-USD 等交易对为加密，6 位数字为 A股，1-5 位数字（可带 .HK 后缀）为港股，
+// 其余（AAPL、BRK.A 等）视为美股。港股判断与 hkCode 一致，数据源按同一规则转换代码
+func StockMarket(stockCode string) string {
+	code := strings.ToUpper(strings.TrimSpace(stockCode))
+	digits := code != "" && strings.TrimLeft(code, "0123456789") == ""
+	switch {
+	case cryptoPairRe.MatchString(code) && !digits:
+		return MarketCrypto
+	case digits && len(code) == 6:
+		return MarketA
+	case hkCode(code) != "":
+		return MarketHK
+	default:
+		return MarketUS
+	}
+}
+
+// hkCode 港股代码补零为 5 位：700、0700.HK、00700 → 00700；不是港股代码时返回空
+func hkCode(stockCode string) string {
+	code := strings.ToUpper(strings.TrimSpace(stockCode))
+	code = strings.TrimSuffix(code, ".HK")
+	if code == "" || len(code) > 5 || strings.TrimLeft(code, "0123456789") != "" {
+		return ""
+	}
+	return strings.Repeat("0", 5-len(code)) + code
+}
+
+// MarketProfile 市场的价格合理性范围与回测交易费率
+type MarketProfile struct {
+	MinPrice float64 // 收盘价下限
+	MaxPrice float64 // 收盘价上限，0 表示不限
+	BuyFee   float64 // 买入费率（佣金等，按成交额比例）
+	SellFee  float64 // 卖出费率（佣金+印花税等）
+}
+
+// MarketProfiles 各市场默认参数：A股佣金万 2.5、卖出另收 0.05% 印花税；港股双边印花税 0.1% 加佣金与征费；
+// 美股上限放宽到 100 万美元（BRK.A 约 60 万）；加密货币不设上限，下限按最小报价精度
+var MarketProfiles = map[string]MarketProfile{
+	MarketA:      {MinPrice: 0.01, MaxPrice: 10000, BuyFee: 0.00025, SellFee: 0.00075},
+	MarketHK:     {MinPrice: 0.001, MaxPrice: 100000, BuyFee: 0.0013, SellFee: 0.0013},
+	MarketUS:     {MinPrice: 0.0001, MaxPrice: 1000000, BuyFee: 0.0003, SellFee: 0.0003},
+	MarketCrypto: {MinPrice: 1e-8, BuyFee: 0.001, SellFee: 0.001},
+}
+
+// MarketProfileFor 按代码推断市场并返回其参数，代码先去掉交易所后缀（600519.SH 按 A股计）；未配置的市场沿用 A股的价格范围且不计费用
+func MarketProfileFor(stockCode string) MarketProfile {
+	if p, ok := MarketProfiles[StockMarket(NormalizeStockCode(stockCode))]; ok {
+		return p
+	}
+	return MarketProfile{MinPrice: 0.01, MaxPrice: 10000}
+}
+
+// priceInRange 收盘价是否在市场合理范围内
+func (p MarketProfile) priceInRange(price float64) bool {
+	return price >= p.MinPrice && (p.MaxPrice <= 0 || price <= p.MaxPrice)
+}
+
+// yahooCryptoSymbol 交易对转为 Yahoo 格式：BTC/USDT、BTCUSDT → BTC-USDT
+func yahooCryptoSymbol(stockCode string) string {
+	code := strings.ToUpper(strings.TrimSpace(stockCode))
+	code = strings.ReplaceAll(code, "/", "-")
+	if !strings.Contains(code, "-") {
+		for _, quote := range []string{"USDT", "USDC"} {
+			if base := strings.TrimSuffix(code, quote); base != code {
+				return base + "-" + quote
+			}
+		}
+	}
+	return code
+}
@@ -0,0 +1,134 @@
+package analysis
+
+import "testing"
+
+func TestStockMarket(t *testing.T) {
+	cases := map[string]string{
+		"600519":   MarketA,
+		"000001":   MarketA,
+		"00700":    MarketHK,
+		"0700.HK":  MarketHK,
+		"700":      MarketHK,
+		"09988.hk": MarketHK,
+		"AAPL":     MarketUS,
+		"BRK.A":    MarketUS,
+		"ABC.HK":   MarketUS,
+		"BTC-USD":  MarketCrypto,
+		"ETHUSDT":  MarketCrypto,
+		"":         MarketUS,
+	}
+	for code, want := range cases {
+		if got := StockMarket(code); got != want {
+			t.Errorf("StockMarket(%q) = %s，期望 %s", code, got, want)
+		}
+		if got := IsHKStock(code); got != (want == MarketHK) {
+			t.Errorf("IsHKStock(%q) = %v，与 StockMarket 不一致", code, got)
+		}
+	}
+}
+
+func TestNormalizeStockCode(t *testing.T) {
+	cases := map[string]string{
+		" 600519.SH ": "600519",
+		"000001.sz":   "000001",
+		"600519.SS":   "600519",
+		"0700.HK":     "00700",
+		"700":         "00700",
+		"00700":       "00700",
+		"AAPL":        "AAPL",
+		"BRK.A":       "BRK.A",
+		"123.SH":      "123.SH",
+	}
+	for in, want := range cases {
+		if got := NormalizeStockCode(in); got != want {
+			t.Errorf("NormalizeStockCode(%q) = %q，期望 %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeSymbol(t *testing.T) {
+	cases := []struct{ code, source, want string }{
+		{"600036", sourceTencent, "sh600036"},
+		{"000001", sourceTencent, "sz000001"},
+		{"300750", sourceNetEase, "0.300750"},
+		{"600036", sourceNetEase, "1.600036"},
+		{"600036", sourceXueqiu, "SH600036"},
+		{"000001", sourceYahoo, "000001.SZ"},
+		{"00700", sourceTencent, "hk00700"},
+		{"0700.HK", sourceTencent, "hk00700"},
+		{"700", sourceYahoo, "0700.HK"},
+		{"09988", sourceYahoo, "9988.HK"},
+		{"00700", sourceXueqiu, "00700"},
+		{"BTC/USDT", sourceYahoo, "BTC-USDT"},
+		{"AAPL", sourceTencent, "AAPL"},
+	}
+	for _, c := range cases {
+		if got := normalizeSymbol(c.code, c.source); got != c.want {
+			t.Errorf("normalizeSymbol(%q, %s) = %q，期望 %q", c.code, c.source, got, c.want)
+		}
+	}
+}
+
+func TestStockMarketCryptoPairs(t *testing.T) {
+	for _, code := range []string{"ETH/USDT", "btc-usd", "SOL-USDC", "DOGEUSDC", "ETH-BTC"} {
+		if got := StockMarket(code); got != MarketCrypto {
+			t.Errorf("StockMarket(%q) = %s，期望加密", code, got)
+		}
+	}
+	for _, code := range []string{"USDT", "TSLA"} {
+		if got := StockMarket(code); got == MarketCrypto {
+			t.Errorf("StockMarket(%q) 不应识别为加密", code)
+		}
+	}
+}
+
+func TestValidateDataUsesMarketPriceRange(t *testing.T) {
+	bar := func(price float64) StockData {
+		return StockData{Open: price, High: price, Low: price, Close: price, Volume: 100}
+	}
+	for _, c := range []struct {
+		code  string
+		price float64
+		keep  bool
+	}{
+		{"600036", 38.5, true},
+		{"600036", 0.005, false},
+		{"600036", 12000, false},
+		{"600036.SH", 12000, false},
+		{"00700", 0.005, true},
+		{"00700", 50000, true},
+		{"00700", 200000, false},
+		{"BRK.A", 620000, true},
+		{"BRK.A", 2000000, false},
+		{"SHIB-USD", 0.00001, true},
+		{"BTC-USD", 2000000, true},
+	} {
+		got := validateAndFilterData([]StockData{bar(c.price)}, c.code)
+		if (len(got) == 1) != c.keep {
+			t.Errorf("%s 收盘价 %v: 保留 = %v，期望 %v", c.code, c.price, len(got) == 1, c.keep)
+		}
+	}
+}
+
+func TestMarketProfilesAndSources(t *testing.T) {
+	a, us := MarketProfileFor("600036.SH"), MarketProfileFor("AAPL")
+	if a.SellFee <= a.BuyFee || us.SellFee != us.BuyFee || MarketProfileFor("BTC-USD").MaxPrice != 0 {
+		t.Errorf("A股卖出应另收印花税、美股双边同费、加密不设上限: %+v %+v", a, us)
+	}
+	ids := func(market string) []string {
+		var list []string
+		for _, src := range sourcesForMarket(market) {
+			list = append(list, src.id)
+		}
+		return list
+	}
+	if got := ids(MarketCrypto); len(got) != 1 || got[0] != sourceYahoo {
+		t.Errorf("加密货币只能走 Yahoo: %v", got)
+	}
+	if got := ids(MarketUS); len(got) != len(stockDataSources) || got[0] != sourceYahoo {
+		t.Errorf("美股应优先 Yahoo 并保留其余数据源: %v", got)
+	}
+	if got := ids(MarketA); got[0] != sourceTencent {
+		t.Errorf("A股应优先腾讯: %v", got)
+	}
+}
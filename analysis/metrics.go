@@ -0,0 +1,179 @@
+package analysis
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricBuckets 请求耗时直方图的桶上界（秒）
+var metricBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metricKey struct {
+	Method string
+	Path   string
+	Status string
+}
+
+type requestStat struct {
+	count   uint64
+	sum     float64
+	buckets []uint64 // 与 metricBuckets 一一对应，非累计
+}
+
+// Metrics HTTP/gRPC 接口的请求计数、耗时直方图与进行中请求数，按 Prometheus 文本格式输出
+type Metrics struct {
+	mu       sync.Mutex
+	requests map[metricKey]*requestStat
+	inFlight int64
+}
+
+// NewMetrics 创建空的指标集合
+func NewMetrics() *Metrics {
+	return &Metrics{requests: make(map[metricKey]*requestStat)}
+}
+
+// RecordRequest 记录一次请求，path 应为路由模板（如 /tasks/{id}）以免标签基数膨胀
+func (m *Metrics) RecordRequest(method, path, status string, seconds float64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	k := metricKey{method, path, status}
+	st, ok := m.requests[k]
+	if !ok {
+		st = &requestStat{buckets: make([]uint64, len(metricBuckets))}
+		m.requests[k] = st
+	}
+	st.count++
+	st.sum += seconds
+	for i, le := range metricBuckets {
+		if seconds <= le {
+			st.buckets[i]++
+			break
+		}
+	}
+}
+
+// track 进行中请求数加减
+func (m *Metrics) track(delta int64) {
+	m.mu.Lock()
+	m.inFlight += delta
+	m.mu.Unlock()
+}
+
+// RequestCount 指定方法、路由与状态码的累计请求数
+func (m *Metrics) RequestCount(method, path, status string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if st, ok := m.requests[metricKey{method, path, status}]; ok {
+		return st.count
+	}
+	return 0
+}
+
+// Write 按 Prometheus 文本格式输出全部指标
+func (m *Metrics) Write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]metricKey, 0, len(m.requests))
+	for k := range m.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Method != b.Method {
+			return a.Method < b.Method
+		}
+		return a.Status < b.Status
+	})
+
+	fmt.Fprintln(w, "# HELP quantix_requests_total 接口请求总数")
+	fmt.Fprintln(w, "# TYPE quantix_requests_total counter")
+	for _, k := range keys {
+		fmt.Fprintf(w, "quantix_requests_total{method=%q,path=%q,status=%q} %d\n", k.Method, k.Path, k.Status, m.requests[k].count)
+	}
+	fmt.Fprintln(w, "# HELP quantix_request_duration_seconds 接口请求耗时")
+	fmt.Fprintln(w, "# TYPE quantix_request_duration_seconds histogram")
+	for _, k := range keys {
+		st := m.requests[k]
+		labels := fmt.Sprintf("method=%q,path=%q", k.Method, k.Path)
+		var cum uint64
+		for i, le := range metricBuckets {
+			cum += st.buckets[i]
+			fmt.Fprintf(w, "quantix_request_duration_seconds_bucket{%s,status=%q,le=%q} %d\n", labels, k.Status, strconv.FormatFloat(le, 'g', -1, 64), cum)
+		}
+		fmt.Fprintf(w, "quantix_request_duration_seconds_bucket{%s,status=%q,le=\"+Inf\"} %d\n", labels, k.Status, st.count)
+		fmt.Fprintf(w, "quantix_request_duration_seconds_sum{%s,status=%q} %g\n", labels, k.Status, st.sum)
+		fmt.Fprintf(w, "quantix_request_duration_seconds_count{%s,status=%q} %d\n", labels, k.Status, st.count)
+	}
+	fmt.Fprintln(w, "# HELP quantix_requests_in_flight 正在处理的请求数")
+	fmt.Fprintln(w, "# TYPE quantix_requests_in_flight gauge")
+	fmt.Fprintf(w, "quantix_requests_in_flight %d\n", m.inFlight)
+
+	hits, misses := MLCacheStats()
+	fmt.Fprintln(w, "# HELP quantix_cache_requests_total 机器学习预测缓存查询次数")
+	fmt.Fprintln(w, "# TYPE quantix_cache_requests_total counter")
+	fmt.Fprintf(w, "quantix_cache_requests_total{result=\"hit\"} %d\n", hits)
+	fmt.Fprintf(w, "quantix_cache_requests_total{result=\"miss\"} %d\n", misses)
+	fmt.Fprintln(w, "# HELP quantix_cache_hit_ratio 机器学习预测缓存命中率")
+	fmt.Fprintln(w, "# TYPE quantix_cache_hit_ratio gauge")
+	fmt.Fprintf(w, "quantix_cache_hit_ratio %g\n", MLCacheHitRatio())
+}
+
+// Handler /metrics 端点
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.Write(w)
+	})
+}
+
+// statusRecorder 记录 handler 写出的状态码
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// Hijack 供 WebSocket 升级使用，升级成功记为 101
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter 不支持 Hijack")
+	}
+	s.status = http.StatusSwitchingProtocols
+	return h.Hijack()
+}
+
+// Middleware 记录每个请求的方法、路由模板、状态码与耗时；未匹配路由的请求统一记为 unmatched
+func (m *Metrics) Middleware(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.track(1)
+		defer m.track(-1)
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		// 路由模板形如 "GET /tasks/{id}"，方法已单独作为标签
+		_, pattern := mux.Handler(r)
+		if _, path, ok := strings.Cut(pattern, " "); ok {
+			pattern = path
+		}
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		mux.ServeHTTP(rec, r)
+		m.RecordRequest(r.Method, pattern, strconv.Itoa(rec.status), time.Since(start).Seconds())
+	})
+}
@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"Quantix/proto/quantixpb"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetricsCountsHTTPRequests(t *testing.T) {
+	s := NewTaskServer("", "deepseek-chat")
+	h := s.Handler()
+	for i := 1; i <= 3; i++ {
+		if code, _ := doJSON(t, h, "GET", "/tasks/missing-"+strconv.Itoa(i), "", nil); code != http.StatusNotFound {
+			t.Fatalf("查询不存在的任务: 状态码 = %d", code)
+		}
+		if got := s.Metrics.RequestCount("GET", "/tasks/{id}", "404"); got != uint64(i) {
+			t.Errorf("第 %d 次请求后计数 = %d，路径参数不应拆分标签", i, got)
+		}
+	}
+	doJSON(t, h, "GET", "/no-such-route", "", nil)
+	if got := s.Metrics.RequestCount("GET", "unmatched", "404"); got != 1 {
+		t.Errorf("未匹配路由应记为 unmatched: %d", got)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE quantix_requests_total counter",
+		`quantix_requests_total{method="GET",path="/tasks/{id}",status="404"} 3`,
+		`quantix_requests_total{method="GET",path="unmatched",status="404"} 1`,
+		`quantix_request_duration_seconds_count{method="GET",path="/tasks/{id}",status="404"} 3`,
+		"quantix_requests_in_flight 1", // 正在处理的 /metrics 请求本身
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("/metrics 缺少 %q:\n%s", want, body)
+		}
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", rec.Header().Get("Content-Type"))
+	}
+	if got := s.Metrics.RequestCount("GET", "/metrics", "200"); got != 1 {
+		t.Errorf("/metrics 请求本身也应计数: %d", got)
+	}
+}
+
+func TestMetricsHistogramBuckets(t *testing.T) {
+	m := NewMetrics()
+	m.RecordRequest("POST", "/analyze", "202", 0.03)
+	m.RecordRequest("POST", "/analyze", "202", 20)
+	var buf bytes.Buffer
+	m.Write(&buf)
+	labels := `method="POST",path="/analyze",status="202"`
+	for _, want := range []string{
+		`quantix_request_duration_seconds_bucket{` + labels + `,le="0.025"} 0`,
+		`quantix_request_duration_seconds_bucket{` + labels + `,le="0.05"} 1`,
+		`quantix_request_duration_seconds_bucket{` + labels + `,le="10"} 1`,
+		`quantix_request_duration_seconds_bucket{` + labels + `,le="+Inf"} 2`,
+		`quantix_request_duration_seconds_sum{` + labels + `} 20.03`,
+		"quantix_requests_in_flight 0",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("直方图缺少 %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestMetricsInterceptorCountsGRPC(t *testing.T) {
+	s := NewTaskServer("", "deepseek-chat")
+	client := dialTestGRPC(t, s)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for i := 1; i <= 2; i++ {
+		if _, err := client.GetTask(ctx, &quantixpb.TaskRequest{TaskId: "missing"}); status.Code(err) != codes.NotFound {
+			t.Fatalf("查询不存在的任务: %v", err)
+		}
+		if got := s.Metrics.RequestCount("grpc", quantixpb.QuantixService_GetTask_FullMethodName, "NotFound"); got != uint64(i) {
+			t.Errorf("第 %d 次 gRPC 请求后计数 = %d", i, got)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package analysis
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisAddr 机器学习预测结果的 Redis 缓存地址（host:port），为空时不缓存
+var RedisAddr = ""
+
+// MLCacheTTL 预测结果缓存时长
+var MLCacheTTL = time.Hour
+
+// mlCacheTimeout 单次读写 Redis 的超时，超时视为缓存不可用，直接计算
+const mlCacheTimeout = 500 * time.Millisecond
+
+var (
+	mlCacheMu     sync.Mutex
+	mlCacheClient *redis.Client
+	mlCacheAddr   string
+
+	mlCacheHits   atomic.Uint64
+	mlCacheMisses atomic.Uint64
+)
+
+// mlRedisClient 按 RedisAddr 复用客户端，地址变化时重建；未配置时返回 nil
+func mlRedisClient() *redis.Client {
+	mlCacheMu.Lock()
+	defer mlCacheMu.Unlock()
+	if mlCacheClient != nil && mlCacheAddr == RedisAddr {
+		return mlCacheClient
+	}
+	if mlCacheClient != nil {
+		mlCacheClient.Close()
+		mlCacheClient = nil
+	}
+	if RedisAddr == "" {
+		return nil
+	}
+	mlCacheClient = redis.NewClient(&redis.Options{Addr: RedisAddr, DialTimeout: mlCacheTimeout, MaxRetries: -1})
+	mlCacheAddr = RedisAddr
+	return mlCacheClient
+}
+
+// mlDataFingerprint 最后一根K线日期与收盘价的哈希，行情更新后缓存键随之变化
+func mlDataFingerprint(data []StockData) string {
+	if len(data) == 0 {
+		return ""
+	}
+	last := data[len(data)-1]
+	sum := sha256.Sum256([]byte(last.Date.Format("2006-01-02") + "|" + strconv.FormatFloat(last.Close, 'f', -1, 64)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// MLCacheStats 进程内预测缓存的累计命中与未命中次数
+func MLCacheStats() (hits, misses uint64) {
+	return mlCacheHits.Load(), mlCacheMisses.Load()
+}
+
+// MLCacheHitRatio 预测缓存命中率，尚无查询时为 0
+func MLCacheHitRatio() float64 {
+	hits, misses := MLCacheStats()
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
+
+// PredictMLCached 按 code+数据指纹读取缓存的预测结果，未命中时计算并写入缓存（TTL 为 MLCacheTTL）；
+// 未配置或 Redis 不可用时直接计算
+func PredictMLCached(ctx context.Context, code string, data []StockData, indicators []TechnicalIndicator) ([]MLPrediction, error) {
+	client := mlRedisClient()
+	if client == nil {
+		return NewMLPredictor(data, indicators).PredictAll()
+	}
+	key := "quantix:ml:" + fileNameCode(code) + ":" + mlDataFingerprint(data)
+	getCtx, cancel := context.WithTimeout(ctx, mlCacheTimeout)
+	cached, err := client.Get(getCtx, key).Bytes()
+	cancel()
+	switch {
+	case err == nil:
+		var preds []MLPrediction
+		if jsonErr := json.Unmarshal(cached, &preds); jsonErr == nil {
+			mlCacheHits.Add(1)
+			return preds, nil
+		}
+		mlCacheMisses.Add(1)
+	case errors.Is(err, redis.Nil):
+		mlCacheMisses.Add(1)
+	default:
+		log.Printf("[缓存] Redis 不可用，直接计算 %s 的机器学习预测: %v\n", code, err)
+		return NewMLPredictor(data, indicators).PredictAll()
+	}
+
+	preds, err := NewMLPredictor(data, indicators).PredictAll()
+	if err != nil {
+		return nil, err
+	}
+	if payload, err := json.Marshal(preds); err == nil {
+		setCtx, cancel := context.WithTimeout(ctx, mlCacheTimeout)
+		if err := client.Set(setCtx, key, payload, MLCacheTTL).Err(); err != nil {
+			log.Printf("[缓存] 写入 %s 的机器学习预测失败: %v\n", code, err)
+		}
+		cancel()
+	}
+	return preds, nil
+}
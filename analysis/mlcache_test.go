@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"Quantix/logger"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestPredictMLCachedHitAndMiss(t *testing.T) {
+	mr := miniredis.RunT(t)
+	oldAddr, oldTTL := RedisAddr, MLCacheTTL
+	RedisAddr, MLCacheTTL = mr.Addr(), 10*time.Minute
+	defer func() { RedisAddr, MLCacheTTL = oldAddr, oldTTL }()
+
+	data := trendBars(200, 0.01)
+	ind := calculateTechnicalIndicators(data)
+	hits0, misses0 := MLCacheStats()
+
+	first, err := PredictMLCached(context.Background(), "600036", data, ind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hits, misses := MLCacheStats()
+	if hits != hits0 || misses != misses0+1 {
+		t.Errorf("首次查询应未命中: hits +%d, misses +%d", hits-hits0, misses-misses0)
+	}
+	key := "quantix:ml:600036:" + mlDataFingerprint(data)
+	if !mr.Exists(key) {
+		t.Fatalf("未写入缓存键 %s，现有 %v", key, mr.Keys())
+	}
+	if ttl := mr.TTL(key); ttl != MLCacheTTL {
+		t.Errorf("缓存 TTL = %v，期望 %v", ttl, MLCacheTTL)
+	}
+
+	second, err := PredictMLCached(context.Background(), "600036", data, ind)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hits, _ := MLCacheStats(); hits != hits0+1 {
+		t.Errorf("相同行情应命中缓存: hits +%d", hits-hits0)
+	}
+	if len(second) != len(first) || second[1] != first[1] {
+		t.Errorf("命中结果与计算结果不一致: %+v vs %+v", second, first)
+	}
+	var buf bytes.Buffer
+	NewMetrics().Write(&buf)
+	if !strings.Contains(buf.String(), "quantix_cache_hit_ratio ") {
+		t.Errorf("指标缺少 quantix_cache_hit_ratio:\n%s", buf.String())
+	}
+
+	// 新的K线改变数据指纹，不应命中旧结果
+	more := trendBars(201, 0.01)
+	if _, err := PredictMLCached(context.Background(), "600036", more, calculateTechnicalIndicators(more)); err != nil {
+		t.Fatal(err)
+	}
+	if _, misses := MLCacheStats(); misses != misses0+2 {
+		t.Errorf("行情更新后应未命中: misses +%d", misses-misses0)
+	}
+
+	mr.FastForward(MLCacheTTL + time.Second)
+	if mr.Exists(key) {
+		t.Error("超过 TTL 后缓存应过期")
+	}
+}
+
+func TestPredictMLCachedFallsBackWhenRedisDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	addr := mr.Addr()
+	mr.Close()
+	oldAddr := RedisAddr
+	RedisAddr = addr
+	defer func() { RedisAddr = oldAddr }()
+	// file 模式下运行日志应写入日志文件
+	logFile := filepath.Join(t.TempDir(), "quantix.log")
+	if err := logger.InitLogger(logger.LogConfig{Output: logger.OutputFile, File: logFile}); err != nil {
+		t.Fatal(err)
+	}
+	defer logger.InitLogger(logger.LogConfig{Output: logger.OutputStderr})
+
+	data := trendBars(200, 0.01)
+	hits0, misses0 := MLCacheStats()
+	preds, err := PredictMLCached(context.Background(), "600036", data, calculateTechnicalIndicators(data))
+	if err != nil || len(preds) != 3 {
+		t.Fatalf("Redis 不可用时应直接计算: %v, %v", preds, err)
+	}
+	if hits, misses := MLCacheStats(); hits != hits0 || misses != misses0 {
+		t.Errorf("Redis 不可用时不应计入命中率: hits +%d, misses +%d", hits-hits0, misses-misses0)
+	}
+	if content, err := os.ReadFile(logFile); err != nil || !strings.Contains(string(content), "[缓存] Redis 不可用") {
+		t.Errorf("日志文件应包含缓存降级日志: %q, %v", content, err)
+	}
+}
@@ -0,0 +1,268 @@
+package analysis
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// MLFeatureNames 机器学习特征，顺序与 mlFeatures 输出一致
+var MLFeatureNames = []string{"ret1", "ret5", "ret20", "ma5_dev", "ma20_dev", "rsi6", "kdj_k", "macd_hist", "boll_pos", "vol_ratio"}
+
+// mlLookback 计算特征所需的最少历史K线数
+const mlLookback = 20
+
+// 决策树与随机森林的训练参数
+const (
+	mlMaxDepth   = 6
+	mlMinLeaf    = 5
+	mlForestSize = 50
+	mlSeed       = 20240101 // 固定随机种子，同一份数据训练结果可复现
+)
+
+// mlInstance 一个训练样本：第 i 日的特征与 horizon 日后的涨跌
+type mlInstance struct {
+	X      []float64
+	Up     bool
+	Return float64 // horizon 日后收盘相对当日的涨跌幅
+}
+
+// ratioOrZero a/b-1，b 不为正时为 0
+func ratioOrZero(a, b float64) float64 {
+	if b <= 0 {
+		return 0
+	}
+	return a/b - 1
+}
+
+// mlFeatures 第 i 日的特征：1/5/20 日涨跌、相对 MA5/MA20 的偏离、RSI6、KDJ-K、MACD 柱（占价格）、布林带位置与量比；
+// i 需不小于 mlLookback
+func mlFeatures(data []StockData, ind []TechnicalIndicator, i int) []float64 {
+	d, t := data[i], ind[i]
+	bollPos := 0.5
+	if t.BOLLUpper > t.BOLLLower {
+		bollPos = (d.Close - t.BOLLLower) / (t.BOLLUpper - t.BOLLLower)
+	}
+	avgVol := 0.0
+	for j := i - 4; j <= i; j++ {
+		avgVol += data[j].Volume / 5
+	}
+	macd := 0.0
+	if d.Close > 0 {
+		macd = t.MACDHistogram / d.Close
+	}
+	return []float64{
+		ratioOrZero(d.Close, data[i-1].Close),
+		ratioOrZero(d.Close, data[i-5].Close),
+		ratioOrZero(d.Close, data[i-mlLookback].Close),
+		ratioOrZero(d.Close, t.MA5),
+		ratioOrZero(d.Close, t.MA20),
+		t.RSI6 / 100,
+		t.K / 100,
+		macd,
+		bollPos,
+		ratioOrZero(d.Volume, avgVol) + 1,
+	}
+}
+
+// buildMLInstances 由行情构造训练样本，标签为 horizon 日后是否收涨；样本不足时返回空
+func buildMLInstances(data []StockData, ind []TechnicalIndicator, horizon int) []mlInstance {
+	if horizon <= 0 || len(ind) < len(data) {
+		return nil
+	}
+	var list []mlInstance
+	for i := mlLookback; i+horizon < len(data); i++ {
+		ret := ratioOrZero(data[i+horizon].Close, data[i].Close)
+		list = append(list, mlInstance{X: mlFeatures(data, ind, i), Up: ret > 0, Return: ret})
+	}
+	return list
+}
+
+// mlNode 决策树节点，Left/Right 为子节点下标，叶子节点为 -1
+type mlNode struct {
+	Feature   int     `json:"feature"`
+	Threshold float64 `json:"threshold"`
+	Left      int     `json:"left"`
+	Right     int     `json:"right"`
+	Prob      float64 `json:"prob"` // 叶子节点样本中上涨的比例
+}
+
+// DecisionTree CART 分类树（基尼系数），节点按数组存放以便 JSON 序列化
+type DecisionTree struct {
+	Nodes []mlNode `json:"nodes"`
+}
+
+// upRatio 样本中上涨的比例
+func upRatio(samples []mlInstance, idx []int) float64 {
+	if len(idx) == 0 {
+		return 0.5
+	}
+	up := 0
+	for _, i := range idx {
+		if samples[i].Up {
+			up++
+		}
+	}
+	return float64(up) / float64(len(idx))
+}
+
+// trainDecisionTree 在 idx 指定的样本上训练分类树，maxFeatures>0 时每次分裂随机选取该数量的特征（随机森林）
+func trainDecisionTree(samples []mlInstance, idx []int, maxFeatures int, rng *rand.Rand) DecisionTree {
+	var t DecisionTree
+	t.grow(samples, idx, 0, maxFeatures, rng)
+	return t
+}
+
+// grow 递归生长，深度不超过 mlMaxDepth，返回节点下标
+func (t *DecisionTree) grow(samples []mlInstance, idx []int, depth, maxFeatures int, rng *rand.Rand) int {
+	node := len(t.Nodes)
+	p := upRatio(samples, idx)
+	t.Nodes = append(t.Nodes, mlNode{Left: -1, Right: -1, Prob: p})
+	if depth >= mlMaxDepth || len(idx) < 2*mlMinLeaf || p == 0 || p == 1 {
+		return node
+	}
+	feature, threshold, ok := bestSplit(samples, idx, maxFeatures, rng)
+	if !ok {
+		return node
+	}
+	var left, right []int
+	for _, i := range idx {
+		if samples[i].X[feature] <= threshold {
+			left = append(left, i)
+		} else {
+			right = append(right, i)
+		}
+	}
+	l := t.grow(samples, left, depth+1, maxFeatures, rng)
+	r := t.grow(samples, right, depth+1, maxFeatures, rng)
+	t.Nodes[node] = mlNode{Feature: feature, Threshold: threshold, Left: l, Right: r, Prob: p}
+	return node
+}
+
+// gini 二分类基尼不纯度
+func gini(up, n int) float64 {
+	if n == 0 {
+		return 0
+	}
+	p := float64(up) / float64(n)
+	return 2 * p * (1 - p)
+}
+
+// bestSplit 在候选特征上寻找加权基尼不纯度最小的阈值，两侧样本均不少于 mlMinLeaf
+func bestSplit(samples []mlInstance, idx []int, maxFeatures int, rng *rand.Rand) (int, float64, bool) {
+	nFeat := len(samples[idx[0]].X)
+	features := make([]int, nFeat)
+	for i := range features {
+		features[i] = i
+	}
+	if maxFeatures > 0 && maxFeatures < nFeat {
+		rng.Shuffle(nFeat, func(i, j int) { features[i], features[j] = features[j], features[i] })
+		features = features[:maxFeatures]
+	}
+	totalUp := 0
+	for _, i := range idx {
+		if samples[i].Up {
+			totalUp++
+		}
+	}
+	n := len(idx)
+	best, bestFeature, bestThreshold := gini(totalUp, n), -1, 0.0
+	sorted := make([]int, n)
+	for _, f := range features {
+		copy(sorted, idx)
+		sort.Slice(sorted, func(a, b int) bool { return samples[sorted[a]].X[f] < samples[sorted[b]].X[f] })
+		leftUp := 0
+		for k := 0; k < n-1; k++ {
+			if samples[sorted[k]].Up {
+				leftUp++
+			}
+			lo, hi := samples[sorted[k]].X[f], samples[sorted[k+1]].X[f]
+			if k+1 < mlMinLeaf || n-k-1 < mlMinLeaf || lo == hi {
+				continue
+			}
+			score := (float64(k+1)*gini(leftUp, k+1) + float64(n-k-1)*gini(totalUp-leftUp, n-k-1)) / float64(n)
+			if score < best-1e-12 {
+				best, bestFeature, bestThreshold = score, f, (lo+hi)/2
+			}
+		}
+	}
+	return bestFeature, bestThreshold, bestFeature >= 0
+}
+
+// Predict 上涨概率
+func (t DecisionTree) Predict(x []float64) float64 {
+	if len(t.Nodes) == 0 {
+		return 0.5
+	}
+	n := t.Nodes[0]
+	for n.Left >= 0 {
+		if x[n.Feature] <= n.Threshold {
+			n = t.Nodes[n.Left]
+		} else {
+			n = t.Nodes[n.Right]
+		}
+	}
+	return n.Prob
+}
+
+// RandomForest 随机森林分类器，OOBError 为袋外样本的分类错误率
+type RandomForest struct {
+	Trees    []DecisionTree `json:"trees"`
+	Features []string       `json:"features"`
+	Horizon  int            `json:"horizon"`
+	OOBError float64        `json:"oob_error"`
+}
+
+// trainRandomForest 有放回抽样训练 trees 棵树，每次分裂随机取 √特征数 个特征，并计算袋外错误率
+func trainRandomForest(samples []mlInstance, trees, horizon int) RandomForest {
+	rf := RandomForest{Features: MLFeatureNames, Horizon: horizon}
+	if len(samples) == 0 || trees <= 0 {
+		return rf
+	}
+	rng := rand.New(rand.NewSource(mlSeed))
+	maxFeatures := int(math.Max(1, math.Round(math.Sqrt(float64(len(samples[0].X))))))
+	votes := make([]float64, len(samples))
+	voteCount := make([]int, len(samples))
+	for k := 0; k < trees; k++ {
+		inBag := make([]bool, len(samples))
+		idx := make([]int, len(samples))
+		for i := range idx {
+			idx[i] = rng.Intn(len(samples))
+			inBag[idx[i]] = true
+		}
+		tree := trainDecisionTree(samples, idx, maxFeatures, rng)
+		rf.Trees = append(rf.Trees, tree)
+		for i, s := range samples {
+			if !inBag[i] {
+				votes[i] += tree.Predict(s.X)
+				voteCount[i]++
+			}
+		}
+	}
+	wrong, total := 0, 0
+	for i, s := range samples {
+		if voteCount[i] == 0 {
+			continue
+		}
+		total++
+		if (votes[i]/float64(voteCount[i]) >= 0.5) != s.Up {
+			wrong++
+		}
+	}
+	if total > 0 {
+		rf.OOBError = float64(wrong) / float64(total)
+	}
+	return rf
+}
+
+// Predict 各树上涨概率的平均
+func (rf RandomForest) Predict(x []float64) float64 {
+	if len(rf.Trees) == 0 {
+		return 0.5
+	}
+	sum := 0.0
+	for _, t := range rf.Trees {
+		sum += t.Predict(x)
+	}
+	return sum / float64(len(rf.Trees))
+}
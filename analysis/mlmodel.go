@@ -0,0 +1,135 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MLModelPath 训练后的随机森林模型目录，每个模型保存为 {code}_{时间戳}.model.json 与同名 .meta.json 元信息旁文件
+var MLModelPath = "models"
+
+// mlTrainHorizon 持久化模型的预测周期（交易日），标签为 5 日后是否收涨
+const mlTrainHorizon = 5
+
+// mlMinSamples 训练所需的最少样本数
+const mlMinSamples = 60
+
+// ModelMeta 已保存模型的元信息
+type ModelMeta struct {
+	StockCode string    `json:"stock_code"`
+	TrainedAt time.Time `json:"trained_at"`
+	Features  []string  `json:"features"`
+	Samples   int       `json:"samples"`
+	Trees     int       `json:"trees"`
+	Horizon   int       `json:"horizon"`   // 预测周期（交易日）
+	OOBError  float64   `json:"oob_error"` // 袋外错误率
+	ModelFile string    `json:"model_file"`
+}
+
+// TrainModelRequest 训练模型请求，start/end 可为空
+type TrainModelRequest struct {
+	StockCode string `json:"stock_code"`
+	Start     string `json:"start"`
+	End       string `json:"end"`
+}
+
+// TrainStockModel 按区间获取行情训练随机森林，模型与元信息写入 MLModelPath；代码或日期不合法时返回 RequestError
+func TrainStockModel(ctx context.Context, req TrainModelRequest) (ModelMeta, error) {
+	req.StockCode = strings.TrimSpace(req.StockCode)
+	if err := validateAnalyzeRequest(AnalyzeRequest{StockCode: req.StockCode, Start: req.Start, End: req.End}); err != nil {
+		return ModelMeta{}, err
+	}
+	code := NormalizeStockCode(req.StockCode)
+	stockData, indicators, info, err := FetchStockHistoryWithFallbackContext(ctx, code, req.Start, req.End, "")
+	if err != nil {
+		return ModelMeta{}, err
+	}
+	stockData, indicators = displayRange(stockData, indicators, info.WarmupBars)
+	samples := buildMLInstances(stockData, indicators, mlTrainHorizon)
+	if len(samples) < mlMinSamples {
+		return ModelMeta{}, &RequestError{fmt.Sprintf("%s 行情 %d 条，样本 %d 个，不足 %d 个无法训练", code, len(stockData), len(samples), mlMinSamples)}
+	}
+	forest := trainRandomForest(samples, mlForestSize, mlTrainHorizon)
+	return saveModel(code, forest, len(samples), time.Now())
+}
+
+// saveModel 写入模型文件与元信息旁文件
+func saveModel(code string, forest RandomForest, samples int, at time.Time) (ModelMeta, error) {
+	if err := os.MkdirAll(MLModelPath, 0755); err != nil {
+		return ModelMeta{}, fmt.Errorf("创建模型目录失败: %v", err)
+	}
+	base := fileNameCode(code) + "_" + at.Format("20060102-150405")
+	meta := ModelMeta{
+		StockCode: code,
+		TrainedAt: at,
+		Features:  forest.Features,
+		Samples:   samples,
+		Trees:     len(forest.Trees),
+		Horizon:   forest.Horizon,
+		OOBError:  forest.OOBError,
+		ModelFile: base + ".model.json",
+	}
+	modelData, err := json.Marshal(forest)
+	if err != nil {
+		return ModelMeta{}, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(MLModelPath, meta.ModelFile), modelData, 0644); err != nil {
+		return ModelMeta{}, fmt.Errorf("保存模型失败: %v", err)
+	}
+	metaData, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return ModelMeta{}, err
+	}
+	if err := ioutil.WriteFile(filepath.Join(MLModelPath, base+".meta.json"), metaData, 0644); err != nil {
+		return ModelMeta{}, fmt.Errorf("保存模型元信息失败: %v", err)
+	}
+	return meta, nil
+}
+
+// ListModels 扫描 MLModelPath 下的元信息文件，按训练时间倒序；code 非空时只返回该股票的模型，目录不存在时返回空
+func ListModels(code string) ([]ModelMeta, error) {
+	files, err := filepath.Glob(filepath.Join(MLModelPath, "*.meta.json"))
+	if err != nil {
+		return nil, err
+	}
+	code = NormalizeStockCode(code)
+	list := []ModelMeta{}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var meta ModelMeta
+		if err := json.Unmarshal(data, &meta); err != nil {
+			log.Printf("[模型] 跳过无法解析的元信息 %s: %v\n", f, err)
+			continue
+		}
+		if code != "" && meta.StockCode != code {
+			continue
+		}
+		list = append(list, meta)
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].TrainedAt.After(list[j].TrainedAt) })
+	return list, nil
+}
+
+// LoadModel 读取元信息对应的模型文件
+func LoadModel(meta ModelMeta) (RandomForest, error) {
+	var forest RandomForest
+	data, err := ioutil.ReadFile(filepath.Join(MLModelPath, filepath.Base(meta.ModelFile)))
+	if err != nil {
+		return forest, fmt.Errorf("读取模型失败: %v", err)
+	}
+	if err := json.Unmarshal(data, &forest); err != nil {
+		return forest, fmt.Errorf("解析模型 %s 失败: %v", meta.ModelFile, err)
+	}
+	return forest, nil
+}
@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestModelsEndpointTrainAndList(t *testing.T) {
+	oldTransport, oldRetries, oldCache, oldModels := FetchTransport, FetchMaxRetries, DataCacheDir, MLModelPath
+	FetchTransport, FetchMaxRetries, DataCacheDir, MLModelPath = failingTransport{}, 0, t.TempDir(), t.TempDir()
+	defer func() {
+		FetchTransport, FetchMaxRetries, DataCacheDir, MLModelPath = oldTransport, oldRetries, oldCache, oldModels
+	}()
+	if err := saveStockCache("600036", "测试", datedBars(200)); err != nil {
+		t.Fatal(err)
+	}
+	server := NewTaskServer("", "deepseek-chat")
+	server.AuthToken = "s3cret"
+	h := server.Handler()
+
+	for _, token := range []string{"", "wrong"} {
+		if code, body := doJSON(t, h, "POST", "/models", token, map[string]string{"stock_code": "600036"}); code != http.StatusUnauthorized {
+			t.Errorf("令牌 %q 训练状态码 = %d，期望 401（%v）", token, code, body)
+		}
+	}
+	code, body := doJSON(t, h, "POST", "/models", "s3cret", map[string]string{"stock_code": "600036"})
+	if code != http.StatusCreated {
+		t.Fatalf("训练状态码 = %d: %v", code, body)
+	}
+	modelFile, _ := body["model_file"].(string)
+	if body["stock_code"] != "600036" || modelFile == "" || body["samples"].(float64) < mlMinSamples {
+		t.Fatalf("元信息不完整: %v", body)
+	}
+	if features, _ := body["features"].([]interface{}); len(features) != len(MLFeatureNames) {
+		t.Errorf("特征 = %v，期望 %v", features, MLFeatureNames)
+	}
+	for _, f := range []string{modelFile, modelFile[:len(modelFile)-len(".model.json")] + ".meta.json"} {
+		if _, err := os.Stat(filepath.Join(MLModelPath, f)); err != nil {
+			t.Errorf("缺少文件 %s: %v", f, err)
+		}
+	}
+
+	code, body = doJSON(t, h, "GET", "/models?code=600036", "", nil)
+	models, _ := body["models"].([]interface{})
+	if code != http.StatusOK || len(models) != 1 {
+		t.Fatalf("列出模型 = %d %v", code, body)
+	}
+	if m := models[0].(map[string]interface{}); m["model_file"] != modelFile {
+		t.Errorf("列出的模型 = %v，期望 %s", m, modelFile)
+	}
+	if _, body := doJSON(t, h, "GET", "/models?code=000001", "", nil); len(body["models"].([]interface{})) != 0 {
+		t.Errorf("按代码筛选未生效: %v", body)
+	}
+
+	list, err := ListModels("600036")
+	if err != nil || len(list) != 1 {
+		t.Fatalf("ListModels = %v, %v", list, err)
+	}
+	forest, err := LoadModel(list[0])
+	if err != nil || len(forest.Trees) != mlForestSize {
+		t.Errorf("LoadModel 树数 = %d, %v", len(forest.Trees), err)
+	}
+
+	if code, body := doJSON(t, h, "POST", "/models", "s3cret", map[string]string{"stock_code": "../etc"}); code != http.StatusBadRequest {
+		t.Errorf("非法代码状态码 = %d: %v", code, body)
+	}
+}
@@ -0,0 +1,254 @@
+package analysis
+
+import (
+	"fmt"
+	"strings"
+)
+
+// mlHorizons 下一天/周/月对应的交易日数
+var mlHorizons = [3]int{1, 5, 20}
+
+// mlCVFolds 单棵决策树交叉验证的折数
+const mlCVFolds = 5
+
+// mlMinBars 预测所需的最少K线数：特征回看、最长周期与最少样本数之和
+var mlMinBars = mlLookback + mlHorizons[len(mlHorizons)-1] + mlMinSamples
+
+// MLPrediction 单个机器学习方法的预测：分类得到的涨跌方向结合当前价与历史平均涨跌幅折算为价格
+type MLPrediction struct {
+	Method         string  `json:"method"`
+	Trend          string  `json:"trend"` // 上涨/下跌/震荡，取下一周的分类结果
+	NextDayPrice   float64 `json:"next_day_price"`
+	NextWeekPrice  float64 `json:"next_week_price"`
+	NextMonthPrice float64 `json:"next_month_price"`
+	Confidence     float64 `json:"confidence"` // 0-1，随机森林取 1-袋外错误率，决策树取交叉验证准确率
+}
+
+// MLPredictor 基于本地K线的决策树/随机森林预测
+type MLPredictor struct {
+	data       []StockData
+	indicators []TechnicalIndicator
+}
+
+// NewMLPredictor 创建预测器，indicators 与 data 一一对应
+func NewMLPredictor(data []StockData, indicators []TechnicalIndicator) *MLPredictor {
+	return &MLPredictor{data: data, indicators: indicators}
+}
+
+// mlHorizonModel 某一周期上训练的模型与历史平均涨跌幅
+type mlHorizonModel struct {
+	treeProb, forestProb float64 // 最新一根K线的上涨概率
+	treeConf, forestConf float64
+	avgUp, avgDown       float64 // 样本中上涨/下跌时的平均涨跌幅，avgDown 为负
+}
+
+// PredictAll 依次给出决策树、随机森林与二者按置信度加权的集成预测；K线不足以训练月度模型时返回错误
+func (p *MLPredictor) PredictAll() ([]MLPrediction, error) {
+	n := len(p.data)
+	if n == 0 || len(p.indicators) < n {
+		return nil, fmt.Errorf("无可用K线")
+	}
+	if n < mlMinBars {
+		return nil, fmt.Errorf("K线 %d 条，不足 %d 条", n, mlMinBars)
+	}
+	latest := mlFeatures(p.data, p.indicators, n-1)
+	var models [3]mlHorizonModel
+	for k, h := range mlHorizons {
+		samples := buildMLInstances(p.data, p.indicators, h)
+		if len(samples) < mlMinSamples {
+			return nil, fmt.Errorf("K线 %d 条，%d 日周期样本 %d 个，不足 %d 个", n, h, len(samples), mlMinSamples)
+		}
+		models[k] = trainHorizonModel(samples, latest, h)
+	}
+
+	current := p.data[n-1].Close
+	build := func(method string, prob func(m mlHorizonModel) float64, conf func(m mlHorizonModel) float64) MLPrediction {
+		var prices [3]float64
+		c := 0.0
+		for k, m := range models {
+			prices[k] = mlPriceFromClass(current, prob(m), m.avgUp, m.avgDown)
+			c += conf(m) / float64(len(models))
+		}
+		return MLPrediction{
+			Method:         method,
+			Trend:          mlTrendText(prob(models[1])),
+			NextDayPrice:   prices[0],
+			NextWeekPrice:  prices[1],
+			NextMonthPrice: prices[2],
+			Confidence:     c,
+		}
+	}
+	return []MLPrediction{
+		build("决策树", func(m mlHorizonModel) float64 { return m.treeProb }, func(m mlHorizonModel) float64 { return m.treeConf }),
+		build("随机森林", func(m mlHorizonModel) float64 { return m.forestProb }, func(m mlHorizonModel) float64 { return m.forestConf }),
+		build("集成", mlHorizonModel.ensembleProb, func(m mlHorizonModel) float64 { return (m.treeConf + m.forestConf) / 2 }),
+	}, nil
+}
+
+// trainHorizonModel 在全部样本上训练决策树与随机森林，给出 latest 的上涨概率与各自置信度
+func trainHorizonModel(samples []mlInstance, latest []float64, horizon int) mlHorizonModel {
+	var m mlHorizonModel
+	m.avgUp, m.avgDown = averageMoves(samples)
+	all := make([]int, len(samples))
+	for i := range all {
+		all[i] = i
+	}
+	m.treeProb = trainDecisionTree(samples, all, 0, nil).Predict(latest)
+	m.treeConf = crossValidateTree(samples, mlCVFolds)
+	forest := trainRandomForest(samples, mlForestSize, horizon)
+	m.forestProb = forest.Predict(latest)
+	m.forestConf = 1 - forest.OOBError
+	return m
+}
+
+// ensembleProb 决策树与随机森林上涨概率按置信度加权，置信度均为 0 时取平均
+func (m mlHorizonModel) ensembleProb() float64 {
+	if w := m.treeConf + m.forestConf; w > 0 {
+		return (m.treeProb*m.treeConf + m.forestProb*m.forestConf) / w
+	}
+	return (m.treeProb + m.forestProb) / 2
+}
+
+// EvaluateAccuracy 滚动验证 method（决策树/随机森林/集成）的次日方向命中率：在最近 window 个交易日的每一天
+// 只用当日已知次日涨跌的样本训练，预测次日涨跌并与实际收盘比对，平盘日不计；
+// 训练样本不足 mlMinSamples 的交易日跳过，有效样本少于 minAccuracySamples 或方法未知时返回 0
+func (p *MLPredictor) EvaluateAccuracy(method string, window int) float64 {
+	switch method {
+	case "决策树", "随机森林", "集成":
+	default:
+		return 0
+	}
+	n := len(p.data)
+	if window <= 0 || n == 0 || len(p.indicators) < n {
+		return 0
+	}
+	samples := buildMLInstances(p.data, p.indicators, 1) // samples[k] 对应第 mlLookback+k 日
+	start := n - 1 - window
+	if start < mlLookback+mlMinSamples {
+		start = mlLookback + mlMinSamples
+	}
+	hits, total := 0, 0
+	for t := start; t < n-1; t++ {
+		change := p.data[t+1].Close - p.data[t].Close
+		if change == 0 {
+			continue
+		}
+		// 第 t 日收盘时只知道第 t-1 日及以前样本的次日涨跌
+		m := trainHorizonModel(samples[:t-mlLookback], mlFeatures(p.data, p.indicators, t), 1)
+		prob := m.ensembleProb()
+		switch method {
+		case "决策树":
+			prob = m.treeProb
+		case "随机森林":
+			prob = m.forestProb
+		}
+		total++
+		if (prob >= 0.5) == (change > 0) {
+			hits++
+		}
+	}
+	if total < minAccuracySamples {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// FormatMLPredictionTable 机器学习预测 markdown 表格，lang 为 en 时使用英文表头与取值，无预测时返回空
+func FormatMLPredictionTable(preds []MLPrediction, lang string) string {
+	if len(preds) == 0 {
+		return ""
+	}
+	l := labelsFor(lang)
+	var b strings.Builder
+	b.WriteString("\n" + l.mlTitle + "\n" + markdownHeader(l.mlHeader))
+	for _, p := range preds {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n",
+			localize(p.Method, lang), FormatPrice(p.NextDayPrice), FormatPrice(p.NextWeekPrice), FormatPrice(p.NextMonthPrice), FormatPercent(p.Confidence), localize(p.Trend, lang))
+	}
+	return b.String()
+}
+
+// FormatMLPredictionTableHTML 机器学习预测 HTML 表格，lang 为 en 时使用英文表头与取值
+func FormatMLPredictionTableHTML(preds []MLPrediction, lang string) string {
+	if len(preds) == 0 {
+		return ""
+	}
+	l := labelsFor(lang)
+	var b strings.Builder
+	b.WriteString("\n<h3>" + l.mlTitle + "</h3>\n<table>\n" + htmlHeader(l.mlHeader) + "\n")
+	for _, p := range preds {
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			localize(p.Method, lang), FormatPrice(p.NextDayPrice), FormatPrice(p.NextWeekPrice), FormatPrice(p.NextMonthPrice), FormatPercent(p.Confidence), localize(p.Trend, lang))
+	}
+	b.WriteString("</table>\n")
+	return b.String()
+}
+
+// averageMoves 上涨样本与下跌（含平盘）样本各自的平均涨跌幅，无对应样本时为 0
+func averageMoves(samples []mlInstance) (avgUp, avgDown float64) {
+	var nUp, nDown int
+	for _, s := range samples {
+		if s.Up {
+			avgUp += s.Return
+			nUp++
+		} else {
+			avgDown += s.Return
+			nDown++
+		}
+	}
+	if nUp > 0 {
+		avgUp /= float64(nUp)
+	}
+	if nDown > 0 {
+		avgDown /= float64(nDown)
+	}
+	return avgUp, avgDown
+}
+
+// mlPriceFromClass 上涨概率不低于 0.5 时按历史平均涨幅、否则按平均跌幅折算价格，幅度乘以该方向的概率
+func mlPriceFromClass(current, prob, avgUp, avgDown float64) float64 {
+	if prob >= 0.5 {
+		return current * (1 + prob*avgUp)
+	}
+	return current * (1 + (1-prob)*avgDown)
+}
+
+// mlTrendText 上涨概率 ±5% 以内视为震荡
+func mlTrendText(prob float64) string {
+	switch {
+	case prob >= 0.55:
+		return "上涨"
+	case prob <= 0.45:
+		return "下跌"
+	}
+	return "震荡"
+}
+
+// crossValidateTree 按时间顺序切分为 folds 段，依次留一段验证，返回单棵决策树的平均分类准确率
+func crossValidateTree(samples []mlInstance, folds int) float64 {
+	size := len(samples) / folds
+	if size == 0 {
+		return 0
+	}
+	correct, total := 0, 0
+	for f := 0; f < folds; f++ {
+		lo, hi := f*size, (f+1)*size
+		if f == folds-1 {
+			hi = len(samples)
+		}
+		train := make([]int, 0, len(samples)-(hi-lo))
+		for i := range samples {
+			if i < lo || i >= hi {
+				train = append(train, i)
+			}
+		}
+		tree := trainDecisionTree(samples, train, 0, nil)
+		for i := lo; i < hi; i++ {
+			if (tree.Predict(samples[i].X) >= 0.5) == samples[i].Up {
+				correct++
+			}
+			total++
+		}
+	}
+	return float64(correct) / float64(total)
+}
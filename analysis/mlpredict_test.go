@@ -0,0 +1,170 @@
+package analysis
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// trendBars 每 5 日中 4 日按 step 变动、1 日反向回撤，step>0 为上升趋势
+func trendBars(n int, step float64) []StockData {
+	data := datedBars(n)
+	c := 50.0
+	for i := range data {
+		if i%5 == 4 {
+			c *= 1 - step/2
+		} else {
+			c *= 1 + step
+		}
+		data[i].Open, data[i].Close, data[i].High, data[i].Low = c, c, c*1.01, c*0.99
+	}
+	return data
+}
+
+func TestMLPredictorUpClassPredictsHigherPrice(t *testing.T) {
+	data := trendBars(200, 0.01)
+	current := data[len(data)-1].Close
+	preds, err := NewMLPredictor(data, calculateTechnicalIndicators(data)).PredictAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(preds) != 3 {
+		t.Fatalf("预测方法数 = %d，期望 3", len(preds))
+	}
+	for _, p := range preds {
+		if p.Trend != "上涨" {
+			t.Errorf("%s 趋势 = %s，期望上涨", p.Method, p.Trend)
+		}
+		if p.NextWeekPrice <= current || p.NextMonthPrice <= current {
+			t.Errorf("%s 上涨分类的预测价应高于当前价 %.2f: %+v", p.Method, current, p)
+		}
+		if p.NextMonthPrice <= p.NextWeekPrice {
+			t.Errorf("%s 月度预测应高于周度（平均涨幅更大）: %+v", p.Method, p)
+		}
+		if p.Confidence <= 0 || p.Confidence > 1 {
+			t.Errorf("%s 置信度 = %v，应在 (0,1]", p.Method, p.Confidence)
+		}
+	}
+
+	down := trendBars(200, -0.01)
+	preds, err = NewMLPredictor(down, calculateTechnicalIndicators(down)).PredictAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range preds {
+		if p.Trend != "下跌" || p.NextWeekPrice >= down[len(down)-1].Close {
+			t.Errorf("下跌趋势中 %s 预测 = %+v", p.Method, p)
+		}
+	}
+}
+
+func TestMLPriceFromClass(t *testing.T) {
+	// 上涨概率过半时即使历史平均跌幅更大，也按涨幅折算
+	if got := mlPriceFromClass(100, 0.6, 0.02, -0.05); got <= 100 {
+		t.Errorf("上涨分类价格 = %v，应高于 100", got)
+	}
+	if got := mlPriceFromClass(100, 0.3, 0.05, -0.02); got >= 100 {
+		t.Errorf("下跌分类价格 = %v，应低于 100", got)
+	}
+}
+
+func TestMLPredictorInsufficientData(t *testing.T) {
+	// 不足特征回看期（20 根）的短序列也应返回错误而不是越界
+	for _, n := range []int{1, 12, mlLookback, mlLookback + 1, 60, mlMinBars - 1} {
+		data := trendBars(n, 0.01)
+		if _, err := NewMLPredictor(data, calculateTechnicalIndicators(data)).PredictAll(); err == nil {
+			t.Errorf("%d 根K线应返回错误", n)
+		}
+	}
+	data := trendBars(mlMinBars, 0.01)
+	if _, err := NewMLPredictor(data, calculateTechnicalIndicators(data)).PredictAll(); err != nil {
+		t.Errorf("%d 根K线应可预测: %v", mlMinBars, err)
+	}
+}
+
+func TestAnalyzeOneInsertsMLTableBetweenRiskAndBacktest(t *testing.T) {
+	params := AnalysisParams{StockCodes: []string{"600036"}, Start: "2024-01-01", End: "2024-12-31", Model: "deepseek-chat"}
+	_, result := analyzeCSV(t, params, "## 趋势判断\n偏多", 150)
+	report := result.Report
+	risk, ml, backtest := strings.Index(report, "【风险指标】"), strings.Index(report, "【机器学习预测】"), strings.Index(report, "【策略回测结果】")
+	if risk < 0 || ml < 0 || backtest < 0 {
+		t.Fatalf("报告缺少风险/机器学习/回测表格（%d/%d/%d）:\n%s", risk, ml, backtest, report)
+	}
+	if !(risk < ml && ml < backtest) {
+		t.Errorf("机器学习预测表应位于风险表与回测表之间（%d/%d/%d）", risk, ml, backtest)
+	}
+	for _, method := range []string{"| 决策树 |", "| 随机森林 |", "| 集成 |"} {
+		if !strings.Contains(report, method) {
+			t.Errorf("预测表缺少 %s", method)
+		}
+	}
+}
+
+func TestAnalyzeOneSkipsMLTableForShortSeries(t *testing.T) {
+	params := AnalysisParams{StockCodes: []string{"600036"}, Start: "2024-01-01", End: "2024-12-31", Model: "deepseek-chat"}
+	_, result := analyzeCSV(t, params, "## 趋势判断\n偏多", 12)
+	if strings.Contains(result.Report, "【机器学习预测】") || !strings.Contains(result.Report, "【风险指标】") {
+		t.Errorf("12 根K线时应跳过机器学习预测表并保留其他表格:\n%s", result.Report)
+	}
+}
+
+func TestMLPredictionTableEnglish(t *testing.T) {
+	preds := []MLPrediction{{Method: "随机森林", Trend: "上涨", NextDayPrice: 10, NextWeekPrice: 11, NextMonthPrice: 12, Confidence: 0.6}}
+	for name, table := range map[string]string{"markdown": FormatMLPredictionTable(preds, LangEN), "html": FormatMLPredictionTableHTML(preds, LangEN)} {
+		if containsHan(table) {
+			t.Errorf("%s 英文表格含中文:\n%s", name, table)
+		}
+		if !strings.Contains(table, "Random Forest") || !strings.Contains(table, "Up") {
+			t.Errorf("%s 英文表格缺少翻译后的方法或趋势:\n%s", name, table)
+		}
+	}
+}
+
+func TestMLEndpointShortSeries(t *testing.T) {
+	oldTransport, oldRetries, oldCache := FetchTransport, FetchMaxRetries, DataCacheDir
+	FetchTransport, FetchMaxRetries, DataCacheDir = failingTransport{}, 0, t.TempDir()
+	defer func() { FetchTransport, FetchMaxRetries, DataCacheDir = oldTransport, oldRetries, oldCache }()
+	h := NewTaskServer("", "deepseek-chat").Handler()
+
+	if err := saveStockCache("600036", "测试", datedBars(12)); err != nil {
+		t.Fatal(err)
+	}
+	if code, body := doJSON(t, h, "GET", "/stocks/600036/ml", "", nil); code != http.StatusBadRequest {
+		t.Errorf("12 根K线状态码 = %d，期望 400（%v）", code, body)
+	}
+	if err := saveStockCache("600036", "测试", datedBars(200)); err != nil {
+		t.Fatal(err)
+	}
+	code, body := doJSON(t, h, "GET", "/stocks/600036/ml", "", nil)
+	if preds, _ := body["predictions"].([]interface{}); code != http.StatusOK || len(preds) != 3 {
+		t.Errorf("预测接口 = %d %v", code, body)
+	}
+}
+
+func TestMLEvaluateAccuracyDeterministicTrend(t *testing.T) {
+	// 每 5 日 4 涨 1 跌的周期走势可由均线偏离等特征完全识别，滚动命中率应很高
+	data := trendBars(200, 0.01)
+	p := NewMLPredictor(data, calculateTechnicalIndicators(data))
+	for _, method := range []string{"决策树", "随机森林", "集成"} {
+		if acc := p.EvaluateAccuracy(method, 40); acc < 0.8 || acc > 1 {
+			t.Errorf("%s 周期走势命中率 = %v，期望在 [0.8, 1]", method, acc)
+		}
+	}
+	if acc := p.EvaluateAccuracy("线性回归", 40); acc != 0 {
+		t.Errorf("未知方法命中率 = %v，期望 0", acc)
+	}
+}
+
+func TestMLEvaluateAccuracyInsufficientData(t *testing.T) {
+	// 训练样本不足 mlMinSamples 的交易日不参与验证，有效样本不足时返回 0
+	for _, n := range []int{0, 12, mlLookback + mlMinSamples + minAccuracySamples - 1} {
+		data := trendBars(n, 0.01)
+		if acc := NewMLPredictor(data, calculateTechnicalIndicators(data)).EvaluateAccuracy("集成", 60); acc != 0 {
+			t.Errorf("%d 根K线命中率 = %v，期望 0", n, acc)
+		}
+	}
+	data := trendBars(mlLookback+mlMinSamples+minAccuracySamples+1, 0.01)
+	if acc := NewMLPredictor(data, calculateTechnicalIndicators(data)).EvaluateAccuracy("集成", 60); acc == 0 {
+		t.Error("有效样本足够时应给出命中率")
+	}
+}
@@ -0,0 +1,260 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ModelCacheTTL 模型列表按 API 地址与 Key 缓存的有效期
+var ModelCacheTTL = 10 * time.Minute
+
+// DefaultDeepSeekModels 模型列表获取失败且无缓存时使用的内置模型名
+var DefaultDeepSeekModels = []string{"deepseek-chat", "deepseek-reasoner"}
+
+// DefaultGeminiModels Gemini 模型列表获取失败时使用的内置模型名
+var DefaultGeminiModels = []string{"gemini-1.5-flash", "gemini-1.5-pro", "gemini-2.5-flash", "gemini-2.5-pro"}
+
+// GeminiModelsURL Gemini 模型列表接口地址
+var GeminiModelsURL = "https://" + GeminiAPIHost + "/v1beta/models"
+
+// maxModelPages 分页拉取的最大页数，防止接口循环返回同一游标
+const maxModelPages = 50
+
+type modelCacheEntry struct {
+	models    []string
+	fetchedAt time.Time
+}
+
+var (
+	modelCacheMu sync.Mutex
+	modelCache   = make(map[string]modelCacheEntry)
+)
+
+// modelsPage 模型列表单页响应，兼容 OpenAI（has_more/last_id，after 参数翻页）与 next/next_cursor 形式的分页
+type modelsPage struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+	HasMore    bool   `json:"has_more"`
+	LastID     string `json:"last_id"`
+	Next       string `json:"next"`
+	NextCursor string `json:"next_cursor"`
+}
+
+// nextPageURL 根据分页字段给出下一页地址，没有下一页时返回空；
+// next 为绝对地址时须与 base 同协议同主机，否则拒绝，避免把 API Key 发往其他主机
+func (p modelsPage) nextPageURL(base, current string) (string, error) {
+	switch {
+	case p.Next != "":
+		// next 可能是相对地址
+		cur, err := url.Parse(current)
+		if err != nil {
+			return "", err
+		}
+		next, err := cur.Parse(p.Next)
+		if err != nil {
+			return "", err
+		}
+		b, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		if !strings.EqualFold(next.Scheme, b.Scheme) || !strings.EqualFold(next.Host, b.Host) {
+			return "", fmt.Errorf("分页地址 %s://%s 与接口地址 %s://%s 不同源，已拒绝", next.Scheme, next.Host, b.Scheme, b.Host)
+		}
+		return next.String(), nil
+	case p.NextCursor != "":
+		return withQuery(base, "cursor", p.NextCursor)
+	case p.HasMore && p.LastID != "":
+		return withQuery(base, "after", p.LastID)
+	case p.HasMore && len(p.Data) > 0:
+		return withQuery(base, "after", p.Data[len(p.Data)-1].ID)
+	default:
+		return "", nil
+	}
+}
+
+func withQuery(base, key, value string) (string, error) {
+	u, err := url.Parse(base)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// fetchModelList 逐页拉取 baseURL 下的全部模型
+func fetchModelList(apiKey, baseURL string) ([]string, error) {
+	first := ModelsURL(baseURL)
+	client := &http.Client{Timeout: 15 * time.Second}
+	var models []string
+	seen := make(map[string]bool)
+	next := first
+	for page := 0; next != "" && page < maxModelPages; page++ {
+		req, err := http.NewRequest("GET", next, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		req.Header.Set("Accept", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var p modelsPage
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			return nil, fmt.Errorf("http status: %d", resp.StatusCode)
+		}
+		err = json.NewDecoder(resp.Body).Decode(&p)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range p.Data {
+			if !seen[m.ID] {
+				seen[m.ID] = true
+				models = append(models, m.ID)
+			}
+		}
+		if next, err = p.nextPageURL(first, next); err != nil {
+			return nil, err
+		}
+	}
+	return models, nil
+}
+
+// FetchDeepSeekModels 查询 baseURL 下可用模型（为空时使用 DeepSeekBaseURL），支持分页，结果按地址与 Key 缓存 ModelCacheTTL。
+// 请求失败时回退到上次成功的结果（即使已过期），再无则返回 DefaultDeepSeekModels，error 说明失败原因；
+// 返回的切片均为副本，调用方修改不影响缓存
+func FetchDeepSeekModels(apiKey, baseURL string) ([]string, error) {
+	if baseURL == "" {
+		baseURL = DeepSeekBaseURL
+	}
+	key := baseURL + "\n" + apiKey
+	modelCacheMu.Lock()
+	cached, ok := modelCache[key]
+	modelCacheMu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < ModelCacheTTL {
+		return copyModels(cached.models), nil
+	}
+
+	models, err := fetchModelList(apiKey, baseURL)
+	if err == nil && len(models) == 0 {
+		err = fmt.Errorf("模型列表为空")
+	}
+	if err != nil {
+		if ok {
+			return copyModels(cached.models), fmt.Errorf("获取模型列表失败，使用 %s 的缓存结果: %v", cached.fetchedAt.Format("15:04"), err)
+		}
+		return copyModels(DefaultDeepSeekModels), fmt.Errorf("获取模型列表失败，使用内置模型: %v", err)
+	}
+	modelCacheMu.Lock()
+	modelCache[key] = modelCacheEntry{models: models, fetchedAt: time.Now()}
+	modelCacheMu.Unlock()
+	return copyModels(models), nil
+}
+
+// FetchGeminiModels 调用 Gemini models 接口逐页获取可用模型（最多 maxModelPages 页），失败时返回 DefaultGeminiModels 的副本与错误；
+// Key 放在 x-goog-api-key 请求头中，避免出现在 URL 与代理日志里
+func FetchGeminiModels(apiKey string) ([]string, error) {
+	models, err := fetchGeminiModelList(apiKey)
+	if err == nil && len(models) == 0 {
+		err = fmt.Errorf("Gemini 未返回可用模型")
+	}
+	if err != nil {
+		return copyModels(DefaultGeminiModels), err
+	}
+	return models, nil
+}
+
+func fetchGeminiModelList(apiKey string) ([]string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	var models []string
+	pageToken := ""
+	for page := 0; page < maxModelPages; page++ {
+		q := url.Values{}
+		q.Set("pageSize", "100")
+		if pageToken != "" {
+			q.Set("pageToken", pageToken)
+		}
+		req, err := http.NewRequest("GET", GeminiModelsURL+"?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("x-goog-api-key", apiKey)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return nil, fmt.Errorf("Gemini API Key 鉴权失败: http status %d", resp.StatusCode)
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("http status: %d", resp.StatusCode)
+		}
+		names, next, err := parseGeminiModels(body)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, names...)
+		if next == "" {
+			break
+		}
+		pageToken = next
+	}
+	return models, nil
+}
+
+// parseGeminiModels 解析 Gemini models 接口响应，只保留支持 generateContent 的 gemini 模型
+func parseGeminiModels(body []byte) ([]string, string, error) {
+	var result struct {
+		Models []struct {
+			Name                       string   `json:"name"`
+			SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+		} `json:"models"`
+		NextPageToken string `json:"nextPageToken"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, "", err
+	}
+	models := make([]string, 0, len(result.Models))
+	for _, m := range result.Models {
+		name := strings.TrimPrefix(m.Name, "models/")
+		if !strings.HasPrefix(name, "gemini") {
+			continue
+		}
+		if len(m.SupportedGenerationMethods) > 0 && !supportsGenerateContent(m.SupportedGenerationMethods) {
+			continue
+		}
+		models = append(models, name)
+	}
+	return models, result.NextPageToken, nil
+}
+
+func supportsGenerateContent(methods []string) bool {
+	for _, m := range methods {
+		if m == "generateContent" {
+			return true
+		}
+	}
+	return false
+}
+
+func copyModels(models []string) []string {
+	return append([]string(nil), models...)
+}
@@ -0,0 +1,183 @@
+package analysis
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestFetchModelListPagination(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer sk-test" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.URL.Query().Get("after") == "" && r.URL.Query().Get("cursor") == "" && r.URL.Query().Get("p") == "":
+			fmt.Fprint(w, `{"data":[{"id":"a"},{"id":"b"}],"has_more":true,"last_id":"b"}`)
+		case r.URL.Query().Get("after") == "b":
+			fmt.Fprint(w, `{"data":[{"id":"c"}],"next_cursor":"x"}`)
+		case r.URL.Query().Get("cursor") == "x":
+			fmt.Fprint(w, `{"data":[{"id":"c"},{"id":"d"}],"next":"/v1/models?p=3"}`)
+		default:
+			fmt.Fprint(w, `{"data":[{"id":"e"}]}`)
+		}
+	}))
+	defer srv.Close()
+	models, err := fetchModelList("sk-test", srv.URL+"/v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b", "c", "d", "e"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("模型列表 = %v，期望 %v", models, want)
+	}
+}
+
+func TestFetchModelListRejectsCrossHostNext(t *testing.T) {
+	var leaked int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			atomic.AddInt32(&leaked, 1)
+		}
+		fmt.Fprint(w, `{"data":[]}`)
+	}))
+	defer other.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"data":[{"id":"a"}],"next":%q}`, other.URL+"/steal")
+	}))
+	defer srv.Close()
+	_, err := fetchModelList("sk-secret", srv.URL)
+	if err == nil || !strings.Contains(err.Error(), "不同源") {
+		t.Fatalf("跨主机 next 应被拒绝，得到 %v", err)
+	}
+	if leaked != 0 {
+		t.Fatal("API Key 被发送到了其他主机")
+	}
+}
+
+func TestFetchDeepSeekModelsReturnsCopy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"data":[{"id":"m1"},{"id":"m2"}]}`)
+	}))
+	defer srv.Close()
+	first, err := FetchDeepSeekModels("k", srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first[0] = "mutated"
+	second, _ := FetchDeepSeekModels("k", srv.URL)
+	if second[0] != "m1" {
+		t.Fatalf("修改返回值影响了缓存：%v", second)
+	}
+	fallback, _ := FetchDeepSeekModels("k", "http://127.0.0.1:1")
+	fallback[0] = "mutated"
+	if DefaultDeepSeekModels[0] == "mutated" {
+		t.Fatal("修改回退结果影响了 DefaultDeepSeekModels")
+	}
+}
+
+func TestParseGeminiModelsFiltersAndPaginates(t *testing.T) {
+	body := []byte(`{"models":[
+		{"name":"models/gemini-2.5-pro","supportedGenerationMethods":["generateContent","countTokens"]},
+		{"name":"models/gemini-embedding","supportedGenerationMethods":["embedContent"]},
+		{"name":"models/text-bison","supportedGenerationMethods":["generateContent"]},
+		{"name":"models/gemini-1.5-flash"}
+	],"nextPageToken":"abc/=="}`)
+	models, next, err := parseGeminiModels(body)
+	if err != nil {
+		t.Fatalf("解析失败: %v", err)
+	}
+	if want := []string{"gemini-2.5-pro", "gemini-1.5-flash"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("模型 = %v，期望 %v", models, want)
+	}
+	if next != "abc/==" {
+		t.Errorf("nextPageToken = %q，期望 abc/==", next)
+	}
+	if _, _, err := parseGeminiModels([]byte("not json")); err == nil {
+		t.Error("非法 JSON 应返回错误")
+	}
+}
+
+func TestFetchGeminiModelsUsesHeaderKeyAndPageToken(t *testing.T) {
+	var tokens []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("key") != "" {
+			t.Error("Key 不应出现在 URL 中")
+		}
+		if got := r.Header.Get("x-goog-api-key"); got != "secret" {
+			t.Errorf("x-goog-api-key = %q，期望 secret", got)
+		}
+		token := r.URL.Query().Get("pageToken")
+		tokens = append(tokens, token)
+		switch token {
+		case "":
+			fmt.Fprint(w, `{"models":[{"name":"models/gemini-a"}],"nextPageToken":"p2&x=1"}`)
+		case "p2&x=1":
+			fmt.Fprint(w, `{"models":[{"name":"models/gemini-b"}]}`)
+		default:
+			t.Errorf("未知 pageToken %q", token)
+		}
+	}))
+	defer srv.Close()
+	old := GeminiModelsURL
+	GeminiModelsURL = srv.URL
+	defer func() { GeminiModelsURL = old }()
+
+	models, err := FetchGeminiModels("secret")
+	if err != nil {
+		t.Fatalf("获取模型失败: %v", err)
+	}
+	if want := []string{"gemini-a", "gemini-b"}; !reflect.DeepEqual(models, want) {
+		t.Errorf("模型 = %v，期望 %v", models, want)
+	}
+	if want := []string{"", "p2&x=1"}; !reflect.DeepEqual(tokens, want) {
+		t.Errorf("pageToken 序列 = %q，期望 %q", tokens, want)
+	}
+}
+
+func TestFetchGeminiModelsStopsAtPageCap(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		// 接口始终返回同一个 nextPageToken
+		fmt.Fprint(w, `{"models":[{"name":"models/gemini-loop"}],"nextPageToken":"same"}`)
+	}))
+	defer srv.Close()
+	old := GeminiModelsURL
+	GeminiModelsURL = srv.URL
+	defer func() { GeminiModelsURL = old }()
+
+	if _, err := FetchGeminiModels("secret"); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != maxModelPages {
+		t.Errorf("请求页数 = %d，期望在 %d 页处停止", n, maxModelPages)
+	}
+}
+
+func TestFetchGeminiModelsFallbackReturnsCopy(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer srv.Close()
+	old := GeminiModelsURL
+	GeminiModelsURL = srv.URL
+	defer func() { GeminiModelsURL = old }()
+
+	want := append([]string(nil), DefaultGeminiModels...)
+	models, err := FetchGeminiModels("bad")
+	if err == nil || !strings.Contains(err.Error(), "鉴权失败") {
+		t.Errorf("403 应返回鉴权错误: %v", err)
+	}
+	if !reflect.DeepEqual(models, want) {
+		t.Fatalf("失败时应回退到内置模型: %v", models)
+	}
+	models[0] = "changed"
+	if !reflect.DeepEqual(DefaultGeminiModels, want) {
+		t.Errorf("修改返回值不应影响内置模型: %v", DefaultGeminiModels)
+	}
+}
@@ -0,0 +1,139 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ModelEvalHorizonDays 预测评估期（自然日）：取分析后至少间隔该天数的首条同股记录的当时价格作为回填实际价
+var ModelEvalHorizonDays = 5
+
+// modelNeutralBand 中性立场视为命中的评估期涨跌幅绝对值上限
+const modelNeutralBand = 0.03
+
+// ModelQuality 单个模型的历史预测质量
+type ModelQuality struct {
+	Model         string
+	Predictions   int     // 带模型标记的结论数
+	Samples       int     // 已回填实际价且立场明确的预测数
+	Hits          int     // 方向命中数
+	HitRate       float64 // 方向命中率，无样本时为 -1
+	TargetSamples int     // 已回填实际价且给出目标价的预测数
+	TargetMAPE    float64 // 目标价相对实际价的平均绝对误差率，无样本时为 -1
+}
+
+// actualPriceAfter 同一股票在 rec.Time + horizon 之后的首条记录的当时价格，按时间升序的 later 中查找
+func actualPriceAfter(rec ConclusionRecord, later []ConclusionRecord, horizon time.Duration) (float64, bool) {
+	due := rec.Time.Add(horizon)
+	for _, r := range later {
+		if r.StockCode == rec.StockCode && !r.Time.Before(due) && r.CurrentPrice > 0 {
+			return r.CurrentPrice, true
+		}
+	}
+	return 0, false
+}
+
+// stanceHit 立场与评估期实际涨跌是否一致：看多须上涨、看空须下跌、中性须在 modelNeutralBand 内
+func stanceHit(stance string, change float64) bool {
+	switch stance {
+	case StanceBullish:
+		return change > 0
+	case StanceBearish:
+		return change < 0
+	default:
+		return math.Abs(change) <= modelNeutralBand
+	}
+}
+
+// EvaluateModels 基于带模型标记的历史结论按模型统计命中率与目标价误差，实际价取同股后续记录回填；
+// 指定 market 时只统计该市场。结果按命中率降序、目标价误差升序排列
+func EvaluateModels(records []ConclusionRecord, since time.Time, market string, horizon time.Duration) []ModelQuality {
+	sorted := append([]ConclusionRecord(nil), records...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	byModel := make(map[string]*ModelQuality)
+	errSum := make(map[string]float64)
+	var order []string
+	for i, rec := range sorted {
+		model := strings.TrimSpace(rec.Model)
+		if model == "" || rec.Time.Before(since) || (market != "" && rec.Market != market) {
+			continue
+		}
+		q, ok := byModel[model]
+		if !ok {
+			q = &ModelQuality{Model: model}
+			byModel[model] = q
+			order = append(order, model)
+		}
+		q.Predictions++
+		if rec.CurrentPrice <= 0 {
+			continue
+		}
+		actual, ok := actualPriceAfter(rec, sorted[i+1:], horizon)
+		if !ok {
+			continue
+		}
+		if rec.Stance != "" && rec.Stance != StanceMissing {
+			q.Samples++
+			if stanceHit(rec.Stance, (actual-rec.CurrentPrice)/rec.CurrentPrice) {
+				q.Hits++
+			}
+		}
+		if rec.TargetPrice > 0 {
+			q.TargetSamples++
+			errSum[model] += math.Abs(rec.TargetPrice-actual) / actual
+		}
+	}
+
+	list := make([]ModelQuality, 0, len(order))
+	for _, model := range order {
+		q := byModel[model]
+		q.HitRate, q.TargetMAPE = -1, -1
+		if q.Samples > 0 {
+			q.HitRate = float64(q.Hits) / float64(q.Samples)
+		}
+		if q.TargetSamples > 0 {
+			q.TargetMAPE = errSum[model] / float64(q.TargetSamples)
+		}
+		list = append(list, *q)
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if a.HitRate != b.HitRate {
+			return a.HitRate > b.HitRate
+		}
+		if (a.TargetMAPE < 0) != (b.TargetMAPE < 0) {
+			return a.TargetMAPE >= 0
+		}
+		if a.TargetMAPE != b.TargetMAPE {
+			return a.TargetMAPE < b.TargetMAPE
+		}
+		return a.Samples > b.Samples
+	})
+	return list
+}
+
+// FormatModelQuality 模型质量排行 markdown 表格
+func FormatModelQuality(list []ModelQuality, horizonDays int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "\n【模型质量排行】评估期 %d 天，实际价取同股后续分析时的价格\n", horizonDays)
+	if len(list) == 0 {
+		b.WriteString("暂无带模型标记的历史结论\n")
+		return b.String()
+	}
+	b.WriteString("| 排名 | 模型 | 结论数 | 已评估 | 方向命中率 | 目标价样本 | 目标价平均误差 |\n|---|---|---|---|---|---|---|\n")
+	for i, q := range list {
+		hit, mape := "-", "-"
+		if q.HitRate >= 0 {
+			hit = fmt.Sprintf("%s（%d/%d）", FormatPercent(q.HitRate), q.Hits, q.Samples)
+		}
+		if q.TargetMAPE >= 0 {
+			mape = FormatPercent(q.TargetMAPE)
+		}
+		fmt.Fprintf(&b, "| %d | %s | %d | %d | %s | %d | %s |\n", i+1, q.Model, q.Predictions, q.Samples, hit, q.TargetSamples, mape)
+	}
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package analysis
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluateModelsRanksByHitRateAndError(t *testing.T) {
+	t0 := time.Date(2024, 6, 3, 15, 0, 0, 0, time.Local)
+	day := func(n int) time.Time { return t0.AddDate(0, 0, n) }
+	records := []ConclusionRecord{
+		// 回填用的后续分析记录，无模型标记不参与统计
+		{StockCode: "600036", Market: MarketA, CurrentPrice: 50, Time: day(2)}, // 未到评估期，不作为实际价
+		{StockCode: "600036", Market: MarketA, CurrentPrice: 10.5, Time: day(6)},
+		{StockCode: "000001", Market: MarketA, CurrentPrice: 21, Time: day(6)},
+		{StockCode: "600519", Market: MarketA, CurrentPrice: 90, Time: day(7)},
+		{StockCode: "601398", Market: MarketA, CurrentPrice: 5.1, Time: day(6)},
+
+		{StockCode: "600036", Market: MarketA, Model: "deepseek-chat", Stance: StanceBullish, CurrentPrice: 10, TargetPrice: 11, Time: t0},
+		{StockCode: "000001", Market: MarketA, Model: "deepseek-chat", Stance: StanceBearish, CurrentPrice: 20, Time: t0},
+		{StockCode: "600519", Market: MarketA, Model: "gpt-4o", Stance: StanceBullish, CurrentPrice: 100, TargetPrice: 120, Time: t0},
+		{StockCode: "601398", Market: MarketA, Model: "gpt-4o", Stance: StanceNeutral, CurrentPrice: 5, TargetPrice: 5, Time: t0},
+		{StockCode: "600036", Market: MarketA, Model: "qwen", Stance: StanceMissing, CurrentPrice: 10, Time: t0},
+		{StockCode: "AAPL", Market: MarketUS, Model: "qwen", Stance: StanceBullish, CurrentPrice: 200, Time: t0},
+		{StockCode: "600036", Market: MarketA, Model: "gpt-4o", Stance: StanceBearish, CurrentPrice: 10, Time: day(-10)}, // 早于统计起点
+	}
+	list := EvaluateModels(records, day(-1), MarketA, time.Duration(ModelEvalHorizonDays)*24*time.Hour)
+	if len(list) != 3 || list[0].Model != "deepseek-chat" || list[1].Model != "gpt-4o" || list[2].Model != "qwen" {
+		t.Fatalf("排行顺序错误: %+v", list)
+	}
+	ds, gpt, qwen := list[0], list[1], list[2]
+	if ds.Predictions != 2 || ds.Samples != 2 || ds.Hits != 1 || ds.HitRate != 0.5 || ds.TargetSamples != 1 || math.Abs(ds.TargetMAPE-0.5/10.5) > 1e-12 {
+		t.Errorf("deepseek-chat 统计 = %+v", ds)
+	}
+	// 中性立场涨幅 2% 在容忍区间内算命中，命中率相同时按目标价误差排序
+	if gpt.Predictions != 2 || gpt.Hits != 1 || gpt.HitRate != 0.5 || gpt.TargetSamples != 2 || math.Abs(gpt.TargetMAPE-(30.0/90+0.1/5.1)/2) > 1e-12 {
+		t.Errorf("gpt-4o 统计 = %+v", gpt)
+	}
+	if qwen.Predictions != 1 || qwen.Samples != 0 || qwen.HitRate != -1 || qwen.TargetMAPE != -1 {
+		t.Errorf("qwen 立场缺失不应计入样本: %+v", qwen)
+	}
+	if all := EvaluateModels(records, day(-1), "", 5*24*time.Hour); len(all) != 3 || all[2].Model != "qwen" || all[2].Predictions != 2 {
+		t.Errorf("不限市场时 qwen 应有 2 条结论且无样本排最后: %+v", all)
+	}
+
+	table := FormatModelQuality(list, ModelEvalHorizonDays)
+	for _, want := range []string{"评估期 5 天", "| 1 | deepseek-chat | 2 | 2 | 50.00%（1/2） | 1 | 4.76% |", "| 3 | qwen | 1 | 0 | - | 0 | - |"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("排行表缺少 %q:\n%s", want, table)
+		}
+	}
+	if !strings.Contains(FormatModelQuality(nil, 5), "暂无带模型标记的历史结论") {
+		t.Error("无记录时应提示暂无数据")
+	}
+}
@@ -0,0 +1,159 @@
+package analysis
+
+import "fmt"
+
+// MoneyFlow 基于量价的本地资金流向估算
+type MoneyFlow struct {
+	OBV         float64 // 最新能量潮
+	OBVChange   float64 // 近 10 日 OBV 变化
+	OBVTrend    string  // 上升/下降/走平
+	NetInflow5  float64 // 近 5 日主力净流入估算（元）
+	NetInflow20 float64 // 近 20 日主力净流入估算（元）
+	VolumeRatio float64 // 近 5 日均量 / 近 20 日均量
+	PriceChange float64 // 近 5 日涨跌幅
+	Signal      string  // 放量上涨/放量下跌/缩量上涨/缩量下跌/量价平稳
+}
+
+// 放量/缩量判定阈值（近5日均量相对近20日均量）
+const (
+	heavyVolumeRatio = 1.2
+	lightVolumeRatio = 0.8
+)
+
+// obvStep 由前一日 OBV 递推当日 OBV：收涨加成交量，收跌减成交量，平盘不变
+func obvStep(prevOBV float64, prev, cur StockData) float64 {
+	switch {
+	case cur.Close > prev.Close:
+		return prevOBV + cur.Volume
+	case cur.Close < prev.Close:
+		return prevOBV - cur.Volume
+	default:
+		return prevOBV
+	}
+}
+
+// calculateOBV 顺序累加整段能量潮，首日为 0；单次遍历 O(n)，长序列不会深递归
+func calculateOBV(stockData []StockData) []float64 {
+	obv := make([]float64, len(stockData))
+	for i := 1; i < len(stockData); i++ {
+		obv[i] = obvStep(obv[i-1], stockData[i-1], stockData[i])
+	}
+	return obv
+}
+
+// estimateNetInflow 单日主力净流入估算：收盘在当日振幅中的位置（-1~1）× 成交额
+func estimateNetInflow(d StockData) float64 {
+	if d.High <= d.Low {
+		return 0
+	}
+	clv := ((d.Close - d.Low) - (d.High - d.Close)) / (d.High - d.Low)
+	return clv * d.Volume * d.Close
+}
+
+// CalculateMoneyFlow 计算 OBV 趋势、主力净流入估算与量价信号，数据不足时返回零值
+func CalculateMoneyFlow(stockData []StockData) MoneyFlow {
+	var mf MoneyFlow
+	n := len(stockData)
+	if n < 2 {
+		return mf
+	}
+
+	obv := calculateOBV(stockData)
+	mf.OBV = obv[n-1]
+	k := 10
+	if n-1 < k {
+		k = n - 1
+	}
+	mf.OBVChange = obv[n-1] - obv[n-1-k]
+
+	sumInflow := func(days int) float64 {
+		if days > n {
+			days = n
+		}
+		total := 0.0
+		for _, d := range stockData[n-days:] {
+			total += estimateNetInflow(d)
+		}
+		return total
+	}
+	mf.NetInflow5 = sumInflow(5)
+	mf.NetInflow20 = sumInflow(20)
+
+	avgVolume := func(days int) float64 {
+		if days > n {
+			days = n
+		}
+		total := 0.0
+		for _, d := range stockData[n-days:] {
+			total += d.Volume
+		}
+		return total / float64(days)
+	}
+	vol5, vol20 := avgVolume(5), avgVolume(20)
+	if vol20 > 0 {
+		mf.VolumeRatio = vol5 / vol20
+	}
+
+	// OBV 变化超过 k 日平均成交量的一半才视为有方向
+	if threshold := avgVolume(k) * 0.5; mf.OBVChange > threshold {
+		mf.OBVTrend = "上升"
+	} else if mf.OBVChange < -threshold {
+		mf.OBVTrend = "下降"
+	} else {
+		mf.OBVTrend = "走平"
+	}
+
+	back := 5
+	if n-1 < back {
+		back = n - 1
+	}
+	if base := stockData[n-1-back].Close; base > 0 {
+		mf.PriceChange = (stockData[n-1].Close - base) / base
+	}
+	switch {
+	case mf.VolumeRatio >= heavyVolumeRatio && mf.PriceChange > 0:
+		mf.Signal = "放量上涨"
+	case mf.VolumeRatio >= heavyVolumeRatio && mf.PriceChange < 0:
+		mf.Signal = "放量下跌"
+	case mf.VolumeRatio <= lightVolumeRatio && mf.PriceChange > 0:
+		mf.Signal = "缩量上涨"
+	case mf.VolumeRatio <= lightVolumeRatio && mf.PriceChange < 0:
+		mf.Signal = "缩量下跌"
+	default:
+		mf.Signal = "量价平稳"
+	}
+	return mf
+}
+
+// FormatMoneyFlowPrompt 注入 prompt 的资金面本地数据，按 lang 输出
+func FormatMoneyFlowPrompt(mf MoneyFlow, lang string) string {
+	if mf.OBVTrend == "" {
+		return ""
+	}
+	return fmt.Sprintf(wrapperFor(lang).moneyFlow, localize(mf.OBVTrend, lang), formatVolumeLang(mf.OBVChange, lang),
+		formatAmountLang(mf.NetInflow5, lang), formatAmountLang(mf.NetInflow20, lang), FormatRatio(mf.VolumeRatio), FormatPercent(mf.PriceChange), localize(mf.Signal, lang))
+}
+
+// FormatMoneyFlowTable 资金流向 markdown 表格，lang 为 en 时使用英文表头与取值
+func FormatMoneyFlowTable(mf MoneyFlow, lang string) string {
+	if mf.OBVTrend == "" {
+		return ""
+	}
+	l := labelsFor(lang)
+	head := "\n" + l.moneyFlowTitle + "\n" + markdownHeader(l.moneyFlowHead)
+	row := fmt.Sprintf("| %s | %s | %s | %s | %s | %s |\n",
+		localize(mf.OBVTrend, lang), formatAmountLang(mf.NetInflow5, lang), formatAmountLang(mf.NetInflow20, lang), FormatRatio(mf.VolumeRatio), FormatPercent(mf.PriceChange), localize(mf.Signal, lang))
+	return head + row
+}
+
+// FormatMoneyFlowTableHTML 资金流向 HTML 表格，lang 为 en 时使用英文表头与取值
+func FormatMoneyFlowTableHTML(mf MoneyFlow, lang string) string {
+	if mf.OBVTrend == "" {
+		return ""
+	}
+	l := labelsFor(lang)
+	return "\n<h3>" + l.moneyFlowTitle + "</h3>\n<table>\n" + htmlHeader(l.moneyFlowHead) + fmt.Sprintf(`
+<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>
+</table>
+`, localize(mf.OBVTrend, lang), formatAmountLang(mf.NetInflow5, lang), formatAmountLang(mf.NetInflow20, lang), FormatRatio(mf.VolumeRatio), FormatPercent(mf.PriceChange), localize(mf.Signal, lang))
+}
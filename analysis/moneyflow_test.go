@@ -0,0 +1,117 @@
+package analysis
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// recursiveOBV 按定义递归计算第 index 日 OBV，作为迭代实现的对照
+func recursiveOBV(data []StockData, index int) float64 {
+	if index <= 0 {
+		return 0
+	}
+	prev := recursiveOBV(data, index-1)
+	switch {
+	case data[index].Close > data[index-1].Close:
+		return prev + data[index].Volume
+	case data[index].Close < data[index-1].Close:
+		return prev - data[index].Volume
+	default:
+		return prev
+	}
+}
+
+func TestCalculateOBVMatchesRecursive(t *testing.T) {
+	data := syntheticBars(120)
+	data[10].Close = data[9].Close // 平盘
+	obv := calculateOBV(data)
+	for i := range data {
+		if want := recursiveOBV(data, i); obv[i] != want {
+			t.Fatalf("第 %d 日 OBV = %v，期望 %v", i, obv[i], want)
+		}
+	}
+	ind := calculateTechnicalIndicators(data)
+	if last := len(data) - 1; ind[last].OBV != obv[last] {
+		t.Errorf("技术指标中的 OBV = %v，期望 %v", ind[last].OBV, obv[last])
+	}
+	if len(calculateOBV(nil)) != 0 || calculateOBV(data[:1])[0] != 0 {
+		t.Error("空序列与单根K线的 OBV 应为空与 0")
+	}
+}
+
+func BenchmarkCalculateOBV1000(b *testing.B) {
+	data := syntheticBars(1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		calculateOBV(data)
+	}
+}
+
+func TestFormatMoneyFlowTableEnglish(t *testing.T) {
+	mf := MoneyFlow{OBVTrend: "上升", NetInflow5: 1.5e8, NetInflow20: -3e7, VolumeRatio: 1.3, PriceChange: 0.04, Signal: "放量上涨"}
+	for name, table := range map[string]string{"markdown": FormatMoneyFlowTable(mf, LangEN), "html": FormatMoneyFlowTableHTML(mf, LangEN)} {
+		if containsHan(table) {
+			t.Errorf("%s 英文表格含中文:\n%s", name, table)
+		}
+		for _, want := range []string{"[Money Flow]", "rising", "rising on heavy volume", "150.00M"} {
+			if !strings.Contains(table, want) {
+				t.Errorf("%s 英文表格缺少 %q:\n%s", name, want, table)
+			}
+		}
+	}
+	if zh := FormatMoneyFlowTable(mf, ""); !strings.Contains(zh, "【资金流向】") || !strings.Contains(zh, "放量上涨") {
+		t.Errorf("中文表格:\n%s", zh)
+	}
+	if FormatMoneyFlowTable(MoneyFlow{}, LangEN) != "" {
+		t.Error("无数据时应返回空")
+	}
+}
+
+// volumeBreakoutBars 20 根平盘常量K线后接 5 根放量单边K线，dir 为 1 时收于最高、逐日上涨，-1 时收于最低、逐日下跌
+func volumeBreakoutBars(dir float64) []StockData {
+	data := make([]StockData, 0, 25)
+	for i := 0; i < 20; i++ {
+		data = append(data, StockData{Open: 10, Close: 10, High: 10.5, Low: 9.5, Volume: 1e6})
+	}
+	price := 10.0
+	for i := 0; i < 5; i++ {
+		open := price
+		price += dir * 0.3
+		data = append(data, StockData{Open: open, Close: price, High: math.Max(open, price), Low: math.Min(open, price), Volume: 3e6})
+	}
+	return data
+}
+
+func TestCalculateMoneyFlowHeavyVolumeRise(t *testing.T) {
+	mf := CalculateMoneyFlow(volumeBreakoutBars(1))
+	if mf.Signal != "放量上涨" || mf.OBVTrend != "上升" {
+		t.Errorf("放量上涨: 信号 %q，OBV 趋势 %q", mf.Signal, mf.OBVTrend)
+	}
+	if mf.OBVChange != 15e6 || mf.OBV != 15e6 {
+		t.Errorf("OBV = %v，近 10 日变化 %v，期望均为 1500 万", mf.OBV, mf.OBVChange)
+	}
+	// 收于最高价时当日净流入为全部成交额
+	if want := 3e6 * (10.3 + 10.6 + 10.9 + 11.2 + 11.5); math.Abs(mf.NetInflow5-want) > 1e-3 || mf.NetInflow20 != mf.NetInflow5 {
+		t.Errorf("净流入 5 日 %v、20 日 %v，期望 %v", mf.NetInflow5, mf.NetInflow20, want)
+	}
+	if want := 3e6 / ((15*1e6 + 5*3e6) / 20); mf.VolumeRatio != want || mf.PriceChange <= 0 {
+		t.Errorf("量比 %v（期望 %v），5 日涨幅 %v", mf.VolumeRatio, want, mf.PriceChange)
+	}
+}
+
+func TestCalculateMoneyFlowHeavyVolumeFall(t *testing.T) {
+	mf := CalculateMoneyFlow(volumeBreakoutBars(-1))
+	if mf.Signal != "放量下跌" || mf.OBVTrend != "下降" || mf.OBV != -15e6 {
+		t.Errorf("放量下跌: 信号 %q，OBV 趋势 %q，OBV %v", mf.Signal, mf.OBVTrend, mf.OBV)
+	}
+	if mf.NetInflow5 >= 0 || mf.NetInflow20 != mf.NetInflow5 || mf.PriceChange >= 0 {
+		t.Errorf("放量下跌应为净流出: 5 日 %v，20 日 %v，涨跌幅 %v", mf.NetInflow5, mf.NetInflow20, mf.PriceChange)
+	}
+	if prompt := FormatMoneyFlowPrompt(mf, ""); !strings.Contains(prompt, "放量下跌") {
+		t.Errorf("资金面 prompt 应包含量价信号:\n%s", prompt)
+	}
+	if (CalculateMoneyFlow(volumeBreakoutBars(1)[:1]) != MoneyFlow{}) {
+		t.Error("数据不足时应返回零值")
+	}
+}
@@ -0,0 +1,150 @@
+package analysis
+
+import (
+	"math"
+	"sort"
+)
+
+// OptimizeObjective 网格寻优的目标：sharpe（默认）、total_return、annualized_return、profit_factor、max_drawdown（越小越好）
+var OptimizeObjective = "sharpe"
+
+// GridPoint 网格中一组参数的回测评估
+type GridPoint struct {
+	Values map[string]float64 // 本组取值，键同 grid
+	Params BacktestParams
+	Result BacktestResult
+	Score  float64 // 按 OptimizeObjective 计算的得分，越大越好
+}
+
+// DefaultBacktestParams 未指定回测参数时使用的默认值
+func DefaultBacktestParams() BacktestParams {
+	return BacktestParams{
+		StrategyType:   "ma_cross",
+		FastMAPeriod:   5,
+		SlowMAPeriod:   20,
+		BreakoutPeriod: 10,
+		RSIPeriod:      14,
+		RSIOverbought:  70,
+		RSIOversold:    30,
+		StopLoss:       0.05,
+		TakeProfit:     0.10,
+		InitialCash:    100000,
+	}
+}
+
+// setGridParam 按字段名设置回测参数，未知字段返回 false
+func setGridParam(p *BacktestParams, key string, v float64) bool {
+	switch key {
+	case "FastMAPeriod":
+		p.FastMAPeriod = int(v)
+	case "SlowMAPeriod":
+		p.SlowMAPeriod = int(v)
+	case "BreakoutPeriod":
+		p.BreakoutPeriod = int(v)
+	case "RSIPeriod":
+		p.RSIPeriod = int(v)
+	case "RSIOverbought":
+		p.RSIOverbought = v
+	case "RSIOversold":
+		p.RSIOversold = v
+	case "StopLoss":
+		p.StopLoss = v
+	case "TakeProfit":
+		p.TakeProfit = v
+	case "ATRPeriod":
+		p.ATRPeriod = int(v)
+	case "ATRMultiplier":
+		p.ATRMultiplier = v
+	case "InitialCash":
+		p.InitialCash = v
+	default:
+		return false
+	}
+	return true
+}
+
+// validGridParams 参数组合是否合法：快线须短于慢线、超卖须低于超买、周期为正且短于行情长度
+func validGridParams(p BacktestParams, n int) bool {
+	switch p.StrategyType {
+	case "breakout":
+		return p.BreakoutPeriod > 0 && p.BreakoutPeriod < n
+	case "rsi":
+		return p.RSIPeriod > 0 && p.RSIPeriod < n && p.RSIOversold < p.RSIOverbought
+	case "atr_trailing":
+		return p.ATRPeriod >= 0 && p.ATRPeriod < n && p.ATRMultiplier >= 0
+	default:
+		return p.FastMAPeriod > 0 && p.FastMAPeriod < p.SlowMAPeriod && p.SlowMAPeriod < n
+	}
+}
+
+// objectiveScore 按 OptimizeObjective 计算得分
+func objectiveScore(r BacktestResult) float64 {
+	switch OptimizeObjective {
+	case "total_return":
+		return r.TotalReturn
+	case "annualized_return":
+		return r.AnnualizedReturn
+	case "profit_factor":
+		return r.ProfitFactor
+	case "max_drawdown":
+		return -r.MaxDrawdown
+	default:
+		return r.SharpeRatio
+	}
+}
+
+// OptimizeStrategy 对 grid（键为 BacktestParams 字段名，如 FastMAPeriod/SlowMAPeriod）的全部取值组合逐一回测，
+// 按 OptimizeObjective 选出最优参数；未出现在 grid 中的参数取 DefaultBacktestParams，未知字段与非法组合跳过。
+// 组合按字段名排序后依序枚举，得分相同时保留先出现的组合，结果可复现
+func OptimizeStrategy(stockData []StockData, strategyType string, grid map[string][]float64) (BacktestParams, BacktestResult, []GridPoint) {
+	base := DefaultBacktestParams()
+	base.StrategyType = strategyType
+	var keys []string
+	for k, vals := range grid {
+		if len(vals) > 0 && setGridParam(&BacktestParams{}, k, 0) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var points []GridPoint
+	best := -1
+	bestScore := math.Inf(-1)
+	idx := make([]int, len(keys))
+	for {
+		p := base
+		values := make(map[string]float64, len(keys))
+		for i, k := range keys {
+			v := grid[k][idx[i]]
+			setGridParam(&p, k, v)
+			values[k] = v
+		}
+		if validGridParams(p, len(stockData)) {
+			r := BacktestStrategy(stockData, p)
+			score := objectiveScore(r)
+			if math.IsNaN(score) {
+				score = math.Inf(-1)
+			}
+			points = append(points, GridPoint{Values: values, Params: p, Result: r, Score: score})
+			if best < 0 || score > bestScore {
+				best, bestScore = len(points)-1, score
+			}
+		}
+		// 末位优先进位，枚举下一组合
+		i := len(keys) - 1
+		for ; i >= 0; i-- {
+			idx[i]++
+			if idx[i] < len(grid[keys[i]]) {
+				break
+			}
+			idx[i] = 0
+		}
+		if i < 0 {
+			break
+		}
+	}
+	if best < 0 {
+		return base, BacktestResult{}, points
+	}
+	return points[best].Params, points[best].Result, points
+}
@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOptimizeStrategySmallGridReproducible(t *testing.T) {
+	data := syntheticBars(200)
+	grid := map[string][]float64{
+		"FastMAPeriod": {5, 10, 20},
+		"SlowMAPeriod": {10, 20, 30},
+		"Unknown":      {1, 2}, // 未知字段忽略
+	}
+	best, result, points := OptimizeStrategy(data, "ma_cross", grid)
+	if len(points) != 6 {
+		t.Fatalf("9 组组合中快线不短于慢线的 3 组应跳过，得到 %d 组", len(points))
+	}
+	if v := points[0].Values; v["FastMAPeriod"] != 5 || v["SlowMAPeriod"] != 10 || len(v) != 2 {
+		t.Errorf("应按字段名顺序、末位优先枚举: %v", v)
+	}
+	bestIdx := 0
+	for i, p := range points {
+		if p.Params.FastMAPeriod >= p.Params.SlowMAPeriod || p.Params.StrategyType != "ma_cross" {
+			t.Errorf("非法组合未跳过: %+v", p.Params)
+		}
+		if p.Score != p.Result.SharpeRatio {
+			t.Errorf("默认目标应为夏普比率: %v != %v", p.Score, p.Result.SharpeRatio)
+		}
+		if p.Score > points[bestIdx].Score {
+			bestIdx = i
+		}
+	}
+	if !reflect.DeepEqual(best, points[bestIdx].Params) || !reflect.DeepEqual(result, points[bestIdx].Result) {
+		t.Errorf("最优参数应为得分最高且最先出现的组合: %+v，期望 %+v", best, points[bestIdx].Params)
+	}
+	if best.InitialCash != DefaultBacktestParams().InitialCash {
+		t.Errorf("网格外参数应取默认值: %+v", best)
+	}
+
+	best2, result2, points2 := OptimizeStrategy(data, "ma_cross", grid)
+	if !reflect.DeepEqual(best, best2) || !reflect.DeepEqual(result, result2) || !reflect.DeepEqual(points, points2) {
+		t.Error("相同输入的寻优结果应可复现")
+	}
+}
+
+func TestOptimizeStrategyObjectiveAndNoValidPoint(t *testing.T) {
+	old := OptimizeObjective
+	OptimizeObjective = "max_drawdown"
+	defer func() { OptimizeObjective = old }()
+
+	_, result, points := OptimizeStrategy(syntheticBars(200), "breakout", map[string][]float64{"BreakoutPeriod": {5, 10, 20, 500}})
+	if len(points) != 3 {
+		t.Fatalf("周期不短于行情长度的组合应跳过，得到 %d 组", len(points))
+	}
+	for _, p := range points {
+		if p.Score != -p.Result.MaxDrawdown || p.Result.MaxDrawdown < result.MaxDrawdown {
+			t.Errorf("按最大回撤寻优应选回撤最小的组合: %v / 最优 %v", p.Result.MaxDrawdown, result.MaxDrawdown)
+		}
+	}
+
+	best, result, points := OptimizeStrategy(syntheticBars(30), "ma_cross", map[string][]float64{"FastMAPeriod": {20}, "SlowMAPeriod": {10}})
+	if len(points) != 0 || result.Trades != 0 || best.FastMAPeriod != 5 || best.SlowMAPeriod != 20 {
+		t.Errorf("无合法组合时应返回默认参数与空结果: %+v %+v", best, points)
+	}
+}
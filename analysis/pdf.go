@@ -0,0 +1,121 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/page"
+	"github.com/chromedp/chromedp"
+)
+
+// PDF 引擎
+const (
+	PDFEngineAuto        = "auto"        // 优先本地 Chrome，不可用时回退 wkhtmltopdf
+	PDFEngineChrome      = "chrome"      // 仅使用 chromedp 驱动的本地 Chrome
+	PDFEngineWkhtmltopdf = "wkhtmltopdf" // 仅使用 wkhtmltopdf 命令
+)
+
+// PDFEngine 当前 PDF 引擎，默认自动选择
+var PDFEngine = PDFEngineAuto
+
+// pdfTimeout 单次 PDF 渲染超时，避免浏览器卡死阻塞导出
+const pdfTimeout = 2 * time.Minute
+
+// chromeCandidates chromedp 默认会查找的浏览器可执行文件
+var chromeCandidates = []string{
+	"headless_shell", "headless-shell", "chromium", "chromium-browser",
+	"google-chrome", "google-chrome-stable", "google-chrome-beta", "google-chrome-unstable",
+	"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+	"/Applications/Chromium.app/Contents/MacOS/Chromium",
+	`C:\Program Files\Google\Chrome\Application\chrome.exe`,
+	`C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`,
+}
+
+// chromeAvailable 本机是否有可供 chromedp 启动的浏览器
+func chromeAvailable() bool {
+	for _, name := range chromeCandidates {
+		if _, err := exec.LookPath(name); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// chromePDF 用本地 Chrome 打印 PDF
+func chromePDF(ctx context.Context, htmlPath, pdfPath string) error {
+	if !chromeAvailable() {
+		return fmt.Errorf("未找到可用的 Chrome/Chromium")
+	}
+	ctx, cancel := chromedp.NewContext(ctx)
+	defer cancel()
+	ctx, cancelTimeout := context.WithTimeout(ctx, pdfTimeout)
+	defer cancelTimeout()
+	var pdfBuf []byte
+	absPath, _ := filepath.Abs(htmlPath)
+	fileURL := "file://" + absPath
+	err := chromedp.Run(ctx,
+		chromedp.Navigate(fileURL),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			var err error
+			pdfBuf, _, err = page.PrintToPDF().WithPrintBackground(true).Do(ctx)
+			return err
+		}),
+	)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(pdfPath, pdfBuf, 0644)
+}
+
+// wkhtmltopdfPDF 调用 wkhtmltopdf 命令生成 PDF
+func wkhtmltopdfPDF(ctx context.Context, htmlPath, pdfPath string) error {
+	bin, err := exec.LookPath("wkhtmltopdf")
+	if err != nil {
+		return fmt.Errorf("未找到 wkhtmltopdf")
+	}
+	ctx, cancel := context.WithTimeout(ctx, pdfTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, bin, "--quiet", "--encoding", "utf-8", "--enable-local-file-access", htmlPath, pdfPath).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%v: %s", err, msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// htmlToPDF 按 PDFEngine 将 HTML 转为 PDF；auto 模式下 Chrome 失败时回退 wkhtmltopdf，全部失败时返回各引擎的原因
+func htmlToPDF(ctx context.Context, htmlPath, pdfPath string) error {
+	var engines []string
+	switch PDFEngine {
+	case PDFEngineAuto, "":
+		engines = []string{PDFEngineChrome, PDFEngineWkhtmltopdf}
+	case PDFEngineChrome, PDFEngineWkhtmltopdf:
+		engines = []string{PDFEngine}
+	default:
+		return fmt.Errorf("未知 PDF 引擎: %s（可选 auto/chrome/wkhtmltopdf）", PDFEngine)
+	}
+	var reasons []string
+	for _, engine := range engines {
+		var err error
+		if engine == PDFEngineChrome {
+			err = chromePDF(ctx, htmlPath, pdfPath)
+		} else {
+			err = wkhtmltopdfPDF(ctx, htmlPath, pdfPath)
+		}
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %v", engine, err))
+	}
+	return fmt.Errorf("%s", strings.Join(reasons, "；"))
+}
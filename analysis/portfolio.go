@@ -0,0 +1,179 @@
+package analysis
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// PortfolioComponent 组合中单只成分股的回测表现
+type PortfolioComponent struct {
+	StockCode    string
+	Weight       float64 // 归一后的权重
+	Return       float64 // 成分自身收益率
+	Contribution float64 // 对组合收益的贡献 = 权重 × 成分收益率
+	Result       BacktestResult
+}
+
+// PortfolioBacktestResult 组合级回测结果
+type PortfolioBacktestResult struct {
+	InitialCapital float64
+	TotalReturn    float64
+	Volatility     float64 // 组合资金曲线日收益的年化波动率
+	MaxDrawdown    float64
+	SharpeRatio    float64
+	Dates          []time.Time // 对齐后的交易日
+	EquityCurve    []float64   // 各成分资金曲线逐日加总，首点为初始资金
+	Components     []PortfolioComponent
+}
+
+// normalizeWeights 剔除无行情与非正权重的成分后归一，weights 为空时对全部有行情的股票等权
+func normalizeWeights(stockData map[string][]StockData, weights map[string]float64) map[string]float64 {
+	norm := make(map[string]float64)
+	total := 0.0
+	if len(weights) == 0 {
+		for code, data := range stockData {
+			if len(data) > 0 {
+				norm[code] = 1
+				total++
+			}
+		}
+	} else {
+		for code, w := range weights {
+			if w > 0 && len(stockData[code]) > 0 {
+				norm[code] = w
+				total += w
+			}
+		}
+	}
+	for code := range norm {
+		norm[code] /= total
+	}
+	return norm
+}
+
+// alignStockData 按全部成分的交易日并集对齐行情：从所有成分都已上市（有数据）的首日开始，
+// 某成分缺失的日期用前一根K线的收盘价填充、成交量记 0
+func alignStockData(stockData map[string][]StockData, codes []string) ([]time.Time, map[string][]StockData) {
+	startKey := ""
+	dateSet := make(map[string]time.Time)
+	sorted := make(map[string][]StockData, len(codes))
+	for _, code := range codes {
+		data := append([]StockData(nil), stockData[code]...)
+		sort.Slice(data, func(i, j int) bool { return data[i].Date.Before(data[j].Date) })
+		sorted[code] = data
+		if key := data[0].Date.Format("2006-01-02"); key > startKey {
+			startKey = key
+		}
+		for _, d := range data {
+			dateSet[d.Date.Format("2006-01-02")] = d.Date
+		}
+	}
+	var keys []string
+	for key := range dateSet {
+		if key >= startKey {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	dates := make([]time.Time, len(keys))
+	for i, key := range keys {
+		dates[i] = dateSet[key]
+	}
+
+	aligned := make(map[string][]StockData, len(codes))
+	for _, code := range codes {
+		data := sorted[code]
+		series := make([]StockData, 0, len(keys))
+		var prev StockData
+		k := 0
+		for i, key := range keys {
+			var cur *StockData
+			for ; k < len(data) && data[k].Date.Format("2006-01-02") <= key; k++ {
+				prev = data[k]
+				if data[k].Date.Format("2006-01-02") == key {
+					cur = &data[k]
+				}
+			}
+			if cur != nil {
+				series = append(series, *cur)
+				continue
+			}
+			series = append(series, StockData{Date: dates[i], Open: prev.Close, Close: prev.Close, High: prev.Close, Low: prev.Close})
+		}
+		aligned[code] = series
+	}
+	return dates, aligned
+}
+
+// BacktestPortfolio 组合回测：按日期并集对齐各成分行情（缺失日前值填充），按权重分配 params.InitialCash，
+// 各成分独立应用策略信号后逐日汇总资金曲线。weights 为空时等权
+func BacktestPortfolio(stockData map[string][]StockData, weights map[string]float64, params BacktestParams) PortfolioBacktestResult {
+	norm := normalizeWeights(stockData, weights)
+	res := PortfolioBacktestResult{InitialCapital: params.InitialCash}
+	if len(norm) == 0 {
+		return res
+	}
+	codes := make([]string, 0, len(norm))
+	for code := range norm {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	dates, aligned := alignStockData(stockData, codes)
+
+	for _, code := range codes {
+		p := params
+		p.InitialCash = params.InitialCash * norm[code]
+		r := BacktestStrategy(aligned[code], p)
+		// 数据不足未产生资金曲线的成分按持有现金处理
+		curve := r.EquityCurve
+		if len(curve) == 0 {
+			curve = []float64{p.InitialCash}
+		}
+		if res.EquityCurve == nil {
+			res.EquityCurve = make([]float64, len(curve))
+		}
+		for i := range res.EquityCurve {
+			// 各成分资金曲线长度一致，个别成分较短时末值延续
+			v := curve[len(curve)-1]
+			if i < len(curve) {
+				v = curve[i]
+			}
+			res.EquityCurve[i] += v
+		}
+		res.Components = append(res.Components, PortfolioComponent{
+			StockCode:    code,
+			Weight:       norm[code],
+			Return:       r.TotalReturn,
+			Contribution: norm[code] * r.TotalReturn,
+			Result:       r,
+		})
+		res.TotalReturn += norm[code] * r.TotalReturn
+	}
+	// 资金曲线首点为回测起始日前一根K线
+	if n := len(res.EquityCurve); n <= len(dates) {
+		res.Dates = dates[len(dates)-n:]
+	}
+	returns := equityReturns(res.EquityCurve)
+	if len(returns) > 1 {
+		res.Volatility = calculateVolatility(returns)
+		res.SharpeRatio = calculateSharpeRatio(returns, RiskFreeRate)
+	}
+	res.MaxDrawdown = equityMaxDrawdown(res.EquityCurve)
+	return res
+}
+
+// FormatPortfolioBacktest 组合回测汇总与成分贡献 markdown 表格
+func FormatPortfolioBacktest(res PortfolioBacktestResult) string {
+	var b strings.Builder
+	b.WriteString("\n【组合回测结果】\n| 初始资金 | 总收益率 | 年化波动率 | 最大回撤 | 夏普比率 |\n|---|---|---|---|---|\n")
+	fmt.Fprintf(&b, "| %.0f | %s | %s | %s | %s |\n\n", res.InitialCapital, FormatPercent(res.TotalReturn),
+		FormatPercent(res.Volatility), FormatPercent(res.MaxDrawdown), FormatRatio(res.SharpeRatio))
+	b.WriteString("| 成分 | 权重 | 成分收益率 | 收益贡献 | 交易次数 |\n|---|---|---|---|---|\n")
+	for _, c := range res.Components {
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %d |\n", c.StockCode, FormatPercent(c.Weight),
+			FormatPercent(c.Return), FormatPercent(c.Contribution), c.Result.Trades)
+	}
+	return b.String()
+}
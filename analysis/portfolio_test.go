@@ -0,0 +1,58 @@
+package analysis
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestBacktestPortfolioEqualWeightBetweenComponents(t *testing.T) {
+	stocks := map[string][]StockData{"600036": trendBars(120, 0.01), "000001": datedBars(120)}
+	params := DefaultBacktestParams()
+	res := BacktestPortfolio(stocks, nil, params)
+	if len(res.Components) != 2 || res.Components[0].StockCode != "000001" || res.Components[1].StockCode != "600036" {
+		t.Fatalf("成分应按代码排序: %+v", res.Components)
+	}
+	a, b := res.Components[0], res.Components[1]
+	if a.Weight != 0.5 || b.Weight != 0.5 || a.Return == b.Return {
+		t.Fatalf("等权且两只收益应不同: %+v / %+v", a, b)
+	}
+	lo, hi := math.Min(a.Return, b.Return), math.Max(a.Return, b.Return)
+	if res.TotalReturn <= lo || res.TotalReturn >= hi || math.Abs(res.TotalReturn-(a.Return+b.Return)/2) > 1e-12 {
+		t.Errorf("等权组合收益 %v 应介于 %v 与 %v 之间且为两者均值", res.TotalReturn, lo, hi)
+	}
+	if math.Abs(a.Contribution+b.Contribution-res.TotalReturn) > 1e-12 {
+		t.Errorf("各成分贡献之和 %v 应等于组合收益 %v", a.Contribution+b.Contribution, res.TotalReturn)
+	}
+	last := res.EquityCurve[len(res.EquityCurve)-1]
+	if res.EquityCurve[0] != params.InitialCash || math.Abs(last/params.InitialCash-1-res.TotalReturn) > 1e-9 {
+		t.Errorf("资金曲线首尾 %v / %v 与总收益 %v 不符", res.EquityCurve[0], last, res.TotalReturn)
+	}
+	if len(res.Dates) != len(res.EquityCurve) || res.Volatility <= 0 || res.MaxDrawdown < 0 {
+		t.Errorf("日期 %d 条、资金曲线 %d 点，波动率 %v 回撤 %v", len(res.Dates), len(res.EquityCurve), res.Volatility, res.MaxDrawdown)
+	}
+	if text := FormatPortfolioBacktest(res); !strings.Contains(text, "【组合回测结果】") || !strings.Contains(text, "| 600036 | 50.00% |") {
+		t.Errorf("组合回测报告:\n%s", text)
+	}
+}
+
+func TestBacktestPortfolioWeightsAndAlignment(t *testing.T) {
+	full := datedBars(60)
+	gappy := append(append([]StockData(nil), trendBars(60, 0.01)[5:30]...), trendBars(60, 0.01)[31:]...)
+	dates, aligned := alignStockData(map[string][]StockData{"A": full, "B": gappy}, []string{"A", "B"})
+	if len(dates) != 55 || !dates[0].Equal(full[5].Date) {
+		t.Fatalf("应从两只都有数据的首日对齐: %d 天，首日 %v", len(dates), dates[0])
+	}
+	filled := aligned["B"][25]
+	if len(aligned["B"]) != 55 || !filled.Date.Equal(full[30].Date) || filled.Close != gappy[24].Close || filled.Volume != 0 {
+		t.Errorf("缺失日应以前值填充: %+v", filled)
+	}
+
+	res := BacktestPortfolio(map[string][]StockData{"A": full, "B": gappy, "C": datedBars(60)}, map[string]float64{"A": 3, "B": 1, "C": 0}, DefaultBacktestParams())
+	if len(res.Components) != 2 || res.Components[0].Weight != 0.75 || res.Components[1].Weight != 0.25 {
+		t.Errorf("权重应归一且剔除非正权重: %+v", res.Components)
+	}
+	if empty := BacktestPortfolio(nil, nil, DefaultBacktestParams()); len(empty.Components) != 0 || empty.TotalReturn != 0 {
+		t.Errorf("无成分时应返回空结果: %+v", empty)
+	}
+}
@@ -0,0 +1,220 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PredictionsFile 预测追踪记录，每次分析成功追加一行，-update-actual 据此补全实际收盘价
+var PredictionsFile = filepath.Join("history", "predictions.csv")
+
+// predictionsMu 串行化预测记录写入
+var predictionsMu sync.Mutex
+
+// PredictionColumns 预测记录的列，前两列（股票代码、预测日期）位置固定，供实际价格补全按列序读取；
+// 已有文件中的其他列（如 T+1实际收盘价）保留不动
+var PredictionColumns = []string{"股票代码", "预测日期", "当前价", "目标价", "止损价", "上涨概率", "下跌概率", "趋势", "建议", "模型", "报告文件"}
+
+// 关键词取各语言并集，见 reportKeywordSets
+var (
+	stopLossRe = regexp.MustCompile(keywordPattern(func(k reportKeywords) []string { return k.stopLoss }) + `[^0-9\n]{0,12}([0-9]+(?:\.[0-9]+)?)`)
+	upProbRe   = regexp.MustCompile(keywordPattern(func(k reportKeywords) []string { return k.upProb }) + `[^0-9\n]{0,12}([0-9]+(?:\.[0-9]+)?)\s*%`)
+	downProbRe = regexp.MustCompile(keywordPattern(func(k reportKeywords) []string { return k.downProb }) + `[^0-9\n]{0,12}([0-9]+(?:\.[0-9]+)?)\s*%`)
+)
+
+// PredictionRecord 从报告中解析出的一条预测，未提及的数值为 0，写入时留空
+type PredictionRecord struct {
+	StockCode    string
+	Date         string // 预测日期 YYYY-MM-DD，即分析区间结束日
+	CurrentPrice float64
+	TargetPrice  float64
+	StopLoss     float64
+	UpProb       float64 // 上涨概率（%）
+	DownProb     float64 // 下跌概率（%）
+	Trend        string  // 偏多/偏空/中性
+	Advice       string  // 偏多/偏空/中性
+	Model        string
+	SavedFile    string
+}
+
+// matchFloat 取正则第一个分组的数值，未匹配返回 0
+func matchFloat(re *regexp.Regexp, s string) float64 {
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+	v, _ := strconv.ParseFloat(m[1], 64)
+	return v
+}
+
+// ExtractPrediction 从报告解析目标价、止损价、涨跌概率与方向，date 为空时取当天
+func ExtractPrediction(stockCode, date, report string, conclusion StructuredConclusion) PredictionRecord {
+	if date == "" {
+		date = time.Now().Format("2006-01-02")
+	}
+	return PredictionRecord{
+		StockCode:    stockCode,
+		Date:         date,
+		CurrentPrice: conclusion.CurrentPrice,
+		TargetPrice:  conclusion.TargetPrice,
+		StopLoss:     matchFloat(stopLossRe, report),
+		UpProb:       matchFloat(upProbRe, report),
+		DownProb:     matchFloat(downProbRe, report),
+		Trend:        directionText(textDirection(conclusion.Trend, bullishWords, bearishWords)),
+		Advice:       directionText(textDirection(conclusion.Recommendation, buyWords, sellWords)),
+	}
+}
+
+// predictionFmt 数值列保留两位小数，0 表示报告未提及，写为空
+func predictionFmt(v float64) string {
+	if v == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
+
+// values 按 PredictionColumns 顺序输出各列
+func (p PredictionRecord) values() []string {
+	return []string{p.StockCode, p.Date, predictionFmt(p.CurrentPrice), predictionFmt(p.TargetPrice), predictionFmt(p.StopLoss),
+		predictionFmt(p.UpProb), predictionFmt(p.DownProb), p.Trend, p.Advice, p.Model, p.SavedFile}
+}
+
+// AppendPrediction 追加一条预测到 PredictionsFile。文件不存在时写入表头；
+// 已有表头缺少 PredictionColumns 中的列时补齐表头并整体重写，新行按表头列名对齐
+func AppendPrediction(rec PredictionRecord) error {
+	predictionsMu.Lock()
+	defer predictionsMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(PredictionsFile), 0755); err != nil {
+		return err
+	}
+	records, err := readPredictionsCSV()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(records) == 0 {
+		records = [][]string{append([]string(nil), PredictionColumns...)}
+	}
+	header := records[0]
+	index := make(map[string]int, len(header))
+	for i, h := range header {
+		index[strings.TrimSpace(h)] = i
+	}
+	rewrite := len(records) == 1
+	for _, col := range PredictionColumns {
+		if _, ok := index[col]; !ok {
+			index[col] = len(header)
+			header = append(header, col)
+			rewrite = true
+		}
+	}
+	records[0] = header
+
+	row := make([]string, len(header))
+	for i, v := range rec.values() {
+		row[index[PredictionColumns[i]]] = v
+	}
+	if rewrite {
+		return WriteCSVAtomic(PredictionsFile, append(records, row))
+	}
+	f, err := os.OpenFile(PredictionsFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := csv.NewWriter(f)
+	if err := w.Write(row); err != nil {
+		return err
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// readPredictionsCSV 读取 PredictionsFile 全部行，允许各行列数不同（补全实际价格前的旧行较短）
+func readPredictionsCSV() ([][]string, error) {
+	f, err := os.Open(PredictionsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %v", PredictionsFile, err)
+	}
+	return records, nil
+}
+
+// LoadPredictions 按列名读取全部预测记录，缺失或无法解析的数值按 0 处理
+func LoadPredictions() ([]PredictionRecord, error) {
+	records, err := readPredictionsCSV()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	index := make(map[string]int, len(records[0]))
+	for i, h := range records[0] {
+		index[strings.TrimSpace(h)] = i
+	}
+	get := func(row []string, col string) string {
+		if i, ok := index[col]; ok && i < len(row) {
+			return row[i]
+		}
+		return ""
+	}
+	num := func(row []string, col string) float64 {
+		v, _ := strconv.ParseFloat(get(row, col), 64)
+		return v
+	}
+	list := make([]PredictionRecord, 0, len(records)-1)
+	for _, row := range records[1:] {
+		list = append(list, PredictionRecord{
+			StockCode:    get(row, "股票代码"),
+			Date:         get(row, "预测日期"),
+			CurrentPrice: num(row, "当前价"),
+			TargetPrice:  num(row, "目标价"),
+			StopLoss:     num(row, "止损价"),
+			UpProb:       num(row, "上涨概率"),
+			DownProb:     num(row, "下跌概率"),
+			Trend:        get(row, "趋势"),
+			Advice:       get(row, "建议"),
+			Model:        get(row, "模型"),
+			SavedFile:    get(row, "报告文件"),
+		})
+	}
+	return list, nil
+}
+
+// WriteCSVAtomic 先写入 path.tmp，Flush 成功后再 rename 覆盖原文件，写入中途中断时原文件保持完好
+func WriteCSVAtomic(path string, records [][]string) error {
+	tmpPath := path + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(f)
+	if err := writer.WriteAll(records); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAppendAndLoadPredictions(t *testing.T) {
+	old := PredictionsFile
+	PredictionsFile = filepath.Join(t.TempDir(), "history", "predictions.csv")
+	defer func() { PredictionsFile = old }()
+
+	report := "目标价 42.5 元，止损价：36.80，上涨概率 65%，下跌概率 35%"
+	first := ExtractPrediction("600036", "2024-06-28", report, StructuredConclusion{CurrentPrice: 38.2, TargetPrice: 42.5, Trend: "看涨", Recommendation: "买入"})
+	first.Model, first.SavedFile = "deepseek-chat", "600036.md"
+	if first.StopLoss != 36.8 || first.UpProb != 65 || first.DownProb != 35 || first.Trend != "偏多" || first.Advice != "偏多" {
+		t.Fatalf("解析预测 = %+v", first)
+	}
+	second := PredictionRecord{StockCode: "AAPL", Date: "2024-06-28", TargetPrice: 230}
+	for _, rec := range []PredictionRecord{first, second} {
+		if err := AppendPrediction(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := LoadPredictions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(list, []PredictionRecord{first, second}) {
+		t.Errorf("读回 = %+v\n期望 %+v", list, []PredictionRecord{first, second})
+	}
+	data, _ := os.ReadFile(PredictionsFile)
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 3 || lines[0] != strings.Join(PredictionColumns, ",") {
+		t.Errorf("文件应为表头加 2 行:\n%s", data)
+	}
+}
+
+func TestAppendPredictionUpgradesOldHeader(t *testing.T) {
+	old := PredictionsFile
+	PredictionsFile = filepath.Join(t.TempDir(), "predictions.csv")
+	defer func() { PredictionsFile = old }()
+
+	// 旧文件只有部分列，且带有实际价格补全列
+	if err := os.WriteFile(PredictionsFile, []byte("股票代码,预测日期,目标价,T+1实际收盘价\n000001,2024-01-02,11.00,10.80\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendPrediction(PredictionRecord{StockCode: "600036", Date: "2024-06-28", TargetPrice: 42.5, Model: "m"}); err != nil {
+		t.Fatal(err)
+	}
+	records, err := readPredictionsCSV()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := records[0]
+	if header[3] != "T+1实际收盘价" || len(header) != len(PredictionColumns)+1 {
+		t.Errorf("表头应保留原列并补齐缺失列: %v", header)
+	}
+	if records[1][3] != "10.80" {
+		t.Errorf("旧行的实际价格应保留: %v", records[1])
+	}
+	list, _ := LoadPredictions()
+	if len(list) != 2 || list[0].TargetPrice != 11 || list[1].TargetPrice != 42.5 || list[1].Model != "m" {
+		t.Errorf("按列名读回 = %+v", list)
+	}
+}
+
+func TestWriteCSVAtomicKeepsOriginalOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "predictions.csv")
+	original := "股票代码,日期\n600036,2024-06-28\n"
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	records := [][]string{{"股票代码", "日期", "实际价格"}, {"600036", "2024-06-28", "36.5"}}
+
+	// 写到一半磁盘写满：临时文件指向 /dev/full，Flush 时报错
+	if _, err := os.Stat("/dev/full"); err == nil {
+		if err := os.Symlink("/dev/full", path+".tmp"); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteCSVAtomic(path, records); err == nil {
+			t.Error("写入失败时应返回错误")
+		}
+		if data, _ := os.ReadFile(path); string(data) != original {
+			t.Errorf("写入失败后原文件被改动: %q", data)
+		}
+		if _, err := os.Lstat(path + ".tmp"); !os.IsNotExist(err) {
+			t.Error("写入失败后应清理临时文件")
+		}
+	}
+
+	// 上次进程在写临时文件时被中断，残留半截临时文件：原文件完好，下次写入覆盖残留
+	if err := os.WriteFile(path+".tmp", []byte("股票代码,日\n6000"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != original {
+		t.Fatalf("中断后原文件被改动: %q", data)
+	}
+	if err := WriteCSVAtomic(path, records); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "股票代码,日期,实际价格\n600036,2024-06-28,36.5\n" {
+		t.Errorf("替换后的内容 = %q", data)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Error("替换后不应残留临时文件")
+	}
+}
@@ -0,0 +1,152 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProfileDir 用户自定义预设的存放目录，每个预设一个 <name>.json 文件，可直接拷贝分享
+var ProfileDir = "profiles"
+
+// Profile 命名的分析参数预设
+type Profile struct {
+	Name            string          `json:"name"`
+	Description     string          `json:"description"`
+	Periods         []string        `json:"periods,omitempty"`
+	Dims            []string        `json:"dims,omitempty"`
+	Risk            string          `json:"risk,omitempty"`
+	Detail          string          `json:"detail,omitempty"` // normal/detailed/extreme
+	Confidence      bool            `json:"confidence"`
+	PredictionTypes []string        `json:"prediction_types,omitempty"`
+	Backtest        *BacktestParams `json:"backtest,omitempty"`
+}
+
+// 内置预设：日内、波段、价投
+var builtinProfiles = map[string]Profile{
+	"intraday": {
+		Name:            "intraday",
+		Description:     "日内/超短线：关注盘面、量能与关键价位",
+		Periods:         []string{"1天", "3天", "短期(1-7天)"},
+		Dims:            []string{"技术面", "K线形态", "成交量分析", "技术指标", "支撑阻力", "主力资金"},
+		Risk:            "激进",
+		Detail:          "normal",
+		Confidence:      true,
+		PredictionTypes: []string{"价格预测", "波动率预测", "涨跌概率预测"},
+		Backtest: &BacktestParams{
+			StrategyType: "ma_cross", FastMAPeriod: 3, SlowMAPeriod: 10,
+			StopLoss: 0.02, TakeProfit: 0.04, InitialCash: 100000,
+		},
+	},
+	"swing": {
+		Name:            "swing",
+		Description:     "波段：兼顾趋势、资金与情绪",
+		Periods:         []string{"1周", "2周", "1月"},
+		Dims:            []string{"技术面", "资金面", "均线系统", "成交量分析", "情绪分析"},
+		Risk:            "平衡型",
+		Detail:          "detailed",
+		Confidence:      true,
+		PredictionTypes: []string{"价格预测", "涨跌概率预测", "风险等级预测"},
+		Backtest: &BacktestParams{
+			StrategyType: "ma_cross", FastMAPeriod: 5, SlowMAPeriod: 20,
+			StopLoss: 0.05, TakeProfit: 0.15, InitialCash: 100000,
+		},
+	},
+	"value": {
+		Name:            "value",
+		Description:     "价值投资：基本面、估值与行业地位",
+		Periods:         []string{"3月", "半年", "1年"},
+		Dims:            []string{"基本面", "财务数据", "盈利能力", "估值分析", "行业地位", "管理层"},
+		Risk:            "稳健",
+		Detail:          "detailed",
+		Confidence:      true,
+		PredictionTypes: []string{"价格预测", "基本面指标预测", "风险等级预测"},
+		Backtest: &BacktestParams{
+			StrategyType: "ma_cross", FastMAPeriod: 20, SlowMAPeriod: 60,
+			StopLoss: 0.15, TakeProfit: 0.50, InitialCash: 100000,
+		},
+	},
+}
+
+// LoadProfile 按名称加载预设，优先读取 ProfileDir 下的用户预设，其次为内置预设
+func LoadProfile(name string) (Profile, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Profile{}, fmt.Errorf("预设名称不能为空")
+	}
+	path := filepath.Join(ProfileDir, name+".json")
+	if data, err := ioutil.ReadFile(path); err == nil {
+		var p Profile
+		if err := json.Unmarshal(data, &p); err != nil {
+			return Profile{}, fmt.Errorf("解析预设 %s 失败: %v", path, err)
+		}
+		if p.Name == "" {
+			p.Name = name
+		}
+		return p, nil
+	}
+	if p, ok := builtinProfiles[name]; ok {
+		return p, nil
+	}
+	return Profile{}, fmt.Errorf("未找到预设: %s", name)
+}
+
+// SaveProfile 保存预设到 ProfileDir/<name>.json
+func SaveProfile(p Profile) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("预设名称不能为空")
+	}
+	if err := os.MkdirAll(ProfileDir, 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(ProfileDir, p.Name+".json"), data, 0644)
+}
+
+// ListProfiles 列出所有可用预设名称（内置+用户）
+func ListProfiles() []string {
+	seen := make(map[string]bool)
+	for name := range builtinProfiles {
+		seen[name] = true
+	}
+	files, _ := ioutil.ReadDir(ProfileDir)
+	for _, f := range files {
+		if !f.IsDir() && filepath.Ext(f.Name()) == ".json" {
+			seen[strings.TrimSuffix(f.Name(), ".json")] = true
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Apply 将预设套用到分析参数上
+func (p Profile) Apply(params *AnalysisParams) {
+	if len(p.Periods) > 0 {
+		params.Periods = append([]string(nil), p.Periods...)
+	}
+	if len(p.Dims) > 0 {
+		params.Dims = append([]string(nil), p.Dims...)
+	}
+	if p.Risk != "" {
+		params.Risk = p.Risk
+	}
+	params.Confidence = p.Confidence
+	if len(p.PredictionTypes) > 0 {
+		params.PredictionTypes = append([]string(nil), p.PredictionTypes...)
+	}
+	if p.Backtest != nil {
+		bt := *p.Backtest
+		params.BacktestParams = &bt
+	}
+}
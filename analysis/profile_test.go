@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func useProfileDir(t *testing.T) {
+	t.Helper()
+	old := ProfileDir
+	ProfileDir = t.TempDir()
+	t.Cleanup(func() { ProfileDir = old })
+}
+
+func TestLoadBuiltinProfileApply(t *testing.T) {
+	useProfileDir(t)
+	p, err := LoadProfile("swing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params := AnalysisParams{Periods: []string{"1天"}, Risk: "稳健"}
+	p.Apply(&params)
+	if !reflect.DeepEqual(params.Periods, []string{"1周", "2周", "1月"}) || params.Risk != "平衡型" || !params.Confidence {
+		t.Errorf("套用 swing 后参数错误: %+v", params)
+	}
+	if !reflect.DeepEqual(params.Dims, builtinProfiles["swing"].Dims) {
+		t.Errorf("维度 = %v", params.Dims)
+	}
+	bt := params.BacktestParams
+	if bt == nil || bt.FastMAPeriod != 5 || bt.SlowMAPeriod != 20 || bt.StopLoss != 0.05 {
+		t.Fatalf("回测参数 = %+v", bt)
+	}
+	// 修改套用后的参数不应影响内置预设
+	bt.FastMAPeriod = 99
+	params.Periods[0] = "x"
+	if q := builtinProfiles["swing"]; q.Backtest.FastMAPeriod != 5 || q.Periods[0] != "1周" {
+		t.Errorf("内置预设被修改: %+v", q)
+	}
+	if _, err := LoadProfile("nope"); err == nil {
+		t.Error("未知预设应返回错误")
+	}
+}
+
+func TestSaveProfileOverridesBuiltin(t *testing.T) {
+	useProfileDir(t)
+	custom := Profile{Name: "swing", Description: "自定义波段", Periods: []string{"2周"}, Risk: "激进", Confidence: false}
+	if err := SaveProfile(custom); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveProfile(Profile{Name: "mine", Dims: []string{"资金面"}}); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadProfile("swing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, custom) {
+		t.Errorf("用户预设应优先于内置预设: %+v", got)
+	}
+	params := AnalysisParams{Confidence: true, Dims: []string{"技术面"}}
+	got.Apply(&params)
+	if params.Risk != "激进" || params.Confidence || !reflect.DeepEqual(params.Dims, []string{"技术面"}) || params.BacktestParams != nil {
+		t.Errorf("未设置的字段不应覆盖原参数: %+v", params)
+	}
+	if names := ListProfiles(); !reflect.DeepEqual(names, []string{"intraday", "mine", "swing", "value"}) {
+		t.Errorf("预设列表 = %v", names)
+	}
+	if err := SaveProfile(Profile{}); err == nil {
+		t.Error("空名称应返回错误")
+	}
+}
@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// QualityThreshold 报告质量总分低于该值时自动重试并标注
+var QualityThreshold = 60.0
+
+// ReportQuality 报告质量评分（各项 0-100）
+type ReportQuality struct {
+	Completeness float64  // 完整性：所选维度与必备结论小节的覆盖率
+	Consistency  float64  // 一致性：趋势/建议/目标价是否自相矛盾
+	DataSupport  float64  // 数据支撑度：具体数值与表格的数量
+	Total        float64  // 加权总分
+	Missing      []string // 未覆盖的维度/小节
+	Issues       []string // 一致性问题
+}
+
+var (
+	dataPointRe = regexp.MustCompile(`[0-9]+(\.[0-9]+)?\s*(%|元|倍|亿|万)?`)
+	tableSepRe  = regexp.MustCompile(`(?m)^\s*\|?\s*:?-{3,}:?\s*(\|\s*:?-{3,}:?\s*)+\|?\s*$`)
+)
+
+// ScoreReportQuality 从完整性、一致性、数据支撑度三个维度为报告打分，维度与必备小节按 params.Lang 的写法识别
+func ScoreReportQuality(report string, params AnalysisParams) ReportQuality {
+	var q ReportQuality
+
+	// 完整性：所选维度 + 必备小节，不区分大小写
+	items := localizeList(params.Dims, params.Lang)
+	items = append(append([]string{}, items...), keywordsFor(params.Lang).sections...)
+	lower := strings.ToLower(report)
+	covered := 0
+	for _, item := range items {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			covered++
+			continue
+		}
+		if strings.Contains(lower, strings.ToLower(item)) {
+			covered++
+		} else {
+			q.Missing = append(q.Missing, item)
+		}
+	}
+	q.Completeness = float64(covered) / float64(len(items)) * 100
+
+	// 一致性：每个冲突扣 40 分
+	q.Issues = CheckReportConsistency(ExtractConclusion(report, 0))
+	q.Consistency = 100 - 40*float64(len(q.Issues))
+	if q.Consistency < 0 {
+		q.Consistency = 0
+	}
+
+	// 数据支撑度：数值个数（20 个封顶）占 70 分，表格占 30 分
+	points := len(dataPointRe.FindAllString(report, -1))
+	if points > 20 {
+		points = 20
+	}
+	q.DataSupport = float64(points) / 20 * 70
+	if tableSepRe.MatchString(report) {
+		q.DataSupport += 30
+	}
+
+	q.Total = 0.4*q.Completeness + 0.3*q.Consistency + 0.3*q.DataSupport
+	return q
+}
+
+// Notice 总分低于阈值时返回写入报告的提示（按 lang 输出），否则为空
+func (q ReportQuality) Notice(lang string) string {
+	if q.Total >= QualityThreshold {
+		return ""
+	}
+	w := wrapperFor(lang)
+	msg := fmt.Sprintf(w.qualityNote, q.Total, q.Completeness, q.Consistency, q.DataSupport)
+	if len(q.Missing) > 0 {
+		msg += w.qualityMissing + strings.Join(q.Missing, templateFor(lang).listSep)
+	}
+	return msg + "\n"
+}
@@ -0,0 +1,61 @@
+package analysis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const goodQualityReport = `## 主要结论
+趋势判断：看涨，均线多头排列
+操作建议：逢低买入
+目标价：15.80 元
+
+## 技术面
+收盘 14.20 元，MA5 13.90，MA20 13.10，RSI 62.5，MACD 0.35，量比 1.8 倍
+近 5 日涨幅 6.5%，成交额 12.3 亿，振幅 4.1%，MA60 12.40
+
+| 指标 | 数值 |
+| --- | --- |
+| 市盈率 | 12.5 倍 |
+| 换手率 | 3.2% |
+
+## 资金面
+主力净流入 2.3 亿，北向资金增持 0.8%
+
+## 风险提示
+跌破 13.00 元止损，回撤风险 8%`
+
+func TestScoreReportQualityGoodReport(t *testing.T) {
+	q := ScoreReportQuality(goodQualityReport, AnalysisParams{Dims: []string{"技术面", "资金面"}})
+	if q.Completeness != 100 || len(q.Missing) != 0 {
+		t.Errorf("完整性 = %v，缺失 %v", q.Completeness, q.Missing)
+	}
+	if q.Consistency != 100 || q.DataSupport != 100 {
+		t.Errorf("一致性 = %v，数据支撑度 = %v，期望均为 100", q.Consistency, q.DataSupport)
+	}
+	if q.Total != 100 || q.Notice("") != "" {
+		t.Errorf("高质量报告总分 = %v，不应标注: %q", q.Total, q.Notice(""))
+	}
+}
+
+func TestScoreReportQualityPoorReport(t *testing.T) {
+	report := "## 主要结论\n趋势判断：看涨\n操作建议：卖出\n整体看好，仅供参考。"
+	q := ScoreReportQuality(report, AnalysisParams{Dims: []string{"技术面", "资金面"}})
+	if !reflect.DeepEqual(q.Missing, []string{"技术面", "资金面", "风险提示"}) || q.Completeness != 40 {
+		t.Errorf("完整性 = %v，缺失 %v", q.Completeness, q.Missing)
+	}
+	if len(q.Issues) != 1 || q.Consistency != 60 {
+		t.Errorf("一致性 = %v，问题 %v", q.Consistency, q.Issues)
+	}
+	if q.DataSupport != 0 {
+		t.Errorf("无数值与表格时数据支撑度 = %v", q.DataSupport)
+	}
+	if q.Total >= QualityThreshold {
+		t.Fatalf("低质量报告总分 %v 不应达到阈值 %v", q.Total, QualityThreshold)
+	}
+	notice := q.Notice("")
+	if !strings.Contains(notice, "报告质量评分 34") || !strings.Contains(notice, "未覆盖：技术面、资金面") {
+		t.Errorf("低分标注 = %q", notice)
+	}
+}
@@ -0,0 +1,199 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// QuotePushInterval WebSocket 行情推送的默认间隔
+var QuotePushInterval = 5 * time.Second
+
+// 客户端可指定的推送间隔范围
+const (
+	minQuotePushInterval = time.Second
+	maxQuotePushInterval = 10 * time.Minute
+)
+
+// quoteWriteTimeout 单帧写超时，客户端不读时避免写协程阻塞
+const quoteWriteTimeout = 10 * time.Second
+
+// latestQuote 获取股票最新一根K线，默认取行情数据源最后一条
+var latestQuote = func(ctx context.Context, stockCode string) (StockData, error) {
+	data, _, err := fetchFromSources(ctx, stockCode)
+	if err != nil {
+		return StockData{}, err
+	}
+	if len(data) == 0 {
+		return StockData{}, fmt.Errorf("无行情数据")
+	}
+	latest := data[0]
+	for _, d := range data[1:] {
+		if d.Date.After(latest.Date) {
+			latest = d
+		}
+	}
+	return latest, nil
+}
+
+// QuoteFrame 推送给客户端的一帧行情，获取失败时 Error 非空
+type QuoteFrame struct {
+	StockCode string    `json:"stock_code"`
+	Date      string    `json:"date,omitempty"`
+	Open      float64   `json:"open,omitempty"`
+	Close     float64   `json:"close,omitempty"`
+	High      float64   `json:"high,omitempty"`
+	Low       float64   `json:"low,omitempty"`
+	Volume    float64   `json:"volume,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Time      time.Time `json:"time"`
+}
+
+// parseQuoteInterval 解析推送间隔，支持 Go 时长（10s、1m）或秒数，超出范围时报错
+func parseQuoteInterval(raw string) (time.Duration, error) {
+	raw = strings.TrimSpace(raw)
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		sec, convErr := strconv.ParseFloat(raw, 64)
+		if convErr != nil {
+			return 0, fmt.Errorf("推送间隔格式非法: %s", raw)
+		}
+		d = time.Duration(sec * float64(time.Second))
+	}
+	if d < minQuotePushInterval || d > maxQuotePushInterval {
+		return 0, fmt.Errorf("推送间隔须在 %s 到 %s 之间", minQuotePushInterval, maxQuotePushInterval)
+	}
+	return d, nil
+}
+
+// QuoteWSAllowedOrigins 允许跨域连接行情推送的页面来源（如 https://dash.example.com），
+// 为空时只接受与服务同主机的页面；不带 Origin 头的非浏览器客户端不受限制
+var QuoteWSAllowedOrigins []string
+
+// checkQuoteOrigin 浏览器发起的连接须与服务同主机或在 QuoteWSAllowedOrigins 中，防止其他站点借用户浏览器订阅行情
+func checkQuoteOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	for _, allowed := range QuoteWSAllowedOrigins {
+		if strings.EqualFold(strings.TrimRight(strings.TrimSpace(allowed), "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
+var quoteUpgrader = websocket.Upgrader{CheckOrigin: checkQuoteOrigin}
+
+// handleQuoteWS GET /ws/quotes/{code}?interval=5s：按间隔推送最新行情，代码须符合 requestCodeRe，来源须通过 checkQuoteOrigin。
+// 客户端可随时发送 {"interval":"10s"} 调整间隔；客户端关闭、读写出错或请求上下文取消时退出，不残留协程
+func (s *TaskServer) handleQuoteWS(w http.ResponseWriter, r *http.Request) {
+	code := strings.TrimSpace(r.PathValue("code"))
+	if !requestCodeRe.MatchString(code) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("stock_code 不合法: %q", code))
+		return
+	}
+	code = NormalizeStockCode(code)
+	interval := QuotePushInterval
+	if raw := r.URL.Query().Get("interval"); raw != "" {
+		d, err := parseQuoteInterval(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		interval = d
+	}
+	conn, err := quoteUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade 已向客户端写出错误响应
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	// gorilla 连接同一时刻只允许一个写者，读协程回写错误帧时与推送循环串行
+	var writeMu sync.Mutex
+	write := func(frame QuoteFrame) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		conn.SetWriteDeadline(time.Now().Add(quoteWriteTimeout))
+		return conn.WriteJSON(frame)
+	}
+	intervals := make(chan time.Duration, 1)
+	readDone := make(chan struct{})
+
+	// 读协程：处理 close/ping 控制帧与间隔调整消息，读出错（含客户端关闭）即取消写循环
+	go func() {
+		defer close(readDone)
+		defer cancel()
+		conn.SetReadLimit(1024)
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var req struct {
+				Interval string `json:"interval"`
+			}
+			if json.Unmarshal(msg, &req) != nil || req.Interval == "" {
+				continue
+			}
+			d, err := parseQuoteInterval(req.Interval)
+			if err != nil {
+				write(QuoteFrame{StockCode: code, Error: err.Error(), Time: time.Now()})
+				continue
+			}
+			select {
+			case <-intervals:
+			default:
+			}
+			intervals <- d
+		}
+	}()
+
+	push := func() error {
+		frame := QuoteFrame{StockCode: code, Time: time.Now()}
+		if q, err := latestQuote(ctx, code); err != nil {
+			frame.Error = err.Error()
+		} else {
+			frame.Date = q.Date.Format("2006-01-02")
+			frame.Open, frame.Close, frame.High, frame.Low, frame.Volume = q.Open, q.Close, q.High, q.Low, q.Volume
+		}
+		return write(frame)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	err = push()
+	for err == nil {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+		case d := <-intervals:
+			ticker.Reset(d)
+		case <-ticker.C:
+			err = push()
+		}
+	}
+
+	// 服务端主动结束时发送关闭帧，读协程收到客户端回应或连接关闭后退出
+	conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+	conn.Close()
+	<-readDone
+}
@@ -0,0 +1,121 @@
+package analysis
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// stubLatestQuote 以递增收盘价模拟行情，记录请求的股票代码
+func stubLatestQuote(t *testing.T) chan string {
+	t.Helper()
+	codes := make(chan string, 16)
+	old := latestQuote
+	price := 10.0
+	latestQuote = func(ctx context.Context, code string) (StockData, error) {
+		select {
+		case codes <- code:
+		default:
+		}
+		price++
+		return StockData{Date: time.Date(2024, 6, 3, 0, 0, 0, 0, time.Local), Close: price}, nil
+	}
+	t.Cleanup(func() { latestQuote = old })
+	return codes
+}
+
+func quoteWSURL(srv *httptest.Server, path string) string {
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + path
+}
+
+func TestQuoteWSPushesFramesWithoutLeak(t *testing.T) {
+	codes := stubLatestQuote(t)
+	srv := httptest.NewServer(NewTaskServer("", "deepseek-chat").Handler())
+	defer srv.Close()
+	before := runtime.NumGoroutine()
+
+	conn, _, err := websocket.DefaultDialer.Dial(quoteWSURL(srv, "/ws/quotes/600036.SH?interval=1s"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var last float64
+	for i := 0; i < 2; i++ {
+		var frame QuoteFrame
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := conn.ReadJSON(&frame); err != nil {
+			t.Fatalf("读取第 %d 帧失败: %v", i+1, err)
+		}
+		if frame.Error != "" || frame.StockCode != "600036" || frame.Close <= last {
+			t.Errorf("第 %d 帧 = %+v", i+1, frame)
+		}
+		last = frame.Close
+	}
+	if code := <-codes; code != "600036" {
+		t.Errorf("行情查询代码 = %q，应去掉 .SH 后缀", code)
+	}
+	conn.Close()
+
+	// 客户端断开后服务端读写协程应全部退出
+	deadline := time.Now().Add(3 * time.Second)
+	for runtime.NumGoroutine() > before && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > before {
+		t.Errorf("断开后协程数 %d，连接前 %d，存在泄漏", n, before)
+	}
+}
+
+func TestQuoteWSRejectsForeignOrigin(t *testing.T) {
+	stubLatestQuote(t)
+	srv := httptest.NewServer(NewTaskServer("", "deepseek-chat").Handler())
+	defer srv.Close()
+	old := QuoteWSAllowedOrigins
+	QuoteWSAllowedOrigins = []string{"https://dash.example.com/"}
+	defer func() { QuoteWSAllowedOrigins = old }()
+
+	for origin, ok := range map[string]bool{
+		"":                         true,
+		srv.URL:                    true,
+		"https://dash.example.com": true,
+		"https://evil.example.com": false,
+		"null":                     false,
+	} {
+		header := http.Header{}
+		if origin != "" {
+			header.Set("Origin", origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(quoteWSURL(srv, "/ws/quotes/600036"), header)
+		if ok {
+			if err != nil {
+				t.Errorf("Origin %q 应允许连接: %v", origin, err)
+				continue
+			}
+			conn.Close()
+			continue
+		}
+		if err == nil {
+			conn.Close()
+			t.Errorf("Origin %q 应被拒绝", origin)
+		} else if resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Errorf("Origin %q 应返回 403: %v", origin, err)
+		}
+	}
+}
+
+func TestQuoteWSRejectsInvalidCode(t *testing.T) {
+	stubLatestQuote(t)
+	h := NewTaskServer("", "deepseek-chat").Handler()
+	for _, code := range []string{".hidden", "600036%2F..%2Fx", "abcdefghijklmn"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/ws/quotes/"+code, nil))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("代码 %q 状态码 = %d，期望 400", code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TencentQuoteURL 腾讯实时行情接口，%s 为 sh600519/sz000001/hk00700/usAAPL
+var TencentQuoteURL = "https://qt.gtimg.cn/q=%s"
+
+// RealtimeDeviationThreshold 实时价相对本地最新收盘的偏离阈值，超过时在报告头部标注“行情异动”
+var RealtimeDeviationThreshold = 0.05
+
+// tencentQuoteTimeLayouts 实时行情时间字段格式：A股 20240105150003，港股 2024/01/05 16:08:05，美股 2024-01-05 16:00:02
+var tencentQuoteTimeLayouts = []string{"20060102150405", "2006/01/02 15:04:05", "2006-01-02 15:04:05"}
+
+// tencentQuoteSymbol 实时接口的代码格式，美股加 us 前缀
+func tencentQuoteSymbol(stockCode string) string {
+	if StockMarket(stockCode) == MarketUS {
+		return "us" + strings.ToUpper(stockCode)
+	}
+	return normalizeSymbol(stockCode, sourceTencent)
+}
+
+// parseTencentQuote 解析 v_sh600519="1~贵州茅台~600519~1688.00~...~20240105150003~..." 格式，取第 3 项现价与第 30 项时间
+func parseTencentQuote(body []byte) (float64, time.Time, error) {
+	s := string(body)
+	start := strings.Index(s, `="`)
+	if start < 0 {
+		return 0, time.Time{}, fmt.Errorf("实时行情格式错误")
+	}
+	s = s[start+2:]
+	if end := strings.Index(s, `"`); end >= 0 {
+		s = s[:end]
+	}
+	fields := strings.Split(s, "~")
+	if len(fields) < 31 {
+		return 0, time.Time{}, fmt.Errorf("实时行情无数据（代码不存在或字段不足）")
+	}
+	price, err := strconv.ParseFloat(fields[3], 64)
+	if err != nil || price <= 0 {
+		return 0, time.Time{}, fmt.Errorf("实时价格无效: %s", fields[3])
+	}
+	for _, layout := range tencentQuoteTimeLayouts {
+		if t, err := time.ParseInLocation(layout, fields[30], time.Local); err == nil {
+			return price, t, nil
+		}
+	}
+	return 0, time.Time{}, fmt.Errorf("无法识别的行情时间: %s", fields[30])
+}
+
+// FetchRealtimeQuote 从腾讯实时接口获取最新价与行情时间
+func FetchRealtimeQuote(ctx context.Context, stockCode string) (float64, time.Time, error) {
+	stockCode = NormalizeStockCode(stockCode)
+	if !DataSourceAllowed(sourceTencent) {
+		return 0, time.Time{}, fmt.Errorf("合规限制：%s 不在允许的数据源列表中", sourceTencent)
+	}
+	if StockMarket(stockCode) == MarketCrypto {
+		return 0, time.Time{}, fmt.Errorf("实时行情暂不支持加密货币: %s", stockCode)
+	}
+	body, err := fetchWithRetry(ctx, fmt.Sprintf(TencentQuoteURL, tencentQuoteSymbol(stockCode)), defaultFetchHeaders, FetchMaxRetries)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return parseTencentQuote(body)
+}
+
+// FormatRealtimeNotice 实时价与本地最新收盘的对比说明，偏离超过 RealtimeDeviationThreshold 时标注“行情异动”
+func FormatRealtimeNotice(price float64, at time.Time, last StockData, lang string) string {
+	if last.Close <= 0 {
+		return ""
+	}
+	w := wrapperFor(lang)
+	change := (price - last.Close) / last.Close
+	notice := fmt.Sprintf(w.realtime, price, at.Format("2006-01-02 15:04"), last.Close, last.Date.Format("2006-01-02"), change*100)
+	if math.Abs(change) > RealtimeDeviationThreshold {
+		notice += fmt.Sprintf(w.realtimeAlert, FormatPercent(RealtimeDeviationThreshold))
+	}
+	return "\n" + notice + "\n"
+}
+
+// realtimeNotice 获取实时价并生成对比说明；csv 离线模式或获取失败时返回空
+func (p AnalysisParams) realtimeNotice(ctx context.Context, stockData []StockData) string {
+	if p.DataSource == DataSourceCSV || len(stockData) == 0 {
+		return ""
+	}
+	price, at, err := FetchRealtimeQuote(ctx, p.StockCodes[0])
+	if err != nil {
+		log.Printf("[实时行情] %s 获取失败，跳过对比: %v\n", p.StockCodes[0], err)
+		return ""
+	}
+	return FormatRealtimeNotice(price, at, stockData[len(stockData)-1], p.Lang)
+}
@@ -0,0 +1,91 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tencentQuoteLine 构造腾讯实时接口返回行，第 3 项为现价、第 30 项为行情时间，其余字段填占位
+func tencentQuoteLine(symbol, price, at string) string {
+	fields := make([]string, 50)
+	for i := range fields {
+		fields[i] = "0"
+	}
+	fields[0], fields[1], fields[2], fields[3], fields[30] = "1", "测试", strings.TrimLeft(symbol, "shzkus"), price, at
+	return fmt.Sprintf("v_%s=\"%s\";\n", symbol, strings.Join(fields, "~"))
+}
+
+func TestParseTencentQuote(t *testing.T) {
+	for _, c := range []struct {
+		body  string
+		price float64
+		at    time.Time
+	}{
+		{tencentQuoteLine("sh600519", "1688.00", "20240105150003"), 1688, time.Date(2024, 1, 5, 15, 0, 3, 0, time.Local)},
+		{tencentQuoteLine("hk00700", "290.40", "2024/01/05 16:08:05"), 290.4, time.Date(2024, 1, 5, 16, 8, 5, 0, time.Local)},
+		{tencentQuoteLine("usAAPL", "181.18", "2024-01-05 16:00:02"), 181.18, time.Date(2024, 1, 5, 16, 0, 2, 0, time.Local)},
+	} {
+		price, at, err := parseTencentQuote([]byte(c.body))
+		if err != nil || price != c.price || !at.Equal(c.at) {
+			t.Errorf("%.20s…: %v %v %v，期望 %v %v", c.body, price, at, err, c.price, c.at)
+		}
+	}
+	for _, c := range []struct{ body, want string }{
+		{"<html>", "格式错误"},
+		{`v_pv_none_match="1";`, "无数据"},
+		{tencentQuoteLine("sh600519", "0.00", "20240105150003"), "价格无效"},
+		{tencentQuoteLine("sh600519", "1688.00", "昨日收盘"), "无法识别的行情时间"},
+	} {
+		if _, _, err := parseTencentQuote([]byte(c.body)); err == nil || !strings.Contains(err.Error(), c.want) {
+			t.Errorf("%.20s…: 应返回含 %q 的错误，得到 %v", c.body, c.want, err)
+		}
+	}
+}
+
+func TestFetchRealtimeQuote(t *testing.T) {
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		symbol := strings.TrimPrefix(r.URL.Path, "/q=")
+		paths = append(paths, symbol)
+		w.Write([]byte(tencentQuoteLine(symbol, "10.50", "20240105150003")))
+	}))
+	defer srv.Close()
+	oldURL, oldRetries := TencentQuoteURL, FetchMaxRetries
+	TencentQuoteURL, FetchMaxRetries = srv.URL+"/q=%s", 0
+	defer func() { TencentQuoteURL, FetchMaxRetries = oldURL, oldRetries }()
+
+	for _, code := range []string{"600519", "aapl"} {
+		if price, _, err := FetchRealtimeQuote(context.Background(), code); err != nil || price != 10.5 {
+			t.Errorf("%s: %v %v", code, price, err)
+		}
+	}
+	if len(paths) != 2 || paths[0] != "sh600519" || paths[1] != "usAAPL" {
+		t.Errorf("实时接口代码格式 = %v", paths)
+	}
+	if _, _, err := FetchRealtimeQuote(context.Background(), "BTC-USD"); err == nil || len(paths) != 2 {
+		t.Errorf("加密货币不应请求实时接口: %v", err)
+	}
+}
+
+func TestFormatRealtimeNotice(t *testing.T) {
+	last := StockData{Date: time.Date(2024, 1, 4, 0, 0, 0, 0, time.Local), Close: 10}
+	at := time.Date(2024, 1, 5, 10, 30, 0, 0, time.Local)
+	notice := FormatRealtimeNotice(10.3, at, last, "")
+	if !strings.Contains(notice, "最新价 10.30（2024-01-05 10:30") || !strings.Contains(notice, "本地最新收盘 10.00（2024-01-04）") || !strings.Contains(notice, "差异 +3.00%") {
+		t.Errorf("实时行情对比说明:\n%s", notice)
+	}
+	if strings.Contains(notice, "行情异动") {
+		t.Errorf("偏离未超过阈值时不应标注行情异动:\n%s", notice)
+	}
+	if notice := FormatRealtimeNotice(9.2, at, last, ""); !strings.Contains(notice, "差异 -8.00%") || !strings.Contains(notice, "行情异动") {
+		t.Errorf("偏离超过阈值应标注行情异动:\n%s", notice)
+	}
+	if FormatRealtimeNotice(10, at, StockData{}, "") != "" {
+		t.Error("无本地收盘价时不应输出对比")
+	}
+}
@@ -0,0 +1,173 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// RecommendSignal 参与综合建议的单一信号
+type RecommendSignal struct {
+	Source     string  // 信号来源：LLM结论/本地趋势/资金流向/机器学习
+	Direction  float64 // -1（看空）~ 1（看多）
+	Confidence float64 // 0 ~ 1，作为加权权重
+	Accuracy   float64 // 本地信号与机器学习滚动验证的次日方向命中率，0 表示未验证（样本不足或 LLM 结论）
+}
+
+// AggregatedRecommendation 置信度加权后的统一建议
+type AggregatedRecommendation struct {
+	Score     float64 // 加权方向得分，-1 ~ 1
+	Action    string  // 买入/增持/持有/减持/卖出
+	Position  int     // 建议仓位上限（%）
+	RiskLevel string
+	Conflict  bool // 信号是否存在明显分歧
+	Signals   []RecommendSignal
+}
+
+// 信号方向超过该值才视为有明确观点，用于分歧判断
+const signalDirectionThreshold = 0.2
+
+var confidenceRe = regexp.MustCompile(keywordPattern(func(k reportKeywords) []string { return k.confidence }) + `[^0-9\n]{0,10}([0-9]+(?:\.[0-9]+)?)\s*%`)
+
+// riskPositionFactor 风险等级对仓位上限的折扣
+var riskPositionFactor = map[string]float64{
+	"低风险":  1.0,
+	"中低风险": 0.85,
+	"中风险":  0.7,
+	"高风险":  0.5,
+	"极高风险": 0.3,
+}
+
+// reportConfidence 报告中各处“置信度 xx%”的均值，未给出时返回 0.5
+func reportConfidence(report string) float64 {
+	matches := confidenceRe.FindAllStringSubmatch(report, -1)
+	total, n := 0.0, 0
+	for _, m := range matches {
+		if v, err := strconv.ParseFloat(m[1], 64); err == nil && v <= 100 {
+			total += v / 100
+			n++
+		}
+	}
+	if n == 0 {
+		return 0.5
+	}
+	return total / float64(n)
+}
+
+// scoreSignal 0-100 评分转为方向信号，偏离 50 越远置信度越高（0.3 ~ 0.9）
+func scoreSignal(source string, score float64) RecommendSignal {
+	dir := (score - 50) / 50
+	return RecommendSignal{Source: source, Direction: dir, Confidence: 0.3 + 0.6*math.Abs(dir)}
+}
+
+// mlTrendDirection 机器学习趋势对应的方向，震荡为中性
+var mlTrendDirection = map[string]float64{"上涨": 1, "下跌": -1, "震荡": 0}
+
+// CollectRecommendSignals 汇总 LLM 结论、本地技术趋势、资金流向与机器学习集成预测信号，缺失的来源不参与
+func CollectRecommendSignals(report string, conclusion StructuredConclusion, stockData []StockData, indicators []TechnicalIndicator, mf *MoneyFlow, ml []MLPrediction) []RecommendSignal {
+	var signals []RecommendSignal
+	dir := textDirection(conclusion.Trend, bullishWords, bearishWords)
+	if dir == 0 {
+		dir = textDirection(conclusion.Recommendation, buyWords, sellWords)
+	}
+	if dir != 0 {
+		signals = append(signals, RecommendSignal{Source: "LLM结论", Direction: float64(dir), Confidence: reportConfidence(report)})
+	}
+	if v, ok := localTechnicalScore(stockData, indicators); ok {
+		signals = append(signals, validatedSignal(scoreSignal("本地趋势", v), stockData, indicators))
+	}
+	if mf != nil {
+		if v, ok := localMoneyFlowScore(*mf); ok {
+			signals = append(signals, validatedSignal(scoreSignal("资金流向", v), stockData, indicators))
+		}
+	}
+	for _, p := range ml {
+		if dir, ok := mlTrendDirection[p.Trend]; ok && p.Method == "集成" {
+			s := RecommendSignal{Source: "机器学习", Direction: dir, Confidence: p.Confidence}
+			if acc := NewMLPredictor(stockData, indicators).EvaluateAccuracy(p.Method, SignalAccuracyWindow); acc > 0 {
+				s.Accuracy = acc
+				s.Confidence = acc
+			}
+			signals = append(signals, s)
+		}
+	}
+	return signals
+}
+
+// validatedSignal 本地信号有足够历史样本时，以最近 SignalAccuracyWindow 日的实际命中率作为置信度
+func validatedSignal(s RecommendSignal, stockData []StockData, indicators []TechnicalIndicator) RecommendSignal {
+	if acc := EvaluateSignalAccuracy(stockData, indicators, s.Source, SignalAccuracyWindow); acc > 0 {
+		s.Accuracy = acc
+		s.Confidence = acc
+	}
+	return s
+}
+
+// AggregateRecommendation 按置信度加权合成方向得分并映射为操作建议；
+// 仓位上限 = 看多程度 × 风险等级折扣，信号多空分歧时仓位再减半
+func AggregateRecommendation(signals []RecommendSignal, riskLevel string) AggregatedRecommendation {
+	agg := AggregatedRecommendation{RiskLevel: riskLevel, Signals: signals}
+	weighted, weights := 0.0, 0.0
+	hasUp, hasDown := false, false
+	for _, s := range signals {
+		weighted += s.Direction * s.Confidence
+		weights += s.Confidence
+		if s.Direction > signalDirectionThreshold {
+			hasUp = true
+		} else if s.Direction < -signalDirectionThreshold {
+			hasDown = true
+		}
+	}
+	if weights > 0 {
+		agg.Score = weighted / weights
+	}
+	agg.Conflict = hasUp && hasDown
+
+	switch {
+	case agg.Score >= 0.5:
+		agg.Action = "买入"
+	case agg.Score >= 0.2:
+		agg.Action = "增持"
+	case agg.Score > -0.2:
+		agg.Action = "持有"
+	case agg.Score > -0.5:
+		agg.Action = "减持"
+	default:
+		agg.Action = "卖出"
+	}
+
+	factor, ok := riskPositionFactor[riskLevel]
+	if !ok {
+		factor = 0.5 // 风险数据不足时保守处理
+	}
+	position := 30 + 70*agg.Score // 中性 30%，满分看多 100%，看空趋向 0
+	position = math.Max(0, position) * factor
+	if agg.Conflict {
+		position /= 2
+	}
+	agg.Position = int(math.Round(position/10) * 10)
+	return agg
+}
+
+// FormatAggregatedRecommendation 置于报告开头的综合建议，lang 为 en 时使用英文
+func FormatAggregatedRecommendation(agg AggregatedRecommendation, lang string) string {
+	if len(agg.Signals) == 0 {
+		return ""
+	}
+	w := wrapperFor(lang)
+	var parts []string
+	for _, s := range agg.Signals {
+		if s.Accuracy > 0 {
+			parts = append(parts, fmt.Sprintf(w.recommendHit, localize(s.Source, lang), s.Direction, SignalAccuracyWindow, FormatPercent(s.Accuracy)))
+			continue
+		}
+		parts = append(parts, fmt.Sprintf(w.recommendSignal, localize(s.Source, lang), s.Direction, FormatPercent(s.Confidence)))
+	}
+	msg := fmt.Sprintf(w.recommend, localize(agg.Action, lang), agg.Position, agg.Score, localize(agg.RiskLevel, lang), strings.Join(parts, w.itemSep))
+	if agg.Conflict {
+		msg += w.recommendSplit
+	}
+	return msg
+}
@@ -0,0 +1,82 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAggregateRecommendationConflictingSignals(t *testing.T) {
+	signals := []RecommendSignal{
+		{Source: "LLM结论", Direction: 1, Confidence: 0.8},
+		{Source: "本地趋势", Direction: -0.6, Confidence: 0.6},
+		{Source: "机器学习", Direction: -1, Confidence: 0.5},
+	}
+	agg := AggregateRecommendation(signals, "中风险")
+	// 加权得分 (0.8 - 0.36 - 0.5) / 1.9 ≈ -0.03，落在持有区间
+	if agg.Action != "持有" || !agg.Conflict {
+		t.Errorf("多空分歧应给出持有并标记分歧: %+v", agg)
+	}
+	// 仓位 (30 + 70×-0.03) × 0.7 / 2 ≈ 9.7，取整到 10%
+	if agg.Position != 10 {
+		t.Errorf("分歧时仓位 = %d%%，期望 10%%", agg.Position)
+	}
+
+	agree := AggregateRecommendation([]RecommendSignal{
+		{Source: "LLM结论", Direction: 1, Confidence: 0.8},
+		{Source: "机器学习", Direction: 1, Confidence: 0.6},
+	}, "低风险")
+	if agree.Action != "买入" || agree.Conflict || agree.Position != 100 {
+		t.Errorf("一致看多且低风险应买入满仓: %+v", agree)
+	}
+	if none := AggregateRecommendation(nil, "数据不足"); none.Action != "持有" || none.Position != 20 {
+		t.Errorf("无信号时应中性持有并按数据不足折扣仓位: %+v", none)
+	}
+}
+
+func TestCollectRecommendSignalsIncludesMLEnsemble(t *testing.T) {
+	ml := []MLPrediction{
+		{Method: "决策树", Trend: "上涨", Confidence: 0.9},
+		{Method: "随机森林", Trend: "下跌", Confidence: 0.7},
+		{Method: "集成", Trend: "下跌", Confidence: 0.62},
+	}
+	signals := CollectRecommendSignals("", StructuredConclusion{}, nil, nil, nil, ml)
+	if len(signals) != 1 {
+		t.Fatalf("仅集成预测应参与综合建议，得到 %+v", signals)
+	}
+	if s := signals[0]; s.Source != "机器学习" || s.Direction != -1 || s.Confidence != 0.62 {
+		t.Errorf("机器学习信号 = %+v", s)
+	}
+	// 有足够行情时以滚动验证的命中率作为机器学习信号的置信度
+	data := trendBars(200, 0.01)
+	signals = CollectRecommendSignals("", StructuredConclusion{}, data, calculateTechnicalIndicators(data), nil, ml)
+	if s := signals[len(signals)-1]; s.Source != "机器学习" || s.Accuracy < 0.8 || s.Confidence != s.Accuracy {
+		t.Errorf("机器学习信号应使用滚动命中率: %+v", s)
+	}
+	if signals := CollectRecommendSignals("", StructuredConclusion{}, nil, nil, nil, nil); len(signals) != 0 {
+		t.Errorf("无预测时不应有机器学习信号: %+v", signals)
+	}
+}
+
+func TestFormatAggregatedRecommendationEnglish(t *testing.T) {
+	agg := AggregateRecommendation([]RecommendSignal{
+		{Source: "LLM结论", Direction: 1, Confidence: 0.8},
+		{Source: "本地趋势", Direction: -0.5, Confidence: 0.6, Accuracy: 0.6},
+		{Source: "机器学习", Direction: -1, Confidence: 0.5},
+	}, "高风险")
+	zh := FormatAggregatedRecommendation(agg, "")
+	if !strings.Contains(zh, "【综合建议】") || !strings.Contains(zh, "多空分歧") {
+		t.Errorf("中文综合建议:\n%s", zh)
+	}
+	en := FormatAggregatedRecommendation(agg, LangEN)
+	if containsHan(en) {
+		t.Errorf("英文综合建议含中文:\n%s", en)
+	}
+	for _, want := range []string{"Overall Recommendation", "High risk", "Machine learning", "hit rate"} {
+		if !strings.Contains(en, want) {
+			t.Errorf("英文综合建议缺少 %q:\n%s", want, en)
+		}
+	}
+	if FormatAggregatedRecommendation(AggregatedRecommendation{}, LangEN) != "" {
+		t.Error("无信号时应返回空")
+	}
+}
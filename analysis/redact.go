@@ -0,0 +1,137 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// RedactExport 导出、推送前是否按脱敏规则屏蔽敏感信息
+var RedactExport = false
+
+// RedactRulesFile 自定义脱敏规则，格式 [{"name": "客户名", "pattern": "张三|李四", "replace": "**"}]，与内置规则合并
+var RedactRulesFile = "redact.json"
+
+// RedactRule 一条脱敏规则：pattern 为正则，命中内容替换为 replace（可用 $1 引用分组，默认 ***），remove 为 true 时移除命中所在行
+type RedactRule struct {
+	Name    string `json:"name"`
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace,omitempty"`
+	Remove  bool   `json:"remove,omitempty"`
+	re      *regexp.Regexp
+}
+
+// builtinRedactRules 内置规则：持仓/账户类字段的取值、邮箱、手机号、银行卡/证券账号、API Key
+var builtinRedactRules = []RedactRule{
+	{Name: "持仓与账户", Pattern: `(持仓金额|持仓市值|持仓数量|持股数量|持仓成本|账户余额|账户资产|总资产|可用资金|资金账号|证券账号|股东账号)(\s*\**\s*[:：|]\s*\**\s*)[^\s，,。；;|*]+`, Replace: "$1$2***"},
+	{Name: "邮箱", Pattern: `[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`, Replace: "***@***"},
+	{Name: "手机号", Pattern: `(^|[^\d])1[3-9]\d{9}([^\d]|$)`, Replace: "$1***********$2"},
+	{Name: "银行卡/账号", Pattern: `(^|[^\d])\d{16,19}([^\d]|$)`, Replace: "$1***$2"},
+	{Name: "API Key", Pattern: `sk-[A-Za-z0-9]{16,}`, Replace: "sk-***"},
+}
+
+// compileRedactRules 编译规则，正则非法时报错
+func compileRedactRules(rules []RedactRule) ([]RedactRule, error) {
+	compiled := make([]RedactRule, 0, len(rules))
+	for _, r := range rules {
+		if strings.TrimSpace(r.Pattern) == "" {
+			continue
+		}
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("脱敏规则 %s 正则非法: %v", r.Name, err)
+		}
+		if r.Replace == "" {
+			r.Replace = "***"
+		}
+		r.re = re
+		compiled = append(compiled, r)
+	}
+	return compiled, nil
+}
+
+// LoadRedactRules 内置规则加上 path 中的自定义规则，文件不存在时只用内置规则
+func LoadRedactRules(path string) ([]RedactRule, error) {
+	rules := append([]RedactRule(nil), builtinRedactRules...)
+	data, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		var custom []RedactRule
+		if err := json.Unmarshal(data, &custom); err != nil {
+			return nil, fmt.Errorf("解析脱敏规则 %s 失败: %v", path, err)
+		}
+		rules = append(rules, custom...)
+	}
+	return compileRedactRules(rules)
+}
+
+// RedactReport 按规则脱敏，先移除 remove 规则命中的整行，再做替换；返回脱敏后文本与命中次数
+func RedactReport(report string, rules []RedactRule) (string, int) {
+	hits := 0
+	var removers, replacers []RedactRule
+	for _, r := range rules {
+		if r.re == nil {
+			continue
+		}
+		if r.Remove {
+			removers = append(removers, r)
+		} else {
+			replacers = append(replacers, r)
+		}
+	}
+	if len(removers) > 0 {
+		lines := strings.Split(report, "\n")
+		kept := lines[:0]
+		for _, l := range lines {
+			removed := false
+			for _, r := range removers {
+				if r.re.MatchString(l) {
+					removed = true
+					break
+				}
+			}
+			if removed {
+				hits++
+				continue
+			}
+			kept = append(kept, l)
+		}
+		report = strings.Join(kept, "\n")
+	}
+	for _, r := range replacers {
+		n := len(r.re.FindAllStringIndex(report, -1))
+		if n == 0 {
+			continue
+		}
+		hits += n
+		report = r.re.ReplaceAllString(report, r.Replace)
+	}
+	return report, hits
+}
+
+// redactForExport 开启 RedactExport 时按规则脱敏，规则加载失败时返回错误，避免未脱敏内容被导出
+func redactForExport(stockCode string, texts ...*string) error {
+	if !RedactExport {
+		return nil
+	}
+	rules, err := LoadRedactRules(RedactRulesFile)
+	if err != nil {
+		return err
+	}
+	total := 0
+	for _, t := range texts {
+		var n int
+		*t, n = RedactReport(*t, rules)
+		total += n
+	}
+	if total > 0 {
+		log.Printf("[脱敏] %s 报告已屏蔽 %d 处敏感信息\n", stockCode, total)
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package analysis
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const sensitiveReport = `## 持仓概况
+持仓金额：1,250,000 元，**账户余额**：320000
+资金账号 | 8800123456
+联系人邮箱 trader.wang@example.com，手机 13812345678
+银行卡 6222021234567890123 已绑定
+调试用 Key：sk-abcdefghijklmnop1234
+客户：张三
+内部备注：仅限投研组查看
+## 操作建议
+逢低买入，目标价 40.5 元`
+
+var sensitiveValues = []string{"1,250,000", "320000", "8800123456", "trader.wang@example.com", "13812345678", "6222021234567890123", "sk-abcdefghijklmnop1234", "张三", "仅限投研组"}
+
+// useRedactRules 开启脱敏并写入自定义规则文件
+func useRedactRules(t *testing.T, rules string) {
+	t.Helper()
+	oldOn, oldFile := RedactExport, RedactRulesFile
+	RedactExport, RedactRulesFile = true, filepath.Join(t.TempDir(), "redact.json")
+	t.Cleanup(func() { RedactExport, RedactRulesFile = oldOn, oldFile })
+	if rules != "" {
+		if err := os.WriteFile(RedactRulesFile, []byte(rules), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRedactReportBuiltinAndCustomRules(t *testing.T) {
+	useRedactRules(t, `[{"name":"客户名","pattern":"张三|李四","replace":"**"},{"name":"内部备注","pattern":"^内部备注","remove":true}]`)
+	rules, err := LoadRedactRules(RedactRulesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, hits := RedactReport(sensitiveReport, rules)
+	for _, v := range sensitiveValues {
+		if strings.Contains(got, v) {
+			t.Errorf("脱敏后仍含敏感值 %q:\n%s", v, got)
+		}
+	}
+	for _, want := range []string{"持仓金额：***", "**账户余额**：***", "资金账号 | ***", "***@***", "sk-***", "客户：**", "目标价 40.5 元"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("脱敏结果缺少 %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "内部备注") || hits != 9 {
+		t.Errorf("remove 规则应移除整行，命中 %d 处:\n%s", hits, got)
+	}
+}
+
+func TestLoadRedactRulesErrors(t *testing.T) {
+	useRedactRules(t, "")
+	if rules, err := LoadRedactRules(RedactRulesFile); err != nil || len(rules) != len(builtinRedactRules) {
+		t.Errorf("规则文件不存在时应只用内置规则: %d 条 %v", len(rules), err)
+	}
+	os.WriteFile(RedactRulesFile, []byte(`[{"name":"坏规则","pattern":"(未闭合"}]`), 0644)
+	if _, err := LoadRedactRules(RedactRulesFile); err == nil || !strings.Contains(err.Error(), "坏规则") {
+		t.Errorf("正则非法时应报错: %v", err)
+	}
+	os.WriteFile(RedactRulesFile, []byte(`{不是 JSON`), 0644)
+	text := sensitiveReport
+	if err := redactForExport("600036", &text); err == nil || text != sensitiveReport {
+		t.Errorf("规则解析失败时应返回错误且不改动原文: %v", err)
+	}
+	RedactExport = false
+	if err := redactForExport("600036", &text); err != nil || text != sensitiveReport {
+		t.Error("未开启脱敏时应原样导出")
+	}
+}
+
+func TestAnalyzeOneExportsRedactedFiles(t *testing.T) {
+	useRedactRules(t, `[{"name":"客户名","pattern":"张三"},{"name":"内部备注","pattern":"^内部备注","remove":true}]`)
+	wd, _ := os.Getwd()
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	oldTransport, oldRetries := FetchTransport, FetchMaxRetries
+	FetchTransport, FetchMaxRetries = failingTransport{}, 0
+	defer func() {
+		FetchTransport, FetchMaxRetries = oldTransport, oldRetries
+		os.Chdir(wd)
+	}()
+
+	params := AnalysisParams{StockCodes: []string{"600036"}, Model: "deepseek-chat", Output: []string{"md", "html"},
+		DataSource: DataSourceCSV, CSVDir: filepath.Join(dir, "csv")}
+	os.MkdirAll(params.CSVDir, 0755)
+	writeTestCSV(t, filepath.Join(params.CSVDir, "600036.csv"), 150)
+	result := AnalyzeOne(context.Background(), params, func(stock, p, apiKey, apiURL, model string, searchMode, hybridSearch bool) (string, error) {
+		return sensitiveReport, nil
+	})
+	if result.Err != nil || result.SavedFile == "" {
+		t.Fatalf("分析失败: %v", result.Err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "history", "600036-*"))
+	if len(files) < 2 {
+		t.Fatalf("应导出 md 与 html 文件，找到 %v", files)
+	}
+	for _, path := range files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range sensitiveValues {
+			if strings.Contains(string(data), v) || strings.Contains(result.Report, v) {
+				t.Errorf("%s 含原始敏感值 %q", filepath.Base(path), v)
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package analysis
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FetchTransport 数据源请求使用的 transport，nil 表示 http.DefaultTransport；
+// 可替换为 NewRecordingTransport（落盘原始响应）或 NewReplayTransport（用本地样本回放）
+var FetchTransport http.RoundTripper
+
+// responseSample 落盘的原始响应样本
+type responseSample struct {
+	URL         string `json:"url"`
+	StatusCode  int    `json:"status_code"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        string `json:"body"`
+}
+
+// sampleIgnoredParams 随请求时间变化的查询参数（如 Yahoo 的 period1/period2），计算样本文件名时忽略，保证回放可复现
+var sampleIgnoredParams = []string{"period1", "period2", "_"}
+
+// samplePath 样本文件名：host-URL哈希.json，同一 URL（忽略时间类参数）总是对应同一文件
+func samplePath(dir string, req *http.Request) string {
+	u := *req.URL
+	q := u.Query()
+	for _, p := range sampleIgnoredParams {
+		q.Del(p)
+	}
+	u.RawQuery = q.Encode()
+	sum := sha1.Sum([]byte(u.String()))
+	host := strings.NewReplacer(":", "_", "/", "_").Replace(req.URL.Host)
+	return filepath.Join(dir, fmt.Sprintf("%s-%s.json", host, hex.EncodeToString(sum[:])[:12]))
+}
+
+type recordingTransport struct {
+	dir  string
+	base http.RoundTripper
+}
+
+// NewRecordingTransport 透传请求并把每个响应写入 dir，base 为 nil 时使用 http.DefaultTransport
+func NewRecordingTransport(dir string, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &recordingTransport{dir: dir, base: base}
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	sample := responseSample{URL: req.URL.String(), StatusCode: resp.StatusCode, ContentType: resp.Header.Get("Content-Type"), Body: string(body)}
+	if data, err := json.MarshalIndent(sample, "", "  "); err == nil && os.MkdirAll(t.dir, 0755) == nil {
+		if err := ioutil.WriteFile(samplePath(t.dir, req), data, 0644); err != nil {
+			log.Printf("[样本] 写入 %s 样本失败: %v\n", req.URL.Host, err)
+		}
+	}
+	return resp, nil
+}
+
+type replayTransport struct {
+	dir string
+}
+
+// NewReplayTransport 从 dir 读取样本构造响应，不发出真实请求；样本不存在时返回错误
+func NewReplayTransport(dir string) http.RoundTripper {
+	return &replayTransport{dir: dir}
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	path := samplePath(t.dir, req)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("回放样本不存在（%s）: %s", req.URL.String(), path)
+	}
+	var sample responseSample
+	if err := json.Unmarshal(data, &sample); err != nil {
+		return nil, fmt.Errorf("解析回放样本 %s 失败: %v", path, err)
+	}
+	header := make(http.Header)
+	if sample.ContentType != "" {
+		header.Set("Content-Type", sample.ContentType)
+	}
+	return &http.Response{
+		StatusCode:    sample.StatusCode,
+		Status:        fmt.Sprintf("%d %s", sample.StatusCode, http.StatusText(sample.StatusCode)),
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(sample.Body)),
+		ContentLength: int64(len(sample.Body)),
+		Request:       req,
+	}, nil
+}
@@ -0,0 +1,50 @@
+package analysis
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRecordThenReplayYahooChart(t *testing.T) {
+	body, err := os.ReadFile("testdata/yahoo_chart.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	oldTransport, oldRetries := FetchTransport, FetchMaxRetries
+	FetchMaxRetries = 0
+	defer func() { FetchTransport, FetchMaxRetries = oldTransport, oldRetries }()
+
+	// 录制：透传到上游并落盘原始响应
+	upstream := &yahooTransport{body: body}
+	FetchTransport = NewRecordingTransport(dir, upstream)
+	recorded, err := fetchFromYahoo(context.Background(), "AAPL")
+	if err != nil || len(recorded) != 4 {
+		t.Fatalf("录制: %d 根, err=%v", len(recorded), err)
+	}
+	samples, _ := filepath.Glob(filepath.Join(dir, "query1.finance.yahoo.com-*.json"))
+	if len(samples) != 1 {
+		t.Fatalf("应落盘 1 个样本，得到 %v", samples)
+	}
+
+	// 回放：不发出真实请求，period1/period2 随时间变化也能命中同一样本
+	FetchTransport = NewReplayTransport(dir)
+	replayed, err := fetchFromYahoo(context.Background(), "AAPL")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("回放解析结果与录制时不一致:\n%+v\n%+v", replayed, recorded)
+	}
+	if len(upstream.urls) != 1 {
+		t.Errorf("回放不应请求上游，上游共收到 %d 次请求", len(upstream.urls))
+	}
+
+	if _, err := fetchFromYahoo(context.Background(), "MSFT"); err == nil || !strings.Contains(err.Error(), "回放样本不存在") {
+		t.Errorf("无样本时应返回错误: %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package analysis
+
+import (
+	"strings"
+	"time"
+)
+
+// PeriodPrediction 报告“多周期预测”表格中的一行
+type PeriodPrediction struct {
+	Period     string `json:"period"`
+	Trend      string `json:"trend,omitempty"`
+	KeyLevels  string `json:"key_levels,omitempty"`
+	Confidence string `json:"confidence,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// ReportJSON 机读格式的分析结果，--export json 时写入 history
+type ReportJSON struct {
+	StockCode      string             `json:"stock_code"`
+	Start          string             `json:"start"`
+	End            string             `json:"end"`
+	Model          string             `json:"model,omitempty"`
+	DataSource     string             `json:"data_source,omitempty"`
+	Risk           *RiskMetrics       `json:"risk,omitempty"`     // 无本地行情时为空
+	Backtest       *BacktestResult    `json:"backtest,omitempty"` // 无本地行情时为空
+	Periods        []PeriodPrediction `json:"periods,omitempty"`
+	Trend          string             `json:"trend,omitempty"`
+	Recommendation string             `json:"recommendation,omitempty"`
+	TargetPrice    float64            `json:"target_price,omitempty"`
+	CurrentPrice   float64            `json:"current_price,omitempty"`
+	Scores         map[string]float64 `json:"scores,omitempty"`
+	Report         string             `json:"report"` // 模型返回的原始报告文本
+	GeneratedAt    time.Time          `json:"generated_at"`
+}
+
+// periodColumns 多周期预测表头关键词到字段的映射，按 BuildPrompt 的格式要求匹配
+var periodColumns = []struct {
+	keyword string
+	set     func(*PeriodPrediction, string)
+}{
+	{"周期", func(p *PeriodPrediction, v string) { p.Period = v }},
+	{"趋势", func(p *PeriodPrediction, v string) { p.Trend = v }},
+	{"价位", func(p *PeriodPrediction, v string) { p.KeyLevels = v }},
+	{"置信度", func(p *PeriodPrediction, v string) { p.Confidence = v }},
+	{"理由", func(p *PeriodPrediction, v string) { p.Reason = v }},
+	{"驱动", func(p *PeriodPrediction, v string) { p.Reason = v }},
+}
+
+// ParsePeriodPredictions 解析报告中表头含“周期”与“趋势”的 markdown 表格，取第一张匹配的表
+func ParsePeriodPredictions(report string) []PeriodPrediction {
+	for _, t := range ParseMarkdownTables(report) {
+		if t.Column("周期") < 0 || t.Column("趋势") < 0 {
+			continue
+		}
+		cols := make([]func(*PeriodPrediction, string), len(t.Header))
+		for i, h := range t.Header {
+			for _, pc := range periodColumns {
+				if strings.Contains(h, pc.keyword) {
+					cols[i] = pc.set
+					break
+				}
+			}
+		}
+		var list []PeriodPrediction
+		for _, row := range t.Rows {
+			var p PeriodPrediction
+			for i, c := range row {
+				if cols[i] != nil {
+					cols[i](&p, c)
+				}
+			}
+			if p.Period != "" {
+				list = append(list, p)
+			}
+		}
+		if len(list) > 0 {
+			return list
+		}
+	}
+	return nil
+}
+
+// BuildReportJSON 汇总一次分析的结构化结果；stockData 为空时不带风险与回测指标
+func BuildReportJSON(params AnalysisParams, report string, stockData []StockData, dataInfo DataSourceInfo, bt BacktestResult, conclusion StructuredConclusion, scores map[string]float64, now time.Time) ReportJSON {
+	r := ReportJSON{
+		StockCode:      params.StockCodes[0],
+		Start:          params.Start,
+		End:            params.End,
+		Model:          params.Model,
+		DataSource:     dataInfo.Source,
+		Periods:        ParsePeriodPredictions(report),
+		Trend:          conclusion.Trend,
+		Recommendation: conclusion.Recommendation,
+		TargetPrice:    conclusion.TargetPrice,
+		CurrentPrice:   conclusion.CurrentPrice,
+		Scores:         scores,
+		Report:         report,
+		GeneratedAt:    now,
+	}
+	if len(stockData) > 0 {
+		risk := CalculateRiskMetrics(stockData, RiskFreeRate)
+		r.Risk = &risk
+		r.Backtest = &bt
+	}
+	return r
+}
@@ -0,0 +1,119 @@
+package analysis
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const periodReport = `## 主要结论
+趋势判断：看涨，多头排列
+操作建议：逢低买入
+目标价：45.00 元
+基本面评分：7.5/10
+
+| 指标 | 数值 |
+|---|---|
+| 市盈率 | 8.2 |
+
+## 多周期预测
+| 周期 | 趋势 | 关键价位 | 置信度 | 驱动因素 |
+|---|---|---|---|---|
+| 短期 | 震荡偏强 | 支撑 38 / 压力 42 | 中 | 放量突破 |
+| 中期 | 看涨 | 目标 45 | 高 | 估值修复 |`
+
+func TestParsePeriodPredictions(t *testing.T) {
+	got := ParsePeriodPredictions(periodReport)
+	want := []PeriodPrediction{
+		{Period: "短期", Trend: "震荡偏强", KeyLevels: "支撑 38 / 压力 42", Confidence: "中", Reason: "放量突破"},
+		{Period: "中期", Trend: "看涨", KeyLevels: "目标 45", Confidence: "高", Reason: "估值修复"},
+	}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+		t.Errorf("多周期预测 = %+v，期望 %+v", got, want)
+	}
+	if got := ParsePeriodPredictions("| 指标 | 数值 |\n|---|---|\n| 市盈率 | 8.2 |"); got != nil {
+		t.Errorf("无周期表时应返回空: %+v", got)
+	}
+}
+
+func TestBuildReportJSONRoundTrip(t *testing.T) {
+	params := AnalysisParams{StockCodes: []string{"600036"}, Start: "2024-01-01", End: "2024-06-30", Model: "deepseek-chat"}
+	data := datedBars(80)
+	bt := BacktestResult{TotalReturn: 0.12, Trades: 3, EquityCurve: []float64{100000, 112000}}
+	conclusion := ExtractConclusion(periodReport, 40)
+	now := time.Date(2024, 7, 1, 15, 0, 0, 0, time.UTC)
+	raw, err := json.MarshalIndent(BuildReportJSON(params, periodReport, data, DataSourceInfo{Source: "CSV"}, bt, conclusion, ExtractDimensionScores(periodReport), now), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got ReportJSON
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("导出的 JSON 无法还原: %v", err)
+	}
+	if got.StockCode != "600036" || got.Start != "2024-01-01" || got.End != "2024-06-30" || got.Model != "deepseek-chat" || got.DataSource != "CSV" {
+		t.Errorf("基本字段 = %+v", got)
+	}
+	if got.Risk == nil || got.Risk.MaxDrawdown != CalculateRiskMetrics(data, RiskFreeRate).MaxDrawdown {
+		t.Errorf("风险指标未还原: %+v", got.Risk)
+	}
+	if got.Backtest == nil || got.Backtest.TotalReturn != 0.12 || got.Backtest.Trades != 3 || len(got.Backtest.EquityCurve) != 2 {
+		t.Errorf("回测结果未还原: %+v", got.Backtest)
+	}
+	if len(got.Periods) != 2 || got.Periods[1].Trend != "看涨" || got.Scores["基本面"] != 75 {
+		t.Errorf("多周期预测或评分未还原: %+v %v", got.Periods, got.Scores)
+	}
+	if !strings.Contains(got.Trend, "看涨") || !strings.Contains(got.Recommendation, "买入") || got.TargetPrice != 45 || got.CurrentPrice != 40 {
+		t.Errorf("结构化结论未还原: %+v", got)
+	}
+	if got.Report != periodReport || !got.GeneratedAt.Equal(now) {
+		t.Errorf("原始报告或生成时间未还原: %v", got.GeneratedAt)
+	}
+
+	raw, _ = json.Marshal(BuildReportJSON(params, "无表格", nil, DataSourceInfo{}, bt, StructuredConclusion{}, nil, now))
+	if strings.Contains(string(raw), `"risk"`) || strings.Contains(string(raw), `"backtest"`) || strings.Contains(string(raw), `"periods"`) {
+		t.Errorf("无本地行情时不应带风险与回测指标: %s", raw)
+	}
+}
+
+func TestAnalyzeOneExportsJSON(t *testing.T) {
+	wd, _ := os.Getwd()
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	oldTransport, oldRetries := FetchTransport, FetchMaxRetries
+	FetchTransport, FetchMaxRetries = failingTransport{}, 0
+	defer func() {
+		FetchTransport, FetchMaxRetries = oldTransport, oldRetries
+		os.Chdir(wd)
+	}()
+
+	params := AnalysisParams{StockCodes: []string{"600036"}, Start: "2024-01-01", End: "2024-06-30", Model: "deepseek-chat",
+		Output: []string{"json"}, DataSource: DataSourceCSV, CSVDir: filepath.Join(dir, "csv")}
+	os.MkdirAll(params.CSVDir, 0755)
+	writeTestCSV(t, filepath.Join(params.CSVDir, "600036.csv"), 150)
+	result := AnalyzeOne(context.Background(), params, func(stock, p, apiKey, apiURL, model string, searchMode, hybridSearch bool) (string, error) {
+		return periodReport, nil
+	})
+	if result.Err != nil || !strings.HasSuffix(result.SavedFile, ".json") {
+		t.Fatalf("分析失败: %q %v", result.SavedFile, result.Err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "history", result.SavedFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got ReportJSON
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("导出的 JSON 无法还原: %v", err)
+	}
+	if got.StockCode != "600036" || got.Start != "2024-01-01" || got.Risk == nil || got.Backtest == nil || len(got.Periods) != 2 {
+		t.Errorf("导出文件缺少关键字段: %s", data)
+	}
+	if got.Scores["基本面"] != 75 || got.TargetPrice != 45 || got.CurrentPrice <= 0 || got.GeneratedAt.IsZero() {
+		t.Errorf("导出文件缺少评分或结论: %s", data)
+	}
+}
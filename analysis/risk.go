@@ -5,33 +5,68 @@ import (
 	"sort"
 )
 
+// minRiskSamples 计算风险指标所需的最少日收益率样本数，不足时风险等级为“数据不足”，VaR 与其他指标口径一致
+const minRiskSamples = 30
+
+// minVaRSamples 历史模拟法 VaR 所需的最少样本数，样本过少时分位数没有意义
+const minVaRSamples = 30
+
+// RiskFreeRate 默认年化无风险利率，用于夏普比率
+var RiskFreeRate = 0.03
+
+// VaR 计算方法
+const (
+	VaRHistorical    = "historical"     // 历史模拟法：经验分位数
+	VaRParametric    = "parametric"     // 参数法：正态假设，均值与标准差
+	VaRCornishFisher = "cornish-fisher" // 修正参数法：按偏度、峰度修正正态分位数
+)
+
+// VaRMethod CalculateRiskMetrics 使用的 VaR 方法，默认历史模拟法
+var VaRMethod = VaRHistorical
+
 // RiskMetrics 风险指标结构
 type RiskMetrics struct {
-	Volatility  float64 // 历史波动率
-	VaR95       float64 // 95%置信度下的风险价值
-	MaxDrawdown float64 // 最大回撤
-	SharpeRatio float64 // 夏普比率
-	Beta        float64 // 贝塔系数
-	RiskLevel   string  // 风险等级
-	RiskScore   float64 // 风险评分（0-100）
-}
+	Volatility   float64 // 历史波动率
+	VaR95        float64 // 95%置信度下的风险价值
+	MaxDrawdown  float64 // 最大回撤
+	SharpeRatio  float64 // 夏普比率
+	Beta         float64 // 贝塔系数
+	RiskLevel    string  // 风险等级
+	RiskScore    float64 // 风险评分（0-100）
+	RiskFreeRate float64 // 计算夏普比率使用的年化无风险利率
 
-// CalculateRiskMetrics 计算风险指标
-func CalculateRiskMetrics(stockData []StockData) RiskMetrics {
-	if len(stockData) < 30 {
-		return RiskMetrics{RiskLevel: "数据不足", RiskScore: 0}
-	}
+	VaR99             float64 // 99%置信度下的风险价值
+	SortinoRatio      float64 // 索提诺比率（仅用下行波动）
+	CalmarRatio       float64 // 卡玛比率（年化收益/最大回撤）
+	Skewness          float64 // 收益率偏度
+	Kurtosis          float64 // 收益率超额峰度
+	DownsideDeviation float64 // 年化下行偏差
+	UpsideCapture     float64 // 上行捕获率（相对基准）
+	DownsideCapture   float64 // 下行捕获率（相对基准）
+	VaRMethod         string  // VaR95/VaR99 的计算方法
+}
 
+// CalculateRiskMetrics 计算风险指标，riskFreeRate 为年化无风险利率；
+// benchmark 为可选的基准日收益序列（与行情尾部对齐），缺省时上下行捕获率为 0
+func CalculateRiskMetrics(stockData []StockData, riskFreeRate float64, benchmark ...float64) RiskMetrics {
 	// 计算日收益率
 	returns := calculateReturns(stockData)
+	if len(returns) < minRiskSamples {
+		return RiskMetrics{RiskLevel: "数据不足", RiskScore: 0, RiskFreeRate: riskFreeRate}
+	}
 
 	// 计算各项指标
 	volatility := calculateVolatility(returns)
-	var95, _ := calculateVaR(returns)
+	var95 := CalculateVaR(returns, 0.95, VaRMethod)
+	// 修正法在极端偏度下分位数可能不单调，保证 VaR99 的损失不小于 VaR95
+	var99 := math.Min(CalculateVaR(returns, 0.99, VaRMethod), var95)
 	maxDrawdown, _ := calculateMaxDrawdown(stockData)
-	sharpeRatio := calculateSharpeRatio(returns)
+	sharpeRatio := calculateSharpeRatio(returns, riskFreeRate)
 	riskScore := calculateRiskScore(volatility, maxDrawdown)
 	riskLevel := determineRiskLevel(riskScore)
+	downside := calculateDownsideDeviation(returns, riskFreeRate)
+	skewness, kurtosis := calculateMoments(returns)
+	upCapture, downCapture := calculateCaptureRatios(returns, benchmark)
 
 	return RiskMetrics{
 		Volatility:  volatility,
@@ -41,6 +76,18 @@ func CalculateRiskMetrics(stockData []StockData) RiskMetrics {
 		Beta:        1.0, // 默认值
 		RiskLevel:   riskLevel,
 		RiskScore:   riskScore,
+
+		RiskFreeRate: riskFreeRate,
+
+		VaR99:             var99,
+		SortinoRatio:      calculateSortinoRatio(returns, riskFreeRate, downside),
+		CalmarRatio:       calculateCalmarRatio(stockData, maxDrawdown),
+		Skewness:          skewness,
+		Kurtosis:          kurtosis,
+		DownsideDeviation: downside,
+		UpsideCapture:     upCapture,
+		DownsideCapture:   downCapture,
+		VaRMethod:         normalizeVaRMethod(VaRMethod),
 	}
 }
 
@@ -75,22 +122,48 @@ func calculateVolatility(returns []float64) float64 {
 	return math.Sqrt(variance) * math.Sqrt(252)
 }
 
-// calculateVaR 计算风险价值
-func calculateVaR(returns []float64) (float95, float99 float64) {
-	if len(returns) == 0 {
-		return 0, 0
+// normalizeVaRMethod 未知或为空的方法按历史模拟法处理
+func normalizeVaRMethod(method string) string {
+	switch method {
+	case VaRParametric, VaRCornishFisher:
+		return method
+	default:
+		return VaRHistorical
 	}
+}
 
-	sortedReturns := make([]float64, len(returns))
-	copy(sortedReturns, returns)
-	sort.Float64s(sortedReturns)
+// CalculateVaR 按 method 计算 confidence（如 0.95）置信度下的日收益 VaR，以分位收益表示（负数表示损失）：
+// historical 取经验分位数；parametric 取正态分位 μ+zσ；cornish-fisher 用偏度、超额峰度修正 z 后再取 μ+zσ。
+// 样本少于 minVaRSamples 时返回 0，未知方法按 historical 计算
+func CalculateVaR(returns []float64, confidence float64, method string) float64 {
+	if len(returns) < minVaRSamples || confidence <= 0 || confidence >= 1 {
+		return 0
+	}
+	tail := 1 - confidence
+	if normalizeVaRMethod(method) == VaRHistorical {
+		sortedReturns := make([]float64, len(returns))
+		copy(sortedReturns, returns)
+		sort.Float64s(sortedReturns)
+		return sortedReturns[int(float64(len(sortedReturns))*tail)]
+	}
 
-	idx95 := int(float64(len(sortedReturns)) * 0.05)
-	if idx95 < len(sortedReturns) {
-		float95 = sortedReturns[idx95]
+	var sum float64
+	for _, r := range returns {
+		sum += r
 	}
+	mean := sum / float64(len(returns))
+	var variance float64
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	sigma := math.Sqrt(variance / float64(len(returns)-1))
 
-	return float95, 0
+	z := math.Sqrt2 * math.Erfinv(2*tail-1) // 标准正态 tail 分位数，95% 时约 -1.645
+	if method == VaRCornishFisher {
+		s, k := calculateMoments(returns)
+		z = z + (z*z-1)*s/6 + (z*z*z-3*z)*k/24 - (2*z*z*z-5*z)*s*s/36
+	}
+	return mean + z*sigma
 }
 
 // calculateMaxDrawdown 计算最大回撤
@@ -120,8 +193,8 @@ func calculateMaxDrawdown(data []StockData) (maxDrawdown float64, duration int)
 	return maxDrawdown, duration
 }
 
-// calculateSharpeRatio 计算夏普比率
-func calculateSharpeRatio(returns []float64) float64 {
+// calculateSharpeRatio 计算年化夏普比率，riskFreeRate 为年化无风险利率
+func calculateSharpeRatio(returns []float64, riskFreeRate float64) float64 {
 	if len(returns) == 0 {
 		return 0
 	}
@@ -142,8 +215,100 @@ func calculateSharpeRatio(returns []float64) float64 {
 		return 0
 	}
 
-	riskFreeRate := 0.03 / 252
-	return (mean - riskFreeRate) / stdDev * math.Sqrt(252)
+	dailyRiskFree := riskFreeRate / 252
+	return (mean - dailyRiskFree) / stdDev * math.Sqrt(252)
+}
+
+// calculateDownsideDeviation 计算年化下行偏差，以日无风险收益为最低可接受收益
+func calculateDownsideDeviation(returns []float64, riskFreeRate float64) float64 {
+	if len(returns) == 0 {
+		return 0
+	}
+	mar := riskFreeRate / 252
+	var sum float64
+	for _, r := range returns {
+		if r < mar {
+			sum += math.Pow(r-mar, 2)
+		}
+	}
+	return math.Sqrt(sum/float64(len(returns))) * math.Sqrt(252)
+}
+
+// calculateSortinoRatio 计算索提诺比率：年化超额收益/年化下行偏差
+func calculateSortinoRatio(returns []float64, riskFreeRate, downsideDeviation float64) float64 {
+	if len(returns) == 0 || downsideDeviation == 0 {
+		return 0
+	}
+	var sum float64
+	for _, r := range returns {
+		sum += r
+	}
+	mean := sum / float64(len(returns))
+	return (mean - riskFreeRate/252) * 252 / downsideDeviation
+}
+
+// calculateCalmarRatio 计算卡玛比率：年化收益/最大回撤
+func calculateCalmarRatio(data []StockData, maxDrawdown float64) float64 {
+	if len(data) < 2 || maxDrawdown == 0 || data[0].Close <= 0 {
+		return 0
+	}
+	total := data[len(data)-1].Close / data[0].Close
+	annualized := math.Pow(total, 252/float64(len(data)-1)) - 1
+	return annualized / maxDrawdown
+}
+
+// calculateMoments 计算收益率偏度与超额峰度
+func calculateMoments(returns []float64) (skewness, kurtosis float64) {
+	n := float64(len(returns))
+	if n < 3 {
+		return 0, 0
+	}
+	var sum float64
+	for _, r := range returns {
+		sum += r
+	}
+	mean := sum / n
+	var m2, m3, m4 float64
+	for _, r := range returns {
+		d := r - mean
+		m2 += d * d
+		m3 += d * d * d
+		m4 += d * d * d * d
+	}
+	m2, m3, m4 = m2/n, m3/n, m4/n
+	if m2 == 0 {
+		return 0, 0
+	}
+	return m3 / math.Pow(m2, 1.5), m4/(m2*m2) - 3
+}
+
+// calculateCaptureRatios 计算相对基准的上下行捕获率：基准上涨（下跌）日个股平均收益/基准平均收益
+func calculateCaptureRatios(returns, benchmark []float64) (upside, downside float64) {
+	n := len(returns)
+	if len(benchmark) < n {
+		n = len(benchmark)
+	}
+	if n == 0 {
+		return 0, 0
+	}
+	returns, benchmark = returns[len(returns)-n:], benchmark[len(benchmark)-n:]
+	var upStock, upBench, downStock, downBench float64
+	for i, b := range benchmark {
+		if b > 0 {
+			upStock += returns[i]
+			upBench += b
+		} else if b < 0 {
+			downStock += returns[i]
+			downBench += b
+		}
+	}
+	if upBench != 0 {
+		upside = upStock / upBench
+	}
+	if downBench != 0 {
+		downside = downStock / downBench
+	}
+	return upside, downside
 }
 
 // calculateRiskScore 计算风险评分
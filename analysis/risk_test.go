@@ -0,0 +1,264 @@
+package analysis
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// risingBars n 根逐日小幅振荡上涨的K线
+func risingBars(n int) []StockData {
+	data := make([]StockData, n)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	price := 10.0
+	for i := range data {
+		if i%3 == 2 {
+			price *= 0.99
+		} else {
+			price *= 1.02
+		}
+		data[i] = StockData{Date: day.AddDate(0, 0, i), Open: price, Close: price, High: price * 1.01, Low: price * 0.99, Volume: 1000}
+	}
+	return data
+}
+
+func TestCalculateRiskMetricsRequires30Returns(t *testing.T) {
+	for _, n := range []int{11, 20, 30} {
+		if r := CalculateRiskMetrics(risingBars(n), 0.03); r.RiskLevel != "数据不足" {
+			t.Errorf("%d 根K线（%d 个收益率）应为数据不足，得到 %q", n, n-1, r.RiskLevel)
+		}
+	}
+	r := CalculateRiskMetrics(risingBars(31), 0.03)
+	if r.RiskLevel == "数据不足" || r.VaR95 == 0 || r.VaR99 == 0 {
+		t.Errorf("30 个收益率时应计算风险等级与 VaR，得到 %+v", r)
+	}
+}
+
+func TestShortSeriesIndicators(t *testing.T) {
+	if ind := calculateTechnicalIndicators(risingBars(4)); ind != nil {
+		t.Fatalf("少于 %d 根K线应返回 nil", minIndicatorBars)
+	}
+	ind := calculateTechnicalIndicators(risingBars(5))
+	if len(ind) != 5 || ind[4].MA5 == 0 || ind[4].MA10 != 0 {
+		t.Fatalf("5 根K线应只有 MA5，得到 %+v", ind[4])
+	}
+	ind = calculateTechnicalIndicators(risingBars(15))
+	last := ind[14]
+	if last.MA10 == 0 || last.RSI12 == 0 || last.MA20 != 0 || last.BOLLMiddle != 0 || last.MACD != 0 {
+		t.Errorf("15 根K线应有 MA10/RSI12，MA20/BOLL/MACD 留零，得到 %+v", last)
+	}
+	if _, ok := localTechnicalScore(risingBars(15), ind); !ok {
+		t.Error("15 根K线应能给出技术面评分")
+	}
+}
+
+func TestClipToDateRangeKeepsWarmupForRisk(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCSV(t, filepath.Join(dir, "600036.csv"), 120)
+
+	data, ind, info, err := FetchStockHistoryFromCSV(dir, "600036", "2024-05-01", "2024-05-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.WarmupBars <= 0 || len(data) != clipWarmupBars {
+		t.Fatalf("一个月区间应补足预热K线至 %d 根，实际 %d 根、预热 %d", clipWarmupBars, len(data), info.WarmupBars)
+	}
+	risk := CalculateRiskMetrics(data, RiskFreeRate)
+	if risk.RiskLevel == "数据不足" || risk.VaR95 == 0 {
+		t.Fatalf("含预热的序列应能计算 VaR，得到 %+v", risk)
+	}
+	shown, shownInd := displayRange(data, ind, info.WarmupBars)
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)
+	if shown[0].Date.Before(from) || len(shown) != len(shownInd) || len(shown) != clipWarmupBars-info.WarmupBars {
+		t.Fatalf("展示区间应从 %s 开始且不含预热，实际首日 %s、%d 根", from.Format("2006-01-02"), shown[0].Date.Format("2006-01-02"), len(shown))
+	}
+
+	// 区间前没有行情时无法预热，原样返回区间内数据
+	data, _, info, err = FetchStockHistoryFromCSV(dir, "600036", "2024-01-01", "2024-01-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.WarmupBars != 0 || data[0].Date.Month() != time.January {
+		t.Fatalf("区间前无行情时不应有预热，实际预热 %d、首日 %s", info.WarmupBars, data[0].Date.Format("2006-01-02"))
+	}
+}
+
+func TestCalculateRiskMetricsUsesRiskFreeRate(t *testing.T) {
+	data := risingBars(60)
+	returns := calculateReturns(data)
+	mean, std := 0.0, 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(len(returns))
+	for _, r := range returns {
+		std += (r - mean) * (r - mean)
+	}
+	std = math.Sqrt(std / float64(len(returns)-1))
+
+	for _, rf := range []float64{0, 0.03, 0.05} {
+		r := CalculateRiskMetrics(data, rf)
+		if want := (mean - rf/252) / std * math.Sqrt(252); math.Abs(r.SharpeRatio-want) > 1e-9 || r.RiskFreeRate != rf {
+			t.Errorf("rf=%v: 夏普比率 = %v，期望 %v（RiskFreeRate=%v）", rf, r.SharpeRatio, want, r.RiskFreeRate)
+		}
+	}
+	if low, high := CalculateRiskMetrics(data, 0.01), CalculateRiskMetrics(data, 0.05); high.SharpeRatio >= low.SharpeRatio {
+		t.Errorf("无风险利率越高夏普比率应越低: %v >= %v", high.SharpeRatio, low.SharpeRatio)
+	}
+}
+
+// barsFromReturns 由日收益序列生成收盘价K线，首日收盘 10
+func barsFromReturns(returns []float64) []StockData {
+	data := make([]StockData, len(returns)+1)
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)
+	price := 10.0
+	for i := range data {
+		if i > 0 {
+			price *= 1 + returns[i-1]
+		}
+		data[i] = StockData{Date: day.AddDate(0, 0, i), Open: price, Close: price, High: price, Low: price, Volume: 1000}
+	}
+	return data
+}
+
+func TestCalculateRiskMetricsExtendedRatios(t *testing.T) {
+	returns := make([]float64, 40)
+	for i := range returns {
+		returns[i] = 0.02
+		if i%2 == 1 {
+			returns[i] = -0.01
+		}
+	}
+	data := barsFromReturns(returns)
+	calc := calculateReturns(data)
+	benchmark := make([]float64, len(calc))
+	for i, r := range calc {
+		benchmark[i] = r / 2
+	}
+	r := CalculateRiskMetrics(data, 0, benchmark...)
+
+	near := func(got, want float64) bool { return math.Abs(got-want) <= 1e-9*math.Max(1, math.Abs(want)) }
+	mean := (0.02 - 0.01) / 2
+	downside := math.Sqrt(0.5*0.01*0.01) * math.Sqrt(252)
+	if !near(r.DownsideDeviation, downside) {
+		t.Errorf("下行偏差 = %v，期望 %v（只计负收益）", r.DownsideDeviation, downside)
+	}
+	if !near(r.SortinoRatio, mean*252/downside) {
+		t.Errorf("索提诺比率 = %v，期望 %v", r.SortinoRatio, mean*252/downside)
+	}
+	if !near(r.MaxDrawdown, 0.01) {
+		t.Errorf("最大回撤 = %v，期望 0.01", r.MaxDrawdown)
+	}
+	annualized := math.Pow(data[40].Close/data[0].Close, 252.0/40) - 1
+	if !near(r.CalmarRatio, annualized/0.01) {
+		t.Errorf("卡玛比率 = %v，期望 %v", r.CalmarRatio, annualized/0.01)
+	}
+	// 两点等概率分布：偏度 0，超额峰度 -2
+	if !near(r.Skewness, 0) || !near(r.Kurtosis, -2) {
+		t.Errorf("偏度 %v、峰度 %v，期望 0、-2", r.Skewness, r.Kurtosis)
+	}
+	if !near(r.UpsideCapture, 2) || !near(r.DownsideCapture, 2) {
+		t.Errorf("捕获率 上行 %v、下行 %v，期望均为 2", r.UpsideCapture, r.DownsideCapture)
+	}
+	if plain := CalculateRiskMetrics(data, 0); plain.UpsideCapture != 0 || plain.DownsideCapture != 0 {
+		t.Errorf("缺省基准时捕获率应为 0: %+v", plain)
+	}
+
+	// 右偏：少数大涨、多数小跌
+	skewed := make([]float64, 40)
+	for i := range skewed {
+		skewed[i] = -0.005
+		if i%10 == 0 {
+			skewed[i] = 0.05
+		}
+	}
+	if s := CalculateRiskMetrics(barsFromReturns(skewed), 0); s.Skewness <= 0 || s.Kurtosis <= 0 {
+		t.Errorf("少数大涨应为正偏、尖峰: 偏度 %v，峰度 %v", s.Skewness, s.Kurtosis)
+	}
+}
+
+func TestCalculateVaRHistorical(t *testing.T) {
+	// 100 个收益率 -0.50, -0.49, ..., 0.49：经验分位取升序第 ⌊n×(1-置信度)⌋ 个（从 0 计），99% 为第 2 差，95% 为第 6 差
+	returns := make([]float64, 100)
+	for i := range returns {
+		returns[(i*37)%100] = float64(i-50) / 100 // 打乱顺序，验证排序
+	}
+	if v := CalculateVaR(returns, 0.99, VaRHistorical); v != -0.49 {
+		t.Errorf("VaR99 = %v，期望 -0.49", v)
+	}
+	if v := CalculateVaR(returns, 0.95, VaRHistorical); v != -0.45 {
+		t.Errorf("VaR95 = %v，期望 -0.45", v)
+	}
+	if v := CalculateVaR(returns[:29], 0.95, VaRHistorical); v != 0 {
+		t.Errorf("少于 30 个样本时应返回 0，得到 %v", v)
+	}
+}
+
+func TestCalculateVaRMethodsOnSkewedReturns(t *testing.T) {
+	// 500 个日收益：正弦波动上每 15 天一次 -4% 急跌，负偏、尖峰
+	returns := make([]float64, 500)
+	for i := range returns {
+		returns[i] = 0.003 + 0.01*math.Sin(float64(i)*1.3)
+		if i%15 == 0 {
+			returns[i] = -0.04
+		}
+	}
+	if s, k := calculateMoments(returns); s >= -1 || k <= 1 {
+		t.Fatalf("样本应明显负偏、尖峰: 偏度 %v，峰度 %v", s, k)
+	}
+	for _, c := range []struct {
+		confidence float64
+		z          float64 // 标准正态 1-confidence 分位
+	}{{0.95, -1.6448536}, {0.99, -2.3263479}} {
+		hist := CalculateVaR(returns, c.confidence, VaRHistorical)
+		param := CalculateVaR(returns, c.confidence, VaRParametric)
+		cf := CalculateVaR(returns, c.confidence, VaRCornishFisher)
+		mean, sigma := meanStd(returns)
+		if math.Abs(param-(mean+c.z*sigma)) > 1e-6 {
+			t.Errorf("%.0f%% 参数法 = %v，期望 μ+zσ = %v", c.confidence*100, param, mean+c.z*sigma)
+		}
+		if math.Abs(cf-hist) >= math.Abs(param-hist) {
+			t.Errorf("%.0f%% 有偏分布下修正法应比参数法更接近历史法: 历史 %v，参数 %v，修正 %v", c.confidence*100, hist, param, cf)
+		}
+	}
+	if CalculateVaR(returns, 0.95, "unknown") != CalculateVaR(returns, 0.95, VaRHistorical) {
+		t.Error("未知方法应按历史法计算")
+	}
+
+	old := VaRMethod
+	defer func() { VaRMethod = old }()
+	VaRMethod = VaRCornishFisher
+	if r := CalculateRiskMetrics(barsFromReturns(returns), 0); r.VaRMethod != VaRCornishFisher || math.Abs(r.VaR95-CalculateVaR(returns, 0.95, VaRCornishFisher)) > 1e-9 {
+		t.Errorf("RiskMetrics 应使用配置的 VaR 方法: %s %v", r.VaRMethod, r.VaR95)
+	}
+}
+
+// meanStd 样本均值与标准差（n-1）
+func meanStd(xs []float64) (mean, std float64) {
+	for _, x := range xs {
+		mean += x
+	}
+	mean /= float64(len(xs))
+	for _, x := range xs {
+		std += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(std / float64(len(xs)-1))
+}
+
+func TestRiskMetricsVaR99NotAboveVaR95(t *testing.T) {
+	old := VaRMethod
+	defer func() { VaRMethod = old }()
+	for _, method := range []string{VaRHistorical, VaRParametric, VaRCornishFisher} {
+		VaRMethod = method
+		for _, data := range [][]StockData{risingBars(31), risingBars(250), syntheticBars(250)} {
+			r := CalculateRiskMetrics(data, RiskFreeRate)
+			if r.VaR99 > r.VaR95 || r.VaR95 >= 0 {
+				t.Errorf("%s（%d 根）: VaR99 %v 应 <= VaR95 %v 且均为负收益", method, len(data), r.VaR99, r.VaR95)
+			}
+		}
+	}
+	if r := CalculateRiskMetrics(risingBars(30), RiskFreeRate); r.VaR95 != 0 || r.VaR99 != 0 || r.RiskLevel != "数据不足" {
+		t.Errorf("29 个收益率应返回 0 并标注数据不足: %+v", r)
+	}
+}
@@ -0,0 +1,229 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ScheduleRoundTimeout 定时任务每轮批量分析的总超时，0 表示不限；超时后未完成的股票以 context.DeadlineExceeded 结束
+var ScheduleRoundTimeout time.Duration
+
+// RoundContext 定时任务单轮使用的 context，ScheduleRoundTimeout>0 时到期自动取消
+func RoundContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if ScheduleRoundTimeout > 0 {
+		return context.WithTimeout(parent, ScheduleRoundTimeout)
+	}
+	return context.WithCancel(parent)
+}
+
+// Schedule 定时任务触发规则
+type Schedule interface {
+	// NextRun 距 now 之后下一次触发的间隔
+	NextRun(now time.Time) time.Duration
+}
+
+// intervalSchedule 固定周期，如 10m、1h
+type intervalSchedule struct {
+	every time.Duration
+}
+
+func (s intervalSchedule) NextRun(now time.Time) time.Duration { return s.every }
+
+// dailySchedule 每天本地时间 0 点
+type dailySchedule struct{}
+
+func (dailySchedule) NextRun(now time.Time) time.Duration {
+	next := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+	return next.Sub(now)
+}
+
+// weeklySchedule 每周固定星期与时刻，如 weekly:Mon:09:30
+type weeklySchedule struct {
+	weekday      time.Weekday
+	hour, minute int
+}
+
+func (s weeklySchedule) NextRun(now time.Time) time.Duration {
+	days := (int(s.weekday) - int(now.Weekday()) + 7) % 7
+	next := time.Date(now.Year(), now.Month(), now.Day()+days, s.hour, s.minute, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 7)
+	}
+	return next.Sub(now)
+}
+
+// cronSchedule 标准 5 段 cron：分 时 日 月 周，每段用位图表示允许的取值
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domAny, dowAny                bool
+}
+
+// cron 最多向后搜索的年数，防止 2 月 30 日之类永不触发的表达式死循环
+const cronSearchYears = 5
+
+func (s cronSchedule) matchDay(t time.Time) bool {
+	domOK := s.dom&(1<<uint(t.Day())) != 0
+	dowOK := s.dow&(1<<uint(t.Weekday())) != 0
+	// 与 cron 一致：日、周同时限定时满足其一即可
+	switch {
+	case s.domAny && s.dowAny:
+		return true
+	case s.domAny:
+		return dowOK
+	case s.dowAny:
+		return domOK
+	default:
+		return domOK || dowOK
+	}
+}
+
+func (s cronSchedule) NextRun(now time.Time) time.Duration {
+	loc := now.Location()
+	t := now.Truncate(time.Minute).Add(time.Minute)
+	limit := now.AddDate(cronSearchYears, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t.Sub(now)
+	}
+	// 表达式永不触发（如 2 月 30 日），退化为每天检查一次
+	return 24 * time.Hour
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseClock 解析 HH:MM
+func parseClock(s string) (int, int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("无效的时刻 %q，应为 HH:MM", s)
+	}
+	return t.Hour(), t.Minute(), nil
+}
+
+// parseCronField 解析单个 cron 字段，支持 *、数字、a-b 区间、逗号列表与 /n 步长
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("无效的步长 %q", part)
+			}
+			step, part = n, part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("无效的取值 %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("无效的取值 %q", part)
+				}
+			} else if step > 1 {
+				hi = max // 如 5/15 表示从 5 开始每 15
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("取值 %q 超出范围 %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// parseCron 解析 5 段 cron 表达式，周字段 0 与 7 均表示周日
+func parseCron(expr string) (cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return cronSchedule{}, fmt.Errorf("cron 表达式需为 5 段（分 时 日 月 周）: %q", expr)
+	}
+	var s cronSchedule
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return s, fmt.Errorf("cron 分钟字段: %v", err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return s, fmt.Errorf("cron 小时字段: %v", err)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return s, fmt.Errorf("cron 日期字段: %v", err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return s, fmt.Errorf("cron 月份字段: %v", err)
+	}
+	if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return s, fmt.Errorf("cron 星期字段: %v", err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny, s.dowAny = fields[2] == "*", fields[4] == "*"
+	return s, nil
+}
+
+// ParseSchedule 解析定时周期：10m、1h、daily、weekly:Mon:09:30、cron:30 15 * * 1-5
+func ParseSchedule(s string) (Schedule, error) {
+	s = strings.TrimSpace(s)
+	lower := strings.ToLower(s)
+	switch {
+	case lower == "daily":
+		return dailySchedule{}, nil
+	case strings.HasPrefix(lower, "weekly:"):
+		parts := strings.SplitN(lower[len("weekly:"):], ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("每周定时格式应为 weekly:Mon:09:30")
+		}
+		day, ok := weekdayNames[parts[0]]
+		if !ok {
+			return nil, fmt.Errorf("无效的星期 %q，应为 Mon~Sun", parts[0])
+		}
+		h, m, err := parseClock(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		return weeklySchedule{weekday: day, hour: h, minute: m}, nil
+	case strings.HasPrefix(lower, "cron:"):
+		return parseCron(s[len("cron:"):])
+	case strings.HasSuffix(lower, "h"):
+		h, err := strconv.Atoi(strings.TrimSuffix(lower, "h"))
+		if err != nil || h <= 0 {
+			return nil, fmt.Errorf("无效的小时数")
+		}
+		return intervalSchedule{every: time.Duration(h) * time.Hour}, nil
+	case strings.HasSuffix(lower, "m"):
+		m, err := strconv.Atoi(strings.TrimSuffix(lower, "m"))
+		if err != nil || m <= 0 {
+			return nil, fmt.Errorf("无效的分钟数")
+		}
+		return intervalSchedule{every: time.Duration(m) * time.Minute}, nil
+	}
+	return nil, fmt.Errorf("不支持的定时格式，仅支持 10m、1h、daily、weekly:Mon:09:30、cron:30 15 * * 1-5 等")
+}
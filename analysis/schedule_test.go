@@ -0,0 +1,48 @@
+package analysis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseScheduleNextRun(t *testing.T) {
+	// 2024-01-05 为周五
+	at := func(day, hour, minute int) time.Time { return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC) }
+	for _, c := range []struct {
+		expr string
+		now  time.Time
+		want time.Duration
+	}{
+		{"10m", at(5, 9, 0), 10 * time.Minute},
+		{"2h", at(5, 9, 0), 2 * time.Hour},
+		{"daily", at(5, 23, 50), 10 * time.Minute},
+		{"weekly:Mon:09:30", at(5, 9, 30), 3 * 24 * time.Hour},
+		{"weekly:fri:09:30", at(5, 9, 0), 30 * time.Minute},
+		{"weekly:Fri:09:30", at(5, 9, 30), 7 * 24 * time.Hour},
+		{"cron:30 0 * * *", at(5, 23, 50), 40 * time.Minute},
+		{"cron:30 15 * * 1-5", at(5, 15, 0), 30 * time.Minute},
+		// 周五收盘后下一次为周一 15:30，跳过周末
+		{"cron:30 15 * * 1-5", at(5, 16, 0), 2*24*time.Hour + 23*time.Hour + 30*time.Minute},
+		{"cron:*/15 * * * *", at(5, 10, 7), 8 * time.Minute},
+		{"cron:0 9 1 * *", at(5, 9, 0), 27 * 24 * time.Hour},
+		{"cron:0 0 * * 7", at(5, 12, 0), 36 * time.Hour},
+	} {
+		s, err := ParseSchedule(c.expr)
+		if err != nil {
+			t.Errorf("%s: %v", c.expr, err)
+			continue
+		}
+		if got := s.NextRun(c.now); got != c.want {
+			t.Errorf("%s 于 %s: NextRun = %v，期望 %v", c.expr, c.now.Format("Mon 15:04"), got, c.want)
+		}
+	}
+}
+
+func TestParseScheduleRejectsInvalid(t *testing.T) {
+	for _, expr := range []string{"", "0m", "xh", "weekly:Foo:09:30", "weekly:Mon", "weekly:Mon:25:00",
+		"cron:30 15 * *", "cron:60 * * * *", "cron:0 0 32 * *", "cron:*/0 * * * *", "monthly"} {
+		if _, err := ParseSchedule(expr); err == nil {
+			t.Errorf("%q 应解析失败", expr)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package analysis
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// RadarDims 雷达图评分维度，prompt 中要求模型按此名称逐项给出 0-100 评分
+var RadarDims = []string{"技术面", "基本面", "资金面", "情绪面"}
+
+// dimScoreRe 匹配“技术面：75”“技术面评分：7.5/10”“| 技术面 | 80分 |”“Technical score: 75”等写法，维度名可为中文或 radarDimEnglish 中的英文名；
+// 数值后须紧跟分隔符或行尾，避免误取“技术面：5日均线”
+func dimScoreRe(dim string) *regexp.Regexp {
+	name := regexp.QuoteMeta(dim)
+	if en, ok := radarDimEnglish[dim]; ok {
+		name = "(?:" + name + "|(?i:" + regexp.QuoteMeta(en) + "))"
+	}
+	return regexp.MustCompile(`(?m)` + name + `(?:评分|得分|(?i:\s*score))?\s*\**\s*(?:[:：]|\|)\s*\**\s*(\d+(?:\.\d+)?)\s*(?:/\s*(10|100)|分)?\s*\**\s*(?:\||$|[，,。；;（(])`)
+}
+
+// ExtractDimensionScores 从报告中提取各维度评分（中英文维度名均可）并归一化到 0-100，同一维度出现多次取最后一次，未给出或超出范围的维度不返回
+func ExtractDimensionScores(report string) map[string]float64 {
+	scores := make(map[string]float64)
+	for _, dim := range RadarDims {
+		matches := dimScoreRe(dim).FindAllStringSubmatch(report, -1)
+		if len(matches) == 0 {
+			continue
+		}
+		m := matches[len(matches)-1]
+		v, err := strconv.ParseFloat(m[1], 64)
+		if err != nil {
+			continue
+		}
+		if m[2] == "10" {
+			v *= 10
+		}
+		if v < 0 || v > 100 {
+			continue
+		}
+		scores[dim] = v
+	}
+	return scores
+}
+
+// clampScore 限制在 0-100
+func clampScore(v float64) float64 {
+	return math.Max(0, math.Min(100, v))
+}
+
+// localTechnicalScore 按最新一根K线的均线排列、MACD 金叉/死叉状态、RSI 估算技术面评分，以 50 为中性；
+// 不足 20 天时以 MA10 代替 MA20，不足 10 天时只看收盘价与 MA5，MA5 也未算出时不评分
+func localTechnicalScore(stockData []StockData, indicators []TechnicalIndicator) (float64, bool) {
+	n := len(indicators)
+	if n == 0 || len(stockData) < n || indicators[n-1].MA5 == 0 {
+		return 0, false
+	}
+	ind, closePrice := indicators[n-1], stockData[n-1].Close
+	trendMA := ind.MA20
+	if trendMA == 0 {
+		trendMA = ind.MA10
+	}
+	score := 50.0
+	if trendMA > 0 {
+		if closePrice > trendMA {
+			score += 15
+		} else {
+			score -= 15
+		}
+		if ind.MA5 > trendMA {
+			score += 10
+		} else {
+			score -= 10
+		}
+	} else if closePrice > ind.MA5 {
+		score += 10
+	} else {
+		score -= 10
+	}
+	if ind.MACD > ind.MACDSignal {
+		score += 15
+	} else if ind.MACD < ind.MACDSignal {
+		score -= 15
+	}
+	switch {
+	case ind.RSI6 > 80:
+		score -= 10
+	case ind.RSI6 > 0 && ind.RSI6 < 20:
+		score += 10
+	}
+	return clampScore(score), true
+}
+
+// localMoneyFlowScore 按量价信号与 OBV 趋势估算资金面评分
+func localMoneyFlowScore(mf MoneyFlow) (float64, bool) {
+	if mf.OBVTrend == "" {
+		return 0, false
+	}
+	score := 50.0
+	switch mf.Signal {
+	case "放量上涨":
+		score += 25
+	case "缩量上涨":
+		score += 10
+	case "缩量下跌":
+		score -= 10
+	case "放量下跌":
+		score -= 25
+	}
+	switch mf.OBVTrend {
+	case "上升":
+		score += 15
+	case "下降":
+		score -= 15
+	}
+	return clampScore(score), true
+}
+
+// FillLocalScores 模型未给出技术面/资金面评分时用本地数据估算补齐，返回被补齐的维度
+func FillLocalScores(scores map[string]float64, stockData []StockData, indicators []TechnicalIndicator, mf *MoneyFlow) []string {
+	var filled []string
+	if _, ok := scores["技术面"]; !ok {
+		if v, ok := localTechnicalScore(stockData, indicators); ok {
+			scores["技术面"] = v
+			filled = append(filled, "技术面")
+		}
+	}
+	if _, ok := scores["资金面"]; !ok && mf != nil {
+		if v, ok := localMoneyFlowScore(*mf); ok {
+			scores["资金面"] = v
+			filled = append(filled, "资金面")
+		}
+	}
+	return filled
+}
+
+// IndustryAverageScores 同行业其他标的（每只取最新一条有评分的记录）的各维度均值，返回均值与参与的标的数
+func IndustryAverageScores(records []ConclusionRecord, industry, excludeCode string) (map[string]float64, int) {
+	if industry == "" {
+		return nil, 0
+	}
+	latest := make(map[string]ConclusionRecord)
+	for _, r := range records {
+		if r.Industry != industry || r.StockCode == excludeCode || len(r.Scores) == 0 {
+			continue
+		}
+		if prev, ok := latest[r.StockCode]; !ok || r.Time.After(prev.Time) {
+			latest[r.StockCode] = r
+		}
+	}
+	if len(latest) == 0 {
+		return nil, 0
+	}
+	sums := make(map[string]float64)
+	counts := make(map[string]int)
+	for _, r := range latest {
+		for dim, v := range r.Scores {
+			sums[dim] += v
+			counts[dim]++
+		}
+	}
+	avg := make(map[string]float64)
+	for dim, s := range sums {
+		avg[dim] = s / float64(counts[dim])
+	}
+	return avg, len(latest)
+}
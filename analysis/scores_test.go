@@ -0,0 +1,53 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExtractDimensionScores(t *testing.T) {
+	report := `| 维度 | 评分 |
+| 技术面 | 80分 |
+基本面评分：7.5/10
+资金面：5日均线上方
+Sentiment score: 62
+技术面：85`
+	want := map[string]float64{"技术面": 85, "基本面": 75, "情绪面": 62}
+	if got := ExtractDimensionScores(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("维度评分 = %v，期望 %v", got, want)
+	}
+	if got := ExtractDimensionScores("技术面：150"); len(got) != 0 {
+		t.Errorf("超出范围的评分应忽略: %v", got)
+	}
+}
+
+func TestFillLocalScoresAndIndustryAverage(t *testing.T) {
+	data := trendBars(60, 0.01)
+	mf := CalculateMoneyFlow(data)
+	scores := map[string]float64{"技术面": 30}
+	filled := FillLocalScores(scores, data, calculateTechnicalIndicators(data), &mf)
+	if !reflect.DeepEqual(filled, []string{"资金面"}) || scores["技术面"] != 30 {
+		t.Errorf("模型已给出的技术面不应被覆盖，补齐 %v，评分 %v", filled, scores)
+	}
+	if v := scores["资金面"]; v < 0 || v > 100 {
+		t.Errorf("资金面评分 = %v", v)
+	}
+
+	day := time.Date(2024, 6, 3, 0, 0, 0, 0, time.Local)
+	records := []ConclusionRecord{
+		{StockCode: "601398", Industry: "银行", Scores: map[string]float64{"技术面": 40}, Time: day},
+		{StockCode: "601398", Industry: "银行", Scores: map[string]float64{"技术面": 60, "资金面": 50}, Time: day.AddDate(0, 0, 1)},
+		{StockCode: "000001", Industry: "银行", Scores: map[string]float64{"技术面": 80}, Time: day},
+		{StockCode: "600036", Industry: "银行", Scores: map[string]float64{"技术面": 10}, Time: day},
+		{StockCode: "601988", Industry: "银行", Time: day},
+		{StockCode: "600519", Industry: "白酒", Scores: map[string]float64{"技术面": 90}, Time: day},
+	}
+	avg, n := IndustryAverageScores(records, "银行", "600036")
+	if n != 2 || !reflect.DeepEqual(avg, map[string]float64{"技术面": 70, "资金面": 50}) {
+		t.Errorf("行业均值 = %v（%d 只），应每只取最新记录并排除本股", avg, n)
+	}
+	if avg, n := IndustryAverageScores(records, "", "600036"); avg != nil || n != 0 {
+		t.Error("未知行业不应计算均值")
+	}
+}
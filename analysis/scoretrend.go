@@ -0,0 +1,103 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ScoreTrendWindow 评分趋势取最近多少次分析
+var ScoreTrendWindow = 10
+
+// ScoreTrendMinPoints 某维度至少有多少次评分才计算趋势
+var ScoreTrendMinPoints = 3
+
+// scoreTrendMinChange 拟合变化量（0-100 分）超过该值才判定为改善或恶化
+const scoreTrendMinChange = 10
+
+// ScorePoint 某次分析的维度评分
+type ScorePoint struct {
+	Time  time.Time
+	Score float64
+}
+
+// ScoreTrend 单个维度的评分时间序列与趋势判断
+type ScoreTrend struct {
+	Dim       string
+	Points    []ScorePoint // 按时间升序
+	Change    float64      // 线性拟合斜率 × (点数-1)，即窗口内的趋势变化量
+	Direction string       // 改善/恶化/持平
+}
+
+// scoreSlope 评分对分析序号的最小二乘斜率，分析间隔不均匀时按次数而非天数计算
+func scoreSlope(points []ScorePoint) float64 {
+	n := float64(len(points))
+	var sumX, sumY, sumXY, sumXX float64
+	for i, p := range points {
+		x := float64(i)
+		sumX += x
+		sumY += p.Score
+		sumXY += x * p.Score
+		sumXX += x * x
+	}
+	den := n*sumXX - sumX*sumX
+	if den == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / den
+}
+
+// StockScoreTrends 个股最近 window 次带评分的分析中各维度的评分趋势，评分少于 ScoreTrendMinPoints 的维度不返回
+func StockScoreTrends(records []ConclusionRecord, stockCode string, window int) []ScoreTrend {
+	var list []ConclusionRecord
+	for _, r := range records {
+		if r.StockCode == stockCode && len(r.Scores) > 0 {
+			list = append(list, r)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Time.Before(list[j].Time) })
+	if window > 0 && len(list) > window {
+		list = list[len(list)-window:]
+	}
+
+	var trends []ScoreTrend
+	for _, dim := range RadarDims {
+		var points []ScorePoint
+		for _, r := range list {
+			if v, ok := r.Scores[dim]; ok {
+				points = append(points, ScorePoint{Time: r.Time, Score: v})
+			}
+		}
+		if len(points) < ScoreTrendMinPoints {
+			continue
+		}
+		t := ScoreTrend{Dim: dim, Points: points, Change: scoreSlope(points) * float64(len(points)-1), Direction: "持平"}
+		switch {
+		case t.Change >= scoreTrendMinChange:
+			t.Direction = "改善"
+		case t.Change <= -scoreTrendMinChange:
+			t.Direction = "恶化"
+		}
+		trends = append(trends, t)
+	}
+	return trends
+}
+
+// FormatScoreTrends 各维度评分趋势 markdown 表格
+func FormatScoreTrends(trends []ScoreTrend) string {
+	if len(trends) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n【评分趋势】\n| 维度 | 历次评分（旧→新） | 趋势变化 | 判断 |\n|---|---|---|---|\n")
+	for _, t := range trends {
+		var seq []string
+		for _, p := range t.Points {
+			seq = append(seq, fmt.Sprintf("%.0f", p.Score))
+		}
+		fmt.Fprintf(&b, "| %s | %s | %+.1f | %s |\n", t.Dim, strings.Join(seq, "→"), math.Round(t.Change*10)/10, t.Direction)
+	}
+	return b.String()
+}
@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"context"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scoreHistory 600036 五次分析：技术面逐次 +5、基本面持平（第 3 次缺失）、资金面逐次 -5、情绪面只有两次
+func scoreHistory() []ConclusionRecord {
+	t0 := time.Date(2024, 6, 3, 15, 0, 0, 0, time.Local)
+	var records []ConclusionRecord
+	for i := 4; i >= 0; i-- { // 倒序写入，验证按时间排序
+		scores := map[string]float64{"技术面": 50 + 5*float64(i), "资金面": 80 - 5*float64(i)}
+		if i != 2 {
+			scores["基本面"] = 70
+		}
+		if i < 2 {
+			scores["情绪面"] = 60
+		}
+		records = append(records, ConclusionRecord{StockCode: "600036", Scores: scores, Time: t0.AddDate(0, 0, i)})
+	}
+	return append(records,
+		ConclusionRecord{StockCode: "600036", Time: t0.AddDate(0, 0, 10)}, // 无评分的旧记录忽略
+		ConclusionRecord{StockCode: "000001", Scores: map[string]float64{"技术面": 10}, Time: t0},
+	)
+}
+
+func TestStockScoreTrends(t *testing.T) {
+	trends := StockScoreTrends(scoreHistory(), "600036", 10)
+	if len(trends) != 3 || trends[0].Dim != "技术面" || trends[1].Dim != "基本面" || trends[2].Dim != "资金面" {
+		t.Fatalf("评分不足 %d 次的情绪面应跳过: %+v", ScoreTrendMinPoints, trends)
+	}
+	tech, fund, flow := trends[0], trends[1], trends[2]
+	if len(tech.Points) != 5 || tech.Points[0].Score != 50 || tech.Points[4].Score != 70 || math.Abs(tech.Change-20) > 1e-9 || tech.Direction != "改善" {
+		t.Errorf("技术面趋势 = %+v", tech)
+	}
+	if len(fund.Points) != 4 || fund.Change != 0 || fund.Direction != "持平" {
+		t.Errorf("基本面趋势 = %+v", fund)
+	}
+	if math.Abs(flow.Change+20) > 1e-9 || flow.Direction != "恶化" {
+		t.Errorf("资金面趋势 = %+v", flow)
+	}
+
+	// 只取最近 3 次：技术面变化 +10 恰好达到阈值，基本面仅 2 次评分不再计算
+	recent := StockScoreTrends(scoreHistory(), "600036", 3)
+	if len(recent) != 2 || recent[0].Points[0].Score != 60 || recent[0].Direction != "改善" || recent[1].Dim != "资金面" {
+		t.Errorf("最近 3 次的趋势 = %+v", recent)
+	}
+
+	table := FormatScoreTrends(trends)
+	for _, want := range []string{"【评分趋势】", "| 技术面 | 50→55→60→65→70 | +20.0 | 改善 |", "| 基本面 | 70→70→70→70 | +0.0 | 持平 |", "| 资金面 | 80→75→70→65→60 | -20.0 | 恶化 |"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("评分趋势表缺少 %q:\n%s", want, table)
+		}
+	}
+	if FormatScoreTrends(nil) != "" || len(StockScoreTrends(scoreHistory(), "AAPL", 10)) != 0 {
+		t.Error("无历史评分时不应输出趋势")
+	}
+}
+
+func TestScoreTrendsFromConclusionFile(t *testing.T) {
+	old := ConclusionFile
+	ConclusionFile = filepath.Join(t.TempDir(), "conclusions.jsonl")
+	defer func() { ConclusionFile = old }()
+	for _, rec := range scoreHistory() {
+		if err := AppendConclusionRecord(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	records, err := LoadConclusionRecords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trends := StockScoreTrends(records, "600036", 10); len(trends) != 3 || trends[0].Direction != "改善" {
+		t.Errorf("历史记录应保存各维度子评分: %+v", trends)
+	}
+}
+
+func TestGenerateScoreTrendChart(t *testing.T) {
+	captureHTML2PNG(t)
+	dir := t.TempDir()
+	path, err := GenerateScoreTrendChart(context.Background(), "600036", StockScoreTrends(scoreHistory(), "600036", 10), dir)
+	if err != nil || path != filepath.Join(dir, "600036-scoretrend.png") {
+		t.Fatalf("评分趋势图: %q %v", path, err)
+	}
+	data, _ := os.ReadFile(path)
+	for _, want := range []string{"600036 评分趋势", "技术面（改善）", "资金面（恶化）", `"-"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("评分趋势图缺少 %s", want)
+		}
+	}
+	if path, err := GenerateScoreTrendChart(context.Background(), "600036", nil, dir); path != "" || err != nil {
+		t.Errorf("无趋势时不应生成: %q %v", path, err)
+	}
+}
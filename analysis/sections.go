@@ -0,0 +1,53 @@
+package analysis
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ReportSection 报告中的一个维度小节
+type ReportSection struct {
+	Title string
+	Lines []string
+}
+
+var (
+	markdownHeadingRe = regexp.MustCompile(`^#{1,3}\s+(.+?)\s*#*\s*$`)
+	bracketHeadingRe  = regexp.MustCompile(`^【([^】]+)】\s*$`)
+)
+
+// sectionTitle 判断一行是否为小节标题：markdown 一至三级标题或单独一行的【xxx】
+func sectionTitle(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if m := markdownHeadingRe.FindStringSubmatch(line); m != nil {
+		return strings.Trim(m[1], "*【】 "), true
+	}
+	if m := bracketHeadingRe.FindStringSubmatch(line); m != nil {
+		return m[1], true
+	}
+	return "", false
+}
+
+// SplitReportSections 按小节标题将报告切分为维度块，标题前的内容归入“概览”，空行与空小节会被剔除
+func SplitReportSections(report string) []ReportSection {
+	var sections []ReportSection
+	cur := ReportSection{Title: "概览"}
+	flush := func() {
+		if len(cur.Lines) > 0 {
+			sections = append(sections, cur)
+		}
+	}
+	for _, line := range strings.Split(report, "\n") {
+		if title, ok := sectionTitle(line); ok {
+			flush()
+			cur = ReportSection{Title: title}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		cur.Lines = append(cur.Lines, line)
+	}
+	flush()
+	return sections
+}
@@ -0,0 +1,45 @@
+package analysis
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitReportSectionsMultiDimension(t *testing.T) {
+	report := `> 数据来源：腾讯API
+
+# 招商银行分析报告
+## 技术面
+MA5 上穿 MA20
+
+RSI 62
+### **资金面** ###
+主力净流入 2.3 亿
+【风险提示】
+注意回撤
+## 空小节
+## 操作建议
+逢低买入`
+	got := SplitReportSections(report)
+	want := []ReportSection{
+		{Title: "概览", Lines: []string{"> 数据来源：腾讯API"}},
+		{Title: "技术面", Lines: []string{"MA5 上穿 MA20", "RSI 62"}},
+		{Title: "资金面", Lines: []string{"主力净流入 2.3 亿"}},
+		{Title: "风险提示", Lines: []string{"注意回撤"}},
+		{Title: "操作建议", Lines: []string{"逢低买入"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("分块结果:\n%+v\n期望:\n%+v", got, want)
+	}
+}
+
+func TestSplitReportSectionsWithoutHeadings(t *testing.T) {
+	got := SplitReportSections("第一行\n\n#### 四级标题不分块\n文中的【资金面】不是标题")
+	want := []ReportSection{{Title: "概览", Lines: []string{"第一行", "#### 四级标题不分块", "文中的【资金面】不是标题"}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("无小节标题时应整体归入概览: %+v", got)
+	}
+	if got := SplitReportSections(""); len(got) != 0 {
+		t.Errorf("空报告不应有小节: %+v", got)
+	}
+}
@@ -0,0 +1,212 @@
+package analysis
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SectorMinMembers 板块内成功分析的股票数不少于该值才输出板块综述
+var SectorMinMembers = 2
+
+// 板块强弱判定阈值（成员综合评分均值，0-100）
+const (
+	sectorStrongScore = 60
+	sectorWeakScore   = 40
+)
+
+// SectorMember 板块内单只股票的聚合输入
+type SectorMember struct {
+	StockCode   string
+	Score       float64 // 各维度评分均值，无评分时为 -1
+	Sentiment   float64 // 情绪面评分，无评分时为 -1
+	PriceChange float64 // 近 5 日涨跌幅
+	NetInflow5  float64 // 近 5 日主力净流入估算（元）
+	HasFlow     bool
+}
+
+// SectorSummary 板块层面的聚合结论
+type SectorSummary struct {
+	Industry     string
+	Members      []SectorMember // 按近 5 日涨跌幅降序，无行情的排在最后
+	AvgScore     float64        // 综合评分均值，无评分时为 -1
+	AvgSentiment float64        // 情绪面均值，无评分时为 -1
+	AvgChange    float64
+	NetInflow5   float64 // 成员近 5 日主力净流入合计
+	UpCount      int
+	DownCount    int
+	Strength     string // 强势/中性/弱势
+}
+
+// avgScore 各维度评分的均值，无评分返回 -1
+func avgScore(scores map[string]float64) float64 {
+	if len(scores) == 0 {
+		return -1
+	}
+	total := 0.0
+	for _, v := range scores {
+		total += v
+	}
+	return total / float64(len(scores))
+}
+
+// newSectorMember 从单只股票的分析结果提取聚合输入
+func newSectorMember(r AnalysisResult) SectorMember {
+	m := SectorMember{StockCode: r.StockCode, Score: avgScore(r.Scores), Sentiment: -1}
+	if v, ok := r.Scores["情绪面"]; ok {
+		m.Sentiment = v
+	}
+	if r.MoneyFlow != nil {
+		m.PriceChange, m.NetInflow5, m.HasFlow = r.MoneyFlow.PriceChange, r.MoneyFlow.NetInflow5, true
+	}
+	return m
+}
+
+// AggregateSector 汇总同板块成员的评分、资金流与情绪，判定板块强弱
+func AggregateSector(industry string, members []SectorMember) SectorSummary {
+	sum := SectorSummary{Industry: industry, AvgScore: -1, AvgSentiment: -1}
+	sum.Members = append([]SectorMember(nil), members...)
+	sort.SliceStable(sum.Members, func(i, j int) bool {
+		a, b := sum.Members[i], sum.Members[j]
+		if a.HasFlow != b.HasFlow {
+			return a.HasFlow
+		}
+		return a.PriceChange > b.PriceChange
+	})
+
+	var scoreTotal, sentimentTotal, changeTotal float64
+	var scoreN, sentimentN, flowN int
+	for _, m := range sum.Members {
+		if m.Score >= 0 {
+			scoreTotal += m.Score
+			scoreN++
+		}
+		if m.Sentiment >= 0 {
+			sentimentTotal += m.Sentiment
+			sentimentN++
+		}
+		if m.HasFlow {
+			changeTotal += m.PriceChange
+			sum.NetInflow5 += m.NetInflow5
+			flowN++
+			if m.PriceChange > 0 {
+				sum.UpCount++
+			} else if m.PriceChange < 0 {
+				sum.DownCount++
+			}
+		}
+	}
+	if scoreN > 0 {
+		sum.AvgScore = scoreTotal / float64(scoreN)
+	}
+	if sentimentN > 0 {
+		sum.AvgSentiment = sentimentTotal / float64(sentimentN)
+	}
+	if flowN > 0 {
+		sum.AvgChange = changeTotal / float64(flowN)
+	}
+
+	switch {
+	case sum.AvgScore >= sectorStrongScore || (sum.AvgScore < 0 && sum.AvgChange > 0 && sum.NetInflow5 > 0):
+		sum.Strength = "强势"
+	case (sum.AvgScore >= 0 && sum.AvgScore <= sectorWeakScore) || (sum.AvgScore < 0 && sum.AvgChange < 0 && sum.NetInflow5 < 0):
+		sum.Strength = "弱势"
+	default:
+		sum.Strength = "中性"
+	}
+	return sum
+}
+
+// AggregateSectors 按行业分组聚合批量分析结果；同一股票多种模式只取第一份成功结果，
+// 行业未知的归入“未分类”，成员不足 SectorMinMembers 的板块不输出
+func AggregateSectors(results []AnalysisResult) []SectorSummary {
+	groups := make(map[string][]SectorMember)
+	var order []string
+	seen := make(map[string]bool)
+	for _, r := range results {
+		if r.Err != nil || seen[r.StockCode] {
+			continue
+		}
+		seen[r.StockCode] = true
+		industry := r.Industry
+		if industry == "" {
+			industry = "未分类"
+		}
+		if _, ok := groups[industry]; !ok {
+			order = append(order, industry)
+		}
+		groups[industry] = append(groups[industry], newSectorMember(r))
+	}
+	var sums []SectorSummary
+	for _, industry := range order {
+		if len(groups[industry]) >= SectorMinMembers {
+			sums = append(sums, AggregateSector(industry, groups[industry]))
+		}
+	}
+	return sums
+}
+
+// formatScore 评分展示，无评分显示“-”
+func formatScore(v float64) string {
+	if v < 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f", v)
+}
+
+// FormatSectorSummary 板块综述 Markdown：整体强弱、评分/情绪/资金均值与领涨领跌
+func FormatSectorSummary(sums []SectorSummary) string {
+	if len(sums) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("## 板块综述\n\n")
+	for _, s := range sums {
+		fmt.Fprintf(&b, "### %s（%d只，%s）\n\n", s.Industry, len(s.Members), s.Strength)
+		fmt.Fprintf(&b, "- 综合评分均值 %s，情绪面均值 %s\n", formatScore(s.AvgScore), formatScore(s.AvgSentiment))
+		var withFlow []SectorMember
+		for _, m := range s.Members {
+			if m.HasFlow {
+				withFlow = append(withFlow, m)
+			}
+		}
+		if len(withFlow) > 0 {
+			leader, laggard := withFlow[0], withFlow[len(withFlow)-1]
+			fmt.Fprintf(&b, "- 近5日平均涨跌幅 %s，上涨 %d 只 / 下跌 %d 只，主力净流入合计 %.2f 万元\n",
+				FormatPercent(s.AvgChange), s.UpCount, s.DownCount, s.NetInflow5/1e4)
+			fmt.Fprintf(&b, "- 领涨：%s（%s），领跌：%s（%s）\n",
+				leader.StockCode, FormatPercent(leader.PriceChange), laggard.StockCode, FormatPercent(laggard.PriceChange))
+		}
+		b.WriteString("\n")
+		b.WriteString("| 股票 | 综合评分 | 情绪面 | 近5日涨跌幅 | 近5日主力净流入(万元) |\n|---|---|---|---|---|\n")
+		for _, m := range s.Members {
+			change, flow := "-", "-"
+			if m.HasFlow {
+				change, flow = FormatPercent(m.PriceChange), fmt.Sprintf("%.2f", m.NetInflow5/1e4)
+			}
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s |\n", m.StockCode, formatScore(m.Score), formatScore(m.Sentiment), change, flow)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// SaveSectorSummary 将板块综述写入 history/sector-日期-时间.md，返回文件名
+func SaveSectorSummary(sums []SectorSummary, now time.Time) (string, error) {
+	content := FormatSectorSummary(sums)
+	if content == "" {
+		return "", nil
+	}
+	if err := os.MkdirAll(HistoryDir, 0755); err != nil {
+		return "", err
+	}
+	fname := reserveHistoryBase("sector-"+now.Format("2006-01-02-150405")) + ".md"
+	if err := ioutil.WriteFile(filepath.Join(HistoryDir, fname), []byte(content), 0644); err != nil {
+		return "", err
+	}
+	return fname, nil
+}
@@ -0,0 +1,86 @@
+package analysis
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func bankSectorResults() []AnalysisResult {
+	return []AnalysisResult{
+		{StockCode: "600036", Industry: "银行", Scores: map[string]float64{"技术面": 80, "情绪面": 70},
+			MoneyFlow: &MoneyFlow{PriceChange: 0.05, NetInflow5: 2e7}},
+		{StockCode: "601398", Industry: "银行", Scores: map[string]float64{"技术面": 60, "情绪面": 50},
+			MoneyFlow: &MoneyFlow{PriceChange: -0.02, NetInflow5: -5e6}},
+		{StockCode: "000001", Industry: "银行", Scores: map[string]float64{"技术面": 50}},
+		{StockCode: "600036", Industry: "银行", Scores: map[string]float64{"技术面": 10}}, // 同股票其他模式，忽略
+		{StockCode: "601166", Industry: "银行", Err: errors.New("分析失败")},
+		{StockCode: "AAPL", Scores: map[string]float64{"技术面": 90}},
+	}
+}
+
+func TestAggregateSectorsBankGroup(t *testing.T) {
+	sums := AggregateSectors(bankSectorResults())
+	if len(sums) != 1 || sums[0].Industry != "银行" {
+		t.Fatalf("应只输出成员不少于 %d 只的银行板块: %+v", SectorMinMembers, sums)
+	}
+	s := sums[0]
+	if len(s.Members) != 3 || s.Members[0].StockCode != "600036" || s.Members[1].StockCode != "601398" || s.Members[2].StockCode != "000001" {
+		t.Errorf("成员应按涨跌幅降序、无行情排最后: %+v", s.Members)
+	}
+	if s.AvgScore != 60 || s.AvgSentiment != 60 || s.Strength != "强势" {
+		t.Errorf("评分均值 %v 情绪均值 %v 强弱 %s", s.AvgScore, s.AvgSentiment, s.Strength)
+	}
+	if math.Abs(s.AvgChange-0.015) > 1e-12 || s.NetInflow5 != 1.5e7 || s.UpCount != 1 || s.DownCount != 1 {
+		t.Errorf("资金流汇总错误: %+v", s)
+	}
+
+	text := FormatSectorSummary(sums)
+	for _, want := range []string{
+		"### 银行（3只，强势）",
+		"- 综合评分均值 60，情绪面均值 60",
+		"近5日平均涨跌幅 1.50%，上涨 1 只 / 下跌 1 只，主力净流入合计 1500.00 万元",
+		"- 领涨：600036（5.00%），领跌：601398（-2.00%）",
+		"| 000001 | 50 | - | - | - |",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("板块综述缺少 %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestAggregateSectorStrengthWithoutScores(t *testing.T) {
+	members := []SectorMember{
+		{StockCode: "600028", Score: -1, Sentiment: -1, PriceChange: -0.03, NetInflow5: -1e6, HasFlow: true},
+		{StockCode: "601857", Score: -1, Sentiment: -1, PriceChange: -0.01, NetInflow5: -2e6, HasFlow: true},
+	}
+	if s := AggregateSector("石油", members); s.Strength != "弱势" || s.AvgScore != -1 {
+		t.Errorf("无评分且普跌流出应判为弱势: %+v", s)
+	}
+	members[0].PriceChange, members[0].NetInflow5 = 0.06, 5e6
+	if s := AggregateSector("石油", members); s.Strength != "强势" {
+		t.Errorf("无评分且上涨净流入应判为强势: %+v", s)
+	}
+	if s := AggregateSector("石油", []SectorMember{{Score: 50, Sentiment: -1}, {Score: 45, Sentiment: -1}}); s.Strength != "中性" {
+		t.Errorf("评分居中应判为中性: %+v", s)
+	}
+}
+
+func TestSaveSectorSummary(t *testing.T) {
+	useHistoryDir(t, nil)
+	fname, err := SaveSectorSummary(AggregateSectors(bankSectorResults()), time.Date(2024, 6, 28, 15, 30, 0, 0, time.Local))
+	if err != nil || !strings.HasPrefix(fname, "sector-2024-06-28-153000") {
+		t.Fatalf("保存板块综述: %q %v", fname, err)
+	}
+	data, err := os.ReadFile(filepath.Join(HistoryDir, fname))
+	if err != nil || !strings.HasPrefix(string(data), "## 板块综述") {
+		t.Errorf("板块综述文件内容错误: %v\n%s", err, data)
+	}
+	if fname, err := SaveSectorSummary(nil, time.Now()); fname != "" || err != nil {
+		t.Errorf("无板块时不应写文件: %q %v", fname, err)
+	}
+}
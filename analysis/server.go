@@ -0,0 +1,336 @@
+package analysis
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// AnalyzeRequest POST /analyze 请求体
+type AnalyzeRequest struct {
+	StockCode   string   `json:"stock_code"`
+	APIKey      string   `json:"api_key"` // 为空时使用服务启动时配置的 Key
+	Model       string   `json:"model"`   // 为空时使用服务启动时配置的模型
+	Start       string   `json:"start"`
+	End         string   `json:"end"`
+	Mode        string   `json:"mode"` // reason/search/hybrid
+	Periods     []string `json:"periods"`
+	Dims        []string `json:"dims"`
+	Output      []string `json:"output"`
+	Lang        string   `json:"lang"`
+	Temperature float64  `json:"temperature,omitempty"` // 0 表示默认
+	MaxTokens   int      `json:"max_tokens,omitempty"`  // 0 表示默认
+}
+
+// TaskServer 异步分析任务 HTTP 服务：
+// POST /analyze 提交任务，GET /tasks/{id} 查询状态与结果，DELETE /tasks/{id} 取消任务；
+// GET /stocks/{code}/risk、GET /stocks/{code}/prediction、GET /compare?codes=a,b&factors=sharpe,技术面&weights=0.6,0.4&industry_neutral=true 查询风险、最近结论（附短/中/长期趋势一致性）与多股因子打分对比；
+// GET /stocks/{code}/backtest?strategy=ma_cross&fast=5&slow=20 策略回测；
+// GET /stocks/{code}/ml 决策树/随机森林价格预测（配置 Redis 时按行情指纹缓存）；
+// POST /models 训练并保存随机森林模型（须携带 AuthToken 令牌），GET /models?code=600519 列出已保存模型的元信息；
+// GET /ws/quotes/{code}?interval=5s WebSocket 行情推送；GET /metrics 输出 Prometheus 指标
+type TaskServer struct {
+	Manager   *TaskManager
+	Metrics   *Metrics // HTTP 与 gRPC 接口共用
+	APIKey    string
+	Model     string
+	APIURL    string
+	AuthToken string // 请求未带 api_key 时须以 Authorization: Bearer <AuthToken> 鉴权才使用 APIKey，为空时不对外提供默认 Key
+}
+
+// NewTaskServer 创建任务服务，apiKey/model 为请求未指定时的默认值
+func NewTaskServer(apiKey, model string) *TaskServer {
+	return &TaskServer{
+		Manager: NewTaskManager(),
+		Metrics: NewMetrics(),
+		APIKey:  apiKey,
+		Model:   model,
+		APIURL:  ChatCompletionsURL(DeepSeekBaseURL),
+	}
+}
+
+// Handler 路由
+func (s *TaskServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /analyze", s.handleAnalyze)
+	mux.HandleFunc("GET /tasks/{id}", s.handleGetTask)
+	mux.HandleFunc("DELETE /tasks/{id}", s.handleCancelTask)
+	mux.HandleFunc("GET /stocks/{code}/risk", s.handleRisk)
+	mux.HandleFunc("GET /stocks/{code}/prediction", s.handlePrediction)
+	mux.HandleFunc("GET /compare", s.handleCompare)
+	mux.HandleFunc("GET /stocks/{code}/backtest", s.handleBacktest)
+	mux.HandleFunc("GET /stocks/{code}/ml", s.handleMLPrediction)
+	mux.HandleFunc("POST /models", s.handleTrainModel)
+	mux.HandleFunc("GET /models", s.handleListModels)
+	mux.HandleFunc("GET /ws/quotes/{code}", s.handleQuoteWS)
+	mux.Handle("GET /metrics", s.Metrics.Handler())
+	return s.Metrics.Middleware(mux)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// writeServiceError 按业务层错误类型映射 HTTP 状态码
+func writeServiceError(w http.ResponseWriter, err error) {
+	switch {
+	case IsRequestError(err):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, ErrNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrUnauthorized):
+		writeError(w, http.StatusUnauthorized, err.Error())
+	default:
+		writeError(w, http.StatusInternalServerError, err.Error())
+	}
+}
+
+func (s *TaskServer) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	var req AnalyzeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "请求体解析失败: "+err.Error())
+		return
+	}
+	task, err := s.SubmitAnalysis(req, bearerToken(r.Header.Get("Authorization")))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, map[string]string{"task_id": task.ID, "status": string(task.Status)})
+}
+
+// bearerToken 取 Authorization: Bearer <token> 中的令牌
+func bearerToken(header string) string {
+	if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
+
+func (s *TaskServer) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	task, err := s.GetTask(r.PathValue("id"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+func (s *TaskServer) handleCancelTask(w http.ResponseWriter, r *http.Request) {
+	task, err := s.CancelTask(r.PathValue("id"))
+	if errors.Is(err, ErrNotFound) {
+		writeServiceError(w, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, task)
+}
+
+func (s *TaskServer) handleRisk(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	risk, info, err := StockRisk(r.Context(), code, r.URL.Query().Get("start"), r.URL.Query().Get("end"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"stock_code": code, "risk": risk, "data_source": info.Source})
+}
+
+// predictionResponse 最近结论附带本地行情的多周期趋势，行情获取失败时不含 multi_period
+type predictionResponse struct {
+	ConclusionRecord
+	MultiPeriod *MultiPeriodTrend `json:"multi_period,omitempty"`
+}
+
+func (s *TaskServer) handlePrediction(w http.ResponseWriter, r *http.Request) {
+	rec, err := LatestPrediction(r.PathValue("code"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	resp := predictionResponse{ConclusionRecord: rec}
+	if trend, err := StockMultiPeriodTrend(r.Context(), rec.StockCode); err != nil {
+		log.Printf("[预测] %s 多周期趋势计算失败: %v\n", rec.StockCode, err)
+	} else {
+		resp.MultiPeriod = &trend
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (s *TaskServer) handleMLPrediction(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	preds, info, err := StockMLPrediction(r.Context(), code)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"stock_code": code, "predictions": preds, "data_source": info.Source})
+}
+
+// handleTrainModel 训练会占用服务端计算资源并写入模型文件，与使用默认 API Key 一样须携带有效令牌
+func (s *TaskServer) handleTrainModel(w http.ResponseWriter, r *http.Request) {
+	if !s.authorized(bearerToken(r.Header.Get("Authorization"))) {
+		writeServiceError(w, fmt.Errorf("训练模型需要有效令牌: %w", ErrUnauthorized))
+		return
+	}
+	var req TrainModelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "请求体解析失败: "+err.Error())
+		return
+	}
+	meta, err := TrainStockModel(r.Context(), req)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, meta)
+}
+
+func (s *TaskServer) handleListModels(w http.ResponseWriter, r *http.Request) {
+	list, err := ListModels(r.URL.Query().Get("code"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"models": list})
+}
+
+// parseFactorQuery 解析 factors=sharpe,技术面&weights=0.6,0.4，两者都未提供时返回默认因子
+func parseFactorQuery(q url.Values) ([]FactorWeight, error) {
+	rawFactors, rawWeights := q.Get("factors"), q.Get("weights")
+	if rawFactors == "" && rawWeights == "" {
+		return DefaultFactors, nil
+	}
+	var factors []string
+	if rawFactors != "" {
+		factors = strings.Split(rawFactors, ",")
+	}
+	var weights []float64
+	if rawWeights != "" {
+		for _, raw := range strings.Split(rawWeights, ",") {
+			v, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+			if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+				return nil, &RequestError{"权重不是合法数字: " + raw}
+			}
+			weights = append(weights, v)
+		}
+	}
+	return ParseFactorWeights(factors, weights)
+}
+
+// parseIndustryQuery 解析 industry_neutral=true 与 industries=600519:白酒,601398:银行，任一提供时按行业中性化打分，否则返回 nil
+func parseIndustryQuery(q url.Values, codes []string) (map[string]string, error) {
+	neutral := false
+	if raw := q.Get("industry_neutral"); raw != "" {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, &RequestError{"industry_neutral 应为 true/false: " + raw}
+		}
+		neutral = v
+	}
+	overrides := make(map[string]string)
+	if raw := q.Get("industries"); raw != "" {
+		for _, pair := range strings.Split(raw, ",") {
+			code, industry, ok := strings.Cut(pair, ":")
+			code, industry = strings.TrimSpace(code), strings.TrimSpace(industry)
+			if !ok || code == "" || industry == "" {
+				return nil, &RequestError{"industries 格式应为 代码:行业，逗号分隔: " + pair}
+			}
+			overrides[code] = industry
+		}
+	}
+	if !neutral && len(overrides) == 0 {
+		return nil, nil
+	}
+	return ResolveIndustries(codes, overrides), nil
+}
+
+func (s *TaskServer) handleCompare(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	factors, err := parseFactorQuery(q)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	codes := strings.Split(q.Get("codes"), ",")
+	for i := range codes {
+		codes[i] = strings.TrimSpace(codes[i])
+	}
+	industries, err := parseIndustryQuery(q, codes)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	list, err := CompareStocks(r.Context(), codes, q.Get("start"), q.Get("end"), factors, industries)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"factors": factors, "stocks": list})
+}
+
+// backtestQueryFields 回测查询参数对应的 BacktestParams 字段
+var backtestQueryFields = map[string]string{
+	"fast":       "FastMAPeriod",
+	"slow":       "SlowMAPeriod",
+	"breakout":   "BreakoutPeriod",
+	"rsi_period": "RSIPeriod",
+	"overbought": "RSIOverbought",
+	"oversold":   "RSIOversold",
+	"stoploss":   "StopLoss",
+	"takeprofit": "TakeProfit",
+	"cash":       "InitialCash",
+	"atr_period": "ATRPeriod",
+	"atr_mult":   "ATRMultiplier",
+}
+
+// parseBacktestQuery 在 DefaultBacktestParams 基础上按查询参数覆盖，数值非法时返回 RequestError
+func parseBacktestQuery(q url.Values) (BacktestParams, error) {
+	params := DefaultBacktestParams()
+	if st := q.Get("strategy"); st != "" {
+		params.StrategyType = st
+	}
+	for key, field := range backtestQueryFields {
+		raw := q.Get(key)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			return params, &RequestError{fmt.Sprintf("参数 %s 不是合法数字: %s", key, raw)}
+		}
+		setGridParam(&params, field, v)
+	}
+	return params, nil
+}
+
+func (s *TaskServer) handleBacktest(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	q := r.URL.Query()
+	params, err := parseBacktestQuery(q)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	result, info, err := StockBacktest(r.Context(), code, q.Get("start"), q.Get("end"), params)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"stock_code": code, "params": params, "data_source": info.Source, "result": result})
+}
@@ -0,0 +1,204 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// blockingTransport 行情请求一直阻塞到请求 ctx 取消，模拟耗时分析，便于在运行中取消任务
+type blockingTransport struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case b.started <- struct{}{}:
+	default:
+	}
+	<-req.Context().Done()
+	select {
+	case b.release <- struct{}{}:
+	default:
+	}
+	return nil, req.Context().Err()
+}
+
+func doJSON(t *testing.T, h http.Handler, method, path, token string, body interface{}) (int, map[string]interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&buf).Encode(body)
+	}
+	req := httptest.NewRequest(method, path, &buf)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var out map[string]interface{}
+	json.Unmarshal(rec.Body.Bytes(), &out)
+	return rec.Code, out
+}
+
+func TestTaskServerRejectsInvalidRequests(t *testing.T) {
+	s := NewTaskServer("server-key", "deepseek-chat")
+	s.AuthToken = "token"
+	h := s.Handler()
+	for _, c := range []struct {
+		name string
+		req  AnalyzeRequest
+		want int
+	}{
+		{"路径穿越", AnalyzeRequest{StockCode: "../escaped"}, http.StatusBadRequest},
+		{"分隔符", AnalyzeRequest{StockCode: `a\b`}, http.StatusBadRequest},
+		{"点开头", AnalyzeRequest{StockCode: ".."}, http.StatusBadRequest},
+		{"过长", AnalyzeRequest{StockCode: "ABCDEFGHIJKLM"}, http.StatusBadRequest},
+		{"开始日期", AnalyzeRequest{StockCode: "600036", Start: "2024/01/01"}, http.StatusBadRequest},
+		{"结束日期", AnalyzeRequest{StockCode: "600036", End: "../x"}, http.StatusBadRequest},
+	} {
+		if code, _ := doJSON(t, h, "POST", "/analyze", "token", c.req); code != c.want {
+			t.Errorf("%s: 状态码 = %d，期望 %d", c.name, code, c.want)
+		}
+	}
+	// 未带令牌或令牌错误时不能使用服务默认 Key
+	for _, token := range []string{"", "wrong"} {
+		if code, _ := doJSON(t, h, "POST", "/analyze", token, AnalyzeRequest{StockCode: "600036"}); code != http.StatusUnauthorized {
+			t.Errorf("令牌 %q: 状态码 = %d，期望 401", token, code)
+		}
+	}
+	// 未配置 AuthToken 时任何令牌都不能使用默认 Key
+	s.AuthToken = ""
+	if code, _ := doJSON(t, h, "POST", "/analyze", "", AnalyzeRequest{StockCode: "600036"}); code != http.StatusUnauthorized {
+		t.Errorf("未配置令牌: 状态码 = %d，期望 401", code)
+	}
+}
+
+func TestTaskServerSubmitQueryCancel(t *testing.T) {
+	bt := &blockingTransport{started: make(chan struct{}, 1), release: make(chan struct{}, 1)}
+	oldTransport, oldCache, oldDB := FetchTransport, DataCacheDir, DBPath
+	FetchTransport, DataCacheDir, DBPath = bt, t.TempDir(), ""
+	defer func() { FetchTransport, DataCacheDir, DBPath = oldTransport, oldCache, oldDB }()
+
+	s := NewTaskServer("server-key", "deepseek-chat")
+	s.AuthToken = "token"
+	h := s.Handler()
+
+	code, body := doJSON(t, h, "POST", "/analyze", "token", AnalyzeRequest{StockCode: "600036", Start: "2024-01-01", End: "2024-06-30"})
+	if code != http.StatusAccepted {
+		t.Fatalf("提交状态码 = %d，期望 202: %v", code, body)
+	}
+	id, _ := body["task_id"].(string)
+	if id == "" {
+		t.Fatalf("未返回 task_id: %v", body)
+	}
+	select {
+	case <-bt.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("任务未开始获取行情")
+	}
+
+	code, body = doJSON(t, h, "GET", "/tasks/"+id, "", nil)
+	if code != http.StatusOK || body["status"] != string(TaskRunning) {
+		t.Errorf("查询 = %d %v，期望 200 running", code, body["status"])
+	}
+	code, body = doJSON(t, h, "DELETE", "/tasks/"+id, "", nil)
+	if code != http.StatusOK || body["status"] != string(TaskCanceled) {
+		t.Errorf("取消 = %d %v，期望 200 canceled", code, body["status"])
+	}
+	select {
+	case <-bt.release:
+	case <-time.After(5 * time.Second):
+		t.Fatal("取消后行情请求未中断")
+	}
+	if code, _ = doJSON(t, h, "DELETE", "/tasks/"+id, "", nil); code != http.StatusConflict {
+		t.Errorf("重复取消状态码 = %d，期望 409", code)
+	}
+	if code, _ = doJSON(t, h, "GET", "/tasks/nope", "", nil); code != http.StatusNotFound {
+		t.Errorf("查询不存在的任务状态码 = %d，期望 404", code)
+	}
+}
+
+func TestTaskManagerEvictsFinishedTasks(t *testing.T) {
+	old := TaskRetention
+	TaskRetention = 0
+	defer func() { TaskRetention = old }()
+
+	m := NewTaskManager()
+	first := m.Submit("600036", func(ctx context.Context) AnalysisResult { return AnalysisResult{Report: "ok"} })
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if t1, _ := m.Get(first.ID); t1.Status.Finished() {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("任务未结束")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	block := make(chan struct{})
+	defer close(block)
+	second := m.Submit("000001", func(ctx context.Context) AnalysisResult {
+		<-block
+		return AnalysisResult{}
+	})
+	if _, ok := m.Get(first.ID); ok {
+		t.Error("已结束且超过保留时间的任务应被清理")
+	}
+	m.Submit("000002", func(ctx context.Context) AnalysisResult { return AnalysisResult{} })
+	if _, ok := m.Get(second.ID); !ok {
+		t.Error("未结束的任务不应被清理")
+	}
+}
+
+func TestFileNameCode(t *testing.T) {
+	for in, want := range map[string]string{
+		"600036":     "600036",
+		"BRK.A":      "BRK.A",
+		"BTC-USD":    "BTC-USD",
+		"ETH/USDT":   "ETH_USDT",
+		"../escaped": "_escaped",
+		`..\x`:       "_x",
+		"..":         "_",
+	} {
+		if got := fileNameCode(in); got != want {
+			t.Errorf("fileNameCode(%q) = %q，期望 %q", in, got, want)
+		}
+	}
+}
+
+func TestBacktestEndpointReturnsJSON(t *testing.T) {
+	oldTransport, oldRetries, oldCache := FetchTransport, FetchMaxRetries, DataCacheDir
+	FetchTransport, FetchMaxRetries, DataCacheDir = failingTransport{}, 0, t.TempDir()
+	defer func() { FetchTransport, FetchMaxRetries, DataCacheDir = oldTransport, oldRetries, oldCache }()
+	if err := saveStockCache("600036", "测试", datedBars(200)); err != nil {
+		t.Fatal(err)
+	}
+	h := NewTaskServer("", "deepseek-chat").Handler()
+
+	code, body := doJSON(t, h, "GET", "/stocks/600036/backtest?strategy=ma_cross&fast=5&slow=20&stoploss=0.05", "", nil)
+	if code != http.StatusOK {
+		t.Fatalf("回测状态码 = %d: %v", code, body)
+	}
+	result, _ := body["result"].(map[string]interface{})
+	curve, _ := result["equity_curve"].([]interface{})
+	trades, _ := result["trade_history"].([]interface{})
+	if len(curve) == 0 || len(trades) == 0 {
+		t.Errorf("结果应含资金曲线与交易记录: %v", result)
+	}
+	params, _ := body["params"].(map[string]interface{})
+	if params["FastMAPeriod"] != 5.0 || params["SlowMAPeriod"] != 20.0 || params["StopLoss"] != 0.05 {
+		t.Errorf("查询参数未生效: %v", params)
+	}
+
+	for _, q := range []string{"strategy=foo", "fast=abc", "fast=30&slow=10", "stoploss=1.5", "cash=-1"} {
+		if code, body := doJSON(t, h, "GET", "/stocks/600036/backtest?"+q, "", nil); code != http.StatusBadRequest {
+			t.Errorf("%s: 状态码 = %d，期望 400（%v）", q, code, body)
+		}
+	}
+}
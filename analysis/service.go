@@ -0,0 +1,299 @@
+package analysis
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HTTP 与 gRPC 接口共用的业务层，接口层只做参数编解码与错误码映射
+
+// RequestError 请求参数不合法，HTTP 返回 400，gRPC 返回 InvalidArgument
+type RequestError struct {
+	Msg string
+}
+
+func (e *RequestError) Error() string { return e.Msg }
+
+// ErrNotFound 任务或股票的历史结论不存在，HTTP 返回 404，gRPC 返回 NotFound
+var ErrNotFound = errors.New("不存在")
+
+// ErrUnauthorized 未携带有效令牌却要使用服务默认 API Key，HTTP 返回 401，gRPC 返回 Unauthenticated
+var ErrUnauthorized = errors.New("未授权")
+
+// requestCodeRe 接口提交的股票代码：字母、数字与点，不以点开头，代码会拼进报告、图表、缓存等文件名
+var requestCodeRe = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.]{0,11}$`)
+
+// validateAnalyzeRequest 校验股票代码与 YYYY-MM-DD 日期，start/end 可为空
+func validateAnalyzeRequest(req AnalyzeRequest) error {
+	if req.StockCode == "" {
+		return &RequestError{"stock_code 不能为空"}
+	}
+	if !requestCodeRe.MatchString(req.StockCode) {
+		return &RequestError{fmt.Sprintf("stock_code 不合法: %q", req.StockCode)}
+	}
+	for _, d := range []struct{ name, value string }{{"start", req.Start}, {"end", req.End}} {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.Parse("2006-01-02", d.value); err != nil {
+			return &RequestError{fmt.Sprintf("%s 须为 YYYY-MM-DD 格式: %q", d.name, d.value)}
+		}
+	}
+	return nil
+}
+
+// authorized 令牌是否与服务配置一致，未配置 AuthToken 时任何请求都不能使用默认 API Key
+func (s *TaskServer) authorized(token string) bool {
+	return s.AuthToken != "" && subtle.ConstantTimeCompare([]byte(token), []byte(s.AuthToken)) == 1
+}
+
+// IsRequestError 是否为参数错误
+func IsRequestError(err error) bool {
+	var re *RequestError
+	return errors.As(err, &re)
+}
+
+// StockComparison 多股对比中单只股票的风险指标、最近一次分析结论与因子得分，行情获取失败时 Error 非空
+type StockComparison struct {
+	StockCode    string             `json:"stock_code"`
+	Risk         *RiskMetrics       `json:"risk,omitempty"`
+	DataSource   string             `json:"data_source,omitempty"`
+	Industry     string             `json:"industry,omitempty"` // 行业中性化打分时的所属行业
+	Prediction   *ConclusionRecord  `json:"prediction,omitempty"`
+	CapitalFlow  *CapitalFlow       `json:"capital_flow,omitempty"`  // 仅在使用资金面因子时获取，非 A 股或获取失败时为空
+	Score        float64            `json:"score"`                   // 因子加权综合得分（0-100）
+	FactorScores map[string]float64 `json:"factor_scores,omitempty"` // 各因子在对比组内归一化后的得分（0-100）
+	Error        string             `json:"error,omitempty"`
+}
+
+// SubmitAnalysis 校验请求并提交异步分析任务，model 为空时使用服务默认值；
+// api_key 为空时须携带与 AuthToken 一致的 token 才使用服务默认 Key，否则返回 ErrUnauthorized
+func (s *TaskServer) SubmitAnalysis(req AnalyzeRequest, token string) (Task, error) {
+	req.StockCode = strings.TrimSpace(req.StockCode)
+	if err := validateAnalyzeRequest(req); err != nil {
+		return Task{}, err
+	}
+	if req.APIKey == "" && s.APIKey != "" {
+		if !s.authorized(token) {
+			return Task{}, fmt.Errorf("使用服务默认 api_key 需要有效令牌: %w", ErrUnauthorized)
+		}
+		req.APIKey = s.APIKey
+	}
+	if req.Model == "" {
+		req.Model = s.Model
+	}
+	if req.APIKey == "" || req.Model == "" {
+		return Task{}, &RequestError{"未配置 api_key 或 model"}
+	}
+
+	params := AnalysisParams{
+		APIKey:       req.APIKey,
+		Model:        req.Model,
+		StockCodes:   []string{req.StockCode},
+		Start:        req.Start,
+		End:          req.End,
+		SearchMode:   req.Mode == "search",
+		HybridSearch: req.Mode == "hybrid",
+		Periods:      req.Periods,
+		Dims:         req.Dims,
+		Output:       req.Output,
+		Lang:         req.Lang,
+		Temperature:  req.Temperature,
+		MaxTokens:    req.MaxTokens,
+		APIURL:       s.APIURL,
+	}
+	task := s.Manager.Submit(req.StockCode, func(ctx context.Context) AnalysisResult {
+		return AnalyzeOne(ctx, params, func(stock, prompt, apiKey, apiURL string, model string, searchMode bool, hybridSearch bool) (string, error) {
+			return GenerateAIReportWithGeneration(ctx, stock, prompt, apiKey, apiURL, model, searchMode, hybridSearch, params.Generation())
+		})
+	})
+	return task, nil
+}
+
+// GetTask 查询任务，不存在时返回 ErrNotFound
+func (s *TaskServer) GetTask(id string) (Task, error) {
+	task, ok := s.Manager.Get(id)
+	if !ok {
+		return Task{}, fmt.Errorf("任务%w: %s", ErrNotFound, id)
+	}
+	return task, nil
+}
+
+// CancelTask 取消任务，不存在时返回 ErrNotFound，已结束时返回其他错误
+func (s *TaskServer) CancelTask(id string) (Task, error) {
+	if _, err := s.GetTask(id); err != nil {
+		return Task{}, err
+	}
+	return s.Manager.Cancel(id)
+}
+
+// LatestPrediction 股票最近一次分析的结论（立场、趋势、目标价与评分）
+func LatestPrediction(stockCode string) (ConclusionRecord, error) {
+	stockCode = NormalizeStockCode(stockCode)
+	if stockCode == "" {
+		return ConclusionRecord{}, &RequestError{"stock_code 不能为空"}
+	}
+	records, err := LoadConclusionRecords()
+	if err != nil && !os.IsNotExist(err) {
+		return ConclusionRecord{}, err
+	}
+	var latest *ConclusionRecord
+	for i := range records {
+		if records[i].StockCode == stockCode && (latest == nil || records[i].Time.After(latest.Time)) {
+			latest = &records[i]
+		}
+	}
+	if latest == nil {
+		return ConclusionRecord{}, fmt.Errorf("%s 的历史分析结论%w", stockCode, ErrNotFound)
+	}
+	return *latest, nil
+}
+
+// StockRisk 按区间获取行情（实时接口→本地缓存）并计算风险指标，ctx 取消时中止行情请求
+func StockRisk(ctx context.Context, stockCode, start, end string) (RiskMetrics, DataSourceInfo, error) {
+	stockCode = NormalizeStockCode(stockCode)
+	if stockCode == "" {
+		return RiskMetrics{}, DataSourceInfo{}, &RequestError{"stock_code 不能为空"}
+	}
+	stockData, _, info, err := FetchStockHistoryWithFallbackContext(ctx, stockCode, start, end, "")
+	if err != nil {
+		return RiskMetrics{}, info, err
+	}
+	return CalculateRiskMetrics(stockData, RiskFreeRate), info, nil
+}
+
+// StockMultiPeriodTrend 获取行情（实时接口→本地缓存）并计算短/中/长期趋势及一致性
+func StockMultiPeriodTrend(ctx context.Context, stockCode string) (MultiPeriodTrend, error) {
+	stockCode = NormalizeStockCode(stockCode)
+	if stockCode == "" {
+		return MultiPeriodTrend{}, &RequestError{"stock_code 不能为空"}
+	}
+	stockData, _, _, err := FetchStockHistoryWithFallbackContext(ctx, stockCode, "", "", "")
+	if err != nil {
+		return MultiPeriodTrend{}, err
+	}
+	return PredictMultiPeriod(stockData), nil
+}
+
+// StockMLPrediction 获取行情并给出决策树、随机森林与集成预测，相同行情的结果走 Redis 缓存
+func StockMLPrediction(ctx context.Context, stockCode string) ([]MLPrediction, DataSourceInfo, error) {
+	stockCode = NormalizeStockCode(stockCode)
+	if stockCode == "" {
+		return nil, DataSourceInfo{}, &RequestError{"stock_code 不能为空"}
+	}
+	stockData, indicators, info, err := FetchStockHistoryWithFallbackContext(ctx, stockCode, "", "", "")
+	if err != nil {
+		return nil, info, err
+	}
+	preds, err := PredictMLCached(ctx, stockCode, stockData, indicators)
+	if err != nil {
+		return nil, info, &RequestError{fmt.Sprintf("%s 无法进行机器学习预测: %v", stockCode, err)}
+	}
+	return preds, info, nil
+}
+
+// CompareConcurrency 对比时并发获取行情的股票数，避免同时请求过多被数据源限流
+var CompareConcurrency = 4
+
+// CompareStocks 并发获取各股风险指标与最近结论并按因子打分（factors 为空时用 DefaultFactors），可混合不同市场的代码；
+// industries（代码→行业）非空时按行业中性化打分，见 ResolveIndustries。
+// 单只行情获取失败时记录日志并在该股票的 Error 中标注，不影响其他股票；结果顺序与输入一致
+func CompareStocks(ctx context.Context, stockCodes []string, start, end string, factors []FactorWeight, industries map[string]string) ([]StockComparison, error) {
+	var codes []string
+	for _, c := range stockCodes {
+		if c = strings.TrimSpace(c); c != "" {
+			codes = append(codes, c)
+		}
+	}
+	if len(codes) < 2 {
+		return nil, &RequestError{"对比至少需要两只股票"}
+	}
+	if len(factors) == 0 {
+		factors = DefaultFactors
+	}
+	withFlow := needsCapitalFlow(factors)
+	concurrency := CompareConcurrency
+	if concurrency <= 0 || concurrency > len(codes) {
+		concurrency = len(codes)
+	}
+	// 每个 worker 只写自己下标的元素，无需加锁
+	list := make([]StockComparison, len(codes))
+	jobs := make(chan int, len(codes))
+	for i := range codes {
+		jobs <- i
+	}
+	close(jobs)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				list[i] = compareOne(ctx, codes[i], start, end, industries[codes[i]], withFlow)
+			}
+		}()
+	}
+	wg.Wait()
+	ScoreStocksByFactors(list, factors)
+	return list, nil
+}
+
+// compareOne 获取单只股票的风险指标、最近结论与（按需）资金流向
+func compareOne(ctx context.Context, code, start, end, industry string, withFlow bool) StockComparison {
+	item := StockComparison{StockCode: code, Industry: industry}
+	risk, info, err := StockRisk(ctx, code, start, end)
+	if err != nil {
+		log.Printf("[对比] %s（%s）行情获取失败: %v\n", code, StockMarket(NormalizeStockCode(code)), err)
+		item.Error = err.Error()
+	} else {
+		item.Risk = &risk
+		item.DataSource = info.Source
+	}
+	if rec, err := LatestPrediction(code); err == nil {
+		item.Prediction = &rec
+	}
+	if withFlow {
+		if flow, err := FetchCapitalFlow(ctx, code); err != nil {
+			log.Printf("[对比] %s 资金流向获取失败，不计资金面因子: %v\n", code, err)
+		} else {
+			item.CapitalFlow = &flow
+		}
+	}
+	return item
+}
+
+// backtestStrategies 支持的回测策略类型
+var backtestStrategies = map[string]bool{"ma_cross": true, "breakout": true, "rsi": true, "atr_trailing": true}
+
+// StockBacktest 按区间获取行情并按 params 回测，策略未知或参数组合不合法时返回 RequestError
+func StockBacktest(ctx context.Context, stockCode, start, end string, params BacktestParams) (BacktestResult, DataSourceInfo, error) {
+	stockCode = NormalizeStockCode(stockCode)
+	switch {
+	case stockCode == "":
+		return BacktestResult{}, DataSourceInfo{}, &RequestError{"stock_code 不能为空"}
+	case !backtestStrategies[params.StrategyType]:
+		return BacktestResult{}, DataSourceInfo{}, &RequestError{"不支持的策略: " + params.StrategyType}
+	case params.InitialCash <= 0:
+		return BacktestResult{}, DataSourceInfo{}, &RequestError{"初始资金必须大于 0"}
+	case params.StopLoss < 0 || params.StopLoss >= 1 || params.TakeProfit < 0:
+		return BacktestResult{}, DataSourceInfo{}, &RequestError{"止损须在 [0,1) 内，止盈不能为负"}
+	}
+	stockData, indicators, info, err := FetchStockHistoryWithFallbackContext(ctx, stockCode, start, end, "")
+	if err != nil {
+		return BacktestResult{}, info, err
+	}
+	stockData, _ = displayRange(stockData, indicators, info.WarmupBars)
+	if !validGridParams(params, len(stockData)) {
+		return BacktestResult{}, info, &RequestError{fmt.Sprintf("%s 策略参数不合法（行情 %d 条）：%s", params.StrategyType, len(stockData), strategyParamSummary(params))}
+	}
+	return BacktestStrategy(stockData, params), info, nil
+}
@@ -0,0 +1,215 @@
+package analysis
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ConclusionFile 历史结构化结论存储，每次分析追加一行 JSON
+var ConclusionFile = filepath.Join("history", "conclusions.jsonl")
+
+// conclusionMu 串行化结论追加写入
+var conclusionMu sync.Mutex
+
+// 结论立场
+const (
+	StanceBullish = "看多"
+	StanceBearish = "看空"
+	StanceNeutral = "中性"
+	StanceMissing = "缺失"
+)
+
+// ConclusionRecord 一次分析的结构化结论
+type ConclusionRecord struct {
+	StockCode      string             `json:"stock_code"`
+	Market         string             `json:"market"`   // A股/港股/美股
+	Industry       string             `json:"industry"` // 报告中“所属行业”，未提及为空
+	Stance         string             `json:"stance"`
+	Trend          string             `json:"trend,omitempty"`
+	Recommendation string             `json:"recommendation,omitempty"`
+	TargetPrice    float64            `json:"target_price,omitempty"`
+	CurrentPrice   float64            `json:"current_price,omitempty"`
+	SavedFile      string             `json:"saved_file,omitempty"`
+	Scores         map[string]float64 `json:"scores,omitempty"` // 各维度评分（0-100），用于行业均值对比
+	Model          string             `json:"model,omitempty"`  // 生成报告的模型，用于模型质量对比
+	Time           time.Time          `json:"time"`
+}
+
+// ConclusionStats 结论聚合统计
+type ConclusionStats struct {
+	Since      time.Time
+	Market     string // 为空表示全部市场
+	Total      int
+	Counts     map[string]int            // 立场 -> 次数
+	ByIndustry map[string]map[string]int // 行业 -> 立场 -> 次数
+}
+
+var (
+	industryRe   = regexp.MustCompile(keywordPattern(func(k reportKeywords) []string { return k.industry }) + `\s*[:：]\s*\**([^\s，,。；;|*]+)`)
+	neutralWords = []string{"中性", "震荡", "观望", "持有", "neutral", "hold"}
+)
+
+// conclusionStance 趋势优先、建议次之判断立场，均无方向时查找中性表述，否则记为缺失
+func conclusionStance(c StructuredConclusion, report string) string {
+	dir := textDirection(c.Trend, bullishWords, bearishWords)
+	if dir == 0 {
+		dir = textDirection(c.Recommendation, buyWords, sellWords)
+	}
+	switch {
+	case dir > 0:
+		return StanceBullish
+	case dir < 0:
+		return StanceBearish
+	}
+	lower := strings.ToLower(report)
+	for _, w := range neutralWords {
+		if strings.Contains(lower, w) {
+			return StanceNeutral
+		}
+	}
+	return StanceMissing
+}
+
+// NewConclusionRecord 从报告抽取结构化结论
+func NewConclusionRecord(stockCode, savedFile, report string, currentPrice float64, at time.Time) ConclusionRecord {
+	c := ExtractConclusion(report, currentPrice)
+	rec := ConclusionRecord{
+		StockCode:      stockCode,
+		Market:         StockMarket(stockCode),
+		Stance:         conclusionStance(c, report),
+		Trend:          c.Trend,
+		Recommendation: c.Recommendation,
+		TargetPrice:    c.TargetPrice,
+		CurrentPrice:   currentPrice,
+		SavedFile:      savedFile,
+		Time:           at,
+	}
+	rec.Industry = ReportIndustry(report)
+	return rec
+}
+
+// ReportIndustry 报告中提及的所属行业，未提及返回空
+func ReportIndustry(report string) string {
+	if m := industryRe.FindStringSubmatch(report); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// AppendConclusionRecord 追加一条结构化结论到 ConclusionFile
+func AppendConclusionRecord(rec ConclusionRecord) error {
+	conclusionMu.Lock()
+	defer conclusionMu.Unlock()
+	if err := os.MkdirAll(filepath.Dir(ConclusionFile), 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(ConclusionFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// LoadConclusionRecords 读取全部结构化结论，跳过无法解析的行
+func LoadConclusionRecords() ([]ConclusionRecord, error) {
+	f, err := os.Open(ConclusionFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var records []ConclusionRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec ConclusionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// SummarizeConclusions 统计 since 之后、指定市场（为空表示全部）的立场分布，结论缺失单独计数，未提及行业归为“未知行业”
+func SummarizeConclusions(records []ConclusionRecord, since time.Time, market string) ConclusionStats {
+	stats := ConclusionStats{
+		Since:      since,
+		Market:     market,
+		Counts:     make(map[string]int),
+		ByIndustry: make(map[string]map[string]int),
+	}
+	for _, rec := range records {
+		if rec.Time.Before(since) || (market != "" && rec.Market != market) {
+			continue
+		}
+		stance := rec.Stance
+		if stance == "" {
+			stance = StanceMissing
+		}
+		industry := rec.Industry
+		if industry == "" {
+			industry = "未知行业"
+		}
+		stats.Total++
+		stats.Counts[stance]++
+		if stats.ByIndustry[industry] == nil {
+			stats.ByIndustry[industry] = make(map[string]int)
+		}
+		stats.ByIndustry[industry][stance]++
+	}
+	return stats
+}
+
+// Ratio 某立场占比（0-1）
+func (s ConclusionStats) Ratio(stance string) float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Counts[stance]) / float64(s.Total)
+}
+
+// FormatConclusionStats 结论统计 markdown 报告
+func FormatConclusionStats(s ConclusionStats) string {
+	market := s.Market
+	if market == "" {
+		market = "全部市场"
+	}
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("\n【结论统计】%s，%s 至今共 %d 份报告\n", market, s.Since.Format("2006-01-02"), s.Total))
+	if s.Total == 0 {
+		return b.String()
+	}
+	stances := []string{StanceBullish, StanceBearish, StanceNeutral, StanceMissing}
+	b.WriteString("| 看多 | 看空 | 中性 | 缺失 |\n|---|---|---|---|\n|")
+	for _, st := range stances {
+		b.WriteString(fmt.Sprintf(" %d (%.1f%%) |", s.Counts[st], s.Ratio(st)*100))
+	}
+	b.WriteString("\n\n| 行业 | 看多 | 看空 | 中性 | 缺失 |\n|---|---|---|---|---|\n")
+	industries := make([]string, 0, len(s.ByIndustry))
+	for ind := range s.ByIndustry {
+		industries = append(industries, ind)
+	}
+	sort.Strings(industries)
+	for _, ind := range industries {
+		b.WriteString("| " + ind + " |")
+		for _, st := range stances {
+			b.WriteString(fmt.Sprintf(" %d |", s.ByIndustry[ind][st]))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,83 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewConclusionRecordStance(t *testing.T) {
+	at := time.Date(2024, 6, 3, 15, 0, 0, 0, time.Local)
+	for _, c := range []struct {
+		code, report, stance, industry string
+	}{
+		{"600036", "所属行业：银行\n趋势判断：看涨\n操作建议：买入\n目标价：40", StanceBullish, "银行"},
+		{"000001", "趋势判断：方向不明\n操作建议：逢高减持", StanceBearish, ""},
+		{"00700", "所处行业：**互联网**\n短期以震荡为主", StanceNeutral, "互联网"},
+		{"AAPL", "接口返回为空", StanceMissing, ""},
+	} {
+		rec := NewConclusionRecord(c.code, c.code+".md", c.report, 35, at)
+		if rec.Stance != c.stance || rec.Industry != c.industry || rec.Market != StockMarket(c.code) || !rec.Time.Equal(at) {
+			t.Errorf("%s: 立场 %q 行业 %q 市场 %q，期望 %q / %q", c.code, rec.Stance, rec.Industry, rec.Market, c.stance, c.industry)
+		}
+	}
+}
+
+func TestSummarizeConclusionsFromStore(t *testing.T) {
+	old := ConclusionFile
+	ConclusionFile = filepath.Join(t.TempDir(), "history", "conclusions.jsonl")
+	defer func() { ConclusionFile = old }()
+
+	since := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local)
+	for _, rec := range []ConclusionRecord{
+		{StockCode: "600036", Market: MarketA, Industry: "银行", Stance: StanceBullish, Time: since.AddDate(0, 0, 1)},
+		{StockCode: "601398", Market: MarketA, Industry: "银行", Stance: StanceBearish, Time: since.AddDate(0, 0, 2)},
+		{StockCode: "000001", Market: MarketA, Stance: StanceBullish, Time: since.AddDate(0, 0, 3)},
+		{StockCode: "000002", Market: MarketA, Time: since.AddDate(0, 0, 4)}, // 旧记录无立场，按缺失计
+		{StockCode: "600519", Market: MarketA, Stance: StanceBearish, Time: since.AddDate(0, 0, -1)},
+		{StockCode: "AAPL", Market: MarketUS, Stance: StanceBullish, Time: since.AddDate(0, 0, 1)},
+	} {
+		if err := AppendConclusionRecord(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f, err := os.OpenFile(ConclusionFile, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("{损坏的行\n")
+	f.Close()
+
+	records, err := LoadConclusionRecords()
+	if err != nil || len(records) != 6 {
+		t.Fatalf("读取结论: %d 条, err=%v", len(records), err)
+	}
+	stats := SummarizeConclusions(records, since, MarketA)
+	if stats.Total != 4 || stats.Counts[StanceBullish] != 2 || stats.Counts[StanceBearish] != 1 || stats.Counts[StanceMissing] != 1 {
+		t.Errorf("A 股统计 = %+v", stats)
+	}
+	if stats.Ratio(StanceBullish) != 0.5 {
+		t.Errorf("看多占比 = %v", stats.Ratio(StanceBullish))
+	}
+	if bank := stats.ByIndustry["银行"]; bank[StanceBullish] != 1 || bank[StanceBearish] != 1 {
+		t.Errorf("银行业分布 = %v", bank)
+	}
+	if unknown := stats.ByIndustry["未知行业"]; unknown[StanceBullish] != 1 || unknown[StanceMissing] != 1 {
+		t.Errorf("未提及行业应归为未知行业: %v", unknown)
+	}
+	if all := SummarizeConclusions(records, since, ""); all.Total != 5 {
+		t.Errorf("全部市场应统计 5 份，得到 %d", all.Total)
+	}
+
+	table := FormatConclusionStats(stats)
+	for _, want := range []string{"【结论统计】A股，2024-06-01 至今共 4 份报告", "| 2 (50.0%) | 1 (25.0%) | 0 (0.0%) | 1 (25.0%) |", "| 银行 | 1 | 1 | 0 | 0 |"} {
+		if !strings.Contains(table, want) {
+			t.Errorf("统计报告缺少 %q:\n%s", want, table)
+		}
+	}
+	if empty := SummarizeConclusions(nil, since, ""); empty.Ratio(StanceBullish) != 0 || strings.Contains(FormatConclusionStats(empty), "|") {
+		t.Error("无记录时占比应为 0 且不输出表格")
+	}
+}
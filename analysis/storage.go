@@ -0,0 +1,77 @@
+package analysis
+
+import (
+	"path/filepath"
+	"sync"
+	"time"
+
+	"Quantix/storage"
+)
+
+// DBPath 分析结果 SQLite 库路径，每次分析成功后写入元信息；为空时不写库
+var DBPath = filepath.Join("history", "quantix.db")
+
+var (
+	analysisDBMu   sync.Mutex
+	analysisDB     *storage.DB
+	analysisDBPath string
+)
+
+// openAnalysisDB 按 DBPath 复用已打开的库，路径变化时重新打开；调用方须持有 analysisDBMu
+func openAnalysisDB() (*storage.DB, error) {
+	if analysisDB != nil && analysisDBPath == DBPath {
+		return analysisDB, nil
+	}
+	if analysisDB != nil {
+		analysisDB.Close()
+		analysisDB = nil
+	}
+	db, err := storage.Open(DBPath)
+	if err != nil {
+		return nil, err
+	}
+	analysisDB, analysisDBPath = db, DBPath
+	return db, nil
+}
+
+// SaveAnalysisRecord 写入一条分析元信息，DBPath 为空时跳过
+func SaveAnalysisRecord(a storage.Analysis) error {
+	if DBPath == "" {
+		return nil
+	}
+	analysisDBMu.Lock()
+	defer analysisDBMu.Unlock()
+	db, err := openAnalysisDB()
+	if err != nil {
+		return err
+	}
+	_, err = db.SaveAnalysis(a)
+	return err
+}
+
+// QueryAnalyses 按条件查询 DBPath 中的分析记录，按时间倒序
+func QueryAnalyses(filter storage.Filter) ([]storage.Analysis, error) {
+	if DBPath == "" {
+		return nil, nil
+	}
+	analysisDBMu.Lock()
+	defer analysisDBMu.Unlock()
+	db, err := openAnalysisDB()
+	if err != nil {
+		return nil, err
+	}
+	return db.QueryAnalyses(filter)
+}
+
+// newAnalysisRecord 由报告结论构造写库记录，置信度取报告中首个“置信度 xx%”
+func newAnalysisRecord(stockCode, model, savedFile, report, riskLevel string, conclusion StructuredConclusion, at time.Time) storage.Analysis {
+	return storage.Analysis{
+		StockCode:  stockCode,
+		Time:       at,
+		Model:      model,
+		Trend:      directionText(textDirection(conclusion.Trend, bullishWords, bearishWords)),
+		Confidence: matchFloat(confidenceRe, report),
+		RiskLevel:  riskLevel,
+		ReportFile: savedFile,
+	}
+}
@@ -0,0 +1,100 @@
+package analysis
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// sseDone SSE 流结束标记
+const sseDone = "[DONE]"
+
+type streamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// GenerateAIReportStream 以 stream 模式调用 DeepSeek，每收到一段正文增量即回调 onChunk，返回累积的完整文本；
+// ctx 取消时中止请求与读流
+func GenerateAIReportStream(ctx context.Context, stock, prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool, cfg GenerationConfig, onChunk func(string)) (string, error) {
+	if err := CheckLLMAllowed(apiURL); err != nil {
+		return "", err
+	}
+	body := deepSeekChatBody(prompt, model, searchMode, hybridSearch, cfg)
+	body["stream"] = true
+	data, _ := json.Marshal(body)
+	resp, err := postLLMWithRetry(ctx, http.DefaultClient, apiURL, data, map[string]string{
+		"Authorization": "Bearer " + apiKey,
+		"Content-Type":  "application/json",
+		"Accept":        "text/event-stream",
+	})
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		respData, _ := ioutil.ReadAll(resp.Body)
+		return "", fmt.Errorf("DeepSeek API 错误: %s", string(respData))
+	}
+	report, err := readSSEStream(resp.Body, onChunk)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return report, ctxErr
+	}
+	if err != nil {
+		return report, err
+	}
+	if strings.TrimSpace(report) == "" {
+		return "", fmt.Errorf("DeepSeek API 无返回内容")
+	}
+	return report, nil
+}
+
+// readSSEStream 逐行解析 SSE：只处理 data: 行，忽略注释与空行，遇到 [DONE] 结束；
+// bufio 按行读取，网络分包造成的半行会缓冲到换行后再解析，末尾无换行的最后一行同样处理
+func readSSEStream(r io.Reader, onChunk func(string)) (string, error) {
+	reader := bufio.NewReader(r)
+	var full strings.Builder
+	for {
+		line, readErr := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "data:") {
+			payload := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+			if payload == sseDone {
+				return full.String(), nil
+			}
+			var chunk streamChunk
+			if err := json.Unmarshal([]byte(payload), &chunk); err != nil {
+				return full.String(), fmt.Errorf("解析流式响应失败: %v", err)
+			}
+			if chunk.Error != nil {
+				return full.String(), fmt.Errorf("DeepSeek API 错误: %s", chunk.Error.Message)
+			}
+			for _, c := range chunk.Choices {
+				if c.Delta.Content == "" {
+					continue
+				}
+				full.WriteString(c.Delta.Content)
+				if onChunk != nil {
+					onChunk(c.Delta.Content)
+				}
+			}
+		}
+		if readErr == io.EOF {
+			return full.String(), nil
+		}
+		if readErr != nil {
+			return full.String(), fmt.Errorf("读取流式响应中断: %v", readErr)
+		}
+	}
+}
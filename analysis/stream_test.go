@@ -0,0 +1,60 @@
+package analysis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGenerateAIReportStreamCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"第一段\"}}]}\n\n")
+		w.(http.Flusher).Flush()
+		// 不再发送后续内容，直到客户端断开
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := GenerateAIReportStream(ctx, "600036", "prompt", "key", srv.URL, "deepseek-chat", false, false, GenerationConfig{}, func(string) {
+			cancel()
+		})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("取消后应返回 context.Canceled，实际 %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ctx 取消后流式请求未结束")
+	}
+}
+
+func TestFetchCapitalFlowCanceled(t *testing.T) {
+	var hits int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+	}))
+	defer srv.Close()
+	old := EastMoneyFlowURL
+	EastMoneyFlowURL = srv.URL + "/?secids=%s"
+	defer func() { EastMoneyFlowURL = old }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := FetchCapitalFlow(ctx, "600036"); !errors.Is(err, context.Canceled) {
+		t.Errorf("已取消的 ctx 应返回 context.Canceled，实际 %v", err)
+	}
+	if hits != 0 {
+		t.Errorf("已取消的 ctx 不应发出请求，实际 %d 次", hits)
+	}
+}
@@ -0,0 +1,121 @@
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// SubscriptionFile 邮件订阅配置，格式 [{"email": "a@example.com", "stocks": ["600519", "AAPL"]}]，stocks 含 "*" 表示订阅全部
+var SubscriptionFile = "subscriptions.json"
+
+// Subscription 一个收件人订阅的股票列表
+type Subscription struct {
+	Email  string   `json:"email"`
+	Stocks []string `json:"stocks"`
+}
+
+// SubscriptionMail 按订阅关系分发给单个收件人的邮件
+type SubscriptionMail struct {
+	To      string
+	Subject string
+	Body    string
+	Stocks  []string
+}
+
+// LoadSubscriptions 读取订阅配置，忽略邮箱或股票列表为空的条目
+func LoadSubscriptions(path string) ([]Subscription, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw []Subscription
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("解析订阅配置 %s 失败: %v", path, err)
+	}
+	var subs []Subscription
+	for _, s := range raw {
+		s.Email = strings.TrimSpace(s.Email)
+		if s.Email != "" && len(s.Stocks) > 0 {
+			subs = append(subs, s)
+		}
+	}
+	return subs, nil
+}
+
+// subscribes 订阅是否包含该股票，代码不区分大小写
+func (s Subscription) subscribes(stockCode string) bool {
+	for _, code := range s.Stocks {
+		code = strings.TrimSpace(code)
+		if code == "*" || strings.EqualFold(code, stockCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// PlanSubscriptionMails 按订阅关系为每个收件人汇总其订阅股票的成功报告，同一股票多种模式的报告都会附上；
+// 同一邮箱多条订阅合并，本轮没有相关报告的收件人不发送
+func PlanSubscriptionMails(subs []Subscription, results []AnalysisResult) []SubscriptionMail {
+	var order []string
+	byEmail := make(map[string][]Subscription)
+	for _, s := range subs {
+		key := strings.ToLower(s.Email)
+		if _, ok := byEmail[key]; !ok {
+			order = append(order, key)
+		}
+		byEmail[key] = append(byEmail[key], s)
+	}
+
+	var mails []SubscriptionMail
+	for _, key := range order {
+		group := byEmail[key]
+		var stocks, sections []string
+		seen := make(map[string]bool)
+		for _, r := range results {
+			if r.Err != nil {
+				continue
+			}
+			matched := false
+			for _, s := range group {
+				if s.subscribes(r.StockCode) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+			if !seen[r.StockCode] {
+				seen[r.StockCode] = true
+				stocks = append(stocks, r.StockCode)
+			}
+			sections = append(sections, fmt.Sprintf("# [%s] AI 智能分析报告\n\n%s", r.StockCode, r.Report))
+		}
+		if len(sections) == 0 {
+			continue
+		}
+		mails = append(mails, SubscriptionMail{
+			To:      group[0].Email,
+			Subject: fmt.Sprintf("Quantix分析报告（%s）", strings.Join(stocks, "、")),
+			Body:    strings.Join(sections, "\n\n---\n\n"),
+			Stocks:  stocks,
+		})
+	}
+	return mails
+}
+
+// DispatchSubscriptions 按订阅关系逐个收件人发送，单个收件人失败不影响其他人，返回成功数与失败原因
+func DispatchSubscriptions(subs []Subscription, results []AnalysisResult, send func(to []string, subject, body string) error) (int, []error) {
+	sent := 0
+	var errs []error
+	for _, m := range PlanSubscriptionMails(subs, results) {
+		if err := send([]string{m.To}, m.Subject, m.Body); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", m.To, err))
+			continue
+		}
+		sent++
+	}
+	return sent, errs
+}
@@ -0,0 +1,71 @@
+package analysis
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadSubscriptionsSkipsIncomplete(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subscriptions.json")
+	data := `[{"email": " a@example.com ", "stocks": ["600036"]}, {"email": "", "stocks": ["AAPL"]}, {"email": "b@example.com", "stocks": []}]`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	subs, err := LoadSubscriptions(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || subs[0].Email != "a@example.com" {
+		t.Errorf("订阅 = %+v，期望仅保留 a@example.com", subs)
+	}
+}
+
+func TestDispatchSubscriptionsMultipleRecipients(t *testing.T) {
+	subs := []Subscription{
+		{Email: "a@example.com", Stocks: []string{"600036", "aapl"}},
+		{Email: "b@example.com", Stocks: []string{"*"}},
+		{Email: "A@example.com", Stocks: []string{"000001"}}, // 同一邮箱的另一条订阅合并
+		{Email: "c@example.com", Stocks: []string{"TSLA"}},   // 本轮无报告，不发送
+		{Email: "d@example.com", Stocks: []string{"MSFT"}},
+	}
+	results := []AnalysisResult{
+		{StockCode: "600036", Report: "招行报告"},
+		{StockCode: "AAPL", Report: "苹果报告"},
+		{StockCode: "000001", Report: "平安报告"},
+		{StockCode: "MSFT", Err: errors.New("失败")},
+	}
+
+	got := make(map[string]string)
+	sent, errs := DispatchSubscriptions(subs, results, func(to []string, subject, body string) error {
+		if to[0] == "b@example.com" {
+			return errors.New("SMTP 拒绝")
+		}
+		got[to[0]] = subject + "\n" + body
+		return nil
+	})
+	if sent != 1 || len(errs) != 1 || !strings.Contains(errs[0].Error(), "b@example.com") {
+		t.Fatalf("成功 %d、失败 %v，期望 a 成功、b 失败", sent, errs)
+	}
+	mailA := got["a@example.com"]
+	for _, want := range []string{"600036、AAPL、000001", "招行报告", "苹果报告", "平安报告"} {
+		if !strings.Contains(mailA, want) {
+			t.Errorf("a 的邮件缺少 %q:\n%s", want, mailA)
+		}
+	}
+
+	mails := PlanSubscriptionMails(subs, results)
+	var to []string
+	for _, m := range mails {
+		to = append(to, m.To)
+	}
+	if want := []string{"a@example.com", "b@example.com"}; !reflect.DeepEqual(to, want) {
+		t.Errorf("收件人 = %v，期望 %v（失败的股票与无报告的订阅不发送）", to, want)
+	}
+	if want := []string{"600036", "AAPL", "000001"}; !reflect.DeepEqual(mails[1].Stocks, want) {
+		t.Errorf("订阅全部的收件人股票 = %v，期望 %v", mails[1].Stocks, want)
+	}
+}
@@ -0,0 +1,263 @@
+package analysis
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var tableSeparatorCellRe = regexp.MustCompile(`^:?-{1,}:?$`)
+
+// tableRepairMaxBadRatio 列数与表头不一致的数据行超过该比例视为无法修复，降级为代码块
+const tableRepairMaxBadRatio = 0.5
+
+// splitTableRow 拆分表格行单元格，去掉首尾竖线，保留转义的 \|
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+	var cells []string
+	var cur strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) && line[i+1] == '|' {
+			cur.WriteString(`\|`)
+			i++
+			continue
+		}
+		if line[i] == '|' {
+			cells = append(cells, strings.TrimSpace(cur.String()))
+			cur.Reset()
+			continue
+		}
+		cur.WriteByte(line[i])
+	}
+	return append(cells, strings.TrimSpace(cur.String()))
+}
+
+// isSeparatorRow 是否为 |---|:---:| 形式的分隔行
+func isSeparatorRow(cells []string) bool {
+	nonEmpty := 0
+	for _, c := range cells {
+		c = strings.ReplaceAll(c, " ", "")
+		if c == "" {
+			continue
+		}
+		if !tableSeparatorCellRe.MatchString(c) {
+			return false
+		}
+		nonEmpty++
+	}
+	return nonEmpty > 0
+}
+
+// repairTable 规整一个表格块：补齐/修复分隔行，缺表头时补“列N”，单元格不足补空、多出的末尾空单元格去掉；
+// repaired 表示结构有改动，无法修复时返回 ok=false
+func repairTable(lines []string) (fixed string, repaired bool, ok bool) {
+	var rows [][]string
+	var align []string
+	separators := 0
+	for i, l := range lines {
+		cells := splitTableRow(l)
+		if isSeparatorRow(cells) {
+			if align == nil {
+				align = cells
+			}
+			separators++
+			if i != 1 {
+				repaired = true // 分隔行错位
+			}
+			continue // 分隔行统一重新生成，错位或重复的分隔行直接丢弃
+		}
+		for len(cells) > 1 && cells[len(cells)-1] == "" {
+			cells = cells[:len(cells)-1]
+			repaired = true
+		}
+		rows = append(rows, cells)
+	}
+	if separators != 1 {
+		repaired = true
+	}
+
+	headerMissing := len(lines) > 0 && isSeparatorRow(splitTableRow(lines[0]))
+	cols := 0
+	for _, r := range rows {
+		if len(r) > cols {
+			cols = len(r)
+		}
+	}
+	if cols < 2 || len(rows) == 0 {
+		return "", false, false
+	}
+	var header []string
+	body := rows
+	if headerMissing {
+		for i := 1; i <= cols; i++ {
+			header = append(header, fmt.Sprintf("列%d", i))
+		}
+	} else {
+		header, body = rows[0], rows[1:]
+	}
+	bad := 0
+	for _, r := range body {
+		if len(r) != len(header) {
+			bad++
+		}
+	}
+	if len(body) > 0 && float64(bad)/float64(len(body)) > tableRepairMaxBadRatio {
+		return "", false, false
+	}
+	if bad > 0 || len(header) < cols {
+		repaired = true
+	}
+	for len(header) < cols {
+		header = append(header, fmt.Sprintf("列%d", len(header)+1))
+	}
+
+	var b strings.Builder
+	writeRow := func(cells []string) {
+		b.WriteString("|")
+		for i := 0; i < cols; i++ {
+			c := ""
+			if i < len(cells) {
+				c = cells[i]
+			}
+			b.WriteString(" " + c + " |")
+		}
+		b.WriteString("\n")
+	}
+	writeRow(header)
+	b.WriteString("|")
+	for i := 0; i < cols; i++ {
+		sep := "---"
+		if i < len(align) {
+			a := strings.ReplaceAll(align[i], " ", "")
+			switch {
+			case strings.HasPrefix(a, ":") && strings.HasSuffix(a, ":") && len(a) > 1:
+				sep = ":---:"
+			case strings.HasPrefix(a, ":"):
+				sep = ":---"
+			case strings.HasSuffix(a, ":"):
+				sep = "---:"
+			}
+		}
+		b.WriteString(sep + "|")
+	}
+	b.WriteString("\n")
+	for _, r := range body {
+		writeRow(r)
+	}
+	return b.String(), repaired, true
+}
+
+// RepairMarkdownTables 校验并修复报告中的 markdown 表格（列数不齐、缺分隔行、缺表头），
+// 无法修复的表格降级为代码块原样展示；代码块内的内容不处理。返回修复后的文本与改动的表格数
+func RepairMarkdownTables(md string) (string, int) {
+	lines := strings.Split(md, "\n")
+	var out []string
+	changed := 0
+	inFence := false
+	flush := func(block []string) {
+		if len(block) < 2 {
+			out = append(out, block...)
+			return
+		}
+		fixed, repaired, ok := repairTable(block)
+		switch {
+		case !ok:
+			out = append(out, "```")
+			out = append(out, block...)
+			out = append(out, "```")
+			changed++
+		case repaired:
+			out = append(out, strings.Split(strings.TrimSuffix(fixed, "\n"), "\n")...)
+			changed++
+		default:
+			out = append(out, block...)
+		}
+	}
+	var block []string
+	for _, l := range lines {
+		trimmed := strings.TrimSpace(l)
+		if strings.HasPrefix(trimmed, "```") {
+			flush(block)
+			block = nil
+			inFence = !inFence
+			out = append(out, l)
+			continue
+		}
+		if !inFence && strings.HasPrefix(trimmed, "|") {
+			block = append(block, trimmed)
+			continue
+		}
+		flush(block)
+		block = nil
+		out = append(out, l)
+	}
+	flush(block)
+	return strings.Join(out, "\n"), changed
+}
+
+// Table 报告中的一张 markdown 表格，Rows 每行单元格数与 Header 一致（不足补空、多余截断），\| 已还原为竖线
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// Column 返回表头包含 keyword 的第一列下标，不存在时返回 -1
+func (t Table) Column(keyword string) int {
+	for i, h := range t.Header {
+		if strings.Contains(h, keyword) {
+			return i
+		}
+	}
+	return -1
+}
+
+// ParseMarkdownTables 解析文本中所有“表头行 + 分隔行 + 数据行”形式的 markdown 表格，
+// 表格前后的文字与代码块内的内容忽略；没有分隔行的竖线块不视为表格
+func ParseMarkdownTables(md string) []Table {
+	var tables []Table
+	var block []string
+	flush := func() {
+		if len(block) >= 2 && isSeparatorRow(splitTableRow(block[1])) {
+			header := unescapeTableCells(splitTableRow(block[0]))
+			t := Table{Header: header}
+			for _, l := range block[2:] {
+				cells := splitTableRow(l)
+				if isSeparatorRow(cells) {
+					continue
+				}
+				row := make([]string, len(header))
+				copy(row, unescapeTableCells(cells))
+				t.Rows = append(t.Rows, row)
+			}
+			tables = append(tables, t)
+		}
+		block = nil
+	}
+	inFence := false
+	for _, l := range strings.Split(md, "\n") {
+		trimmed := strings.TrimSpace(l)
+		if strings.HasPrefix(trimmed, "```") {
+			flush()
+			inFence = !inFence
+			continue
+		}
+		if !inFence && strings.HasPrefix(trimmed, "|") {
+			block = append(block, trimmed)
+			continue
+		}
+		flush()
+	}
+	flush()
+	return tables
+}
+
+func unescapeTableCells(cells []string) []string {
+	for i, c := range cells {
+		cells[i] = strings.ReplaceAll(c, `\|`, "|")
+	}
+	return cells
+}
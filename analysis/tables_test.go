@@ -0,0 +1,69 @@
+package analysis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRepairMarkdownTables(t *testing.T) {
+	for _, c := range []struct {
+		name, in, want string
+		changed        int
+	}{
+		{"格式正确", "| A | B |\n|---|---|\n| 1 | 2 |", "| A | B |\n|---|---|\n| 1 | 2 |", 0},
+		{"缺分隔行", "前言\n| 指标 | 数值 |\n| RSI | 62 |\n结尾", "前言\n| 指标 | 数值 |\n|---|---|\n| RSI | 62 |\n结尾", 1},
+		{"单元格不足补空并保留对齐", "| A | B | C |\n|:---|---:|:-:|\n| 1 | 2 |\n| 3 | 4 | 5 |", "| A | B | C |\n|:---|---:|:---:|\n| 1 | 2 |  |\n| 3 | 4 | 5 |", 1},
+		{"缺表头", "|---|---|\n| 1 | 2 |\n| 3 | 4 |", "| 列1 | 列2 |\n|---|---|\n| 1 | 2 |\n| 3 | 4 |", 1},
+		{"末尾空单元格", "| A | B | |\n|---|---|---|\n| 1 | 2 |", "| A | B |\n|---|---|\n| 1 | 2 |", 1},
+		{"重复分隔行", "| A | B |\n|---|---|\n| 1 | 2 |\n|---|---|\n| 3 | 4 |", "| A | B |\n|---|---|\n| 1 | 2 |\n| 3 | 4 |", 1},
+		{"转义竖线", "| 公式 | 说明 |\n| a\\|b | 或 |", "| 公式 | 说明 |\n|---|---|\n| a\\|b | 或 |", 1},
+		{"多数行列数不齐降级为代码块", "| A | B |\n|---|---|\n| 1 |\n| 2 | 3 | 4 |", "```\n| A | B |\n|---|---|\n| 1 |\n| 2 | 3 | 4 |\n```", 1},
+		{"单列降级为代码块", "| 只有一列 |\n| 数据 |", "```\n| 只有一列 |\n| 数据 |\n```", 1},
+		{"代码块内不处理", "```\n| A |\n| B | C |\n```", "```\n| A |\n| B | C |\n```", 0},
+	} {
+		got, n := RepairMarkdownTables(c.in)
+		if got != c.want || n != c.changed {
+			t.Errorf("%s: 修复结果 (%d 处)\n%s\n期望 (%d 处)\n%s", c.name, n, got, c.changed, c.want)
+		}
+	}
+}
+
+func TestConvertMarkdownTablesRepairsBeforeRendering(t *testing.T) {
+	out := convertMarkdownTablesToHTML("| 指标 | 数值 |\n| RSI | 62 |\n| MACD |\n")
+	for _, want := range []string{"<th>指标</th><th>数值</th>", "<td>RSI</td><td>62</td>", "<td>MACD</td><td></td>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("缺分隔行的表格应修复后渲染，缺少 %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestParseMarkdownTables(t *testing.T) {
+	md := "## 技术面\n以下为关键指标：\n" +
+		"|  指标   |  数值  | 信号 |\n" +
+		"| :------ | -----: | :--: |\n" +
+		"|  RSI    |   62   | 中性 |\n" +
+		"|  MACD   |        | 金叉 |\n" +
+		"| KDJ | 80 |\n" +
+		"表格后的说明文字\n\n" +
+		"```\n| 代码 | 块 |\n|---|---|\n| 不 | 解析 |\n```\n" +
+		"| 没有 | 分隔行 |\n| 不是 | 表格 |\n\n" +
+		"| 日期 | 收盘价 | 备注 |\n|---|---|---|\n| 2024-06-28 | 38.20 | a\\|b | 多余列 |\n"
+	tables := ParseMarkdownTables(md)
+	if len(tables) != 2 {
+		t.Fatalf("应解析出 2 张表格，得到 %d: %+v", len(tables), tables)
+	}
+	want := []Table{
+		{Header: []string{"指标", "数值", "信号"}, Rows: [][]string{{"RSI", "62", "中性"}, {"MACD", "", "金叉"}, {"KDJ", "80", ""}}},
+		{Header: []string{"日期", "收盘价", "备注"}, Rows: [][]string{{"2024-06-28", "38.20", "a|b"}}},
+	}
+	if !reflect.DeepEqual(tables, want) {
+		t.Errorf("解析结果 = %q\n期望 %q", tables, want)
+	}
+	if tables[1].Column("收盘") != 1 || tables[1].Column("成交量") != -1 {
+		t.Errorf("Column 应按表头关键词定位列")
+	}
+	if got := ParseMarkdownTables("纯文本，没有表格"); got != nil {
+		t.Errorf("无表格时应返回空: %+v", got)
+	}
+}
@@ -0,0 +1,142 @@
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TaskStatus 分析任务状态：pending → running → succeeded/failed，pending/running 可转为 canceled
+type TaskStatus string
+
+const (
+	TaskPending   TaskStatus = "pending"
+	TaskRunning   TaskStatus = "running"
+	TaskSucceeded TaskStatus = "succeeded"
+	TaskFailed    TaskStatus = "failed"
+	TaskCanceled  TaskStatus = "canceled"
+)
+
+// Finished 是否为终态
+func (s TaskStatus) Finished() bool {
+	return s == TaskSucceeded || s == TaskFailed || s == TaskCanceled
+}
+
+// Task 异步分析任务
+type Task struct {
+	ID        string     `json:"id"`
+	StockCode string     `json:"stock_code"`
+	Status    TaskStatus `json:"status"`
+	Report    string     `json:"report,omitempty"`
+	SavedFile string     `json:"saved_file,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+
+	cancel context.CancelFunc
+}
+
+// TaskRetention 已结束任务在内存中的保留时间，过期后于下次提交任务时清理，查询返回不存在
+var TaskRetention = time.Hour
+
+// TaskManager 内存中的任务存储与调度
+type TaskManager struct {
+	mu    sync.Mutex
+	tasks map[string]*Task
+	seq   int
+}
+
+// NewTaskManager 创建任务管理器
+func NewTaskManager() *TaskManager {
+	return &TaskManager{tasks: make(map[string]*Task)}
+}
+
+// Submit 提交任务并在后台执行 run，run 应在 ctx 取消时尽快返回；返回任务快照
+func (m *TaskManager) Submit(stockCode string, run func(ctx context.Context) AnalysisResult) Task {
+	ctx, cancel := context.WithCancel(context.Background())
+	now := time.Now()
+
+	m.mu.Lock()
+	m.evictFinished(now)
+	m.seq++
+	t := &Task{
+		ID:        fmt.Sprintf("%s-%d", now.Format("20060102150405"), m.seq),
+		StockCode: stockCode,
+		Status:    TaskPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+		cancel:    cancel,
+	}
+	m.tasks[t.ID] = t
+	snapshot := *t
+	m.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		if !m.transition(t.ID, TaskRunning, func(*Task) {}) {
+			return
+		}
+		result := run(ctx)
+		if result.Err != nil {
+			m.transition(t.ID, TaskFailed, func(t *Task) { t.Error = result.Err.Error() })
+			return
+		}
+		m.transition(t.ID, TaskSucceeded, func(t *Task) {
+			t.Report = result.Report
+			t.SavedFile = result.SavedFile
+		})
+	}()
+	return snapshot
+}
+
+// evictFinished 删除结束超过 TaskRetention 的任务，调用方须持有 m.mu
+func (m *TaskManager) evictFinished(now time.Time) {
+	for id, t := range m.tasks {
+		if t.Status.Finished() && now.Sub(t.UpdatedAt) >= TaskRetention {
+			delete(m.tasks, id)
+		}
+	}
+}
+
+// transition 非终态任务才能迁移状态，已取消的任务不会被执行结果覆盖
+func (m *TaskManager) transition(id string, to TaskStatus, update func(*Task)) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tasks[id]
+	if !ok || t.Status.Finished() {
+		return false
+	}
+	t.Status = to
+	t.UpdatedAt = time.Now()
+	update(t)
+	return true
+}
+
+// Get 查询任务快照
+func (m *TaskManager) Get(id string) (Task, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tasks[id]
+	if !ok {
+		return Task{}, false
+	}
+	return *t, true
+}
+
+// Cancel 取消未结束的任务并中断底层分析，已结束的任务返回错误
+func (m *TaskManager) Cancel(id string) (Task, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tasks[id]
+	if !ok {
+		return Task{}, fmt.Errorf("任务不存在: %s", id)
+	}
+	if t.Status.Finished() {
+		return *t, fmt.Errorf("任务已结束（%s），无法取消", t.Status)
+	}
+	t.Status = TaskCanceled
+	t.UpdatedAt = time.Now()
+	t.cancel()
+	return *t, nil
+}
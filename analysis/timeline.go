@@ -0,0 +1,101 @@
+package analysis
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TimelineDir 个股历史分析时间线页面目录，位于 history 子目录下，不参与报告归档
+var TimelineDir = filepath.Join("history", "timeline")
+
+var timelineMu sync.Mutex
+
+// timelineFileName 时间线页面文件名，代码经 fileNameCode 处理
+func timelineFileName(stockCode string) string {
+	return fileNameCode(stockCode) + ".html"
+}
+
+// TimelineURL 报告指向个股时间线的链接：配置 FeedBaseURL 时为绝对 URL（报告单独分享时仍可访问），否则为相对 history 目录的路径
+func TimelineURL(stockCode string) string {
+	rel := "timeline/" + url.PathEscape(timelineFileName(stockCode))
+	if FeedBaseURL != "" {
+		return strings.TrimRight(FeedBaseURL, "/") + "/" + rel
+	}
+	return rel
+}
+
+// timelineReportURL 时间线页面中单篇报告的链接，相对路径以时间线目录为基准
+func timelineReportURL(savedFile string) string {
+	if FeedBaseURL != "" {
+		return strings.TrimRight(FeedBaseURL, "/") + "/" + url.PathEscape(savedFile)
+	}
+	return "../" + url.PathEscape(savedFile)
+}
+
+// TimelineLinkHTML 置于 HTML 报告顶部的历史回看链接
+func TimelineLinkHTML(stockCode string) string {
+	return fmt.Sprintf("<p class=\"timeline-link\"><a href=\"%s\">查看 %s 全部历史分析 →</a></p>\n",
+		html.EscapeString(TimelineURL(stockCode)), html.EscapeString(stockCode))
+}
+
+// FormatStockTimelineHTML 个股历史分析时间线，按时间倒序；报告已归档（不在 history 目录）且无 FeedBaseURL 时不生成链接
+func FormatStockTimelineHTML(stockCode string, records []ConclusionRecord) string {
+	var list []ConclusionRecord
+	for _, r := range records {
+		if r.StockCode == stockCode {
+			list = append(list, r)
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Time.After(list[j].Time) })
+
+	var b strings.Builder
+	b.WriteString("<meta charset=\"utf-8\">\n" + exportCSS)
+	fmt.Fprintf(&b, "<h2>%s 历史分析时间线（%d 次）</h2>\n", html.EscapeString(stockCode), len(list))
+	b.WriteString("<table>\n<tr><th>时间</th><th>立场</th><th>趋势</th><th>建议</th><th>目标价</th><th>当时价格</th><th>报告</th></tr>\n")
+	for _, r := range list {
+		target, current := "-", "-"
+		if r.TargetPrice > 0 {
+			target = fmt.Sprintf("%.2f", r.TargetPrice)
+		}
+		if r.CurrentPrice > 0 {
+			current = fmt.Sprintf("%.2f", r.CurrentPrice)
+		}
+		link := "-"
+		if r.SavedFile != "" {
+			if _, err := os.Stat(filepath.Join(HistoryDir, r.SavedFile)); err == nil || FeedBaseURL != "" {
+				link = fmt.Sprintf("<a href=\"%s\">%s</a>", html.EscapeString(timelineReportURL(r.SavedFile)), html.EscapeString(r.SavedFile))
+			} else {
+				link = html.EscapeString(r.SavedFile) + "（已归档）"
+			}
+		}
+		fmt.Fprintf(&b, "<tr><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td><td>%s</td></tr>\n",
+			r.Time.Format("2006-01-02 15:04"), html.EscapeString(r.Stance), html.EscapeString(r.Trend),
+			html.EscapeString(r.Recommendation), target, current, link)
+	}
+	b.WriteString("</table>\n")
+	if trends := StockScoreTrends(list, stockCode, ScoreTrendWindow); len(trends) > 0 {
+		b.WriteString(markdownToHTML(convertMarkdownTablesToHTML(FormatScoreTrends(trends))))
+	}
+	return b.String()
+}
+
+// WriteStockTimeline 根据历史结论索引重新生成个股时间线页面
+func WriteStockTimeline(stockCode string) error {
+	timelineMu.Lock()
+	defer timelineMu.Unlock()
+	records, err := LoadConclusionRecords()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(TimelineDir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(TimelineDir, timelineFileName(stockCode)), []byte(FormatStockTimelineHTML(stockCode, records)), 0644)
+}
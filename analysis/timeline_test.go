@@ -0,0 +1,66 @@
+package analysis
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimelineURL(t *testing.T) {
+	old := FeedBaseURL
+	defer func() { FeedBaseURL = old }()
+
+	FeedBaseURL = ""
+	if got := TimelineURL("600036"); got != "timeline/600036.html" {
+		t.Errorf("相对链接 = %q", got)
+	}
+	if got := TimelineURL("BTC/USDT"); got != "timeline/BTC_USDT.html" {
+		t.Errorf("含分隔符的代码链接 = %q", got)
+	}
+	if got := timelineReportURL("600036 报告.html"); got != "../600036%20%E6%8A%A5%E5%91%8A.html" {
+		t.Errorf("时间线内报告链接 = %q", got)
+	}
+
+	// 配置绝对地址后，单独分享的报告仍能打开时间线
+	FeedBaseURL = "https://reports.example.com/history/"
+	if got := TimelineURL("AAPL"); got != "https://reports.example.com/history/timeline/AAPL.html" {
+		t.Errorf("绝对链接 = %q", got)
+	}
+	if got := timelineReportURL("a.html"); got != "https://reports.example.com/history/a.html" {
+		t.Errorf("绝对报告链接 = %q", got)
+	}
+	if got := TimelineLinkHTML("AAPL"); !strings.Contains(got, `href="https://reports.example.com/history/timeline/AAPL.html"`) {
+		t.Errorf("报告顶部链接 = %q", got)
+	}
+}
+
+func TestFormatStockTimelineHTML(t *testing.T) {
+	oldBase, oldHistory := FeedBaseURL, HistoryDir
+	defer func() { FeedBaseURL, HistoryDir = oldBase, oldHistory }()
+	FeedBaseURL, HistoryDir = "", t.TempDir()
+	if err := os.WriteFile(filepath.Join(HistoryDir, "new.html"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	day := time.Date(2024, 3, 1, 10, 0, 0, 0, time.Local)
+	records := []ConclusionRecord{
+		{StockCode: "600036", Time: day, Stance: "看多", TargetPrice: 35.5, SavedFile: "old.html"},
+		{StockCode: "000001", Time: day, Stance: "看空", SavedFile: "other.html"},
+		{StockCode: "600036", Time: day.AddDate(0, 0, 7), Stance: "中性", SavedFile: "new.html"},
+	}
+	page := FormatStockTimelineHTML("600036", records)
+	if !strings.Contains(page, "（2 次）") || strings.Contains(page, "other.html") {
+		t.Errorf("时间线只应包含本股的 2 条记录:\n%s", page)
+	}
+	if strings.Index(page, "new.html") > strings.Index(page, "old.html") {
+		t.Error("时间线应按时间倒序")
+	}
+	if !strings.Contains(page, `<a href="../new.html">`) {
+		t.Error("存在的报告应生成相对链接")
+	}
+	if !strings.Contains(page, "old.html（已归档）") {
+		t.Error("已归档的报告不应生成失效链接")
+	}
+}
@@ -0,0 +1,53 @@
+package analysis
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// MaxResponseTokens 单次生成的最大响应 token 数
+var MaxResponseTokens = 2000
+
+// tokenRatio 每个字符对应的近似 token 数
+type tokenRatio struct {
+	cjk   float64 // 中日韩字符及全角标点
+	other float64 // 英文、数字、标点等其它非空白字符
+}
+
+// 各模型族的近似分词比例：DeepSeek 官方口径为 1 个中文字符约 0.6 token、1 个英文字符约 0.3 token；
+// Gemini 约 4 个英文字符 1 token、中文约 1 字 1 token
+var (
+	deepSeekTokenRatio = tokenRatio{cjk: 0.6, other: 0.3}
+	geminiTokenRatio   = tokenRatio{cjk: 1.0, other: 0.25}
+	defaultTokenRatio  = tokenRatio{cjk: 0.8, other: 0.3}
+)
+
+func tokenRatioFor(model string) tokenRatio {
+	m := strings.ToLower(model)
+	switch {
+	case strings.Contains(m, "deepseek"):
+		return deepSeekTokenRatio
+	case strings.Contains(m, "gemini"):
+		return geminiTokenRatio
+	default:
+		return defaultTokenRatio
+	}
+}
+
+// EstimateTokens 按模型的近似分词规则估算文本 token 数，空白不计
+func EstimateTokens(text, model string) int {
+	ratio := tokenRatioFor(model)
+	var cjk, other int
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul),
+			r >= 0x3000 && r <= 0x303F, r >= 0xFF00 && r <= 0xFFEF: // 含全角标点
+			cjk++
+		default:
+			other++
+		}
+	}
+	return int(math.Ceil(float64(cjk)*ratio.cjk + float64(other)*ratio.other))
+}
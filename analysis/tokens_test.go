@@ -0,0 +1,48 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokensByModel(t *testing.T) {
+	for _, c := range []struct {
+		text, model string
+		want        int
+	}{
+		{"招商银行", "deepseek-chat", 3},            // 4×0.6=2.4
+		{"hello world", "deepseek-reasoner", 3}, // 10×0.3，空格不计
+		{"招商银行，MACD 金叉", "deepseek-chat", 6},    // 7 个中文字符与全角标点×0.6 + 4×0.3 = 5.4
+		{"招商银行", "gemini-1.5-pro", 4},
+		{"abcdefgh", "gemini-1.5-flash", 2},
+		{"招商银行 abcd", "gpt-4o", 5}, // 4×0.8 + 4×0.3 = 4.4
+		{" \n\t", "deepseek-chat", 0},
+		{"", "deepseek-chat", 0},
+	} {
+		if got := EstimateTokens(c.text, c.model); got != c.want {
+			t.Errorf("EstimateTokens(%q, %s) = %d，期望 %d", c.text, c.model, got, c.want)
+		}
+	}
+}
+
+func TestEstimateTokensMixedTextError(t *testing.T) {
+	zh := strings.Repeat("主力资金持续净流入，短期均线多头排列。", 20)
+	en := strings.Repeat("The MACD histogram turned positive while RSI stays below 70. ", 20)
+	mixed := zh + en
+	// 混合文本的估算与分别估算之和的差距不超过取整误差
+	if sum, got := EstimateTokens(zh, "deepseek-chat")+EstimateTokens(en, "deepseek-chat"), EstimateTokens(mixed, "deepseek-chat"); got > sum || sum-got > 1 {
+		t.Errorf("混合文本估算 %d，分别估算之和 %d", got, sum)
+	}
+	// 英文约 1.3 token/词，中文约 0.6 token/字（DeepSeek 口径），估算应落在参考值 ±30% 内
+	words := len(strings.Fields(en))
+	hans := 0
+	for _, r := range zh {
+		if r >= 0x4E00 && r <= 0x9FFF {
+			hans++
+		}
+	}
+	ref := 1.3*float64(words) + 0.6*float64(hans)
+	if got := float64(EstimateTokens(mixed, "deepseek-chat")); got < ref*0.7 || got > ref*1.3 {
+		t.Errorf("混合文本估算 %.0f，参考值 %.0f，误差超过 30%%", got, ref)
+	}
+}
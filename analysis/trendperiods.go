@@ -0,0 +1,119 @@
+package analysis
+
+import "math"
+
+// TrendPeriod 多周期趋势判断的回看窗口
+type TrendPeriod struct {
+	Name string
+	Days int // 交易日数
+}
+
+// TrendPeriods 短期/中期/长期窗口
+var TrendPeriods = []TrendPeriod{{"短期", 5}, {"中期", 20}, {"长期", 60}}
+
+// trendFlatThreshold 回归斜率占均价的比例（每日）低于该值视为震荡
+const trendFlatThreshold = 0.001
+
+// trendDivergencePenalty 多周期背离时各周期置信度的折算系数
+const trendDivergencePenalty = 0.7
+
+// 多周期一致性
+const (
+	TrendAllBullish = "全多头"
+	TrendAllBearish = "全空头"
+	TrendAllFlat    = "全震荡"
+	TrendDivergent  = "背离"
+	TrendNoData     = "数据不足"
+)
+
+// PeriodTrend 单一周期的趋势判断
+type PeriodTrend struct {
+	Period     string  `json:"period"`
+	Days       int     `json:"days"`
+	Trend      string  `json:"trend"`      // 上涨/下跌/震荡
+	Slope      float64 `json:"slope"`      // 收盘价回归斜率占均价的比例（每日）
+	Confidence float64 `json:"confidence"` // 0-1，趋势取拟合优度 R²，震荡取 1-R²；背离时已下调
+}
+
+// MultiPeriodTrend 多周期趋势及一致性
+type MultiPeriodTrend struct {
+	Periods     []PeriodTrend `json:"periods"`     // 数据不足的周期不列出
+	Consistency string        `json:"consistency"` // 全多头/全空头/全震荡/背离/数据不足
+}
+
+// closeRegression 收盘价对序号的最小二乘斜率（占均价比例）与 R²
+func closeRegression(data []StockData) (slope, r2 float64) {
+	n := float64(len(data))
+	var sumX, sumY, sumXY, sumXX, sumYY float64
+	for i, d := range data {
+		x := float64(i)
+		sumX += x
+		sumY += d.Close
+		sumXY += x * d.Close
+		sumXX += x * x
+		sumYY += d.Close * d.Close
+	}
+	sxx := sumXX - sumX*sumX/n
+	syy := sumYY - sumY*sumY/n
+	sxy := sumXY - sumX*sumY/n
+	mean := sumY / n
+	if sxx == 0 || mean == 0 {
+		return 0, 0
+	}
+	b := sxy / sxx
+	if syy > 0 {
+		r2 = sxy * sxy / (sxx * syy)
+	}
+	return b / mean, r2
+}
+
+// PredictMultiPeriod 对短期、中期、长期窗口分别按收盘价线性回归判断趋势，并标注三周期是否一致；
+// 背离时各周期置信度乘以 trendDivergencePenalty。可用周期少于两个时一致性为“数据不足”
+func PredictMultiPeriod(stockData []StockData) MultiPeriodTrend {
+	var result MultiPeriodTrend
+	for _, p := range TrendPeriods {
+		if len(stockData) < p.Days || p.Days < 2 {
+			continue
+		}
+		slope, r2 := closeRegression(stockData[len(stockData)-p.Days:])
+		pt := PeriodTrend{Period: p.Name, Days: p.Days, Slope: slope}
+		switch {
+		case slope > trendFlatThreshold:
+			pt.Trend, pt.Confidence = "上涨", r2
+		case slope < -trendFlatThreshold:
+			pt.Trend, pt.Confidence = "下跌", r2
+		default:
+			pt.Trend, pt.Confidence = "震荡", 1-r2
+		}
+		pt.Confidence = math.Round(pt.Confidence*1000) / 1000
+		result.Periods = append(result.Periods, pt)
+	}
+
+	if len(result.Periods) < 2 {
+		result.Consistency = TrendNoData
+		return result
+	}
+	switch {
+	case allTrend(result.Periods, "上涨"):
+		result.Consistency = TrendAllBullish
+	case allTrend(result.Periods, "下跌"):
+		result.Consistency = TrendAllBearish
+	case allTrend(result.Periods, "震荡"):
+		result.Consistency = TrendAllFlat
+	default:
+		result.Consistency = TrendDivergent
+		for i := range result.Periods {
+			result.Periods[i].Confidence = math.Round(result.Periods[i].Confidence*trendDivergencePenalty*1000) / 1000
+		}
+	}
+	return result
+}
+
+func allTrend(periods []PeriodTrend, trend string) bool {
+	for _, p := range periods {
+		if p.Trend != trend {
+			return false
+		}
+	}
+	return true
+}
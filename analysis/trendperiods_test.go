@@ -0,0 +1,68 @@
+package analysis
+
+import (
+	"math"
+	"testing"
+)
+
+// closeSeries 由收盘价构造K线
+func closeSeries(closes []float64) []StockData {
+	data := make([]StockData, len(closes))
+	for i, c := range closes {
+		data[i] = StockData{Open: c, Close: c, High: c, Low: c, Volume: 1000}
+	}
+	return data
+}
+
+func TestPredictMultiPeriodConsistent(t *testing.T) {
+	var up, down []float64
+	for i := 0; i < 80; i++ {
+		up = append(up, 10*math.Pow(1.01, float64(i)))
+		down = append(down, 10*math.Pow(0.99, float64(i)))
+	}
+	for _, c := range []struct {
+		name, trend, want string
+		data              []StockData
+	}{
+		{"上涨", "上涨", TrendAllBullish, closeSeries(up)},
+		{"下跌", "下跌", TrendAllBearish, closeSeries(down)},
+	} {
+		got := PredictMultiPeriod(c.data)
+		if got.Consistency != c.want || len(got.Periods) != len(TrendPeriods) {
+			t.Fatalf("%s: 一致性 %q、周期 %d 个，期望 %q、%d 个", c.name, got.Consistency, len(got.Periods), c.want, len(TrendPeriods))
+		}
+		for _, p := range got.Periods {
+			if p.Trend != c.trend || p.Confidence < 0.9 {
+				t.Errorf("%s: %s 趋势 %q 置信度 %v，期望 %q 且置信度接近 1", c.name, p.Period, p.Trend, p.Confidence, c.trend)
+			}
+		}
+	}
+}
+
+func TestPredictMultiPeriodDivergent(t *testing.T) {
+	// 长期下跌，最近 5 日急涨
+	var closes []float64
+	for i := 0; i < 75; i++ {
+		closes = append(closes, 100-float64(i))
+	}
+	for i := 1; i <= 5; i++ {
+		closes = append(closes, 26+3*float64(i))
+	}
+	data := closeSeries(closes)
+	got := PredictMultiPeriod(data)
+	if got.Consistency != TrendDivergent {
+		t.Fatalf("一致性 = %q，期望背离: %+v", got.Consistency, got.Periods)
+	}
+	short, long := got.Periods[0], got.Periods[len(got.Periods)-1]
+	if short.Trend != "上涨" || long.Trend != "下跌" {
+		t.Errorf("短期 %q、长期 %q，期望短期上涨、长期下跌", short.Trend, long.Trend)
+	}
+	_, r2 := closeRegression(data[len(data)-short.Days:])
+	if want := math.Round(math.Round(r2*1000)/1000*trendDivergencePenalty*1000) / 1000; short.Confidence != want {
+		t.Errorf("背离时短期置信度 = %v，期望下调为 %v", short.Confidence, want)
+	}
+
+	if got := PredictMultiPeriod(data[:10]); got.Consistency != TrendNoData {
+		t.Errorf("仅够一个周期时一致性 = %q，期望数据不足", got.Consistency)
+	}
+}
@@ -0,0 +1,147 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+// WalkForwardSplit 一组训练/测试窗口，下标左闭右开，测试窗口 [TrainEnd, TestEnd) 紧邻训练窗口
+type WalkForwardSplit struct {
+	TrainStart int
+	TrainEnd   int
+	TestEnd    int
+}
+
+// WalkForwardWindow 单个窗口的寻优与验证结果
+type WalkForwardWindow struct {
+	WalkForwardSplit
+	TestFrom    time.Time
+	TestTo      time.Time
+	Params      BacktestParams // 训练窗口寻优得到的参数
+	InSample    BacktestResult
+	OutOfSample BacktestResult
+}
+
+// WalkForwardResult 各窗口样本外表现的汇总，样本外资金按窗口顺序滚动衔接
+type WalkForwardResult struct {
+	Windows          []WalkForwardWindow
+	TotalReturn      float64   // 样本外累计收益率
+	AnnualizedReturn float64   // 按样本外交易日数折算的年化收益率
+	SharpeRatio      float64   // 衔接后样本外资金曲线的夏普比率
+	MaxDrawdown      float64   // 衔接后样本外资金曲线的最大回撤
+	Trades           int       // 样本外交易次数
+	WinningWindows   int       // 样本外收益为正的窗口数
+	Efficiency       float64   // 样本外年化 / 样本内平均年化，样本内不为正时为 0
+	EquityCurve      []float64 // 衔接后的样本外资金曲线
+}
+
+// WalkForwardSplits 按训练窗口 trainSize、测试窗口 testSize 滚动划分 n 根K线，每次前移一个测试窗口，只保留完整的测试窗口
+func WalkForwardSplits(n, trainSize, testSize int) []WalkForwardSplit {
+	if trainSize <= 0 || testSize <= 0 {
+		return nil
+	}
+	var splits []WalkForwardSplit
+	for start := 0; start+trainSize+testSize <= n; start += testSize {
+		splits = append(splits, WalkForwardSplit{TrainStart: start, TrainEnd: start + trainSize, TestEnd: start + trainSize + testSize})
+	}
+	return splits
+}
+
+// WalkForward 滚动前进回测：每个训练窗口内用 OptimizeStrategy 寻优，再用最优参数在紧邻的测试窗口回测；
+// 测试窗口向前借用训练期K线做指标预热，资金曲线只覆盖测试窗口。训练窗口内无合法参数组合的窗口跳过
+func WalkForward(stockData []StockData, strategyType string, grid map[string][]float64, trainSize, testSize int) (WalkForwardResult, error) {
+	splits := WalkForwardSplits(len(stockData), trainSize, testSize)
+	if len(splits) == 0 {
+		return WalkForwardResult{}, fmt.Errorf("行情 %d 条不足以划分训练窗口 %d + 测试窗口 %d", len(stockData), trainSize, testSize)
+	}
+	var res WalkForwardResult
+	for _, sp := range splits {
+		params, inSample, points := OptimizeStrategy(stockData[sp.TrainStart:sp.TrainEnd], strategyType, grid)
+		if len(points) == 0 {
+			continue
+		}
+		from := sp.TrainEnd - backtestLookback(params)
+		if from < sp.TrainStart {
+			from = sp.TrainStart
+		}
+		res.Windows = append(res.Windows, WalkForwardWindow{
+			WalkForwardSplit: sp,
+			TestFrom:         stockData[sp.TrainEnd].Date,
+			TestTo:           stockData[sp.TestEnd-1].Date,
+			Params:           params,
+			InSample:         inSample,
+			OutOfSample:      BacktestStrategy(stockData[from:sp.TestEnd], params),
+		})
+	}
+	if len(res.Windows) == 0 {
+		return res, fmt.Errorf("所有训练窗口内均无合法参数组合")
+	}
+	aggregateWalkForward(&res)
+	return res, nil
+}
+
+// aggregateWalkForward 按窗口顺序衔接样本外资金曲线并计算汇总指标
+func aggregateWalkForward(res *WalkForwardResult) {
+	capital := 1.0
+	res.EquityCurve = []float64{capital}
+	var inSampleAnnual float64
+	for _, w := range res.Windows {
+		oos := w.OutOfSample
+		res.Trades += oos.Trades
+		if oos.TotalReturn > 0 {
+			res.WinningWindows++
+		}
+		inSampleAnnual += w.InSample.AnnualizedReturn
+		// 各窗口资金曲线按起始资金归一后接在上一窗口末尾
+		if len(oos.EquityCurve) > 1 && oos.EquityCurve[0] > 0 {
+			for _, v := range oos.EquityCurve[1:] {
+				res.EquityCurve = append(res.EquityCurve, capital*v/oos.EquityCurve[0])
+			}
+		}
+		capital *= 1 + oos.TotalReturn
+	}
+	res.TotalReturn = capital - 1
+
+	days := len(res.EquityCurve) - 1
+	if days > 0 && res.TotalReturn > -1 {
+		res.AnnualizedReturn = math.Pow(1+res.TotalReturn, 252/float64(days)) - 1
+	}
+	res.MaxDrawdown = equityMaxDrawdown(res.EquityCurve)
+	if returns := equityReturns(res.EquityCurve); len(returns) > 1 {
+		res.SharpeRatio = calculateSharpeRatio(returns, RiskFreeRate)
+	}
+	if avg := inSampleAnnual / float64(len(res.Windows)); avg > 0 {
+		res.Efficiency = res.AnnualizedReturn / avg
+	}
+}
+
+// strategyParamSummary 策略关键参数的简要描述
+func strategyParamSummary(p BacktestParams) string {
+	switch p.StrategyType {
+	case "breakout":
+		return fmt.Sprintf("突破%d", p.BreakoutPeriod)
+	case "rsi":
+		return fmt.Sprintf("RSI%d(%.0f/%.0f)", p.RSIPeriod, p.RSIOversold, p.RSIOverbought)
+	case "atr_trailing":
+		return fmt.Sprintf("ATR%d×%.1f", p.ATRPeriod, p.ATRMultiplier)
+	default:
+		return fmt.Sprintf("MA%d/MA%d", p.FastMAPeriod, p.SlowMAPeriod)
+	}
+}
+
+// FormatWalkForward 滚动前进回测的窗口明细与样本外汇总 markdown
+func FormatWalkForward(res WalkForwardResult) string {
+	var b strings.Builder
+	b.WriteString("\n【滚动前进回测】\n| 测试区间 | 最优参数 | 样本内收益率 | 样本外收益率 | 样本外交易次数 |\n|---|---|---|---|---|\n")
+	for _, w := range res.Windows {
+		fmt.Fprintf(&b, "| %s ~ %s | %s | %s | %s | %d |\n",
+			w.TestFrom.Format("2006-01-02"), w.TestTo.Format("2006-01-02"), strategyParamSummary(w.Params),
+			FormatPercent(w.InSample.TotalReturn), FormatPercent(w.OutOfSample.TotalReturn), w.OutOfSample.Trades)
+	}
+	fmt.Fprintf(&b, "\n样本外累计收益 %s，年化 %s，夏普比率 %s，最大回撤 %s，盈利窗口 %d/%d，前进效率 %s\n",
+		FormatPercent(res.TotalReturn), FormatPercent(res.AnnualizedReturn), FormatRatio(res.SharpeRatio),
+		FormatPercent(res.MaxDrawdown), res.WinningWindows, len(res.Windows), FormatRatio(res.Efficiency))
+	return b.String()
+}
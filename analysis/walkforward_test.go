@@ -0,0 +1,78 @@
+package analysis
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWalkForwardSplits(t *testing.T) {
+	want := []WalkForwardSplit{{0, 40, 60}, {20, 60, 80}, {40, 80, 100}}
+	if got := WalkForwardSplits(100, 40, 20); !reflect.DeepEqual(got, want) {
+		t.Errorf("窗口划分 = %v，期望 %v", got, want)
+	}
+	if got := WalkForwardSplits(99, 40, 20); len(got) != 2 {
+		t.Errorf("不完整的测试窗口应丢弃: %v", got)
+	}
+	if WalkForwardSplits(100, 0, 20) != nil || WalkForwardSplits(50, 40, 20) != nil {
+		t.Error("窗口非法或行情不足时应无划分")
+	}
+}
+
+func TestWalkForwardAggregatesOutOfSample(t *testing.T) {
+	data := datedBars(300)
+	grid := map[string][]float64{"FastMAPeriod": {5, 10}, "SlowMAPeriod": {20, 30}}
+	res, err := WalkForward(data, "ma_cross", grid, 120, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Windows) != 4 {
+		t.Fatalf("300 根K线按 120+40 应划分 4 个窗口，得到 %d", len(res.Windows))
+	}
+	capital, trades, winning := 1.0, 0, 0
+	for i, w := range res.Windows {
+		if w.TrainEnd != 120+40*i || !w.TestFrom.Equal(data[w.TrainEnd].Date) || !w.TestTo.Equal(data[w.TestEnd-1].Date) {
+			t.Errorf("第 %d 个窗口区间错误: %+v", i+1, w.WalkForwardSplit)
+		}
+		if w.Params.FastMAPeriod >= w.Params.SlowMAPeriod {
+			t.Errorf("第 %d 个窗口参数非法: %+v", i+1, w.Params)
+		}
+		// 样本外回测借用训练期K线预热，资金曲线只覆盖测试窗口
+		if n := len(w.OutOfSample.EquityCurve); n != 41 {
+			t.Errorf("第 %d 个窗口样本外资金曲线长度 = %d，期望 41", i+1, n)
+		}
+		capital *= 1 + w.OutOfSample.TotalReturn
+		trades += w.OutOfSample.Trades
+		if w.OutOfSample.TotalReturn > 0 {
+			winning++
+		}
+	}
+	if math.Abs(res.TotalReturn-(capital-1)) > 1e-12 || res.Trades != trades || res.WinningWindows != winning {
+		t.Errorf("样本外汇总错误: 收益 %v（期望 %v）交易 %d 盈利窗口 %d", res.TotalReturn, capital-1, res.Trades, res.WinningWindows)
+	}
+	if len(res.EquityCurve) != 161 || math.Abs(res.EquityCurve[160]-capital) > 1e-9 {
+		t.Errorf("衔接后的资金曲线长度 %d，期末 %v，期望 161 / %v", len(res.EquityCurve), res.EquityCurve[len(res.EquityCurve)-1], capital)
+	}
+	if res.MaxDrawdown < 0 || res.MaxDrawdown >= 1 {
+		t.Errorf("最大回撤 = %v", res.MaxDrawdown)
+	}
+
+	text := FormatWalkForward(res)
+	for _, want := range []string{"【滚动前进回测】", fmt.Sprintf("盈利窗口 %d/4", winning), "| MA"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("滚动前进报告缺少 %q:\n%s", want, text)
+		}
+	}
+}
+
+func TestWalkForwardErrors(t *testing.T) {
+	if _, err := WalkForward(datedBars(100), "ma_cross", nil, 120, 40); err == nil {
+		t.Error("行情不足以划分窗口时应返回错误")
+	}
+	grid := map[string][]float64{"FastMAPeriod": {30}, "SlowMAPeriod": {10}}
+	if _, err := WalkForward(datedBars(200), "ma_cross", grid, 120, 40); err == nil {
+		t.Error("训练窗口内无合法参数组合时应返回错误")
+	}
+}
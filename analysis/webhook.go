@@ -2,25 +2,326 @@ package analysis
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
-// 发送钉钉/企业微信 webhook 消息
-func SendWebhook(webhookURL, content string) error {
-	body := map[string]interface{}{
+// WebhookTemplate 推送消息模板（text/template 语法），为空时直接推送报告全文；
+// 可用变量见 WebhookContext，如 "[{{.StockCode}}] {{.Recommendation}}\n{{.Summary}}\n{{.Link}}"
+var WebhookTemplate = ""
+
+// WebhookContext 推送模板可引用的字段
+type WebhookContext struct {
+	StockCode      string
+	Market         string  // A股/港股/美股
+	Report         string  // 报告全文
+	Summary        string  // 正文摘要（前 200 字）
+	SavedFile      string  // 历史文件名
+	Link           string  // 报告链接，配置 FeedBaseURL 时为完整 URL，否则为文件名
+	Trend          string  // 报告中的趋势判断原文
+	Recommendation string  // 报告中的操作建议原文
+	TargetPrice    float64 // 目标价，0 表示未给出
+	Time           string  // 推送时间 2006-01-02 15:04
+}
+
+// NewWebhookContext 从分析结果构造模板上下文
+func NewWebhookContext(result AnalysisResult, at time.Time) WebhookContext {
+	c := ExtractConclusion(result.Report, 0)
+	link := result.SavedFile
+	if FeedBaseURL != "" && result.SavedFile != "" {
+		link = strings.TrimRight(FeedBaseURL, "/") + "/" + result.SavedFile
+	}
+	return WebhookContext{
+		StockCode:      result.StockCode,
+		Market:         StockMarket(result.StockCode),
+		Report:         result.Report,
+		Summary:        summarizeReport(result.Report, 200),
+		SavedFile:      result.SavedFile,
+		Link:           link,
+		Trend:          c.Trend,
+		Recommendation: c.Recommendation,
+		TargetPrice:    c.TargetPrice,
+		Time:           at.Format("2006-01-02 15:04"),
+	}
+}
+
+// RenderWebhookMessage 按模板渲染推送内容，模板为空时返回报告全文
+func RenderWebhookMessage(tmpl string, ctx WebhookContext) (string, error) {
+	if strings.TrimSpace(tmpl) == "" {
+		return ctx.Report, nil
+	}
+	t, err := template.New("webhook").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", fmt.Errorf("解析推送模板失败: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, ctx); err != nil {
+		return "", fmt.Errorf("渲染推送模板失败: %v", err)
+	}
+	return buf.String(), nil
+}
+
+// renderWebhookContent 按 WebhookTemplate 渲染分析结果，模板渲染失败时回退为报告全文
+func renderWebhookContent(result AnalysisResult) (string, WebhookContext) {
+	ctx := NewWebhookContext(result, time.Now())
+	content, err := RenderWebhookMessage(WebhookTemplate, ctx)
+	if err != nil {
+		log.Printf("[IM推送] %v，使用默认格式\n", err)
+		content = result.Report
+	}
+	return content, ctx
+}
+
+// isSlackWebhook 是否为 Slack Incoming Webhook 地址
+func isSlackWebhook(webhookURL string) bool {
+	return strings.HasPrefix(webhookURL, "https://hooks.slack.com/")
+}
+
+// isWeComWebhook 是否为企业微信群机器人地址，其 markdown 消息格式与钉钉不同
+func isWeComWebhook(webhookURL string) bool {
+	return strings.Contains(webhookURL, "qyapi.weixin.qq.com")
+}
+
+// WebhookMarkdown 钉钉/企业微信是否以 markdown 消息推送，默认 text
+var WebhookMarkdown = false
+
+// WebhookSecret 钉钉机器人“加签”安全设置的密钥，为空时不签名
+var WebhookSecret = ""
+
+// WebhookMarkdownLimit markdown 消息正文上限（字节），企业微信为 4096，超出时截断并提示查看完整报告
+var WebhookMarkdownLimit = 4000
+
+// SendWebhook 按 WebhookTemplate 渲染分析结果并推送：Slack Incoming Webhook 地址发 Slack 消息，
+// 其余按钉钉/企业微信 text 消息发送，WebhookMarkdown 开启时发 markdown 消息
+func SendWebhook(webhookURL string, result AnalysisResult) error {
+	content, ctx := renderWebhookContent(result)
+	if isSlackWebhook(webhookURL) {
+		return SendSlack(webhookURL, content)
+	}
+	if WebhookMarkdown {
+		return sendWebhookMarkdown(webhookURL, result.StockCode+" 分析报告", content, ctx.Link)
+	}
+	return postWebhook(webhookURL, map[string]interface{}{
 		"msgtype": "text",
 		"text":    map[string]string{"content": content},
+	})
+}
+
+// SendWebhookMarkdown 以 markdown 消息推送到钉钉/企业微信，超长内容截断并提示查看完整报告
+func SendWebhookMarkdown(webhookURL, title, markdown string) error {
+	return sendWebhookMarkdown(webhookURL, title, markdown, "")
+}
+
+func sendWebhookMarkdown(webhookURL, title, markdown, link string) error {
+	markdown = truncateMarkdown(markdown, WebhookMarkdownLimit, link)
+	if isWeComWebhook(webhookURL) {
+		return postWebhook(webhookURL, map[string]interface{}{
+			"msgtype":  "markdown",
+			"markdown": map[string]string{"content": markdown},
+		})
+	}
+	return postWebhook(webhookURL, map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"title": title, "text": markdown},
+	})
+}
+
+// truncateMarkdown 超过 limit 字节时在行边界截断，末尾附“查看完整报告”提示（有链接时附链接）
+func truncateMarkdown(markdown string, limit int, link string) string {
+	if len(markdown) <= limit {
+		return markdown
+	}
+	notice := "\n\n> 内容过长已截断，请查看完整报告"
+	if link != "" {
+		notice = fmt.Sprintf("\n\n> 内容过长已截断，[查看完整报告](%s)", link)
+	}
+	keep := limit - len(notice)
+	if keep < 0 {
+		keep = 0
 	}
-	b, _ := json.Marshal(body)
-	resp, err := http.Post(webhookURL, "application/json", bytes.NewReader(b))
+	cut := strings.LastIndex(markdown[:keep], "\n")
+	if cut <= 0 {
+		// 无换行时按字符边界截断，避免切断 UTF-8 多字节字符
+		for cut = keep; cut > 0 && !utf8.RuneStart(markdown[cut]); cut-- {
+		}
+	}
+	return strings.TrimRight(markdown[:cut], "\n") + notice
+}
+
+// signWebhookURL 钉钉加签：timestamp（毫秒）+"\n"+secret 以 secret 做 HmacSHA256，Base64 后作为 sign 参数
+func signWebhookURL(webhookURL, secret string, now time.Time) (string, error) {
+	u, err := url.Parse(webhookURL)
 	if err != nil {
-		return err
+		return "", fmt.Errorf("Webhook 地址非法: %v", err)
+	}
+	timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	q := u.Query()
+	q.Set("timestamp", timestamp)
+	q.Set("sign", base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// postWebhook 发送钉钉/企业微信机器人消息，配置 WebhookSecret 时加签；HTTP 200 但 errcode 非 0 也视为失败
+func postWebhook(webhookURL string, payload interface{}) error {
+	if WebhookSecret != "" {
+		signed, err := signWebhookURL(webhookURL, WebhookSecret, time.Now())
+		if err != nil {
+			return err
+		}
+		webhookURL = signed
+	}
+	data, err := postJSON(webhookURL, payload)
+	if err != nil {
+		return fmt.Errorf("Webhook 推送失败: %v", err)
+	}
+	var reply struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if json.Unmarshal(data, &reply) == nil && reply.ErrCode != 0 {
+		return fmt.Errorf("Webhook 推送失败: %d %s", reply.ErrCode, reply.ErrMsg)
+	}
+	return nil
+}
+
+// 各平台单条消息长度上限，超出时分段发送
+const (
+	TelegramMessageLimit = 4096 // 按 UTF-16 码元计
+	SlackMessageLimit    = 4000
+)
+
+// TelegramAPIBase Telegram Bot API 地址前缀，可指向自建代理
+var TelegramAPIBase = "https://api.telegram.org"
+
+// utf16Len 文本的 UTF-16 码元数，Telegram 按此计算消息长度
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// SplitMessage 将长文本按 limit（UTF-16 码元）分段，优先在换行处断开，单行超长时按字符硬切；各段不含首尾空行
+func SplitMessage(content string, limit int) []string {
+	var parts []string
+	var cur strings.Builder
+	curLen := 0
+	flush := func() {
+		if part := strings.Trim(cur.String(), "\n"); part != "" {
+			parts = append(parts, part)
+		}
+		cur.Reset()
+		curLen = 0
+	}
+	for _, line := range strings.SplitAfter(content, "\n") {
+		n := utf16Len(line)
+		if curLen+n > limit {
+			flush()
+		}
+		for n > limit {
+			// 单行超长，按字符切到不超过 limit
+			cut, size := 0, 0
+			for i, r := range line {
+				if size+utf16.RuneLen(r) > limit {
+					cut = i
+					break
+				}
+				size += utf16.RuneLen(r)
+			}
+			if cut == 0 {
+				_, cut = utf8.DecodeRuneInString(line)
+			}
+			cur.WriteString(line[:cut])
+			flush()
+			line = line[cut:]
+			n = utf16Len(line)
+		}
+		cur.WriteString(line)
+		curLen += n
+	}
+	flush()
+	return parts
+}
+
+// PushTimeout 单次推送请求的超时
+var PushTimeout = 15 * time.Second
+
+// postJSON 发送 JSON 请求，非 2xx 时返回带响应内容的错误；
+// 网络错误不带请求地址，避免 Telegram Bot Token 等地址中的密钥出现在日志里
+func postJSON(endpoint string, payload interface{}) ([]byte, error) {
+	b, _ := json.Marshal(payload)
+	client := &http.Client{Timeout: PushTimeout}
+	resp, err := client.Post(endpoint, "application/json", bytes.NewReader(b))
+	if err != nil {
+		if ue, ok := err.(*url.Error); ok {
+			return nil, fmt.Errorf("%s 请求失败: %v", ue.Op, ue.Err)
+		}
+		return nil, err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("Webhook 推送失败: %s", resp.Status)
+	data, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return data, fmt.Errorf("%s %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	return data, nil
+}
+
+// SendTelegram 通过 Bot API sendMessage 发送纯文本，超过 4096 字符自动分段
+func SendTelegram(botToken, chatID, content string) error {
+	endpoint := fmt.Sprintf("%s/bot%s/sendMessage", strings.TrimRight(TelegramAPIBase, "/"), botToken)
+	parts := SplitMessage(content, TelegramMessageLimit)
+	for i, part := range parts {
+		data, err := postJSON(endpoint, map[string]interface{}{
+			"chat_id":                  chatID,
+			"text":                     part,
+			"disable_web_page_preview": true,
+		})
+		if err == nil {
+			var reply struct {
+				OK          bool   `json:"ok"`
+				Description string `json:"description"`
+			}
+			if json.Unmarshal(data, &reply) == nil && !reply.OK {
+				err = fmt.Errorf("%s", reply.Description)
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("Telegram 推送失败（第 %d/%d 段）: %v", i+1, len(parts), err)
+		}
+	}
+	return nil
+}
+
+// SendSlack 通过 Incoming Webhook 发送文本，超长时分段
+func SendSlack(webhookURL, content string) error {
+	parts := SplitMessage(content, SlackMessageLimit)
+	for i, part := range parts {
+		if _, err := postJSON(webhookURL, map[string]string{"text": part}); err != nil {
+			return fmt.Errorf("Slack 推送失败（第 %d/%d 段）: %v", i+1, len(parts), err)
+		}
 	}
 	return nil
 }
+
+// SendTelegramReport 按 WebhookTemplate 渲染分析结果并推送到 Telegram
+func SendTelegramReport(botToken, chatID string, result AnalysisResult) error {
+	content, _ := renderWebhookContent(result)
+	return SendTelegram(botToken, chatID, content)
+}
@@ -0,0 +1,304 @@
+package analysis
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestSplitMessageRespectsLimit(t *testing.T) {
+	content := strings.Repeat("第一行内容\n", 30) + strings.Repeat("a", 50)
+	parts := SplitMessage(content, 32)
+	if len(parts) < 2 {
+		t.Fatalf("超长内容应分段，得到 %d 段", len(parts))
+	}
+	for i, p := range parts {
+		if n := utf16Len(p); n > 32 {
+			t.Errorf("第 %d 段长度 %d 超过上限 32", i+1, n)
+		}
+	}
+	if got := strings.Join(parts, ""); strings.ReplaceAll(content, "\n", "") != strings.ReplaceAll(got, "\n", "") {
+		t.Errorf("分段拼接后内容不一致:\n%q", got)
+	}
+	if parts := SplitMessage("短消息", TelegramMessageLimit); len(parts) != 1 || parts[0] != "短消息" {
+		t.Errorf("未超长时应原样返回一段: %q", parts)
+	}
+}
+
+func TestSplitMessageKeepsUTF8Boundary(t *testing.T) {
+	// 单行无换行，只能按字符硬切；emoji 占 2 个 UTF-16 码元
+	content := strings.Repeat("涨😀", 40)
+	for _, limit := range []int{1, 3, 7, 10} {
+		parts := SplitMessage(content, limit)
+		for i, p := range parts {
+			if !utf8.ValidString(p) {
+				t.Errorf("limit=%d 第 %d 段切断了多字节字符: %q", limit, i+1, p)
+			}
+			if n := utf16Len(p); n > limit && utf8.RuneCountInString(p) > 1 {
+				t.Errorf("limit=%d 第 %d 段长度 %d 超限", limit, i+1, n)
+			}
+		}
+		if strings.Join(parts, "") != content {
+			t.Errorf("limit=%d 硬切后拼接应与原文一致", limit)
+		}
+	}
+}
+
+func TestSplitMessagePrefersLineBreaks(t *testing.T) {
+	content := "## 趋势判断\n偏多\n## 操作建议\n逢低买入\n## 风险提示\n注意回撤"
+	parts := SplitMessage(content, 20)
+	for i, p := range parts {
+		for _, line := range strings.Split(p, "\n") {
+			if !strings.Contains(content, line+"\n") && !strings.HasSuffix(content, line) {
+				t.Errorf("第 %d 段在行中间断开: %q", i+1, p)
+			}
+		}
+		if strings.HasPrefix(p, "\n") || strings.HasSuffix(p, "\n") {
+			t.Errorf("第 %d 段不应含首尾空行: %q", i+1, p)
+		}
+	}
+}
+
+func TestSendTelegramSplitsAndPostsJSON(t *testing.T) {
+	var texts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot123:abc/sendMessage" {
+			t.Errorf("请求路径 = %s", r.URL.Path)
+		}
+		var body struct {
+			ChatID string `json:"chat_id"`
+			Text   string `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.ChatID != "42" {
+			t.Errorf("chat_id = %q", body.ChatID)
+		}
+		texts = append(texts, body.Text)
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer srv.Close()
+	old := TelegramAPIBase
+	TelegramAPIBase = srv.URL
+	defer func() { TelegramAPIBase = old }()
+
+	content := strings.Repeat(strings.Repeat("x", 99)+"\n", 60) // 6000 字符
+	if err := SendTelegram("123:abc", "42", content); err != nil {
+		t.Fatal(err)
+	}
+	if len(texts) != 2 {
+		t.Errorf("6000 字符应分 2 段发送，实际 %d 段", len(texts))
+	}
+}
+
+func TestSendTelegramErrorHidesToken(t *testing.T) {
+	const token = "123456:SECRET-token"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	base := srv.URL
+	srv.Close()
+	old := TelegramAPIBase
+	TelegramAPIBase = base
+	defer func() { TelegramAPIBase = old }()
+
+	err := SendTelegram(token, "42", "报告")
+	if err == nil {
+		t.Fatal("服务不可达时应返回错误")
+	}
+	if strings.Contains(err.Error(), "SECRET") {
+		t.Errorf("错误信息泄露了 Bot Token: %v", err)
+	}
+}
+
+func TestPostJSONTimeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-release }))
+	defer srv.Close()
+	defer close(release)
+	old := PushTimeout
+	PushTimeout = 50 * time.Millisecond
+	defer func() { PushTimeout = old }()
+
+	start := time.Now()
+	if err := SendSlack(srv.URL, "报告"); err == nil {
+		t.Fatal("超时应返回错误")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("推送未按超时返回，耗时 %v", elapsed)
+	}
+}
+
+func TestRenderWebhookMessageFields(t *testing.T) {
+	old := FeedBaseURL
+	FeedBaseURL = "https://example.com/reports/"
+	defer func() { FeedBaseURL = old }()
+
+	result := AnalysisResult{
+		StockCode: "600036",
+		SavedFile: "600036_20240628.md",
+		Report:    "## 主要结论\n趋势判断：看涨\n操作建议：逢低买入\n目标价：40.5 元",
+	}
+	ctx := NewWebhookContext(result, time.Date(2024, 6, 28, 15, 30, 0, 0, time.Local))
+	got, err := RenderWebhookMessage("[{{.StockCode}}|{{.Market}}] {{.Recommendation}} 目标 {{.TargetPrice}}\n{{.Link}} {{.Time}}", ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[600036|A股] 操作建议：逢低买入 目标 40.5\nhttps://example.com/reports/600036_20240628.md 2024-06-28 15:30"
+	if got != want {
+		t.Errorf("渲染结果 = %q，期望 %q", got, want)
+	}
+	if !strings.HasPrefix(ctx.Summary, "主要结论 趋势判断：看涨") || ctx.Trend != "趋势判断：看涨" {
+		t.Errorf("摘要/趋势字段错误: %+v", ctx)
+	}
+	if got, err := RenderWebhookMessage("  ", ctx); err != nil || got != result.Report {
+		t.Errorf("模板为空时应返回报告全文: %q %v", got, err)
+	}
+}
+
+func TestRenderWebhookMessageErrors(t *testing.T) {
+	ctx := WebhookContext{StockCode: "600036", Report: "报告全文"}
+	for _, tmpl := range []string{"{{.StockCode", "{{.NoSuchField}}"} {
+		if _, err := RenderWebhookMessage(tmpl, ctx); err == nil {
+			t.Errorf("模板 %q 应返回错误", tmpl)
+		}
+	}
+}
+
+func TestSendWebhookFallsBackOnTemplateError(t *testing.T) {
+	var texts []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			MsgType string `json:"msgtype"`
+			Text    struct {
+				Content string `json:"content"`
+			} `json:"text"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		texts = append(texts, body.Text.Content)
+		w.Write([]byte(`{"errcode":0}`))
+	}))
+	defer srv.Close()
+	old := WebhookTemplate
+	defer func() { WebhookTemplate = old }()
+
+	result := AnalysisResult{StockCode: "AAPL", Report: "报告全文"}
+	WebhookTemplate = "{{.StockCode}} 摘要：{{.Summary}}"
+	if err := SendWebhook(srv.URL, result); err != nil {
+		t.Fatal(err)
+	}
+	WebhookTemplate = "{{.Missing}}"
+	if err := SendWebhook(srv.URL, result); err != nil {
+		t.Fatal(err)
+	}
+	if len(texts) != 2 || texts[0] != "AAPL 摘要：报告全文" || texts[1] != "报告全文" {
+		t.Errorf("推送内容 = %q，渲染失败时应回退为报告全文", texts)
+	}
+}
+
+// webhookRequest 机器人收到的一次推送
+type webhookRequest struct {
+	Query url.Values
+	Body  struct {
+		MsgType  string `json:"msgtype"`
+		Markdown struct {
+			Title   string `json:"title"`
+			Text    string `json:"text"`
+			Content string `json:"content"`
+		} `json:"markdown"`
+	}
+}
+
+// captureWebhook 启动模拟钉钉/企业微信机器人，记录每次推送并以 reply 应答
+func captureWebhook(t *testing.T, reply string) (*httptest.Server, *[]webhookRequest) {
+	t.Helper()
+	var reqs []webhookRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := webhookRequest{Query: r.URL.Query()}
+		json.NewDecoder(r.Body).Decode(&req.Body)
+		reqs = append(reqs, req)
+		w.Write([]byte(reply))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func TestSendWebhookMarkdownSignsRequest(t *testing.T) {
+	srv, reqs := captureWebhook(t, `{"errcode":0}`)
+	old := WebhookSecret
+	defer func() { WebhookSecret = old }()
+
+	WebhookSecret = "SECtest"
+	before := time.Now().UnixMilli()
+	if err := SendWebhookMarkdown(srv.URL+"/robot/send?access_token=abc", "600036 分析报告", "| 指标 | 数值 |\n|---|---|\n| 市盈率 | 8.2 |"); err != nil {
+		t.Fatal(err)
+	}
+	req := (*reqs)[0]
+	if req.Body.MsgType != "markdown" || req.Body.Markdown.Title != "600036 分析报告" || !strings.Contains(req.Body.Markdown.Text, "| 市盈率 | 8.2 |") {
+		t.Errorf("钉钉 markdown 请求体 = %+v", req.Body)
+	}
+	ts, _ := strconv.ParseInt(req.Query.Get("timestamp"), 10, 64)
+	if ts < before || ts > time.Now().UnixMilli() || req.Query.Get("access_token") != "abc" {
+		t.Fatalf("timestamp 应为当前毫秒时间且保留原有参数: %v", req.Query)
+	}
+	mac := hmac.New(sha256.New, []byte("SECtest"))
+	mac.Write([]byte(req.Query.Get("timestamp") + "\nSECtest"))
+	if want := base64.StdEncoding.EncodeToString(mac.Sum(nil)); req.Query.Get("sign") != want {
+		t.Errorf("sign = %q，期望 %q", req.Query.Get("sign"), want)
+	}
+
+	WebhookSecret = ""
+	// 企业微信按地址识别，markdown 只有 content 字段
+	if err := SendWebhookMarkdown(srv.URL+"/qyapi.weixin.qq.com/send", "标题", "**看涨**"); err != nil {
+		t.Fatal(err)
+	}
+	req = (*reqs)[1]
+	if req.Query.Has("sign") || req.Query.Has("timestamp") {
+		t.Errorf("未配置密钥时不应加签: %v", req.Query)
+	}
+	if req.Body.MsgType != "markdown" || req.Body.Markdown.Content != "**看涨**" || req.Body.Markdown.Title != "" {
+		t.Errorf("企业微信 markdown 请求体 = %+v", req.Body)
+	}
+}
+
+func TestSendWebhookMarkdownMode(t *testing.T) {
+	srv, reqs := captureWebhook(t, `{"errcode":0}`)
+	oldMarkdown, oldLimit, oldTemplate, oldBase := WebhookMarkdown, WebhookMarkdownLimit, WebhookTemplate, FeedBaseURL
+	defer func() {
+		WebhookMarkdown, WebhookMarkdownLimit, WebhookTemplate, FeedBaseURL = oldMarkdown, oldLimit, oldTemplate, oldBase
+	}()
+	WebhookMarkdown, WebhookMarkdownLimit, WebhookTemplate, FeedBaseURL = true, 200, "", "https://quantix.example.com"
+
+	result := AnalysisResult{StockCode: "600036", Report: strings.Repeat("| 短期 | 看涨 |\n", 40), SavedFile: "600036-20240628.html"}
+	if err := SendWebhook(srv.URL, result); err != nil {
+		t.Fatal(err)
+	}
+	text := (*reqs)[0].Body.Markdown.Text
+	if (*reqs)[0].Body.MsgType != "markdown" || len(text) > 200 || !strings.HasSuffix(text, "[查看完整报告](https://quantix.example.com/600036-20240628.html)") {
+		t.Errorf("超长报告应截断并附完整报告链接（%d 字节）:\n%s", len(text), text)
+	}
+	if kept, _, _ := strings.Cut(text, "\n\n>"); strings.Trim(strings.ReplaceAll(kept, "| 短期 | 看涨 |", ""), "\n") != "" {
+		t.Errorf("应在行边界截断，不留半行:\n%s", text)
+	}
+
+	srv, _ = captureWebhook(t, `{"errcode":310000,"errmsg":"sign not match"}`)
+	if err := SendWebhookMarkdown(srv.URL, "标题", "内容"); err == nil || !strings.Contains(err.Error(), "sign not match") {
+		t.Errorf("errcode 非 0 应返回错误: %v", err)
+	}
+}
+
+func TestTruncateMarkdownKeepsUTF8Boundary(t *testing.T) {
+	if got := truncateMarkdown("短内容", 100, ""); got != "短内容" {
+		t.Errorf("未超长时应原样返回: %q", got)
+	}
+	got := truncateMarkdown(strings.Repeat("看涨", 100), 120, "")
+	if len(got) > 120 || !utf8.ValidString(got) || !strings.HasSuffix(got, "请查看完整报告") {
+		t.Errorf("无换行时应按字符边界截断（%d 字节）: %q", len(got), got)
+	}
+}
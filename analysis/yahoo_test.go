@@ -0,0 +1,70 @@
+package analysis
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// yahooTransport 以本地 chart 样本响应请求，并记录请求的 URL
+type yahooTransport struct {
+	body []byte
+	urls []string
+}
+
+func (tr *yahooTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	tr.urls = append(tr.urls, r.URL.String())
+	return &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(tr.body)), Request: r}, nil
+}
+
+func TestParseYahooChartSkipsNullBars(t *testing.T) {
+	body, err := os.ReadFile("testdata/yahoo_chart.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := parseYahooChart(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 4 {
+		t.Fatalf("含 null 的节假日K线应被跳过，期望 4 根，得到 %d", len(data))
+	}
+	first, third := data[0], data[2]
+	if !first.Date.Equal(time.Unix(1719235800, 0)) || first.Open != 192.21 || first.High != 193.31 || first.Low != 191.16 || first.Close != 191.79 || first.Volume != 58923100 {
+		t.Errorf("第 1 根K线 = %+v", first)
+	}
+	if !third.Date.Equal(time.Unix(1719495000, 0)) || third.Close != 208.50 {
+		t.Errorf("null 之后的K线应顺延: %+v", third)
+	}
+
+	if _, err := parseYahooChart([]byte(`{"chart":{"result":null,"error":{"code":"Not Found","description":"No data found, symbol may be delisted"}}}`)); err == nil || !strings.Contains(err.Error(), "Not Found") {
+		t.Errorf("接口错误应原样返回: %v", err)
+	}
+	if _, err := parseYahooChart([]byte(`{"chart":{"result":[]}}`)); err == nil {
+		t.Error("无结果时应返回错误")
+	}
+}
+
+func TestFetchFromYahooUsesChartAPI(t *testing.T) {
+	body, err := os.ReadFile("testdata/yahoo_chart.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &yahooTransport{body: body}
+	old := FetchTransport
+	FetchTransport = tr
+	defer func() { FetchTransport = old }()
+
+	data, err := fetchFromYahoo(context.Background(), "AAPL")
+	if err != nil || len(data) != 4 {
+		t.Fatalf("获取 AAPL 行情: %d 根, err=%v", len(data), err)
+	}
+	if len(tr.urls) != 1 || !strings.HasPrefix(tr.urls[0], "https://query1.finance.yahoo.com/v8/finance/chart/AAPL?") || !strings.Contains(tr.urls[0], "interval=1d") {
+		t.Errorf("应请求 v8 chart 接口: %v", tr.urls)
+	}
+}
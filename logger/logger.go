@@ -0,0 +1,110 @@
+// Package logger 配置标准库 log 的输出：stdout、stderr，或按大小与天数轮转的日志文件；
+// analysis 包的 [标签] 运行日志均经由 log 输出
+package logger
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// 输出方式
+const (
+	OutputStdout = "stdout"
+	OutputStderr = "stderr"
+	OutputFile   = "file"
+)
+
+// LogConfig 日志配置，轮转参数仅在 Output 为 file 时生效，为 0 时使用默认值
+type LogConfig struct {
+	Output     string // stdout/stderr/file，为空时同 stdout
+	File       string // file 模式的日志文件路径
+	MaxSizeMB  int    // 单个文件达到该大小（MB）后轮转，默认 100
+	MaxBackups int    // 保留的轮转文件数，默认 5
+	MaxAgeDays int    // 轮转文件保留天数，默认 30
+	Compress   bool   // 轮转文件是否 gzip 压缩
+}
+
+// 轮转参数默认值
+const (
+	DefaultMaxSizeMB  = 100
+	DefaultMaxBackups = 5
+	DefaultMaxAgeDays = 30
+)
+
+var (
+	mu      sync.Mutex
+	current *lumberjack.Logger // file 模式下正在使用的轮转文件，重新初始化时关闭
+)
+
+// InitLogger 按配置设置 log 的输出；控制台输出不加时间前缀，与原先的打印一致，file 模式每行带日期时间。
+// file 模式下目录无法创建或文件无法写入时返回错误，log 输出保持不变
+func InitLogger(cfg LogConfig) error {
+	var w io.Writer
+	flags := 0
+	switch cfg.Output {
+	case "", OutputStdout:
+		w = os.Stdout
+	case OutputStderr:
+		w = os.Stderr
+	case OutputFile:
+		lj, err := newRotatingFile(cfg)
+		if err != nil {
+			return err
+		}
+		w, flags = lj, log.LstdFlags
+	default:
+		return fmt.Errorf("日志输出仅支持 stdout/stderr/file: %s", cfg.Output)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	log.SetOutput(w)
+	log.SetFlags(flags)
+	if current != nil {
+		current.Close()
+	}
+	current, _ = w.(*lumberjack.Logger)
+	return nil
+}
+
+// newRotatingFile 创建轮转日志文件，先试打开一次以便尽早暴露权限等错误
+func newRotatingFile(cfg LogConfig) (*lumberjack.Logger, error) {
+	if cfg.File == "" {
+		return nil, fmt.Errorf("日志输出为 file 时须指定日志文件")
+	}
+	if cfg.MaxSizeMB < 0 || cfg.MaxBackups < 0 || cfg.MaxAgeDays < 0 {
+		return nil, fmt.Errorf("日志轮转参数不能为负: 大小 %dMB，保留 %d 份，%d 天", cfg.MaxSizeMB, cfg.MaxBackups, cfg.MaxAgeDays)
+	}
+	if err := os.MkdirAll(filepath.Dir(cfg.File), 0755); err != nil {
+		return nil, fmt.Errorf("创建日志目录失败: %v", err)
+	}
+	f, err := os.OpenFile(cfg.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("打开日志文件失败: %v", err)
+	}
+	f.Close()
+	lj := &lumberjack.Logger{
+		Filename:   cfg.File,
+		MaxSize:    DefaultMaxSizeMB,
+		MaxBackups: DefaultMaxBackups,
+		MaxAge:     DefaultMaxAgeDays,
+		Compress:   cfg.Compress,
+		LocalTime:  true,
+	}
+	if cfg.MaxSizeMB > 0 {
+		lj.MaxSize = cfg.MaxSizeMB
+	}
+	if cfg.MaxBackups > 0 {
+		lj.MaxBackups = cfg.MaxBackups
+	}
+	if cfg.MaxAgeDays > 0 {
+		lj.MaxAge = cfg.MaxAgeDays
+	}
+	return lj, nil
+}
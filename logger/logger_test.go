@@ -0,0 +1,69 @@
+package logger
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInitLoggerRotatesPastMaxSize(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "quantix.log")
+	if err := InitLogger(LogConfig{Output: OutputFile, File: file, MaxSizeMB: 1, MaxBackups: 3}); err != nil {
+		t.Fatal(err)
+	}
+	defer InitLogger(LogConfig{Output: OutputStderr})
+	if log.Flags() != log.LstdFlags {
+		t.Errorf("日志文件每行应带日期时间: flags = %d", log.Flags())
+	}
+
+	line := strings.Repeat("x", 1023)
+	for i := 0; i < 1536; i++ { // 约 1.5MB，超过 1MB 阈值
+		log.Print(line)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rotated []string
+	for _, e := range entries {
+		if e.Name() != "quantix.log" && strings.HasPrefix(e.Name(), "quantix-") {
+			rotated = append(rotated, e.Name())
+		}
+	}
+	if len(rotated) == 0 {
+		t.Fatalf("写入超过 1MB 后应产生轮转文件，目录内容: %v", entries)
+	}
+	info, err := os.Stat(file)
+	if err != nil || info.Size() > 1<<20 {
+		t.Errorf("当前日志文件应小于 1MB: %v, %v", info, err)
+	}
+}
+
+func TestInitLoggerReturnsErrors(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "not-a-dir")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for name, cfg := range map[string]LogConfig{
+		"未知输出":  {Output: "syslog"},
+		"缺少文件":  {Output: OutputFile},
+		"目录不可建": {Output: OutputFile, File: filepath.Join(blocker, "quantix.log")},
+		"参数为负":  {Output: OutputFile, File: filepath.Join(t.TempDir(), "a.log"), MaxSizeMB: -1},
+	} {
+		if err := InitLogger(cfg); err == nil {
+			t.Errorf("%s: 应返回错误", name)
+		}
+	}
+	if err := InitLogger(LogConfig{Output: OutputStdout}); err != nil {
+		t.Errorf("stdout: %v", err)
+	}
+	if err := InitLogger(LogConfig{}); err != nil {
+		t.Errorf("默认 stdout: %v", err)
+	}
+	if log.Flags() != 0 {
+		t.Errorf("控制台输出不应带时间前缀: flags = %d", log.Flags())
+	}
+}
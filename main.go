@@ -2,18 +2,22 @@ package main
 
 import (
 	"Quantix/analysis"
+	"Quantix/logger"
 	"bufio"
+	"context"
 	"encoding/csv"
-	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/AlecAivazis/survey/v2"
@@ -24,9 +28,22 @@ import (
 
 var globalAPIKey string // 全局缓存API Key
 
+// maskKey 显示用的 API Key：超过 8 位时只显示前 8 位，否则全部打码，避免短 Key 截断越界或整段泄露
+func maskKey(k string) string {
+	r := []rune(k)
+	switch {
+	case len(r) == 0:
+		return "(未设置)"
+	case len(r) > 8:
+		return string(r[:8]) + "..."
+	default:
+		return strings.Repeat("*", len(r))
+	}
+}
+
 func promptForAPIKey() string {
 	if globalAPIKey != "" {
-		fmt.Printf("当前API Key: %s...\n", globalAPIKey[:8])
+		fmt.Printf("当前API Key: %s\n", maskKey(globalAPIKey))
 		fmt.Print("是否更换API Key? (Y/N, 默认N): ")
 		reader := bufio.NewReader(os.Stdin)
 		input, _ := reader.ReadString('\n')
@@ -46,37 +63,21 @@ func promptForAPIKey() string {
 	return key
 }
 
-func fetchDeepSeekModels(apiKey string, _ string) ([]string, error) {
-	// 直接用 DeepSeek 官方 API
-	req, err := http.NewRequest("GET", "https://api.deepseek.com/models", nil)
-	if err != nil {
-		return nil, err
-	}
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-	req.Header.Set("Accept", "application/json")
-	client := &http.Client{}
-	resp, err := client.Do(req)
+// checkDeepSeekBalance 查询并打印 DeepSeek 余额，余额不足时警告；接口不支持时静默跳过
+func checkDeepSeekBalance(apiKey string) {
+	info, err := analysis.CheckBalance(apiKey)
 	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("http status: %d", resp.StatusCode)
-	}
-	var result struct {
-		Data []struct {
-			ID string `json:"id"`
-		} `json:"data"`
+		fmt.Println("[余额] 查询失败，跳过：", err)
+		return
 	}
-	err = json.NewDecoder(resp.Body).Decode(&result)
-	if err != nil {
-		return nil, err
+	if !info.Supported {
+		return
 	}
-	models := make([]string, 0, len(result.Data))
-	for _, m := range result.Data {
-		models = append(models, m.ID)
+	if warn := info.Warning(); warn != "" {
+		fmt.Println("[余额] ⚠️ ", warn)
+		return
 	}
-	return models, nil
+	fmt.Printf("[余额] 当前余额 %.2f %s\n", info.Total, info.Currency)
 }
 
 func promptForModel(models []string) string {
@@ -252,40 +253,19 @@ func promptForPredictionOptions() (periods, dims, searchScope []string, outputFo
 	return
 }
 
+// splitAndTrim 按逗号拆分输入，去除首尾空格、剔除空项并去重，保持首次出现的顺序
 func splitAndTrim(s string) []string {
-	arr := strings.Split(s, ",")
-	for i := range arr {
-		arr[i] = strings.TrimSpace(arr[i])
-	}
-	return arr
-}
-
-func listHistoryFiles() {
-	files, err := ioutil.ReadDir("history")
-	if err != nil {
-		fmt.Println("[历史记录] 无法读取 history 目录：", err)
-		return
-	}
-	if len(files) == 0 {
-		fmt.Println("[历史记录] 暂无历史分析记录。")
-		return
-	}
-	fmt.Println("[历史记录] 可用分析记录：")
-	for _, f := range files {
-		if !f.IsDir() {
-			fmt.Println(f.Name())
+	var arr []string
+	seen := make(map[string]bool)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
 		}
+		seen[item] = true
+		arr = append(arr, item)
 	}
-}
-
-func showHistoryFile(filename string) {
-	path := filepath.Join("history", filename)
-	data, err := ioutil.ReadFile(path)
-	if err != nil {
-		fmt.Println("[历史记录] 读取失败：", err)
-		return
-	}
-	fmt.Println(string(data))
+	return arr
 }
 
 func mainMenu() {
@@ -314,12 +294,12 @@ func mainMenu() {
 		case menuOptions[1]:
 			aiScheduleInteractiveMenu()
 		case menuOptions[2]:
-			listHistoryFiles()
+			analysis.ListHistoryFiles()
 		case menuOptions[3]:
 			var filename string
 			_ = survey.AskOne(&survey.Input{Message: "请输入文件名:"}, &filename)
 			if filename != "" {
-				showHistoryFile(filename)
+				analysis.ShowHistoryFile(filename)
 			}
 		case menuOptions[4]:
 			globalAPIKey = ""
@@ -470,18 +450,74 @@ func printStepBox(title string, lines ...string) {
 	fmt.Println("└" + strings.Repeat("─", width-2) + "┘")
 }
 
-func aiAnalysisInteractiveMenu() {
-	reader := bufio.NewReader(os.Stdin)
+// printReportSections 按维度小节分块展示报告，只展开用户选择的维度，其余折叠为一行
+func printReportSections(report string) {
+	sections := analysis.SplitReportSections(report)
+	if len(sections) <= 1 {
+		printStepBox("AI 智能分析报告", strings.Split(report, "\n")...)
+		return
+	}
+	options := make([]string, len(sections))
+	var defaults []string
+	for i, sec := range sections {
+		options[i] = fmt.Sprintf("%d. %s", i+1, sec.Title)
+		if i == 0 || strings.Contains(sec.Title, "结论") || strings.Contains(sec.Title, "建议") {
+			defaults = append(defaults, options[i])
+		}
+	}
+	for {
+		selected := interactiveSelectList("请选择要展开的维度（未选中的将折叠显示）：", options, defaults)
+		expand := make(map[string]bool, len(selected))
+		for _, o := range selected {
+			expand[o] = true
+		}
+		for i, sec := range sections {
+			if expand[options[i]] {
+				printStepBox(sec.Title, sec.Lines...)
+			} else {
+				fmt.Printf("▶ %s（%d 行，已折叠）\n", sec.Title, len(sec.Lines))
+			}
+		}
+		if !interactiveConfirm("是否重新选择展开的维度？", false) {
+			return
+		}
+		defaults = selected
+	}
+}
+
+// pushConfig 交互菜单中收集的邮件/IM 推送配置
+type pushConfig struct {
+	Emails     []string
+	SMTPServer string
+	SMTPPort   int
+	SMTPUser   string
+	SMTPPass   string
+	Webhook    string
+	// Telegram 推送，Token 与 ChatID 均填写时生效
+	TelegramToken string
+	TelegramChat  string
+}
 
+// collectAnalysisParams 分析与定时菜单共用的 Step 0~Step 11 参数交互，header 为选择大模型后打印的菜单说明；
+// 返回分析参数、分析模式列表、详细程度（normal/detailed/extreme）与推送配置
+func collectAnalysisParams(reader *bufio.Reader, header ...string) (analysis.AnalysisParams, []string, string, pushConfig, error) {
 	// Step 0: 选择大模型
-	llmOptions := []string{"DeepSeek", "Gemini"}
+	var llmOptions []string
+	for _, opt := range []string{"DeepSeek", "Gemini"} {
+		if err := analysis.CheckLLMAllowed(analysis.LLMProviderURL(opt)); err != nil {
+			fmt.Printf("[合规] %s 不可选：%v\n", opt, err)
+			continue
+		}
+		llmOptions = append(llmOptions, opt)
+	}
+	if len(llmOptions) == 0 {
+		return analysis.AnalysisParams{}, nil, "", pushConfig{}, fmt.Errorf("合规限制：没有允许使用的大模型，请检查 -allow-llm 配置")
+	}
 	llmType := interactiveSingleSelect("请选择大模型：", llmOptions, llmOptions[0])
 
-	fmt.Println("\n================= AI 智能分析配置 =================")
-	fmt.Println("单选：使用 ↑↓ 箭头键移动选择，回车键确认")
-	fmt.Println("多选：使用 ↑↓ 箭头键移动，空格键选择/取消，右箭头键全选，左箭头键全不选，回车键确认")
-	fmt.Println("按 ? 键可查看详细操作说明")
-	fmt.Println("==================================================")
+	for _, line := range header {
+		fmt.Println(line)
+	}
 
 	// Step 1: API Key & 模型
 	var apiKey string
@@ -492,20 +528,20 @@ func aiAnalysisInteractiveMenu() {
 			"说明：用于访问 DeepSeek LLM 服务",
 		)
 		apiKey = promptForAPIKey()
-		printStepBox("Step 0: API Key", fmt.Sprintf("[当前API Key]: %s...", func() string {
-			if len(apiKey) > 8 {
-				return apiKey[:8]
-			} else {
-				return apiKey
-			}
-		}()))
+		printStepBox("Step 0: API Key", fmt.Sprintf("[当前API Key]: %s", maskKey(apiKey)))
 		printStepBox("Step 1: AI Model",
 			"选择要使用的AI模型",
 			"说明：不同模型分析能力和速度略有差异",
 			"Default: 自动推荐 DeepSeek 模型",
 			"正在获取可用 DeepSeek 模型...",
 		)
-		models, _ = fetchDeepSeekModels(apiKey, "")
+		var modelsErr error
+		if models, modelsErr = analysis.FetchDeepSeekModels(apiKey, ""); modelsErr != nil {
+			fmt.Println("[模型]", modelsErr)
+		}
+		if interactiveConfirm("是否查询 DeepSeek 账户余额？", true) {
+			checkDeepSeekBalance(apiKey)
+		}
 		deepseekModels := make([]string, 0)
 		for _, m := range models {
 			if strings.Contains(m, "deepseek") {
@@ -528,14 +564,18 @@ func aiAnalysisInteractiveMenu() {
 			apiKey = strings.TrimSpace(apiKey)
 		}
 		if apiKey == "" {
-			fmt.Println("未检测到 Gemini API Key，无法继续。")
-			return
+			return analysis.AnalysisParams{}, nil, "", pushConfig{}, fmt.Errorf("未检测到 Gemini API Key，无法继续。")
 		}
 		printStepBox("Step 1: AI Model",
 			"选择要使用的Gemini模型",
 			"说明：不同模型分析能力和速度略有差异",
+			"正在获取可用 Gemini 模型...",
 		)
-		models = []string{"gemini-1.5-flash", "gemini-1.5-pro", "gemini-2.5-flash", "gemini-2.5-pro"}
+		var err error
+		models, err = analysis.FetchGeminiModels(apiKey)
+		if err != nil {
+			fmt.Println("[Gemini] 获取模型列表失败，使用内置默认模型：", err)
+		}
 	}
 	model := promptForModel(models)
 	printStepBox("Step 1: AI Model", fmt.Sprintf("[当前选择]: %s", model))
@@ -549,8 +589,7 @@ func aiAnalysisInteractiveMenu() {
 	stockInput := interactiveInput("请输入股票代码（可批量，逗号分隔）:", "")
 	stockCodes := splitAndTrim(stockInput)
 	if len(stockCodes) == 0 || stockCodes[0] == "" {
-		fmt.Println("股票代码不能为空！")
-		return
+		return analysis.AnalysisParams{}, nil, "", pushConfig{}, fmt.Errorf("股票代码不能为空！")
 	}
 	printStepBox("Step 2: Ticker Symbol", fmt.Sprintf("[当前选择]: %s", strings.Join(stockCodes, ", ")))
 
@@ -664,9 +703,9 @@ func aiAnalysisInteractiveMenu() {
 	// Step 7: 导出格式
 	printStepBox("Step 7: Export Format",
 		"Select export format(s)",
-		"说明：可多选，支持 Markdown/HTML/PDF",
+		"说明：可多选，支持 Markdown/HTML/PDF/JSON",
 	)
-	exportOptions := []string{"Markdown", "HTML", "PDF"}
+	exportOptions := []string{"Markdown", "HTML", "PDF", "JSON"}
 	defaultExport := []string{"Markdown"}
 	exportResult := interactiveSelectList("请选择导出格式（可多选）：", exportOptions, defaultExport)
 	exportFormats := make([]string, 0, len(exportResult))
@@ -678,6 +717,8 @@ func aiAnalysisInteractiveMenu() {
 			exportFormats = append(exportFormats, "html")
 		case "PDF":
 			exportFormats = append(exportFormats, "pdf")
+		case "JSON":
+			exportFormats = append(exportFormats, "json")
 		}
 	}
 	if len(exportFormats) == 0 {
@@ -692,15 +733,16 @@ func aiAnalysisInteractiveMenu() {
 	emailInput := interactiveInput("如需邮件推送请输入收件人邮箱（可逗号分隔，留空跳过）:", "")
 	emails := splitAndTrim(emailInput)
 	var smtpServer, smtpUser, smtpPass string
+	smtpPort := 465
 	if len(emails) > 0 && emails[0] != "" {
 		fmt.Println("SMTP服务器、端口、用户名、密码依次输入：")
 		fmt.Print("SMTP服务器: ")
 		smtpServer, _ = reader.ReadString('\n')
 		smtpServer = strings.TrimSpace(smtpServer)
-		fmt.Print("SMTP端口(默认465): ")
-		portInput := interactiveInput("SMTP端口(默认465):", "")
-		if portInput == "" {
-			// smtpPort = 465
+		fmt.Print("SMTP端口(默认465，587 使用 STARTTLS，25 明文): ")
+		portInput := interactiveInput("SMTP端口(默认465，587 使用 STARTTLS，25 明文):", "")
+		if port, err := strconv.Atoi(strings.TrimSpace(portInput)); err == nil && port > 0 {
+			smtpPort = port
 		}
 		fmt.Print("SMTP用户名: ")
 		smtpUser, _ = reader.ReadString('\n')
@@ -713,10 +755,15 @@ func aiAnalysisInteractiveMenu() {
 
 	// Step 9: IM 推送
 	printStepBox("Step 9: IM Push",
-		"如需IM推送请输入Webhook地址（钉钉/企业微信，留空跳过）",
+		"如需IM推送请输入Webhook地址（钉钉/企业微信/Slack，留空跳过），或填写Telegram Bot Token与Chat ID",
 	)
-	webhook := interactiveInput("如需IM推送请输入Webhook地址（钉钉/企业微信，留空跳过）:", "")
-	printStepBox("Step 9: IM Push", fmt.Sprintf("[当前Webhook]: %s", webhook))
+	webhook := interactiveInput("如需IM推送请输入Webhook地址（钉钉/企业微信/Slack，留空跳过）:", "")
+	telegramToken := interactiveInput("如需Telegram推送请输入Bot Token（留空跳过）:", "")
+	var telegramChat string
+	if telegramToken != "" {
+		telegramChat = interactiveInput("Telegram Chat ID:", "")
+	}
+	printStepBox("Step 9: IM Push", fmt.Sprintf("[当前Webhook]: %s", webhook), fmt.Sprintf("[Telegram Chat]: %s", telegramChat))
 
 	// Step 10: 分析详细程度
 	printStepBox("Step 10: Research Depth",
@@ -746,12 +793,28 @@ func aiAnalysisInteractiveMenu() {
 	}
 	printStepBox("Step 10: Research Depth", fmt.Sprintf("[当前选择]: %s", detailText))
 
+	// Step 10.5: 生成风格（temperature）
+	printStepBox("Step 10.5: Generation Style",
+		"Select generation style",
+		"说明：稳健输出更确定，创造性输出更发散",
+	)
+	styleOptions := []string{"默认（temperature 0.7）", "稳健（temperature 0.3）", "创造性（temperature 1.0）"}
+	style := interactiveSingleSelect("请选择生成风格：", styleOptions, styleOptions[0])
+	var temperature float64 // 0 表示使用默认值
+	switch style {
+	case "稳健（temperature 0.3）":
+		temperature = 0.3
+	case "创造性（temperature 1.0）":
+		temperature = 1.0
+	}
+	printStepBox("Step 10.5: Generation Style", fmt.Sprintf("[当前选择]: %s", style))
+
 	// Step 11: 回测策略类型与参数
 	printStepBox("Step 11: Backtest Strategy",
 		"选择回测策略类型及参数",
-		"支持：均线交叉、突破、RSI等",
+		"支持：均线交叉、突破、RSI、ATR追踪止损等",
 	)
-	strategyOptions := []string{"均线交叉(ma_cross)", "突破(breakout)", "RSI(rsi)"}
+	strategyOptions := []string{"均线交叉(ma_cross)", "突破(breakout)", "RSI(rsi)", "ATR追踪止损(atr_trailing)"}
 	defaultStrategy := "均线交叉(ma_cross)"
 	strategy := interactiveSingleSelect("请选择回测策略类型：", strategyOptions, defaultStrategy)
 
@@ -769,6 +832,11 @@ func aiAnalysisInteractiveMenu() {
 		backtestParams.RSIPeriod = interactiveInputInt("请输入RSI周期（如14）:", 14)
 		backtestParams.RSIOverbought = interactiveInputFloat("请输入RSI超买阈值（如70）:", 70)
 		backtestParams.RSIOversold = interactiveInputFloat("请输入RSI超卖阈值（如30）:", 30)
+	case "ATR追踪止损(atr_trailing)":
+		backtestParams.StrategyType = "atr_trailing"
+		backtestParams.BreakoutPeriod = interactiveInputInt("请输入突破入场周期（如20，0表示改用5/20均线金叉入场）:", 20)
+		backtestParams.ATRPeriod = interactiveInputInt("请输入ATR周期（如14）:", 14)
+		backtestParams.ATRMultiplier = interactiveInputFloat("请输入ATR止损倍数k（如3）:", 3)
 	}
 	// 通用参数
 	backtestParams.StopLoss = interactiveInputFloat("请输入止损百分比（如0.05表示5%）:", 0.05)
@@ -806,10 +874,40 @@ func aiAnalysisInteractiveMenu() {
 		MarketPosition:       contains(predictionItems, "市场定位分析"),
 		CompetitiveAdvantage: contains(predictionItems, "竞争优势分析"),
 		BacktestParams:       &backtestParams,
+		Temperature:          temperature,
 		// 新增：分析模式参数
 		SearchMode:   (searchMode == "联网搜索（结合最新互联网信息）") || (llmType == "Gemini" && model == "gemini-2.5-pro" && searchMode == "联网搜索（Deep Search）"),
 		HybridSearch: searchMode == "深度思考+联网搜索（自动融合）",
 	}
+	push := pushConfig{
+		Emails:     emails,
+		SMTPServer: smtpServer,
+		SMTPPort:   smtpPort,
+		SMTPUser:   smtpUser,
+		SMTPPass:   smtpPass,
+		Webhook:    webhook,
+
+		TelegramToken: telegramToken,
+		TelegramChat:  telegramChat,
+	}
+	return params, searchModes, detailInput, push, nil
+}
+
+func aiAnalysisInteractiveMenu() {
+	reader := bufio.NewReader(os.Stdin)
+	params, searchModes, detailInput, push, err := collectAnalysisParams(reader,
+		"\n================= AI 智能分析配置 =================",
+		"单选：使用 ↑↓ 箭头键移动选择，回车键确认",
+		"多选：使用 ↑↓ 箭头键移动，空格键选择/取消，右箭头键全选，左箭头键全不选，回车键确认",
+		"按 ? 键可查看详细操作说明",
+		"==================================================",
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	stockCodes, start, end := params.StockCodes, params.Start, params.End
+	searchMode := searchModes[0]
 
 	fmt.Println("\n=== 开始AI智能分析 ===")
 	fmt.Printf("分析股票：%s\n", strings.Join(stockCodes, ", "))
@@ -828,26 +926,34 @@ func aiAnalysisInteractiveMenu() {
 	}())
 	fmt.Println("正在生成分析报告，请稍候...")
 
-	prompt := buildPromptWithDetail(params, detailInput)
 	done := make(chan struct{})
+	stopAnimation := sync.OnceFunc(func() { close(done) })
 	go showAnalyzingAnimation(done)
-	results := make([]analysis.AnalysisResult, 0, len(params.StockCodes)*len(searchModes))
-	for _, mode := range searchModes {
-		for _, code := range params.StockCodes {
-			p := params
-			p.StockCodes = []string{code}
-			p.SearchMode = (mode == "联网搜索（结合最新互联网信息）")
-			p.HybridSearch = (mode == "深度思考+联网搜索（自动融合）")
-			result := analysis.AnalyzeOne(p, func(stock, _prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool) (string, error) {
-				return analysis.GenerateAIReportWithConfigAndSearch(stock, prompt, apiKey, "https://api.deepseek.com/v1/chat/completions", model, searchMode, hybridSearch)
+	jobs := batchParams(params, searchModes, detailInput)
+	// 分析期间 Ctrl+C 取消进行中的请求，结束后恢复默认的退出行为
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	genFunc := func(stock, prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool) (string, error) {
+		return analysis.GenerateAIReportWithGeneration(ctx, stock, prompt, apiKey, apiURL, model, searchMode, hybridSearch, params.Generation())
+	}
+	if len(jobs) == 1 {
+		// 单只股票单一模式时流式输出，正文边生成边打印；并发批量时多路输出会交错，仍等待完整报告
+		genFunc = func(stock, prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool) (string, error) {
+			stopAnimation()
+			fmt.Printf("\n[AI] %s 实时输出：\n", stock)
+			report, err := analysis.GenerateAIReportStream(ctx, stock, prompt, apiKey, apiURL, model, searchMode, hybridSearch, params.Generation(), func(chunk string) {
+				fmt.Print(chunk)
 			})
-			results = append(results, result)
+			fmt.Println()
+			return report, err
 		}
 	}
+	results := analysis.AnalyzeBatch(ctx, jobs, genFunc, analysis.BatchConcurrency)
+	stop()
 	for _, r := range results {
 		fmt.Printf("\n=== [%s] AI 智能分析报告 ===\n", r.StockCode)
 		if r.Err != nil {
-			fmt.Println("[AI] 生成失败:", r.Err)
+			printAnalysisError(r)
 		} else {
 			// 分离图片引用和正文
 			reportLines := strings.Split(r.Report, "\n")
@@ -863,21 +969,21 @@ func aiAnalysisInteractiveMenu() {
 			for _, l := range imgLines {
 				fmt.Println(l)
 			}
-			// 用框输出正文
+			// 按维度分块输出正文，可选择展开的维度
 			if len(textLines) > 0 {
-				printStepBox("AI 智能分析报告", textLines...)
+				printReportSections(strings.Join(textLines, "\n"))
 			}
 			fmt.Printf("[历史已保存: %s]\n", r.SavedFile)
 
 			// 邮件推送
-			if len(emails) > 0 && emails[0] != "" && smtpServer != "" && smtpUser != "" && smtpPass != "" {
+			if len(push.Emails) > 0 && push.Emails[0] != "" && push.SMTPServer != "" && push.SMTPUser != "" && push.SMTPPass != "" {
 				var attachs []string
-				for _, fmtx := range exportFormats {
+				for _, fmtx := range params.Output {
 					if fmtx == "html" {
 						attachs = append(attachs, "history/"+r.SavedFile[:len(r.SavedFile)-5]+"."+fmtx)
 					}
 				}
-				err := analysis.SendEmail(smtpServer, 465, smtpUser, smtpPass, emails, "Quantix分析报告", r.Report, attachs)
+				err := sendReportEmail(push.SMTPServer, push.SMTPPort, push.SMTPUser, push.SMTPPass, push.Emails, r.Report, attachs, contains(params.Output, "html"))
 				if err != nil {
 					fmt.Println("[邮件发送失败]", err)
 				} else {
@@ -885,17 +991,11 @@ func aiAnalysisInteractiveMenu() {
 				}
 			}
 			// IM推送
-			if webhook != "" {
-				err := analysis.SendWebhook(webhook, r.Report)
-				if err != nil {
-					fmt.Println("[IM推送失败]", err)
-				} else {
-					fmt.Println("[IM已推送]")
-				}
-			}
+			pushIM(push.Webhook, push.TelegramToken, push.TelegramChat, r)
 		}
 	}
-	close(done)
+	printSectorSummary(results)
+	stopAnimation()
 
 	// 询问是否继续下一次预测
 	fmt.Println("\n=== 预测完成 ===")
@@ -912,28 +1012,92 @@ func aiAnalysisInteractiveMenu() {
 	return
 }
 
-func parseSchedule(s string) (time.Duration, error) {
-	s = strings.ToLower(strings.TrimSpace(s))
-	if s == "daily" {
-		now := time.Now()
-		next := now.AddDate(0, 0, 1).Truncate(24 * time.Hour)
-		return next.Sub(now), nil
+// printSectorSummary 批量分析包含同板块多只股票时输出并保存板块综述
+func printSectorSummary(results []analysis.AnalysisResult) {
+	sums := analysis.AggregateSectors(results)
+	if len(sums) == 0 {
+		return
+	}
+	printStepBox("板块综述", strings.Split(strings.TrimSpace(analysis.FormatSectorSummary(sums)), "\n")...)
+	if fname, err := analysis.SaveSectorSummary(sums, time.Now()); err != nil {
+		fmt.Println("[板块] 保存板块综述失败:", err)
+	} else {
+		fmt.Printf("[板块综述已保存: %s]\n", fname)
+	}
+}
+
+// printAnalysisError 打印单只股票的分析失败原因，无行情时提示已跳过
+func printAnalysisError(r analysis.AnalysisResult) {
+	if errors.Is(r.Err, analysis.ErrNoData) {
+		fmt.Printf("[数据源] %s 无法获取行情，已跳过: %v\n", r.StockCode, r.Err)
+		return
+	}
+	fmt.Println("[AI] 生成失败:", r.Err)
+}
+
+// pushIM 按配置推送到 webhook（钉钉/企业微信/Slack）与 Telegram，未配置的通道跳过
+func pushIM(webhook, telegramToken, telegramChat string, r analysis.AnalysisResult) {
+	if webhook != "" {
+		if err := analysis.SendWebhook(webhook, r); err != nil {
+			fmt.Println("[IM推送失败]", err)
+		} else {
+			fmt.Println("[IM已推送]")
+		}
+	}
+	if telegramToken != "" && telegramChat != "" {
+		if err := analysis.SendTelegramReport(telegramToken, telegramChat, r); err != nil {
+			fmt.Println("[Telegram推送失败]", err)
+		} else {
+			fmt.Println("[Telegram已推送]")
+		}
+	}
+}
+
+// sendReportEmail 推送单只股票报告，导出格式含 html 时以 HTML 正文发送并内联图表
+func sendReportEmail(server string, port int, user, pass string, to []string, report string, attachs []string, useHTML bool) error {
+	if useHTML {
+		return analysis.SendEmailWithContentType(server, port, user, pass, to, "Quantix分析报告", analysis.EmailReportHTML(report), "text/html", attachs)
 	}
-	if strings.HasSuffix(s, "h") {
-		h, err := strconv.Atoi(strings.TrimSuffix(s, "h"))
-		if err != nil || h <= 0 {
-			return 0, fmt.Errorf("无效的小时数")
+	return analysis.SendEmail(server, port, user, pass, to, "Quantix分析报告", report, attachs)
+}
+
+// sendSubscriptionMails 定时任务每轮结束后按订阅配置把各股票报告分发给对应收件人，未配置订阅文件时跳过
+func sendSubscriptionMails(results []analysis.AnalysisResult, server string, port int, user, pass string) {
+	subs, err := analysis.LoadSubscriptions(analysis.SubscriptionFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			fmt.Println("[邮件订阅]", err)
 		}
-		return time.Duration(h) * time.Hour, nil
+		return
+	}
+	if server == "" || user == "" || pass == "" {
+		fmt.Println("[邮件订阅] 未配置 SMTP，跳过订阅分发")
+		return
+	}
+	sent, errs := analysis.DispatchSubscriptions(subs, results, func(to []string, subject, body string) error {
+		return analysis.SendEmail(server, port, user, pass, to, subject, body, nil)
+	})
+	for _, e := range errs {
+		fmt.Println("[邮件订阅] 发送失败:", e)
 	}
-	if strings.HasSuffix(s, "m") {
-		m, err := strconv.Atoi(strings.TrimSuffix(s, "m"))
-		if err != nil || m <= 0 {
-			return 0, fmt.Errorf("无效的分钟数")
+	fmt.Printf("[邮件订阅] 已按订阅发送 %d 封邮件\n", sent)
+}
+
+// batchParams 按“模式 × 股票”展开为单只股票的分析参数，顺序与报告输出顺序一致
+func batchParams(params analysis.AnalysisParams, searchModes []string, detail string) []analysis.AnalysisParams {
+	list := make([]analysis.AnalysisParams, 0, len(params.StockCodes)*len(searchModes))
+	for _, mode := range searchModes {
+		for _, code := range params.StockCodes {
+			p := params
+			p.StockCodes = []string{code}
+			p.SearchMode = (mode == "联网搜索（结合最新互联网信息）")
+			p.HybridSearch = (mode == "深度思考+联网搜索（自动融合）")
+			// 按单只股票与该模式生成 prompt，AnalyzeOne 在其上追加行情数据后交给 genFunc
+			p.Prompt = buildPromptWithDetail(p, detail)
+			list = append(list, p)
 		}
-		return time.Duration(m) * time.Minute, nil
 	}
-	return 0, fmt.Errorf("不支持的定时格式，仅支持 10m、1h、daily 等")
+	return list
 }
 
 // 定时任务交互式菜单
@@ -943,469 +1107,349 @@ func aiScheduleInteractiveMenu() {
 	fmt.Println("本功能支持自动定时分析、推送，无需人工值守。Ctrl+C 可随时终止。")
 
 	// 复用 aiAnalysisInteractiveMenu 的参数交互
-	// Step 0: 选择大模型
-	llmOptions := []string{"DeepSeek", "Gemini"}
-	llmType := interactiveSingleSelect("请选择大模型：", llmOptions, llmOptions[0])
+	params, searchModes, detailInput, push, err := collectAnalysisParams(reader,
+		"\n================= 定时任务配置 =================",
+		"本功能支持自动定时分析、推送，无需人工值守。Ctrl+C 可随时终止。",
+	)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
 
-	fmt.Println("\n================= 定时任务配置 =================")
-	fmt.Println("本功能支持自动定时分析、推送，无需人工值守。Ctrl+C 可随时终止。")
+	// Step 12: 定时周期
+	scheduleInput := interactiveInput("请输入定时周期（如 1h、10m、daily、weekly:Mon:09:30、cron:30 15 * * 1-5）:", "1h")
+	sched, err := analysis.ParseSchedule(scheduleInput)
+	if err != nil {
+		fmt.Println("[定时任务] 格式错误：", err)
+		return
+	}
 
-	// Step 1: API Key & 模型
-	var apiKey string
-	var models []string
-	if llmType == "DeepSeek" {
-		printStepBox("Step 0: API Key",
-			"请输入 DeepSeek API Key",
-			"说明：用于访问 DeepSeek LLM 服务",
-		)
-		apiKey = promptForAPIKey()
-		printStepBox("Step 0: API Key", fmt.Sprintf("[当前API Key]: %s...", func() string {
-			if len(apiKey) > 8 {
-				return apiKey[:8]
+	fmt.Println("\n=== 定时任务已启动，Ctrl+C 可随时终止 ===")
+	for {
+		fmt.Printf("\n[%s] 批量分析开始\n", time.Now().Format("2006-01-02 15:04:05"))
+		done := make(chan struct{})
+		go showAnalyzingAnimation(done)
+		ctx, cancel := analysis.RoundContext(context.Background())
+		results := analysis.AnalyzeBatch(ctx, batchParams(params, searchModes, detailInput), func(stock, prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool) (string, error) {
+			return analysis.GenerateAIReportWithGeneration(ctx, stock, prompt, apiKey, apiURL, model, searchMode, hybridSearch, params.Generation())
+		}, analysis.BatchConcurrency)
+		cancel()
+		for _, r := range results {
+			fmt.Printf("\n=== [%s] AI 智能分析报告 ===\n", r.StockCode)
+			if r.Err != nil {
+				printAnalysisError(r)
 			} else {
-				return apiKey
-			}
-		}()))
-		printStepBox("Step 1: AI Model",
-			"选择要使用的AI模型",
-			"说明：不同模型分析能力和速度略有差异",
-			"Default: 自动推荐 DeepSeek 模型",
-			"正在获取可用 DeepSeek 模型...",
-		)
-		models, _ = fetchDeepSeekModels(apiKey, "")
-		deepseekModels := make([]string, 0)
-		for _, m := range models {
-			if strings.Contains(m, "deepseek") {
-				deepseekModels = append(deepseekModels, m)
+				// 分离图片引用和正文
+				reportLines := strings.Split(r.Report, "\n")
+				var imgLines, textLines []string
+				for _, l := range reportLines {
+					if strings.HasPrefix(l, "![图表](") {
+						imgLines = append(imgLines, l)
+					} else if strings.TrimSpace(l) != "" {
+						textLines = append(textLines, l)
+					}
+				}
+				// 先输出图片引用
+				for _, l := range imgLines {
+					fmt.Println(l)
+				}
+				// 用框输出正文
+				if len(textLines) > 0 {
+					printStepBox("AI 智能分析报告", textLines...)
+				}
+				fmt.Printf("[历史已保存: %s]\n", r.SavedFile)
 			}
 		}
-		if len(deepseekModels) > 0 {
-			models = deepseekModels
+		printSectorSummary(results)
+		sendSubscriptionMails(results, push.SMTPServer, push.SMTPPort, push.SMTPUser, push.SMTPPass)
+		close(done)
+		// 每轮按当前时间重新计算，daily/weekly/cron 不会因分析耗时而漂移
+		wait := sched.NextRun(time.Now())
+		fmt.Printf("[定时任务] 下一次将在 %s 后运行，Ctrl+C 可终止。\n", wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+}
+
+func main() {
+	survey.ErrorTemplate = `
+{{- color "red"}}提示：{{.Error.Error}}{{color "reset"}}
+`
+	survey.MultiSelectQuestionTemplate = `
+{{- define "option"}}
+    {{- if eq $.SelectedIndex $.CurrentIndex }}{{color "cyan"}}> {{if index $.Checked $.CurrentOpt.Index }}[✓]{{else}}[ ]{{end}} {{$.CurrentOpt.Value}}{{color "reset"}}{{else}}  {{if index $.Checked $.CurrentOpt.Index }}[✓]{{else}}[ ]{{end}} {{$.CurrentOpt.Value}}{{end}}
+{{"\n"}}
+{{- end}}
+{{- if .ShowHelp }}{{color "cyan"}}{{ .Help }}{{color "reset"}}{{"\n"}}{{end}}
+{{- color "cyan"}}{{ .Message }}{{ .FilterMessage }}
+{{- if .ShowAnswer}}{{color "cyan"}} {{.Answer}}{{color "reset"}}{{"\n"}}
+{{- else }}
+  {{- "  "}}{{- color "cyan"}}[使用箭头键移动，空格键选择，右箭头键全选，左箭头键全不选，输入过滤，? 查看帮助]{{color "reset"}}
+  {{- "\n"}}
+  {{- range $ix, $option := .PageEntries}}
+    {{- template "option" ($.IterateOption $ix $option) }}
+  {{- end}}
+{{- end}}`
+
+	survey.SelectQuestionTemplate = `
+{{- if .ShowHelp }}{{color "cyan"}}{{ .Help }}{{color "reset"}}{{"\n"}}{{end}}
+{{- color "cyan"}}{{ .Message }}{{ .FilterMessage }}
+{{- if .ShowAnswer}}{{color "cyan"}} {{.Answer}}{{color "reset"}}{{"\n"}}
+{{- else }}
+  {{- "  "}}{{- color "cyan"}}[使用箭头键移动，回车键确认，? 查看帮助]{{color "reset"}}
+  {{- "\n"}}
+  {{- range $ix, $option := .PageEntries}}
+    {{- if eq $.SelectedIndex $ix }}{{color "cyan"}}> {{ $option.Value }}{{color "reset"}}{{else}}  {{ $option.Value }}{{end}}
+    {{- "\n"}}
+  {{- end}}
+{{- end}}`
+
+	// 命令行参数模式：有参数则分析一次后退出，无参数则进入主菜单
+	apiKeyFlag := flag.String("apikey", "", "DeepSeek API Key")
+	modelFlag := flag.String("model", "", "DeepSeek 模型名")
+	stockFlag := flag.String("stock", "", "股票代码（可批量，逗号分隔）")
+	startFlag := flag.String("start", "", "开始日期 YYYY-MM-DD")
+	endFlag := flag.String("end", "", "结束日期 YYYY-MM-DD")
+	modeFlag := flag.String("mode", "", "分析模式: reason/search")
+	periodsFlag := flag.String("periods", "", "预测周期, 逗号分隔")
+	dimsFlag := flag.String("dims", "", "分析维度, 逗号分隔")
+	outputFlag := flag.String("output", "", "输出格式, 逗号分隔")
+	confidenceFlag := flag.String("confidence", "", "是否需要置信度说明 Y/N")
+	riskFlag := flag.String("risk", "", "风险/机会偏好")
+	scopeFlag := flag.String("scope", "", "联网搜索内容范围, 逗号分隔")
+	langFlag := flag.String("lang", "zh", "分析语言 zh/en")
+	historyFlag := flag.Bool("history", false, "列出分析历史记录")
+	historyStockFlag := flag.String("history-stock", "", "配合 -history 按股票代码筛选历史记录")
+	historySinceFlag := flag.String("history-since", "", "配合 -history 筛选该日期（YYYY-MM-DD，含）之后生成的记录")
+	historyUntilFlag := flag.String("history-until", "", "配合 -history 筛选该日期（YYYY-MM-DD，含）之前生成的记录")
+	historyFormatFlag := flag.String("history-format", "", "配合 -history 按格式筛选，逗号分隔，如 md,pdf")
+	showFlag := flag.String("show", "", "显示指定历史分析记录")
+	statsFlag := flag.Int("stats", 0, "统计最近N天历史报告的看多/看空/中性比例及行业分布")
+	marketFlag := flag.String("market", "", "配合 -stats/-model-stats 按市场筛选：A股/港股/美股，默认全部")
+	modelStatsFlag := flag.Int("model-stats", 0, "按模型统计最近N天预测的方向命中率与目标价误差，输出模型质量排行")
+	evalDaysFlag := flag.Int("eval-days", analysis.ModelEvalHorizonDays, "配合 -model-stats 的预测评估期（天）")
+	scheduleFlag := flag.String("schedule", "", "定时任务周期，如 1h、daily、weekly:Mon:09:30、cron:30 15 * * 1-5")
+	exportFlag := flag.String("export", "md", "导出格式，逗号分隔，支持md,html,pdf,json")
+	pdfEngineFlag := flag.String("pdf-engine", analysis.PDFEngine, "PDF 引擎 auto/chrome/wkhtmltopdf，auto 优先本地 Chrome、不可用时回退 wkhtmltopdf，均不可用时保留 HTML")
+	emailFlag := flag.String("email", "", "收件人邮箱，逗号分隔")
+	smtpServerFlag := flag.String("smtp-server", "", "SMTP服务器")
+	smtpPortFlag := flag.Int("smtp-port", 465, "SMTP端口")
+	smtpTLSFlag := flag.String("smtp-tls", analysis.SMTPTLSMode, "SMTP 加密方式 auto/tls/starttls/none，auto 按端口选择：465 隐式 TLS、587 等 STARTTLS、25 明文")
+	smtpInsecureFlag := flag.Bool("smtp-insecure", false, "不校验 SMTP 服务器证书（仅用于自签名证书的内网服务器，密码可能被中间人窃取）")
+	smtpUserFlag := flag.String("smtp-user", "", "SMTP用户名")
+	smtpPassFlag := flag.String("smtp-pass", "", "SMTP密码")
+	webhookFlag := flag.String("webhook", "", "IM webhook地址（钉钉/企业微信；https://hooks.slack.com/ 开头的地址按 Slack 发送）")
+	webhookMarkdownFlag := flag.Bool("webhook-markdown", false, "钉钉/企业微信以 markdown 消息推送（表格可读），超长截断并附完整报告链接")
+	webhookSecretFlag := flag.String("webhook-secret", "", "钉钉机器人加签密钥（SEC 开头），开启加签安全设置时必填")
+	telegramTokenFlag := flag.String("telegram-token", "", "Telegram Bot Token，配合 -telegram-chat 推送报告（超长自动分段）")
+	telegramChatFlag := flag.String("telegram-chat", "", "Telegram 接收消息的 Chat ID")
+	detailFlag := flag.String("detail", "normal", "分析详细程度 normal/detailed/extreme")
+	updateActualFlag := flag.Bool("update-actual", false, "批量补全预测的实际行情（T+1、T+5、T+20）")
+	profileFlag := flag.String("profile", "", "套用参数预设，如 intraday/swing/value 或 profiles 目录下的自定义预设")
+	saveProfileFlag := flag.String("save-profile", "", "将当前命令行参数保存为指定名称的预设")
+	checkBalanceFlag := flag.Bool("check-balance", false, "分析前查询 DeepSeek 账户余额，不足时提前警告")
+	outlierFlag := flag.String("outlier", analysis.OutlierDrop, "异常K线处理模式 drop/repair（repair 插值或前值填充，保持序列连续）")
+	baseCurrencyFlag := flag.String("base-currency", analysis.BaseCurrency, "本币，外币标的价格附带本币折算，如 CNY/USD/HKD")
+	fxURLFlag := flag.String("fx-url", analysis.FXRateURL, "汇率接口地址，%s 为原币代码，返回需包含 rates 字段")
+	serveFlag := flag.String("serve", "", "以 HTTP 服务方式运行异步分析任务 API，如 :8080（配合 -apikey/-model 作为默认值）")
+	wsOriginsFlag := flag.String("ws-origins", "", "允许跨域连接 /ws/quotes 的页面来源，逗号分隔，如 https://dash.example.com；为空时只允许同主机页面")
+	serveTokenFlag := flag.String("serve-token", "", "HTTP/gRPC 服务的访问令牌，请求未带 api_key 时须以 Authorization: Bearer <令牌> 鉴权才使用 -apikey；为空时请求须自带 api_key")
+	grpcFlag := flag.String("grpc", "", "以 gRPC 服务方式运行分析 API，如 :9090（proto/quantix.proto），可与 -serve 同时开启并共享任务")
+	riskFreeFlag := flag.Float64("risk-free-rate", analysis.RiskFreeRate, "年化无风险利率，用于计算夏普比率")
+	varMethodFlag := flag.String("var-method", analysis.VaRMethod, "VaR 计算方法 historical/parametric/cornish-fisher（历史模拟/正态参数法/偏度峰度修正）")
+	concurrencyFlag := flag.Int("concurrency", analysis.BatchConcurrency, "批量分析并发数（多只股票/多种模式同时分析）")
+	webhookTemplateFlag := flag.String("webhook-template", "", "IM 推送消息模板文件（Go text/template，可引用 .StockCode/.Summary/.Recommendation/.Link 等）")
+	recordSamplesFlag := flag.String("record-samples", "", "将数据源原始响应保存到指定目录，供回放调试")
+	replaySamplesFlag := flag.String("replay-samples", "", "回放模式：数据源请求改为读取指定目录下的样本，不访问网络")
+	dataSourceFlag := flag.String("data-source", analysis.DataSourceOnline, "行情来源 online/csv，csv 时从 -csv-dir 目录按 {code}.csv 读取K线，不访问行情接口")
+	csvDirFlag := flag.String("csv-dir", analysis.DefaultCSVDir, "csv 模式的K线目录；配合 -stock all 分析目录下全部股票")
+	roundTimeoutFlag := flag.Duration("round-timeout", 0, "定时任务每轮批量分析的总超时（如 30m），超时未完成的股票直接结束，0 表示不限")
+	archiveDaysFlag := flag.Int("archive-days", 0, "将超过N天的历史报告按月打包到 history/archive 并从目录移除，查看时自动从归档读取")
+	fetchRetriesFlag := flag.Int("fetch-retries", analysis.FetchMaxRetries, "行情接口遇到网络错误、429 或 5xx 时的最大重试次数（指数退避）")
+	llmRetriesFlag := flag.Int("llm-retries", analysis.LLMMaxRetries, "大模型接口返回 429 限流或 503 过载时的最大重试次数（优先按 Retry-After 等待，否则指数退避）")
+	temperatureFlag := flag.Float64("temperature", 0, "大模型采样温度，0 表示默认 0.7，越低越稳健、越高越发散")
+	maxTokensFlag := flag.Int("max-tokens", 0, "单次生成的最大响应 token 数，0 表示默认 2000，极致分析可适当调大避免截断")
+	apiBaseFlag := flag.String("api-base", analysis.DeepSeekBaseURL, "大模型 OpenAI 兼容接口前缀（拼接 /chat/completions、/models），可指向自建代理或 https://openrouter.ai/api/v1")
+	subscriptionsFlag := flag.String("subscriptions", analysis.SubscriptionFile, "邮件订阅配置（邮箱-股票列表），定时任务每轮按订阅分发对应股票报告")
+	fillMissingFlag := flag.Bool("fill-missing", false, "行情缺失交易日（对比交易日历）时用相邻K线线性插补")
+	redactFlag := flag.Bool("redact", false, "导出与推送前按脱敏规则屏蔽持仓金额、账户、邮箱、手机号等敏感信息")
+	redactRulesFlag := flag.String("redact-rules", analysis.RedactRulesFile, "自定义脱敏规则文件（与内置规则合并）")
+	allowSourcesFlag := flag.String("allow-sources", "", "合规白名单：允许的行情数据源，逗号分隔（xueqiu,netease,tencent,yahoo，资金流向 eastmoney），默认不限制")
+	allowLLMFlag := flag.String("allow-llm", "", "合规白名单：允许的大模型服务，逗号分隔，可填 deepseek/gemini 或接口域名，默认不限制")
+	dbFlag := flag.String("db", analysis.DBPath, "分析结果 SQLite 库路径，每次分析成功后写入股票、时间、模型、趋势、置信度、风险等级与报告文件，为空时不写库")
+	redisFlag := flag.String("redis", "", "Redis 地址（host:port），缓存相同行情的机器学习预测结果，为空或不可用时直接计算")
+	cacheTTLFlag := flag.Duration("cache-ttl", analysis.MLCacheTTL, "机器学习预测缓存时长，如 30m、2h")
+	modelPathFlag := flag.String("model-path", analysis.MLModelPath, "随机森林模型保存目录（HTTP 服务 POST /models 训练、GET /models 列出）")
+	logOutputFlag := flag.String("log-output", logger.OutputStdout, "日志输出 stdout/stderr/file，file 时写入 -log-file 并按大小轮转")
+	logFileFlag := flag.String("log-file", filepath.Join("logs", "quantix.log"), "配合 -log-output file 的日志文件")
+	logMaxSizeFlag := flag.Int("log-max-size", logger.DefaultMaxSizeMB, "单个日志文件达到该大小（MB）后轮转")
+	logMaxBackupsFlag := flag.Int("log-max-backups", logger.DefaultMaxBackups, "保留的轮转日志文件数")
+	logMaxAgeFlag := flag.Int("log-max-age", logger.DefaultMaxAgeDays, "轮转日志文件保留天数")
+	configFlag := flag.String("config", analysis.ConfigFile, "配置文件（YAML），未显式指定的 API Key、模型、温度、SMTP、webhook、收件人从中读取")
+	flag.Parse()
+
+	// 记录显式设置的 flag，套用配置文件与预设时不覆盖它们
+	setFlags := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+	applyConfigFile(*configFlag, setFlags)
+	if err := logger.InitLogger(logger.LogConfig{
+		Output:     *logOutputFlag,
+		File:       *logFileFlag,
+		MaxSizeMB:  *logMaxSizeFlag,
+		MaxBackups: *logMaxBackupsFlag,
+		MaxAgeDays: *logMaxAgeFlag,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, "[日志] 初始化失败，日志输出到标准输出：", err)
+		logger.InitLogger(logger.LogConfig{Output: logger.OutputStdout})
+	}
+
+	if *outlierFlag == analysis.OutlierRepair {
+		analysis.OutlierMode = analysis.OutlierRepair
+	}
+	analysis.RiskFreeRate = *riskFreeFlag
+	switch *varMethodFlag {
+	case analysis.VaRHistorical, analysis.VaRParametric, analysis.VaRCornishFisher:
+		analysis.VaRMethod = *varMethodFlag
+	default:
+		fmt.Printf("[参数错误] -var-method 仅支持 historical/parametric/cornish-fisher，使用默认 %s\n", analysis.VaRMethod)
+	}
+	analysis.FetchMaxRetries = *fetchRetriesFlag
+	analysis.LLMMaxRetries = *llmRetriesFlag
+	analysis.ScheduleRoundTimeout = *roundTimeoutFlag
+	analysis.DBPath = *dbFlag
+	analysis.MLModelPath = *modelPathFlag
+	analysis.RedisAddr = *redisFlag
+	if *cacheTTLFlag > 0 {
+		analysis.MLCacheTTL = *cacheTTLFlag
+	}
+	analysis.FillMissingTradingDays = *fillMissingFlag
+	analysis.DeepSeekBaseURL = *apiBaseFlag
+	analysis.SubscriptionFile = *subscriptionsFlag
+	analysis.RedactExport = *redactFlag
+	analysis.RedactRulesFile = *redactRulesFlag
+	analysis.AllowedDataSources = splitAndTrim(*allowSourcesFlag)
+	analysis.AllowedLLMs = splitAndTrim(*allowLLMFlag)
+	analysis.BatchConcurrency = *concurrencyFlag
+	analysis.PDFEngine = *pdfEngineFlag
+	analysis.SMTPTLSMode = *smtpTLSFlag
+	analysis.SMTPInsecureSkipVerify = *smtpInsecureFlag
+	if *smtpInsecureFlag {
+		fmt.Println("[邮件] ⚠️  已关闭 SMTP 证书校验，仅应用于受信任的内网服务器")
+	}
+	analysis.WebhookMarkdown = *webhookMarkdownFlag
+	analysis.WebhookSecret = *webhookSecretFlag
+	if *webhookTemplateFlag != "" {
+		if data, err := ioutil.ReadFile(*webhookTemplateFlag); err != nil {
+			fmt.Println("[IM推送] 读取推送模板失败，使用默认格式：", err)
+		} else {
+			analysis.WebhookTemplate = string(data)
 		}
-	} else {
-		// Gemini
-		printStepBox("Step 0: API Key",
-			"请输入 Gemini API Key（可留空自动读取环境变量 GEMINI_API_KEY）",
-			"说明：用于访问 Gemini LLM 服务",
-		)
-		apiKey = os.Getenv("GEMINI_API_KEY")
-		if apiKey == "" {
-			fmt.Print("请输入 Gemini API Key: ")
-			apiKey, _ = reader.ReadString('\n')
-			apiKey = strings.TrimSpace(apiKey)
+	}
+	if *replaySamplesFlag != "" {
+		analysis.FetchTransport = analysis.NewReplayTransport(*replaySamplesFlag)
+		fmt.Printf("[样本] 回放模式，数据源样本目录：%s\n", *replaySamplesFlag)
+	} else if *recordSamplesFlag != "" {
+		analysis.FetchTransport = analysis.NewRecordingTransport(*recordSamplesFlag, nil)
+		fmt.Printf("[样本] 数据源原始响应将保存到：%s\n", *recordSamplesFlag)
+	}
+	analysis.BaseCurrency = strings.ToUpper(*baseCurrencyFlag)
+	analysis.FXRateURL = *fxURLFlag
+
+	if *saveProfileFlag != "" {
+		profile := analysis.Profile{
+			Name:       *saveProfileFlag,
+			Periods:    splitAndTrim(*periodsFlag),
+			Dims:       splitAndTrim(*dimsFlag),
+			Risk:       *riskFlag,
+			Detail:     *detailFlag,
+			Confidence: (*confidenceFlag == "Y" || *confidenceFlag == "y"),
 		}
-		if apiKey == "" {
-			fmt.Println("未检测到 Gemini API Key，无法继续。")
+		if err := analysis.SaveProfile(profile); err != nil {
+			fmt.Println("[预设] 保存失败：", err)
 			return
 		}
-		printStepBox("Step 1: AI Model",
-			"选择要使用的Gemini模型",
-			"说明：不同模型分析能力和速度略有差异",
-		)
-		models = []string{"gemini-1.5-flash", "gemini-1.5-pro", "gemini-2.5-flash", "gemini-2.5-pro"}
+		fmt.Printf("[预设] 已保存：%s\n", filepath.Join(analysis.ProfileDir, *saveProfileFlag+".json"))
+		return
 	}
-	model := promptForModel(models)
-	printStepBox("Step 1: AI Model", fmt.Sprintf("[当前选择]: %s", model))
 
-	// Step 2: 股票代码
-	printStepBox("Step 2: Ticker Symbol",
-		"Enter the ticker symbol(s) to analyze",
-		"说明：可批量，逗号分隔。如 600036,000001",
-		"Default: 600036",
-	)
-	stockInput := interactiveInput("请输入股票代码（可批量，逗号分隔）:", "")
-	stockCodes := splitAndTrim(stockInput)
-	if len(stockCodes) == 0 || stockCodes[0] == "" {
-		fmt.Println("股票代码不能为空！")
+	if *updateActualFlag {
+		updateActualPricesWithDeepSeek()
 		return
 	}
-	printStepBox("Step 2: Ticker Symbol", fmt.Sprintf("[当前选择]: %s", strings.Join(stockCodes, ", ")))
-
-	// Step 3: 日期
-	today := time.Now()
-	defaultEnd := today.Format("2006-01-02")
-	defaultStart := today.AddDate(0, 0, -30).Format("2006-01-02")
-	printStepBox("Step 3: Analysis Date",
-		"Enter the analysis date range (YYYY-MM-DD)",
-		fmt.Sprintf("Default: %s ~ %s", defaultStart, defaultEnd),
-	)
-	start := interactiveInput(fmt.Sprintf("请输入开始日期(YYYY-MM-DD, 默认%s):", defaultStart), defaultStart)
-	end := interactiveInput(fmt.Sprintf("请输入结束日期(YYYY-MM-DD, 默认%s):", defaultEnd), defaultEnd)
-	printStepBox("Step 3: Analysis Date", fmt.Sprintf("[当前选择]: %s ~ %s", start, end))
-
-	// Step 4: 分析模式
-	var searchMode string
-	var searchModes []string
-	if llmType == "DeepSeek" {
-		printStepBox("Step 4: Analysis Mode",
-			"Select your analysis mode",
-			"说明：深度思考仅用模型推理，联网搜索结合互联网信息，混合模式自动融合",
-		)
-		modeOptions := []string{"深度思考（仅用模型推理）", "联网搜索（结合最新互联网信息）", "深度思考+联网搜索（自动融合）"}
-		defaultMode := "深度思考（仅用模型推理）"
-		searchMode = interactiveSingleSelect("请选择分析模式（单选）：", modeOptions, defaultMode)
-		printStepBox("Step 4: Analysis Mode", fmt.Sprintf("[当前选择]: %s", searchMode))
-		// 定义 searchModes
-		switch searchMode {
-		case "深度思考（仅用模型推理）":
-			searchModes = []string{"深度思考（仅用模型推理）"}
-		case "联网搜索（结合最新互联网信息）":
-			searchModes = []string{"联网搜索（结合最新互联网信息）"}
-		case "深度思考+联网搜索（自动融合）":
-			searchModes = []string{"深度思考+联网搜索（自动融合）"}
-		default:
-			searchModes = []string{"深度思考（仅用模型推理）"}
-		}
-	} else if llmType == "Gemini" && model == "gemini-2.5-pro" {
-		modeOptions := []string{"深度思考（仅用模型推理）", "联网搜索（Deep Search）"}
-		searchMode = interactiveSingleSelect("请选择分析模式（单选）：", modeOptions, modeOptions[0])
-		printStepBox("Step 4: Analysis Mode", fmt.Sprintf("[当前选择]: %s", searchMode))
-		searchModes = []string{searchMode}
-	} else {
-		searchMode = "深度思考（仅用模型推理）"
-		searchModes = []string{searchMode}
-		printStepBox("Step 4: Analysis Mode", "该 Gemini 模型仅支持深度思考")
-	}
-
-	// Step 5: 预测参数
-	printStepBox("Step 5: Prediction Options",
-		"选择预测周期、分析维度、输出格式等",
-		"说明：可多选，回车确认",
-	)
-	periods, dims, searchScope, outputFormat, needConfidence, riskPref := promptForPredictionOptions()
-	printStepBox("Step 5: Prediction Options",
-		fmt.Sprintf("[周期]: %s", strings.Join(periods, ", ")),
-		fmt.Sprintf("[维度]: %s", strings.Join(dims, ", ")),
-		fmt.Sprintf("[输出]: %s", outputFormat),
-		fmt.Sprintf("[置信度]: %v", needConfidence),
-		fmt.Sprintf("[风险偏好]: %s", riskPref),
-		fmt.Sprintf("[联网范围]: %s", strings.Join(searchScope, ", ")),
-	)
-
-	// Step 5.5: 详细预测选项
-	printStepBox("Step 5.5: Detailed Prediction Options",
-		"选择具体的预测项目和参数",
-		"说明：可多选，回车确认",
-	)
-
-	// 预测类型选择
-	predictionTypeOptions := []string{
-		"价格预测", "波动率预测", "成交量预测", "涨跌概率预测",
-		"技术指标预测", "基本面指标预测", "情绪评分预测",
-		"市场定位预测", "竞争优势预测", "风险等级预测",
-	}
-	defaultPredictionTypes := []string{"价格预测", "涨跌概率预测", "风险等级预测"}
-	predictionTypes := interactiveSelectList("请选择预测类型（可多选）：", predictionTypeOptions, defaultPredictionTypes)
-
-	// 具体预测项目选择
-	predictionItemOptions := []string{
-		"目标价位预测", "止损位预测", "止盈位预测", "波动率预测",
-		"成交量预测", "涨跌概率预测", "风险等级预测", "趋势强度预测",
-		"支撑阻力位预测", "技术信号预测", "基本面指标预测", "情绪评分预测",
-		"市场定位分析", "竞争优势分析",
-	}
-	defaultPredictionItems := []string{"目标价位预测", "止损位预测", "止盈位预测", "涨跌概率预测"}
-	predictionItems := interactiveSelectList("请选择具体预测项目（可多选）：", predictionItemOptions, defaultPredictionItems)
-
-	printStepBox("Step 5.5: Detailed Prediction Options",
-		fmt.Sprintf("[预测类型]: %s", strings.Join(predictionTypes, ", ")),
-		fmt.Sprintf("[预测项目]: %s", strings.Join(predictionItems, ", ")),
-	)
-
-	// Step 6: 语言选择
-	printStepBox("Step 6: Language",
-		"Select analysis language",
-		"说明：支持中文和英文",
-	)
-	langOptions := []string{"中文", "英文"}
-	defaultLang := []string{"中文"}
-	langResult := interactiveSelectList("请选择分析语言：", langOptions, defaultLang)
-	var lang string
-	if len(langResult) > 0 && langResult[0] == "英文" {
-		lang = "en"
-	} else {
-		lang = "zh"
-	}
-	printStepBox("Step 6: Language", fmt.Sprintf("[当前选择]: %s", lang))
-
-	// Step 7: 导出格式
-	printStepBox("Step 7: Export Format",
-		"Select export format(s)",
-		"说明：可多选，支持 Markdown/HTML/PDF",
-	)
-	exportOptions := []string{"Markdown", "HTML", "PDF"}
-	defaultExport := []string{"Markdown"}
-	exportResult := interactiveSelectList("请选择导出格式（可多选）：", exportOptions, defaultExport)
-	exportFormats := make([]string, 0, len(exportResult))
-	for _, fmtx := range exportResult {
-		switch fmtx {
-		case "Markdown":
-			exportFormats = append(exportFormats, "md")
-		case "HTML":
-			exportFormats = append(exportFormats, "html")
-		case "PDF":
-			exportFormats = append(exportFormats, "pdf")
+	if *historyStockFlag != "" || *historySinceFlag != "" || *historyUntilFlag != "" || *historyFormatFlag != "" {
+		filter, err := historyFilterFromFlags(*historyStockFlag, *historySinceFlag, *historyUntilFlag, *historyFormatFlag)
+		if err != nil {
+			fmt.Println("[参数错误]", err)
+			return
 		}
+		analysis.ListHistoryEntries(filter)
+		return
 	}
-	if len(exportFormats) == 0 {
-		exportFormats = []string{"md"}
-	}
-	printStepBox("Step 7: Export Format", fmt.Sprintf("[当前选择]: %s", strings.Join(exportFormats, ", ")))
-
-	// Step 8: 邮件推送
-	printStepBox("Step 8: Email Push",
-		"如需邮件推送请输入收件人邮箱（可逗号分隔，留空跳过）",
-	)
-	emailInput := interactiveInput("如需邮件推送请输入收件人邮箱（可逗号分隔，留空跳过）:", "")
-	emails := splitAndTrim(emailInput)
-	var smtpServer, smtpUser, smtpPass string
-	if len(emails) > 0 && emails[0] != "" {
-		fmt.Println("SMTP服务器、端口、用户名、密码依次输入：")
-		fmt.Print("SMTP服务器: ")
-		smtpServer, _ = reader.ReadString('\n')
-		smtpServer = strings.TrimSpace(smtpServer)
-		fmt.Print("SMTP端口(默认465): ")
-		portInput := interactiveInput("SMTP端口(默认465):", "")
-		if portInput == "" {
-			// smtpPort = 465
-		}
-		fmt.Print("SMTP用户名: ")
-		smtpUser, _ = reader.ReadString('\n')
-		smtpUser = strings.TrimSpace(smtpUser)
-		fmt.Print("SMTP密码: ")
-		smtpPass, _ = reader.ReadString('\n')
-		smtpPass = strings.TrimSpace(smtpPass)
+	if *historyFlag {
+		analysis.ListHistoryFiles()
+		return
 	}
-	printStepBox("Step 8: Email Push", fmt.Sprintf("[当前邮箱]: %s", strings.Join(emails, ", ")))
-
-	// Step 9: IM 推送
-	printStepBox("Step 9: IM Push",
-		"如需IM推送请输入Webhook地址（钉钉/企业微信，留空跳过）",
-	)
-	webhook := interactiveInput("如需IM推送请输入Webhook地址（钉钉/企业微信，留空跳过）:", "")
-	printStepBox("Step 9: IM Push", fmt.Sprintf("[当前Webhook]: %s", webhook))
-
-	// Step 10: 分析详细程度
-	printStepBox("Step 10: Research Depth",
-		"Select your research depth level",
-		"说明：影响分析细致程度和报告内容",
-	)
-	detailOptions := []string{"普通分析 - 基础技术指标和简要分析", "详细分析 - 多维度深度分析，包含更多指标", "极致分析 - 最全面的分析，包含所有可用指标和深度洞察"}
-	defaultDetail := []string{"普通分析 - 基础技术指标和简要分析"}
-	detailResult := interactiveSelectList("请选择分析详细程度：", detailOptions, defaultDetail)
-	var detailInput string
-	var detailText string
-	if len(detailResult) > 0 {
-		switch detailResult[0] {
-		case "普通分析 - 基础技术指标和简要分析":
-			detailInput = "normal"
-		case "详细分析 - 多维度深度分析，包含更多指标":
-			detailInput = "detailed"
-		case "极致分析 - 最全面的分析，包含所有可用指标和深度洞察":
-			detailInput = "extreme"
-		default:
-			detailInput = "normal"
+	if *archiveDaysFlag > 0 {
+		n, err := analysis.ArchiveHistory(*archiveDaysFlag, time.Now())
+		if err != nil {
+			fmt.Println("[归档] 归档失败：", err)
 		}
-		detailText = detailResult[0]
-	} else {
-		detailInput = "normal"
-		detailText = "普通分析 - 基础技术指标和简要分析"
-	}
-	printStepBox("Step 10: Research Depth", fmt.Sprintf("[当前选择]: %s", detailText))
-
-	// Step 11: 回测策略类型与参数
-	printStepBox("Step 11: Backtest Strategy",
-		"选择回测策略类型及参数",
-		"支持：均线交叉、突破、RSI等",
-	)
-	strategyOptions := []string{"均线交叉(ma_cross)", "突破(breakout)", "RSI(rsi)"}
-	defaultStrategy := "均线交叉(ma_cross)"
-	strategy := interactiveSingleSelect("请选择回测策略类型：", strategyOptions, defaultStrategy)
-
-	var backtestParams analysis.BacktestParams
-	switch strategy {
-	case "均线交叉(ma_cross)":
-		backtestParams.StrategyType = "ma_cross"
-		backtestParams.FastMAPeriod = interactiveInputInt("请输入快速均线周期（如5）:", 5)
-		backtestParams.SlowMAPeriod = interactiveInputInt("请输入慢速均线周期（如20）:", 20)
-	case "突破(breakout)":
-		backtestParams.StrategyType = "breakout"
-		backtestParams.BreakoutPeriod = interactiveInputInt("请输入突破周期（如10）:", 10)
-	case "RSI(rsi)":
-		backtestParams.StrategyType = "rsi"
-		backtestParams.RSIPeriod = interactiveInputInt("请输入RSI周期（如14）:", 14)
-		backtestParams.RSIOverbought = interactiveInputFloat("请输入RSI超买阈值（如70）:", 70)
-		backtestParams.RSIOversold = interactiveInputFloat("请输入RSI超卖阈值（如30）:", 30)
-	}
-	// 通用参数
-	backtestParams.StopLoss = interactiveInputFloat("请输入止损百分比（如0.05表示5%）:", 0.05)
-	backtestParams.TakeProfit = interactiveInputFloat("请输入止盈百分比（如0.10表示10%）:", 0.10)
-	backtestParams.InitialCash = interactiveInputFloat("请输入初始资金（如100000）:", 100000)
-	printStepBox("Step 11: Backtest Strategy", fmt.Sprintf("[当前策略]: %s, 参数: %+v", backtestParams.StrategyType, backtestParams))
-
-	// ====== 修复：定时周期输入和 dur 赋值 ======
-	scheduleInput := interactiveInput("请输入定时周期（如 1h、10m、daily）:", "1h")
-	dur, err := parseSchedule(scheduleInput)
-	if err != nil {
-		fmt.Println("[定时任务] 格式错误：", err)
+		fmt.Printf("[归档] 已将 %d 份超过 %d 天的报告归档至 %s\n", n, *archiveDaysFlag, analysis.ArchiveDir)
 		return
 	}
-	// =========================================
-
-	params := analysis.AnalysisParams{
-		LLMType:              llmType,
-		APIKey:               apiKey,
-		Model:                model,
-		StockCodes:           stockCodes,
-		Start:                start,
-		End:                  end,
-		Periods:              periods,
-		Dims:                 dims,
-		Output:               exportFormats,
-		Confidence:           needConfidence,
-		Risk:                 riskPref,
-		Scope:                searchScope,
-		Lang:                 lang,
-		PredictionTypes:      predictionTypes,
-		TargetPrice:          contains(predictionItems, "目标价位预测"),
-		StopLoss:             contains(predictionItems, "止损位预测"),
-		TakeProfit:           contains(predictionItems, "止盈位预测"),
-		Volatility:           contains(predictionItems, "波动率预测"),
-		Volume:               contains(predictionItems, "成交量预测"),
-		Probability:          contains(predictionItems, "涨跌概率预测"),
-		RiskLevel:            contains(predictionItems, "风险等级预测"),
-		TrendStrength:        contains(predictionItems, "趋势强度预测"),
-		SupportResistance:    contains(predictionItems, "支撑阻力位预测"),
-		TechnicalSignals:     contains(predictionItems, "技术信号预测"),
-		FundamentalMetrics:   contains(predictionItems, "基本面指标预测"),
-		SentimentScore:       contains(predictionItems, "情绪评分预测"),
-		MarketPosition:       contains(predictionItems, "市场定位分析"),
-		CompetitiveAdvantage: contains(predictionItems, "竞争优势分析"),
-		BacktestParams:       &backtestParams,
-		// 新增：分析模式参数
-		SearchMode:   (searchMode == "联网搜索（结合最新互联网信息）") || (llmType == "Gemini" && model == "gemini-2.5-pro" && searchMode == "联网搜索（Deep Search）"),
-		HybridSearch: searchMode == "深度思考+联网搜索（自动融合）",
-	}
-
-	fmt.Println("\n=== 定时任务已启动，Ctrl+C 可随时终止 ===")
-	for {
-		fmt.Printf("\n[%s] 批量分析开始\n", time.Now().Format("2006-01-02 15:04:05"))
-		done := make(chan struct{})
-		go showAnalyzingAnimation(done)
-		prompt := buildPromptWithDetail(params, detailInput)
-
-		results := make([]analysis.AnalysisResult, 0, len(params.StockCodes)*len(searchModes))
-		for _, mode := range searchModes {
-			for _, code := range params.StockCodes {
-				p := params
-				p.StockCodes = []string{code}
-				p.SearchMode = (mode == "联网搜索（结合最新互联网信息）")
-				p.HybridSearch = (mode == "深度思考+联网搜索（自动融合）")
-				result := analysis.AnalyzeOne(p, func(stock, _prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool) (string, error) {
-					return analysis.GenerateAIReportWithConfigAndSearch(stock, prompt, apiKey, "https://api.deepseek.com/v1/chat/completions", model, searchMode, hybridSearch)
-				})
-				results = append(results, result)
-			}
+	if *serveFlag != "" || *grpcFlag != "" {
+		server := analysis.NewTaskServer(*apiKeyFlag, *modelFlag)
+		server.AuthToken = *serveTokenFlag
+		analysis.QuoteWSAllowedOrigins = splitAndTrim(*wsOriginsFlag)
+		if server.APIKey != "" && server.AuthToken == "" {
+			fmt.Println("[任务服务] 未设置 -serve-token，默认 API Key 不对外提供，请求须自带 api_key")
 		}
-		for _, r := range results {
-			fmt.Printf("\n=== [%s] AI 智能分析报告 ===\n", r.StockCode)
-			if r.Err != nil {
-				fmt.Println("[AI] 生成失败:", r.Err)
-			} else {
-				// 分离图片引用和正文
-				reportLines := strings.Split(r.Report, "\n")
-				var imgLines, textLines []string
-				for _, l := range reportLines {
-					if strings.HasPrefix(l, "![图表](") {
-						imgLines = append(imgLines, l)
-					} else if strings.TrimSpace(l) != "" {
-						textLines = append(textLines, l)
-					}
+		done := make(chan struct{}, 2)
+		if *grpcFlag != "" {
+			go func() {
+				defer func() { done <- struct{}{} }()
+				lis, err := net.Listen("tcp", *grpcFlag)
+				if err != nil {
+					fmt.Println("[gRPC服务] 启动失败：", err)
+					return
 				}
-				// 先输出图片引用
-				for _, l := range imgLines {
-					fmt.Println(l)
+				fmt.Printf("[gRPC服务] 监听 %s：quantix.v1.QuantixService\n", *grpcFlag)
+				if err := analysis.NewGRPCServer(server).Serve(lis); err != nil {
+					fmt.Println("[gRPC服务] 退出：", err)
 				}
-				// 用框输出正文
-				if len(textLines) > 0 {
-					printStepBox("AI 智能分析报告", textLines...)
+			}()
+		}
+		if *serveFlag != "" {
+			go func() {
+				defer func() { done <- struct{}{} }()
+				fmt.Printf("[任务服务] 监听 %s：POST /analyze，GET /tasks/{id}，DELETE /tasks/{id}，GET /stocks/{code}/risk，GET /stocks/{code}/prediction，GET /stocks/{code}/backtest，GET /compare，GET /ws/quotes/{code}，GET /metrics\n", *serveFlag)
+				if err := http.ListenAndServe(*serveFlag, server.Handler()); err != nil {
+					fmt.Println("[任务服务] 启动失败：", err)
 				}
-				fmt.Printf("[历史已保存: %s]\n", r.SavedFile)
-			}
+			}()
 		}
-		close(done)
-		fmt.Printf("[定时任务] 下一次将在 %s 后运行，Ctrl+C 可终止。\n", dur)
-		time.Sleep(dur)
+		// 任一服务退出即结束进程
+		<-done
+		return
 	}
-}
-
-func main() {
-	survey.ErrorTemplate = `
-{{- color "red"}}提示：{{.Error.Error}}{{color "reset"}}
-`
-	survey.MultiSelectQuestionTemplate = `
-{{- define "option"}}
-    {{- if eq $.SelectedIndex $.CurrentIndex }}{{color "cyan"}}> {{if index $.Checked $.CurrentOpt.Index }}[✓]{{else}}[ ]{{end}} {{$.CurrentOpt.Value}}{{color "reset"}}{{else}}  {{if index $.Checked $.CurrentOpt.Index }}[✓]{{else}}[ ]{{end}} {{$.CurrentOpt.Value}}{{end}}
-{{"\n"}}
-{{- end}}
-{{- if .ShowHelp }}{{color "cyan"}}{{ .Help }}{{color "reset"}}{{"\n"}}{{end}}
-{{- color "cyan"}}{{ .Message }}{{ .FilterMessage }}
-{{- if .ShowAnswer}}{{color "cyan"}} {{.Answer}}{{color "reset"}}{{"\n"}}
-{{- else }}
-  {{- "  "}}{{- color "cyan"}}[使用箭头键移动，空格键选择，右箭头键全选，左箭头键全不选，输入过滤，? 查看帮助]{{color "reset"}}
-  {{- "\n"}}
-  {{- range $ix, $option := .PageEntries}}
-    {{- template "option" ($.IterateOption $ix $option) }}
-  {{- end}}
-{{- end}}`
-
-	survey.SelectQuestionTemplate = `
-{{- if .ShowHelp }}{{color "cyan"}}{{ .Help }}{{color "reset"}}{{"\n"}}{{end}}
-{{- color "cyan"}}{{ .Message }}{{ .FilterMessage }}
-{{- if .ShowAnswer}}{{color "cyan"}} {{.Answer}}{{color "reset"}}{{"\n"}}
-{{- else }}
-  {{- "  "}}{{- color "cyan"}}[使用箭头键移动，回车键确认，? 查看帮助]{{color "reset"}}
-  {{- "\n"}}
-  {{- range $ix, $option := .PageEntries}}
-    {{- if eq $.SelectedIndex $ix }}{{color "cyan"}}> {{ $option.Value }}{{color "reset"}}{{else}}  {{ $option.Value }}{{end}}
-    {{- "\n"}}
-  {{- end}}
-{{- end}}`
-
-	// 命令行参数模式：有参数则分析一次后退出，无参数则进入主菜单
-	apiKeyFlag := flag.String("apikey", "", "DeepSeek API Key")
-	modelFlag := flag.String("model", "", "DeepSeek 模型名")
-	stockFlag := flag.String("stock", "", "股票代码（可批量，逗号分隔）")
-	startFlag := flag.String("start", "", "开始日期 YYYY-MM-DD")
-	endFlag := flag.String("end", "", "结束日期 YYYY-MM-DD")
-	modeFlag := flag.String("mode", "", "分析模式: reason/search")
-	periodsFlag := flag.String("periods", "", "预测周期, 逗号分隔")
-	dimsFlag := flag.String("dims", "", "分析维度, 逗号分隔")
-	outputFlag := flag.String("output", "", "输出格式, 逗号分隔")
-	confidenceFlag := flag.String("confidence", "", "是否需要置信度说明 Y/N")
-	riskFlag := flag.String("risk", "", "风险/机会偏好")
-	scopeFlag := flag.String("scope", "", "联网搜索内容范围, 逗号分隔")
-	langFlag := flag.String("lang", "zh", "分析语言 zh/en")
-	historyFlag := flag.Bool("history", false, "列出分析历史记录")
-	showFlag := flag.String("show", "", "显示指定历史分析记录")
-	scheduleFlag := flag.String("schedule", "", "定时任务周期，如 1h、daily（预留）")
-	exportFlag := flag.String("export", "md", "导出格式，逗号分隔，支持md,html,pdf")
-	emailFlag := flag.String("email", "", "收件人邮箱，逗号分隔")
-	smtpServerFlag := flag.String("smtp-server", "", "SMTP服务器")
-	smtpPortFlag := flag.Int("smtp-port", 465, "SMTP端口")
-	smtpUserFlag := flag.String("smtp-user", "", "SMTP用户名")
-	smtpPassFlag := flag.String("smtp-pass", "", "SMTP密码")
-	webhookFlag := flag.String("webhook", "", "IM webhook地址")
-	detailFlag := flag.String("detail", "normal", "分析详细程度 normal/detailed/extreme")
-	updateActualFlag := flag.Bool("update-actual", false, "批量补全预测的实际行情（T+1、T+5、T+20）")
-	flag.Parse()
-
-	if *updateActualFlag {
-		updateActualPricesWithDeepSeek()
+	if *statsFlag > 0 {
+		records, err := analysis.LoadConclusionRecords()
+		if err != nil {
+			fmt.Println("[结论统计] 读取历史结论失败：", err)
+			return
+		}
+		since := time.Now().AddDate(0, 0, -*statsFlag)
+		fmt.Println(analysis.FormatConclusionStats(analysis.SummarizeConclusions(records, since, *marketFlag)))
 		return
 	}
-	if *historyFlag {
-		analysis.ListHistoryFiles()
+	if *modelStatsFlag > 0 {
+		records, err := analysis.LoadConclusionRecords()
+		if err != nil {
+			fmt.Println("[模型质量] 读取历史结论失败：", err)
+			return
+		}
+		since := time.Now().AddDate(0, 0, -*modelStatsFlag)
+		horizon := time.Duration(*evalDaysFlag) * 24 * time.Hour
+		fmt.Println(analysis.FormatModelQuality(analysis.EvaluateModels(records, since, *marketFlag, horizon), *evalDaysFlag))
 		return
 	}
 	if *showFlag != "" {
@@ -1414,7 +1458,31 @@ func main() {
 	}
 	// 判断是否为命令行参数模式
 	if *apiKeyFlag != "" && *modelFlag != "" && *stockFlag != "" {
+		if *dataSourceFlag != analysis.DataSourceOnline && *dataSourceFlag != analysis.DataSourceCSV {
+			fmt.Println("[参数错误] -data-source 仅支持 online/csv")
+			return
+		}
 		stockCodes := splitAndTrim(*stockFlag)
+		if *dataSourceFlag == analysis.DataSourceCSV && len(stockCodes) == 1 && stockCodes[0] == "all" {
+			codes, err := analysis.CSVStockCodes(*csvDirFlag)
+			if err != nil {
+				fmt.Println("[数据源] 读取 CSV 目录失败：", err)
+				return
+			}
+			fmt.Printf("[数据源] CSV 目录 %s 下共 %d 只股票\n", *csvDirFlag, len(codes))
+			stockCodes = codes
+		}
+		if len(stockCodes) == 0 {
+			fmt.Println("[参数错误] -stock 未包含有效的股票代码")
+			return
+		}
+		if err := analysis.CheckLLMAllowed(analysis.ChatCompletionsURL(analysis.DeepSeekBaseURL)); err != nil {
+			fmt.Println("[合规]", err)
+			return
+		}
+		if *checkBalanceFlag {
+			checkDeepSeekBalance(*apiKeyFlag)
+		}
 		var hybridSearch bool
 		if *modeFlag == "hybrid" {
 			hybridSearch = true
@@ -1444,6 +1512,19 @@ func main() {
 			Risk:         *riskFlag,
 			Scope:        splitAndTrim(*scopeFlag),
 			Lang:         *langFlag,
+			Temperature:  *temperatureFlag,
+			MaxTokens:    *maxTokensFlag,
+			DataSource:   *dataSourceFlag,
+			CSVDir:       *csvDirFlag,
+		}
+		if *profileFlag != "" {
+			profile, err := analysis.LoadProfile(*profileFlag)
+			if err != nil {
+				fmt.Println("[预设] 加载失败：", err, "可用预设：", strings.Join(analysis.ListProfiles(), ", "))
+				return
+			}
+			applyProfile(profile, &params, detailFlag, setFlags)
+			fmt.Printf("[预设] 已套用：%s %s\n", profile.Name, profile.Description)
 		}
 		emails := splitAndTrim(*emailFlag)
 		exportFormats := splitAndTrim(*exportFlag)
@@ -1455,7 +1536,7 @@ func main() {
 			*scheduleFlag = schedule
 		}
 		if schedule := strings.TrimSpace(*scheduleFlag); schedule != "" {
-			dur, err := parseSchedule(schedule)
+			sched, err := analysis.ParseSchedule(schedule)
 			if err != nil {
 				fmt.Println("[定时任务] 格式错误：", err)
 				return
@@ -1465,24 +1546,15 @@ func main() {
 				fmt.Printf("\n[%s] 批量分析开始\n", time.Now().Format("2006-01-02 15:04:05"))
 				done := make(chan struct{})
 				go showAnalyzingAnimation(done)
-				prompt := buildPromptWithDetail(params, *detailFlag)
-				results := make([]analysis.AnalysisResult, 0, len(params.StockCodes)*len(searchModes))
-				for _, mode := range searchModes {
-					for _, code := range params.StockCodes {
-						p := params
-						p.StockCodes = []string{code}
-						p.SearchMode = (mode == "联网搜索（结合最新互联网信息）")
-						p.HybridSearch = (mode == "深度思考+联网搜索（自动融合）")
-						result := analysis.AnalyzeOne(p, func(stock, _prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool) (string, error) {
-							return analysis.GenerateAIReportWithConfigAndSearch(stock, prompt, apiKey, "https://api.deepseek.com/v1/chat/completions", model, searchMode, hybridSearch)
-						})
-						results = append(results, result)
-					}
-				}
+				ctx, cancel := analysis.RoundContext(context.Background())
+				results := analysis.AnalyzeBatch(ctx, batchParams(params, searchModes, *detailFlag), func(stock, prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool) (string, error) {
+					return analysis.GenerateAIReportWithGeneration(ctx, stock, prompt, apiKey, apiURL, model, searchMode, hybridSearch, params.Generation())
+				}, analysis.BatchConcurrency)
+				cancel()
 				for _, r := range results {
 					fmt.Printf("\n=== [%s] AI 智能分析报告 ===\n", r.StockCode)
 					if r.Err != nil {
-						fmt.Println("[AI] 生成失败:", r.Err)
+						printAnalysisError(r)
 					} else {
 						// 分离图片引用和正文
 						reportLines := strings.Split(r.Report, "\n")
@@ -1507,52 +1579,35 @@ func main() {
 						// 导出报告功能已移除
 						if len(emails) > 0 && emails[0] != "" && *smtpServerFlag != "" && *smtpUserFlag != "" && *smtpPassFlag != "" {
 							var attachs []string
-							err := analysis.SendEmail(*smtpServerFlag, *smtpPortFlag, *smtpUserFlag, *smtpPassFlag, emails, "Quantix分析报告", r.Report, attachs)
+							err := sendReportEmail(*smtpServerFlag, *smtpPortFlag, *smtpUserFlag, *smtpPassFlag, emails, r.Report, attachs, contains(exportFormats, "html"))
 							if err != nil {
 								fmt.Println("[邮件发送失败]", err)
 							} else {
 								fmt.Println("[邮件已发送]")
 							}
 						}
-						if *webhookFlag != "" {
-							err := analysis.SendWebhook(*webhookFlag, r.Report)
-							if err != nil {
-								fmt.Println("[IM推送失败]", err)
-							} else {
-								fmt.Println("[IM已推送]")
-							}
-						}
+						pushIM(*webhookFlag, *telegramTokenFlag, *telegramChatFlag, r)
 					}
 				}
-				fmt.Printf("[定时任务] 下一次将在 %s 后运行，Ctrl+C 可终止。\n", dur)
-				time.Sleep(dur)
-				if schedule == "daily" {
-					dur, _ = parseSchedule("daily") // 重新计算到明天0点的间隔
-				}
+				printSectorSummary(results)
+				sendSubscriptionMails(results, *smtpServerFlag, *smtpPortFlag, *smtpUserFlag, *smtpPassFlag)
+				wait := sched.NextRun(time.Now())
+				fmt.Printf("[定时任务] 下一次将在 %s 后运行，Ctrl+C 可终止。\n", wait.Round(time.Second))
+				time.Sleep(wait)
 				close(done)
 			}
-			mainMenu() // 分析完进入主菜单
 		}
 		done := make(chan struct{})
 		go showAnalyzingAnimation(done)
-		prompt := buildPromptWithDetail(params, *detailFlag)
-		results := make([]analysis.AnalysisResult, 0, len(params.StockCodes)*len(searchModes))
-		for _, mode := range searchModes {
-			for _, code := range params.StockCodes {
-				p := params
-				p.StockCodes = []string{code}
-				p.SearchMode = (mode == "联网搜索（结合最新互联网信息）")
-				p.HybridSearch = (mode == "深度思考+联网搜索（自动融合）")
-				result := analysis.AnalyzeOne(p, func(stock, _prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool) (string, error) {
-					return analysis.GenerateAIReportWithConfigAndSearch(stock, prompt, apiKey, "https://api.deepseek.com/v1/chat/completions", model, searchMode, hybridSearch)
-				})
-				results = append(results, result)
-			}
-		}
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		results := analysis.AnalyzeBatch(ctx, batchParams(params, searchModes, *detailFlag), func(stock, prompt, apiKey, apiURL, model string, searchMode bool, hybridSearch bool) (string, error) {
+			return analysis.GenerateAIReportWithGeneration(ctx, stock, prompt, apiKey, apiURL, model, searchMode, hybridSearch, params.Generation())
+		}, analysis.BatchConcurrency)
+		stop()
 		for _, r := range results {
 			fmt.Printf("\n=== [%s] AI 智能分析报告 ===\n", r.StockCode)
 			if r.Err != nil {
-				fmt.Println("[AI] 生成失败:", r.Err)
+				printAnalysisError(r)
 			} else {
 				// 分离图片引用和正文
 				reportLines := strings.Split(r.Report, "\n")
@@ -1577,23 +1632,17 @@ func main() {
 				// 导出报告功能已移除
 				if len(emails) > 0 && emails[0] != "" && *smtpServerFlag != "" && *smtpUserFlag != "" && *smtpPassFlag != "" {
 					var attachs []string
-					err := analysis.SendEmail(*smtpServerFlag, *smtpPortFlag, *smtpUserFlag, *smtpPassFlag, emails, "Quantix分析报告", r.Report, attachs)
+					err := sendReportEmail(*smtpServerFlag, *smtpPortFlag, *smtpUserFlag, *smtpPassFlag, emails, r.Report, attachs, contains(exportFormats, "html"))
 					if err != nil {
 						fmt.Println("[邮件发送失败]", err)
 					} else {
 						fmt.Println("[邮件已发送]")
 					}
 				}
-				if *webhookFlag != "" {
-					err := analysis.SendWebhook(*webhookFlag, r.Report)
-					if err != nil {
-						fmt.Println("[IM推送失败]", err)
-					} else {
-						fmt.Println("[IM已推送]")
-					}
-				}
+				pushIM(*webhookFlag, *telegramTokenFlag, *telegramChatFlag, r)
 			}
 		}
+		printSectorSummary(results)
 		close(done)
 		mainMenu()
 	}
@@ -1601,6 +1650,94 @@ func main() {
 	mainMenu()
 }
 
+// applyProfile 套用预设，命令行显式指定的参数优先
+func applyProfile(profile analysis.Profile, params *analysis.AnalysisParams, detail *string, setFlags map[string]bool) {
+	explicit := *params
+	profile.Apply(params)
+	if setFlags["periods"] {
+		params.Periods = explicit.Periods
+	}
+	if setFlags["dims"] {
+		params.Dims = explicit.Dims
+	}
+	if setFlags["risk"] {
+		params.Risk = explicit.Risk
+	}
+	if setFlags["confidence"] {
+		params.Confidence = explicit.Confidence
+	}
+	if !setFlags["detail"] && profile.Detail != "" {
+		*detail = profile.Detail
+	}
+}
+
+// applyConfigFile 读取配置文件，将其中的值填入命令行未显式指定的 flag；文件不存在时跳过
+func applyConfigFile(path string, setFlags map[string]bool) {
+	cfg, err := analysis.LoadConfig(path)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Println("[配置] 读取失败，忽略配置文件：", err)
+		}
+		return
+	}
+	smtpInsecure := ""
+	if cfg.Notify.SMTPInsecure {
+		smtpInsecure = "true"
+	}
+	values := map[string]string{
+		"apikey":        cfg.DeepSeek.APIKey,
+		"model":         cfg.DeepSeek.Model,
+		"api-base":      cfg.DeepSeek.APIURL,
+		"temperature":   strconv.FormatFloat(cfg.DeepSeek.Temperature, 'f', -1, 64),
+		"llm-retries":   strconv.Itoa(cfg.DeepSeek.MaxRetries),
+		"smtp-server":   cfg.Notify.SMTPServer,
+		"smtp-port":     strconv.Itoa(cfg.Notify.SMTPPort),
+		"smtp-user":     cfg.Notify.SMTPUser,
+		"smtp-pass":     cfg.Notify.SMTPPass,
+		"smtp-tls":      cfg.Notify.SMTPTLS,
+		"smtp-insecure": smtpInsecure,
+		"webhook":       cfg.Notify.Webhook,
+		"email":         strings.Join(cfg.Notify.Emails, ","),
+		"db":            cfg.Storage.DBPath,
+		"model-path":    cfg.ML.ModelPath,
+		"redis":         cfg.Storage.RedisAddr,
+		"cache-ttl":     (time.Duration(cfg.Storage.CacheExpiration) * time.Second).String(),
+	}
+	for name, value := range values {
+		if setFlags[name] || value == "" {
+			continue
+		}
+		if err := flag.Set(name, value); err != nil {
+			fmt.Printf("[配置] %s 的值无效，已忽略：%v\n", name, err)
+		}
+	}
+	if cfg.DeepSeek.APIKey != "" && !setFlags["apikey"] {
+		// 交互模式同样使用配置中的 API Key
+		globalAPIKey = cfg.DeepSeek.APIKey
+	}
+	fmt.Printf("[配置] 已加载 %s\n", path)
+}
+
+// historyFilterFromFlags 由 -history-* 参数构造查询条件，until 当天的记录也包含在内
+func historyFilterFromFlags(stock, since, until, formats string) (analysis.HistoryFilter, error) {
+	filter := analysis.HistoryFilter{StockCode: strings.TrimSpace(stock), Formats: splitAndTrim(formats)}
+	if since != "" {
+		t, err := time.ParseInLocation("2006-01-02", since, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("-history-since 格式应为 YYYY-MM-DD: %s", since)
+		}
+		filter.Since = t
+	}
+	if until != "" {
+		t, err := time.ParseInLocation("2006-01-02", until, time.Local)
+		if err != nil {
+			return filter, fmt.Errorf("-history-until 格式应为 YYYY-MM-DD: %s", until)
+		}
+		filter.Until = t.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
 // contains 检查字符串数组中是否包含指定字符串
 func contains(arr []string, item string) bool {
 	for _, i := range arr {
@@ -1623,10 +1760,9 @@ func updateActualPricesWithDeepSeek() {
 	}
 
 	// 获取可用模型
-	models, err := fetchDeepSeekModels(apiKey, "")
+	models, err := analysis.FetchDeepSeekModels(apiKey, "")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "[预测追踪] 获取模型失败: %v\n", err)
-		return
+		fmt.Fprintf(os.Stderr, "[预测追踪] %v\n", err)
 	}
 
 	deepseekModels := make([]string, 0)
@@ -1647,7 +1783,7 @@ func updateActualPricesWithDeepSeek() {
 		return
 	}
 
-	csvPath := "history/predictions.csv"
+	csvPath := analysis.PredictionsFile
 	f, err := os.Open(csvPath)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "[预测追踪] 无法打开CSV: %v\n", err)
@@ -1749,7 +1885,7 @@ func updateActualPricesWithDeepSeek() {
 
 		// 调用 DeepSeek API
 		result, err := analysis.GenerateAIReportWithConfigAndSearch(
-			stock, prompt, apiKey, "https://api.deepseek.com/v1/chat/completions",
+			stock, prompt, apiKey, analysis.ChatCompletionsURL(analysis.DeepSeekBaseURL),
 			model, true, true) // 启用联网搜索
 
 		if err != nil {
@@ -1786,44 +1922,26 @@ func updateActualPricesWithDeepSeek() {
 
 	if updated {
 		// 保存更新后的预测记录
-		f, err := os.Create("history/predictions.csv")
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "[预测追踪] 无法保存CSV: %v\n", err)
-			return
-		}
-		defer f.Close()
-
-		writer := csv.NewWriter(f)
-		defer writer.Flush()
-
-		for _, row := range records {
-			err := writer.Write(row)
-			if err != nil {
-				fmt.Fprintf(os.Stderr, "[预测追踪] 写入CSV失败: %v\n", err)
-				return
-			}
+		if err := analysis.WriteCSVAtomic(csvPath, records); err != nil {
+			fmt.Fprintf(os.Stderr, "[预测追踪] 保存CSV失败，原文件未改动: %v\n", err)
 		}
 	}
 }
 
-// parseActualPricesFromTable 从 AI 返回的 markdown 表格中解析实际价格
+// parseActualPricesFromTable 从 AI 返回的 markdown 表格中取“收盘价”列的实际价格
 func parseActualPricesFromTable(result string) []string {
 	prices := make([]string, 0)
-	lines := strings.Split(result, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "|") && strings.Contains(line, "|") {
-			if strings.Contains(line, "日期") || strings.Contains(line, "---") {
-				continue
-			}
-			parts := strings.Split(line, "|")
-			if len(parts) >= 3 {
-				price := strings.TrimSpace(parts[2])
-				if price != "" && price != "收盘价" {
-					prices = append(prices, price)
-				}
+	for _, t := range analysis.ParseMarkdownTables(result) {
+		col := t.Column("收盘")
+		if col < 0 {
+			continue
+		}
+		for _, row := range t.Rows {
+			if row[col] != "" {
+				prices = append(prices, row[col])
 			}
 		}
+		break
 	}
 	return prices
 }
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"Quantix/analysis"
+)
+
+func TestBatchParamsBuildsPerJobPrompt(t *testing.T) {
+	params := analysis.AnalysisParams{StockCodes: []string{"600036", "000001"}, Start: "2024-01-01", End: "2024-06-30"}
+	modes := []string{"深度思考（本地数据）", "联网搜索（结合最新互联网信息）"}
+	jobs := batchParams(params, modes, "extreme")
+	if len(jobs) != 4 {
+		t.Fatalf("任务数 = %d，期望 4", len(jobs))
+	}
+	for _, j := range jobs {
+		code, other := j.StockCodes[0], "000001"
+		if code == other {
+			other = "600036"
+		}
+		if !strings.Contains(j.Prompt, code) || strings.Contains(j.Prompt, other) {
+			t.Errorf("%s 的 prompt 应只包含本股票代码:\n%s", code, j.Prompt)
+		}
+		if !strings.Contains(j.Prompt, "极致详细分析要求") {
+			t.Errorf("%s 的 prompt 缺少详细程度要求", code)
+		}
+		if want := analysis.BuildPrompt(j); !strings.HasPrefix(j.Prompt, want) {
+			t.Errorf("%s 的 prompt 与联网模式 %v 不符", code, j.SearchMode)
+		}
+	}
+}
+
+func TestMaskKey(t *testing.T) {
+	for _, c := range []struct{ in, want string }{
+		{"", "(未设置)"},
+		{"abc", "***"},
+		{"12345678", "********"},
+		{"sk-1234567890abcdefg", "sk-12345..."},
+	} {
+		if got := maskKey(c.in); got != c.want {
+			t.Errorf("maskKey(%q) = %q，期望 %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestSplitAndTrimDedupKeepsOrder(t *testing.T) {
+	for _, c := range []struct {
+		in   string
+		want []string
+	}{
+		{"600036,600036, ,000001", []string{"600036", "000001"}},
+		{" 000001 ,600036,  000001,600519 ", []string{"000001", "600036", "600519"}},
+		{"AAPL,,aapl", []string{"AAPL", "aapl"}},
+		{" , ,", nil},
+		{"", nil},
+	} {
+		if got := splitAndTrim(c.in); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitAndTrim(%q) = %q，期望 %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestHistoryFilterFromFlags(t *testing.T) {
+	filter, err := historyFilterFromFlags(" 600036 ", "2024-06-01", "2024-06-10", "md, pdf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	since, until := time.Date(2024, 6, 1, 0, 0, 0, 0, time.Local), time.Date(2024, 6, 11, 0, 0, 0, 0, time.Local)
+	if filter.StockCode != "600036" || !filter.Since.Equal(since) || !filter.Until.Equal(until) || !reflect.DeepEqual(filter.Formats, []string{"md", "pdf"}) {
+		t.Errorf("查询条件 = %+v，until 当天应包含在内", filter)
+	}
+	for _, c := range [][2]string{{"2024/06/01", ""}, {"", "昨天"}} {
+		if _, err := historyFilterFromFlags("", c[0], c[1], ""); err == nil || !strings.Contains(err.Error(), "YYYY-MM-DD") {
+			t.Errorf("日期 %q/%q 非法时应报错: %v", c[0], c[1], err)
+		}
+	}
+}
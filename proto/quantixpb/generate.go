@@ -0,0 +1,4 @@
+package quantixpb
+
+// 修改 proto/quantix.proto 后在本目录执行 go generate 重新生成，需安装 protoc、protoc-gen-go v1.34.2 与 protoc-gen-go-grpc v1.5.1
+//go:generate protoc -I .. --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative quantix.proto
@@ -0,0 +1,146 @@
+// Package storage 用 SQLite 保存每次分析的元信息，便于按股票、模型、时间等统计查询
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS analyses (
+	id          INTEGER PRIMARY KEY AUTOINCREMENT,
+	stock_code  TEXT    NOT NULL,
+	created_at  INTEGER NOT NULL,
+	model       TEXT    NOT NULL DEFAULT '',
+	trend       TEXT    NOT NULL DEFAULT '',
+	confidence  REAL    NOT NULL DEFAULT 0,
+	risk_level  TEXT    NOT NULL DEFAULT '',
+	report_file TEXT    NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_analyses_stock_time ON analyses(stock_code, created_at);
+`
+
+// Analysis 一次分析的元信息
+type Analysis struct {
+	ID         int64     `json:"id"`
+	StockCode  string    `json:"stock_code"`
+	Time       time.Time `json:"time"`
+	Model      string    `json:"model"`
+	Trend      string    `json:"trend"`      // 偏多/偏空/中性
+	Confidence float64   `json:"confidence"` // 报告给出的置信度（%），未提及为 0
+	RiskLevel  string    `json:"risk_level"`
+	ReportFile string    `json:"report_file"` // history 下的报告文件名
+}
+
+// Filter 查询条件，零值字段不参与筛选；Since 含、Until 不含
+type Filter struct {
+	StockCode string
+	Model     string
+	Trend     string
+	RiskLevel string
+	Since     time.Time
+	Until     time.Time
+	Limit     int // 最多返回条数，0 表示不限
+}
+
+// DB 分析结果库
+type DB struct {
+	db *sql.DB
+}
+
+// Open 打开（不存在时创建）数据库文件并建表
+func Open(path string) (*DB, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("打开数据库 %s 失败: %v", path, err)
+	}
+	// SQLite 单写者，限制为一个连接，避免并发写入时报 database is locked
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("初始化数据库 %s 失败: %v", path, err)
+	}
+	return &DB{db: db}, nil
+}
+
+// Close 关闭数据库
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// SaveAnalysis 写入一条分析记录，Time 为零时取当前时间，返回记录 ID
+func (d *DB) SaveAnalysis(a Analysis) (int64, error) {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	res, err := d.db.Exec(`INSERT INTO analyses (stock_code, created_at, model, trend, confidence, risk_level, report_file)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		a.StockCode, a.Time.UnixNano(), a.Model, a.Trend, a.Confidence, a.RiskLevel, a.ReportFile)
+	if err != nil {
+		return 0, fmt.Errorf("写入分析记录失败: %v", err)
+	}
+	return res.LastInsertId()
+}
+
+// QueryAnalyses 按条件查询分析记录，按时间倒序
+func (d *DB) QueryAnalyses(f Filter) ([]Analysis, error) {
+	var conds []string
+	var args []interface{}
+	for _, c := range []struct {
+		column, value string
+	}{
+		{"stock_code", f.StockCode},
+		{"model", f.Model},
+		{"trend", f.Trend},
+		{"risk_level", f.RiskLevel},
+	} {
+		if c.value != "" {
+			conds = append(conds, c.column+" = ?")
+			args = append(args, c.value)
+		}
+	}
+	if !f.Since.IsZero() {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.Since.UnixNano())
+	}
+	if !f.Until.IsZero() {
+		conds = append(conds, "created_at < ?")
+		args = append(args, f.Until.UnixNano())
+	}
+	query := "SELECT id, stock_code, created_at, model, trend, confidence, risk_level, report_file FROM analyses"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+	if f.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, f.Limit)
+	}
+	rows, err := d.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("查询分析记录失败: %v", err)
+	}
+	defer rows.Close()
+	var list []Analysis
+	for rows.Next() {
+		var a Analysis
+		var ts int64
+		if err := rows.Scan(&a.ID, &a.StockCode, &ts, &a.Model, &a.Trend, &a.Confidence, &a.RiskLevel, &a.ReportFile); err != nil {
+			return nil, fmt.Errorf("读取分析记录失败: %v", err)
+		}
+		a.Time = time.Unix(0, ts)
+		list = append(list, a)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSaveAndQueryAnalyses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "quantix.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	base := time.Date(2024, 6, 1, 10, 0, 0, 0, time.Local)
+	rows := []Analysis{
+		{StockCode: "600036", Time: base, Model: "deepseek-chat", Trend: "偏多", Confidence: 70, RiskLevel: "中", ReportFile: "a.md"},
+		{StockCode: "600036", Time: base.Add(24 * time.Hour), Model: "deepseek-reasoner", Trend: "偏空", Confidence: 55, RiskLevel: "高", ReportFile: "b.md"},
+		{StockCode: "AAPL", Time: base.Add(48 * time.Hour), Model: "deepseek-chat", Trend: "偏多", RiskLevel: "低", ReportFile: "c.md"},
+	}
+	for i, a := range rows {
+		id, err := db.SaveAnalysis(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id != int64(i+1) {
+			t.Errorf("第 %d 条 ID = %d", i, id)
+		}
+	}
+
+	all, err := db.QueryAnalyses(Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 || all[0].ReportFile != "c.md" || all[2].ReportFile != "a.md" {
+		t.Fatalf("应按时间倒序返回全部记录: %+v", all)
+	}
+	got := all[2]
+	if got.ID != 1 || got.StockCode != "600036" || !got.Time.Equal(base) || got.Model != "deepseek-chat" ||
+		got.Trend != "偏多" || got.Confidence != 70 || got.RiskLevel != "中" {
+		t.Errorf("字段未完整往返: %+v", got)
+	}
+
+	for _, c := range []struct {
+		name   string
+		filter Filter
+		want   []string
+	}{
+		{"按股票", Filter{StockCode: "600036"}, []string{"b.md", "a.md"}},
+		{"按模型与趋势", Filter{Model: "deepseek-chat", Trend: "偏多"}, []string{"c.md", "a.md"}},
+		{"按风险等级", Filter{RiskLevel: "高"}, []string{"b.md"}},
+		{"时间区间含起不含止", Filter{Since: base.Add(24 * time.Hour), Until: base.Add(48 * time.Hour)}, []string{"b.md"}},
+		{"条数限制", Filter{Limit: 1}, []string{"c.md"}},
+		{"无匹配", Filter{StockCode: "000001"}, nil},
+	} {
+		list, err := db.QueryAnalyses(c.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var files []string
+		for _, a := range list {
+			files = append(files, a.ReportFile)
+		}
+		if !reflect.DeepEqual(files, c.want) {
+			t.Errorf("%s: 得到 %v，期望 %v", c.name, files, c.want)
+		}
+	}
+
+	// 重新打开后数据仍在，建表语句可重复执行
+	db.Close()
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := db.QueryAnalyses(Filter{}); len(list) != 3 {
+		t.Errorf("重新打开后记录数 = %d，期望 3", len(list))
+	}
+}
+
+func TestSaveAnalysisDefaultsTime(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "q.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	before := time.Now()
+	if _, err := db.SaveAnalysis(Analysis{StockCode: "600036"}); err != nil {
+		t.Fatal(err)
+	}
+	list, _ := db.QueryAnalyses(Filter{})
+	if len(list) != 1 || list[0].Time.Before(before) {
+		t.Errorf("未指定时间时应取当前时间: %+v", list)
+	}
+}